@@ -0,0 +1,283 @@
+// Package audit implements an append-only log of actions done by users of
+// the gateway and the command line. Every entry contains the hash of the
+// previous one, so changing or removing an entry in the middle of the log
+// breaks the chain and is detected by Verify. The log is stored as JSON
+// lines, which makes it easy to export and process with other tools.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	e "github.com/pkg/errors"
+)
+
+const (
+	// SourceCLI is used for actions done via the daemon's API.
+	SourceCLI = "cli"
+	// SourceGateway is used for actions done via the gateway.
+	SourceGateway = "gateway"
+)
+
+// Entry is a single action in the audit log.
+type Entry struct {
+	// Seq is the number of the entry, starting at 1.
+	Seq uint64 `json:"seq"`
+	// Time is when the action happened (in UTC).
+	Time time.Time `json:"time"`
+	// Source is where the action came from (SourceCLI or SourceGateway).
+	Source string `json:"source"`
+	// User is the gateway user or the repo owner for the command line.
+	User string `json:"user"`
+	// Addr is the network address of the client, if known.
+	Addr string `json:"addr,omitempty"`
+	// Action is what happened, like »fs.remove« or »gateway.login«.
+	Action string `json:"action"`
+	// Path is the path the action was done on, if any.
+	Path string `json:"path,omitempty"`
+	// Detail is free-form additional information.
+	Detail string `json:"detail,omitempty"`
+	// Prev is the hash of the previous entry (empty for the first).
+	Prev string `json:"prev"`
+	// Hash is the hash over all other fields of this entry.
+	Hash string `json:"hash"`
+}
+
+func (en Entry) computeHash() (string, error) {
+	en.Hash = ""
+	data, err := json.Marshal(en)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Query describes what entries should be returned by Log.Query.
+// Empty fields match everything.
+type Query struct {
+	// User only matches entries of this user.
+	User string
+	// Path matches entries on this path or below it.
+	Path string
+	// Action matches entries whose action starts with it (»fs.« e.g.)
+	Action string
+	// Since matches entries that happened at or after this time.
+	Since time.Time
+}
+
+// Matches returns true if `en` matches the query.
+func (q Query) Matches(en Entry) bool {
+	if q.User != "" && q.User != en.User {
+		return false
+	}
+
+	if q.Action != "" && !strings.HasPrefix(en.Action, q.Action) {
+		return false
+	}
+
+	if !q.Since.IsZero() && en.Time.Before(q.Since) {
+		return false
+	}
+
+	if q.Path != "" {
+		root := path.Clean("/" + q.Path)
+		if root != "/" && en.Path != root && !strings.HasPrefix(en.Path, root+"/") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Log is an audit log stored in a single file.
+// A nil *Log is valid and does not record anything.
+type Log struct {
+	mu       sync.Mutex
+	path     string
+	fd       *os.File
+	lastSeq  uint64
+	lastHash string
+}
+
+// Open opens or creates the log at `path`.
+func Open(path string) (*Log, error) {
+	lg := &Log{path: path}
+
+	// Find out where the chain currently ends:
+	err := lg.iter(func(en Entry) error {
+		lg.lastSeq = en.Seq
+		lg.lastHash = en.Hash
+		return nil
+	})
+
+	if err != nil && !os.IsNotExist(e.Cause(err)) {
+		return nil, err
+	}
+
+	fd, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	lg.fd = fd
+	return lg, nil
+}
+
+// Close closes the underlying file.
+func (lg *Log) Close() error {
+	if lg == nil {
+		return nil
+	}
+
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	return lg.fd.Close()
+}
+
+// Record adds `en` to the log. Seq, Time, Prev and Hash are filled in.
+func (lg *Log) Record(en Entry) error {
+	if lg == nil {
+		return nil
+	}
+
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	en.Seq = lg.lastSeq + 1
+	en.Time = time.Now().UTC()
+	en.Prev = lg.lastHash
+
+	hash, err := en.computeHash()
+	if err != nil {
+		return err
+	}
+
+	en.Hash = hash
+
+	data, err := json.Marshal(en)
+	if err != nil {
+		return err
+	}
+
+	if _, err := lg.fd.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	// Entries should survive a crash right after the action:
+	if err := lg.fd.Sync(); err != nil {
+		return err
+	}
+
+	lg.lastSeq = en.Seq
+	lg.lastHash = en.Hash
+	return nil
+}
+
+func (lg *Log) iter(fn func(en Entry) error) error {
+	fd, err := os.Open(lg.path)
+	if err != nil {
+		return err
+	}
+
+	defer fd.Close()
+	return Read(fd, fn)
+}
+
+// Query calls `fn` for every entry matching `q`, oldest first.
+func (lg *Log) Query(q Query, fn func(en Entry) error) error {
+	if lg == nil {
+		return nil
+	}
+
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	return lg.iter(func(en Entry) error {
+		if !q.Matches(en) {
+			return nil
+		}
+
+		return fn(en)
+	})
+}
+
+// Verify checks the hash chain of the whole log.
+// See the Verify function for the return values.
+func (lg *Log) Verify() (uint64, string, error) {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	fd, err := os.Open(lg.path)
+	if err != nil {
+		return 0, "", err
+	}
+
+	defer fd.Close()
+	return Verify(fd)
+}
+
+// Read calls `fn` for every entry in the log stream `r`.
+func Read(r io.Reader, fn func(en Entry) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		en := Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), &en); err != nil {
+			return e.Wrapf(err, "audit log is corrupt at line %d", line)
+		}
+
+		if err := fn(en); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Verify checks that all entries in `r` are unmodified and form a complete
+// chain. It returns the number of entries and the hash of the last one.
+// Note that removing entries from the end can only be detected by comparing
+// the returned hash with one that was noted down earlier.
+func Verify(r io.Reader) (uint64, string, error) {
+	count, lastHash := uint64(0), ""
+	err := Read(r, func(en Entry) error {
+		if en.Seq != count+1 {
+			return fmt.Errorf("audit entry %d: expected sequence number %d", en.Seq, count+1)
+		}
+
+		if en.Prev != lastHash {
+			return fmt.Errorf("audit entry %d: chain is broken", en.Seq)
+		}
+
+		hash, err := en.computeHash()
+		if err != nil {
+			return err
+		}
+
+		if hash != en.Hash {
+			return fmt.Errorf("audit entry %d: was modified", en.Seq)
+		}
+
+		count, lastHash = en.Seq, en.Hash
+		return nil
+	})
+
+	return count, lastHash, err
+}
@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func withLog(t *testing.T, fn func(lg *Log, path string)) {
+	dir, err := ioutil.TempDir("", "brig-audit-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	lg, err := Open(path)
+	require.Nil(t, err)
+
+	fn(lg, path)
+	require.Nil(t, lg.Close())
+}
+
+func TestRecordAndQuery(t *testing.T) {
+	withLog(t, func(lg *Log, path string) {
+		require.Nil(t, lg.Record(Entry{Source: SourceCLI, User: "ali", Action: "fs.stage", Path: "/x/y"}))
+		require.Nil(t, lg.Record(Entry{Source: SourceGateway, User: "bob", Action: "gateway.login"}))
+		require.Nil(t, lg.Record(Entry{Source: SourceGateway, User: "bob", Action: "fs.remove", Path: "/xy"}))
+
+		collect := func(q Query) []Entry {
+			entries := []Entry{}
+			require.Nil(t, lg.Query(q, func(en Entry) error {
+				entries = append(entries, en)
+				return nil
+			}))
+			return entries
+		}
+
+		require.Len(t, collect(Query{}), 3)
+		require.Len(t, collect(Query{User: "bob"}), 2)
+		require.Len(t, collect(Query{Action: "fs."}), 2)
+		require.Len(t, collect(Query{Since: time.Now().Add(time.Hour)}), 0)
+
+		byPath := collect(Query{Path: "/x"})
+		require.Len(t, byPath, 1)
+		require.Equal(t, "/x/y", byPath[0].Path)
+		require.Equal(t, uint64(1), byPath[0].Seq)
+
+		count, head, err := lg.Verify()
+		require.Nil(t, err)
+		require.Equal(t, uint64(3), count)
+		require.NotEmpty(t, head)
+
+		// Re-opening should continue the chain:
+		reopened, err := Open(path)
+		require.Nil(t, err)
+		defer reopened.Close()
+		require.Nil(t, reopened.Record(Entry{Source: SourceCLI, User: "ali", Action: "vcs.commit"}))
+
+		count, _, err = reopened.Verify()
+		require.Nil(t, err)
+		require.Equal(t, uint64(4), count)
+	})
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	withLog(t, func(lg *Log, path string) {
+		for _, user := range []string{"ali", "bob", "charlie"} {
+			require.Nil(t, lg.Record(Entry{Source: SourceCLI, User: user, Action: "fs.stage"}))
+		}
+
+		data, err := ioutil.ReadFile(path)
+		require.Nil(t, err)
+
+		_, _, err = Verify(bytes.NewReader(data))
+		require.Nil(t, err)
+
+		// Modify an entry:
+		modified := strings.Replace(string(data), `"user":"bob"`, `"user":"eve"`, 1)
+		_, _, err = Verify(strings.NewReader(modified))
+		require.Error(t, err)
+
+		// Remove an entry in the middle:
+		lines := strings.SplitAfter(string(data), "\n")
+		removed := lines[0] + lines[2]
+		_, _, err = Verify(strings.NewReader(removed))
+		require.Error(t, err)
+	})
+}
+
+func TestNilLog(t *testing.T) {
+	var lg *Log
+	require.Nil(t, lg.Record(Entry{Action: "fs.stage"}))
+	require.Nil(t, lg.Query(Query{}, func(en Entry) error { return nil }))
+	require.Nil(t, lg.Close())
+}
@@ -81,6 +81,18 @@ func FromName(name, path, fingerprint string) (Backend, error) {
 	return nil, ErrNoSuchBackend
 }
 
+// Share returns a backend that uses the same connection as `bk` (which
+// needs to be returned by FromName), but acts as the repository with
+// `fingerprint` on the network. Backends that can not be shared
+// are created anew from `name` and `path`.
+func Share(bk Backend, name, path, fingerprint string) (Backend, error) {
+	if nd, ok := bk.(*httpipfs.Node); ok {
+		return nd.WithFingerprint(fingerprint), nil
+	}
+
+	return FromName(name, path, fingerprint)
+}
+
 // IsValidName tells you if `name` is a valid backend name.
 func IsValidName(name string) bool {
 	switch name {
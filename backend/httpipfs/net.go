@@ -74,7 +74,9 @@ func (nd *Node) Dial(peerHash, fingerprint, protocol string) (net.Conn, error) {
 		return net.Dial("tcp", addr)
 	}
 
-	protocol = path.Join(protocol, peerHash)
+	// An empty fingerprint will dial the repository that owns
+	// the IPFS node, which also listens on the old protocol name:
+	protocol = path.Join(protocol, peerHash, fingerprint)
 
 	port := util.FindFreePort()
 	addr := fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)
@@ -176,12 +178,13 @@ func (sa *addrWrapper) String() string {
 }
 
 type listenerWrapper struct {
-	lst         net.Listener
-	protocol    string
-	peer        string
-	targetAddr  string
-	fingerprint string
-	sh          *shell.Shell
+	lst          net.Listener
+	protocol     string
+	protocols    []string
+	peer         string
+	targetAddr   string
+	fingerprints []string
+	sh           *shell.Shell
 }
 
 func (lw *listenerWrapper) Accept() (net.Conn, error) {
@@ -208,8 +211,19 @@ func (lw *listenerWrapper) Addr() net.Addr {
 
 func (lw *listenerWrapper) Close() error {
 	defer lw.lst.Close()
-	defer deleteLocalAddr(lw.peer, lw.fingerprint)
-	return closeStream(lw.sh, lw.protocol, lw.targetAddr, "")
+
+	var firstErr error
+	for _, protocol := range lw.protocols {
+		if err := closeStream(lw.sh, protocol, lw.targetAddr, ""); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for _, fingerprint := range lw.fingerprints {
+		deleteLocalAddr(lw.peer, fingerprint)
+	}
+
+	return firstErr
 }
 
 func buildLocalAddrPath(id, fingerprint string) string {
@@ -247,22 +261,30 @@ func (nd *Node) Listen(protocol string) (net.Listener, error) {
 		return nil, err
 	}
 
-	// TODO: Is this even needed still?
-	// Do we want support for having more than one brig per ipfs.
-	// Append the id to the protocol:
-	protocol = path.Join(protocol, self.Addr)
+	// Append the id to the protocol. The repository that owns the node
+	// keeps this name, so older versions and peeks (that do not know the
+	// fingerprint yet) can still reach it. Repositories that share the node
+	// only listen with their fingerprint appended:
+	protocols := []string{path.Join(protocol, self.Addr, nd.fingerprint)}
+	fingerprints := []string{nd.fingerprint}
+	if !nd.shared && nd.fingerprint != "" {
+		protocols = append(protocols, path.Join(protocol, self.Addr))
+		fingerprints = append(fingerprints, "")
+	}
 
 	port := util.FindFreePort()
 	addr := fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)
 
-	// Prevent errors by closing any previously opened listeners:
-	if err := closeStream(nd.sh, protocol, "", ""); err != nil {
-		return nil, err
-	}
+	for _, protocol := range protocols {
+		// Prevent errors by closing any previously opened listeners:
+		if err := closeStream(nd.sh, protocol, "", ""); err != nil {
+			return nil, err
+		}
 
-	log.Debugf("backend: listening for %s over port %d", protocol, port)
-	if err := openListener(nd.sh, protocol, addr); err != nil {
-		return nil, err
+		log.Debugf("backend: listening for %s over port %d", protocol, port)
+		if err := openListener(nd.sh, protocol, addr); err != nil {
+			return nil, err
+		}
 	}
 
 	localAddr := fmt.Sprintf("127.0.0.1:%d", port)
@@ -271,17 +293,20 @@ func (nd *Node) Listen(protocol string) (net.Listener, error) {
 		return nil, err
 	}
 
-	if err := writeLocalAddr(self.Addr, nd.fingerprint, localAddr); err != nil {
-		return nil, err
+	for _, fingerprint := range fingerprints {
+		if err := writeLocalAddr(self.Addr, fingerprint, localAddr); err != nil {
+			return nil, err
+		}
 	}
 
 	return &listenerWrapper{
-		lst:         lst,
-		protocol:    protocol,
-		peer:        self.Addr,
-		targetAddr:  addr,
-		fingerprint: nd.fingerprint,
-		sh:          nd.sh,
+		lst:          lst,
+		protocol:     protocols[0],
+		protocols:    protocols,
+		peer:         self.Addr,
+		targetAddr:   addr,
+		fingerprints: fingerprints,
+		sh:           nd.sh,
 	}, nil
 }
 
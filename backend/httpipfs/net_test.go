@@ -3,6 +3,7 @@ package httpipfs
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"testing"
 	"time"
 
@@ -141,3 +142,36 @@ func TestPingSelf(t *testing.T) {
 		require.True(t, time.Since(pinger.LastSeen()) < 2*time.Second)
 	})
 }
+
+func TestListenWithTwoFingerprints(t *testing.T) {
+	WithIpfs(t, 1, func(t *testing.T, ipfsPath string) {
+		nd, err := NewNode(ipfsPath, "ali")
+		require.Nil(t, err)
+
+		// Both repositories share the connection to the same IPFS node:
+		nds := map[string]*Node{"ali": nd, "bob": nd.WithFingerprint("bob")}
+		for fingerprint, nd := range nds {
+			lst, err := nd.Listen(TestProtocol)
+			require.Nil(t, err)
+			defer lst.Close()
+
+			id, err := nd.Identity()
+			require.Nil(t, err)
+
+			go func(nd *Node, addr, fingerprint string) {
+				conn, err := nd.Dial(addr, fingerprint, TestProtocol)
+				require.Nil(t, err)
+				_, err = conn.Write([]byte(fingerprint))
+				require.Nil(t, err)
+				require.Nil(t, conn.Close())
+			}(nd, id.Addr, fingerprint)
+
+			conn, err := lst.Accept()
+			require.Nil(t, err)
+
+			data, err := ioutil.ReadAll(conn)
+			require.Nil(t, err)
+			require.Equal(t, fingerprint, string(data))
+		}
+	})
+}
@@ -27,6 +27,10 @@ type Node struct {
 	allowNetOps    bool
 	fingerprint    string
 	version        *semver.Version
+
+	// shared is true for nodes created by WithFingerprint.
+	// Only those add the fingerprint to the protocol name.
+	shared bool
 }
 
 func getExperimentalFeatures(sh *shell.Shell) (map[string]bool, error) {
@@ -98,6 +102,23 @@ func NewNode(ipfsPath, fingerprint string) (*Node, error) {
 	}, nil
 }
 
+// WithFingerprint returns a node that uses the same connection to IPFS
+// as `nd`, but acts as the brig repository with `fingerprint` on the
+// network. This is used to serve several repositories from one daemon.
+func (nd *Node) WithFingerprint(fingerprint string) *Node {
+	nd.mu.Lock()
+	defer nd.mu.Unlock()
+
+	return &Node{
+		sh:             nd.sh,
+		cachedIdentity: nd.cachedIdentity,
+		allowNetOps:    true,
+		fingerprint:    fingerprint,
+		version:        nd.version,
+		shared:         true,
+	}
+}
+
 // IsOnline returns true if the node is in online mode and the daemon is reachable.
 func (nd *Node) IsOnline() bool {
 	nd.mu.Lock()
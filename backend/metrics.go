@@ -0,0 +1,52 @@
+package backend
+
+import (
+	"io"
+	"time"
+
+	"github.com/sahib/brig/catfs/mio"
+	"github.com/sahib/brig/metrics"
+	h "github.com/sahib/brig/util/hashlib"
+)
+
+// metricsBackendWrapper records latencies and pin operations of a backend.
+type metricsBackendWrapper struct {
+	Backend
+}
+
+// WithMetrics returns a backend that reports how long `bk` takes
+// to add and cat content and how often it pins and unpins.
+func WithMetrics(bk Backend) Backend {
+	return &metricsBackendWrapper{Backend: bk}
+}
+
+func observe(op string, start time.Time, err error) {
+	metrics.BackendDuration.With(op).ObserveSince(start)
+	if err != nil {
+		metrics.BackendErrors.With(op).Inc()
+	}
+}
+
+func (mw *metricsBackendWrapper) Add(r io.Reader) (h.Hash, error) {
+	start := time.Now()
+	hash, err := mw.Backend.Add(r)
+	observe("add", start, err)
+	return hash, err
+}
+
+func (mw *metricsBackendWrapper) Cat(hash h.Hash) (mio.Stream, error) {
+	start := time.Now()
+	stream, err := mw.Backend.Cat(hash)
+	observe("cat", start, err)
+	return stream, err
+}
+
+func (mw *metricsBackendWrapper) Pin(hash h.Hash) error {
+	metrics.Pins.With("pin").Inc()
+	return mw.Backend.Pin(hash)
+}
+
+func (mw *metricsBackendWrapper) Unpin(hash h.Hash) error {
+	metrics.Pins.With("unpin").Inc()
+	return mw.Backend.Unpin(hash)
+}
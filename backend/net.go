@@ -0,0 +1,78 @@
+package backend
+
+import (
+	"context"
+	"net"
+
+	netBackend "github.com/sahib/brig/net/backend"
+	"github.com/sahib/brig/net/lan"
+	"github.com/sahib/brig/net/peer"
+	"github.com/sahib/brig/net/rendezvous"
+)
+
+// netBackendWrapper replaces the net part of a backend with another
+// net backend, which usually wraps the net part of the original backend.
+type netBackendWrapper struct {
+	Backend
+	net netBackend.Backend
+}
+
+// WithLAN returns a backend that discovers and talks to peers on the local
+// network. Everything else (and, unless `opts.Exclusive` is set, reaching
+// peers that are not on the local network) is done by `bk`.
+func WithLAN(bk Backend, opts lan.Options) (Backend, error) {
+	lanBk, err := lan.NewBackend(bk, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &netBackendWrapper{Backend: bk, net: lanBk}, nil
+}
+
+// WithRendezvous returns a backend that publishes and resolves names with a
+// rendezvous server and relays connections through it if `bk` can not
+// reach a peer. Entries on the server are signed by `signer`.
+func WithRendezvous(bk Backend, signer rendezvous.Signer, opts rendezvous.Options) (Backend, error) {
+	rdvBk, err := rendezvous.NewBackend(bk, signer, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &netBackendWrapper{Backend: bk, net: rdvBk}, nil
+}
+
+func (nw *netBackendWrapper) ResolveName(ctx context.Context, name string) ([]peer.Info, error) {
+	return nw.net.ResolveName(ctx, name)
+}
+
+func (nw *netBackendWrapper) PublishName(name string) error {
+	return nw.net.PublishName(name)
+}
+
+func (nw *netBackendWrapper) Identity() (peer.Info, error) {
+	return nw.net.Identity()
+}
+
+func (nw *netBackendWrapper) Dial(peerAddr, fingerprint, protocol string) (net.Conn, error) {
+	return nw.net.Dial(peerAddr, fingerprint, protocol)
+}
+
+func (nw *netBackendWrapper) Listen(protocol string) (net.Listener, error) {
+	return nw.net.Listen(protocol)
+}
+
+func (nw *netBackendWrapper) Ping(peerAddr string) (netBackend.Pinger, error) {
+	return nw.net.Ping(peerAddr)
+}
+
+func (nw *netBackendWrapper) Connect() error {
+	return nw.net.Connect()
+}
+
+func (nw *netBackendWrapper) Disconnect() error {
+	return nw.net.Disconnect()
+}
+
+func (nw *netBackendWrapper) IsOnline() bool {
+	return nw.net.IsOnline()
+}
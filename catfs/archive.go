@@ -0,0 +1,172 @@
+package catfs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	e "github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// cleanArchivePath joins `name` to `root` and makes sure
+// that entries can not point outside of `root`.
+func cleanArchivePath(root, name string) (string, error) {
+	cleanName := path.Clean("/" + strings.Replace(name, "\\", "/", -1))
+	if cleanName == "/" {
+		return "", fmt.Errorf("archive entry has no name")
+	}
+
+	return path.Join(prefixSlash(root), cleanName), nil
+}
+
+// setModTime sets the mtime of the node at `path` without changing anything else.
+func (fs *FS) setModTime(path string, modTime time.Time) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	nd, err := fs.lkr.LookupModNode(path)
+	if err != nil {
+		return err
+	}
+
+	nd.SetModTime(modTime)
+	return fs.lkr.StageNode(nd)
+}
+
+// importFile stages the content of `r` at `path`. Since Stage() needs to
+// read the stream twice, the content is spooled to a temporary file first.
+func (fs *FS) importFile(path string, r io.Reader, modTime time.Time) error {
+	fd, err := ioutil.TempFile("", "brig-import-")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(fd.Name())
+	defer fd.Close()
+
+	if _, err := io.Copy(fd, r); err != nil {
+		return err
+	}
+
+	if _, err := fd.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if err := fs.Stage(path, fd); err != nil {
+		return err
+	}
+
+	return fs.setModTime(path, modTime)
+}
+
+func (fs *FS) importDir(path string, modTime time.Time) error {
+	// Re-importing an archive should work:
+	if info, err := fs.Stat(path); err != nil || !info.IsDir {
+		if err := fs.Mkdir(path, true); err != nil {
+			return err
+		}
+	}
+
+	return fs.setModTime(path, modTime)
+}
+
+// ImportTar stages all files and directories of the tar archive in `r`
+// below `root`. The modification times of the entries are kept. Other
+// kinds of entries (like symbolic links) are skipped. brig does not store
+// file permissions, so those are ignored too. The number of imported
+// files is returned. No commit is made.
+func (fs *FS) ImportTar(root string, r io.Reader) (int, error) {
+	if fs.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	count := 0
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return count, err
+		}
+
+		path, err := cleanArchivePath(root, hdr.Name)
+		if err != nil {
+			return count, err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = fs.importDir(path, hdr.ModTime)
+		case tar.TypeReg, tar.TypeRegA:
+			err = fs.importFile(path, tr, hdr.ModTime)
+			count++
+		default:
+			log.Warningf("import: skipping `%s`: not a file or directory", hdr.Name)
+			continue
+		}
+
+		if err != nil {
+			return count, e.Wrapf(err, "failed to import %s", hdr.Name)
+		}
+	}
+
+	return count, nil
+}
+
+// ImportZip works like ImportTar, but reads a zip archive of `size` bytes.
+func (fs *FS) ImportZip(root string, r io.ReaderAt, size int64) (int, error) {
+	if fs.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, file := range zr.File {
+		path, err := cleanArchivePath(root, file.Name)
+		if err != nil {
+			return count, err
+		}
+
+		mode := file.Mode()
+		switch {
+		case mode.IsDir():
+			err = fs.importDir(path, file.Modified)
+		case mode.IsRegular():
+			err = fs.importZipFile(path, file)
+			count++
+		default:
+			log.Warningf("import: skipping `%s`: not a file or directory", file.Name)
+			continue
+		}
+
+		if err != nil {
+			return count, e.Wrapf(err, "failed to import %s", file.Name)
+		}
+	}
+
+	return count, nil
+}
+
+func (fs *FS) importZipFile(path string, file *zip.File) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+
+	defer rc.Close()
+	return fs.importFile(path, rc, file.Modified)
+}
@@ -0,0 +1,121 @@
+package catfs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func requireImported(t *testing.T, fs *FS, path string, data []byte, modTime time.Time) {
+	info, err := fs.Stat(path)
+	require.Nil(t, err)
+	require.True(t, info.ModTime.Equal(modTime), "mtime of %s: %v", path, info.ModTime)
+
+	stream, err := fs.Cat(path)
+	require.Nil(t, err)
+
+	content, err := ioutil.ReadAll(stream)
+	require.Nil(t, err)
+	require.Equal(t, data, content)
+}
+
+func TestZip(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/a/file.png", bytes.NewReader([]byte("hello"))))
+		require.Nil(t, fs.Stage("/b/file.jpg", bytes.NewReader([]byte("world"))))
+
+		buf := &bytes.Buffer{}
+		require.Nil(t, fs.Zip("/", buf, nil))
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.Nil(t, err)
+		require.Len(t, zr.File, 2)
+		require.Equal(t, "a/file.png", zr.File[0].Name)
+		require.Equal(t, "b/file.jpg", zr.File[1].Name)
+
+		rc, err := zr.File[1].Open()
+		require.Nil(t, err)
+
+		data, err := ioutil.ReadAll(rc)
+		require.Nil(t, err)
+		require.Equal(t, []byte("world"), data)
+		require.Nil(t, rc.Close())
+	})
+}
+
+func TestImportTar(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		modTime := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		buf := &bytes.Buffer{}
+		tw := tar.NewWriter(buf)
+		require.Nil(t, tw.WriteHeader(&tar.Header{
+			Name:     "dir/",
+			Typeflag: tar.TypeDir,
+			Mode:     0755,
+			ModTime:  modTime,
+		}))
+
+		require.Nil(t, tw.WriteHeader(&tar.Header{
+			Name:     "dir/x",
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     3,
+			ModTime:  modTime,
+		}))
+
+		_, err := tw.Write([]byte{1, 2, 3})
+		require.Nil(t, err)
+
+		// Should not escape the root:
+		require.Nil(t, tw.WriteHeader(&tar.Header{
+			Name:     "../../y",
+			Typeflag: tar.TypeReg,
+			Size:     1,
+			ModTime:  modTime,
+		}))
+
+		_, err = tw.Write([]byte{4})
+		require.Nil(t, err)
+
+		require.Nil(t, tw.WriteHeader(&tar.Header{
+			Name:     "link",
+			Typeflag: tar.TypeSymlink,
+			Linkname: "dir/x",
+		}))
+		require.Nil(t, tw.Close())
+
+		count, err := fs.ImportTar("/imported", buf)
+		require.Nil(t, err)
+		require.Equal(t, 2, count)
+
+		requireImported(t, fs, "/imported/dir/x", []byte{1, 2, 3}, modTime)
+		requireImported(t, fs, "/imported/y", []byte{4}, modTime)
+
+		_, err = fs.Stat("/imported/link")
+		require.NotNil(t, err)
+	})
+}
+
+func TestImportZipRoundtrip(t *testing.T) {
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/a/file.png", bytes.NewReader([]byte("hello"))))
+		info, err := fs.Stat("/a/file.png")
+		require.Nil(t, err)
+
+		buf := &bytes.Buffer{}
+		require.Nil(t, fs.Zip("/", buf, nil))
+
+		count, err := fs.ImportZip("/copy", bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.Nil(t, err)
+		require.Equal(t, 1, count)
+
+		// zip only stores mtimes with a precision of a second:
+		requireImported(t, fs, "/copy/a/file.png", []byte("hello"), info.ModTime.Truncate(time.Second))
+	})
+}
@@ -356,11 +356,16 @@ func Move(lkr *Linker, nd n.ModNode, dstPath string) error {
 
 // StageFromFileNode is a convinience helper that will call Stage() with all necessary params from `f`.
 func StageFromFileNode(lkr *Linker, f *n.File) (*n.File, error) {
-	return Stage(lkr, f.Path(), f.ContentHash(), f.BackendHash(), f.Size(), f.Key())
+	return StageWithKeyScheme(lkr, f.Path(), f.ContentHash(), f.BackendHash(), f.Size(), f.Key(), f.KeyScheme())
 }
 
-// Stage adds a file to brigs DAG.
+// Stage adds a file to brigs DAG. The key is assumed to be derived from the content.
 func Stage(lkr *Linker, repoPath string, contentHash, backendHash h.Hash, size uint64, key []byte) (file *n.File, err error) {
+	return StageWithKeyScheme(lkr, repoPath, contentHash, backendHash, size, key, n.KeySchemeConvergent)
+}
+
+// StageWithKeyScheme is like Stage, but also records how `key` was chosen.
+func StageWithKeyScheme(lkr *Linker, repoPath string, contentHash, backendHash h.Hash, size uint64, key []byte, scheme n.KeyScheme) (file *n.File, err error) {
 	node, lerr := lkr.LookupNode(repoPath)
 	if lerr != nil && !ie.IsNoSuchFileError(lerr) {
 		err = lerr
@@ -439,6 +444,7 @@ func Stage(lkr *Linker, repoPath string, contentHash, backendHash h.Hash, size u
 		file.SetContent(lkr, contentHash)
 		file.SetBackend(lkr, backendHash)
 		file.SetKey(key)
+		file.SetKeyScheme(scheme)
 		file.SetUser(lkr.owner)
 
 		// Add it again when the hash was changed.
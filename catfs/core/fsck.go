@@ -0,0 +1,506 @@
+package core
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+
+	"github.com/sahib/brig/catfs/db"
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+	h "github.com/sahib/brig/util/hashlib"
+)
+
+const (
+	// FsckDangling means that an object is referenced, but does not exist.
+	FsckDangling = "dangling"
+	// FsckCorrupt means that an object could not be loaded
+	// or that its attributes do not match its hash.
+	FsckCorrupt = "corrupt"
+	// FsckBadIndex means that an entry of a derivable index
+	// (commit index, inode index or path index) is missing or wrong.
+	FsckBadIndex = "bad-index"
+	// FsckBadMoveMapping means that a move mapping refers to unknown nodes.
+	FsckBadMoveMapping = "bad-move-mapping"
+)
+
+// FsckProblem describes a single inconsistency found by Fsck().
+type FsckProblem struct {
+	// Kind is one of the Fsck* constants
+	Kind string
+	// Path of the affected node, if known
+	Path string
+	// Hash of the affected object, if known
+	Hash h.Hash
+	// Detail is a human readable description of the problem
+	Detail string
+	// Repaired is true if the problem was fixed by Fsck()
+	Repaired bool
+
+	// fix repairs the problem, if that's possible.
+	fix func(batch db.Batch)
+}
+
+type fsckChecker struct {
+	lkr      *Linker
+	problems []*FsckProblem
+	onFile   func(file *n.File)
+
+	// tree hashes of already checked nodes and commits
+	visited map[string]bool
+
+	// tree hashes of nodes that were checked against the path index
+	pathChecked map[string]bool
+
+	// first node (i.e. the newest) seen for each inode
+	inodes map[uint64]n.Node
+
+	// index keys of all commits in the history
+	cmtIndexes map[string]bool
+}
+
+func (fc *fsckChecker) report(kind, nodePath string, hash h.Hash, fix func(batch db.Batch), format string, args ...interface{}) {
+	fc.problems = append(fc.problems, &FsckProblem{
+		Kind:   kind,
+		Path:   nodePath,
+		Hash:   hash,
+		Detail: fmt.Sprintf(format, args...),
+		fix:    fix,
+	})
+}
+
+// checkNode loads the object at `hash` and verifies that it is
+// consistent with its hash. It returns nil if it could not be loaded.
+func (fc *fsckChecker) checkNode(hash h.Hash, nodePath string) n.Node {
+	nd, err := fc.lkr.loadNode(hash)
+	if err != nil {
+		fc.report(FsckCorrupt, nodePath, hash, nil, "failed to load: %v", err)
+		return nil
+	}
+
+	if nd == nil {
+		fc.report(FsckDangling, nodePath, hash, nil, "object does not exist")
+		return nil
+	}
+
+	if !nd.TreeHash().Equal(hash) {
+		fc.report(
+			FsckCorrupt, nodePath, hash, nil,
+			"object is stored under a different hash than its own (%s)",
+			nd.TreeHash().B58String(),
+		)
+		return nd
+	}
+
+	expected, err := n.ExpectedTreeHash(nd)
+	if err != nil {
+		fc.report(FsckCorrupt, nodePath, hash, nil, "failed to compute hash: %v", err)
+		return nd
+	}
+
+	if expected != nil && !expected.Equal(hash) {
+		fc.report(FsckCorrupt, nodePath, hash, nil, "attributes do not match the hash")
+	}
+
+	return nd
+}
+
+// checkTree recursively checks the node at `hash` and all its children.
+// If `pathIndex` is true, the path index is checked to point to them too.
+func (fc *fsckChecker) checkTree(hash h.Hash, nodePath string, pathIndex bool) {
+	b58Hash := hash.B58String()
+	seen := fc.visited[b58Hash]
+	if seen && (!pathIndex || fc.pathChecked[b58Hash]) {
+		return
+	}
+
+	fc.visited[b58Hash] = true
+	if pathIndex {
+		fc.pathChecked[b58Hash] = true
+	}
+
+	var nd n.Node
+	if seen {
+		// Problems of this node were reported already.
+		nd, _ = fc.lkr.loadNode(hash)
+	} else {
+		nd = fc.checkNode(hash, nodePath)
+	}
+
+	if nd == nil {
+		return
+	}
+
+	if !seen {
+		if nd.Path() != nodePath {
+			fc.report(FsckCorrupt, nodePath, hash, nil, "node thinks it lives at %s", nd.Path())
+		}
+
+		if _, ok := fc.inodes[nd.Inode()]; !ok {
+			fc.inodes[nd.Inode()] = nd
+		}
+
+		if file, ok := nd.(*n.File); ok && fc.onFile != nil {
+			fc.onFile(file)
+		}
+	}
+
+	if pathIndex {
+		treeKey := nodePath
+		if nd.Type() == n.NodeTypeDirectory {
+			treeKey = appendDot(treeKey)
+		}
+
+		data, err := fc.lkr.kv.Get("tree", treeKey)
+		if err != nil || string(data) != b58Hash {
+			fc.report(FsckBadIndex, nodePath, hash, func(batch db.Batch) {
+				batch.Put([]byte(b58Hash), "tree", treeKey)
+			}, "path index does not point to the last committed node")
+		}
+	}
+
+	if dir, ok := nd.(*n.Directory); ok {
+		children := dir.ChildHashes()
+		names := []string{}
+		for name := range children {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		for _, name := range names {
+			fc.checkTree(children[name], path.Join(nodePath, name), pathIndex)
+		}
+	}
+}
+
+// checkHistory checks `cmt` and all of its parents.
+func (fc *fsckChecker) checkHistory(cmt *n.Commit, base *n.Commit, pathIndex bool) {
+	for cmt != nil {
+		b58Hash := cmt.TreeHash().B58String()
+		if fc.visited[b58Hash] {
+			return
+		}
+
+		fc.visited[b58Hash] = true
+
+		cmtPath := fmt.Sprintf("commit[%d]", cmt.Index())
+		if nd := fc.checkNode(cmt.TreeHash(), cmtPath); nd == nil {
+			return
+		}
+
+		indexKey := strconv.FormatInt(cmt.Index(), 10)
+		fc.cmtIndexes[indexKey] = true
+
+		data, err := fc.lkr.kv.Get("index", indexKey)
+		if err != nil || string(data) != b58Hash {
+			fc.report(FsckBadIndex, cmtPath, cmt.TreeHash(), func(batch db.Batch) {
+				batch.Put([]byte(b58Hash), "index", indexKey)
+			}, "commit index does not point to the commit")
+		}
+
+		fc.checkTree(cmt.Root(), "/", pathIndex)
+
+		// Only HEAD's tree is in the path index:
+		pathIndex = false
+
+		parentHash := cmt.ParentHash()
+		if parentHash == nil {
+			return
+		}
+
+		parent, err := fc.lkr.loadNode(parentHash)
+		if err != nil {
+			fc.report(FsckCorrupt, cmtPath, parentHash, nil, "failed to load parent: %v", err)
+			return
+		}
+
+		if parent == nil {
+			// The parents of the history base were pruned on purpose.
+			if base == nil || !base.TreeHash().Equal(cmt.TreeHash()) {
+				fc.report(FsckDangling, cmtPath, parentHash, nil, "parent commit does not exist")
+			}
+
+			return
+		}
+
+		parentCmt, ok := parent.(*n.Commit)
+		if !ok {
+			fc.report(FsckCorrupt, cmtPath, parentHash, nil, "parent is not a commit")
+			return
+		}
+
+		cmt = parentCmt
+	}
+}
+
+func (fc *fsckChecker) checkRefs(base *n.Commit) error {
+	refs, err := fc.lkr.ListRefs()
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(refs)
+	for _, ref := range refs {
+		if ref == "curr" {
+			// The staging commit is not in the object store.
+			continue
+		}
+
+		data, err := fc.lkr.kv.Get("refs", ref)
+		if err != nil {
+			return err
+		}
+
+		hash, err := h.FromB58String(string(data))
+		if err != nil {
+			fc.report(FsckCorrupt, ref, nil, nil, "ref is not a valid hash: %v", err)
+			continue
+		}
+
+		nd, err := fc.lkr.loadNode(hash)
+		if err != nil {
+			return err
+		}
+
+		if nd == nil {
+			fc.report(FsckDangling, ref, hash, nil, "ref points to nothing")
+			continue
+		}
+
+		if cmt, ok := nd.(*n.Commit); ok {
+			fc.checkHistory(cmt, base, ref == "head")
+		}
+	}
+
+	return nil
+}
+
+func (fc *fsckChecker) checkIndexes() error {
+	keys, err := fc.lkr.kv.Keys("index")
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		key := key
+		if fc.cmtIndexes[key[len(key)-1]] {
+			// Checked already by checkHistory.
+			continue
+		}
+
+		data, err := fc.lkr.kv.Get(key...)
+		if err != nil {
+			return err
+		}
+
+		cmtPath := fmt.Sprintf("commit[%s]", key[len(key)-1])
+		hash, err := h.FromB58String(string(data))
+		if err != nil {
+			fc.report(FsckBadIndex, cmtPath, nil, func(batch db.Batch) {
+				batch.Erase(key...)
+			}, "commit index entry is not a valid hash")
+			continue
+		}
+
+		nd, err := fc.lkr.loadNode(hash)
+		if err != nil {
+			return err
+		}
+
+		if cmt, ok := nd.(*n.Commit); !ok || strconv.FormatInt(cmt.Index(), 10) != key[len(key)-1] {
+			fc.report(FsckBadIndex, cmtPath, hash, func(batch db.Batch) {
+				batch.Erase(key...)
+			}, "commit index entry points to no or a wrong commit")
+		}
+	}
+
+	// All nodes that are currently reachable should be resolvable by inode:
+	inodes := []uint64{}
+	for inode := range fc.inodes {
+		inodes = append(inodes, inode)
+	}
+
+	sort.Slice(inodes, func(i, j int) bool { return inodes[i] < inodes[j] })
+
+	for _, inode := range inodes {
+		nd := fc.inodes[inode]
+		inodeKey := strconv.FormatUint(inode, 10)
+		b58Hash := nd.TreeHash().B58String()
+
+		data, err := fc.lkr.kv.Get("inode", inodeKey)
+		if err != nil && err != db.ErrNoSuchKey {
+			return err
+		}
+
+		var indexed n.Node
+		if hash, err := h.FromB58String(string(data)); err == nil {
+			indexed, err = fc.lkr.loadNode(hash)
+			if err != nil {
+				return err
+			}
+		}
+
+		if indexed == nil || indexed.Inode() != inode {
+			fc.report(FsckBadIndex, nd.Path(), nd.TreeHash(), func(batch db.Batch) {
+				batch.Put([]byte(b58Hash), "inode", inodeKey)
+			}, "inode %d is not resolvable", inode)
+		}
+	}
+
+	return nil
+}
+
+func (fc *fsckChecker) checkMoveMappings() error {
+	locations := [][]string{{"moves"}, {"stage", "moves"}}
+	for _, location := range locations {
+		keys, err := fc.lkr.kv.Keys(location...)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			key := key
+			fix := func(batch db.Batch) {
+				batch.Erase(key...)
+			}
+
+			data, err := fc.lkr.kv.Get(key...)
+			if err != nil {
+				return err
+			}
+
+			dstNd, _, err := fc.lkr.parseMoveMappingLine(string(data))
+			if err != nil {
+				fc.report(FsckBadMoveMapping, "", nil, fix, "%v", err)
+				continue
+			}
+
+			if dstNd == nil {
+				fc.report(FsckBadMoveMapping, "", nil, fix, "move target of %v does not exist", key)
+				continue
+			}
+
+			if location[0] != "moves" || len(key) < 3 {
+				// Staged moves are keyed by inode.
+				continue
+			}
+
+			srcHash, err := h.FromB58String(key[len(key)-1])
+			if err != nil {
+				fc.report(FsckBadMoveMapping, dstNd.Path(), nil, fix, "invalid move source: %v", err)
+				continue
+			}
+
+			srcNd, err := fc.lkr.loadNode(srcHash)
+			if err != nil {
+				return err
+			}
+
+			if srcNd == nil {
+				fc.report(FsckBadMoveMapping, dstNd.Path(), srcHash, fix, "move source does not exist")
+				continue
+			}
+
+			if key[1] == "overlay" {
+				continue
+			}
+
+			cmtHash, err := h.FromB58String(key[1])
+			if err != nil {
+				fc.report(FsckBadMoveMapping, srcNd.Path(), srcHash, fix, "invalid commit: %v", err)
+				continue
+			}
+
+			if cmt, err := fc.lkr.loadNode(cmtHash); err != nil || cmt == nil {
+				fc.report(FsckBadMoveMapping, srcNd.Path(), cmtHash, fix, "commit of move does not exist")
+			}
+		}
+	}
+
+	return nil
+}
+
+// Fsck checks the metadata for consistency. It recomputes the hashes of all
+// nodes and commits, and checks that every referenced object exists. It also
+// checks that the commit, inode and path indexes are correct and that all
+// move mappings point to existing nodes.
+//
+// `onFile` is called once for every distinct file in the staging area or
+// in any commit. It may be nil.
+//
+// If `repair` is true, problems that can be fixed are fixed, i.e. the
+// derivable indexes are rebuilt and broken move mappings are removed.
+// The usage records are always rebuilt on repair. Corrupt or missing
+// nodes can not be repaired.
+func (lkr *Linker) Fsck(repair bool, onFile func(file *n.File)) ([]*FsckProblem, error) {
+	fc := &fsckChecker{
+		lkr:         lkr,
+		onFile:      onFile,
+		visited:     make(map[string]bool),
+		pathChecked: make(map[string]bool),
+		inodes:      make(map[uint64]n.Node),
+		cmtIndexes:  make(map[string]bool),
+	}
+
+	status, err := lkr.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := lkr.HistoryBase()
+	if err != nil {
+		return nil, err
+	}
+
+	// Staging goes first, so the inode check uses the newest nodes:
+	fc.checkTree(status.Root(), "/", false)
+
+	// HEAD goes next, since it's the only one in the path index.
+	head, err := lkr.Head()
+	if err != nil && !ie.IsErrNoSuchRef(err) {
+		return nil, err
+	}
+
+	if head != nil {
+		fc.checkHistory(head, base, true)
+	}
+
+	if err := fc.checkRefs(base); err != nil {
+		return nil, err
+	}
+
+	if err := fc.checkIndexes(); err != nil {
+		return nil, err
+	}
+
+	if err := fc.checkMoveMappings(); err != nil {
+		return nil, err
+	}
+
+	if !repair {
+		return fc.problems, nil
+	}
+
+	err = lkr.AtomicWithBatch(func(batch db.Batch) (bool, error) {
+		for _, problem := range fc.problems {
+			if problem.fix != nil {
+				problem.fix(batch)
+			}
+		}
+
+		// Make sure the usage records are recalculated on next access.
+		batch.Erase("usage", "root")
+		return false, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, problem := range fc.problems {
+		problem.Repaired = problem.fix != nil
+	}
+
+	return fc.problems, nil
+}
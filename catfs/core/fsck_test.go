@@ -0,0 +1,90 @@
+package core
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/sahib/brig/catfs/db"
+	n "github.com/sahib/brig/catfs/nodes"
+	h "github.com/sahib/brig/util/hashlib"
+	"github.com/stretchr/testify/require"
+)
+
+func requireFsckKinds(t *testing.T, lkr *Linker, repair bool, kinds ...string) []*FsckProblem {
+	problems, err := lkr.Fsck(repair, nil)
+	require.Nil(t, err)
+
+	var foundKinds []string
+	for _, problem := range problems {
+		foundKinds = append(foundKinds, problem.Kind)
+	}
+
+	require.Equal(t, kinds, foundKinds)
+	return problems
+}
+
+func TestFsck(t *testing.T) {
+	WithDummyLinker(t, func(lkr *Linker) {
+		// A fresh linker is fine:
+		requireFsckKinds(t, lkr, false)
+
+		_, err := Stage(lkr, "/a/x", h.TestDummy(t, 10), h.TestDummy(t, 10), 10, nil)
+		require.Nil(t, err)
+		MustCommit(t, lkr, "first")
+
+		MustMkdir(t, lkr, "/b")
+		x, err := lkr.LookupModNode("/a/x")
+		require.Nil(t, err)
+		MustMove(t, lkr, x, "/b/y")
+		MustCommit(t, lkr, "second")
+
+		_, err = Stage(lkr, "/c", h.TestDummy(t, 11), h.TestDummy(t, 11), 11, nil)
+		require.Nil(t, err)
+		MustCommit(t, lkr, "third")
+
+		// Staged, uncommitted changes are fine too:
+		y, err := lkr.LookupModNode("/b/y")
+		require.Nil(t, err)
+		MustRemove(t, lkr, y)
+
+		files := []string{}
+		problems, err := lkr.Fsck(false, func(file *n.File) {
+			files = append(files, file.Path())
+		})
+		require.Nil(t, err)
+		require.Empty(t, problems)
+		require.Equal(t, []string{"/c", "/b/y", "/a/x"}, files)
+
+		c, err := lkr.LookupModNode("/c")
+		require.Nil(t, err)
+
+		// Break things:
+		require.Nil(t, lkr.AtomicWithBatch(func(batch db.Batch) (bool, error) {
+			batch.Erase("index", "1")
+			batch.Erase("inode", strconv.FormatUint(c.Inode(), 10))
+			batch.Put([]byte("garbage"), "moves", "overlay", h.TestDummy(t, 12).B58String())
+			batch.Put([]byte(h.TestDummy(t, 13).B58String()), "refs", "broken")
+			return false, nil
+		}))
+
+		problems = requireFsckKinds(
+			t, lkr, true,
+			FsckBadIndex, FsckDangling, FsckBadIndex, FsckBadMoveMapping,
+		)
+
+		require.Equal(t, "commit[1]", problems[0].Path)
+		require.Equal(t, "broken", problems[1].Path)
+		require.Equal(t, "/c", problems[2].Path)
+		require.True(t, problems[0].Repaired)
+		require.False(t, problems[1].Repaired)
+		require.True(t, problems[2].Repaired)
+		require.True(t, problems[3].Repaired)
+
+		// Only the problems that can't be repaired should stay:
+		requireFsckKinds(t, lkr, false, FsckDangling)
+
+		cmt, err := lkr.CommitByIndex(1)
+		require.Nil(t, err)
+		require.Equal(t, "first", cmt.Message())
+	})
+}
@@ -16,6 +16,12 @@ package core
 // stage/moves/overlay/<INODE>           => MOVE_INFO
 //
 // stats/max-inode                       => UINT64
+// stats/history-base                    => COMMIT_HASH
+// usage/root                            => ROOT_HASH (of last accounted tree)
+// usage/dirs/<DIR_PATH>/.               => USAGE
+// usage/users/<USER>/.                  => USAGE
+// usage/user-list                       => USER_NAMES
+// usage/refs/{dirs,users}/<HASH>/<ID>/. => UINT64 (content refcount)
 // refs/<REFNAME>                        => NODE_HASH
 //
 // Defined by caller:
@@ -79,6 +85,9 @@ type Linker struct {
 
 	// Cache for the linker owner.
 	owner string
+
+	// signer signs the hash of new commits, if set.
+	signer func(data []byte) ([]byte, error)
 }
 
 // NewLinker returns a new lkr, ready to use. It assumes the key value store
@@ -384,6 +393,21 @@ func (lkr *Linker) CommitByIndex(index int64) (*n.Commit, error) {
 			return status, nil
 		}
 
+		// Tell the caller if the commit existed once:
+		base, err := lkr.HistoryBase()
+		if err != nil {
+			return nil, err
+		}
+
+		if base != nil && index < base.Index() {
+			return nil, e.Wrapf(
+				ie.ErrHistoryPruned,
+				"commit[%d] is older than the oldest commit[%d]",
+				index,
+				base.Index(),
+			)
+		}
+
 		return nil, nil
 	}
 
@@ -399,7 +423,11 @@ func (lkr *Linker) CommitByIndex(index int64) (*n.Commit, error) {
 // It will return nil if no corresponding node was found.
 func (lkr *Linker) NodeByInode(uid uint64) (n.Node, error) {
 	b58Hash, err := lkr.kv.Get("inode", strconv.FormatUint(uid, 10))
-	if err != nil && err != db.ErrNoSuchKey {
+	if err == db.ErrNoSuchKey {
+		return nil, nil
+	}
+
+	if err != nil {
 		return nil, err
 	}
 
@@ -556,6 +584,19 @@ func (lkr *Linker) makeCommit(batch db.Batch, author string, message string) err
 		return err
 	}
 
+	// Account for the storage changes since the last commit:
+	var oldRootDir *n.Directory
+	if head != nil {
+		oldRootDir, err = lkr.DirectoryByHash(head.Root())
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := lkr.updateUsage(batch, oldRootDir, rootDir); err != nil {
+		return err
+	}
+
 	// NOTE: `head` may be nil, if it couldn't be resolved,
 	//        or (maybe more likely) if this is the first commit.
 	if head != nil {
@@ -568,6 +609,15 @@ func (lkr *Linker) makeCommit(batch db.Batch, author string, message string) err
 		return err
 	}
 
+	if lkr.signer != nil {
+		sig, err := lkr.signer(status.TreeHash().Bytes())
+		if err != nil {
+			return e.Wrapf(err, "failed to sign commit")
+		}
+
+		status.SetSignature(sig)
+	}
+
 	statusData, err := n.MarshalNode(status)
 	if err != nil {
 		return err
@@ -682,6 +732,12 @@ func (lkr *Linker) SetOwner(owner string) error {
 	return lkr.MetadataPut("owner", []byte(owner))
 }
 
+// SetSigner sets a function that is used to sign the hash of every
+// commit made from now on. A nil `signer` disables signing.
+func (lkr *Linker) SetSigner(signer func(data []byte) ([]byte, error)) {
+	lkr.signer = signer
+}
+
 // SetABIVersion will set the ABI version to `version`.
 func (lkr *Linker) SetABIVersion(version int) error {
 	sv := strconv.Itoa(version)
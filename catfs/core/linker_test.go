@@ -176,6 +176,10 @@ func TestLinkerNested(t *testing.T) {
 			t.Fatalf("Resolving /sub by ID (%d) failed: %v", sameSubDir.Inode(), err)
 		}
 
+		if nd, err := lkr.NodeByInode(12345); nd != nil || err != nil {
+			t.Fatalf("Resolving an unknown ID did not yield nil: %v (%v)", nd, err)
+		}
+
 		subpub, err := n.NewEmptyDirectory(lkr, sameSubDir, "pub", "u", 4)
 		if err != nil {
 			t.Fatalf("Creating of deep sub failed")
@@ -0,0 +1,264 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/sahib/brig/catfs/db"
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+	h "github.com/sahib/brig/util/hashlib"
+)
+
+// HistoryBase returns the oldest commit that is still part of the history.
+// If the history was never pruned, nil is returned.
+func (lkr *Linker) HistoryBase() (*n.Commit, error) {
+	b58Hash, err := lkr.kv.Get("stats", "history-base")
+	if err != nil && err != db.ErrNoSuchKey {
+		return nil, err
+	}
+
+	if err == db.ErrNoSuchKey {
+		return nil, nil
+	}
+
+	hash, err := h.FromB58String(string(b58Hash))
+	if err != nil {
+		return nil, err
+	}
+
+	return lkr.CommitByHash(hash)
+}
+
+func (lkr *Linker) parentCommit(cmt *n.Commit) (*n.Commit, error) {
+	parent, err := cmt.Parent(lkr)
+	if err != nil {
+		return nil, err
+	}
+
+	if parent == nil {
+		return nil, nil
+	}
+
+	parentCmt, ok := parent.(*n.Commit)
+	if !ok {
+		return nil, ie.ErrBadNode
+	}
+
+	return parentCmt, nil
+}
+
+// PruneHistory makes `base` the oldest commit in the history of HEAD.
+// All commits before it are removed together with all nodes and move mappings
+// that are only reachable through them. The tree of `base` stays as it is,
+// so it acts like one commit that squashes all older ones.
+//
+// Commits that are referenced by a tag are kept, even if they are older
+// than `base`. They are still resolvable by their tag, but are not
+// part of the log anymore.
+//
+// `unref` is called for every removed file whose content is not used
+// by any of the remaining nodes anymore. It may be nil.
+// The number of removed commits is returned.
+func (lkr *Linker) PruneHistory(base *n.Commit, unref func(file *n.File)) (int, error) {
+	head, err := lkr.Head()
+	if err != nil {
+		return 0, err
+	}
+
+	status, err := lkr.Status()
+	if err != nil {
+		return 0, err
+	}
+
+	// Collect all commits between HEAD and base (inclusive).
+	keep := []*n.Commit{}
+	for curr := head; ; {
+		keep = append(keep, curr)
+		if curr.TreeHash().Equal(base.TreeHash()) {
+			break
+		}
+
+		curr, err = lkr.parentCommit(curr)
+		if err != nil {
+			return 0, err
+		}
+
+		if curr == nil {
+			return 0, fmt.Errorf("commit %s is not part of the history", base.TreeHash())
+		}
+	}
+
+	// Everything after base can go away:
+	pruned := make(map[string]*n.Commit)
+	for curr := base; ; {
+		curr, err = lkr.parentCommit(curr)
+		if err != nil {
+			return 0, err
+		}
+
+		if curr == nil {
+			break
+		}
+
+		pruned[curr.TreeHash().B58String()] = curr
+	}
+
+	if len(pruned) == 0 {
+		return 0, nil
+	}
+
+	refs, err := lkr.ListRefs()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, ref := range refs {
+		switch ref {
+		case "head", "curr", "init":
+			continue
+		}
+
+		nd, err := lkr.ResolveRef(ref)
+		if ie.IsErrNoSuchRef(err) {
+			continue
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		b58Hash := nd.TreeHash().B58String()
+		if cmt, ok := pruned[b58Hash]; ok {
+			keep = append(keep, cmt)
+			delete(pruned, b58Hash)
+		}
+	}
+
+	// Mark everything that is reachable from the kept commits.
+	// We can re-use the machinery of the garbage collector for that.
+	gc := NewGarbageCollector(lkr, lkr.kv, nil)
+	gc.markMap = make(map[string]struct{})
+	if err := gc.mark(status, false); err != nil {
+		return 0, err
+	}
+
+	if err := gc.markMoveMap([]string{"stage", "moves"}); err != nil {
+		return 0, err
+	}
+
+	for _, cmt := range keep {
+		if err := gc.mark(cmt, false); err != nil {
+			return 0, err
+		}
+
+		location := []string{"moves", cmt.TreeHash().B58String()}
+		if err := gc.markMoveMap(location); err != nil {
+			return 0, err
+		}
+	}
+
+	// Remember which contents are still in use,
+	// so we don't report them as unreferenced.
+	usedContent := make(map[string]bool)
+	for b58Hash := range gc.markMap {
+		hash, err := h.FromB58String(b58Hash)
+		if err != nil {
+			return 0, err
+		}
+
+		nd, err := lkr.NodeByHash(hash)
+		if err != nil {
+			return 0, err
+		}
+
+		if file, ok := nd.(*n.File); ok {
+			usedContent[file.BackendHash().B58String()] = true
+		}
+	}
+
+	err = lkr.AtomicWithBatch(func(batch db.Batch) (bool, error) {
+		keys, err := lkr.kv.Keys("objects")
+		if err != nil {
+			return hintRollback(err)
+		}
+
+		erased := make(map[string]bool)
+		for _, key := range keys {
+			b58Hash := key[len(key)-1]
+			if _, ok := gc.markMap[b58Hash]; ok {
+				continue
+			}
+
+			hash, err := h.FromB58String(b58Hash)
+			if err != nil {
+				return hintRollback(err)
+			}
+
+			nd, err := lkr.NodeByHash(hash)
+			if err != nil {
+				return hintRollback(err)
+			}
+
+			if file, ok := nd.(*n.File); ok && unref != nil {
+				content := file.BackendHash().B58String()
+				if !usedContent[content] {
+					// Only report each content once:
+					usedContent[content] = true
+					unref(file)
+				}
+			}
+
+			batch.Erase(key...)
+			erased[b58Hash] = true
+		}
+
+		// Get rid of all lookup entries that point to erased objects:
+		for _, bucket := range []string{"index", "tree", "inode"} {
+			keys, err := lkr.kv.Keys(bucket)
+			if err != nil {
+				return hintRollback(err)
+			}
+
+			for _, key := range keys {
+				data, err := lkr.kv.Get(key...)
+				if err != nil {
+					return hintRollback(err)
+				}
+
+				if erased[string(data)] {
+					batch.Erase(key...)
+				}
+			}
+		}
+
+		keys, err = lkr.kv.Keys("moves")
+		if err != nil {
+			return hintRollback(err)
+		}
+
+		for _, key := range keys {
+			if len(key) < 3 {
+				continue
+			}
+
+			isOverlay := key[1] == "overlay"
+			_, isPruned := pruned[key[1]]
+			if isPruned || (isOverlay && erased[key[2]]) {
+				batch.Erase(key...)
+			}
+		}
+
+		baseHash := []byte(base.TreeHash().B58String())
+		batch.Put(baseHash, "stats", "history-base")
+		batch.Put(baseHash, "refs", "init")
+		return false, nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	// Make sure no erased node stays in the memory index:
+	lkr.MemIndexClear()
+	return len(pruned), nil
+}
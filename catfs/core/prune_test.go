@@ -0,0 +1,104 @@
+package core
+
+import (
+	"testing"
+
+	e "github.com/pkg/errors"
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneHistory(t *testing.T) {
+	WithDummyLinker(t, func(lkr *Linker) {
+		// Every commit has a new version of /x with different content.
+		_, c1 := MustTouchAndCommit(t, lkr, "/x", 1)
+		_, c2 := MustTouchAndCommit(t, lkr, "/x", 2)
+		_, c3 := MustTouchAndCommit(t, lkr, "/x", 3)
+		_, c4 := MustTouchAndCommit(t, lkr, "/x", 4)
+		_, c5 := MustTouchAndCommit(t, lkr, "/x", 5)
+
+		require.Nil(t, lkr.SaveRef("keepme", c2))
+
+		base, err := lkr.HistoryBase()
+		require.Nil(t, err)
+		require.Nil(t, base)
+
+		unrefed := []string{}
+		nPruned, err := lkr.PruneHistory(c4, func(file *n.File) {
+			unrefed = append(unrefed, file.BackendHash().B58String())
+		})
+		require.Nil(t, err)
+
+		// c1, c3 and the init commit are gone; c2 is tagged.
+		require.Equal(t, 3, nPruned)
+		require.Len(t, unrefed, 2)
+
+		base, err = lkr.HistoryBase()
+		require.Nil(t, err)
+		require.Equal(t, c4.TreeHash(), base.TreeHash())
+
+		// c4 is the first commit of the history now:
+		prunedC4, err := lkr.CommitByHash(c4.TreeHash())
+		require.Nil(t, err)
+		parent, err := prunedC4.Parent(lkr)
+		require.Nil(t, err)
+		require.Nil(t, parent)
+
+		initCmt, err := lkr.ResolveRef("init")
+		require.Nil(t, err)
+		require.Equal(t, c4.TreeHash(), initCmt.TreeHash())
+
+		// Old commits are not reachable anymore:
+		for _, cmt := range []*n.Commit{c1, c3} {
+			prunedCmt, err := lkr.CommitByHash(cmt.TreeHash())
+			require.Nil(t, err)
+			require.Nil(t, prunedCmt)
+
+			_, err = lkr.CommitByIndex(cmt.Index())
+			require.Equal(t, ie.ErrHistoryPruned, e.Cause(err))
+		}
+
+		// ...but tagged ones are still there, including their tree.
+		tagged, err := lkr.ResolveRef("keepme")
+		require.Nil(t, err)
+		require.Equal(t, c2.TreeHash(), tagged.TreeHash())
+
+		taggedX, err := lkr.LookupNodeAt(tagged.(*n.Commit), "/x")
+		require.Nil(t, err)
+		require.NotNil(t, taggedX)
+
+		// The current state is not affected at all.
+		head, err := lkr.Head()
+		require.Nil(t, err)
+		require.Equal(t, c5.TreeHash(), head.TreeHash())
+
+		x, err := lkr.LookupFile("/x")
+		require.Nil(t, err)
+		require.Equal(t, uint64(5), x.Size())
+
+		// Pruning again up to the same base does nothing:
+		nPruned, err = lkr.PruneHistory(c4, nil)
+		require.Nil(t, err)
+		require.Equal(t, 0, nPruned)
+
+		// New commits should still work normally.
+		_, c6 := MustTouchAndCommit(t, lkr, "/x", 6)
+		parent, err = c6.Parent(lkr)
+		require.Nil(t, err)
+		require.Equal(t, c5.TreeHash(), parent.TreeHash())
+	})
+}
+
+func TestPruneHistoryBadBase(t *testing.T) {
+	WithDummyLinker(t, func(lkr *Linker) {
+		MustTouchAndCommit(t, lkr, "/x", 1)
+
+		status, err := lkr.Status()
+		require.Nil(t, err)
+
+		// The staging commit is not part of the history yet.
+		_, err = lkr.PruneHistory(status, nil)
+		require.NotNil(t, err)
+	})
+}
@@ -0,0 +1,465 @@
+package core
+
+import (
+	"encoding/binary"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sahib/brig/catfs/db"
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+	h "github.com/sahib/brig/util/hashlib"
+)
+
+// Usage describes how much storage the files below a directory
+// (or the files last modified by a certain user) take.
+// The numbers always reflect the state of HEAD.
+type Usage struct {
+	// Files is the number of files
+	Files uint64
+	// Logical is the sum of all file sizes
+	Logical uint64
+	// Deduplicated is like Logical, but every content is only counted once.
+	Deduplicated uint64
+}
+
+func (u *Usage) marshal() []byte {
+	data := make([]byte, 24)
+	binary.LittleEndian.PutUint64(data[0:], u.Files)
+	binary.LittleEndian.PutUint64(data[8:], u.Logical)
+	binary.LittleEndian.PutUint64(data[16:], u.Deduplicated)
+	return data
+}
+
+func (u *Usage) unmarshal(data []byte) error {
+	if len(data) < 24 {
+		return ie.ErrBadNode
+	}
+
+	u.Files = binary.LittleEndian.Uint64(data[0:])
+	u.Logical = binary.LittleEndian.Uint64(data[8:])
+	u.Deduplicated = binary.LittleEndian.Uint64(data[16:])
+	return nil
+}
+
+// usageUpdate collects all changes to the usage records
+// that are caused by a single commit.
+type usageUpdate struct {
+	lkr *Linker
+
+	// if true, the records in the database are not read
+	// since they are about to be rebuilt from scratch.
+	rebuild bool
+
+	// account key => usage
+	records map[string]*usageRecord
+
+	// account key + backend hash => number of files with this content
+	refs map[string]*refRecord
+
+	// users whose usage was touched
+	users map[string]*Usage
+}
+
+// The database keys might contain any character (including separators),
+// so we remember the original key parts next to the record.
+type usageRecord struct {
+	key   []string
+	usage *Usage
+}
+
+type refRecord struct {
+	key   []string
+	count uint64
+}
+
+func (uu *usageUpdate) load(key []string) (*Usage, error) {
+	joined := strings.Join(key, "\x00")
+	if record, ok := uu.records[joined]; ok {
+		return record.usage, nil
+	}
+
+	usage := &Usage{}
+	uu.records[joined] = &usageRecord{key: key, usage: usage}
+	if uu.rebuild {
+		return usage, nil
+	}
+
+	data, err := uu.lkr.kv.Get(key...)
+	if err == db.ErrNoSuchKey {
+		return usage, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return usage, usage.unmarshal(data)
+}
+
+func (uu *usageUpdate) refCount(key []string) (uint64, error) {
+	joined := strings.Join(key, "\x00")
+	if record, ok := uu.refs[joined]; ok {
+		return record.count, nil
+	}
+
+	if uu.rebuild {
+		return 0, nil
+	}
+
+	data, err := uu.lkr.kv.Get(key...)
+	if err == db.ErrNoSuchKey {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	if len(data) < 8 {
+		return 0, ie.ErrBadNode
+	}
+
+	return binary.LittleEndian.Uint64(data), nil
+}
+
+// usageKey returns the key of the account `id` of the type `kind`.
+// Since ids may contain slashes, they are handled like directory keys
+// in the tree bucket. Otherwise /a and /a/b would clash in some databases.
+func usageKey(kind, id string) []string {
+	return []string{"usage", kind, appendDot(id)}
+}
+
+func usageRefKey(kind, id string, hash h.Hash) []string {
+	return []string{"usage", "refs", kind, hash.B58String(), appendDot(id)}
+}
+
+func (uu *usageUpdate) account(kind, id string, file *n.File, added bool) (*Usage, error) {
+	usage, err := uu.load(usageKey(kind, id))
+	if err != nil {
+		return nil, err
+	}
+
+	refKey := usageRefKey(kind, id, file.BackendHash())
+	count, err := uu.refCount(refKey)
+	if err != nil {
+		return nil, err
+	}
+
+	size := file.Size()
+	if added {
+		usage.Files++
+		usage.Logical += size
+		if count == 0 {
+			usage.Deduplicated += size
+		}
+
+		count++
+	} else {
+		// Be defensive here; a broken record should
+		// not make the numbers overflow.
+		if usage.Files > 0 {
+			usage.Files--
+		}
+
+		usage.Logical -= minUint64(usage.Logical, size)
+		if count == 1 {
+			usage.Deduplicated -= minUint64(usage.Deduplicated, size)
+		}
+
+		if count > 0 {
+			count--
+		}
+	}
+
+	uu.refs[strings.Join(refKey, "\x00")] = &refRecord{key: refKey, count: count}
+	return usage, nil
+}
+
+func (uu *usageUpdate) visitFile(file *n.File, added bool) error {
+	dirPath := path.Dir(file.Path())
+	for {
+		if _, err := uu.account("dirs", dirPath, file, added); err != nil {
+			return err
+		}
+
+		if dirPath == "/" {
+			break
+		}
+
+		dirPath = path.Dir(dirPath)
+	}
+
+	usage, err := uu.account("users", file.User(), file, added)
+	if err != nil {
+		return err
+	}
+
+	uu.users[file.User()] = usage
+	return nil
+}
+
+func (uu *usageUpdate) visitTree(nd n.Node, added bool) error {
+	return n.Walk(uu.lkr, nd, true, func(child n.Node) error {
+		if child.Type() != n.NodeTypeFile {
+			return nil
+		}
+
+		file, ok := child.(*n.File)
+		if !ok {
+			return ie.ErrBadNode
+		}
+
+		return uu.visitFile(file, added)
+	})
+}
+
+// diff goes over all nodes that differ between `oldNd` and `newNd`.
+// Unchanged sub trees are skipped by comparing their tree hashes.
+func (uu *usageUpdate) diff(oldNd, newNd n.Node) error {
+	if oldNd != nil && newNd != nil && oldNd.TreeHash().Equal(newNd.TreeHash()) {
+		return nil
+	}
+
+	oldDir, oldIsDir := oldNd.(*n.Directory)
+	newDir, newIsDir := newNd.(*n.Directory)
+	if !oldIsDir || !newIsDir {
+		if oldNd != nil {
+			if err := uu.visitTree(oldNd, false); err != nil {
+				return err
+			}
+		}
+
+		if newNd != nil {
+			return uu.visitTree(newNd, true)
+		}
+
+		return nil
+	}
+
+	oldChildren, err := oldDir.ChildrenSorted(uu.lkr)
+	if err != nil {
+		return err
+	}
+
+	oldByName := make(map[string]n.Node)
+	for _, child := range oldChildren {
+		oldByName[child.Name()] = child
+	}
+
+	err = newDir.VisitChildren(uu.lkr, func(newChild n.Node) error {
+		oldChild := oldByName[newChild.Name()]
+		delete(oldByName, newChild.Name())
+		return uu.diff(oldChild, newChild)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, oldChild := range oldChildren {
+		if _, ok := oldByName[oldChild.Name()]; ok {
+			if err := uu.diff(oldChild, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (uu *usageUpdate) flush(batch db.Batch) error {
+	users := make(map[string]bool)
+	if uu.rebuild {
+		batch.Clear("usage")
+	} else {
+		known, err := readUsageUsers(uu.lkr.kv)
+		if err != nil {
+			return err
+		}
+
+		for _, user := range known {
+			users[user] = true
+		}
+	}
+
+	for _, record := range uu.records {
+		if record.usage.Files == 0 {
+			batch.Erase(record.key...)
+			continue
+		}
+
+		batch.Put(record.usage.marshal(), record.key...)
+	}
+
+	for _, record := range uu.refs {
+		if record.count == 0 {
+			batch.Erase(record.key...)
+			continue
+		}
+
+		data := make([]byte, 8)
+		binary.LittleEndian.PutUint64(data, record.count)
+		batch.Put(data, record.key...)
+	}
+
+	// Remember which users have records, so we can list them later.
+	for user, usage := range uu.users {
+		users[user] = usage.Files > 0
+	}
+
+	sortedUsers := []string{}
+	for user, hasFiles := range users {
+		if hasFiles {
+			sortedUsers = append(sortedUsers, user)
+		}
+	}
+
+	sort.Strings(sortedUsers)
+	batch.Put([]byte(strings.Join(sortedUsers, "\n")), "usage", "user-list")
+	return nil
+}
+
+func readUsageUsers(kv db.Database) ([]string, error) {
+	data, err := kv.Get("usage", "user-list")
+	if err == db.ErrNoSuchKey || len(data) == 0 {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Split(string(data), "\n"), nil
+}
+
+// updateUsage brings the usage records up to date with `newRoot`.
+// If the records were computed for `oldRoot` (which may be nil),
+// only the differences between both trees are visited.
+// Otherwise the records are rebuilt from scratch.
+func (lkr *Linker) updateUsage(batch db.Batch, oldRoot, newRoot *n.Directory) error {
+	uu := &usageUpdate{
+		lkr:     lkr,
+		records: make(map[string]*usageRecord),
+		refs:    make(map[string]*refRecord),
+		users:   make(map[string]*Usage),
+	}
+
+	var oldNd n.Node
+	if oldRoot != nil {
+		oldNd = oldRoot
+	}
+
+	base, err := lkr.kv.Get("usage", "root")
+	if err != nil && err != db.ErrNoSuchKey {
+		return err
+	}
+
+	if oldRoot == nil || string(base) != oldRoot.TreeHash().B58String() {
+		uu.rebuild = true
+		oldNd = nil
+	}
+
+	if err := uu.diff(oldNd, newRoot); err != nil {
+		return err
+	}
+
+	if err := uu.flush(batch); err != nil {
+		return err
+	}
+
+	batch.Put([]byte(newRoot.TreeHash().B58String()), "usage", "root")
+	return nil
+}
+
+func (lkr *Linker) ensureUsage() error {
+	head, err := lkr.Head()
+	if ie.IsErrNoSuchRef(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	root, err := lkr.DirectoryByHash(head.Root())
+	if err != nil {
+		return err
+	}
+
+	base, err := lkr.kv.Get("usage", "root")
+	if err != nil && err != db.ErrNoSuchKey {
+		return err
+	}
+
+	if string(base) == root.TreeHash().B58String() {
+		return nil
+	}
+
+	return lkr.AtomicWithBatch(func(batch db.Batch) (bool, error) {
+		return hintRollback(lkr.updateUsage(batch, nil, root))
+	})
+}
+
+func (lkr *Linker) readUsage(kind, id string) (*Usage, error) {
+	if err := lkr.ensureUsage(); err != nil {
+		return nil, err
+	}
+
+	usage := &Usage{}
+	data, err := lkr.kv.Get(usageKey(kind, id)...)
+	if err == db.ErrNoSuchKey {
+		return usage, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return usage, usage.unmarshal(data)
+}
+
+// DirUsage returns the usage of all files below the directory at `repoPath`
+// as of HEAD. Directories that do not exist have an empty usage.
+func (lkr *Linker) DirUsage(repoPath string) (*Usage, error) {
+	return lkr.readUsage("dirs", path.Join("/", repoPath))
+}
+
+// UserUsage returns the usage of all files that were last modified by `user`.
+func (lkr *Linker) UserUsage(user string) (*Usage, error) {
+	return lkr.readUsage("users", user)
+}
+
+// UsageByUser returns the usage of all users that modified
+// any of the files in HEAD last.
+func (lkr *Linker) UsageByUser() (map[string]*Usage, error) {
+	if err := lkr.ensureUsage(); err != nil {
+		return nil, err
+	}
+
+	users, err := readUsageUsers(lkr.kv)
+	if err != nil {
+		return nil, err
+	}
+
+	usages := make(map[string]*Usage)
+	for _, user := range users {
+		usage, err := lkr.UserUsage(user)
+		if err != nil {
+			return nil, err
+		}
+
+		usages[user] = usage
+	}
+
+	return usages, nil
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/sahib/brig/catfs/db"
+	h "github.com/sahib/brig/util/hashlib"
+	"github.com/stretchr/testify/require"
+)
+
+func requireUsage(t *testing.T, lkr *Linker, path string, files, logical, dedup uint64) {
+	usage, err := lkr.DirUsage(path)
+	require.Nil(t, err)
+	require.Equal(t, &Usage{
+		Files:        files,
+		Logical:      logical,
+		Deduplicated: dedup,
+	}, usage, path)
+}
+
+func TestUsage(t *testing.T) {
+	WithDummyLinker(t, func(lkr *Linker) {
+		// No commit yet, so no usage:
+		requireUsage(t, lkr, "/", 0, 0, 0)
+
+		// x and y have the same content.
+		_, err := Stage(lkr, "/a/x", h.TestDummy(t, 10), h.TestDummy(t, 10), 10, nil)
+		require.Nil(t, err)
+		_, err = Stage(lkr, "/a/y", h.TestDummy(t, 10), h.TestDummy(t, 10), 10, nil)
+		require.Nil(t, err)
+		_, err = Stage(lkr, "/b/z", h.TestDummy(t, 20), h.TestDummy(t, 20), 20, nil)
+		require.Nil(t, err)
+		MustCommit(t, lkr, "first")
+
+		requireUsage(t, lkr, "/", 3, 40, 30)
+		requireUsage(t, lkr, "/a", 2, 20, 10)
+		requireUsage(t, lkr, "/b", 1, 20, 20)
+		requireUsage(t, lkr, "/c", 0, 0, 0)
+
+		// Modify y, so it does not share the content with x anymore:
+		_, err = Stage(lkr, "/a/y", h.TestDummy(t, 5), h.TestDummy(t, 5), 5, nil)
+		require.Nil(t, err)
+		MustCommit(t, lkr, "second")
+
+		requireUsage(t, lkr, "/", 3, 35, 35)
+		requireUsage(t, lkr, "/a", 2, 15, 15)
+		requireUsage(t, lkr, "/b", 1, 20, 20)
+
+		// Move away and remove:
+		z, err := lkr.LookupModNode("/b/z")
+		require.Nil(t, err)
+		MustMove(t, lkr, z, "/a/z")
+		y, err := lkr.LookupModNode("/a/y")
+		require.Nil(t, err)
+		MustRemove(t, lkr, y)
+		MustCommit(t, lkr, "third")
+
+		requireUsage(t, lkr, "/", 2, 30, 30)
+		requireUsage(t, lkr, "/a", 2, 30, 30)
+		requireUsage(t, lkr, "/b", 0, 0, 0)
+
+		// Staged, but uncommitted changes are not accounted:
+		_, err = Stage(lkr, "/a/w", h.TestDummy(t, 7), h.TestDummy(t, 7), 7, nil)
+		require.Nil(t, err)
+		requireUsage(t, lkr, "/a", 2, 30, 30)
+
+		users, err := lkr.UsageByUser()
+		require.Nil(t, err)
+		require.Len(t, users, 1)
+
+		owner, err := lkr.Owner()
+		require.Nil(t, err)
+		require.Equal(t, &Usage{Files: 2, Logical: 30, Deduplicated: 30}, users[owner])
+
+		// If the records are out of date, they are rebuilt:
+		require.Nil(t, lkr.AtomicWithBatch(func(batch db.Batch) (bool, error) {
+			batch.Put([]byte("garbage"), "usage", "root")
+			return false, nil
+		}))
+
+		requireUsage(t, lkr, "/", 2, 30, 30)
+		MustCommit(t, lkr, "fourth")
+		requireUsage(t, lkr, "/", 3, 37, 37)
+	})
+}
@@ -21,6 +21,18 @@ var (
 
 	// ErrBadNode is returned when a wrong node type was passed to a method.
 	ErrBadNode = errors.New("Cannot convert to concrete type. Broken input data?")
+
+	// ErrHistoryPruned is returned when an operation needs a part of the
+	// history that was removed by pruning or was never fetched.
+	ErrHistoryPruned = errors.New("this part of the history was pruned")
+
+	// ErrQuotaExceeded is returned when an operation would make a folder
+	// or a remote use more storage than its quota allows.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+
+	// ErrPendingUploads is returned when a commit would contain content
+	// that is still waiting in the write queue for the backend.
+	ErrPendingUploads = errors.New("staged content was not uploaded to the backend yet")
 )
 
 //////////////
@@ -2,6 +2,7 @@ package catfs
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"bytes"
 	"encoding/binary"
 	"errors"
@@ -9,6 +10,7 @@ import (
 	"io"
 	"io/ioutil"
 	"path"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -27,6 +29,7 @@ import (
 	"github.com/sahib/brig/catfs/mio/compress"
 	n "github.com/sahib/brig/catfs/nodes"
 	"github.com/sahib/brig/catfs/vcs"
+	"github.com/sahib/brig/metrics"
 	"github.com/sahib/brig/util"
 	h "github.com/sahib/brig/util/hashlib"
 )
@@ -64,6 +67,13 @@ type FS struct {
 	// channel to schedule repins and quit the loop
 	repinControl chan string
 
+	// channel to quit the write queue loop
+	queueControl chan bool
+
+	// queue for content added while the backend is offline.
+	// It wraps the actual backend and is used as `bk`.
+	queue *writeQueue
+
 	// Actual storage backend (e.g. ipfs or memory)
 	bk FsBackend
 
@@ -76,6 +86,12 @@ type FS struct {
 	// wether this fs is read only and cannot be changed.
 	// It can be change by applying patches though.
 	readOnly bool
+
+	// signer signs new commits and patches, if set.
+	signer func(data []byte) ([]byte, error)
+
+	// verifier checks signatures of commits and patches, if set.
+	verifier func(data, sig []byte) error
 }
 
 // ErrReadOnly is returned when a file system was created in read only mode
@@ -114,6 +130,8 @@ type StatInfo struct {
 	IsPinned bool
 	// IsExplicit is true when the user pinned this node on purpose
 	IsExplicit bool
+	// KeyScheme tells how the key of a file was chosen (empty for directories)
+	KeyScheme string
 }
 
 // DiffPair is a pair of nodes.
@@ -162,6 +180,9 @@ type Commit struct {
 	Date time.Time
 	// Index is the index of the commit:
 	Index int64
+	// Signature is the state of the commit's signature (see SignatureGood
+	// and friends). It is empty for the staging commit.
+	Signature string
 }
 
 // Change describes a single change to a node between two versions
@@ -209,8 +230,12 @@ func (fs *FS) nodeToStat(nd n.Node) *StatInfo {
 		log.Warningf("stat: failed to acquire pin state: %v", err)
 	}
 
-	isDir := false
+	isDir, keyScheme := false, ""
 	switch nd.Type() {
+	case n.NodeTypeFile:
+		if file, ok := nd.(*n.File); ok {
+			keyScheme = string(file.KeyScheme())
+		}
 	case n.NodeTypeDirectory:
 		isDir = true
 	case n.NodeTypeGhost:
@@ -230,6 +255,7 @@ func (fs *FS) nodeToStat(nd n.Node) *StatInfo {
 		Depth:       n.Depth(nd),
 		IsPinned:    isPinned,
 		IsExplicit:  isExplicit,
+		KeyScheme:   keyScheme,
 		ContentHash: nd.ContentHash().Clone(),
 		BackendHash: nd.BackendHash().Clone(),
 		TreeHash:    nd.TreeHash().Clone(),
@@ -315,7 +341,12 @@ func NewFilesystem(backend FsBackend, dbPath string, owner string, readOnly bool
 		return nil, err
 	}
 
-	pinCache, err := NewPinner(lkr, backend)
+	queue, err := newWriteQueue(backend, filepath.Join(dbPath, "queue"))
+	if err != nil {
+		return nil, err
+	}
+
+	pinCache, err := NewPinner(lkr, queue)
 	if err != nil {
 		return nil, err
 	}
@@ -327,12 +358,14 @@ func NewFilesystem(backend FsBackend, dbPath string, owner string, readOnly bool
 	fs := &FS{
 		kv:                kv,
 		lkr:               lkr,
-		bk:                backend,
+		bk:                queue,
+		queue:             queue,
 		cfg:               fsCfg,
 		readOnly:          readOnly,
 		gcControl:         make(chan bool, 1),
 		autoCommitControl: make(chan bool, 1),
 		repinControl:      make(chan string, 1),
+		queueControl:      make(chan bool, 1),
 		pinner:            pinCache,
 	}
 
@@ -344,6 +377,7 @@ func NewFilesystem(backend FsBackend, dbPath string, owner string, readOnly bool
 	go fs.gcLoop()
 	go fs.autoCommitLoop()
 	go fs.repinLoop()
+	go fs.queueLoop()
 
 	return fs, nil
 }
@@ -386,7 +420,12 @@ func (fs *FS) autoCommitLoop() {
 			if time.Since(lastCheck) >= fs.cfg.Duration("autocommit.interval") {
 				lastCheck = time.Now()
 				msg := fmt.Sprintf("auto commit at »%s«", time.Now().Format(time.RFC822))
-				if err := fs.MakeCommit(msg); err != nil && err != ie.ErrNoChange {
+				err := fs.MakeCommit(msg)
+				switch err {
+				case nil, ie.ErrNoChange:
+				case ie.ErrPendingUploads:
+					log.Debugf("auto commit waits for the write queue")
+				default:
 					log.Warningf("failed to create auto commit: %v", err)
 				}
 			}
@@ -443,6 +482,7 @@ func (fs *FS) Close() error {
 	go func() { fs.gcControl <- false }()
 	go func() { fs.autoCommitControl <- false }()
 	go func() { fs.repinControl <- "" }()
+	go func() { fs.queueControl <- false }()
 
 	if err := fs.pinner.Close(); err != nil {
 		log.Warnf("Failed to close pin cache: %v", err)
@@ -460,17 +500,71 @@ func (fs *FS) Export(w io.Writer) error {
 }
 
 // Import will read a previously FS dump from `r`.
+// The history in the dump is checked like in VerifyHistory
+// and nothing is imported if it is rejected. Afterwards,
+// LastPatchIndex returns the index of the imported state.
 func (fs *FS) Import(r io.Reader) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	if err := fs.kv.Import(r); err != nil {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.verifyDump(data); err != nil {
+		return err
+	}
+
+	if err := fs.kv.Import(bytes.NewReader(data)); err != nil {
 		return err
 	}
 
 	// disk (probably) changed, delete memcache:
 	fs.lkr.MemIndexClear()
-	return nil
+
+	// The dump contains the metadata of the other side.
+	// Continue with patches from the state we just imported:
+	status, err := fs.lkr.Status()
+	if err != nil {
+		return err
+	}
+
+	return fs.writeLastPatchIndex(status.Index())
+}
+
+// PinnedContent returns the backend hashes of all content that is pinned
+// by at least one node, as known by the pin cache.
+func (fs *FS) PinnedContent() ([]h.Hash, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	keys, err := fs.kv.Keys("pins")
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := []h.Hash{}
+	for _, key := range keys {
+		hash, err := h.FromB58String(key[len(key)-1])
+		if err != nil {
+			log.Warningf("pin cache has an invalid key: %v", key)
+			continue
+		}
+
+		entry, err := getEntry(fs.kv, hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if entry == nil || len(entry.Inodes) == 0 {
+			continue
+		}
+
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, nil
 }
 
 /////////////////////
@@ -692,13 +786,15 @@ func (fs *FS) List(root string, maxDepth int) ([]*StatInfo, error) {
 // preCache makes the backend fetch the data already from the network,
 // even though it might not be needed yet.
 func (fs *FS) preCache(hash h.Hash) error {
-	stream, err := fs.bk.Cat(hash)
-	if err != nil {
-		return err
-	}
+	return fs.pinner.download(hash)
+}
 
-	_, err = io.Copy(ioutil.Discard, stream)
-	return err
+// SetContentThrottle sets a function that wraps the content streams that
+// are fetched from the backend when pinning content that is not cached yet
+// and in the background after pinning (see fs.pre_cache).
+// It can be used to limit the bandwidth used by them.
+func (fs *FS) SetContentThrottle(throttle func(r io.Reader) io.Reader) {
+	fs.pinner.setThrottle(throttle)
 }
 
 func (fs *FS) preCacheInBackground(hash h.Hash) {
@@ -715,35 +811,82 @@ func (fs *FS) preCacheInBackground(hash h.Hash) {
 
 // Pin will pin the file or directory at `path` explicitly.
 func (fs *FS) Pin(path, rev string, explicit bool) error {
+	// Fetching throttled content might take a long while.
+	// Do it before doPin takes the lock:
+	if err := fs.prefetch(path, rev); err != nil {
+		return err
+	}
+
 	return fs.doPin(path, rev, fs.pinner.PinNode, explicit)
 }
 
+// prefetch downloads all files at or below `path` in `rev`
+// that are not cached yet (see Pinner.prefetch).
+func (fs *FS) prefetch(path, rev string) error {
+	fs.mu.Lock()
+	hashes := []h.Hash{}
+	nd, err := fs.nodeAtRev(path, rev)
+	if err == nil {
+		err = n.Walk(fs.lkr, nd, true, func(child n.Node) error {
+			if child.Type() == n.NodeTypeFile {
+				hashes = append(hashes, child.BackendHash())
+			}
+
+			return nil
+		})
+	}
+
+	fs.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		if err := fs.pinner.prefetch(hash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Unpin will unpin the file or directory at `path` explicitly.
 func (fs *FS) Unpin(path, rev string, explicit bool) error {
 	return fs.doPin(path, rev, fs.pinner.UnpinNode, explicit)
 }
 
-func (fs *FS) doPin(path, rev string, op func(nd n.Node, explicit bool) error, explicit bool) error {
-	fs.mu.Lock()
-	defer fs.mu.Unlock()
-
+// nodeAtRev returns the file or directory at `path` in `rev`.
+func (fs *FS) nodeAtRev(path, rev string) (n.Node, error) {
 	cmt, err := parseRev(fs.lkr, rev)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	root, err := fs.lkr.DirectoryByHash(cmt.Root())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	nd, err := root.Lookup(fs.lkr, path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if nd == nil || nd.Type() == n.NodeTypeGhost {
-		return ie.NoSuchFile(path)
+		return nil, ie.NoSuchFile(path)
+	}
+
+	return nd, nil
+}
+
+func (fs *FS) doPin(path, rev string, op func(nd n.Node, explicit bool) error, explicit bool) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	nd, err := fs.nodeAtRev(path, rev)
+	if err != nil {
+		return err
 	}
 
 	if err := op(nd, explicit); err != nil {
@@ -928,7 +1071,8 @@ func (fs *FS) renewPins(oldFile, newFile *n.File) error {
 }
 
 // Stage reads all data from `r` and stores as content of the node at `path`.
-// If `path` already exists, it will be updated.
+// If `path` already exists, it will be updated. If the new content would
+// exceed the quota of a folder, an error wrapping ErrQuotaExceeded is returned.
 func (fs *FS) Stage(path string, r io.ReadSeeker) error {
 	fs.mu.Lock()
 
@@ -977,6 +1121,12 @@ func (fs *FS) Stage(path string, r io.ReadSeeker) error {
 		oldFileCopy = oldFile.Copy(oldFile.Inode()).(*n.File)
 	}
 
+	scheme, err := fs.keySchemeFor(path)
+	if err != nil {
+		fs.mu.Unlock()
+		return err
+	}
+
 	// Unlock the fs lock while adding the stream to the backend.
 	// This is not required for the data integrity of the fs.
 	fs.mu.Unlock()
@@ -986,21 +1136,45 @@ func (fs *FS) Stage(path string, r io.ReadSeeker) error {
 		return err
 	}
 
+	if oldFileCopy != nil && contentHash.Equal(oldFileCopy.ContentHash()) {
+		log.Infof("content of %s did not change; not modifying", path)
+		return nil
+	}
+
+	if oldFileCopy != nil && oldFileCopy.KeyScheme() == n.KeySchemeRandom {
+		// Files with random keys keep them, even if the folder changed.
+		scheme = n.KeySchemeRandom
+	}
+
 	var key []byte
-	if oldFileCopy == nil {
-		// only create a new key for new files.
-		// The key depends on the content hash and the size.
-		key = deriveKeyFromContent(contentHash, size)
-	} else {
-		if contentHash.Equal(oldFileCopy.ContentHash()) {
-			log.Infof("content of %s did not change; not modifying", path)
-			return nil
+	if oldFileCopy == nil || scheme != oldFileCopy.KeyScheme() || scheme == n.KeySchemeRandom {
+		// Create a new key for new files, for files in a folder that switched
+		// the scheme and for every new content of a file with a random key.
+		// The encryption nonces only depend on the block number, so
+		// re-using the key with different content would leak data.
+		// Convergent keys depend on the content hash and the size.
+		if key, err = newFileKey(scheme, contentHash, size); err != nil {
+			return err
 		}
-
+	} else {
 		// Next generations of the same file get the same key.
 		key = oldFileCopy.Key()
 	}
 
+	// Check the quotas before uploading anything to the backend.
+	growth := int64(size)
+	if oldFileCopy != nil {
+		growth -= int64(oldFileCopy.Size())
+	}
+
+	fs.mu.Lock()
+	err = fs.checkQuotas(map[string]int64{path: growth})
+	fs.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
 	stream, err := mio.NewInStream(r, key, compressAlgo)
 	if err != nil {
 		return err
@@ -1015,11 +1189,12 @@ func (fs *FS) Stage(path string, r io.ReadSeeker) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	newFile, err := c.Stage(fs.lkr, path, contentHash, backendHash, size, key)
+	newFile, err := c.StageWithKeyScheme(fs.lkr, path, contentHash, backendHash, size, key, scheme)
 	if err != nil {
 		return err
 	}
 
+	metrics.StagedBytes.Add(float64(size))
 	return fs.pinner.PinNode(newFile, false)
 }
 
@@ -1028,9 +1203,10 @@ func (fs *FS) Stage(path string, r io.ReadSeeker) error {
 ////////////////////
 
 type tarEntry struct {
-	path   string
-	size   int64
-	stream mio.Stream
+	path    string
+	size    int64
+	modTime time.Time
+	stream  mio.Stream
 }
 
 func (fs *FS) getTarableEntries(root string, filter func(node *StatInfo) bool) ([]tarEntry, string, error) {
@@ -1066,9 +1242,10 @@ func (fs *FS) getTarableEntries(root string, filter func(node *StatInfo) bool) (
 		}
 
 		entries = append(entries, tarEntry{
-			path:   child.Path(),
-			size:   int64(child.Size()),
-			stream: stream,
+			path:    child.Path(),
+			size:    int64(child.Size()),
+			modTime: child.ModTime(),
+			stream:  stream,
 		})
 		return nil
 	})
@@ -1086,9 +1263,10 @@ func (fs *FS) getTarableEntries(root string, filter func(node *StatInfo) bool) (
 	return entries, prefixPath, err
 }
 
-// Tar produces a tar archive from the file or directory at `root` and writes
-// the output to `w`. If you want compression, supply a gzip writer.
-func (fs *FS) Tar(root string, w io.Writer, filter func(node *StatInfo) bool) error {
+// writeArchive writes all files below `root` to an archive. `create` is called
+// for every file and should return the writer the content should go to.
+// `archive` is closed at the end, or when any error happens.
+func (fs *FS) writeArchive(root string, filter func(node *StatInfo) bool, archive io.Closer, create func(name string, entry tarEntry) (io.Writer, error)) error {
 	// getTarableEntries is locking fs.mu while it is running.
 	// the rest of the code in this method should NOT use any nodes
 	// or anything that is open to race conditions!
@@ -1097,10 +1275,8 @@ func (fs *FS) Tar(root string, w io.Writer, filter func(node *StatInfo) bool) er
 		return err
 	}
 
-	tw := tar.NewWriter(w)
-
 	// Make sure to close all remaining streams when any error happens.
-	// Also clean up the tar writer. This might flush some data still.
+	// Also clean up the archive writer. This might flush some data still.
 	// The user of this API should not use `w` if an error happens.
 	cleanup := func(idx int) {
 		for ; idx < len(entries); idx++ {
@@ -1110,22 +1286,17 @@ func (fs *FS) Tar(root string, w io.Writer, filter func(node *StatInfo) bool) er
 			}
 		}
 
-		tw.Close()
+		archive.Close()
 	}
 
 	for idx, entry := range entries {
-		hdr := &tar.Header{
-			Name: entry.path[len(prefixPath):],
-			Mode: 0600,
-			Size: entry.size,
-		}
-
-		if err := tw.WriteHeader(hdr); err != nil {
+		w, err := create(entry.path[len(prefixPath):], entry)
+		if err != nil {
 			cleanup(idx)
 			return err
 		}
 
-		if _, err := io.Copy(tw, entry.stream); err != nil {
+		if _, err := io.Copy(w, entry.stream); err != nil {
 			cleanup(idx)
 			return err
 		}
@@ -1136,7 +1307,39 @@ func (fs *FS) Tar(root string, w io.Writer, filter func(node *StatInfo) bool) er
 		}
 	}
 
-	return tw.Close()
+	return archive.Close()
+}
+
+// Tar produces a tar archive from the file or directory at `root` and writes
+// the output to `w`. If you want compression, supply a gzip writer.
+func (fs *FS) Tar(root string, w io.Writer, filter func(node *StatInfo) bool) error {
+	tw := tar.NewWriter(w)
+	return fs.writeArchive(root, filter, tw, func(name string, entry tarEntry) (io.Writer, error) {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    entry.size,
+			ModTime: entry.modTime,
+		}
+
+		return tw, tw.WriteHeader(hdr)
+	})
+}
+
+// Zip works like Tar, but produces a (deflate compressed) zip archive.
+func (fs *FS) Zip(root string, w io.Writer, filter func(node *StatInfo) bool) error {
+	zw := zip.NewWriter(w)
+	return fs.writeArchive(root, filter, zw, func(name string, entry tarEntry) (io.Writer, error) {
+		hdr := &zip.FileHeader{
+			// zip does not like absolute paths:
+			Name:     strings.TrimPrefix(name, "/"),
+			Method:   zip.Deflate,
+			Modified: entry.modTime,
+		}
+
+		hdr.SetMode(0600)
+		return zw.CreateHeader(hdr)
+	})
 }
 
 // Cat will open a file read-only and expose it's underlying data as stream.
@@ -1163,7 +1366,29 @@ func (fs *FS) Cat(path string) (mio.Stream, error) {
 
 	fs.mu.Unlock()
 
-	return fs.catHash(backendHash, key, size)
+	stream, err := fs.catHash(backendHash, key, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &countingStream{Stream: stream}, nil
+}
+
+// countingStream reports the number of bytes read from a stream.
+type countingStream struct {
+	mio.Stream
+}
+
+func (cs *countingStream) Read(buf []byte) (int, error) {
+	n, err := cs.Stream.Read(buf)
+	metrics.CatBytes.Add(float64(n))
+	return n, err
+}
+
+func (cs *countingStream) WriteTo(w io.Writer) (int64, error) {
+	n, err := cs.Stream.WriteTo(w)
+	metrics.CatBytes.Add(float64(n))
+	return n, err
 }
 
 // NOTE: This method can be called without locking fs.mu!
@@ -1211,9 +1436,18 @@ func (fs *FS) Open(path string) (*Handle, error) {
 // If no changes were made since the last call to MakeCommit() ErrNoConflict
 // is returned.
 func (fs *FS) MakeCommit(msg string) error {
+	// Make sure we commit backend hashes if the backend is back already:
+	if err := fs.DrainWriteQueue(); err != nil {
+		return err
+	}
+
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	if err := fs.checkPlaceholders(); err != nil {
+		return err
+	}
+
 	owner, err := fs.lkr.Owner()
 	if err != nil {
 		return err
@@ -1530,6 +1764,63 @@ func SyncOptConflictgStrategyPerFolder(strategies map[string]string) SyncOption
 	}
 }
 
+// SyncOptOnlyFolders limits the sync to the given folders.
+// Everything outside of them will not be modified by the sync.
+// An empty list means that all folders are synced.
+func SyncOptOnlyFolders(folders []string) SyncOption {
+	return func(cfg *vcs.SyncOptions) {
+		cfg.OnlyFolders = append(cfg.OnlyFolders, folders...)
+	}
+}
+
+// SyncOptProgress calls `fn` every time a node was added, removed,
+// merged or ended up in a conflict during the sync. `fn` is given the
+// number of nodes that were processed so far.
+func SyncOptProgress(fn func(done int64)) SyncOption {
+	return func(cfg *vcs.SyncOptions) {
+		done := int64(0)
+		count := func() {
+			done++
+			fn(done)
+		}
+
+		onAdd, onRemove := cfg.OnAdd, cfg.OnRemove
+		onMerge, onConflict := cfg.OnMerge, cfg.OnConflict
+
+		cfg.OnAdd = func(newNd n.ModNode) bool {
+			defer count()
+			return onAdd == nil || onAdd(newNd)
+		}
+
+		cfg.OnRemove = func(oldNd n.ModNode) bool {
+			defer count()
+			return onRemove == nil || onRemove(oldNd)
+		}
+
+		cfg.OnMerge = func(src, dst n.ModNode) bool {
+			defer count()
+			return onMerge == nil || onMerge(src, dst)
+		}
+
+		cfg.OnConflict = func(src, dst n.ModNode) bool {
+			defer count()
+			return onConflict == nil || onConflict(src, dst)
+		}
+	}
+}
+
+// SyncOptOnConflict calls `fn` with the path of every node that
+// ended up in a conflict during the sync.
+func SyncOptOnConflict(fn func(path string)) SyncOption {
+	return func(cfg *vcs.SyncOptions) {
+		onConflict := cfg.OnConflict
+		cfg.OnConflict = func(src, dst n.ModNode) bool {
+			fn(src.Path())
+			return onConflict == nil || onConflict(src, dst)
+		}
+	}
+}
+
 // Sync will synchronize the state of two filesystems.
 // If one of filesystems have unstaged changes, they will be committted first.
 // If our filesystem was changed by Sync(), a new merge commit will also be created.
@@ -1551,12 +1842,18 @@ func (fs *FS) Sync(remote *FS, options ...SyncOption) error {
 		option(syncCfg)
 	}
 
+	// The sync commits our staging area too:
+	if err := fs.checkPlaceholders(); err != nil {
+		return err
+	}
+
 	return vcs.Sync(remote.lkr, fs.lkr, syncCfg)
 }
 
 // MakeDiff will return a diff between `headRevOwn` and `headRevRemote`.
 // `remote` is the filesystem `headRevRemote` belongs to and may be the same as `fs`.
-func (fs *FS) MakeDiff(remote *FS, headRevOwn, headRevRemote string) (*Diff, error) {
+// The same options as for Sync() may be given to see what a sync would do.
+func (fs *FS) MakeDiff(remote *FS, headRevOwn, headRevRemote string, options ...SyncOption) (*Diff, error) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
@@ -1575,6 +1872,10 @@ func (fs *FS) MakeDiff(remote *FS, headRevOwn, headRevRemote string) (*Diff, err
 		return nil, err
 	}
 
+	for _, option := range options {
+		option(syncCfg)
+	}
+
 	realDiff, err := vcs.MakeDiff(remote.lkr, fs.lkr, srcHead, dstHead, syncCfg)
 	if err != nil {
 		return nil, e.Wrapf(err, "make diff")
@@ -1678,8 +1979,20 @@ func (fs *FS) Log(head string, fn func(c *Commit) error) error {
 		return err
 	}
 
+	status, err := fs.lkr.Status()
+	if err != nil {
+		return err
+	}
+
 	return c.Log(fs.lkr, headCmt, func(cmt *n.Commit) error {
-		return fn(commitToExternal(cmt, hashToRef))
+		extCmt := commitToExternal(cmt, hashToRef)
+
+		// The staging commit is never signed:
+		if !cmt.TreeHash().Equal(status.TreeHash()) {
+			extCmt.Signature = fs.commitSignature(cmt)
+		}
+
+		return fn(extCmt)
 	})
 }
 
@@ -1772,6 +2085,63 @@ func (fs *FS) RemoveTag(name string) error {
 	return fs.lkr.RemoveRef(name)
 }
 
+// PruneHistory squashes all commits that are older than `keepSince` and
+// not within the last `keepLast` commits into one base commit. A zero value
+// for either criteria means that it is not used. If both are given, the
+// commits matching either criteria are kept. Tagged commits are always kept.
+// The number of removed commits is returned.
+func (fs *FS) PruneHistory(keepSince time.Time, keepLast int) (int, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	if keepSince.IsZero() && keepLast <= 0 {
+		return 0, fmt.Errorf("need at least one criteria to decide what to keep")
+	}
+
+	head, err := fs.lkr.Head()
+	if err != nil {
+		return 0, err
+	}
+
+	// Go back from HEAD until we find the first commit we don't need to keep.
+	// This one will become the new base of the history.
+	base := head
+	for idx := 1; ; idx++ {
+		isRecent := !keepSince.IsZero() && !base.ModTime().Before(keepSince)
+		if !isRecent && idx > keepLast {
+			break
+		}
+
+		parent, err := base.Parent(fs.lkr)
+		if err != nil {
+			return 0, err
+		}
+
+		if parent == nil {
+			// Nothing to prune, the history is short enough.
+			return 0, nil
+		}
+
+		parentCmt, ok := parent.(*n.Commit)
+		if !ok {
+			return 0, ie.ErrBadNode
+		}
+
+		base = parentCmt
+	}
+
+	return fs.lkr.PruneHistory(base, func(file *n.File) {
+		// The content is not referenced by any version anymore.
+		if err := fs.pinner.Unpin(file.Inode(), file.BackendHash(), true); err != nil {
+			log.Warningf("failed to unpin pruned %s: %v", file.Path(), err)
+		}
+	})
+}
+
 // FilesByContent returns all stat info for the content hashes referenced in
 // `contents`.  The return value is a map with the content hash as key and a
 // StatInfo describing the exact file content.
@@ -1809,7 +2179,8 @@ func (fs *FS) writeLastPatchIndex(index int64) error {
 
 // MakePatch creates a binary patch with all file changes starting with
 // `fromRev`. Note that commit information is not exported, only individual
-// file and directory changes.
+// file and directory changes. If `fromRev` is the initial commit or is not
+// part of the history anymore, the complete state is exported instead.
 //
 // The byte structured returned by this method may change at any point
 // and may not be relied upon.
@@ -1832,6 +2203,10 @@ func (fs *FS) MakePatch(fromRev string, folders []string, remoteName string) ([]
 	// anymore then, since the same version might have a different
 	// set of changes.
 	if haveStagedChanges {
+		if err := fs.checkPlaceholders(); err != nil {
+			return nil, err
+		}
+
 		owner, err := fs.lkr.Owner()
 		if err != nil {
 			return nil, err
@@ -1844,15 +2219,32 @@ func (fs *FS) MakePatch(fromRev string, folders []string, remoteName string) ([]
 	}
 
 	from, err := parseRev(fs.lkr, fromRev)
-	if err != nil {
+	if err != nil && e.Cause(err) != ie.ErrHistoryPruned {
 		return nil, err
 	}
 
-	patch, err := vcs.MakePatch(fs.lkr, from, folders)
+	var patch *vcs.Patch
+	if from == nil || from.Index() == 0 {
+		// The other side did not fetch from us yet or wants to have
+		// changes from a part of the history that we do not have anymore.
+		// Send our complete state instead, so the other side can start over.
+		log.Infof("sending complete state to %s instead of changes since %s", remoteName, fromRev)
+		patch, err = vcs.MakeShallowPatch(fs.lkr, folders)
+	} else {
+		patch, err = vcs.MakePatch(fs.lkr, from, folders)
+	}
+
 	if err != nil {
 		return nil, err
 	}
 
+	if fs.signer != nil {
+		patch.Signature, err = fs.signer(patch.Digest())
+		if err != nil {
+			return nil, e.Wrapf(err, "failed to sign patch")
+		}
+	}
+
 	msg, err := patch.ToCapnp()
 	if err != nil {
 		return nil, err
@@ -1876,6 +2268,19 @@ func (fs *FS) ApplyPatch(data []byte) error {
 		return err
 	}
 
+	status := fs.signatureStatus(patch.Digest(), patch.Signature)
+	if status != SignatureGood {
+		owner, err := fs.lkr.Owner()
+		if err != nil {
+			return err
+		}
+
+		reason := fmt.Sprintf("patch from %s: signature is %s", owner, status)
+		if err := fs.rejectSignature(reason); err != nil {
+			return err
+		}
+	}
+
 	if err := vcs.ApplyPatch(fs.lkr, patch); err != nil {
 		return err
 	}
@@ -30,7 +30,10 @@ func init() {
 }
 
 func withDummyFSReadOnly(t *testing.T, readOnly bool, fn func(fs *FS)) {
-	backend := NewMemFsBackend()
+	withDummyFSBackend(t, NewMemFsBackend(), readOnly, fn)
+}
+
+func withDummyFSBackend(t *testing.T, backend FsBackend, readOnly bool, fn func(fs *FS)) {
 	owner := "alice"
 
 	dbPath, err := ioutil.TempDir("", "brig-fs-test")
@@ -409,6 +412,43 @@ func TestExportImport(t *testing.T) {
 	})
 }
 
+func TestImportLastPatchIndex(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(srcFs *FS) {
+		require.Nil(t, srcFs.Stage("/x", bytes.NewReader([]byte{1})))
+		require.Nil(t, srcFs.MakeCommit("add x"))
+
+		mem := &bytes.Buffer{}
+		require.Nil(t, srcFs.Export(mem))
+
+		withDummyFS(t, func(dstFs *FS) {
+			require.Nil(t, dstFs.Import(mem))
+
+			// Later patches should continue from the imported state:
+			status, err := srcFs.lkr.Status()
+			require.Nil(t, err)
+
+			index, err := dstFs.LastPatchIndex()
+			require.Nil(t, err)
+			require.Equal(t, status.Index(), index)
+
+			require.Nil(t, srcFs.Stage("/y", bytes.NewReader([]byte{2})))
+			require.Nil(t, srcFs.MakeCommit("add y"))
+
+			patch, err := srcFs.MakePatch(fmt.Sprintf("commit[%d]", index), nil, "")
+			require.Nil(t, err)
+			require.Nil(t, dstFs.ApplyPatch(patch))
+
+			_, err = dstFs.Stat("/x")
+			require.Nil(t, err)
+
+			_, err = dstFs.Stat("/y")
+			require.Nil(t, err)
+		})
+	})
+}
+
 func TestSync(t *testing.T) {
 	t.Parallel()
 
@@ -442,6 +482,29 @@ func TestSync(t *testing.T) {
 	})
 }
 
+func TestSyncProgress(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fsa *FS) {
+		require.Nil(t, fsa.MakeCommit("hello a"))
+		withDummyFS(t, func(fsb *FS) {
+			require.Nil(t, fsb.Stage("/x", bytes.NewReader([]byte{1})))
+			require.Nil(t, fsb.Stage("/y", bytes.NewReader([]byte{2})))
+			require.Nil(t, fsb.MakeCommit("hello b"))
+
+			progress := []int64{}
+			require.Nil(t, fsa.Sync(fsb, SyncOptProgress(func(done int64) {
+				progress = append(progress, done)
+			})))
+
+			require.Equal(t, []int64{1, 2}, progress)
+
+			_, err := fsa.Stat("/x")
+			require.Nil(t, err)
+		})
+	})
+}
+
 func TestMakeDiff(t *testing.T) {
 	t.Parallel()
 
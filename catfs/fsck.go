@@ -0,0 +1,260 @@
+package catfs
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sahib/brig/catfs/db"
+	"github.com/sahib/brig/catfs/mio"
+	n "github.com/sahib/brig/catfs/nodes"
+	h "github.com/sahib/brig/util/hashlib"
+)
+
+const (
+	// FsckBadPinEntry means that the pin cache has an invalid entry
+	// or that the backend does not have a pin the cache knows of.
+	FsckBadPinEntry = "bad-pin-entry"
+	// FsckMissingContent means that the backend could not deliver
+	// the content of a file.
+	FsckMissingContent = "missing-content"
+	// FsckBadContent means that the content of a file could not be
+	// decrypted or does not match its content hash.
+	FsckBadContent = "bad-content"
+)
+
+// FsckProblem describes a single inconsistency found by Fsck().
+// Kind is either one of the Fsck* constants of this package
+// or one of the catfs/core package.
+type FsckProblem struct {
+	Kind     string
+	Path     string
+	Hash     h.Hash
+	Detail   string
+	Repaired bool
+}
+
+// checkPins verifies the entries of the pin cache. If `deep` is true the
+// backend is asked if it really has the pins the cache knows of.
+func (pc *Pinner) checkPins(deep, repair bool) ([]FsckProblem, error) {
+	problems := []FsckProblem{}
+	kv := pc.lkr.KV()
+
+	keys, err := kv.Keys("pins")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		problem := FsckProblem{Kind: FsckBadPinEntry}
+		hash, err := h.FromB58String(key[len(key)-1])
+		if err != nil {
+			problem.Detail = fmt.Sprintf("invalid hash: %v", err)
+		}
+
+		var entry *pinCacheEntry
+		if problem.Detail == "" {
+			problem.Hash = hash
+			entry, err = getEntry(kv, hash)
+			if err != nil {
+				problem.Detail = fmt.Sprintf("failed to decode entry: %v", err)
+			}
+		}
+
+		if problem.Detail != "" {
+			// Nothing to save here; the entry will be recreated on demand.
+			if repair {
+				if err := pc.lkr.AtomicWithBatch(func(batch db.Batch) (bool, error) {
+					batch.Erase(key...)
+					return false, nil
+				}); err != nil {
+					return nil, err
+				}
+
+				problem.Repaired = true
+			}
+
+			problems = append(problems, problem)
+			continue
+		}
+
+		staleInodes := []uint64{}
+		for inode := range entry.Inodes {
+			nd, err := pc.lkr.NodeByInode(inode)
+			if err != nil {
+				return nil, err
+			}
+
+			if nd == nil {
+				staleInodes = append(staleInodes, inode)
+			}
+		}
+
+		if len(staleInodes) > 0 {
+			problem := FsckProblem{
+				Kind:   FsckBadPinEntry,
+				Hash:   hash,
+				Detail: fmt.Sprintf("pin cache refers to unresolvable inodes %v", staleInodes),
+			}
+
+			if repair {
+				for _, inode := range staleInodes {
+					if err := pc.remember(inode, hash, false, false); err != nil {
+						return nil, err
+					}
+
+					delete(entry.Inodes, inode)
+				}
+
+				problem.Repaired = true
+			}
+
+			problems = append(problems, problem)
+		}
+
+		if !deep || len(entry.Inodes) == 0 {
+			continue
+		}
+
+		isPinned, err := pc.bk.IsPinned(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if isPinned {
+			continue
+		}
+
+		problem = FsckProblem{
+			Kind:   FsckBadPinEntry,
+			Hash:   hash,
+			Detail: "content is pinned in the cache, but not in the backend",
+		}
+
+		if repair {
+			if err := pc.bk.Pin(hash); err != nil {
+				return nil, err
+			}
+
+			problem.Repaired = true
+		}
+
+		problems = append(problems, problem)
+	}
+
+	return problems, nil
+}
+
+// checkContent reads and authenticates the content of `file`.
+func (fs *FS) checkContent(file *n.File) *FsckProblem {
+	problem := &FsckProblem{
+		Kind: FsckBadContent,
+		Path: file.Path(),
+		Hash: file.BackendHash(),
+	}
+
+	rawStream, err := fs.bk.Cat(file.BackendHash())
+	if err != nil {
+		problem.Kind = FsckMissingContent
+		problem.Detail = fmt.Sprintf("failed to get content: %v", err)
+		return problem
+	}
+
+	defer rawStream.Close()
+
+	stream, err := mio.NewOutStream(rawStream, file.Key())
+	if err != nil {
+		problem.Detail = fmt.Sprintf("failed to decrypt: %v", err)
+		return problem
+	}
+
+	hashWriter := h.NewHashWriter()
+	size, err := io.Copy(hashWriter, mio.LimitStream(stream, file.Size()))
+	if err != nil {
+		problem.Detail = fmt.Sprintf("failed to decrypt: %v", err)
+		return problem
+	}
+
+	if uint64(size) != file.Size() {
+		problem.Detail = fmt.Sprintf("expected %d bytes, but got only %d", file.Size(), size)
+		return problem
+	}
+
+	if !hashWriter.Finalize().Equal(file.ContentHash()) {
+		problem.Detail = "content does not match the content hash"
+		return problem
+	}
+
+	return nil
+}
+
+// Fsck checks the filesystem for consistency. See core.Linker.Fsck() for
+// what is checked on the metadata. Additionally the pin cache is checked.
+//
+// If `deep` is true, the content of every file in every commit is read
+// from the backend, decrypted and checked against its content hash.
+// Also the pins of the backend are compared against the pin cache.
+// This can take a long time, since all content needs to be fetched.
+//
+// If `repair` is true, all derivable indexes are rebuilt and invalid
+// entries are removed. Missing or corrupt data can not be repaired.
+func (fs *FS) Fsck(deep, repair bool) ([]FsckProblem, error) {
+	problems, files, err := fs.fsckMetadata(deep, repair)
+	if err != nil || !deep {
+		return problems, err
+	}
+
+	// Reading all content takes long, so it is done without the lock.
+	// The files were loaded just for us and are not shared with anyone.
+	for _, file := range files {
+		if problem := fs.checkContent(file); problem != nil {
+			problems = append(problems, *problem)
+		}
+	}
+
+	return problems, nil
+}
+
+// fsckMetadata does the part of Fsck() that needs the lock. It returns
+// the problems found so far and the files whose content needs checking.
+func (fs *FS) fsckMetadata(deep, repair bool) ([]FsckProblem, []*n.File, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if repair && fs.readOnly {
+		return nil, nil, ErrReadOnly
+	}
+
+	// Only read each content once, even if it is used by many files:
+	files := []*n.File{}
+	seenContent := make(map[string]bool)
+	onFile := func(file *n.File) {
+		b58Hash := file.BackendHash().B58String()
+		if !seenContent[b58Hash] {
+			seenContent[b58Hash] = true
+			files = append(files, file)
+		}
+	}
+
+	coreProblems, err := fs.lkr.Fsck(repair, onFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	problems := []FsckProblem{}
+	for _, problem := range coreProblems {
+		problems = append(problems, FsckProblem{
+			Kind:     problem.Kind,
+			Path:     problem.Path,
+			Hash:     problem.Hash,
+			Detail:   problem.Detail,
+			Repaired: problem.Repaired,
+		})
+	}
+
+	pinProblems, err := fs.pinner.checkPins(deep, repair)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return append(problems, pinProblems...), files, nil
+}
@@ -0,0 +1,104 @@
+package catfs
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/sahib/brig/catfs/mio"
+	h "github.com/sahib/brig/util/hashlib"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFsck(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte{1, 2, 3})))
+		require.Nil(t, fs.Stage("/sub/y", bytes.NewReader([]byte{4, 5, 6})))
+		require.Nil(t, fs.MakeCommit("add"))
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte{7, 8, 9})))
+
+		problems, err := fs.Fsck(true, false)
+		require.Nil(t, err)
+		require.Empty(t, problems)
+
+		bk := fs.queue.bk.(*MemFsBackend)
+		x, err := fs.Stat("/x")
+		require.Nil(t, err)
+		y, err := fs.Stat("/sub/y")
+		require.Nil(t, err)
+
+		// Corrupt the content of y, forget the backend pin of x
+		// and remember a pin for a node that does not exist:
+		bk.data[y.BackendHash.B58String()][0] ^= 0xFF
+		bk.pins[x.BackendHash.B58String()] = false
+		require.Nil(t, fs.pinner.remember(12345, x.BackendHash, true, false))
+
+		// Without deep, only the pin cache entry is found:
+		problems, err = fs.Fsck(false, false)
+		require.Nil(t, err)
+		require.Len(t, problems, 1)
+		require.Equal(t, FsckBadPinEntry, problems[0].Kind)
+
+		problems, err = fs.Fsck(true, true)
+		require.Nil(t, err)
+		require.Len(t, problems, 3)
+
+		require.Equal(t, FsckBadPinEntry, problems[0].Kind)
+		require.True(t, problems[0].Repaired)
+		require.Equal(t, FsckBadPinEntry, problems[1].Kind)
+		require.True(t, problems[1].Repaired)
+		require.Equal(t, FsckBadContent, problems[2].Kind)
+		require.Equal(t, "/sub/y", problems[2].Path)
+		require.False(t, problems[2].Repaired)
+
+		require.True(t, bk.pins[x.BackendHash.B58String()])
+
+		// Missing content is reported too:
+		delete(bk.data, x.BackendHash.B58String())
+		problems, err = fs.Fsck(true, false)
+		require.Nil(t, err)
+		require.Len(t, problems, 2)
+		require.Equal(t, FsckBadContent, problems[0].Kind)
+		require.Equal(t, FsckMissingContent, problems[1].Kind)
+		require.Equal(t, "/x", problems[1].Path)
+	})
+}
+
+// lockCheckingBackend counts how often content is read while `mu` is held.
+type lockCheckingBackend struct {
+	*MemFsBackend
+
+	mu           *sync.Mutex
+	catsWithLock int
+}
+
+func (lb *lockCheckingBackend) Cat(hash h.Hash) (mio.Stream, error) {
+	if lb.mu != nil {
+		if lb.mu.TryLock() {
+			lb.mu.Unlock()
+		} else {
+			lb.catsWithLock++
+		}
+	}
+
+	return lb.MemFsBackend.Cat(hash)
+}
+
+func TestFsckDeepWithoutLock(t *testing.T) {
+	t.Parallel()
+
+	backend := &lockCheckingBackend{MemFsBackend: NewMemFsBackend()}
+	withDummyFSBackend(t, backend, false, func(fs *FS) {
+		require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte{1, 2, 3})))
+		require.Nil(t, fs.MakeCommit("add"))
+
+		// Reading all content takes long; it should not block everything else:
+		backend.mu = &fs.mu
+		problems, err := fs.Fsck(true, false)
+		require.Nil(t, err)
+		require.Empty(t, problems)
+		require.Equal(t, 0, backend.catsWithLock)
+	})
+}
@@ -0,0 +1,108 @@
+package catfs
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/sahib/brig/catfs/db"
+	n "github.com/sahib/brig/catfs/nodes"
+	h "github.com/sahib/brig/util/hashlib"
+)
+
+// newFileKey returns the key for a new file with the given content.
+func newFileKey(scheme n.KeyScheme, content h.Hash, size uint64) ([]byte, error) {
+	if scheme != n.KeySchemeRandom {
+		return deriveKeyFromContent(content, size), nil
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (fs *FS) keySchemes() (map[string]n.KeyScheme, error) {
+	schemes := make(map[string]n.KeyScheme)
+	data, err := fs.lkr.MetadataGet("fs.key_schemes")
+	if err == db.ErrNoSuchKey {
+		return schemes, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &schemes); err != nil {
+		return nil, err
+	}
+
+	return schemes, nil
+}
+
+// keySchemeFor returns the scheme new files at `nodePath` should use.
+// The setting of the deepest folder above `nodePath` wins.
+func (fs *FS) keySchemeFor(nodePath string) (n.KeyScheme, error) {
+	schemes, err := fs.keySchemes()
+	if err != nil {
+		return "", err
+	}
+
+	scheme, depth := n.KeySchemeConvergent, -1
+	for folder, folderScheme := range schemes {
+		if isBelowFolder(nodePath, folder) && len(folder) > depth {
+			scheme, depth = folderScheme, len(folder)
+		}
+	}
+
+	return scheme, nil
+}
+
+// KeySchemes returns a mapping of folders to the key scheme
+// that new files in them use.
+func (fs *FS) KeySchemes() (map[string]n.KeyScheme, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.keySchemes()
+}
+
+// SetKeyScheme sets how the keys of new files below the folder `root`
+// are chosen. Settings of sub folders take precedence. An empty `scheme`
+// removes the setting, so the scheme of the parent folders is used again.
+// Existing files keep their key until their content changes.
+// The folder does not need to exist yet.
+func (fs *FS) SetKeyScheme(root string, scheme n.KeyScheme) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.readOnly {
+		return ErrReadOnly
+	}
+
+	if scheme != "" && !scheme.IsValid() {
+		return fmt.Errorf("invalid key scheme: %s", scheme)
+	}
+
+	schemes, err := fs.keySchemes()
+	if err != nil {
+		return err
+	}
+
+	root = prefixSlash(path.Clean(root))
+	if scheme == "" {
+		delete(schemes, root)
+	} else {
+		schemes[root] = scheme
+	}
+
+	data, err := json.Marshal(schemes)
+	if err != nil {
+		return err
+	}
+
+	return fs.lkr.MetadataPut("fs.key_schemes", data)
+}
@@ -0,0 +1,128 @@
+package catfs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	n "github.com/sahib/brig/catfs/nodes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStageKeyScheme(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		data := []byte{1, 2, 3}
+		require.Nil(t, fs.Stage("/old", bytes.NewReader(data)))
+
+		require.Nil(t, fs.SetKeyScheme("/", n.KeySchemeRandom))
+		require.Nil(t, fs.SetKeyScheme("/public", n.KeySchemeConvergent))
+		require.NotNil(t, fs.SetKeyScheme("/x", n.KeyScheme("rot13")))
+
+		schemes, err := fs.KeySchemes()
+		require.Nil(t, err)
+		require.Equal(t, map[string]n.KeyScheme{
+			"/":       n.KeySchemeRandom,
+			"/public": n.KeySchemeConvergent,
+		}, schemes)
+
+		require.Nil(t, fs.Stage("/a", bytes.NewReader(data)))
+		require.Nil(t, fs.Stage("/b", bytes.NewReader(data)))
+		require.Nil(t, fs.Stage("/public/a", bytes.NewReader(data)))
+		require.Nil(t, fs.Stage("/public/b", bytes.NewReader(data)))
+
+		infoOld, err := fs.Stat("/old")
+		require.Nil(t, err)
+		require.Equal(t, "convergent", infoOld.KeyScheme)
+
+		infoA, err := fs.Stat("/a")
+		require.Nil(t, err)
+		require.Equal(t, "random", infoA.KeyScheme)
+
+		infoB, err := fs.Stat("/b")
+		require.Nil(t, err)
+		require.Equal(t, "random", infoB.KeyScheme)
+
+		// Files with random keys are not deduplicated:
+		require.False(t, infoA.BackendHash.Equal(infoB.BackendHash))
+		require.False(t, infoA.BackendHash.Equal(infoOld.BackendHash))
+
+		infoPublic, err := fs.Stat("/public/a")
+		require.Nil(t, err)
+		require.Equal(t, "convergent", infoPublic.KeyScheme)
+		require.True(t, infoPublic.BackendHash.Equal(infoOld.BackendHash))
+
+		stream, err := fs.Cat("/a")
+		require.Nil(t, err)
+
+		catData, err := ioutil.ReadAll(stream)
+		require.Nil(t, err)
+		require.Equal(t, data, catData)
+		require.Nil(t, stream.Close())
+
+		// Files made before the setting switch on their next change:
+		require.Nil(t, fs.Stage("/old", bytes.NewReader([]byte{4, 5, 6})))
+		infoOld, err = fs.Stat("/old")
+		require.Nil(t, err)
+		require.Equal(t, "random", infoOld.KeyScheme)
+
+		require.Nil(t, fs.SetKeyScheme("/", ""))
+		schemes, err = fs.KeySchemes()
+		require.Nil(t, err)
+		require.Len(t, schemes, 1)
+
+		// ...but keep their random key afterwards:
+		require.Nil(t, fs.Stage("/old", bytes.NewReader([]byte{7, 8, 9})))
+		infoOld, err = fs.Stat("/old")
+		require.Nil(t, err)
+		require.Equal(t, "random", infoOld.KeyScheme)
+	})
+}
+
+func TestStageRandomKeyChanges(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.SetKeyScheme("/hr", n.KeySchemeRandom))
+
+		keys := [][]byte{}
+		for idx := 0; idx < 3; idx++ {
+			require.Nil(t, fs.Stage("/hr/x", bytes.NewReader([]byte{byte(idx)})))
+
+			file, err := fs.lkr.LookupFile("/hr/x")
+			require.Nil(t, err)
+			require.Equal(t, n.KeySchemeRandom, file.KeyScheme())
+
+			// Every version needs its own key:
+			for _, key := range keys {
+				require.NotEqual(t, key, file.Key())
+			}
+
+			keys = append(keys, file.Key())
+		}
+	})
+}
+
+func TestSyncKeyScheme(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fsa *FS) {
+		require.Nil(t, fsa.MakeCommit("hello a"))
+		withDummyFS(t, func(fsb *FS) {
+			require.Nil(t, fsb.SetKeyScheme("/hr", n.KeySchemeRandom))
+			require.Nil(t, fsb.Stage("/hr/x", bytes.NewReader([]byte{1})))
+			require.Nil(t, fsb.MakeCommit("hello b"))
+
+			require.Nil(t, fsa.Sync(fsb))
+
+			infoA, err := fsa.Stat("/hr/x")
+			require.Nil(t, err)
+			require.Equal(t, "random", infoA.KeyScheme)
+
+			infoB, err := fsb.Stat("/hr/x")
+			require.Nil(t, err)
+			require.True(t, infoA.BackendHash.Equal(infoB.BackendHash))
+		})
+	})
+}
@@ -336,3 +336,32 @@ func ContentHash(nd Node) (h.Hash, error) {
 
 	return nil, ie.ErrBadNode
 }
+
+// ExpectedTreeHash recalculates the tree hash of `nd` from its attributes.
+// If it differs from nd.TreeHash(), the node was corrupted somehow.
+func ExpectedTreeHash(nd Node) (h.Hash, error) {
+	switch typedNd := nd.(type) {
+	case *Directory:
+		emptyRootHash := h.Sum([]byte(""))
+		if typedNd.parentName == "" && len(typedNd.children) == 0 && typedNd.tree.Equal(emptyRootHash) {
+			// A new root directory was not hashed with its path yet.
+			return emptyRootHash, nil
+		}
+
+		return typedNd.computeTreeHash(), nil
+	case *File:
+		return typedNd.computeTreeHash(typedNd.Path()), nil
+	case *Commit:
+		if !typedNd.IsBoxed() {
+			// The staging commit has no hash yet.
+			return nil, nil
+		}
+
+		return typedNd.computeTreeHash(), nil
+	case *Ghost:
+		// The hash of a ghost is always derived from the old node.
+		return typedNd.TreeHash(), nil
+	}
+
+	return nil, ie.ErrBadNode
+}
@@ -17,12 +17,12 @@ type Commit_merge Commit
 const Commit_TypeID = 0x8da013c66e545daf
 
 func NewCommit(s *capnp.Segment) (Commit, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 6})
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 7})
 	return Commit{st}, err
 }
 
 func NewRootCommit(s *capnp.Segment) (Commit, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 6})
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 7})
 	return Commit{st}, err
 }
 
@@ -145,12 +145,26 @@ func (s Commit_merge) SetHead(v []byte) error {
 	return s.Struct.SetData(5, v)
 }
 
+func (s Commit) Signature() ([]byte, error) {
+	p, err := s.Struct.Ptr(6)
+	return []byte(p.Data()), err
+}
+
+func (s Commit) HasSignature() bool {
+	p, err := s.Struct.Ptr(6)
+	return p.IsValid() || err != nil
+}
+
+func (s Commit) SetSignature(v []byte) error {
+	return s.Struct.SetData(6, v)
+}
+
 // Commit_List is a list of Commit.
 type Commit_List struct{ capnp.List }
 
 // NewCommit creates a new list of Commit.
 func NewCommit_List(s *capnp.Segment, sz int32) (Commit_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 8, PointerCount: 6}, sz)
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 8, PointerCount: 7}, sz)
 	return Commit_List{l}, err
 }
 
@@ -402,12 +416,12 @@ type File struct{ capnp.Struct }
 const File_TypeID = 0x8ea7393d37893155
 
 func NewFile(s *capnp.Segment) (File, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 2})
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 2})
 	return File{st}, err
 }
 
 func NewRootFile(s *capnp.Segment) (File, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 2})
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 2})
 	return File{st}, err
 }
 
@@ -462,12 +476,20 @@ func (s File) SetKey(v []byte) error {
 	return s.Struct.SetData(1, v)
 }
 
+func (s File) RandomKey() bool {
+	return s.Struct.Bit(64)
+}
+
+func (s File) SetRandomKey(v bool) {
+	s.Struct.SetBit(64, v)
+}
+
 // File_List is a list of File.
 type File_List struct{ capnp.List }
 
 // NewFile creates a new list of File.
 func NewFile_List(s *capnp.Segment, sz int32) (File_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 8, PointerCount: 2}, sz)
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 16, PointerCount: 2}, sz)
 	return File_List{l}, err
 }
 
@@ -1036,87 +1058,88 @@ func (p Node_Promise) Ghost() Ghost_Promise {
 	return Ghost_Promise{Pipeline: p.Pipeline.GetPipeline(5)}
 }
 
-const schema_9195d073cb5c5953 = "x\xda\xb4V_l\x14\xd5\x17>\xdf\xbd3;,)" +
-	"\xbf\xdd\xfdMI\x08\xf9\x95\xbd!\x90_!\x15Z\x8a" +
-	"\x916\x18(\xb4\xd2b!\xbdl\x89@\xd08\xec\xde" +
-	"\xeeL\xd8\x9d)3\x83\xb5\xc6\x064\x98\xa0\x06\x03\x11" +
-	"\x13IJ\x04S\xff%\x1a|\xf1\xd1\x98\x98h\x8c\xbe" +
-	"\x18\x1f4\xf1QM4\x9a\xf8,F\x18s\xf7oi" +
-	"*\xf0\xe2[\xf7;\xe7\xde\xfb\x9d\xef|\xe7L{9" +
-	"\xdf\xcd\xfa\xcc\xff\x1bD\xb2\xd7L%\xbf\xfew\xfe\x97" +
-	"\xef\xba\xbf8Kr\x1dXR8z\xfc\xab\xe8\xeb\xd7" +
-	".\xd1\x08\xb38\x8c\xfe\x9bX\x0f;\xcd,;\xcd\xf2" +
-	"\xfd#,\x0fBr5\xff\xe8\xccS\xbf\xaf~\x99r" +
-	"\xeb\xd0>`2\x8b\xa8\xdf\xe1\x83\xb0Oq\xcb>\xc5" +
-	"\xf3\xf6U>CHn<>\xe9\x7fn_\xbb\xa0\x1f" +
-	"X\x9c\x9f\xd2\xf97\xf9f\xd8i\xc3\xb2\xd3F\xbe\x7f" +
-	"\xc0xL\xdf\x7f\xb8\xef\xc5\x87\x1e\x1ex\xe7\x95\xa5\x07" +
-	"j\x0fx\xe6Z\xd8\xb3\xa6e\xcf\x9ay\xfb\xbay\x83" +
-	"\x90\xfc\xf8\xe7\xd4\xf4\x99\xdf6\xbd\xbd\xb4\x02\xcb2`" +
-	"\xf4\x0f\xa4\xd6\xc2\x1eKY\xf6X*\xdf?\x97\x0a\x18" +
-	"!Y\xf8i\xfc\xfb\xcc\xc2\x1f\x9f\x90\xdc\x88E\x04W" +
-	"\xa7,\x10\xf5\x9b\xe9c \xd8\xb9\xb4f\x8f\xf9\xe7+" +
-	"\xbdG\xc7\x7fXJ\x86k2\xd5\xf4\x1e\xd8si\xcb" +
-	"\x9eK\xe7\xed\x8f\xd2?\xd3\x8e\xa4\xe8\xc4S\xd1V?" +
-	"\xe0%\x15m-:\xd3\xfe\xf4V?(\xa9hK\xed" +
-	"\xef\xc1}\xae\x15D\xf1\x04 \x0d\xb0\xe4\x89W\xdf\x90" +
-	"\x1f\x7f\xfb\xd2g$\x0d\x86\xa1\x1e\xa0\x83\xa8\x0f\xdf " +
-	"\xd9\xe7\x06Q,<?U\xf2\x8aN\xac\"\x11\xbbN" +
-	",\x1cQTa\xecx\xbe\xd0W\x8a\x19'\x12N," +
-	"b\xd7\x8b\xc4\xb4\x13\xbb\"\xf0\x8bPD\xb2\x93\x1bD" +
-	"\x06\x88rs\xc7\x88\xe4\xb3\x1c\xf2<\x03\xd0\x09\x8d\xbd" +
-	"p\x88H\x9e\xe3\x90\x17\x19\xbaX\x92\xa0\x13\x8c(w" +
-	"a\x90H\x9e\xe7\x90\x97\x19\xba\xf8m\x0ds\xa2\xdc%" +
-	"\x9d}\x91C\xce3t\x19\xb74l\x10\xe5\xael&" +
-	"\x92\x979\xe45\x86\xa4\xac\xd9\x8e\xf9\x01\xf1\x92B\x9a" +
-	"\x18\xd2\xd4\x00'\x9c\x98\xe0\xa2\x83\x18:\x08\xbb\x8aA" +
-	"\xb5\xea\xc5\xc8\xb6%' KHJ^\xa8\x8aq\x10" +
-	"\x12f\x91mk^\x8ff\xa6\xbc\x8aB\xb6\xed\x8b\xc6" +
-	"\xa1{H=\xec\xed\x0aG\xfc8\x9c]^\xed\xff\xd5" +
-	"\xd4\xce\xe1\xcbdHD\x9e_\xae(&\x9a4f\x85" +
-	"\xd2\x07\x09rEK\xcaM\xba\xe2\x0d\x1c\xb2\x97!\xd7" +
-	"\xd4\xf2\x01\x0dvs\xc8\xed\x0c\x19\xdf\xa9\xaaf\xa9\x19" +
-	"\xd7\x89\\\xac\"\x86U\xf7f\xba7\xc8h]\x96\xe7" +
-	")\x1a\xaeX\x8fdoM>\xe1\xf1H8\"R\xb1" +
-	"\x08\xa6D\xd1u\xfc\xb26H \xfc\xc0*\xa9\x88H" +
-	"\xaei\x91\xbe\xb2\xa7\xdd\xa6\x16\xe9\xab\xba\xd3\xafs\xc8" +
-	"\x05\x86\x1cc\xf5\xf6_\xd7\xe0<\x87|\x97!\xc7y" +
-	"\xbd\xf9o\xe9\xf2\xaeq\xc8\xf7\x19`\xd4;\xff\xde6" +
-	"\"\xb9\xc0!?d\x80\x89E\xc3\x94\xfb`\x1b\xb13" +
-	"U\x15EN\xb9%\xc4.\xe7t\xec\x06a\xeb\xe7\xb4" +
-	"\x13*?n*\x93\x09\x83\xa0\xf5#\xef\xf9%\xf54" +
-	"Lb0\x09\xf9\xaa\x0a\xcb\xea^\xd2=\xe2\xf1\x8aZ" +
-	"^\xb85\x8d\x06\x7f\x9a\x0c\x89\x8ar\xa6\x84\xcf\xf4\xd4" +
-	"x\xbe\x88]%\x0e\x0c\x0f\xed#\"\xd9\xd1\xd2jD" +
-	"\x17\xbb\x9bC\x8e\xb7geL\xab2\xcc!'\xb4T" +
-	"\x8dI9\xb0\x9eH\x8er\xc8I\x86L\xe4=\xd3\xf2" +
-	"|\xb3\xb8F\xad\xd6I5{\xbf\x168\xa8\x03\xcb\xd7" +
-	"\xb1\xa1a\x80\xfdH\x0e\xd6\x0a\x88\x84\xe1\xd47@\xa3" +
-	"\x96\xaa\x0aOV\x94(9e\xed\x88\x13\xa1W&\xc8" +
-	"\x9efa\xf6Fl&*\x08p\x14z\xd0\xf6\x81\xbd" +
-	"\x09\xfb\x89\x0a\xdd\x1a\xdf\x8e\xb6\x15\xec>\xec!*\xf4" +
-	"h|\x07\x18P7\x83\xfd \xb6\x11\x15z5\xbcS" +
-	"\xa7\x1b\xbcf\x08{\x00'\x88\x0a;4>\xacq\xd3" +
-	"\xe8\x84Id\x0f\xd5\x9e\xdd\xa9\xf1Q0t\xa5\x92\xc4" +
-	"\xecD\x8a\xc8\x1e\xc1 Qa\xb7\x8e\x8c\xeb\x88u[" +
-	"G,\"{\x0c\x87\x88\x0a\xa3:2\xa9#+n\xe9" +
-	"\xc8\x0a\"[\xd6n\x1b\xd7\x91#:\x92\xfeKG\xd2" +
-	"D\xf6\xe1\x1a\xaf\x09\x1d9\xae\xdf_\x99\xea\xc4J\"" +
-	"\xfbh\x8d\xd7\x11\x8d\x97\xb0d<\x938Tj\xd4\x89" +
-	"\\\"j\xb6\xe8L5(Mz\xed\x9c\xbc\xa75n" +
-	"\xed\xb3b\xe0\xc7\xca\x8fG\xc9Z4\xd9\x99\xd3\x91\x0a" +
-	"\xff\x9d\xf5\x96\xaf-Pd\xdb\x1f\xe8\xc6e'\x9c\xe2" +
-	"I\xe5\x97\xee$\xd2\xf2\x97\xf1O+FS\xdbRU" +
-	"!/+\xbd\xd5\xb2\xf5.-Yk\xf5\x06\xdd\xb9\xd6" +
-	"f\xbc\xd8m\xaf5\xe5\x94\xee\xf7\xcd\xe1\xe66\xa5\xe5" +
-	"\x8d\xdd\xdd0\xf6\x9bH\x9a\xa9\xe6\xac\xd0:;\x9e\x1f" +
-	"\x89\xc0W\"\x08E5\x08Uk1{*\xd2\xd8\x94" +
-	"gUj\x9b.\xdb\x9a^GS>\xce!\xdd\xf6\xf4" +
-	"*=\xbdOr\xc8\xca\xa2\xe9\xf5\xf6\x13I\x97C\x9e" +
-	"\xd3\x8b\x8e\xd5\x17\xdds\x1a<[\xff\xca\xddm\xa4\x93" +
-	"\xa2\xebUJ\xa1\xf2\xb5o\xfeC\x98\xe0@\xb6\xfd\x0f" +
-	"\x11A\x83M\xabDwK\xfa;\x00\x00\xff\xffL\xce" +
-	"@$"
+const schema_9195d073cb5c5953 = "x\xda\xb4Vo\x88\x14e\x18\x7f~\xef;\xbb\xe3\x8a" +
+	"\xba\xbb\xcd\x09!\x9e\xfbbJ\xa7\\z\xe7)\xe9a" +
+	"\xe8\xe9]\x9ez\xc9\xbd\xae\x82\x8aE\xe3\xee{;\x83" +
+	"\xbb3\xe7\xcc\xd8yQh\xa1`\x85\xa5d\x1f\x02%" +
+	"\x09)\xfaTAA\x07J\x16\x19Z}\x88>\x14\xf4" +
+	"\xad?`\x14\xf59\xa3s\xe2\xdd\xbf\xe7q\xa9\x04}" +
+	"\xdb\xf9=\xcf\xbc\xef\xef\xf9=\xbf\xe7\x99\xedz\x80o" +
+	"d\xdd\x89\x07\x0d\"\xd9\x95H\xc6\xbf\xdew\xee\x97\xef" +
+	":\xae\x1d#\xb9\x08,\xce\xef\xdd\xffe\xf8\xf5kg" +
+	"h\x80\x99\x1cF\xcfM,\x86\x95b\xa6\x95b\xb9\x9e" +
+	"\x01\x96\x03!>\x9f\xdb>\xf6\xd4\x1f\xf3_\xa2\xec\"" +
+	"\xb4^H0\x93\xa8\xc7\xe6\xbd\xb0\x0eq\xd3:\xc4s" +
+	"\xd6y>F\x88\xdf}|\x97\xf7\xb9u\xe1\x94\xbe`" +
+	"j\xbe\xa9\xf3o\xf2\xe5\xb0R\x86i\xa5\x8c\\\xcf:" +
+	"\xe3\x15}\xfe\xee\xee\x17\x1e~d\xdd\xdb/OcT" +
+	"\xbb\xe0\x9d\xc4\x02X\x13\x09\xd3\x9aH\xe4\xac\x1f\x127" +
+	"\x08\xf1O\x7f\x8d\x8c\x1e\xfdm\xd9[\xd3+0M\x03" +
+	"F\xcfDr\x01\xac\xebI\xd3\xba\x9e\xcc\xf5L&}" +
+	"F\x88/\xfe<\xf4}\xfa\xe2\x9f\x1f\x93\\\x8a)\x04" +
+	"\xe7\x9b&\x88zN\xa4\xf6\x81`\x9dIi\xf68\xf7" +
+	"|\xb9k\xef\xd0\x8f\xd3\xd9sM\xe6\xf7\xd4&X\x93" +
+	")\xd3\x9aL\xe5\xac5\xb3o\xd0\xda\xb8`G#\xe1" +
+	"J\xcf\xe7E\x15\xae,\xd8\xa3\xde\xe8J\xcf/\xaap" +
+	"E\xf5w\xef\x16\xc7\xf4\xc3h\x18\x90\x06X\xfc\xc4\xab" +
+	"o\xc8\xcb\xdf\xbex\x95\xa4\xc1\xd0\xd7\x09\xcc!\xea\xc6" +
+	"7\x88\xb78~\x18\x09\xd7K\x16\xdd\x82\x1d\xa9PD" +
+	"\x8e\x1d\x09[\x14T\x10\xd9\xae'\xf4\x91b\xcc\x0e\x85" +
+	"\x1d\x89\xc8qC1jG\x8e\xf0\xbd\x02\x14\x91l\xe3" +
+	"\x06\x91\x01\xa2\xec\xb3\xfb\x88\xe43\x1c\xf2$\x03\xd0\x06" +
+	"\x8d\x9d\xd8I$\x8fs\xc8\xd3\x0c\xed,\x8e\xd1\x06F" +
+	"\x94=\xd5K$Or\xc8\xb3\x0c\xed\xfc\x96\x869Q" +
+	"\xf6\x8c\xce>\xcd!\xcf1\xb4\x1b\x93\x1a6\x88\xb2\xaf" +
+	"/'\x92g9\xe4\x05\x86\xb8\xa4\xd9n\xf5|\xe2E" +
+	"\x85\x141\xa4\xa8\x0e\x0e\xdb\x11\xc1\xc1\x1cb\x98C\xd8" +
+	"P\xf0+\x157B\xa6%9\x01\x19B\\t\x03U" +
+	"\x88\xfc\x800\x8eLK\xf3Z4=\xe2\x96\x152-" +
+	"_\xd4_\xba\x8b\xd4\xfd\xee\x86`\xc0\x8b\x82\xf1\x99\xd5" +
+	"^XU;\x8b/\xe2>\x11\xba^\xa9\xac\x98h\xd0" +
+	"\x18\x17J\xbfH\x90\xb3\x9aR.\xd3\x15/\xe1\x90]" +
+	"\x0c\xd9\x86\x96\x0fi\xb0\x83C\xaefH{vE5" +
+	"JM;v\xe8`.1\xcc\xbd;\xd3\xcd~Z\xeb" +
+	"23OQw\xc5b\xc4\x9b\xab\xf2\x09\x97\x87\xc2\x16" +
+	"\xa1\x8a\x84?\"\x0a\x8e\xed\x95\xb4A|\xe1\xf9fQ" +
+	"\x85Dra\x93\xf4\x87\x9b\x88\xe4{\x1c\xf2\xd2\x14\xd2" +
+	"\x13\xba\xd3\x1fp\xc8+\x0cY\xc6j\xed\xbf\xac\xc1\x8f" +
+	"8\xe4g\x0cY\xcek\xcd\xffD\x97w\x89C^c" +
+	"\x80Q\xeb\xfc\xd5UD\xf2\x0a\x87\xfc\x8a\x01\x09L\x19" +
+	"\xa6\xec\xf5U\xc4\xb2\xc9d\x1bL\xa2\xec\xfb;[W" +
+	"\x1f\xad\xa80\xb4KMu6\xd8\x87#\xc7\x0f\x9a\x8f" +
+	"\xa3v\xa0\xbc\xa8!W:\xf0\xfd\xe6C\xce\xf5\x8a\xea" +
+	"\x08\x12\xc4\x90 \xe4**(\xa98tK\x9e\x1d\x1d" +
+	"\x0e\x08\xea^5~\xd4\xe5e5\xb3\xc2\xf7\xd7\x9d\xf0" +
+	"i\xdc'\xca\xca\x1e\x11\x1e\xd3\xe3\xe5z\"r\x94x" +
+	"\xac\xbfo\x0b\x11\xc9LST[\xab\xb2\x9fC:\xad" +
+	"\xa1RZ\xbe'9dYkZ\x1f)w1\x91," +
+	"r\xc8Q\xad\xe9\xc6\x9a\xa6\x15\xadK\x99C\x1eaH" +
+	"\x87\xee\xd3\xcd\x89i\xa8P\x17\xc5<\xa8\xc6\x9b\xc5\x05" +
+	"\xb6W\xf4+\xdb\x95\x9e\x0f\x10\x03\xee^\xf0\x0e\x1d\x98" +
+	"\xb9\xe0%uKmC\xbc\xa3Zi(\x0c[xS" +
+	"\x8a\xae\xa8\xe0`Y\x89\xa2]\xd2\x1e;\x10\xb8%\x82" +
+	"\xecl(`-\xc5r\xa2\xbc\x00G\xbe\x13-gY" +
+	"\xcb\xb0\x8d(\xdf\xa1\xf1\xd5h\x99\xcb\xea\xc6&\xa2|" +
+	"\xa7\xc6\xd7\x82\x015{Yk\xb0\x8a(\xdf\xa5\xe1\xf5" +
+	":\xdd\xe0U\x8bY\xebp\x80(\xbfV\xe3\xfd\x1aO" +
+	"\x18mH\x10Y}\xd5k\xd7k|\x10\x0c\xed\xc98" +
+	"N\xb4!Id\x0d\xa0\x97(\xbfQG\x86t\xc4\xbc" +
+	"\xa5#&\x91\xb5\x15;\x89\xf2\x83:\xb2KGfM" +
+	"\xea\xc8,\"KVO\x1b\xd2\x91=:\x92\xfa[G" +
+	"RD\xd6\xee*\xafa\x1d\xd9\xaf\xef\x9f\x9dl\xc3l" +
+	"\"ko\x95\xd7\x1e\x8d\x171m\xe0\xe3(Pj\xd0" +
+	"\x0e\x1d\"j\xb4\xedh\xc5/\xeer[99Wk" +
+	"\xdc\xdc\x90\x05\xdf\x8b\x94\x17\x0d\x929eW\xa4\x0f\x87" +
+	"*\xf8\x7f\x16f\xae\xba\x92\x91i}\xf2\xeb\x87\x1d\xb0" +
+	"\x0b\x07\x95W\xbc\x9dH\xd3_\xc6\xbf--MmE" +
+	"E\x05\xbc\xa4\xf4\x9e\xcc\xd4\xba4mQ\xd6\x1at\xfb" +
+	"\xa2\x1cs#\xa7\xb5(\x95]\xbc\xd7;\xfb\x1b\xfb\x99" +
+	"f6vG\xdd\xd8o\"n\xa4&\xc6\x85\xd6\xd9v" +
+	"\xbdP\xf8\x9e\x12~ *~\xa0\x9a\xab\xdeU\xa1\xc6" +
+	"F\\\xb3\xac\xc2\xff8\xe6\xdb\x88\xa4\xc3!\x8f\xeb1" +
+	"g\xb51\x7fN\x83\xc7j\xdf\xcd;\x8dy\\p\xdc" +
+	"r1P\x1e\x11a\x1ea\x98\x03\x99\xd6_,\x02\xe6" +
+	"\xb5\xac\x12\xde)\xe9\x9f\x01\x00<cXI"
 
 func init() {
 	schemas.Register(schema_9195d073cb5c5953,
@@ -44,6 +44,10 @@ type Commit struct {
 		// the remote side.
 		head h.Hash
 	}
+
+	// signature is a detached signature of the tree hash,
+	// made with the key of the author. Nil if unsigned.
+	signature []byte
 }
 
 // NewEmptyCommit creates a new commit after the commit referenced by `parent`.
@@ -121,6 +125,10 @@ func (c *Commit) setCommitAttrs(seg *capnp.Segment) (*capnp_model.Commit, error)
 		return nil, err
 	}
 
+	if err := capCmt.SetSignature(c.signature); err != nil {
+		return nil, err
+	}
+
 	return &capCmt, nil
 }
 
@@ -182,6 +190,11 @@ func (c *Commit) readCommitAttrs(capCmt capnp_model.Commit) error {
 	}
 
 	c.merge.with, err = capMerge.With()
+	if err != nil {
+		return err
+	}
+
+	c.signature, err = capCmt.Signature()
 	return err
 }
 
@@ -220,7 +233,13 @@ func (c *Commit) BoxCommit(author string, message string) error {
 	}
 
 	c.author = author
+	c.message = message
+	c.tree = c.computeTreeHash()
+	return nil
+}
 
+// computeTreeHash calculates the hash of the commit from its attributes.
+func (c *Commit) computeTreeHash() h.Hash {
 	buf := &bytes.Buffer{}
 
 	// If parent == nil, this will be EmptyBackendHash.
@@ -233,12 +252,8 @@ func (c *Commit) BoxCommit(author string, message string) error {
 	buf.Write(padHash(h.Sum([]byte(c.author))))
 
 	// Write the message last, it may be arbitrary length.
-	buf.Write([]byte(message))
-
-	mh := h.Sum(buf.Bytes())
-	c.message = message
-	c.tree = h.Hash(mh)
-	return nil
+	buf.Write([]byte(c.message))
+	return h.Sum(buf.Bytes())
 }
 
 // String will return a nice representation of a commit.
@@ -262,6 +277,18 @@ func (c *Commit) MergeMarker() (string, h.Hash) {
 	return c.merge.with, c.merge.head
 }
 
+// Signature returns the detached signature of the commit hash
+// or nil if the commit was not signed.
+func (c *Commit) Signature() []byte {
+	return c.signature
+}
+
+// SetSignature sets the signature of the commit hash.
+// It is not part of the hash itself.
+func (c *Commit) SetSignature(sig []byte) {
+	c.signature = sig
+}
+
 // /////////////////// METADATA INTERFACE ///////////////////
 
 // Name will return the hash of the commit.
@@ -316,6 +343,12 @@ func (c *Commit) Parent(lkr Linker) (Node, error) {
 	return lkr.NodeByHash(c.parent)
 }
 
+// ParentHash returns the hash of the parent commit or nil
+// if it is the first commit ever made.
+func (c *Commit) ParentHash() h.Hash {
+	return c.parent
+}
+
 // SetParent sets the parent of the commit to `nd`.
 func (c *Commit) SetParent(lkr Linker, nd Node) error {
 	c.parent = nd.TreeHash().Clone()
@@ -24,6 +24,8 @@ func TestCommit(t *testing.T) {
 		t.Fatalf("Failed to box commit: %v", err)
 	}
 
+	cmt.SetSignature([]byte("signature"))
+
 	msg, err := cmt.ToCapnp()
 	if err != nil {
 		t.Fatalf("Failed to convert commit to capnp: %v", err)
@@ -73,6 +75,8 @@ func TestCommit(t *testing.T) {
 		t.Fatalf("Person from unmarshaled commit does not equal staging author: %v", person)
 	}
 
+	require.Equal(t, []byte("signature"), empty.Signature())
+
 	empty.modTime = cmt.modTime
 	require.Equal(t, empty, cmt)
 }
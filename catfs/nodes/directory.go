@@ -544,12 +544,31 @@ func (d *Directory) Copy(inode uint64) ModNode {
 	}
 }
 
+// computeTreeHash calculates the tree hash from the path and the children.
+func (d *Directory) computeTreeHash() h.Hash {
+	treeHash := h.Sum([]byte(path.Join(d.parentName, d.name)))
+	for _, name := range d.order {
+		treeHash = treeHash.Mix(d.children[name])
+	}
+
+	return treeHash
+}
+
+// ChildHashes returns a mapping of child names to their tree hashes.
+// The returned map is a copy and may be modified.
+func (d *Directory) ChildHashes() map[string]h.Hash {
+	children := make(map[string]h.Hash, len(d.children))
+	for name, hash := range d.children {
+		children[name] = hash.Clone()
+	}
+
+	return children
+}
+
 func (d *Directory) rehash(lkr Linker, updateContentHash bool) error {
-	newTreeHash := h.Sum([]byte(path.Join(d.parentName, d.name)))
+	newTreeHash := d.computeTreeHash()
 	newContentHash := h.EmptyInternalHash.Clone()
 	for _, name := range d.order {
-		newTreeHash = newTreeHash.Mix(d.children[name])
-
 		if childContent := d.contents[name]; updateContentHash && childContent != nil {
 			// The child content might be nil in case of ghost.
 			// Those should not add to the content calculation.
@@ -10,14 +10,32 @@ import (
 	capnp "zombiezen.com/go/capnproto2"
 )
 
+// KeyScheme describes how the key of a file was chosen.
+type KeyScheme string
+
+const (
+	// KeySchemeConvergent derives the key from the content. Files with
+	// the same content are encrypted the same way and are deduplicated.
+	KeySchemeConvergent = KeyScheme("convergent")
+	// KeySchemeRandom uses a random key for every file. This prevents
+	// confirming that a certain file is stored, but also deduplication.
+	KeySchemeRandom = KeyScheme("random")
+)
+
+// IsValid returns true if `ks` is a known key scheme.
+func (ks KeyScheme) IsValid() bool {
+	return ks == KeySchemeConvergent || ks == KeySchemeRandom
+}
+
 // File represents a single file in the repository.
 // It stores all metadata about it and links to the actual data.
 type File struct {
 	Base
 
-	size   uint64
-	parent string
-	key    []byte
+	size      uint64
+	parent    string
+	key       []byte
+	randomKey bool
 }
 
 // NewEmptyFile returns a newly created file under `parent`, named `name`.
@@ -78,6 +96,7 @@ func (f *File) setFileAttrs(seg *capnp.Segment) (*capnp_model.File, error) {
 	}
 
 	capFile.SetSize(f.size)
+	capFile.SetRandomKey(f.randomKey)
 	return &capFile, nil
 }
 
@@ -115,6 +134,7 @@ func (f *File) readFileAttrs(capFile capnp_model.File) error {
 
 	f.nodeType = NodeTypeFile
 	f.size = capFile.Size()
+	f.randomKey = capFile.RandomKey()
 	f.key, err = capFile.Key()
 	return err
 }
@@ -124,6 +144,15 @@ func (f *File) readFileAttrs(capFile capnp_model.File) error {
 // Size returns the number of bytes in the file's content.
 func (f *File) Size() uint64 { return f.size }
 
+// KeyScheme returns how the key of the file was chosen.
+func (f *File) KeyScheme() KeyScheme {
+	if f.randomKey {
+		return KeySchemeRandom
+	}
+
+	return KeySchemeConvergent
+}
+
 ////////////////// ATTRIBUTE SETTERS //////////////////
 
 // SetModTime udates the mod time of the file (i.e. "touch"es it)
@@ -137,6 +166,9 @@ func (f *File) SetName(n string) { f.name = n }
 // SetKey updates the key to a new value, taking ownership of the value.
 func (f *File) SetKey(k []byte) { f.key = k }
 
+// SetKeyScheme remembers how the key of the file was chosen.
+func (f *File) SetKeyScheme(ks KeyScheme) { f.randomKey = ks == KeySchemeRandom }
+
 // SetSize will update the size of the file and update it's mod time.
 func (f *File) SetSize(s uint64) {
 	f.size = s
@@ -156,15 +188,16 @@ func (f *File) Copy(inode uint64) ModNode {
 	}
 
 	return &File{
-		Base:   f.Base.copyBase(inode),
-		size:   f.size,
-		parent: f.parent,
-		key:    copyKey,
+		Base:      f.Base.copyBase(inode),
+		size:      f.size,
+		parent:    f.parent,
+		key:       copyKey,
+		randomKey: f.randomKey,
 	}
 }
 
-func (f *File) rehash(lkr Linker, newPath string) {
-	oldHash := f.tree.Clone()
+// computeTreeHash calculates the tree hash the file would have at `filePath`.
+func (f *File) computeTreeHash(filePath string) h.Hash {
 	var contentHash h.Hash
 	if f.Base.content != nil {
 		contentHash = f.Base.content.Clone()
@@ -172,7 +205,12 @@ func (f *File) rehash(lkr Linker, newPath string) {
 		contentHash = h.EmptyInternalHash.Clone()
 	}
 
-	f.tree = h.Sum([]byte(fmt.Sprintf("%s|%s", newPath, contentHash)))
+	return h.Sum([]byte(fmt.Sprintf("%s|%s", filePath, contentHash)))
+}
+
+func (f *File) rehash(lkr Linker, newPath string) {
+	oldHash := f.tree.Clone()
+	f.tree = f.computeTreeHash(newPath)
 	lkr.MemIndexSwap(f, oldHash, true)
 }
 
@@ -27,6 +27,7 @@ func TestFile(t *testing.T) {
 
 	file.SetName("new_name")
 	file.SetKey([]byte{1, 2, 3})
+	file.SetKeyScheme(KeySchemeRandom)
 	file.SetSize(42)
 	file.SetContent(lkr, []byte{4, 5, 6})
 	file.SetBackend(lkr, []byte{7, 8, 9})
@@ -71,6 +72,10 @@ func TestFile(t *testing.T) {
 		t.Fatalf("key differs after unmarshal: %v", empty.Key())
 	}
 
+	if empty.KeyScheme() != KeySchemeRandom {
+		t.Fatalf("key scheme differs after unmarshal: %v", empty.KeyScheme())
+	}
+
 	if !bytes.Equal(empty.TreeHash(), hashBeforeUnmarshal) {
 		t.Fatalf("tree hash differs after unmarshal: %v", empty.TreeHash())
 	}
@@ -2,6 +2,9 @@ package catfs
 
 import (
 	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
 
 	capnp "github.com/sahib/brig/catfs/capnp"
 	c "github.com/sahib/brig/catfs/core"
@@ -94,6 +97,11 @@ func pinEnryToCapnpData(entry *pinCacheEntry) ([]byte, error) {
 type Pinner struct {
 	bk  FsBackend
 	lkr *c.Linker
+
+	mu sync.Mutex
+
+	// throttle wraps content streams fetched by download(), if not nil.
+	throttle func(r io.Reader) io.Reader
 }
 
 // NewPinner creates a new pin cache at `pinDbPath`, possibly erroring out.
@@ -102,6 +110,58 @@ func NewPinner(lkr *c.Linker, bk FsBackend) (*Pinner, error) {
 	return &Pinner{lkr: lkr, bk: bk}, nil
 }
 
+// setThrottle sets the function that limits the bandwidth of download().
+func (pc *Pinner) setThrottle(throttle func(r io.Reader) io.Reader) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	pc.throttle = throttle
+}
+
+// download reads the content at `hash` from the backend, which will cache it.
+// The transfer is limited by the throttle, if any.
+func (pc *Pinner) download(hash h.Hash) error {
+	stream, err := pc.bk.Cat(hash)
+	if err != nil {
+		return err
+	}
+
+	defer stream.Close()
+
+	pc.mu.Lock()
+	throttle := pc.throttle
+	pc.mu.Unlock()
+
+	var r io.Reader = stream
+	if throttle != nil {
+		r = throttle(stream)
+	}
+
+	_, err = io.Copy(ioutil.Discard, r)
+	return err
+}
+
+// prefetch downloads the content at `hash` before pinning it if it is not
+// cached yet and a throttle is set. Otherwise the backend would fetch it
+// while pinning, without any limits. Callers that hold a lock should
+// call it before taking it, since the download might take a long while.
+func (pc *Pinner) prefetch(hash h.Hash) error {
+	pc.mu.Lock()
+	isThrottled := pc.throttle != nil
+	pc.mu.Unlock()
+
+	if !isThrottled {
+		return nil
+	}
+
+	isCached, err := pc.bk.IsCached(hash)
+	if err != nil || isCached {
+		return err
+	}
+
+	return pc.download(hash)
+}
+
 // Close the pinning cache.
 func (pc *Pinner) Close() error {
 	// currently a no-op
@@ -158,6 +218,25 @@ func (pc *Pinner) remember(inode uint64, hash h.Hash, isPinned, isExplicit bool)
 	})
 }
 
+// moveEntry transfers the cached pin state of `oldHash` to `newHash`.
+// This is used when the backend hash of a content changes.
+func (pc *Pinner) moveEntry(oldHash, newHash h.Hash) error {
+	return pc.lkr.AtomicWithBatch(func(batch db.Batch) (bool, error) {
+		data, err := pc.lkr.KV().Get("pins", oldHash.B58String())
+		if err == db.ErrNoSuchKey {
+			return false, nil
+		}
+
+		if err != nil {
+			return true, err
+		}
+
+		batch.Put(data, "pins", newHash.B58String())
+		batch.Erase("pins", oldHash.B58String())
+		return false, nil
+	})
+}
+
 // IsPinned returns two boolean values indicating the pin status of `inode` and
 // `hash`.  If the first value is true, the content is pinned, if the second is
 // true it is pinned explicitly.
@@ -210,6 +289,10 @@ func (pc *Pinner) Pin(inode uint64, hash h.Hash, explicit bool) error {
 			return nil
 		}
 	} else {
+		if err := pc.prefetch(hash); err != nil {
+			return err
+		}
+
 		if err := pc.bk.Pin(hash); err != nil {
 			return err
 		}
@@ -2,9 +2,12 @@ package catfs
 
 import (
 	"bytes"
+	"io"
+	"sync"
 	"testing"
 
 	c "github.com/sahib/brig/catfs/core"
+	"github.com/sahib/brig/catfs/mio"
 	h "github.com/sahib/brig/util/hashlib"
 	"github.com/stretchr/testify/require"
 )
@@ -132,3 +135,112 @@ func TestPinEmptyDir(t *testing.T) {
 		require.True(t, isExplicit)
 	})
 }
+
+type uncachedBackend struct {
+	*MemFsBackend
+}
+
+func (ub *uncachedBackend) IsCached(hash h.Hash) (bool, error) {
+	return false, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n *int
+}
+
+func (cr countingReader) Read(buf []byte) (int, error) {
+	n, err := cr.r.Read(buf)
+	*cr.n += n
+	return n, err
+}
+
+func TestPinThrottle(t *testing.T) {
+	c.WithDummyLinker(t, func(lkr *c.Linker) {
+		backend := &uncachedBackend{NewMemFsBackend()}
+		pinner, err := NewPinner(lkr, backend)
+		require.Nil(t, err)
+
+		data := []byte("hello world")
+		hash, err := backend.Add(bytes.NewReader(data))
+		require.Nil(t, err)
+
+		// Without throttle the backend fetches the content itself:
+		require.Nil(t, pinner.Pin(1, hash, true))
+		require.Nil(t, pinner.Unpin(1, hash, true))
+
+		// With throttle we fetch it through the throttle before:
+		read := 0
+		pinner.setThrottle(func(r io.Reader) io.Reader {
+			return countingReader{r: r, n: &read}
+		})
+
+		require.Nil(t, pinner.Pin(1, hash, true))
+		require.Equal(t, len(data), read)
+
+		isPinned, err := backend.IsPinned(hash)
+		require.Nil(t, err)
+		require.True(t, isPinned)
+	})
+}
+
+// lazyBackend only reports content as cached after it was read once.
+type lazyBackend struct {
+	*MemFsBackend
+
+	mu     sync.Mutex
+	cached map[string]bool
+}
+
+func (lb *lazyBackend) Cat(hash h.Hash) (mio.Stream, error) {
+	lb.mu.Lock()
+	lb.cached[hash.B58String()] = true
+	lb.mu.Unlock()
+
+	return lb.MemFsBackend.Cat(hash)
+}
+
+func (lb *lazyBackend) IsCached(hash h.Hash) (bool, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	return lb.cached[hash.B58String()], nil
+}
+
+func TestPinThrottleWithoutLock(t *testing.T) {
+	t.Parallel()
+
+	backend := &lazyBackend{
+		MemFsBackend: NewMemFsBackend(),
+		cached:       make(map[string]bool),
+	}
+
+	withDummyFSBackend(t, backend, false, func(fs *FS) {
+		fs.cfg.SetBool("pre_cache.enabled", false)
+		require.Nil(t, fs.Stage("/dir/x", bytes.NewReader([]byte("hello"))))
+		require.Nil(t, fs.MakeCommit("add x"))
+		require.Nil(t, fs.Unpin("/dir", "curr", true))
+
+		// The throttled download might take long,
+		// so it should not block everything else:
+		downloads, downloadsWithLock := 0, 0
+		fs.SetContentThrottle(func(r io.Reader) io.Reader {
+			downloads++
+			if fs.mu.TryLock() {
+				fs.mu.Unlock()
+			} else {
+				downloadsWithLock++
+			}
+
+			return r
+		})
+
+		require.Nil(t, fs.Pin("/dir", "curr", true))
+		require.Equal(t, 1, downloads)
+		require.Equal(t, 0, downloadsWithLock)
+
+		isPinned, _, err := fs.IsPinned("/dir/x")
+		require.Nil(t, err)
+		require.True(t, isPinned)
+	})
+}
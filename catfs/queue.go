@@ -0,0 +1,556 @@
+package catfs
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	multihash "github.com/multiformats/go-multihash"
+	c "github.com/sahib/brig/catfs/core"
+	ie "github.com/sahib/brig/catfs/errors"
+	"github.com/sahib/brig/catfs/mio"
+	n "github.com/sahib/brig/catfs/nodes"
+	h "github.com/sahib/brig/util/hashlib"
+	log "github.com/sirupsen/logrus"
+)
+
+// onlineChecker is implemented by backends that can tell
+// if they are currently able to store data.
+type onlineChecker interface {
+	IsOnline() bool
+}
+
+// WriteQueueStats tells how much content still waits for the backend.
+type WriteQueueStats struct {
+	// Length is the number of queued objects
+	Length int
+	// Bytes is the size of all queued objects
+	Bytes uint64
+	// Pins is the number of pin operations that wait for the backend
+	Pins int
+}
+
+type queueEntry struct {
+	Size   uint64 `json:"size"`
+	Pinned bool   `json:"pinned"`
+}
+
+// queueIndex is the on-disk state of the write queue.
+// All hashes are stored as base58 strings.
+type queueIndex struct {
+	// Entries maps a placeholder hash to the spooled object.
+	Entries map[string]*queueEntry `json:"entries"`
+	// Pins are pin (true) or unpin (false) operations on content
+	// that is already in the backend.
+	Pins map[string]bool `json:"pins"`
+	// Resolved maps placeholder hashes to the hash the backend
+	// gave the object once it was uploaded. Entries are removed
+	// once the staging area uses the backend hash.
+	Resolved map[string]string `json:"resolved"`
+}
+
+// writeQueue is a FsBackend that spools objects to a local directory
+// while the backend it wraps is offline or unreachable. Since brig only
+// adds streams that are encrypted already, the spooled data is encrypted
+// too. Queued objects get a placeholder hash, which is the hash the
+// backend would have given them if it was a plain content hash.
+// drain() uploads them once the backend is back.
+type writeQueue struct {
+	mu  sync.Mutex
+	bk  FsBackend
+	dir string
+	idx *queueIndex
+}
+
+func newWriteQueue(bk FsBackend, dir string) (*writeQueue, error) {
+	idx := &queueIndex{
+		Entries:  make(map[string]*queueEntry),
+		Pins:     make(map[string]bool),
+		Resolved: make(map[string]string),
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err == nil {
+		if err := json.Unmarshal(data, idx); err != nil {
+			return nil, err
+		}
+	}
+
+	return &writeQueue{bk: bk, dir: dir, idx: idx}, nil
+}
+
+func (wq *writeQueue) isOnline() bool {
+	checker, ok := wq.bk.(onlineChecker)
+	if !ok {
+		// Backends that can't tell are assumed to be always there.
+		return true
+	}
+
+	return checker.IsOnline()
+}
+
+// save writes the index to disk. wq.mu must be held.
+func (wq *writeQueue) save() error {
+	data, err := json.Marshal(wq.idx)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := filepath.Join(wq.dir, "index.json.tmp")
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, filepath.Join(wq.dir, "index.json"))
+}
+
+func (wq *writeQueue) spoolPath(b58Hash string) string {
+	return filepath.Join(wq.dir, b58Hash)
+}
+
+// resolve returns the backend hash of `hash` if it was queued before.
+func (wq *writeQueue) resolve(hash h.Hash) h.Hash {
+	wq.mu.Lock()
+	defer wq.mu.Unlock()
+
+	if real, ok := wq.idx.Resolved[hash.B58String()]; ok {
+		if realHash, err := h.FromB58String(real); err == nil {
+			return realHash
+		}
+	}
+
+	return hash
+}
+
+func (wq *writeQueue) spool(r io.Reader) (h.Hash, error) {
+	if err := os.MkdirAll(wq.dir, 0700); err != nil {
+		return nil, err
+	}
+
+	fd, err := ioutil.TempFile(wq.dir, "spool-")
+	if err != nil {
+		return nil, err
+	}
+
+	defer os.Remove(fd.Name())
+
+	hasher := sha256.New()
+	size, err := io.Copy(fd, io.TeeReader(r, hasher))
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	if err := fd.Close(); err != nil {
+		return nil, err
+	}
+
+	mh, err := multihash.Encode(hasher.Sum(nil), multihash.SHA2_256)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := h.Hash(mh)
+	b58Hash := hash.B58String()
+
+	wq.mu.Lock()
+	defer wq.mu.Unlock()
+
+	if err := os.Rename(fd.Name(), wq.spoolPath(b58Hash)); err != nil {
+		return nil, err
+	}
+
+	if _, ok := wq.idx.Entries[b58Hash]; !ok {
+		wq.idx.Entries[b58Hash] = &queueEntry{Size: uint64(size)}
+	}
+
+	log.Infof("write queue: backend is offline; queued %s (%d bytes)", hash.ShortB58(), size)
+	return hash, wq.save()
+}
+
+// hasPlaceholders returns true if there are placeholder hashes
+// that might still be used in the staging area.
+func (wq *writeQueue) hasPlaceholders() bool {
+	wq.mu.Lock()
+	defer wq.mu.Unlock()
+
+	return len(wq.idx.Entries) > 0 || len(wq.idx.Resolved) > 0
+}
+
+// isPlaceholder returns true if `hash` was made up by the queue
+// and is not (yet) replaced by the hash of the backend.
+func (wq *writeQueue) isPlaceholder(hash h.Hash) bool {
+	wq.mu.Lock()
+	defer wq.mu.Unlock()
+
+	b58Hash := hash.B58String()
+	_, isQueued := wq.idx.Entries[b58Hash]
+	_, isResolved := wq.idx.Resolved[b58Hash]
+	return isQueued || isResolved
+}
+
+// forget removes the placeholders in `resolved` after they
+// were replaced by their backend hash everywhere.
+func (wq *writeQueue) forget(resolved map[string]h.Hash) error {
+	wq.mu.Lock()
+	defer wq.mu.Unlock()
+
+	for placeholder := range resolved {
+		delete(wq.idx.Resolved, placeholder)
+	}
+
+	return wq.save()
+}
+
+// Add implements FsBackend.Add. If the backend is offline, `r` is spooled.
+func (wq *writeQueue) Add(r io.Reader) (h.Hash, error) {
+	if wq.isOnline() {
+		return wq.bk.Add(r)
+	}
+
+	return wq.spool(r)
+}
+
+// Cat implements FsBackend.Cat. Queued objects are read from the spool.
+func (wq *writeQueue) Cat(hash h.Hash) (mio.Stream, error) {
+	wq.mu.Lock()
+	_, isQueued := wq.idx.Entries[hash.B58String()]
+	wq.mu.Unlock()
+
+	if isQueued {
+		return os.Open(wq.spoolPath(hash.B58String()))
+	}
+
+	return wq.bk.Cat(wq.resolve(hash))
+}
+
+// doPinOp pins or unpins `hash`, remembering the operation if
+// the backend is not reachable right now.
+func (wq *writeQueue) doPinOp(hash h.Hash, pin bool) error {
+	b58Hash := hash.B58String()
+
+	wq.mu.Lock()
+	if entry, ok := wq.idx.Entries[b58Hash]; ok {
+		defer wq.mu.Unlock()
+		entry.Pinned = pin
+		return wq.save()
+	}
+	wq.mu.Unlock()
+
+	hash = wq.resolve(hash)
+	if wq.isOnline() {
+		if pin {
+			return wq.bk.Pin(hash)
+		}
+
+		return wq.bk.Unpin(hash)
+	}
+
+	wq.mu.Lock()
+	defer wq.mu.Unlock()
+
+	if err := os.MkdirAll(wq.dir, 0700); err != nil {
+		return err
+	}
+
+	wq.idx.Pins[hash.B58String()] = pin
+	return wq.save()
+}
+
+// Pin implements FsBackend.Pin
+func (wq *writeQueue) Pin(hash h.Hash) error {
+	return wq.doPinOp(hash, true)
+}
+
+// Unpin implements FsBackend.Unpin
+func (wq *writeQueue) Unpin(hash h.Hash) error {
+	return wq.doPinOp(hash, false)
+}
+
+// IsPinned implements FsBackend.IsPinned
+func (wq *writeQueue) IsPinned(hash h.Hash) (bool, error) {
+	hash = wq.resolve(hash)
+	b58Hash := hash.B58String()
+
+	wq.mu.Lock()
+	if entry, ok := wq.idx.Entries[b58Hash]; ok {
+		wq.mu.Unlock()
+		return entry.Pinned, nil
+	}
+
+	if pin, ok := wq.idx.Pins[b58Hash]; ok {
+		wq.mu.Unlock()
+		return pin, nil
+	}
+	wq.mu.Unlock()
+
+	return wq.bk.IsPinned(hash)
+}
+
+// IsCached implements FsBackend.IsCached. Queued objects are always cached.
+func (wq *writeQueue) IsCached(hash h.Hash) (bool, error) {
+	wq.mu.Lock()
+	_, isQueued := wq.idx.Entries[hash.B58String()]
+	wq.mu.Unlock()
+
+	if isQueued {
+		return true, nil
+	}
+
+	return wq.bk.IsCached(wq.resolve(hash))
+}
+
+// Stats returns the current size of the queue.
+func (wq *writeQueue) Stats() WriteQueueStats {
+	wq.mu.Lock()
+	defer wq.mu.Unlock()
+
+	stats := WriteQueueStats{
+		Length: len(wq.idx.Entries),
+		Pins:   len(wq.idx.Pins),
+	}
+
+	for _, entry := range wq.idx.Entries {
+		stats.Bytes += entry.Size
+	}
+
+	return stats
+}
+
+// drain uploads all queued objects and applies all waiting pin operations
+// if the backend is online. It returns a mapping of placeholder hashes to
+// backend hashes for all objects that were ever uploaded by the queue and
+// got a different hash, or nil if nothing was uploaded by this call.
+func (wq *writeQueue) drain() (map[string]h.Hash, error) {
+	if !wq.isOnline() {
+		return nil, nil
+	}
+
+	wq.mu.Lock()
+	queued := []string{}
+	for b58Hash := range wq.idx.Entries {
+		queued = append(queued, b58Hash)
+	}
+
+	pins := make(map[string]bool)
+	for b58Hash, pin := range wq.idx.Pins {
+		pins[b58Hash] = pin
+	}
+	wq.mu.Unlock()
+
+	for _, b58Hash := range queued {
+		fd, err := os.Open(wq.spoolPath(b58Hash))
+		if err != nil {
+			return nil, err
+		}
+
+		realHash, err := wq.bk.Add(fd)
+		fd.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		wq.mu.Lock()
+		entry := wq.idx.Entries[b58Hash]
+		delete(wq.idx.Entries, b58Hash)
+		if realHash.B58String() != b58Hash {
+			wq.idx.Resolved[b58Hash] = realHash.B58String()
+		}
+
+		err = wq.save()
+		wq.mu.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if entry != nil && entry.Pinned {
+			if err := wq.bk.Pin(realHash); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := os.Remove(wq.spoolPath(b58Hash)); err != nil {
+			log.Warningf("write queue: failed to remove spooled %s: %v", b58Hash, err)
+		}
+
+		log.Infof("write queue: uploaded %s as %s", b58Hash, realHash.B58String())
+	}
+
+	for b58Hash, pin := range pins {
+		hash, err := h.FromB58String(b58Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if pin {
+			err = wq.bk.Pin(hash)
+		} else {
+			err = wq.bk.Unpin(hash)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		wq.mu.Lock()
+		if wq.idx.Pins[b58Hash] == pin {
+			delete(wq.idx.Pins, b58Hash)
+		}
+
+		err = wq.save()
+		wq.mu.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(queued) == 0 {
+		return nil, nil
+	}
+
+	wq.mu.Lock()
+	defer wq.mu.Unlock()
+
+	resolved := make(map[string]h.Hash)
+	for placeholder, real := range wq.idx.Resolved {
+		realHash, err := h.FromB58String(real)
+		if err != nil {
+			return nil, err
+		}
+
+		resolved[placeholder] = realHash
+	}
+
+	return resolved, nil
+}
+
+////////////////////////
+// FS SPECIFIC PARTS  //
+////////////////////////
+
+// WriteQueueStats returns how much content is waiting to be uploaded
+// to the backend. Content is queued when the backend is offline.
+func (fs *FS) WriteQueueStats() WriteQueueStats {
+	return fs.queue.Stats()
+}
+
+// DrainWriteQueue uploads all queued content if the backend is online
+// and updates the staging area to use the new backend hashes.
+// This is done periodically in the background too. Until then,
+// the staging area can not be committed (see ErrPendingUploads).
+func (fs *FS) DrainWriteQueue() error {
+	resolved, err := fs.queue.drain()
+	if err != nil {
+		return err
+	}
+
+	if len(resolved) == 0 {
+		return nil
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	root, err := fs.lkr.Root()
+	if err != nil {
+		return err
+	}
+
+	// Collect first, staging modifies the tree we walk on.
+	files := []*n.File{}
+	err = n.Walk(fs.lkr, root, true, func(child n.Node) error {
+		if child.Type() != n.NodeTypeFile {
+			return nil
+		}
+
+		file, ok := child.(*n.File)
+		if !ok {
+			return nil
+		}
+
+		if _, ok := resolved[file.BackendHash().B58String()]; ok {
+			files = append(files, file)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		oldHash := file.BackendHash()
+		newHash := resolved[oldHash.B58String()]
+		if _, err := c.StageWithKeyScheme(fs.lkr, file.Path(), file.ContentHash(), newHash, file.Size(), file.Key(), file.KeyScheme()); err != nil {
+			return err
+		}
+
+		if err := fs.pinner.moveEntry(oldHash, newHash); err != nil {
+			return err
+		}
+	}
+
+	return fs.queue.forget(resolved)
+}
+
+// checkPlaceholders returns ErrPendingUploads if a staged file still uses
+// a placeholder hash of the write queue. Those may not end up in a commit,
+// since the backend (and therefore other remotes) does not know them.
+// fs.mu must be held.
+func (fs *FS) checkPlaceholders() error {
+	if !fs.queue.hasPlaceholders() {
+		return nil
+	}
+
+	root, err := fs.lkr.Root()
+	if err != nil {
+		return err
+	}
+
+	return n.Walk(fs.lkr, root, true, func(child n.Node) error {
+		file, ok := child.(*n.File)
+		if !ok {
+			return nil
+		}
+
+		if fs.queue.isPlaceholder(file.BackendHash()) {
+			return ie.ErrPendingUploads
+		}
+
+		return nil
+	})
+}
+
+func (fs *FS) queueLoop() {
+	if fs.readOnly {
+		return
+	}
+
+	drainTicker := time.NewTicker(5 * time.Second)
+	defer drainTicker.Stop()
+
+	for {
+		select {
+		case <-fs.queueControl:
+			log.Debugf("quitting the write queue loop")
+			return
+		case <-drainTicker.C:
+			if err := fs.DrainWriteQueue(); err != nil {
+				log.Warningf("failed to drain write queue: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package catfs
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	ie "github.com/sahib/brig/catfs/errors"
+	"github.com/sahib/brig/defaults"
+	h "github.com/sahib/brig/util/hashlib"
+	"github.com/sahib/config"
+	"github.com/stretchr/testify/require"
+)
+
+// switchableBackend can be taken offline and uses other
+// hashes than the write queue uses for its placeholders.
+type switchableBackend struct {
+	*MemFsBackend
+	online bool
+}
+
+func (sb *switchableBackend) IsOnline() bool {
+	return sb.online
+}
+
+func (sb *switchableBackend) Add(r io.Reader) (h.Hash, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := h.Sum(data)
+	sb.data[hash.B58String()] = data
+	return hash, nil
+}
+
+func TestWriteQueue(t *testing.T) {
+	t.Parallel()
+
+	dbPath, err := ioutil.TempDir("", "brig-fs-queue-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dbPath)
+
+	cfg, err := config.Open(nil, defaults.Defaults, config.StrictnessPanic)
+	require.Nil(t, err)
+
+	bk := &switchableBackend{MemFsBackend: NewMemFsBackend()}
+	fs, err := NewFilesystem(bk, dbPath, "alice", false, cfg.Section("fs"))
+	require.Nil(t, err)
+	defer fs.Close()
+
+	data := []byte("hello world")
+	require.Nil(t, fs.Stage("/x", bytes.NewReader(data)))
+
+	// The queue holds the encrypted stream, so it's a bit bigger:
+	stats := fs.WriteQueueStats()
+	require.Equal(t, 1, stats.Length)
+	require.True(t, stats.Bytes > uint64(len(data)))
+	require.Empty(t, bk.data)
+
+	// The placeholder hash may not end up in a commit,
+	// but the content is readable from the queue:
+	require.Equal(t, ie.ErrPendingUploads, fs.MakeCommit("offline commit"))
+	stream, err := fs.Cat("/x")
+	require.Nil(t, err)
+	readData, err := ioutil.ReadAll(stream)
+	require.Nil(t, err)
+	require.Equal(t, data, readData)
+	require.Nil(t, stream.Close())
+
+	placeholder, err := fs.Stat("/x")
+	require.Nil(t, err)
+
+	// Nothing happens while we're still offline:
+	require.Nil(t, fs.DrainWriteQueue())
+	require.Equal(t, 1, fs.WriteQueueStats().Length)
+
+	bk.online = true
+	require.Nil(t, fs.DrainWriteQueue())
+	require.Equal(t, WriteQueueStats{}, fs.WriteQueueStats())
+	require.Len(t, bk.data, 1)
+
+	// The staging area uses the new hash now:
+	info, err := fs.Stat("/x")
+	require.Nil(t, err)
+	require.False(t, info.BackendHash.Equal(placeholder.BackendHash))
+	require.True(t, bk.pins[info.BackendHash.B58String()])
+
+	isPinned, _, err := fs.IsPinned("/x")
+	require.Nil(t, err)
+	require.True(t, isPinned)
+
+	stream, err = fs.Cat("/x")
+	require.Nil(t, err)
+	readData, err = ioutil.ReadAll(stream)
+	require.Nil(t, err)
+	require.Equal(t, data, readData)
+	require.Nil(t, stream.Close())
+
+	// Pin operations are queued too while offline:
+	bk.online = false
+	require.Nil(t, fs.Unpin("/x", "curr", true))
+	require.Equal(t, 1, fs.WriteQueueStats().Pins)
+	require.True(t, bk.pins[info.BackendHash.B58String()])
+
+	bk.online = true
+	require.Nil(t, fs.DrainWriteQueue())
+	require.Equal(t, WriteQueueStats{}, fs.WriteQueueStats())
+	require.False(t, bk.pins[info.BackendHash.B58String()])
+}
+
+func TestWriteQueueSync(t *testing.T) {
+	t.Parallel()
+
+	alicePath, err := ioutil.TempDir("", "brig-fs-queue-test-alice")
+	require.Nil(t, err)
+	defer os.RemoveAll(alicePath)
+
+	bobPath, err := ioutil.TempDir("", "brig-fs-queue-test-bob")
+	require.Nil(t, err)
+	defer os.RemoveAll(bobPath)
+
+	cfg, err := config.Open(nil, defaults.Defaults, config.StrictnessPanic)
+	require.Nil(t, err)
+
+	bk := &switchableBackend{MemFsBackend: NewMemFsBackend()}
+	aliceFs, err := NewFilesystem(bk, alicePath, "alice", false, cfg.Section("fs"))
+	require.Nil(t, err)
+	defer aliceFs.Close()
+
+	bobFs, err := NewFilesystem(bk, bobPath, "bob", false, cfg.Section("fs"))
+	require.Nil(t, err)
+	defer bobFs.Close()
+
+	data := []byte("hello world")
+	require.Nil(t, aliceFs.Stage("/x", bytes.NewReader(data)))
+
+	// Nobody can get the placeholder while we're offline:
+	require.Equal(t, ie.ErrPendingUploads, aliceFs.MakeCommit("offline commit"))
+	_, err = aliceFs.MakePatch("curr", nil, "bob")
+	require.Equal(t, ie.ErrPendingUploads, err)
+
+	// Once online, the commit drains the queue first:
+	bk.online = true
+	require.Nil(t, aliceFs.MakeCommit("online commit"))
+	require.Equal(t, WriteQueueStats{}, aliceFs.WriteQueueStats())
+
+	aliceInfo, err := aliceFs.Stat("/x")
+	require.Nil(t, err)
+	require.Contains(t, bk.data, aliceInfo.BackendHash.B58String())
+
+	// Nothing is left to commit after the hashes were replaced:
+	require.Equal(t, ie.ErrNoChange, aliceFs.MakeCommit("nothing"))
+
+	require.Nil(t, bobFs.Sync(aliceFs))
+	bobInfo, err := bobFs.Stat("/x")
+	require.Nil(t, err)
+	require.Equal(t, aliceInfo.BackendHash, bobInfo.BackendHash)
+
+	stream, err := bobFs.Cat("/x")
+	require.Nil(t, err)
+	readData, err := ioutil.ReadAll(stream)
+	require.Nil(t, err)
+	require.Equal(t, data, readData)
+	require.Nil(t, stream.Close())
+}
@@ -8,6 +8,7 @@ import (
 	ie "github.com/sahib/brig/catfs/errors"
 	n "github.com/sahib/brig/catfs/nodes"
 	"github.com/sahib/brig/catfs/vcs"
+	"github.com/sahib/brig/metrics"
 	"github.com/sahib/brig/util"
 	log "github.com/sirupsen/logrus"
 )
@@ -263,6 +264,15 @@ func (fs *FS) repin(root string) error {
 		return e.Wrapf(err, "repin: quota balance")
 	}
 
+	pinnedStorage := uint64(0)
+	if totalStorage > quotaUnpins {
+		pinnedStorage = totalStorage - quotaUnpins
+	}
+
+	metrics.RepinQuotaBytes.Set(float64(quota))
+	metrics.RepinPinnedBytes.Set(float64(pinnedStorage))
+	metrics.RepinUnpinnedBytes.Add(float64(quotaUnpins))
+
 	savedStorage += quotaUnpins
 	log.Infof("repin finished; unpinned %s", humanize.Bytes(savedStorage))
 	return nil
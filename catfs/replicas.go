@@ -0,0 +1,311 @@
+package catfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/dustin/go-humanize"
+	e "github.com/pkg/errors"
+	"github.com/sahib/brig/catfs/db"
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+	"github.com/sahib/brig/util/bloom"
+	h "github.com/sahib/brig/util/hashlib"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReplicaFile describes a file in regard to how many copies of it should exist.
+type ReplicaFile struct {
+	// Path is the path of the file.
+	Path string
+	// BackendHash is the hash of the content other peers pin.
+	BackendHash h.Hash
+	// Size is the size of the file.
+	Size uint64
+	// IsPinned is true if we hold a copy of the content ourselves.
+	IsPinned bool
+	// MinReplicas is the number of remotes that should have it pinned.
+	// 0 if no replica policy applies to the file.
+	MinReplicas int
+}
+
+func (fs *FS) replicaPolicies() (map[string]int, error) {
+	policies := make(map[string]int)
+	data, err := fs.lkr.MetadataGet("fs.replica_policies")
+	if err == db.ErrNoSuchKey {
+		return policies, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+// minReplicasFor returns the policy of the deepest folder above `nodePath`.
+func minReplicasFor(policies map[string]int, nodePath string) int {
+	minReplicas, depth := 0, -1
+	for folder, folderMin := range policies {
+		if isBelowFolder(nodePath, folder) && len(folder) > depth {
+			minReplicas, depth = folderMin, len(folder)
+		}
+	}
+
+	return minReplicas
+}
+
+// ReplicaPolicies returns a mapping of folders to the number of
+// remotes that should have the files in them pinned.
+func (fs *FS) ReplicaPolicies() (map[string]int, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.replicaPolicies()
+}
+
+// SetReplicaPolicy requires every file below `root` to be pinned by
+// at least `minReplicas` remotes. Policies of sub folders take precedence.
+// A `minReplicas` of 0 removes the policy. The folder does not need to exist yet.
+func (fs *FS) SetReplicaPolicy(root string, minReplicas int) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.readOnly {
+		return ErrReadOnly
+	}
+
+	if minReplicas < 0 {
+		return fmt.Errorf("invalid number of replicas: %d", minReplicas)
+	}
+
+	policies, err := fs.replicaPolicies()
+	if err != nil {
+		return err
+	}
+
+	root = prefixSlash(path.Clean(root))
+	if minReplicas == 0 {
+		delete(policies, root)
+	} else {
+		policies[root] = minReplicas
+	}
+
+	data, err := json.Marshal(policies)
+	if err != nil {
+		return err
+	}
+
+	return fs.lkr.MetadataPut("fs.replica_policies", data)
+}
+
+// walkFiles calls `fn` for every file below `root` in the staging area.
+func (fs *FS) walkFiles(root string, fn func(nd n.ModNode) error) error {
+	rootNd, err := lookupFileOrDir(fs.lkr, root)
+	if err != nil {
+		return err
+	}
+
+	return n.Walk(fs.lkr, rootNd, true, func(child n.Node) error {
+		if child.Type() != n.NodeTypeFile {
+			return nil
+		}
+
+		modChild, ok := child.(n.ModNode)
+		if !ok {
+			return e.Wrapf(ie.ErrBadNode, "walk files")
+		}
+
+		return fn(modChild)
+	})
+}
+
+// ReplicaFiles returns all files below `root` with their pin state
+// and the number of replicas the policies require for them.
+func (fs *FS) ReplicaFiles(root string) ([]ReplicaFile, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	policies, err := fs.replicaPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	files := []ReplicaFile{}
+	err = fs.walkFiles(root, func(nd n.ModNode) error {
+		isPinned, _, err := fs.pinner.IsNodePinned(nd)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, ReplicaFile{
+			Path:        nd.Path(),
+			BackendHash: nd.BackendHash(),
+			Size:        nd.Size(),
+			IsPinned:    isPinned,
+			MinReplicas: minReplicasFor(policies, nd.Path()),
+		})
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// PinSummary returns a bloom filter with the backend hashes of all pinned
+// files below any of `prefixes`. If `prefixes` is empty, all files count.
+// Other peers can use it to check if we hold a copy of some content.
+func (fs *FS) PinSummary(prefixes []string) (*bloom.Filter, error) {
+	files, err := fs.ReplicaFiles("/")
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := []h.Hash{}
+	for _, file := range files {
+		if !file.IsPinned {
+			continue
+		}
+
+		if len(prefixes) > 0 && !isBelowAnyFolder(file.Path, prefixes) {
+			continue
+		}
+
+		hashes = append(hashes, file.BackendHash)
+	}
+
+	filter := bloom.New(len(hashes), 0.01)
+	for _, hash := range hashes {
+		filter.Add(hash.Bytes())
+	}
+
+	return filter, nil
+}
+
+func isBelowAnyFolder(nodePath string, folders []string) bool {
+	for _, folder := range folders {
+		if isBelowFolder(nodePath, folder) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PinReplicas pins the files with one of the backend hashes in `hashes`
+// on behalf of another peer. Only files in our own tree below any of
+// `prefixes` can be pinned (all files if `prefixes` is empty) and only
+// as long as all pinned files stay below fs.repin.quota.
+// The pins are explicit, so repinning does not remove them again.
+// The hashes that are pinned afterwards are returned.
+func (fs *FS) PinReplicas(hashes []h.Hash, prefixes []string) ([]h.Hash, error) {
+	toPin, pinned, err := fs.replicasToPin(hashes, prefixes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetching throttled content might take a long while.
+	// Do it before taking the lock again for pinning:
+	for _, nd := range toPin {
+		if err := fs.pinner.prefetch(nd.BackendHash()); err != nil {
+			return nil, err
+		}
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for _, nd := range toPin {
+		if err := fs.pinner.PinNode(nd, true); err != nil {
+			return nil, err
+		}
+
+		fs.preCacheInBackground(nd.BackendHash())
+		pinned = append(pinned, nd.BackendHash())
+	}
+
+	return pinned, nil
+}
+
+// replicasToPin returns the files PinReplicas should pin and
+// the hashes of the wanted files that are pinned already.
+func (fs *FS) replicasToPin(hashes []h.Hash, prefixes []string) ([]n.ModNode, []h.Hash, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.readOnly {
+		return nil, nil, ErrReadOnly
+	}
+
+	quota, err := humanize.ParseBytes(fs.cfg.String("repin.quota"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wanted := make(map[string]bool)
+	for _, hash := range hashes {
+		wanted[hash.B58String()] = true
+	}
+
+	pinnedSize := uint64(0)
+	candidates := []n.ModNode{}
+	pinned := []h.Hash{}
+	seen := make(map[string]bool)
+
+	err = fs.walkFiles("/", func(nd n.ModNode) error {
+		isPinned, _, err := fs.pinner.IsNodePinned(nd)
+		if err != nil {
+			return err
+		}
+
+		b58 := nd.BackendHash().B58String()
+		isAllowed := len(prefixes) == 0 || isBelowAnyFolder(nd.Path(), prefixes)
+		if isPinned {
+			pinnedSize += nd.Size()
+			if isAllowed && wanted[b58] && !seen[b58] {
+				seen[b58] = true
+				pinned = append(pinned, nd.BackendHash())
+			}
+
+			return nil
+		}
+
+		if isAllowed && wanted[b58] {
+			candidates = append(candidates, nd)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	toPin := []n.ModNode{}
+	for _, nd := range candidates {
+		b58 := nd.BackendHash().B58String()
+		if seen[b58] {
+			continue
+		}
+
+		if pinnedSize+nd.Size() > quota {
+			log.Infof("not pinning replica of %s: quota exceeded", nd.Path())
+			continue
+		}
+
+		pinnedSize += nd.Size()
+		seen[b58] = true
+		toPin = append(toPin, nd)
+	}
+
+	return toPin, pinned, nil
+}
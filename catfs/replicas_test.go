@@ -0,0 +1,105 @@
+package catfs
+
+import (
+	"bytes"
+	"testing"
+
+	h "github.com/sahib/brig/util/hashlib"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicaPolicies(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.SetReplicaPolicy("/archive", 2))
+		require.Nil(t, fs.SetReplicaPolicy("/archive/old", 1))
+		require.NotNil(t, fs.SetReplicaPolicy("/x", -1))
+
+		policies, err := fs.ReplicaPolicies()
+		require.Nil(t, err)
+		require.Equal(t, map[string]int{
+			"/archive":     2,
+			"/archive/old": 1,
+		}, policies)
+
+		require.Nil(t, fs.Stage("/archive/a", bytes.NewReader([]byte{1})))
+		require.Nil(t, fs.Stage("/archive/old/b", bytes.NewReader([]byte{2})))
+		require.Nil(t, fs.Stage("/c", bytes.NewReader([]byte{3})))
+
+		files, err := fs.ReplicaFiles("/")
+		require.Nil(t, err)
+		require.Len(t, files, 3)
+
+		minReplicas := make(map[string]int)
+		for _, file := range files {
+			require.True(t, file.IsPinned)
+			minReplicas[file.Path] = file.MinReplicas
+		}
+
+		require.Equal(t, map[string]int{
+			"/archive/a":     2,
+			"/archive/old/b": 1,
+			"/c":             0,
+		}, minReplicas)
+
+		require.Nil(t, fs.SetReplicaPolicy("/archive/old", 0))
+		files, err = fs.ReplicaFiles("/archive/old")
+		require.Nil(t, err)
+		require.Len(t, files, 1)
+		require.Equal(t, 2, files[0].MinReplicas)
+	})
+}
+
+func TestPinSummaryAndReplicas(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.Stage("/pub/a", bytes.NewReader([]byte{1})))
+		require.Nil(t, fs.Stage("/pub/b", bytes.NewReader([]byte{2, 2})))
+		require.Nil(t, fs.Stage("/priv/c", bytes.NewReader([]byte{3, 3, 3})))
+		require.Nil(t, fs.Unpin("/pub/b", "curr", true))
+
+		infoA, err := fs.Stat("/pub/a")
+		require.Nil(t, err)
+		infoB, err := fs.Stat("/pub/b")
+		require.Nil(t, err)
+		infoC, err := fs.Stat("/priv/c")
+		require.Nil(t, err)
+
+		filter, err := fs.PinSummary([]string{"/pub"})
+		require.Nil(t, err)
+		require.True(t, filter.Test(infoA.BackendHash.Bytes()))
+		require.False(t, filter.Test(infoB.BackendHash.Bytes()))
+		require.False(t, filter.Test(infoC.BackendHash.Bytes()))
+
+		// 1 + 3 bytes are pinned; there is no room for /pub/b:
+		fs.cfg.SetString("repin.quota", "5")
+		pinned, err := fs.PinReplicas([]h.Hash{infoA.BackendHash, infoB.BackendHash}, nil)
+		require.Nil(t, err)
+		require.Equal(t, []h.Hash{infoA.BackendHash}, pinned)
+
+		// Content outside of the allowed folders is never mentioned:
+		pinned, err = fs.PinReplicas([]h.Hash{infoA.BackendHash, infoC.BackendHash}, []string{"/priv/x", "/pub"})
+		require.Nil(t, err)
+		require.Equal(t, []h.Hash{infoA.BackendHash}, pinned)
+
+		fs.cfg.SetString("repin.quota", "6")
+		pinned, err = fs.PinReplicas([]h.Hash{infoB.BackendHash}, []string{"/priv"})
+		require.Nil(t, err)
+		require.Empty(t, pinned)
+
+		isPinned, _, err := fs.IsPinned("/pub/b")
+		require.Nil(t, err)
+		require.False(t, isPinned)
+
+		pinned, err = fs.PinReplicas([]h.Hash{infoB.BackendHash}, []string{"/pub"})
+		require.Nil(t, err)
+		require.Equal(t, []h.Hash{infoB.BackendHash}, pinned)
+
+		isPinned, isExplicit, err := fs.IsPinned("/pub/b")
+		require.Nil(t, err)
+		require.True(t, isPinned)
+		require.True(t, isExplicit)
+	})
+}
@@ -0,0 +1,215 @@
+package catfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	e "github.com/pkg/errors"
+	c "github.com/sahib/brig/catfs/core"
+	"github.com/sahib/brig/catfs/db"
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+	h "github.com/sahib/brig/util/hashlib"
+	log "github.com/sirupsen/logrus"
+)
+
+// Possible values of Commit.Signature:
+const (
+	// SignatureGood means that the signature matches the owner's key.
+	SignatureGood = "good"
+	// SignatureBad means that the signature does not match the owner's key.
+	SignatureBad = "bad"
+	// SignatureUnsigned means that there is no signature at all.
+	SignatureUnsigned = "unsigned"
+	// SignatureUnknown means that there is a signature,
+	// but no key to check it with (see SetVerifier).
+	SignatureUnknown = "unknown"
+)
+
+// ErrBadSignature is returned when history or a patch is rejected because
+// it is unsigned or badly signed (see fs.sync.require_signatures).
+var ErrBadSignature = errors.New("bad or missing signature")
+
+// SetSigner sets a function that signs every new commit and every patch
+// created by MakePatch. A nil `sign` disables signing.
+func (fs *FS) SetSigner(sign func(data []byte) ([]byte, error)) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.signer = sign
+	fs.lkr.SetSigner(sign)
+}
+
+// SetVerifier sets a function that checks if `sig` is a valid signature
+// of `data` made by the owner of this filesystem. It is used to check
+// commits and patches. A nil `verify` disables all checks.
+func (fs *FS) SetVerifier(verify func(data, sig []byte) error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.verifier = verify
+}
+
+func (fs *FS) signatureStatus(data, sig []byte) string {
+	if len(sig) == 0 {
+		return SignatureUnsigned
+	}
+
+	if fs.verifier == nil {
+		return SignatureUnknown
+	}
+
+	if err := fs.verifier(data, sig); err != nil {
+		return SignatureBad
+	}
+
+	return SignatureGood
+}
+
+func (fs *FS) commitSignature(cmt *n.Commit) string {
+	// The signature only covers the stored hash. Make sure it was
+	// not kept while the attributes of the commit were changed:
+	expected, err := n.ExpectedTreeHash(cmt)
+	if err != nil || !expected.Equal(cmt.TreeHash()) {
+		return SignatureBad
+	}
+
+	return fs.signatureStatus(cmt.TreeHash().Bytes(), cmt.Signature())
+}
+
+// rejectSignature fails with `msg` if signatures are required,
+// otherwise it only logs a warning.
+func (fs *FS) rejectSignature(msg string) error {
+	if fs.cfg.Bool("sync.require_signatures") {
+		return e.Wrap(ErrBadSignature, msg)
+	}
+
+	log.Warningf("%s", msg)
+	return nil
+}
+
+// VerifyHistory checks the signatures of all commits up to HEAD
+// and that the trees of the commits match their signed hashes.
+// Commits that are not signed with the key known to SetVerifier
+// are rejected with ErrBadSignature if fs.sync.require_signatures
+// is set. Otherwise only a warning is logged.
+func (fs *FS) VerifyHistory() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.verifyHistory(fs.lkr)
+}
+
+// verifyDump checks the history in a dump made by Export
+// before it is imported.
+func (fs *FS) verifyDump(data []byte) error {
+	dbPath, err := ioutil.TempDir("", "brig-fs-import")
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(dbPath)
+
+	kv, err := db.NewBadgerDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+
+	defer kv.Close()
+
+	if err := kv.Import(bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	return fs.verifyHistory(c.NewLinker(kv))
+}
+
+// treeMatches checks that every node below `hash` is stored under the
+// hash it is referenced by and that this hash matches its attributes.
+// Only then does the signature of a commit cover its whole tree.
+// Subtrees in `checked` were found to be fine already.
+func treeMatches(lkr *c.Linker, hash h.Hash, checked map[string]bool) bool {
+	b58Hash := hash.B58String()
+	if checked[b58Hash] {
+		return true
+	}
+
+	nd, err := lkr.NodeByHash(hash)
+	if err != nil || nd == nil || !nd.TreeHash().Equal(hash) {
+		return false
+	}
+
+	expected, err := n.ExpectedTreeHash(nd)
+	if err != nil || !expected.Equal(hash) {
+		return false
+	}
+
+	if dir, ok := nd.(*n.Directory); ok {
+		for _, childHash := range dir.ChildHashes() {
+			if !treeMatches(lkr, childHash, checked) {
+				return false
+			}
+		}
+	}
+
+	checked[b58Hash] = true
+	return true
+}
+
+func (fs *FS) verifyHistory(lkr *c.Linker) error {
+	head, err := lkr.Head()
+	if ie.IsErrNoSuchRef(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	counts := make(map[string]int)
+	checked := make(map[string]bool)
+	expectedHash := head.TreeHash()
+	err = c.Log(lkr, head, func(cmt *n.Commit) error {
+		total++
+
+		// A good signature is worth nothing if the commit or its tree
+		// were swapped with something that is stored under the same hash.
+		status := fs.commitSignature(cmt)
+		if status == SignatureGood {
+			if !cmt.TreeHash().Equal(expectedHash) || !treeMatches(lkr, cmt.Root(), checked) {
+				status = SignatureBad
+			}
+		}
+
+		expectedHash = cmt.ParentHash()
+		counts[status]++
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if counts[SignatureGood] == total {
+		return nil
+	}
+
+	owner, err := fs.lkr.Owner()
+	if err != nil {
+		return err
+	}
+
+	return fs.rejectSignature(fmt.Sprintf(
+		"history of %s: %d of %d commits are not verified (%d bad, %d unsigned, %d unknown)",
+		owner,
+		total-counts[SignatureGood],
+		total,
+		counts[SignatureBad],
+		counts[SignatureUnsigned],
+		counts[SignatureUnknown],
+	))
+}
@@ -0,0 +1,207 @@
+package catfs
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	e "github.com/pkg/errors"
+	capnp_model "github.com/sahib/brig/catfs/nodes/capnp"
+	h "github.com/sahib/brig/util/hashlib"
+	"github.com/stretchr/testify/require"
+)
+
+func dummySigner(key string) func(data []byte) ([]byte, error) {
+	return func(data []byte) ([]byte, error) {
+		return h.Sum(append([]byte(key), data...)).Bytes(), nil
+	}
+}
+
+func dummyVerifier(key string) func(data, sig []byte) error {
+	return func(data, sig []byte) error {
+		if !bytes.Equal(h.Sum(append([]byte(key), data...)).Bytes(), sig) {
+			return errors.New("signature mismatch")
+		}
+
+		return nil
+	}
+}
+
+func logSignatures(t *testing.T, fs *FS) []string {
+	sigs := []string{}
+	require.Nil(t, fs.Log("", func(cmt *Commit) error {
+		sigs = append(sigs, cmt.Signature)
+		return nil
+	}))
+
+	return sigs
+}
+
+func TestCommitSignature(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.MakeCommit("unsigned"))
+
+		fs.SetSigner(dummySigner("alice"))
+		require.Nil(t, fs.Touch("/x"))
+		require.Nil(t, fs.MakeCommit("signed"))
+
+		// Without a key we cannot say anything about the signature:
+		require.Equal(t, []string{"", SignatureUnknown, SignatureUnsigned}, logSignatures(t, fs))
+
+		fs.SetVerifier(dummyVerifier("alice"))
+		require.Equal(t, []string{"", SignatureGood, SignatureUnsigned}, logSignatures(t, fs))
+
+		fs.SetVerifier(dummyVerifier("bob"))
+		require.Equal(t, []string{"", SignatureBad, SignatureUnsigned}, logSignatures(t, fs))
+
+		// Only a warning by default:
+		require.Nil(t, fs.VerifyHistory())
+
+		fs.cfg.SetBool("sync.require_signatures", true)
+		require.Equal(t, ErrBadSignature, e.Cause(fs.VerifyHistory()))
+	})
+}
+
+func TestPatchSignature(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(srcFs *FS) {
+		withDummyFS(t, func(dstFs *FS) {
+			require.Nil(t, srcFs.MakeCommit("init"))
+			require.Nil(t, srcFs.Touch("/x"))
+			require.Nil(t, srcFs.MakeCommit("added x"))
+
+			dstFs.cfg.SetBool("sync.require_signatures", true)
+			dstFs.SetVerifier(dummyVerifier("alice"))
+
+			unsigned, err := srcFs.MakePatch("commit[0]", nil, "")
+			require.Nil(t, err)
+			require.Equal(t, ErrBadSignature, e.Cause(dstFs.ApplyPatch(unsigned)))
+
+			srcFs.SetSigner(dummySigner("mallory"))
+			badlySigned, err := srcFs.MakePatch("commit[0]", nil, "")
+			require.Nil(t, err)
+			require.Equal(t, ErrBadSignature, e.Cause(dstFs.ApplyPatch(badlySigned)))
+
+			_, err = dstFs.Stat("/x")
+			require.NotNil(t, err)
+
+			srcFs.SetSigner(dummySigner("alice"))
+			signed, err := srcFs.MakePatch("commit[0]", nil, "")
+			require.Nil(t, err)
+			require.Nil(t, dstFs.ApplyPatch(signed))
+
+			_, err = dstFs.Stat("/x")
+			require.Nil(t, err)
+		})
+	})
+}
+
+func TestImportSignature(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(srcFs *FS) {
+		srcFs.SetSigner(dummySigner("alice"))
+		require.Nil(t, srcFs.Touch("/x"))
+		require.Nil(t, srcFs.MakeCommit("added x"))
+
+		dump := &bytes.Buffer{}
+		require.Nil(t, srcFs.Export(dump))
+
+		withDummyFS(t, func(dstFs *FS) {
+			dstFs.cfg.SetBool("sync.require_signatures", true)
+			dstFs.SetVerifier(dummyVerifier("bob"))
+
+			err := dstFs.Import(bytes.NewReader(dump.Bytes()))
+			require.Equal(t, ErrBadSignature, e.Cause(err))
+
+			_, err = dstFs.Stat("/x")
+			require.NotNil(t, err)
+
+			dstFs.SetVerifier(dummyVerifier("alice"))
+			require.Nil(t, dstFs.Import(bytes.NewReader(dump.Bytes())))
+
+			_, err = dstFs.Stat("/x")
+			require.Nil(t, err)
+		})
+	})
+}
+
+func TestCommitSignatureForged(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		fs.SetSigner(dummySigner("alice"))
+		fs.SetVerifier(dummyVerifier("alice"))
+		require.Nil(t, fs.Touch("/x"))
+		require.Nil(t, fs.MakeCommit("signed"))
+		require.Equal(t, []string{"", SignatureGood}, logSignatures(t, fs))
+
+		// Change the message, but keep the hash and signature:
+		head, err := fs.lkr.Head()
+		require.Nil(t, err)
+
+		msg, err := head.ToCapnp()
+		require.Nil(t, err)
+
+		capNd, err := capnp_model.ReadRootNode(msg)
+		require.Nil(t, err)
+
+		capCmt, err := capNd.Commit()
+		require.Nil(t, err)
+		require.Nil(t, capCmt.SetMessage("forged"))
+
+		data, err := msg.Marshal()
+		require.Nil(t, err)
+
+		batch := fs.lkr.KV().Batch()
+		batch.Put(data, "objects", head.TreeHash().B58String())
+		require.Nil(t, batch.Flush())
+		fs.lkr.MemIndexClear()
+
+		require.Equal(t, []string{"", SignatureBad}, logSignatures(t, fs))
+
+		fs.cfg.SetBool("sync.require_signatures", true)
+		require.Equal(t, ErrBadSignature, e.Cause(fs.VerifyHistory()))
+	})
+}
+
+func TestCommitSignatureForgedTree(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		fs.SetSigner(dummySigner("alice"))
+		fs.SetVerifier(dummyVerifier("alice"))
+		fs.cfg.SetBool("sync.require_signatures", true)
+		require.Nil(t, fs.Mkdir("/dir", false))
+		require.Nil(t, fs.Touch("/dir/x"))
+		require.Nil(t, fs.MakeCommit("signed"))
+		require.Nil(t, fs.VerifyHistory())
+
+		// Change the content of a file deep in the tree,
+		// but store it under its old hash:
+		nd, err := fs.lkr.LookupNode("/dir/x")
+		require.Nil(t, err)
+
+		msg, err := nd.ToCapnp()
+		require.Nil(t, err)
+
+		capNd, err := capnp_model.ReadRootNode(msg)
+		require.Nil(t, err)
+		require.Nil(t, capNd.SetContentHash(h.TestDummy(t, 42)))
+
+		data, err := msg.Marshal()
+		require.Nil(t, err)
+
+		batch := fs.lkr.KV().Batch()
+		batch.Put(data, "objects", nd.TreeHash().B58String())
+		require.Nil(t, batch.Flush())
+		fs.lkr.MemIndexClear()
+
+		// The commit itself is still fine, but its tree is not:
+		require.Equal(t, []string{"", SignatureGood}, logSignatures(t, fs))
+		require.Equal(t, ErrBadSignature, e.Cause(fs.VerifyHistory()))
+	})
+}
@@ -0,0 +1,417 @@
+package catfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	e "github.com/pkg/errors"
+	c "github.com/sahib/brig/catfs/core"
+	"github.com/sahib/brig/catfs/db"
+	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
+	"github.com/sahib/brig/catfs/vcs"
+)
+
+// Usage describes how much storage is used below a directory.
+// The numbers reflect the state of the last commit.
+type Usage struct {
+	// Path is the directory (or the user name for UsageByUser)
+	Path string
+	// Files is the number of files below Path
+	Files uint64
+	// Logical is the sum of all file sizes
+	Logical uint64
+	// Deduplicated counts every content only once
+	Deduplicated uint64
+	// Pinned is the deduplicated size of all pinned content
+	Pinned uint64
+	// Cached is the deduplicated size of all locally available content
+	Cached uint64
+	// Quota is the maximum logical size of the directory (0 if none)
+	Quota uint64
+}
+
+// DiskUsage returns the usage of the directory `root` and all its
+// sub directories up to a depth of `maxDepth` relative to it.
+// A negative `maxDepth` lists all directories.
+//
+// The number of files and their sizes are updated on every commit.
+// Pinned and cached bytes depend on the pin state and are computed
+// on every call, so this can be slow for big trees.
+func (fs *FS) DiskUsage(root string, maxDepth int) ([]Usage, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	root = prefixSlash(path.Clean(root))
+	quotas, err := fs.quotas()
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := fs.lkr.Head()
+	if ie.IsErrNoSuchRef(err) {
+		// Nothing committed yet, so nothing is used.
+		return []Usage{{Path: root, Quota: quotas[root]}}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	rootNd, err := fs.lkr.LookupNodeAt(head, root)
+	if err != nil {
+		return nil, err
+	}
+
+	if rootNd == nil || rootNd.Type() != n.NodeTypeDirectory {
+		return nil, e.Wrapf(ie.NoSuchFile(root), "no directory in last commit")
+	}
+
+	rootDepth := n.Depth(rootNd)
+	usages := make(map[string]*Usage)
+	seen := make(map[string]map[string]bool)
+
+	// Remember the pin and cache state per content.
+	isPinned := make(map[string]bool)
+	isCached := make(map[string]bool)
+
+	err = n.Walk(fs.lkr, rootNd, false, func(child n.Node) error {
+		switch child.Type() {
+		case n.NodeTypeDirectory:
+			if maxDepth >= 0 && n.Depth(child)-rootDepth > maxDepth {
+				return nil
+			}
+
+			usage, err := fs.lkr.DirUsage(child.Path())
+			if err != nil {
+				return err
+			}
+
+			usages[child.Path()] = &Usage{
+				Path:         child.Path(),
+				Files:        usage.Files,
+				Logical:      usage.Logical,
+				Deduplicated: usage.Deduplicated,
+				Quota:        quotas[child.Path()],
+			}
+
+			seen[child.Path()] = make(map[string]bool)
+			return nil
+		case n.NodeTypeFile:
+			b58Hash := child.BackendHash().B58String()
+			if _, ok := isPinned[b58Hash]; !ok {
+				pinned, _, err := fs.pinner.IsNodePinned(child)
+				if err != nil {
+					return err
+				}
+
+				cached, err := fs.bk.IsCached(child.BackendHash())
+				if err != nil {
+					return err
+				}
+
+				isPinned[b58Hash] = pinned
+				isCached[b58Hash] = cached
+			}
+
+			// Add the file to all reported parent directories:
+			for dir := path.Dir(child.Path()); ; dir = path.Dir(dir) {
+				if usage, ok := usages[dir]; ok && !seen[dir][b58Hash] {
+					seen[dir][b58Hash] = true
+					if isPinned[b58Hash] {
+						usage.Pinned += child.Size()
+					}
+
+					if isCached[b58Hash] {
+						usage.Cached += child.Size()
+					}
+				}
+
+				if dir == root || dir == "/" {
+					break
+				}
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := []Usage{}
+	for _, usage := range usages {
+		result = append(result, *usage)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+
+	return result, nil
+}
+
+// UsageByUser returns the usage of each user, based on who
+// modified a file last. Pinned and Cached are not filled.
+func (fs *FS) UsageByUser() ([]Usage, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	usages, err := fs.lkr.UsageByUser()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []Usage{}
+	for user, usage := range usages {
+		result = append(result, Usage{
+			Path:         user,
+			Files:        usage.Files,
+			Logical:      usage.Logical,
+			Deduplicated: usage.Deduplicated,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+
+	return result, nil
+}
+
+/////////////////////
+// QUOTA HANDLING  //
+/////////////////////
+
+func (fs *FS) quotas() (map[string]uint64, error) {
+	quotas := make(map[string]uint64)
+	data, err := fs.lkr.MetadataGet("fs.quotas")
+	if err == db.ErrNoSuchKey {
+		return quotas, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &quotas); err != nil {
+		return nil, err
+	}
+
+	return quotas, nil
+}
+
+// Quotas returns a mapping of folders to their quota in bytes.
+func (fs *FS) Quotas() (map[string]uint64, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.quotas()
+}
+
+// SetQuota limits the logical size of the folder at `root` to `size` bytes.
+// Staging files or syncing changes that would make the folder bigger fail
+// with ErrQuotaExceeded. A size of 0 removes the quota.
+// The folder does not need to exist yet.
+func (fs *FS) SetQuota(root string, size uint64) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.readOnly {
+		return ErrReadOnly
+	}
+
+	quotas, err := fs.quotas()
+	if err != nil {
+		return err
+	}
+
+	root = prefixSlash(path.Clean(root))
+	if size == 0 {
+		delete(quotas, root)
+	} else {
+		quotas[root] = size
+	}
+
+	data, err := json.Marshal(quotas)
+	if err != nil {
+		return err
+	}
+
+	return fs.lkr.MetadataPut("fs.quotas", data)
+}
+
+func isBelowFolder(nodePath, folder string) bool {
+	return folder == "/" || nodePath == folder || strings.HasPrefix(nodePath, folder+"/")
+}
+
+// checkQuotas checks if growing the folders below the keys of `growth` by the
+// respective values would exceed any of the folder quotas.
+// The current size is taken from the staging area.
+func (fs *FS) checkQuotas(growth map[string]int64) error {
+	quotas, err := fs.quotas()
+	if err != nil {
+		return err
+	}
+
+	folders := []string{}
+	for folder := range quotas {
+		folders = append(folders, folder)
+	}
+
+	sort.Strings(folders)
+
+	for _, folder := range folders {
+		delta := int64(0)
+		for nodePath, size := range growth {
+			if isBelowFolder(nodePath, folder) {
+				delta += size
+			}
+		}
+
+		if delta <= 0 {
+			continue
+		}
+
+		current := uint64(0)
+		dir, err := fs.lkr.LookupDirectory(folder)
+		if err != nil && !ie.IsNoSuchFileError(err) {
+			return err
+		}
+
+		if dir != nil {
+			current = dir.Size()
+		}
+
+		if quota := quotas[folder]; current+uint64(delta) > quota {
+			return e.Wrapf(
+				ie.ErrQuotaExceeded,
+				"%s would use %s of %s",
+				folder,
+				humanize.Bytes(current+uint64(delta)),
+				humanize.Bytes(quota),
+			)
+		}
+	}
+
+	return nil
+}
+
+// CheckSyncQuota checks if syncing with `remote` would exceed any folder
+// quota or would make the files modified last by `remoteUser` take more
+// than `remoteQuota` bytes (0 disables this check). The same options as
+// given to Sync() should be passed. If a quota would be exceeded, an error
+// wrapping ErrQuotaExceeded is returned and nothing should be synced.
+func (fs *FS) CheckSyncQuota(remote *FS, remoteUser string, remoteQuota uint64, options ...SyncOption) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	srcHead, err := remote.lkr.Head()
+	if ie.IsErrNoSuchRef(err) {
+		// They have nothing to give to us.
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	dstHead, err := fs.lkr.Head()
+	if err != nil {
+		return err
+	}
+
+	syncCfg, err := fs.buildSyncCfg()
+	if err != nil {
+		return err
+	}
+
+	for _, option := range options {
+		option(syncCfg)
+	}
+
+	diff, err := vcs.MakeDiff(remote.lkr, fs.lkr, srcHead, dstHead, syncCfg)
+	if err != nil {
+		return err
+	}
+
+	growth := make(map[string]int64)
+	userGrowth := int64(0)
+	visited := make(map[string]bool)
+
+	// account adds or subtracts the size of all files in `nd`.
+	// Directories might be reported as a whole by the diff.
+	account := func(lkr *c.Linker, nd n.Node, sign int64) error {
+		return n.Walk(lkr, nd, true, func(child n.Node) error {
+			if child.Type() != n.NodeTypeFile {
+				return nil
+			}
+
+			key := fmt.Sprintf("%d:%s", sign, child.Path())
+			if visited[key] {
+				return nil
+			}
+
+			visited[key] = true
+			size := sign * int64(child.Size())
+			growth[child.Path()] += size
+			if child.User() == remoteUser {
+				userGrowth += size
+			}
+
+			return nil
+		})
+	}
+
+	for _, nd := range diff.Added {
+		if err := account(remote.lkr, nd, +1); err != nil {
+			return err
+		}
+	}
+
+	for _, nd := range diff.Removed {
+		if err := account(fs.lkr, nd, -1); err != nil {
+			return err
+		}
+	}
+
+	for _, pair := range append(diff.Merged, diff.Moved...) {
+		if err := account(remote.lkr, pair.Src, +1); err != nil {
+			return err
+		}
+
+		if err := account(fs.lkr, pair.Dst, -1); err != nil {
+			return err
+		}
+	}
+
+	if err := fs.checkQuotas(growth); err != nil {
+		return err
+	}
+
+	if remoteQuota == 0 || userGrowth <= 0 {
+		return nil
+	}
+
+	usage, err := fs.lkr.UserUsage(remoteUser)
+	if err != nil {
+		return err
+	}
+
+	if newSize := usage.Logical + uint64(userGrowth); newSize > remoteQuota {
+		return e.Wrapf(
+			ie.ErrQuotaExceeded,
+			"files of %s would use %s of %s",
+			remoteUser,
+			humanize.Bytes(newSize),
+			humanize.Bytes(remoteQuota),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package catfs
+
+import (
+	"bytes"
+	"testing"
+
+	e "github.com/pkg/errors"
+	ie "github.com/sahib/brig/catfs/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskUsage(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		usages, err := fs.DiskUsage("/", -1)
+		require.Nil(t, err)
+		require.Equal(t, []Usage{{Path: "/"}}, usages)
+
+		require.Nil(t, fs.Stage("/a/x", bytes.NewReader([]byte{1, 2, 3})))
+		require.Nil(t, fs.Stage("/a/y", bytes.NewReader([]byte{1, 2, 3})))
+		require.Nil(t, fs.Stage("/a/b/z", bytes.NewReader([]byte{4, 5})))
+		require.Nil(t, fs.SetQuota("/a", 100))
+		require.Nil(t, fs.MakeCommit("add"))
+		require.Nil(t, fs.Unpin("/a/b/z", "curr", true))
+
+		usages, err = fs.DiskUsage("/", 1)
+		require.Nil(t, err)
+		require.Len(t, usages, 2)
+
+		require.Equal(t, "/", usages[0].Path)
+		require.Equal(t, uint64(3), usages[0].Files)
+		require.Equal(t, uint64(8), usages[0].Logical)
+		require.Equal(t, uint64(5), usages[0].Deduplicated)
+		require.Equal(t, uint64(3), usages[0].Pinned)
+		require.Equal(t, uint64(5), usages[0].Cached)
+		require.Equal(t, uint64(0), usages[0].Quota)
+
+		require.Equal(t, "/a", usages[1].Path)
+		require.Equal(t, uint64(100), usages[1].Quota)
+
+		usages, err = fs.DiskUsage("/a/b", -1)
+		require.Nil(t, err)
+		require.Len(t, usages, 1)
+		require.Equal(t, uint64(2), usages[0].Logical)
+		require.Equal(t, uint64(0), usages[0].Pinned)
+
+		users, err := fs.UsageByUser()
+		require.Nil(t, err)
+		require.Len(t, users, 1)
+		require.Equal(t, "alice", users[0].Path)
+		require.Equal(t, uint64(8), users[0].Logical)
+	})
+}
+
+func TestStageQuota(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fs *FS) {
+		require.Nil(t, fs.SetQuota("/limited", 4))
+
+		quotas, err := fs.Quotas()
+		require.Nil(t, err)
+		require.Equal(t, map[string]uint64{"/limited": 4}, quotas)
+
+		require.Nil(t, fs.Stage("/limited/x", bytes.NewReader([]byte{1, 2, 3})))
+		err = fs.Stage("/limited/y", bytes.NewReader([]byte{1, 2}))
+		require.Equal(t, ie.ErrQuotaExceeded, e.Cause(err))
+
+		// Replacing a file only counts the difference:
+		require.Nil(t, fs.Stage("/limited/x", bytes.NewReader([]byte{1, 2, 3, 4})))
+
+		// Other folders are not affected:
+		require.Nil(t, fs.Stage("/free/x", bytes.NewReader([]byte{1, 2, 3, 4, 5})))
+
+		require.Nil(t, fs.SetQuota("/limited", 0))
+		require.Nil(t, fs.Stage("/limited/y", bytes.NewReader([]byte{1, 2})))
+
+		quotas, err = fs.Quotas()
+		require.Nil(t, err)
+		require.Empty(t, quotas)
+	})
+}
+
+func TestSyncQuota(t *testing.T) {
+	t.Parallel()
+
+	withDummyFS(t, func(fsa *FS) {
+		require.Nil(t, fsa.MakeCommit("hello a"))
+		withDummyFS(t, func(fsb *FS) {
+			require.Nil(t, fsb.Stage("/sub/x", bytes.NewReader([]byte{1, 2, 3})))
+			require.Nil(t, fsb.Stage("/y", bytes.NewReader([]byte{4, 5})))
+			require.Nil(t, fsb.MakeCommit("hello b"))
+
+			require.Nil(t, fsa.CheckSyncQuota(fsb, "alice", 0))
+			require.Nil(t, fsa.CheckSyncQuota(fsb, "alice", 5))
+
+			err := fsa.CheckSyncQuota(fsb, "alice", 4)
+			require.Equal(t, ie.ErrQuotaExceeded, e.Cause(err))
+
+			// Only the files of that user count:
+			require.Nil(t, fsa.CheckSyncQuota(fsb, "bob", 1))
+
+			require.Nil(t, fsa.SetQuota("/sub", 2))
+			err = fsa.CheckSyncQuota(fsb, "alice", 0)
+			require.Equal(t, ie.ErrQuotaExceeded, e.Cause(err))
+
+			// Limiting the sync to other folders works:
+			require.Nil(t, fsa.CheckSyncQuota(fsb, "alice", 0, SyncOptOnlyFolders([]string{"/y"})))
+		})
+	})
+}
@@ -201,12 +201,12 @@ type Patch struct{ capnp.Struct }
 const Patch_TypeID = 0x927c7336e3054805
 
 func NewPatch(s *capnp.Segment) (Patch, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 1})
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 24, PointerCount: 2})
 	return Patch{st}, err
 }
 
 func NewRootPatch(s *capnp.Segment) (Patch, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 1})
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 24, PointerCount: 2})
 	return Patch{st}, err
 }
 
@@ -261,12 +261,34 @@ func (s Patch) NewChanges(n int32) (Change_List, error) {
 	return l, err
 }
 
+func (s Patch) IsShallow() bool {
+	return s.Struct.Bit(128)
+}
+
+func (s Patch) SetIsShallow(v bool) {
+	s.Struct.SetBit(128, v)
+}
+
+func (s Patch) Signature() ([]byte, error) {
+	p, err := s.Struct.Ptr(1)
+	return []byte(p.Data()), err
+}
+
+func (s Patch) HasSignature() bool {
+	p, err := s.Struct.Ptr(1)
+	return p.IsValid() || err != nil
+}
+
+func (s Patch) SetSignature(v []byte) error {
+	return s.Struct.SetData(1, v)
+}
+
 // Patch_List is a list of Patch.
 type Patch_List struct{ capnp.List }
 
 // NewPatch creates a new list of Patch.
 func NewPatch_List(s *capnp.Segment, sz int32) (Patch_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 16, PointerCount: 1}, sz)
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 24, PointerCount: 2}, sz)
 	return Patch_List{l}, err
 }
 
@@ -287,37 +309,40 @@ func (p Patch_Promise) Struct() (Patch, error) {
 	return Patch{s}, err
 }
 
-const schema_b943b54bf1683782 = "x\xda|\x90Ak\x13]\x18\x85\xcfy\xef\xcc\x17\xfa" +
-	"\x11M\xc6d!R\xe8luaZ\x0a\x0a\"h\x8d" +
-	"\x82\xa2\xc2\\QtU\x18'\x93L0\x99\x84\xcc4" +
-	"6\xa0\x04\xba\x11\x85\x82T\x05\x05\xc5\x0aU,T\xea" +
-	"F\xb0\x0b\x97\xfe\x05\xff@\x97\xae\x04W\xdd\x8c\xdc\xd4" +
-	"j\xd1\xe2\xee\xf2\xdc\x03/\xcf39\xcb\xd32eW" +
-	"l@\x9f\xb4\xff\xcb\xec\xf3\xf6\xe6\xb1\xe4\xce\x12\xf48" +
-	"%[8\x1e}\xbb\xf8\xa1\xba\x01\x9b9`\xfa\xae\x1c" +
-	"`iQr\xa5E\x99(m\xc8:\x98\xad\xdf\xbf\xf7" +
-	"}\xdf\xe4\xd2\x13\xb3\xe7\xae\xbdm\xf6Mu\x88\xa5\x81" +
-	"\xca\x95\x06jbzU]'\x1ef\x81\x9f\xd6\x93J" +
-	"?PI%\xf0\xbbq\xb7\xd2\xf5\xd3 ::z\x9f" +
-	"\xf0\xfc4`\xe4\x91\xda\xa2d\xb3\x8f^\xeaO_\x1e" +
-	"|\x86\xb6\x843\xe3d\x1ep\xb8\x95\x99U\xe4\x06\x1d" +
-	"\x89S\xbf\x19'\xae\xef&\xcd\xb8\xd1\x0a\xddSA\xe4" +
-	"\xc7\x8d\x10\xd0ye\x01\x16\x01\xe7\xdc\x15@\x9fU\xd4" +
-	"\x9e\xd0!\xcb4\xf0\xb2\x81\x97\x14\xf5\x0d!\xa5L\x01" +
-	"\x9ckg\x00\xed)\xea\x960\xab\xf7:\xed\x0bq-" +
-	"\x04\xe7iCh\x83Y0\xd7\xeb\xfd\xc1\x86\xdb\x07\x13" +
-	"\xee\x07=E\x16\x7f\xf7\x00\x0d\xfc\xb7n5\xf2c\xd5" +
-	"\x08\xf7\xf6uG\xbeS\xfc\x9fYut\xc5\xad\xa90" +
-	"\x09z\xcd\x9b\xe1.\xe5\x9f\xc6\xd4\x07\x7f\x19?;\x02" +
-	"\xe8\xc7\x8azY\xb8#\xfc\xc2\xb0\xa7\x8azE\xe8\x08" +
-	"\xb7\x8d_\x19\xf8\\Q\xbf\x15:J\xcaT\x80\xf3\xda" +
-	"\xc0eE\xbd&t,U\xa6\x058\xab\xa6\xcd\x8a\xa2" +
-	"~/tl\xabL\x1bp\xde-\x00zMQ\x7f\x14" +
-	"\x16\xda~r\x8bc\x10\x8e\x81\x85(\xf4k,f\x9b" +
-	"[\xf5\xee\xf0\xeb\xe17\xa6E\x11,\xc4\xe1|\xba\x07" +
-	"6]\xff\xc6\xc3v\xa7\x1f\xd6\xaev\x98\x870\x0ff" +
-	"\xb7\xfd\xc4\xeb\x85\xfd&;sIk0\x93b\xe7\xe7" +
-	"G\x00\x00\x00\xff\xff\x0b\xcf\xb1-"
+const schema_b943b54bf1683782 = "x\xda|\xd0OKTQ\x18\xc7\xf1\xdf\xef9\xf7&" +
+	"\x86\xa6\xb7\x99E\x84\xe0lu\xd1(BA\x04en" +
+	"\x8a6s*h\x17\x9c\xee\\\xe7\x0e\x8dw\x86\xb9\xd7" +
+	"?\x81fH\x91\x05\x82XAA\x91\x82\x85\x81Q\x8b" +
+	"\x82\\\xb4\xec-\xf4\x06\\E\xab\xa0\x8dnN\x1c\xff" +
+	"\xd4P\xd2\xee\xf0=\xcf\x03\x0f\x9f\x81\xdb<'\x83~" +
+	"\xd1\x07\xf4\x19\xff\x90\xf5/\xf8\x9b'\xd3\xe9%\xe8\x1e" +
+	"*;w*\xfeq\xe9\xe3\xc8\x06|i\x03\x86f\xe4" +
+	"(s\x0b\xd2\x96[\x90\xde\xa1\x0d\xe9%h\xdf=\xb8" +
+	"\xff\xb3s`\xe9\x89[`\xcb\x82\xef\x16\xbe\xa9\xe3\xcc" +
+	"m\xa9\xb6\xdc\x96\xea\x1d\xea\xf3\xae\x11\x8b64\xd9h" +
+	"Z\x9c\x08UZ\x0cM#i\x14\x1b&\x0b\xe3\x13;" +
+	"\xef\xd3%\x93\x85\x8cK\xa4\xf6(\xf6\xfa\xa3\x97\xfa\xf3" +
+	"\xd7\x87_\xa0=\xe1p\x0f\xd9\x01\x04\xdc\xb6n*." +
+	"\x84uI2SM\xd2\x82)\xa4\xd5\xa4R\x8b\x0ag" +
+	"\xc3\xd8$\x95\x08\xd0y\xe5\x01\x1e\x81`\xe62\xa0\xa7" +
+	"\x15\xf5\xbc0 \xf3t\xf1\x9e\x8bw\x15\xf5\xa2\x90\x92" +
+	"\xa7\x00\xc1\xc2y@\xcf+\xeaUa\xa0\xee\xe4\xa9\x80" +
+	"`\xc5\x0d.+\xeaua\xe01O\x0f\x08\xde\xb8\xb8" +
+	"\xa6\xa8?\x08\xedh\xb3>v1)G\xe0\x14}\x08" +
+	"}\xd0\x86\xe3\xcd\xe6_mv\xf7\xb4\x94G\xc0\x92\"" +
+	"\xbb\xff\xc8\x81.\xdajz%6\xb5Z\x1d\x9c$!" +
+	"$h\xd3j%1\xd9x\x13\x8c\xd8\x09a'\xf8\x7f" +
+	"\xc0\x91\xd8$\xaa\x12\x1d,X\xd8\x11\x1c\xe4a\xda\x91" +
+	"\x9dk\x0ae\x15\xa5a\xb3z#jA\xdc3\xa4>" +
+	"\xf6\xdb\xf0Y?\xa0\x1f+\xeae\xe1>\xe1\x0b\xd7\x9e" +
+	"\xeeq\x09w\x0dW\\|\xae\xa8\xd7\x9c\xa1\xec\x1a\xbe" +
+	"\xeao5T{\x86N{UQ\xbf\x17\x06\xbe\x97\xa7" +
+	"\x0f\x04o\xe7\x00\xbd\xae\xa8?\x09\xbb\xc6Lz\x93\xed" +
+	"\x10\xb6\x83]qd\xca\xec\xb6\x9b\xdb\xa3\x8d\xd9\xef}" +
+	"\xaf\x01\xb2\x1b\xecJ\xa2\xa9\xec\x80\xec\xfc\xff\xcd\xb3c" +
+	"\xf5\x89\xa8|\xb5\xce\x0e\x08;@;i\xd2R3\x9a" +
+	"\xa8\xb2>\x9e\xd6n\x0dg\xd8\xff\xf95\x00\"\\\xc4" +
+	"t"
 
 func init() {
 	schemas.Register(schema_b943b54bf1683782,
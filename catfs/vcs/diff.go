@@ -120,6 +120,8 @@ func MakeDiff(lkrSrc, lkrDst *c.Linker, headSrc, headDst *n.Commit, cfg *SyncOpt
 		return nil, err
 	}
 
+	rsv.limitToPrefixes(cfg.OnlyFolders)
+
 	if err := rsv.resolve(); err != nil {
 		return nil, err
 	}
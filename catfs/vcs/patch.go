@@ -1,6 +1,8 @@
 package vcs
 
 import (
+	"bytes"
+	"encoding/binary"
 	"path"
 	"sort"
 
@@ -9,6 +11,7 @@ import (
 	ie "github.com/sahib/brig/catfs/errors"
 	n "github.com/sahib/brig/catfs/nodes"
 	capnp_patch "github.com/sahib/brig/catfs/vcs/capnp"
+	h "github.com/sahib/brig/util/hashlib"
 	"github.com/sahib/brig/util/trie"
 	log "github.com/sirupsen/logrus"
 	capnp "zombiezen.com/go/capnproto2"
@@ -20,6 +23,59 @@ type Patch struct {
 	FromIndex int64
 	CurrIndex int64
 	Changes   []*Change
+
+	// IsShallow is true if the patch does not contain changes,
+	// but the complete state at CurrIndex (see MakeShallowPatch).
+	IsShallow bool
+
+	// Signature is a detached signature of Digest() made by the
+	// sender of the patch. It is nil for unsigned patches.
+	Signature []byte
+}
+
+// Digest returns a hash over everything in the patch that
+// affects how it is applied. This is what gets signed.
+func (p *Patch) Digest() []byte {
+	buf := &bytes.Buffer{}
+	writeInt := func(v uint64) {
+		binary.Write(buf, binary.BigEndian, v)
+	}
+
+	writeData := func(data []byte) {
+		writeInt(uint64(len(data)))
+		buf.Write(data)
+	}
+
+	writeInt(uint64(p.FromIndex))
+	writeInt(uint64(p.CurrIndex))
+	if p.IsShallow {
+		writeInt(1)
+	} else {
+		writeInt(0)
+	}
+
+	for _, change := range p.Changes {
+		writeInt(uint64(change.Mask))
+		writeData([]byte(change.MovedTo))
+		writeData([]byte(change.WasPreviouslyAt))
+		writeData(change.Head.TreeHash().Bytes())
+		writeData(change.Next.TreeHash().Bytes())
+
+		curr := change.Curr
+		writeInt(uint64(curr.Type()))
+		writeData([]byte(curr.Path()))
+		writeData(curr.TreeHash().Bytes())
+		writeData(curr.ContentHash().Bytes())
+		writeData(curr.BackendHash().Bytes())
+		writeInt(curr.Size())
+
+		if file, ok := curr.(*n.File); ok {
+			writeData(file.Key())
+			writeData([]byte(file.KeyScheme()))
+		}
+	}
+
+	return h.Sum(buf.Bytes()).Bytes()
 }
 
 // Len returns the number of changes in the patch.
@@ -82,6 +138,10 @@ func (p *Patch) ToCapnp() (*capnp.Message, error) {
 
 	capPatch.SetFromIndex(p.FromIndex)
 	capPatch.SetCurrIndex(p.CurrIndex)
+	capPatch.SetIsShallow(p.IsShallow)
+	if err := capPatch.SetSignature(p.Signature); err != nil {
+		return nil, err
+	}
 
 	capChangeLst, err := capnp_patch.NewChange_List(seg, int32(len(p.Changes)))
 	if err != nil {
@@ -119,6 +179,11 @@ func (p *Patch) FromCapnp(msg *capnp.Message) error {
 
 	p.FromIndex = capPatch.FromIndex()
 	p.CurrIndex = capPatch.CurrIndex()
+	p.IsShallow = capPatch.IsShallow()
+	p.Signature, err = capPatch.Signature()
+	if err != nil {
+		return err
+	}
 
 	capChs, err := capPatch.Changes()
 	if err != nil {
@@ -174,6 +239,24 @@ func hasValidPrefix(root *trie.Node, path string) bool {
 	return false
 }
 
+// hasPrefixBelow returns true if one of the prefixes in `root`
+// is located at `path` or somewhere below it.
+func hasPrefixBelow(root *trie.Node, path string) bool {
+	if root.Data != nil && root.Data.(bool) == true {
+		return true
+	}
+
+	curr := root
+	for _, elem := range trie.SplitPath(path) {
+		curr = curr.Lookup(elem)
+		if curr == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
 func filterInvalidMoveGhost(lkr *c.Linker, child n.Node, combCh *Change, prefixTrie *trie.Node) (bool, error) {
 	if child.Type() != n.NodeTypeGhost || combCh.Mask&ChangeTypeMove == 0 {
 		return true, nil
@@ -306,6 +389,71 @@ func MakePatch(lkr *c.Linker, from *n.Commit, prefixes []string) (*Patch, error)
 	return patch, nil
 }
 
+// MakeShallowPatch creates a patch that contains the complete current state
+// of all nodes below `prefixes` as additions. In contrast to MakePatch it does
+// not need any history, so it is cheap to create and can be used for the first
+// fetch or when the history the other side asked for was already pruned.
+func MakeShallowPatch(lkr *c.Linker, prefixes []string) (*Patch, error) {
+	root, err := lkr.Root()
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := lkr.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	patch := &Patch{
+		FromIndex: -1,
+		CurrIndex: status.Index(),
+		IsShallow: true,
+	}
+
+	if len(prefixes) == 0 {
+		prefixes = []string{"/"}
+	}
+	prefixTrie := buildPrefixTrie(prefixes)
+
+	err = n.Walk(lkr, root, false, func(child n.Node) error {
+		if !hasValidPrefix(prefixTrie, path.Dir(child.Path())) {
+			return nil
+		}
+
+		switch child.Type() {
+		case n.NodeTypeGhost:
+			// Removed files are of no interest without history.
+			return nil
+		case n.NodeTypeDirectory:
+			// Non-empty directories are created implicitly.
+			if child.NChildren() > 0 {
+				return nil
+			}
+		}
+
+		childModNode, ok := child.(n.ModNode)
+		if !ok {
+			return e.Wrapf(ie.ErrBadNode, "make-shallow-patch: walk")
+		}
+
+		patch.Changes = append(patch.Changes, &Change{
+			Mask: ChangeTypeAdd,
+			Head: status,
+			Next: status,
+			Curr: childModNode,
+		})
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(patch)
+	return patch, nil
+}
+
 // ApplyPatch applies the patch `p` to the linker `lkr`.
 func ApplyPatch(lkr *c.Linker, p *Patch) error {
 	sort.Sort(p)
@@ -317,5 +465,58 @@ func ApplyPatch(lkr *c.Linker, p *Patch) error {
 		}
 	}
 
+	if p.IsShallow {
+		return removeNodesNotInPatch(lkr, p)
+	}
+
+	return nil
+}
+
+// removeNodesNotInPatch removes all files and directories that are not part
+// of the shallow patch `p`, since the patch describes the complete state.
+func removeNodesNotInPatch(lkr *c.Linker, p *Patch) error {
+	// Also contains all parent directories of the patch's nodes:
+	inPatch := trie.NewNode()
+	for _, change := range p.Changes {
+		inPatch.Insert(change.Curr.Path())
+	}
+
+	root, err := lkr.Root()
+	if err != nil {
+		return err
+	}
+
+	toRemove := []n.ModNode{}
+	err = n.Walk(lkr, root, false, func(child n.Node) error {
+		childPath := child.Path()
+		if child.Type() == n.NodeTypeGhost || childPath == "/" {
+			return nil
+		}
+
+		if inPatch.Lookup(childPath) != nil {
+			return nil
+		}
+
+		childModNode, ok := child.(n.ModNode)
+		if !ok {
+			return e.Wrapf(ie.ErrBadNode, "shallow-patch: walk")
+		}
+
+		// No need to remove anything below a removed directory.
+		toRemove = append(toRemove, childModNode)
+		return n.ErrSkipChild
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, nd := range toRemove {
+		log.Debugf("removing %s since it is not part of the shallow patch", nd.Path())
+		if _, _, err := c.Remove(lkr, nd, true, true); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
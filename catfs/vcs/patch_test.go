@@ -39,6 +39,7 @@ func TestPatchMarshalling(t *testing.T) {
 		patch := &Patch{
 			FromIndex: head.Index(),
 			Changes:   []*Change{change2, change1},
+			Signature: []byte("signature"),
 		}
 
 		msg, err := patch.ToCapnp()
@@ -48,6 +49,11 @@ func TestPatchMarshalling(t *testing.T) {
 		require.Nil(t, newPatch.FromCapnp(msg))
 
 		require.Equal(t, patch, newPatch)
+		require.Equal(t, patch.Digest(), newPatch.Digest())
+
+		// The digest must change with the contents:
+		newPatch.Changes[0].MovedTo = "/something3"
+		require.NotEqual(t, patch.Digest(), newPatch.Digest())
 	})
 }
 
@@ -302,3 +308,58 @@ func TestSyncPartialTwiceWithMovedFile(t *testing.T) {
 		require.Len(t, diff.Ignored, 0)
 	})
 }
+
+func TestMakeShallowPatch(t *testing.T) {
+	c.WithLinkerPair(t, func(lkrSrc, lkrDst *c.Linker) {
+		srcX := c.MustTouch(t, lkrSrc, "/x", 1)
+		srcY := c.MustTouch(t, lkrSrc, "/y", 2)
+		c.MustMkdir(t, lkrSrc, "/sub")
+		c.MustMkdir(t, lkrSrc, "/empty")
+		c.MustTouch(t, lkrSrc, "/sub/z", 3)
+		c.MustCommit(t, lkrSrc, "3 files")
+
+		c.MustModify(t, lkrSrc, srcX, 4)
+		c.MustRemove(t, lkrSrc, srcY)
+		c.MustCommit(t, lkrSrc, "modify and remove")
+
+		// The destination has some state that does not exist in src anymore:
+		c.MustTouch(t, lkrDst, "/y", 2)
+		c.MustMkdir(t, lkrDst, "/stale")
+		c.MustTouch(t, lkrDst, "/stale/file", 5)
+		c.MustCommit(t, lkrDst, "stale")
+
+		patch, err := MakeShallowPatch(lkrSrc, nil)
+		require.Nil(t, err)
+		require.True(t, patch.IsShallow)
+
+		// Only /x, /sub/z and /empty should be in there.
+		require.Len(t, patch.Changes, 3)
+
+		msg, err := patch.ToCapnp()
+		require.Nil(t, err)
+
+		newPatch := &Patch{}
+		require.Nil(t, newPatch.FromCapnp(msg))
+		require.True(t, newPatch.IsShallow)
+
+		require.Nil(t, ApplyPatch(lkrDst, newPatch))
+
+		dstX, err := lkrDst.LookupFile("/x")
+		require.Nil(t, err)
+		require.Equal(t, h.TestDummy(t, 4), dstX.ContentHash())
+
+		dstZ, err := lkrDst.LookupFile("/sub/z")
+		require.Nil(t, err)
+		require.Equal(t, h.TestDummy(t, 3), dstZ.ContentHash())
+
+		_, err = lkrDst.LookupDirectory("/empty")
+		require.Nil(t, err)
+
+		// Everything else is gone:
+		_, err = lkrDst.LookupGhost("/y")
+		require.Nil(t, err)
+
+		_, err = lkrDst.LookupGhost("/stale")
+		require.Nil(t, err)
+	})
+}
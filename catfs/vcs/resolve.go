@@ -57,6 +57,7 @@ import (
 	c "github.com/sahib/brig/catfs/core"
 	ie "github.com/sahib/brig/catfs/errors"
 	n "github.com/sahib/brig/catfs/nodes"
+	"github.com/sahib/brig/util/trie"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -95,6 +96,9 @@ type resolver struct {
 
 	// actual executor based on the decision
 	exec executor
+
+	// if not nil, only pairs below those prefixes are considered.
+	prefixTrie *trie.Node
 }
 
 func newResolver(lkrSrc, lkrDst *c.Linker, srcHead, dstHead *n.Commit, exec executor) (*resolver, error) {
@@ -148,7 +152,80 @@ func (rv *resolver) resolve() error {
 	}
 
 	for _, pair := range mappings {
-		if err := rv.decide(pair); err != nil {
+		if err := rv.decideInPrefix(pair); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// limitToPrefixes makes the resolver ignore all nodes
+// that are not located under one of `prefixes`.
+// An empty list of prefixes means that everything is considered.
+func (rv *resolver) limitToPrefixes(prefixes []string) {
+	if len(prefixes) == 0 {
+		rv.prefixTrie = nil
+		return
+	}
+
+	rv.prefixTrie = buildPrefixTrie(prefixes)
+}
+
+func (rv *resolver) isInPrefix(nd n.ModNode) bool {
+	return nd == nil || hasValidPrefix(rv.prefixTrie, nd.Path())
+}
+
+// decideInPrefix calls decide() for all pairs that are located in one
+// of the prefixes. The mapper reports added or removed directories as
+// a whole, so we need to look into those if they contain a prefix.
+// Pairs that only partly lie in a prefix (i.e. moves from the outside)
+// are ignored, since we may not modify anything outside.
+func (rv *resolver) decideInPrefix(pair MapPair) error {
+	if rv.prefixTrie == nil {
+		return rv.decide(pair)
+	}
+
+	if rv.isInPrefix(pair.Src) && rv.isInPrefix(pair.Dst) {
+		return rv.decide(pair)
+	}
+
+	if pair.Src != nil && pair.Dst != nil {
+		return nil
+	}
+
+	lkr, nd := rv.lkrSrc, pair.Src
+	if nd == nil {
+		lkr, nd = rv.lkrDst, pair.Dst
+	}
+
+	dir, ok := nd.(*n.Directory)
+	if !ok || !hasPrefixBelow(rv.prefixTrie, dir.Path()) {
+		return nil
+	}
+
+	children, err := dir.ChildrenSorted(lkr)
+	if err != nil {
+		return err
+	}
+
+	for _, child := range children {
+		childModNd, ok := child.(n.ModNode)
+		if !ok || child.Type() == n.NodeTypeGhost {
+			continue
+		}
+
+		childPair := MapPair{
+			SrcWasRemoved: pair.SrcWasRemoved,
+		}
+
+		if pair.Src != nil {
+			childPair.Src = childModNd
+		} else {
+			childPair.Dst = childModNd
+		}
+
+		if err := rv.decideInPrefix(childPair); err != nil {
 			return err
 		}
 	}
@@ -172,6 +249,30 @@ func (rv *resolver) cacheLastCommonMerge() error {
 				return err
 			}
 
+			if srcHead == nil {
+				// We merged with them before, but do not know
+				// the commit anymore. If their history was pruned
+				// it would be wrong to compare the full history.
+				srcBase, err := rv.lkrSrc.HistoryBase()
+				if err != nil {
+					return err
+				}
+
+				if srcBase != nil {
+					if rv.srcMergeCmt != nil {
+						// Use the oldest merge we still know.
+						break
+					}
+
+					return e.Wrapf(
+						ie.ErrHistoryPruned,
+						"last merge with %s (%s) is older than their oldest commit",
+						srcOwner,
+						srcRef.B58String(),
+					)
+				}
+			}
+
 			debugf("last merge found: %v = %s", with, srcRef)
 			rv.dstMergeCmt = currHead
 			rv.srcMergeCmt = srcHead
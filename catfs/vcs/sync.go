@@ -66,6 +66,11 @@ type SyncOptions struct {
 	ReadOnlyFolders           map[string]bool
 	ConflictStrategyPerFolder map[string]ConflictStrategy
 
+	// OnlyFolders limits the sync to the given folders (selective sync).
+	// Nodes outside of them are left untouched on our side.
+	// If empty, the whole tree is synced.
+	OnlyFolders []string
+
 	OnAdd      func(newNd n.ModNode) bool
 	OnRemove   func(oldNd n.ModNode) bool
 	OnMerge    func(src, dst n.ModNode) bool
@@ -145,6 +150,7 @@ func (sy *syncer) add(src n.ModNode, srcParent, srcName string) error {
 			newDstFile.SetBackend(sy.lkrDst, srcFile.BackendHash())
 			newDstFile.SetSize(srcFile.Size())
 			newDstFile.SetKey(srcFile.Key())
+			newDstFile.SetKeyScheme(srcFile.KeyScheme())
 		}
 
 		if err := parentDir.Add(sy.lkrDst, newDstFile); err != nil {
@@ -190,7 +196,14 @@ func (sy *syncer) handleAdd(src n.ModNode) error {
 		}
 	}
 
-	return sy.add(src, path.Dir(src.Path()), src.Name())
+	// The parent might not exist yet on our side when
+	// only a part of the tree is synced (see OnlyFolders).
+	srcParent := path.Dir(src.Path())
+	if _, err := c.Mkdir(sy.lkrDst, srcParent, true); err != nil {
+		return err
+	}
+
+	return sy.add(src, srcParent, src.Name())
 }
 
 func (sy *syncer) handleMove(src, dst n.ModNode) error {
@@ -362,6 +375,7 @@ func (sy *syncer) handleMerge(src, dst n.ModNode, srcMask, dstMask ChangeType) e
 	dstFile.SetBackend(sy.lkrDst, srcFile.BackendHash())
 	dstFile.SetSize(srcFile.Size())
 	dstFile.SetKey(srcFile.Key())
+	dstFile.SetKeyScheme(srcFile.KeyScheme())
 
 	if err := dstParent.Add(sy.lkrDst, dstFile); err != nil {
 		return err
@@ -410,6 +424,8 @@ func Sync(lkrSrc, lkrDst *c.Linker, cfg *SyncOptions) error {
 		return err
 	}
 
+	resolver.limitToPrefixes(cfg.OnlyFolders)
+
 	// Make sure the complete sync goes through in one disk transaction.
 	return lkrDst.Atomic(func() (bool, error) {
 		// This calls all the handleXXX() callbacks above.
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	c "github.com/sahib/brig/catfs/core"
+	ie "github.com/sahib/brig/catfs/errors"
 	h "github.com/sahib/brig/util/hashlib"
 	"github.com/stretchr/testify/require"
 )
@@ -380,3 +381,42 @@ func TestSyncReadOnlyFolders(t *testing.T) {
 		require.Equal(t, srcX.ContentHash(), h.TestDummy(t, byte(1)))
 	})
 }
+
+func TestSyncOnlyFolders(t *testing.T) {
+	c.WithLinkerPair(t, func(lkrSrc, lkrDst *c.Linker) {
+		c.MustTouchAndCommit(t, lkrSrc, "/projects/foo/x.png", 1)
+		c.MustTouchAndCommit(t, lkrSrc, "/projects/bar/y.png", 2)
+		c.MustTouchAndCommit(t, lkrSrc, "/z.png", 3)
+
+		// The same file on both sides, but with different content:
+		c.MustTouchAndCommit(t, lkrSrc, "/other.png", 4)
+		c.MustTouchAndCommit(t, lkrDst, "/other.png", 5)
+
+		cfg := &SyncOptions{
+			OnlyFolders: []string{"/projects/foo"},
+		}
+
+		diff, err := MakeDiff(lkrSrc, lkrDst, nil, nil, cfg)
+		require.Nil(t, err)
+		require.Len(t, diff.Added, 1)
+		require.Equal(t, "/projects/foo", diff.Added[0].Path())
+		require.Empty(t, diff.Conflict)
+		require.Empty(t, diff.Missing)
+
+		require.Nil(t, Sync(lkrSrc, lkrDst, cfg))
+
+		dstX, err := lkrDst.LookupFile("/projects/foo/x.png")
+		require.Nil(t, err)
+		require.Equal(t, h.TestDummy(t, 1), dstX.BackendHash())
+
+		// Everything outside of /projects/foo should be untouched:
+		for _, path := range []string{"/projects/bar", "/z.png", "/other.png.conflict.0"} {
+			_, err = lkrDst.LookupNode(path)
+			require.True(t, ie.IsNoSuchFileError(err), path)
+		}
+
+		dstOther, err := lkrDst.LookupFile("/other.png")
+		require.Nil(t, err)
+		require.Equal(t, h.TestDummy(t, 5), dstOther.BackendHash())
+	})
+}
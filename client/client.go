@@ -2,10 +2,13 @@ package client
 
 import (
 	"context"
-	"fmt"
 	"net"
+	"path/filepath"
+	"strconv"
 
+	"github.com/sahib/brig/defaults"
 	"github.com/sahib/brig/server/capnp"
+	"github.com/sahib/brig/util/server"
 	"zombiezen.com/go/capnproto2/rpc"
 )
 
@@ -15,39 +18,77 @@ import (
 type Client struct {
 	ctx     context.Context
 	conn    *rpc.Conn
-	tcpConn net.Conn
+	rawConn net.Conn
 
 	api capnp.API
 }
 
-// Dial will attempt to connect to brigd under the specified port
-func Dial(ctx context.Context, port int) (*Client, error) {
-	addr := fmt.Sprintf("localhost:%d", port)
-	tcpConn, err := net.Dial("tcp", addr)
+// DialOption changes how a connection to the daemon is made.
+type DialOption func(hello *server.Hello)
+
+// WithRepo makes the client use the repository called `name`.
+// The daemon needs to have it configured under daemon.repos.
+// By default, the repository the daemon was started for is used.
+func WithRepo(name string) DialOption {
+	return func(hello *server.Hello) {
+		hello.Repo = name
+	}
+}
+
+// Dial will attempt to connect to the daemon of the repository at `repoPath`
+// over its unix socket.
+func Dial(ctx context.Context, repoPath string, opts ...DialOption) (*Client, error) {
+	socketPath := filepath.Join(repoPath, defaults.DaemonSocketName)
+	conn, err := net.Dial("unix", socketPath)
 	if err != nil {
 		return nil, err
 	}
 
-	transport := rpc.StreamTransport(tcpConn)
+	return newClient(ctx, conn, server.Hello{}, opts)
+}
+
+// DialTCP will attempt to connect to brigd on `host` under the specified port.
+// The daemon needs to have daemon.tcp.enabled set and `token` needs to be
+// the token from its daemon.token file.
+func DialTCP(ctx context.Context, host string, port int, token string, opts ...DialOption) (*Client, error) {
+	conn, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+
+	return newClient(ctx, conn, server.Hello{Token: token}, opts)
+}
+
+func newClient(ctx context.Context, conn net.Conn, hello server.Hello, opts []DialOption) (*Client, error) {
+	for _, opt := range opts {
+		opt(&hello)
+	}
+
+	if err := server.ClientHandshake(conn, hello); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	transport := rpc.StreamTransport(conn)
 	clientConn := rpc.NewConn(transport, rpc.ConnLog(nil))
 	api := capnp.API{Client: clientConn.Bootstrap(ctx)}
 
 	return &Client{
 		ctx:     ctx,
 		conn:    clientConn,
-		tcpConn: tcpConn,
+		rawConn: conn,
 		api:     api,
 	}, nil
 }
 
 // LocalAddr return info about the local addr
 func (cl *Client) LocalAddr() net.Addr {
-	return cl.tcpConn.LocalAddr()
+	return cl.rawConn.LocalAddr()
 }
 
 // RemoteAddr return info about the remote addr
 func (cl *Client) RemoteAddr() net.Addr {
-	return cl.tcpConn.RemoteAddr()
+	return cl.rawConn.RemoteAddr()
 }
 
 // Close will close the connection from the client side
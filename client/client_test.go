@@ -0,0 +1,246 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sahib/brig/defaults"
+	"github.com/sahib/brig/repo"
+	"github.com/sahib/brig/server"
+	"github.com/sahib/brig/util"
+	"github.com/sahib/brig/util/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatAndTarLargeFile(t *testing.T) {
+	withDaemon(t, "ali", func(ctl *Client) {
+		// Big enough to need several chunks:
+		expected := testutil.CreateDummyBuf(3*streamChunkSize + 123)
+		require.Nil(t, ctl.StageFromReader("/big", bytes.NewReader(expected)))
+
+		rc, err := ctl.Cat("/big", false)
+		require.Nil(t, err, stringify(err))
+
+		data, err := ioutil.ReadAll(rc)
+		require.Nil(t, err, stringify(err))
+		require.Nil(t, rc.Close())
+		require.Equal(t, expected, data)
+
+		rc, err = ctl.Tar("/", false)
+		require.Nil(t, err, stringify(err))
+
+		tr := tar.NewReader(rc)
+		hdr, err := tr.Next()
+		require.Nil(t, err, stringify(err))
+		require.Equal(t, "big", strings.TrimPrefix(hdr.Name, "/"))
+
+		data, err = ioutil.ReadAll(tr)
+		require.Nil(t, err, stringify(err))
+		require.Equal(t, expected, data)
+		require.Nil(t, rc.Close())
+
+		// Closing a stream early should not disturb other calls:
+		rc, err = ctl.Cat("/big", false)
+		require.Nil(t, err, stringify(err))
+
+		buf := make([]byte, 10)
+		_, err = io.ReadFull(rc, buf)
+		require.Nil(t, err, stringify(err))
+		require.Nil(t, rc.Close())
+
+		_, err = ctl.Whoami()
+		require.Nil(t, err, stringify(err))
+	})
+}
+
+func TestDialTCP(t *testing.T) {
+	port := util.FindFreePort()
+	repoPath, err := ioutil.TempDir("", "brig-client-repo")
+	require.Nil(t, err)
+
+	defer os.RemoveAll(repoPath)
+
+	require.Nil(t, repo.Init(repoPath, "ali", "no-pass", "mock", int64(port)))
+	require.Nil(t, repo.OverwriteConfigKey(repoPath, "daemon.tcp.enabled", true))
+
+	passwordFn := func() (string, error) {
+		return "no-pass", nil
+	}
+
+	srv, err := server.BootServer(repoPath, passwordFn, "127.0.0.1", port, true)
+	require.Nil(t, err, stringify(err))
+
+	go func() {
+		require.Nil(t, srv.Serve())
+	}()
+
+	defer func() {
+		require.Nil(t, srv.Close())
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+
+	info, err := os.Stat(filepath.Join(repoPath, defaults.DaemonSocketName))
+	require.Nil(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	tokenData, err := ioutil.ReadFile(filepath.Join(repoPath, defaults.DaemonTokenName))
+	require.Nil(t, err)
+	token := strings.TrimSpace(string(tokenData))
+
+	_, err = DialTCP(context.Background(), "127.0.0.1", port, "")
+	require.NotNil(t, err)
+
+	_, err = DialTCP(context.Background(), "127.0.0.1", port, "not-the-token")
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "bad token")
+
+	ctl, err := DialTCP(context.Background(), "127.0.0.1", port, token)
+	require.Nil(t, err, stringify(err))
+	defer ctl.Close()
+
+	whoami, err := ctl.Whoami()
+	require.Nil(t, err, stringify(err))
+	require.Equal(t, "ali", whoami.CurrentUser)
+
+	// The unix socket still works:
+	sockCtl, err := Dial(context.Background(), repoPath)
+	require.Nil(t, err, stringify(err))
+	defer sockCtl.Close()
+
+	_, err = sockCtl.Whoami()
+	require.Nil(t, err, stringify(err))
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	port := util.FindFreePort()
+	metricsPort := util.FindFreePort()
+	repoPath, err := ioutil.TempDir("", "brig-client-repo")
+	require.Nil(t, err)
+
+	defer os.RemoveAll(repoPath)
+
+	require.Nil(t, repo.Init(repoPath, "ali", "no-pass", "mock", int64(port)))
+	require.Nil(t, repo.OverwriteConfigKey(repoPath, "metrics.enabled", true))
+	require.Nil(t, repo.OverwriteConfigKey(repoPath, "metrics.port", int64(metricsPort)))
+
+	passwordFn := func() (string, error) {
+		return "no-pass", nil
+	}
+
+	srv, err := server.BootServer(repoPath, passwordFn, "127.0.0.1", port, true)
+	require.Nil(t, err, stringify(err))
+
+	go func() {
+		require.Nil(t, srv.Serve())
+	}()
+
+	defer func() {
+		require.Nil(t, srv.Close())
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+
+	ctl, err := Dial(context.Background(), repoPath)
+	require.Nil(t, err)
+	defer ctl.Close()
+
+	require.Nil(t, ctl.StageFromReader("/hello", bytes.NewReader([]byte("hello"))))
+	stream, err := ctl.Cat("/hello", false)
+	require.Nil(t, err)
+	_, err = io.Copy(ioutil.Discard, stream)
+	require.Nil(t, err)
+	require.Nil(t, stream.Close())
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/metrics", metricsPort))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Other tests in this package might have staged before,
+	// so only check that the metrics are there at all:
+	body := string(data)
+	require.Contains(t, body, "brig_staged_bytes_total ")
+	require.Contains(t, body, "brig_cat_bytes_total ")
+	require.Contains(t, body, `brig_backend_duration_seconds_count{op="add"}`)
+	require.NotContains(t, body, "brig_staged_bytes_total 0\n")
+}
+
+func TestMultipleRepos(t *testing.T) {
+	port := util.FindFreePort()
+	basePath, err := ioutil.TempDir("", "brig-client-repos")
+	require.Nil(t, err)
+
+	defer os.RemoveAll(basePath)
+
+	aliPath := filepath.Join(basePath, "ali")
+	bobPath := filepath.Join(basePath, "bob")
+	require.Nil(t, repo.Init(aliPath, "ali", "no-pass", "mock", int64(port)))
+	require.Nil(t, repo.Init(bobPath, "bob", "no-pass", "mock", int64(port)))
+	require.Nil(t, repo.OverwriteConfigKey(aliPath, "daemon.repos.work.path", bobPath))
+
+	passwordFn := func() (string, error) {
+		return "no-pass", nil
+	}
+
+	srv, err := server.BootServer(aliPath, passwordFn, "127.0.0.1", port, true)
+	require.Nil(t, err, stringify(err))
+
+	go func() {
+		require.Nil(t, srv.Serve())
+	}()
+
+	defer func() {
+		require.Nil(t, srv.Close())
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+
+	aliCtl, err := Dial(context.Background(), aliPath)
+	require.Nil(t, err)
+	defer aliCtl.Close()
+
+	// Both repositories are reachable over the socket of ali:
+	bobCtl, err := Dial(context.Background(), aliPath, WithRepo("work"))
+	require.Nil(t, err)
+	defer bobCtl.Close()
+
+	// ...and bob also over his own socket:
+	bobSocketCtl, err := Dial(context.Background(), bobPath)
+	require.Nil(t, err)
+	defer bobSocketCtl.Close()
+
+	_, err = Dial(context.Background(), aliPath, WithRepo("nope"))
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "no such repository")
+
+	for owner, ctl := range map[string]*Client{"ali": aliCtl, "bob": bobCtl} {
+		whoami, err := ctl.Whoami()
+		require.Nil(t, err, stringify(err))
+		require.Equal(t, owner, whoami.CurrentUser)
+	}
+
+	// Every repository has its own files:
+	require.Nil(t, bobCtl.StageFromReader("/bob-file", bytes.NewReader([]byte("hello"))))
+
+	exists, err := bobSocketCtl.Exists("/bob-file")
+	require.Nil(t, err)
+	require.True(t, exists)
+
+	exists, err = aliCtl.Exists("/bob-file")
+	require.Nil(t, err)
+	require.False(t, exists)
+}
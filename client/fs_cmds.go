@@ -1,10 +1,8 @@
 package client
 
 import (
-	"fmt"
 	"io"
 	"io/ioutil"
-	"net"
 	"os"
 	"time"
 
@@ -24,6 +22,7 @@ type StatInfo struct {
 	ModTime     time.Time
 	IsPinned    bool
 	IsExplicit  bool
+	KeyScheme   string
 	TreeHash    h.Hash
 	ContentHash h.Hash
 	BackendHash h.Hash
@@ -74,6 +73,11 @@ func convertCapStatInfo(capInfo *capnp.StatInfo) (*StatInfo, error) {
 		return nil, err
 	}
 
+	keyScheme, err := capInfo.KeyScheme()
+	if err != nil {
+		return nil, err
+	}
+
 	info.Path = path
 	info.User = user
 	info.Size = capInfo.Size()
@@ -81,6 +85,7 @@ func convertCapStatInfo(capInfo *capnp.StatInfo) (*StatInfo, error) {
 	info.IsDir = capInfo.IsDir()
 	info.IsPinned = capInfo.IsPinned()
 	info.IsExplicit = capInfo.IsExplicit()
+	info.KeyScheme = keyScheme
 	info.Depth = int(capInfo.Depth())
 
 	info.TreeHash = treeHash
@@ -134,6 +139,30 @@ func (cl *Client) Stage(localPath, repoPath string) error {
 	return err
 }
 
+// ImportArchive stages all files of the tar or zip archive at `localPath`
+// below `repoPath` and commits them with `msg` (a default is used if empty).
+// The number of imported files is returned.
+func (cl *Client) ImportArchive(localPath, repoPath, msg string) (int64, error) {
+	call := cl.api.ImportArchive(cl.ctx, func(p capnp.FS_importArchive_Params) error {
+		if err := p.SetRepoPath(repoPath); err != nil {
+			return err
+		}
+
+		if err := p.SetMessage(msg); err != nil {
+			return err
+		}
+
+		return p.SetLocalPath(localPath)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return 0, err
+	}
+
+	return result.Count(), nil
+}
+
 // StageFromReader will create a new node at `repoPath` from the contents of `r`.
 func (cl *Client) StageFromReader(repoPath string, r io.Reader) error {
 	fd, err := ioutil.TempFile("", "brig-stage-temp")
@@ -163,13 +192,7 @@ func (cl *Client) Cat(path string, offline bool) (io.ReadCloser, error) {
 		return nil, err
 	}
 
-	port := result.Port()
-	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
-	if err != nil {
-		return nil, err
-	}
-
-	return conn, nil
+	return newStreamReader(cl.ctx, result.Stream()), nil
 }
 
 // Tar outputs a tar archive with the contents of `path`.
@@ -185,13 +208,7 @@ func (cl *Client) Tar(path string, offline bool) (io.ReadCloser, error) {
 		return nil, err
 	}
 
-	port := result.Port()
-	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
-	if err != nil {
-		return nil, err
-	}
-
-	return conn, nil
+	return newStreamReader(cl.ctx, result.Stream()), nil
 }
 
 // Mkdir creates a new empty directory at `path`, possibly creating
@@ -370,3 +387,358 @@ func (cl *Client) IsCached(path string) (bool, error) {
 
 	return result.IsCached(), nil
 }
+
+// DiskUsage describes how much storage a directory (or a user) takes.
+type DiskUsage struct {
+	Path         string
+	Files        uint64
+	Logical      uint64
+	Deduplicated uint64
+	Pinned       uint64
+	Cached       uint64
+	Quota        uint64
+}
+
+func convertCapDiskUsages(lst capnp.DiskUsage_List) ([]DiskUsage, error) {
+	usages := []DiskUsage{}
+	for idx := 0; idx < lst.Len(); idx++ {
+		capUsage := lst.At(idx)
+		path, err := capUsage.Path()
+		if err != nil {
+			return nil, err
+		}
+
+		usages = append(usages, DiskUsage{
+			Path:         path,
+			Files:        capUsage.Files(),
+			Logical:      capUsage.Logical(),
+			Deduplicated: capUsage.Deduplicated(),
+			Pinned:       capUsage.Pinned(),
+			Cached:       capUsage.Cached(),
+			Quota:        capUsage.Quota(),
+		})
+	}
+
+	return usages, nil
+}
+
+// DiskUsage returns the usage of `root` and all directories below it
+// up to `maxDepth`. A negative depth lists all directories.
+func (cl *Client) DiskUsage(root string, maxDepth int) ([]DiskUsage, error) {
+	call := cl.api.DiskUsage(cl.ctx, func(p capnp.FS_diskUsage_Params) error {
+		p.SetMaxDepth(int32(maxDepth))
+		return p.SetRoot(root)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	lst, err := result.Usages()
+	if err != nil {
+		return nil, err
+	}
+
+	return convertCapDiskUsages(lst)
+}
+
+// UserUsage returns the usage per user that modified the files last.
+// Path is the user name; Pinned and Cached are not filled.
+func (cl *Client) UserUsage() ([]DiskUsage, error) {
+	call := cl.api.UserUsage(cl.ctx, func(p capnp.FS_userUsage_Params) error {
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	lst, err := result.Usages()
+	if err != nil {
+		return nil, err
+	}
+
+	return convertCapDiskUsages(lst)
+}
+
+// QuotaSet limits the size of the folder at `path` to `size` bytes.
+// A size of 0 removes the quota.
+func (cl *Client) QuotaSet(path string, size uint64) error {
+	call := cl.api.QuotaSet(cl.ctx, func(p capnp.FS_quotaSet_Params) error {
+		p.SetSize(size)
+		return p.SetPath(path)
+	})
+
+	_, err := call.Struct()
+	return err
+}
+
+// Quota is the maximum size of a folder.
+type Quota struct {
+	Path string
+	Size uint64
+}
+
+// QuotaList returns all folder quotas.
+func (cl *Client) QuotaList() ([]Quota, error) {
+	call := cl.api.QuotaList(cl.ctx, func(p capnp.FS_quotaList_Params) error {
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	lst, err := result.Quotas()
+	if err != nil {
+		return nil, err
+	}
+
+	quotas := []Quota{}
+	for idx := 0; idx < lst.Len(); idx++ {
+		capQuota := lst.At(idx)
+		path, err := capQuota.Path()
+		if err != nil {
+			return nil, err
+		}
+
+		quotas = append(quotas, Quota{Path: path, Size: capQuota.Size()})
+	}
+
+	return quotas, nil
+}
+
+// KeySchemeSet sets how keys of new files below the folder at `path` are
+// chosen; "convergent" or "random". An empty `scheme` removes the setting.
+func (cl *Client) KeySchemeSet(path, scheme string) error {
+	call := cl.api.KeySchemeSet(cl.ctx, func(p capnp.FS_keySchemeSet_Params) error {
+		if err := p.SetScheme(scheme); err != nil {
+			return err
+		}
+
+		return p.SetPath(path)
+	})
+
+	_, err := call.Struct()
+	return err
+}
+
+// FolderKeyScheme tells how keys of new files in a folder are chosen.
+type FolderKeyScheme struct {
+	Path   string
+	Scheme string
+}
+
+// KeySchemeList returns all folders with a key scheme setting.
+func (cl *Client) KeySchemeList() ([]FolderKeyScheme, error) {
+	call := cl.api.KeySchemeList(cl.ctx, func(p capnp.FS_keySchemeList_Params) error {
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	lst, err := result.Schemes()
+	if err != nil {
+		return nil, err
+	}
+
+	schemes := []FolderKeyScheme{}
+	for idx := 0; idx < lst.Len(); idx++ {
+		capScheme := lst.At(idx)
+		path, err := capScheme.Path()
+		if err != nil {
+			return nil, err
+		}
+
+		scheme, err := capScheme.Scheme()
+		if err != nil {
+			return nil, err
+		}
+
+		schemes = append(schemes, FolderKeyScheme{Path: path, Scheme: scheme})
+	}
+
+	return schemes, nil
+}
+
+// FsckProblem is a single inconsistency found by Fsck().
+type FsckProblem struct {
+	Kind     string
+	Path     string
+	Hash     h.Hash
+	Detail   string
+	Repaired bool
+}
+
+// Fsck checks the metadata of the repository for consistency.
+// If `deep` is true, the content of all files is read and verified too.
+// If `repair` is true, problems that can be fixed are fixed.
+func (cl *Client) Fsck(deep, repair bool) ([]FsckProblem, error) {
+	call := cl.api.Fsck(cl.ctx, func(p capnp.FS_fsck_Params) error {
+		p.SetDeep(deep)
+		p.SetRepair(repair)
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	lst, err := result.Problems()
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []FsckProblem{}
+	for idx := 0; idx < lst.Len(); idx++ {
+		capProblem := lst.At(idx)
+		problem := FsckProblem{Repaired: capProblem.Repaired()}
+
+		problem.Kind, err = capProblem.Kind()
+		if err != nil {
+			return nil, err
+		}
+
+		problem.Path, err = capProblem.Path()
+		if err != nil {
+			return nil, err
+		}
+
+		problem.Detail, err = capProblem.Detail()
+		if err != nil {
+			return nil, err
+		}
+
+		b58Hash, err := capProblem.Hash()
+		if err != nil {
+			return nil, err
+		}
+
+		if b58Hash != "" {
+			problem.Hash, err = h.FromB58String(b58Hash)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		problems = append(problems, problem)
+	}
+
+	return problems, nil
+}
+
+// ReplicaPolicy requires the files in a folder to be pinned by some remotes.
+type ReplicaPolicy struct {
+	Path        string
+	MinReplicas int
+}
+
+// ReplicaPolicySet requires all files below `path` to be pinned by at least
+// `minReplicas` online remotes. A `minReplicas` of 0 removes the policy.
+func (cl *Client) ReplicaPolicySet(path string, minReplicas int) error {
+	call := cl.api.ReplicaPolicySet(cl.ctx, func(p capnp.FS_replicaPolicySet_Params) error {
+		p.SetMinReplicas(int32(minReplicas))
+		return p.SetPath(path)
+	})
+
+	_, err := call.Struct()
+	return err
+}
+
+// ReplicaPolicyList returns all replica policies.
+func (cl *Client) ReplicaPolicyList() ([]ReplicaPolicy, error) {
+	call := cl.api.ReplicaPolicyList(cl.ctx, func(p capnp.FS_replicaPolicyList_Params) error {
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	lst, err := result.Policies()
+	if err != nil {
+		return nil, err
+	}
+
+	policies := []ReplicaPolicy{}
+	for idx := 0; idx < lst.Len(); idx++ {
+		capPolicy := lst.At(idx)
+		path, err := capPolicy.Path()
+		if err != nil {
+			return nil, err
+		}
+
+		policies = append(policies, ReplicaPolicy{
+			Path:        path,
+			MinReplicas: int(capPolicy.MinReplicas()),
+		})
+	}
+
+	return policies, nil
+}
+
+// ReplicaStatus describes which online remotes have a file pinned.
+type ReplicaStatus struct {
+	Path        string
+	Size        uint64
+	IsPinned    bool
+	MinReplicas int
+	Remotes     []string
+}
+
+// ReplicaStatus returns the replica status of all files below `root`.
+// If `refresh` is true, the pin summaries of all remotes are fetched
+// first; otherwise the ones of the last periodic update are used.
+func (cl *Client) ReplicaStatus(root string, refresh bool) ([]ReplicaStatus, error) {
+	call := cl.api.ReplicaStatus(cl.ctx, func(p capnp.FS_replicaStatus_Params) error {
+		p.SetRefresh(refresh)
+		return p.SetRoot(root)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	lst, err := result.Files()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []ReplicaStatus{}
+	for idx := 0; idx < lst.Len(); idx++ {
+		capStatus := lst.At(idx)
+		path, err := capStatus.Path()
+		if err != nil {
+			return nil, err
+		}
+
+		capRemotes, err := capStatus.Remotes()
+		if err != nil {
+			return nil, err
+		}
+
+		remotes, err := capTextListToStrings(capRemotes)
+		if err != nil {
+			return nil, err
+		}
+
+		statuses = append(statuses, ReplicaStatus{
+			Path:        path,
+			Size:        capStatus.Size(),
+			IsPinned:    capStatus.IsPinned(),
+			MinReplicas: int(capStatus.MinReplicas()),
+			Remotes:     remotes,
+		})
+	}
+
+	return statuses, nil
+}
@@ -3,12 +3,14 @@ package client
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"sort"
 	"testing"
 	"time"
 
+	"github.com/sahib/brig/audit"
 	"github.com/sahib/brig/repo"
 	"github.com/sahib/brig/server"
 	"github.com/sahib/brig/util"
@@ -57,7 +59,7 @@ func withDaemon(t *testing.T, name string, fn func(ctl *Client)) {
 
 	time.Sleep(500 * time.Millisecond)
 
-	ctl, err := Dial(context.Background(), port)
+	ctl, err := Dial(context.Background(), repoPath)
 	require.Nil(t, err)
 
 	defer func() {
@@ -181,6 +183,75 @@ func TestSyncBasic(t *testing.T) {
 	})
 }
 
+func TestSyncJob(t *testing.T) {
+	withDaemonPair(t, "ali", "bob", func(aliCtl, bobCtl *Client) {
+		err := bobCtl.StageFromReader("/bob_file", bytes.NewReader([]byte{23}))
+		require.Nil(t, err, stringify(err))
+
+		ticket, err := aliCtl.SyncStart("bob", true, nil)
+		require.Nil(t, err, stringify(err))
+
+		phases := []string{}
+		err = aliCtl.JobWait(ticket, 10*time.Millisecond, func(status *JobStatus) {
+			require.Equal(t, "sync", status.Kind)
+			phases = append(phases, status.Phase)
+		})
+		require.Nil(t, err, stringify(err))
+		require.Equal(t, "done", phases[len(phases)-1])
+
+		diff, err := aliCtl.SyncResult(ticket)
+		require.Nil(t, err, stringify(err))
+		require.Len(t, diff.Added, 1)
+		require.Equal(t, "/bob_file", diff.Added[0].Path)
+
+		jobs, err := aliCtl.JobList()
+		require.Nil(t, err, stringify(err))
+		require.Len(t, jobs, 1)
+		require.Equal(t, ticket, jobs[0].Ticket)
+		require.True(t, jobs[0].IsDone)
+
+		// Canceling a finished job is fine:
+		require.Nil(t, aliCtl.JobCancel(ticket))
+	})
+}
+
+func TestSyncSubscribedFolders(t *testing.T) {
+	withDaemonPair(t, "ali", "bob", func(aliCtl, bobCtl *Client) {
+		err := bobCtl.StageFromReader("/sub/file", bytes.NewReader([]byte{23}))
+		require.Nil(t, err, stringify(err))
+
+		err = bobCtl.StageFromReader("/other/file", bytes.NewReader([]byte{42}))
+		require.Nil(t, err, stringify(err))
+
+		rmt, err := aliCtl.RemoteByName("bob")
+		require.Nil(t, err, stringify(err))
+
+		rmt.Subscribed = []string{"/sub"}
+		require.Nil(t, aliCtl.RemoteUpdate(rmt))
+
+		rmt, err = aliCtl.RemoteByName("bob")
+		require.Nil(t, err, stringify(err))
+		require.Equal(t, []string{"/sub"}, rmt.Subscribed)
+
+		_, err = aliCtl.Sync("bob", true)
+		require.Nil(t, err, stringify(err))
+
+		_, err = aliCtl.Stat("/sub/file")
+		require.Nil(t, err, stringify(err))
+
+		_, err = aliCtl.Stat("/other/file")
+		require.NotNil(t, err)
+
+		// An explicit list of folders overwrites the subscriptions:
+		ticket, err := aliCtl.SyncStart("bob", false, []string{"/other"})
+		require.Nil(t, err, stringify(err))
+		require.Nil(t, aliCtl.JobWait(ticket, 10*time.Millisecond, nil))
+
+		_, err = aliCtl.Stat("/other/file")
+		require.Nil(t, err, stringify(err))
+	})
+}
+
 func pathsFromListing(l []StatInfo) []string {
 	result := []string{}
 	for _, entry := range l {
@@ -289,6 +360,49 @@ func TestSyncSeveralTimes(t *testing.T) {
 	})
 }
 
+func TestSyncAfterHistoryPrune(t *testing.T) {
+	withDaemonPair(t, "ali", "bob", func(aliCtl, bobCtl *Client) {
+		err := aliCtl.StageFromReader("/ali_file_1", bytes.NewReader([]byte{1}))
+		require.Nil(t, err, stringify(err))
+		require.Nil(t, aliCtl.MakeCommit("first"))
+
+		_, err = bobCtl.Sync("ali", true)
+		require.Nil(t, err, stringify(err))
+
+		for idx := 2; idx < 5; idx++ {
+			path := fmt.Sprintf("/ali_file_%d", idx)
+			err := aliCtl.StageFromReader(path, bytes.NewReader([]byte{byte(idx)}))
+			require.Nil(t, err, stringify(err))
+			require.Nil(t, aliCtl.MakeCommit(path))
+		}
+
+		require.Nil(t, aliCtl.Remove("/ali_file_1"))
+		require.Nil(t, aliCtl.MakeCommit("remove"))
+
+		nPruned, err := aliCtl.HistoryPrune(0, 1)
+		require.Nil(t, err, stringify(err))
+		require.True(t, nPruned > 0)
+
+		// CURR, HEAD and the new base:
+		log, err := aliCtl.Log()
+		require.Nil(t, err, stringify(err))
+		require.Len(t, log, 3)
+
+		// Bob asks for changes since a commit ali does not have anymore.
+		// He should get the complete state instead.
+		_, err = bobCtl.Sync("ali", true)
+		require.Nil(t, err, stringify(err))
+
+		dirs, err := bobCtl.List("/", -1)
+		require.Nil(t, err, stringify(err))
+		require.Equal(
+			t,
+			[]string{"/", "/ali_file_2", "/ali_file_3", "/ali_file_4"},
+			pathsFromListing(dirs),
+		)
+	})
+}
+
 func TestSyncPartial(t *testing.T) {
 	withDaemonPair(t, "ali", "bob", func(aliCtl, bobCtl *Client) {
 		aliWhoami, err := aliCtl.Whoami()
@@ -406,3 +520,33 @@ func TestSyncMovedFile(t *testing.T) {
 		require.Len(t, bobDiffAfter.Moved, 1)
 	})
 }
+
+func TestAuditLog(t *testing.T) {
+	withDaemon(t, "ali", func(ctl *Client) {
+		require.Nil(t, ctl.StageFromReader("/dir/a", bytes.NewReader([]byte{1})))
+		require.Nil(t, ctl.Mkdir("/other", true))
+		require.Nil(t, ctl.Remove("/dir/a"))
+
+		entries, err := ctl.AuditLog(audit.Query{Path: "/dir"})
+		require.Nil(t, err)
+
+		actions := []string{}
+		for _, entry := range entries {
+			require.Equal(t, "ali", entry.User)
+			require.Equal(t, audit.SourceCLI, entry.Source)
+			require.Equal(t, "/dir/a", entry.Path)
+			actions = append(actions, entry.Action)
+		}
+
+		require.Equal(t, []string{"fs.stage", "fs.remove"}, actions)
+
+		entries, err = ctl.AuditLog(audit.Query{Since: time.Now().Add(time.Hour)})
+		require.Nil(t, err)
+		require.Len(t, entries, 0)
+
+		count, head, err := ctl.AuditVerify()
+		require.Nil(t, err)
+		require.True(t, count >= 3)
+		require.NotEmpty(t, head)
+	})
+}
@@ -28,6 +28,89 @@ type Remote struct {
 	AutoUpdate       bool           `yaml:"AutoUpdate"`
 	ConflictStrategy string         `yaml:"ConflictStrategy"`
 	AcceptPush       bool           `yaml:"AcceptPush"`
+	Subscribed       []string       `yaml:"Subscribed,flow"`
+	Quota            uint64         `yaml:"Quota"`
+	UploadLimit      uint64         `yaml:"UploadLimit"`
+	DownloadLimit    uint64         `yaml:"DownloadLimit"`
+
+	// Groups and EffectiveFolders are only informational
+	// and not sent back to the daemon on updates.
+	Groups           []string       `yaml:"-"`
+	EffectiveFolders []RemoteFolder `yaml:"-"`
+}
+
+// RemoteGroup is a named set of remotes that may access the same folders.
+type RemoteGroup struct {
+	Name    string         `yaml:"Name"`
+	Members []string       `yaml:"Members,flow"`
+	Folders []RemoteFolder `yaml:"Folders,flow"`
+}
+
+func capFoldersToFolders(capFolders capnp.RemoteFolder_List) ([]RemoteFolder, error) {
+	folders := []RemoteFolder{}
+	for idx := 0; idx < capFolders.Len(); idx++ {
+		folder := capFolders.At(idx)
+		folderName, err := folder.Folder()
+		if err != nil {
+			return nil, err
+		}
+
+		cs, err := folder.ConflictStrategy()
+		if err != nil {
+			return nil, err
+		}
+
+		folders = append(folders, RemoteFolder{
+			Folder:           folderName,
+			ReadOnly:         folder.ReadOnly(),
+			ConflictStrategy: cs,
+		})
+	}
+
+	return folders, nil
+}
+
+func foldersToCapFolders(folders []RemoteFolder, seg *capnplib.Segment) (*capnp.RemoteFolder_List, error) {
+	capFolders, err := capnp.NewRemoteFolder_List(seg, int32(len(folders)))
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, folder := range folders {
+		capFolder, err := capnp.NewRemoteFolder(seg)
+		if err != nil {
+			return nil, err
+		}
+
+		capFolder.SetReadOnly(folder.ReadOnly)
+		if err := capFolder.SetFolder(folder.Folder); err != nil {
+			return nil, err
+		}
+
+		if err := capFolder.SetConflictStrategy(folder.ConflictStrategy); err != nil {
+			return nil, err
+		}
+
+		if err := capFolders.Set(idx, capFolder); err != nil {
+			return nil, err
+		}
+	}
+
+	return &capFolders, nil
+}
+
+func capTextListToStrings(capLst capnplib.TextList) ([]string, error) {
+	strs := []string{}
+	for idx := 0; idx < capLst.Len(); idx++ {
+		str, err := capLst.At(idx)
+		if err != nil {
+			return nil, err
+		}
+
+		strs = append(strs, str)
+	}
+
+	return strs, nil
 }
 
 func capRemoteToRemote(capRemote capnp.Remote) (*Remote, error) {
@@ -51,24 +134,44 @@ func capRemoteToRemote(capRemote capnp.Remote) (*Remote, error) {
 		return nil, err
 	}
 
-	folders := []RemoteFolder{}
-	for idx := 0; idx < remoteFolders.Len(); idx++ {
-		folder := remoteFolders.At(idx)
-		folderName, err := folder.Folder()
-		if err != nil {
-			return nil, err
-		}
+	folders, err := capFoldersToFolders(remoteFolders)
+	if err != nil {
+		return nil, err
+	}
 
-		cs, err := folder.ConflictStrategy()
+	capEffective, err := capRemote.EffectiveFolders()
+	if err != nil {
+		return nil, err
+	}
+
+	effective, err := capFoldersToFolders(capEffective)
+	if err != nil {
+		return nil, err
+	}
+
+	capGroups, err := capRemote.Groups()
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := capTextListToStrings(capGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	capSubscribed, err := capRemote.SubscribedFolders()
+	if err != nil {
+		return nil, err
+	}
+
+	subscribed := []string{}
+	for idx := 0; idx < capSubscribed.Len(); idx++ {
+		folder, err := capSubscribed.At(idx)
 		if err != nil {
 			return nil, err
 		}
 
-		folders = append(folders, RemoteFolder{
-			Folder:           folderName,
-			ReadOnly:         folder.ReadOnly(),
-			ConflictStrategy: cs,
-		})
+		subscribed = append(subscribed, folder)
 	}
 
 	return &Remote{
@@ -78,6 +181,12 @@ func capRemoteToRemote(capRemote capnp.Remote) (*Remote, error) {
 		AutoUpdate:       capRemote.AcceptAutoUpdates(),
 		AcceptPush:       capRemote.AcceptPush(),
 		ConflictStrategy: conflictStrategy,
+		Subscribed:       subscribed,
+		Quota:            capRemote.Quota(),
+		UploadLimit:      capRemote.UploadLimit(),
+		DownloadLimit:    capRemote.DownloadLimit(),
+		Groups:           groups,
+		EffectiveFolders: effective,
 	}, nil
 }
 
@@ -99,37 +208,35 @@ func remoteToCapRemote(remote Remote, seg *capnplib.Segment) (*capnp.Remote, err
 		return nil, err
 	}
 
-	capFolders, err := capnp.NewRemoteFolder_List(seg, int32(len(remote.Folders)))
+	capFolders, err := foldersToCapFolders(remote.Folders, seg)
 	if err != nil {
 		return nil, err
 	}
 
-	for idx, folder := range remote.Folders {
-		capFolder, err := capnp.NewRemoteFolder(seg)
-		if err != nil {
-			return nil, err
-		}
-
-		capFolder.SetReadOnly(folder.ReadOnly)
-		if err := capFolder.SetFolder(folder.Folder); err != nil {
-			return nil, err
-		}
+	if err := capRemote.SetFolders(*capFolders); err != nil {
+		return nil, err
+	}
 
-		if err := capFolder.SetConflictStrategy(folder.ConflictStrategy); err != nil {
-			return nil, err
-		}
+	capSubscribed, err := capnplib.NewTextList(seg, int32(len(remote.Subscribed)))
+	if err != nil {
+		return nil, err
+	}
 
-		if err := capFolders.Set(idx, capFolder); err != nil {
+	for idx, folder := range remote.Subscribed {
+		if err := capSubscribed.Set(idx, folder); err != nil {
 			return nil, err
 		}
 	}
 
-	if err := capRemote.SetFolders(capFolders); err != nil {
+	if err := capRemote.SetSubscribedFolders(capSubscribed); err != nil {
 		return nil, err
 	}
 
 	capRemote.SetAcceptAutoUpdates(remote.AutoUpdate)
 	capRemote.SetAcceptPush(remote.AcceptPush)
+	capRemote.SetQuota(remote.Quota)
+	capRemote.SetUploadLimit(remote.UploadLimit)
+	capRemote.SetDownloadLimit(remote.DownloadLimit)
 	return &capRemote, nil
 }
 
@@ -174,6 +281,160 @@ func (cl *Client) RemoteByName(name string) (Remote, error) {
 	return *rmt, nil
 }
 
+// RemoteGroupLs lists all remote groups.
+func (cl *Client) RemoteGroupLs() ([]RemoteGroup, error) {
+	call := cl.api.RemoteGroupLs(cl.ctx, func(p capnp.Net_remoteGroupLs_Params) error {
+		return nil
+	})
+
+	res, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capGroups, err := res.Groups()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []RemoteGroup{}
+	for idx := 0; idx < capGroups.Len(); idx++ {
+		capGroup := capGroups.At(idx)
+		name, err := capGroup.Name()
+		if err != nil {
+			return nil, err
+		}
+
+		capMembers, err := capGroup.Members()
+		if err != nil {
+			return nil, err
+		}
+
+		members, err := capTextListToStrings(capMembers)
+		if err != nil {
+			return nil, err
+		}
+
+		capFolders, err := capGroup.Folders()
+		if err != nil {
+			return nil, err
+		}
+
+		folders, err := capFoldersToFolders(capFolders)
+		if err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, RemoteGroup{
+			Name:    name,
+			Members: members,
+			Folders: folders,
+		})
+	}
+
+	return groups, nil
+}
+
+// RemoteGroupSet adds `group` or overwrites the group with the same name.
+func (cl *Client) RemoteGroupSet(group RemoteGroup) error {
+	call := cl.api.RemoteGroupSet(cl.ctx, func(p capnp.Net_remoteGroupSet_Params) error {
+		seg := p.Segment()
+		capGroup, err := capnp.NewRemoteGroup(seg)
+		if err != nil {
+			return err
+		}
+
+		if err := capGroup.SetName(group.Name); err != nil {
+			return err
+		}
+
+		capMembers, err := capnplib.NewTextList(seg, int32(len(group.Members)))
+		if err != nil {
+			return err
+		}
+
+		for idx, member := range group.Members {
+			if err := capMembers.Set(idx, member); err != nil {
+				return err
+			}
+		}
+
+		if err := capGroup.SetMembers(capMembers); err != nil {
+			return err
+		}
+
+		capFolders, err := foldersToCapFolders(group.Folders, seg)
+		if err != nil {
+			return err
+		}
+
+		if err := capGroup.SetFolders(*capFolders); err != nil {
+			return err
+		}
+
+		return p.SetGroup(capGroup)
+	})
+
+	_, err := call.Struct()
+	return err
+}
+
+// RemoteGroupRm removes the group named `name`. Its members stay remotes.
+func (cl *Client) RemoteGroupRm(name string) error {
+	call := cl.api.RemoteGroupRm(cl.ctx, func(p capnp.Net_remoteGroupRm_Params) error {
+		return p.SetName(name)
+	})
+
+	_, err := call.Struct()
+	return err
+}
+
+// RemoteInvite creates an invite code for a new remote that may access `folders`.
+// The code can be used once with RemoteJoin and expires after `timeoutSec`.
+func (cl *Client) RemoteInvite(folders []RemoteFolder, timeoutSec float64) (string, error) {
+	call := cl.api.RemoteInvite(cl.ctx, func(p capnp.Net_remoteInvite_Params) error {
+		capFolders, err := foldersToCapFolders(folders, p.Segment())
+		if err != nil {
+			return err
+		}
+
+		p.SetTimeoutSec(timeoutSec)
+		return p.SetFolders(*capFolders)
+	})
+
+	res, err := call.Struct()
+	if err != nil {
+		return "", err
+	}
+
+	return res.Code()
+}
+
+// RemoteJoin redeems an invite code made by RemoteInvite on another peer.
+// Both sides add each other as remote; the remote we added is returned.
+func (cl *Client) RemoteJoin(code string) (Remote, error) {
+	call := cl.api.RemoteJoin(cl.ctx, func(p capnp.Net_remoteJoin_Params) error {
+		return p.SetCode(code)
+	})
+
+	res, err := call.Struct()
+	if err != nil {
+		return Remote{}, err
+	}
+
+	capRmt, err := res.Remote()
+	if err != nil {
+		return Remote{}, err
+	}
+
+	rmt, err := capRemoteToRemote(capRmt)
+	if err != nil {
+		return Remote{}, err
+	}
+
+	return *rmt, nil
+}
+
 // RemoteUpdate Updates the contents of `remote`.
 func (cl *Client) RemoteUpdate(remote Remote) error {
 	call := cl.api.RemoteUpdate(cl.ctx, func(p capnp.Net_remoteUpdate_Params) error {
@@ -272,6 +533,7 @@ type LocateResult struct {
 	Addr        string
 	Mask        []string
 	Fingerprint string
+	Verified    bool
 }
 
 func capLrToLr(capLr capnp.LocateResult) (*LocateResult, error) {
@@ -300,6 +562,7 @@ func capLrToLr(capLr capnp.LocateResult) (*LocateResult, error) {
 		Name:        name,
 		Mask:        strings.Split(mask, ","),
 		Fingerprint: fingerprint,
+		Verified:    capLr.Verified(),
 	}, nil
 }
 
@@ -380,6 +643,14 @@ type Whoami struct {
 	Owner       string
 	Fingerprint string
 	IsOnline    bool
+	QueueLength uint64
+	QueueBytes  uint64
+
+	// Current throughput and global limits in bytes per second:
+	UploadRate    uint64
+	DownloadRate  uint64
+	UploadLimit   uint64
+	DownloadLimit uint64
 }
 
 // Whoami describes our own identity.
@@ -415,6 +686,12 @@ func (cl *Client) Whoami() (*Whoami, error) {
 	}
 
 	whoami.IsOnline = capWhoami.IsOnline()
+	whoami.QueueLength = capWhoami.QueueLength()
+	whoami.QueueBytes = capWhoami.QueueBytes()
+	whoami.UploadRate = capWhoami.UploadRate()
+	whoami.DownloadRate = capWhoami.DownloadRate()
+	whoami.UploadLimit = capWhoami.UploadLimit()
+	whoami.DownloadLimit = capWhoami.DownloadLimit()
 	return whoami, nil
 }
 
@@ -530,3 +807,19 @@ func (cl *Client) Push(remoteName string, dryRun bool) error {
 	_, err := call.Struct()
 	return err
 }
+
+// PushStart is like Push, but returns immediately with a ticket.
+// The ticket can be passed to JobWait, JobStatus or JobCancel.
+func (cl *Client) PushStart(remoteName string, dryRun bool) (uint64, error) {
+	call := cl.api.PushStart(cl.ctx, func(p capnp.Net_pushStart_Params) error {
+		p.SetDryRun(dryRun)
+		return p.SetRemoteName(remoteName)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return 0, err
+	}
+
+	return result.Ticket(), nil
+}
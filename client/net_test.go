@@ -40,3 +40,45 @@ func TestPush(t *testing.T) {
 
 	})
 }
+
+func TestPushQuota(t *testing.T) {
+	withDaemonPair(t, "ali", "bob", func(aliCtl, bobCtl *Client) {
+		require.Nil(t, aliCtl.StageFromReader("/ali-file", bytes.NewReader([]byte{1, 2, 3})))
+		require.Nil(t, aliCtl.MakeCommit("add"))
+
+		aliRmt, err := bobCtl.RemoteByName("ali")
+		require.Nil(t, err)
+		aliRmt.AcceptPush = true
+		aliRmt.Quota = 2
+		require.Nil(t, bobCtl.RemoteAddOrUpdate(aliRmt))
+
+		err = aliCtl.Push("bob", false)
+		require.NotNil(t, err)
+		require.Contains(t, err.Error(), "quota exceeded")
+
+		_, err = bobCtl.Stat("/ali-file")
+		require.NotNil(t, err)
+
+		// Folder quotas are also respected:
+		aliRmt.Quota = 0
+		require.Nil(t, bobCtl.RemoteAddOrUpdate(aliRmt))
+		require.Nil(t, bobCtl.QuotaSet("/", 2))
+
+		err = aliCtl.Push("bob", false)
+		require.NotNil(t, err)
+		require.Contains(t, err.Error(), "quota exceeded")
+
+		require.Nil(t, bobCtl.QuotaSet("/", 0))
+		require.Nil(t, aliCtl.Push("bob", false))
+
+		time.Sleep(250 * time.Millisecond)
+		_, err = bobCtl.Stat("/ali-file")
+		require.Nil(t, err)
+
+		usages, err := bobCtl.UserUsage()
+		require.Nil(t, err)
+		require.Len(t, usages, 1)
+		require.Equal(t, "ali", usages[0].Path)
+		require.Equal(t, uint64(3), usages[0].Logical)
+	})
+}
@@ -1,6 +1,10 @@
 package client
 
 import (
+	"errors"
+	"time"
+
+	"github.com/sahib/brig/audit"
 	gwdb "github.com/sahib/brig/gateway/db"
 	"github.com/sahib/brig/server/capnp"
 	h "github.com/sahib/brig/util/hashlib"
@@ -446,11 +450,13 @@ type GatewayUser struct {
 	Salt         string
 	Folders      []string
 	Rights       []string
+	FolderRights []string
 }
 
 // GatewayUserAdd adds a new user to the user database.
 // `folders` is a list of directories he may access. It might be empty,
 // in which case he can access everything (same as []string{"/"})
+// Entries like »/incoming:fs.view,!fs.edit« give or take rights per folder.
 func (ctl *Client) GatewayUserAdd(name, password string, folders, rights []string) error {
 	call := ctl.api.GatewayUserAdd(ctl.ctx, func(p capnp.Repo_gatewayUserAdd_Params) error {
 		if err := p.SetName(name); err != nil {
@@ -510,6 +516,115 @@ func (ctl *Client) GatewayUserRemove(name string) error {
 	return err
 }
 
+// GatewayToken is an API token of a gateway user.
+type GatewayToken struct {
+	ID       string
+	User     string
+	Name     string
+	Folders  []string
+	Rights   []string
+	Created  time.Time
+	LastUsed time.Time
+}
+
+// GatewayTokenAdd creates a new API token for the gateway user `user`.
+// `folders` and `rights` restrict what the token may do; if empty, the
+// token can do everything the user can. The secret token is returned.
+func (ctl *Client) GatewayTokenAdd(user, name string, folders, rights []string) (string, error) {
+	call := ctl.api.GatewayTokenAdd(ctl.ctx, func(p capnp.Repo_gatewayTokenAdd_Params) error {
+		if err := p.SetUser(user); err != nil {
+			return err
+		}
+
+		if err := p.SetName(name); err != nil {
+			return err
+		}
+
+		seg := p.Segment()
+		capFolders, err := capnplib.NewTextList(seg, int32(len(folders)))
+		if err != nil {
+			return err
+		}
+
+		for idx, folder := range folders {
+			if err := capFolders.Set(idx, folder); err != nil {
+				return err
+			}
+		}
+
+		if err := p.SetFolders(capFolders); err != nil {
+			return err
+		}
+
+		capRights, err := capnplib.NewTextList(seg, int32(len(rights)))
+		if err != nil {
+			return err
+		}
+
+		for idx, right := range rights {
+			if err := capRights.Set(idx, right); err != nil {
+				return err
+			}
+		}
+
+		return p.SetRights(capRights)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return "", err
+	}
+
+	return result.Token()
+}
+
+// GatewayTokenRemove revokes the token with the id `id`.
+func (ctl *Client) GatewayTokenRemove(id string) error {
+	call := ctl.api.GatewayTokenRm(ctl.ctx, func(p capnp.Repo_gatewayTokenRm_Params) error {
+		return p.SetId(id)
+	})
+
+	_, err := call.Struct()
+	return err
+}
+
+// GatewayTokenList lists the tokens of `user` or of all users if empty.
+func (ctl *Client) GatewayTokenList(user string) ([]GatewayToken, error) {
+	call := ctl.api.GatewayTokenList(ctl.ctx, func(p capnp.Repo_gatewayTokenList_Params) error {
+		return p.SetUser(user)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capTokens, err := result.Tokens()
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := []GatewayToken{}
+	for idx := 0; idx < capTokens.Len(); idx++ {
+		token, err := gwdb.TokenFromCapnp(capTokens.At(idx))
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, GatewayToken{
+			ID:       token.ID,
+			User:     token.User,
+			Name:     token.Name,
+			Folders:  token.Folders,
+			Rights:   token.Rights,
+			Created:  token.Created,
+			LastUsed: token.LastUsed,
+		})
+	}
+
+	return tokens, nil
+}
+
 // GatewayUserList lists all currently existing users.
 func (ctl *Client) GatewayUserList() ([]GatewayUser, error) {
 	call := ctl.api.GatewayUserList(ctl.ctx, func(p capnp.Repo_gatewayUserList_Params) error {
@@ -534,12 +649,18 @@ func (ctl *Client) GatewayUserList() ([]GatewayUser, error) {
 			return nil, err
 		}
 
+		folderRights := []string{}
+		for _, fr := range gwuser.FolderRights {
+			folderRights = append(folderRights, fr.String())
+		}
+
 		users = append(users, GatewayUser{
 			Name:         gwuser.Name,
 			Salt:         gwuser.Salt,
 			PasswordHash: gwuser.PasswordHash,
 			Folders:      gwuser.Folders,
 			Rights:       gwuser.Rights,
+			FolderRights: folderRights,
 		})
 	}
 
@@ -560,3 +681,324 @@ func (ctl *Client) DebugProfilePort() (int, error) {
 
 	return int(result.Port()), nil
 }
+
+// JobStatus is the progress of a long running job in the daemon,
+// like a sync, fetch or push.
+type JobStatus struct {
+	Ticket     uint64
+	Kind       string
+	Remote     string
+	Phase      string
+	BytesDone  int64
+	BytesTotal int64
+	FilesDone  int64
+	FilesTotal int64
+	IsDone     bool
+	Err        string
+}
+
+func capJobStatusToJobStatus(capStatus capnp.JobStatus) (*JobStatus, error) {
+	kind, err := capStatus.Kind()
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := capStatus.Remote()
+	if err != nil {
+		return nil, err
+	}
+
+	phase, err := capStatus.Phase()
+	if err != nil {
+		return nil, err
+	}
+
+	jobErr, err := capStatus.Error()
+	if err != nil {
+		return nil, err
+	}
+
+	return &JobStatus{
+		Ticket:     capStatus.Ticket(),
+		Kind:       kind,
+		Remote:     remote,
+		Phase:      phase,
+		BytesDone:  capStatus.BytesDone(),
+		BytesTotal: capStatus.BytesTotal(),
+		FilesDone:  capStatus.FilesDone(),
+		FilesTotal: capStatus.FilesTotal(),
+		IsDone:     capStatus.IsDone(),
+		Err:        jobErr,
+	}, nil
+}
+
+// JobStatus returns the current progress of the job with `ticket`.
+func (ctl *Client) JobStatus(ticket uint64) (*JobStatus, error) {
+	call := ctl.api.JobStatus(ctl.ctx, func(p capnp.Repo_jobStatus_Params) error {
+		p.SetTicket(ticket)
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capStatus, err := result.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	return capJobStatusToJobStatus(capStatus)
+}
+
+// JobCancel asks the daemon to stop the job with `ticket`.
+// The job will stop at the next possible point, so it might still
+// take a moment until it is reported as done.
+func (ctl *Client) JobCancel(ticket uint64) error {
+	call := ctl.api.JobCancel(ctl.ctx, func(p capnp.Repo_jobCancel_Params) error {
+		p.SetTicket(ticket)
+		return nil
+	})
+
+	_, err := call.Struct()
+	return err
+}
+
+// JobList returns all jobs that are running or finished recently.
+func (ctl *Client) JobList() ([]JobStatus, error) {
+	call := ctl.api.JobList(ctl.ctx, func(p capnp.Repo_jobList_Params) error {
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capJobs, err := result.Jobs()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := []JobStatus{}
+	for idx := 0; idx < capJobs.Len(); idx++ {
+		job, err := capJobStatusToJobStatus(capJobs.At(idx))
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, *job)
+	}
+
+	return jobs, nil
+}
+
+// JobWait polls the status of the job with `ticket` every `interval`
+// until it is done. `fn` is called with every polled status and may be nil.
+// If the job failed, its error is returned.
+func (ctl *Client) JobWait(ticket uint64, interval time.Duration, fn func(status *JobStatus)) error {
+	for {
+		status, err := ctl.JobStatus(ticket)
+		if err != nil {
+			return err
+		}
+
+		if fn != nil {
+			fn(status)
+		}
+
+		if status.IsDone {
+			if status.Err != "" {
+				return errors.New(status.Err)
+			}
+
+			return nil
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// BackupManifest is a summary of a backup bundle.
+type BackupManifest struct {
+	ID          string
+	Parent      string
+	Owner       string
+	Created     time.Time
+	WithContent bool
+	Blobs       int64
+	NewBlobs    int64
+}
+
+// BackupCreate makes the daemon write a backup bundle to `path`.
+// If `parentPath` is not empty, it should point to a previous bundle;
+// only content not in there will be included then.
+// If `withContent` is true, all pinned content is included.
+func (ctl *Client) BackupCreate(path, parentPath string, withContent bool) (*BackupManifest, error) {
+	call := ctl.api.BackupCreate(ctl.ctx, func(p capnp.Repo_backupCreate_Params) error {
+		p.SetWithContent(withContent)
+		if err := p.SetParentPath(parentPath); err != nil {
+			return err
+		}
+
+		return p.SetPath(path)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capManifest, err := result.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &BackupManifest{
+		WithContent: capManifest.WithContent(),
+		Blobs:       capManifest.Blobs(),
+		NewBlobs:    capManifest.NewBlobs(),
+	}
+
+	if manifest.ID, err = capManifest.Id(); err != nil {
+		return nil, err
+	}
+
+	if manifest.Parent, err = capManifest.Parent(); err != nil {
+		return nil, err
+	}
+
+	if manifest.Owner, err = capManifest.Owner(); err != nil {
+		return nil, err
+	}
+
+	created, err := capManifest.Created()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := manifest.Created.UnmarshalText([]byte(created)); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// AuditLog returns all entries of the audit log that match `query`.
+func (ctl *Client) AuditLog(query audit.Query) ([]audit.Entry, error) {
+	call := ctl.api.AuditLog(ctl.ctx, func(p capnp.Repo_auditLog_Params) error {
+		if err := p.SetUser(query.User); err != nil {
+			return err
+		}
+
+		if err := p.SetPath(query.Path); err != nil {
+			return err
+		}
+
+		if err := p.SetAction(query.Action); err != nil {
+			return err
+		}
+
+		if query.Since.IsZero() {
+			return nil
+		}
+
+		since, err := query.Since.MarshalText()
+		if err != nil {
+			return err
+		}
+
+		return p.SetSince(string(since))
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capEntries, err := result.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []audit.Entry{}
+	for idx := 0; idx < capEntries.Len(); idx++ {
+		entry, err := auditEntryFromCapnp(capEntries.At(idx))
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, *entry)
+	}
+
+	return entries, nil
+}
+
+func auditEntryFromCapnp(capEntry capnp.AuditEntry) (*audit.Entry, error) {
+	var err error
+	entry := &audit.Entry{Seq: capEntry.Seq()}
+
+	if entry.Source, err = capEntry.Source(); err != nil {
+		return nil, err
+	}
+
+	if entry.User, err = capEntry.User(); err != nil {
+		return nil, err
+	}
+
+	if entry.Addr, err = capEntry.Addr(); err != nil {
+		return nil, err
+	}
+
+	if entry.Action, err = capEntry.Action(); err != nil {
+		return nil, err
+	}
+
+	if entry.Path, err = capEntry.Path(); err != nil {
+		return nil, err
+	}
+
+	if entry.Detail, err = capEntry.Detail(); err != nil {
+		return nil, err
+	}
+
+	if entry.Prev, err = capEntry.Prev(); err != nil {
+		return nil, err
+	}
+
+	if entry.Hash, err = capEntry.Hash(); err != nil {
+		return nil, err
+	}
+
+	enTime, err := capEntry.Time()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := entry.Time.UnmarshalText([]byte(enTime)); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// AuditVerify checks the hash chain of the audit log. It returns the number
+// of entries and the hash of the last entry.
+func (ctl *Client) AuditVerify() (uint64, string, error) {
+	call := ctl.api.AuditVerify(ctl.ctx, func(p capnp.Repo_auditVerify_Params) error {
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return 0, "", err
+	}
+
+	head, err := result.Head()
+	if err != nil {
+		return 0, "", err
+	}
+
+	return result.Count(), head, nil
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"io"
+
+	"github.com/sahib/brig/server/capnp"
+)
+
+const (
+	// streamChunkSize is how much data is requested from the daemon at once.
+	streamChunkSize = 256 * 1024
+)
+
+// streamReader reads the data of a stream sent by the daemon
+// over the same connection that is used for the api.
+type streamReader struct {
+	ctx    context.Context
+	stream capnp.Stream
+	buf    []byte
+	isEOF  bool
+}
+
+func newStreamReader(ctx context.Context, stream capnp.Stream) io.ReadCloser {
+	return &streamReader{ctx: ctx, stream: stream}
+}
+
+func (sr *streamReader) Read(buf []byte) (int, error) {
+	if len(sr.buf) == 0 {
+		if sr.isEOF {
+			return 0, io.EOF
+		}
+
+		call := sr.stream.Read(sr.ctx, func(p capnp.Stream_read_Params) error {
+			p.SetSize(streamChunkSize)
+			return nil
+		})
+
+		result, err := call.Struct()
+		if err != nil {
+			return 0, err
+		}
+
+		data, err := result.Data()
+		if err != nil {
+			return 0, err
+		}
+
+		// The daemon sends an empty chunk at the end of the stream.
+		if len(data) == 0 {
+			sr.isEOF = true
+			return 0, io.EOF
+		}
+
+		// data belongs to the rpc message, which might be reused.
+		sr.buf = append([]byte{}, data...)
+	}
+
+	n := copy(buf, sr.buf)
+	sr.buf = sr.buf[n:]
+	return n, nil
+}
+
+// Close releases the stream, which makes the daemon stop sending data.
+func (sr *streamReader) Close() error {
+	return sr.stream.Client.Close()
+}
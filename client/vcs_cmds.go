@@ -6,6 +6,7 @@ import (
 
 	"github.com/sahib/brig/server/capnp"
 	h "github.com/sahib/brig/util/hashlib"
+	capnplib "zombiezen.com/go/capnproto2"
 )
 
 // MakeCommit creates a new commit from the current staging area.
@@ -21,10 +22,11 @@ func (ctl *Client) MakeCommit(msg string) error {
 
 // Commit describes a single commit in more detail.
 type Commit struct {
-	Hash h.Hash
-	Msg  string
-	Tags []string
-	Date time.Time
+	Hash      h.Hash
+	Msg       string
+	Tags      []string
+	Date      time.Time
+	Signature string
 }
 
 func convertCapCommit(capEntry *capnp.Commit) (*Commit, error) {
@@ -64,6 +66,11 @@ func convertCapCommit(capEntry *capnp.Commit) (*Commit, error) {
 	}
 
 	result.Tags = tags
+	result.Signature, err = capEntry.Signature()
+	if err != nil {
+		return nil, err
+	}
+
 	return &result, nil
 }
 
@@ -447,6 +454,78 @@ func (ctl *Client) Sync(remote string, needFetch bool) (*Diff, error) {
 	return convertCapDiffToDiff(capDiff)
 }
 
+// FetchStart is like Fetch, but returns immediately with a ticket.
+// The ticket can be passed to JobWait, JobStatus or JobCancel.
+func (ctl *Client) FetchStart(remote string) (uint64, error) {
+	call := ctl.api.FetchStart(ctl.ctx, func(p capnp.VCS_fetchStart_Params) error {
+		return p.SetWho(remote)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return 0, err
+	}
+
+	return result.Ticket(), nil
+}
+
+// SyncStart is like Sync, but returns immediately with a ticket.
+// The ticket can be passed to JobWait, JobStatus or JobCancel.
+// Once the job is done, the diff can be fetched by SyncResult.
+//
+// If `onlyFolders` is not empty, only those folders of the remote are synced.
+// Otherwise the subscribed folders of the remote are used (if any).
+func (ctl *Client) SyncStart(remote string, needFetch bool, onlyFolders []string) (uint64, error) {
+	call := ctl.api.SyncStart(ctl.ctx, func(p capnp.VCS_syncStart_Params) error {
+		p.SetNeedFetch(needFetch)
+
+		capFolders, err := capnplib.NewTextList(p.Segment(), int32(len(onlyFolders)))
+		if err != nil {
+			return err
+		}
+
+		for idx, folder := range onlyFolders {
+			if err := capFolders.Set(idx, folder); err != nil {
+				return err
+			}
+		}
+
+		if err := p.SetOnlyFolders(capFolders); err != nil {
+			return err
+		}
+
+		return p.SetWithWhom(remote)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return 0, err
+	}
+
+	return result.Ticket(), nil
+}
+
+// SyncResult returns the diff of a successfully finished sync job.
+// It may only be called once per ticket.
+func (ctl *Client) SyncResult(ticket uint64) (*Diff, error) {
+	call := ctl.api.SyncResult(ctl.ctx, func(p capnp.VCS_syncResult_Params) error {
+		p.SetTicket(ticket)
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capDiff, err := result.Diff()
+	if err != nil {
+		return nil, err
+	}
+
+	return convertCapDiffToDiff(capDiff)
+}
+
 // CommitInfo is like a stat(2) for commits.
 func (ctl *Client) CommitInfo(rev string) (bool, *Commit, error) {
 	call := ctl.api.CommitInfo(ctl.ctx, func(p capnp.VCS_commitInfo_Params) error {
@@ -474,3 +553,22 @@ func (ctl *Client) CommitInfo(rev string) (bool, *Commit, error) {
 
 	return true, cmt, nil
 }
+
+// HistoryPrune squashes all commits older than `keep` and not within the
+// last `keepLast` commits into one base commit. Zero values disable the
+// respective criteria. Tagged commits are always kept.
+// It returns the number of removed commits.
+func (ctl *Client) HistoryPrune(keep time.Duration, keepLast int) (int, error) {
+	call := ctl.api.HistoryPrune(ctl.ctx, func(p capnp.VCS_historyPrune_Params) error {
+		p.SetKeepSeconds(int64(keep / time.Second))
+		p.SetKeepLast(int64(keepLast))
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(result.Pruned()), nil
+}
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"bytes"
-	"context"
 	"fmt"
 	"net/url"
 	"os"
@@ -10,7 +9,6 @@ import (
 	"strings"
 
 	"github.com/fatih/color"
-	"github.com/sahib/brig/client"
 	"github.com/sahib/brig/version"
 	"github.com/toqueteos/webbrowser"
 	"github.com/urfave/cli"
@@ -68,8 +66,7 @@ Please include anything else you think is helpful. Thanks!
 		version.BuildTime,
 	)
 
-	port := guessPort(ctx, false)
-	ctl, err := client.Dial(context.Background(), port)
+	ctl, err := dialDaemon(ctx, guessRepoFolder(ctx))
 	if err == nil {
 		// Try to get the server side / ipfs version.
 		version, err := ctl.Version()
@@ -519,8 +519,10 @@ func handleShowFileOrDir(ctx *cli.Context, ctl *client.Client, path string) erro
 
 	if !info.IsDir {
 		printPair("Backend Hash", info.BackendHash.B58String())
+		printPair("Key Scheme", info.KeyScheme)
 	} else {
 		printPair("Backend Hash", "-")
+		printPair("Key Scheme", "-")
 	}
 
 	return tabW.Flush()
@@ -563,6 +565,26 @@ func handleEdit(ctx *cli.Context, ctl *client.Client) error {
 	return ctl.Stage(tempPath, repoPath)
 }
 
+func handleImport(ctx *cli.Context, ctl *client.Client) error {
+	localPath, err := filepath.Abs(ctx.Args().First())
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve absolute path: %v", err)
+	}
+
+	repoPath := "/"
+	if ctx.NArg() > 1 {
+		repoPath = ctx.Args().Get(1)
+	}
+
+	count, err := ctl.ImportArchive(localPath, repoPath, ctx.String("message"))
+	if err != nil {
+		return ExitCode{UnknownError, fmt.Sprintf("import: %v", err)}
+	}
+
+	fmt.Printf("Imported %d files to %s.\n", count, repoPath)
+	return nil
+}
+
 func handleTouch(ctx *cli.Context, ctl *client.Client) error {
 	repoPath := ctx.Args().First()
 	return ctl.Touch(repoPath)
@@ -589,3 +611,183 @@ func handleTrashList(ctx *cli.Context, ctl *client.Client) error {
 func handleTrashRemove(ctx *cli.Context, ctl *client.Client) error {
 	return ctl.Undelete(ctx.Args().First())
 }
+
+func formatQuota(quota uint64) string {
+	if quota == 0 {
+		return "-"
+	}
+
+	return humanize.Bytes(quota)
+}
+
+func handleDiskUsage(ctx *cli.Context, ctl *client.Client) error {
+	var usages []client.DiskUsage
+	var err error
+
+	nameColumn := "PATH"
+	if ctx.Bool("users") {
+		nameColumn = "USER"
+		usages, err = ctl.UserUsage()
+	} else {
+		maxDepth := ctx.Int("depth")
+		if ctx.Bool("recursive") {
+			maxDepth = -1
+		}
+
+		root := "/"
+		if ctx.Args().Present() {
+			root = ctx.Args().First()
+		}
+
+		usages, err = ctl.DiskUsage(root, maxDepth)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	if ctx.Bool("users") {
+		fmt.Fprintf(tabW, "%s\tFILES\tSIZE\tDEDUP\tQUOTA\t\n", nameColumn)
+	} else {
+		fmt.Fprintf(tabW, "%s\tFILES\tSIZE\tDEDUP\tPINNED\tCACHED\tQUOTA\t\n", nameColumn)
+	}
+
+	for _, usage := range usages {
+		quota := formatQuota(usage.Quota)
+		if usage.Quota > 0 && usage.Logical > usage.Quota {
+			quota = color.RedString(quota)
+		}
+
+		if ctx.Bool("users") {
+			fmt.Fprintf(
+				tabW,
+				"%s\t%d\t%s\t%s\t%s\t\n",
+				color.GreenString(usage.Path),
+				usage.Files,
+				humanize.Bytes(usage.Logical),
+				humanize.Bytes(usage.Deduplicated),
+				quota,
+			)
+
+			continue
+		}
+
+		fmt.Fprintf(
+			tabW,
+			"%s\t%d\t%s\t%s\t%s\t%s\t%s\t\n",
+			color.GreenString(usage.Path),
+			usage.Files,
+			humanize.Bytes(usage.Logical),
+			humanize.Bytes(usage.Deduplicated),
+			humanize.Bytes(usage.Pinned),
+			humanize.Bytes(usage.Cached),
+			quota,
+		)
+	}
+
+	return tabW.Flush()
+}
+
+func handleQuotaSet(ctx *cli.Context, ctl *client.Client) error {
+	size, err := humanize.ParseBytes(ctx.Args().Get(1))
+	if err != nil {
+		return ExitCode{
+			BadArgs,
+			fmt.Sprintf("invalid size: %v", err),
+		}
+	}
+
+	if size == 0 {
+		return ExitCode{
+			BadArgs,
+			"the quota has to be bigger than zero; use »brig quota rm« to remove it",
+		}
+	}
+
+	return ctl.QuotaSet(ctx.Args().First(), size)
+}
+
+func handleQuotaRemove(ctx *cli.Context, ctl *client.Client) error {
+	for _, folder := range ctx.Args() {
+		if err := ctl.QuotaSet(folder, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func handleQuotaList(ctx *cli.Context, ctl *client.Client) error {
+	quotas, err := ctl.QuotaList()
+	if err != nil {
+		return err
+	}
+
+	if len(quotas) == 0 {
+		fmt.Println("No quotas yet. Use »brig quota set« to add one.")
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintf(tabW, "FOLDER\tQUOTA\t\n")
+	for _, quota := range quotas {
+		fmt.Fprintf(tabW, "%s\t%s\t\n", color.GreenString(quota.Path), humanize.Bytes(quota.Size))
+	}
+
+	return tabW.Flush()
+}
+
+func handleEncryptionSet(ctx *cli.Context, ctl *client.Client) error {
+	scheme := ctx.Args().Get(1)
+	if scheme != "convergent" && scheme != "random" {
+		return ExitCode{
+			BadArgs,
+			fmt.Sprintf("invalid key scheme: %s; use »convergent« or »random«", scheme),
+		}
+	}
+
+	return ctl.KeySchemeSet(ctx.Args().First(), scheme)
+}
+
+func handleEncryptionRemove(ctx *cli.Context, ctl *client.Client) error {
+	for _, folder := range ctx.Args() {
+		if err := ctl.KeySchemeSet(folder, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func handleEncryptionList(ctx *cli.Context, ctl *client.Client) error {
+	schemes, err := ctl.KeySchemeList()
+	if err != nil {
+		return err
+	}
+
+	if len(schemes) == 0 {
+		fmt.Println("All files use convergent keys. Use »brig encryption set« to change that.")
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintf(tabW, "FOLDER\tSCHEME\t\n")
+	for _, scheme := range schemes {
+		fmt.Fprintf(tabW, "%s\t%s\t\n", color.GreenString(scheme.Path), scheme.Scheme)
+	}
+
+	return tabW.Flush()
+}
@@ -181,6 +181,63 @@ EXAMPLES:
 		Complete:    completeArgsUsage,
 		Description: "Remove a remote by name.",
 	},
+	"remote.invite": {
+		Usage:    "Create a one-time code another peer can use to become a remote.",
+		Complete: completeArgsUsage,
+		Flags: []cli.Flag{
+			cli.StringSliceFlag{
+				Name:  "folder,f",
+				Usage: "Configure the folders the new remote may see. Can be given more than once. If the first letter of the folder is »-« it is added as read-only.",
+			},
+			cli.BoolFlag{
+				Name:  "read-only,r",
+				Usage: "Add all folders as read-only.",
+			},
+			cli.StringFlag{
+				Name:  "timeout,t",
+				Value: "24h",
+				Usage: "How long the invite is valid.",
+			},
+			cli.StringFlag{
+				Name:  "output,o",
+				Usage: "Write the invite to a file instead of printing it.",
+			},
+		},
+		Description: `
+   Instead of exchanging names and fingerprints and adding each other with
+   »brig remote add« on both sides, you can create an invite and pass it to the
+   other side (e.g. by mail or chat). The other side redeems it with
+   »brig remote join«. Both sides then add each other as remote with the
+   folders given here. The new remote is subscribed to those folders.
+
+   The invite is valid only once and only until the timeout passed. Anyone who
+   gets hold of it before can become your remote, so pass it on a trusted way.
+
+EXAMPLES:
+
+   # Share /photos with a friend who may not change anything in it:
+   $ brig remote invite --folder /photos --read-only
+   brig-invite:eyJuYW1lIjoiYWxp...
+`,
+	},
+	"remote.join": {
+		Usage:     "Become a remote of another peer with an invite code.",
+		ArgsUsage: "<code|file>",
+		Complete:  completeArgsUsage,
+		Description: `
+   Redeem an invite made with »brig remote invite« by another peer. Both sides
+   exchange their fingerprints over an authenticated connection and add each
+   other as remote with the folders of the invite. The invite may also be given
+   as file written by »brig remote invite --output«.
+
+   Both peers need to be online for this.
+
+EXAMPLES:
+
+   $ brig remote join brig-invite:eyJuYW1lIjoiYWxp...
+   Added »ali« as remote. Shared folders: /photos
+`,
+	},
 	"remote.list": {
 		Usage:    "List all remotes and their online status",
 		Complete: completeArgsUsage,
@@ -203,6 +260,8 @@ EXAMPLES:
 	   * .Name
 	   * .Fingerprint
 	   * .Folders
+	   * .EffectiveFolders (including the folders of groups)
+	   * .Groups
 	   * .AutoUpdate
 
    The syntax of the template is borrowed from Go. You can read about the details here:
@@ -315,6 +374,7 @@ EXAMPLES:
    By default every remote is allowed to see all of your folders.
    You might want to share only specific folders with certain remotes.
    By adding folders to this list, you're limiting the nodes other remotes can see.
+   Folders can also be granted to several remotes at once with »brig rmt group«.
 
    If you do not specify any subcommand, this is a shortcut for »brig rmt f ls«`,
 	},
@@ -379,6 +439,175 @@ EXAMPLES:
 		Complete:    completeArgsUsage,
 		Description: ``,
 	},
+	"remote.group": {
+		Usage:    "Share folders with several remotes at once.",
+		Complete: completeArgsUsage,
+		Description: `
+   A group is a named set of remotes that may access the same folders.
+   Every member may access the folders of all of its groups in addition
+   to the folders configured with »brig remote folder«. Settings of the
+   remote itself take precedence. If several groups grant the same folder,
+   it is only read-only if all of them say so.
+
+   Note that a remote without any folders of its own may access all folders.
+   This does not change when it becomes member of a group.
+
+   If you do not specify any subcommand, this is a shortcut for »brig rmt g ls«
+
+EXAMPLES:
+
+   # Share /team-docs with all colleagues:
+   $ brig remote group add team bob charlie dave
+   $ brig remote group folder add team /team-docs
+`,
+	},
+	"remote.group.add": {
+		Usage:     "Create a group or add remotes to it.",
+		ArgsUsage: "<group> [<remote>...]",
+		Complete:  completeArgsUsage,
+		Description: `
+   The group is created if it does not exist yet. All remotes need to be in
+   the remote list already.
+
+EXAMPLES:
+
+   $ brig remote group add team bob charlie
+`,
+	},
+	"remote.group.remove": {
+		Usage:     "Remove remotes from a group or the group itself.",
+		ArgsUsage: "<group> [<remote>...]",
+		Complete:  completeArgsUsage,
+		Description: `
+   If no remotes are given, the whole group is removed. The remotes
+   themselves stay in the remote list, but lose the folders of the group.
+
+EXAMPLES:
+
+   $ brig remote group remove team charlie  # charlie left the team.
+   $ brig remote group remove team          # The team does not exist anymore.
+`,
+	},
+	"remote.group.list": {
+		Usage:       "List all groups with their members and folders.",
+		Complete:    completeArgsUsage,
+		Description: ``,
+	},
+	"remote.group.folder": {
+		Usage:       "Configure what folders the members of a group may see.",
+		Complete:    completeArgsUsage,
+		Description: ``,
+	},
+	"remote.group.folder.add": {
+		Usage:     "Add one or several folders to a group.",
+		ArgsUsage: "<group> <folder>...",
+		Complete:  completeArgsUsage,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "read-only,r",
+				Usage: "Add the folder as read-only.",
+			},
+			cli.StringFlag{
+				Name:  "conflict-strategy,c",
+				Usage: "What conflict strategy to use for this specific folder. Overwrites per-remote conflict strategy.",
+				Value: "",
+			},
+		},
+		Description: `This works like »brig remote folder add«, but for all members of the group.
+
+EXAMPLES:
+
+   $ brig remote group folder add team /handbook --read-only
+`,
+	},
+	"remote.group.folder.remove": {
+		Usage:       "Remove one or several folders from a group.",
+		ArgsUsage:   "<group> <folder>...",
+		Complete:    completeArgsUsage,
+		Description: ``,
+	},
+	"remote.quota": {
+		Usage:     "Show or change the storage quota of a remote.",
+		ArgsUsage: "<remote> [<size>|none]",
+		Complete:  completeArgsUsage,
+		Description: `
+   The quota limits how much storage the files modified last by this remote may
+   take in our tree. A sync with this remote (including syncs triggered by
+   »brig push« from their side) fails before merging anything when it would
+   exceed the quota. The size is given in a human readable form like »10G«.
+
+   If no size is given, the current quota is shown. Pass »none« to remove it.
+
+EXAMPLES:
+
+   # Do not let bob fill up our disk with more than 5GB:
+   $ brig remote quota bob 5G
+`,
+	},
+	"remote.bandwidth": {
+		Usage:     "Show or change the bandwidth limits for a remote.",
+		ArgsUsage: "<remote> [<rate>|<up>/<down>|unlimited]",
+		Complete:  completeArgsUsage,
+		Description: `
+   Limit how fast we send data to (up) and receive data from (down) this remote.
+   A single rate applies to both directions. Rates are given in a human
+   readable form like »1MB« (per second). These limits apply all the time and
+   in addition to the global limits in »net.bandwidth.*«.
+
+   If no rate is given, the current limits are shown. Pass »unlimited« to
+   remove them.
+
+   Limits apply to the connections to other peers (i.e. fetching metadata
+   and syncing). The global download limit also applies to content that is
+   downloaded when pinning and pre-caching. Content the backend transfers on
+   its own (like reading files that are not cached yet, or serving content
+   to other nodes of the backend's network) is not limited by brig.
+
+EXAMPLES:
+
+   # Do not send more than 512KB/s to bob, but receive as fast as possible:
+   $ brig remote bandwidth bob 512KB/unlimited
+`,
+	},
+	"remote.subscribe": {
+		Usage:    "Configure what folders of a remote we sync.",
+		Complete: completeArgsUsage,
+		Description: `
+   By default »brig sync« merges the complete tree of a remote.
+   If you only want parts of it (because it is too large for example),
+   you can subscribe to specific folders. Only those folders will be synced
+   and shown by »brig diff«, everything else is left untouched.
+
+   If you do not specify any subcommand, this is a shortcut for »brig rmt sub ls«`,
+	},
+	"remote.subscribe.add": {
+		Usage:     "Subscribe to one or more folders of a remote.",
+		ArgsUsage: "<remote> <folder> [<folder>...]",
+		Complete:  completeArgsUsage,
+		Description: `
+EXAMPLES:
+
+   $ brig remote subscribe add bob /projects/foo /photos
+`,
+	},
+	"remote.subscribe.remove": {
+		Usage:       "Unsubscribe from one or more folders of a remote.",
+		ArgsUsage:   "<remote> <folder> [<folder>...]",
+		Complete:    completeArgsUsage,
+		Description: ``,
+	},
+	"remote.subscribe.clear": {
+		Usage:       "Unsubscribe from all folders, so the whole tree of the remote is synced again.",
+		ArgsUsage:   "<remote>",
+		Complete:    completeArgsUsage,
+		Description: ``,
+	},
+	"remote.subscribe.list": {
+		Usage:       "List all subscribed folders of a specific remote.",
+		ArgsUsage:   "<remote>",
+		Complete:    completeArgsUsage,
+		Description: ``,
+	},
 	"pin": {
 		Usage:     "Commands to handle the pin state.",
 		ArgsUsage: "<file>",
@@ -435,6 +664,72 @@ EXAMPLES:
    the space should be reclaimed.
    `,
 	},
+	"pin.status": {
+		Usage:     "Show which files are pinned here and by how many remotes.",
+		ArgsUsage: "[<root>]",
+		Complete:  completeBrigPath(true, true),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "replicas,r",
+				Usage: "Also show what online remotes have the file pinned.",
+			},
+			cli.BoolFlag{
+				Name:  "refresh,f",
+				Usage: "Ask all remotes for their pins now instead of using the last known state.",
+			},
+		},
+		Description: `List all files below »root« (or all files) and if they are pinned.
+
+   With »--replicas« the number of online remotes that have the file pinned is
+   shown too. For this, the daemon periodically asks all online remotes for a
+   summary of their pins (see »fs.replicas.interval«). The summary is a bloom
+   filter, so a remote might rarely be counted although it does not have the
+   file pinned. Only remotes that are online are counted. If a replica policy
+   applies to the file (see »brig pin policy«), the count is shown as
+   »have/want« and marked red if there are too few replicas.
+
+EXAMPLES:
+
+   $ brig pin status --replicas /archive
+   PATH               SIZE    PINNED  REPLICAS  REMOTES
+   /archive/2019.tar  1.2 GB  ✔       1/2       bob
+`,
+	},
+	"pin.policy": {
+		Usage:    "Require files to be pinned by several remotes.",
+		Complete: completeSubcommands,
+		Description: `A replica policy says how many online remotes should have the files in
+   a folder pinned. This is useful when using brig as distributed backup.
+   Policies of sub folders take precedence over the ones of parent folders.
+
+   The daemon checks the policies in the interval of »fs.replicas.interval«.
+   For every file with too few replicas, a warning is logged and other online
+   remotes are asked to pin it. Remotes only do so if they set
+   »fs.replicas.accept_requests« to true and have enough space left in their
+   »fs.repin.quota«. Use »brig pin status --replicas« to see the state.
+
+   If no subcommand is given, all policies are listed.
+
+EXAMPLES:
+
+   # Every file in /archive should be pinned on at least two remotes:
+   $ brig pin policy set /archive 2
+`,
+	},
+	"pin.policy.set": {
+		Usage:     "Set the number of remotes that should pin files in a folder.",
+		ArgsUsage: "<folder> <min-replicas>",
+		Complete:  completeBrigPath(false, true),
+	},
+	"pin.policy.remove": {
+		Usage:     "Remove the replica policy of one or several folders.",
+		ArgsUsage: "<folder>...",
+		Complete:  completeBrigPath(false, true),
+	},
+	"pin.policy.list": {
+		Usage:    "List all replica policies.",
+		Complete: completeArgsUsage,
+	},
 	"net": {
 		Usage:       "Commands that change or query the network status.",
 		Complete:    completeSubcommands,
@@ -461,9 +756,48 @@ EXAMPLES:
    Opposite of »brig net offline«. This is the default state whenever the daemon starts.`,
 	},
 	"net.status": {
-		Usage:       "Check if you're connected to the global network.",
-		Complete:    completeArgsUsage,
-		Description: `This will either print the string »online« or »offline«.`,
+		Usage:    "Check if you're connected to the global network.",
+		Complete: completeArgsUsage,
+		Description: `This will either print the string »online« or »offline«.
+
+Additionally it tells how many files are waiting in the write queue.
+Files that are added while being offline (or while the backend is not
+reachable) are stored encrypted in a local queue. They are uploaded to
+the backend automatically once it is back. Until then, no commit can be
+made and syncing with others is not possible, since they could not get
+the content of those files anyways.
+
+Finally, the current throughput to and from other peers is shown,
+together with the global limits in effect right now (see »net.bandwidth.*«).`,
+	},
+	"net.rendezvous": {
+		Usage:    "Run a rendezvous server that peers can use to find each other.",
+		Complete: completeArgsUsage,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "b,bind",
+				Value: ":6669",
+				Usage: "Address (host:port) to accept connections on",
+			},
+		},
+		Description: `Start a rendezvous server in the foreground. It does not need a repository.
+
+   Peers that have »net.rendezvous.enabled« set register their names with
+   the server given in »net.rendezvous.server« and use it to resolve the
+   names of other peers instead of the global network. If a peer can not
+   be reached directly, the connection is relayed through the server.
+
+   Every registered entry is signed by the peer's key and »brig net locate«
+   shows the fingerprint of that key. Anyone could register a name though,
+   so when resolving the name of a known remote, only entries made with the
+   key of its fingerprint are used. The connection to other peers is still
+   authenticated as usual; the server only ever sees encrypted traffic.
+
+EXAMPLES:
+
+   $ brig net rendezvous --bind :6669                         # On the server.
+   $ brig cfg set net.rendezvous.server rdv.example.org:6669  # On each peer.
+   $ brig cfg set net.rendezvous.enabled true`,
 	},
 	"net.locate": {
 		Usage:     "Try to locate a remote by their name or by a part of it.",
@@ -610,7 +944,16 @@ EXAMPLES:
    If omitted »--from INIT --to CURR« will be assumed.
 
    The output will show one commit per line, each including the (short) hash of the commit,
-   the date it was committed and the (optional) commit message.
+   the state of its signature, the date it was committed and the (optional) commit message.
+
+   Commits are signed with the key of their author. The signature states are:
+
+   ✔  The signature matches the author's key.
+   ✘  The signature does not match. The history might have been tampered with.
+   -  The commit was not signed (e.g. made by an older version of brig).
+   ?  There is no key to check the signature with.
+
+   See also »brig config doc fs.sync.require_signatures«.
 `,
 	},
 	"fetch": {
@@ -624,7 +967,10 @@ EXAMPLES:
    You have to be authenticated to the user to get his data.
 
    Fetch will be done automatically by »sync« and »diff« and is usually
-   only helpful when doing it together with »become«.`,
+   only helpful when doing it together with »become«.
+
+   The progress of the fetch is shown while it runs. Pressing Ctrl-C
+   will cancel the fetch.`,
 	},
 	"sync": {
 		Usage:     "Sync with another peer",
@@ -639,6 +985,10 @@ EXAMPLES:
 				Name:  "quiet,q",
 				Usage: "Do not print what changed.",
 			},
+			cli.StringSliceFlag{
+				Name:  "only,o",
+				Usage: "Only sync this folder. Can be given several times.",
+			},
 		},
 		Description: `Sync and merge all metadata of another peer with our metadata.
    After this operation you might see new files in your folder.
@@ -649,6 +999,15 @@ EXAMPLES:
    When passing no arguments, 'sync' will synchronize with all online remotes.
    When passing a single argument, it will be used as the remote name to sync with.
 
+   If you subscribed to specific folders of a remote (see »brig remote
+   subscribe«), only those folders are synced. Everything else stays untouched.
+   The --only flag does the same for a single sync and overrides the subscriptions.
+
+   While syncing, the current phase (fetching, merging...) and the amount of
+   transferred bytes and processed files is shown. Pressing Ctrl-C will cancel
+   the sync. Note that the merge itself cannot be interrupted, the cancellation
+   will take effect before or after it.
+
    The symbols in the output prefixing every path have the following meaning:
 
 	+	The file is only present on the remote side.
@@ -779,6 +1138,43 @@ EXAMPLES:
    - moved & modified: The file was moved and modified.
    - add & modified: The file was removed before and now re-added with different content.
    - moved & removed: The file was moved to another location.
+
+   Use »brig history prune« to get rid of old history.
+`,
+	},
+	"history.prune": {
+		Usage: "Remove old commits to save space",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "keep,k",
+				Usage: "Keep all commits that are younger than this (e.g. 90d or 12h).",
+			},
+			cli.IntFlag{
+				Name:  "keep-last,l",
+				Usage: "Keep at least this many commits.",
+			},
+		},
+		Description: `Squash all old commits into one base commit.
+
+   Every commit is kept forever by default, so the metadata of a busy repository
+   grows without bound. This command removes all commits that are not matched
+   by --keep or --keep-last (at least one of them is required). The state of the
+   newest removed commit is kept as the new first commit (»init«) of the history.
+   The current state of your files is not affected in any way.
+
+   Tagged commits are never removed. They are still reachable by their tag name,
+   but do not show up in »brig log« anymore.
+
+   Remotes that need changes from a removed part of the history will receive
+   the complete state from us on their next fetch instead.
+
+EXAMPLES:
+
+   # Keep the history of the last 90 days:
+   $ brig history prune --keep 90d
+
+   # Keep only the last 100 commits:
+   $ brig history prune --keep-last 100
 `,
 	},
 	"stage": {
@@ -807,6 +1203,30 @@ EXAMPLES:
    $ brig stage file.png                   # gets added as /file.png
    $ brig stage file.png /photos/me.png    # gets added as /photos/me.png
    $ cat file.png | brig --stdin /file.png # gets added as /file.png`,
+	},
+	"import": {
+		Usage:     "Add all files of a tar or zip archive and commit them",
+		ArgsUsage: "<archive> [<path>]",
+		Complete:  completeLocalPath,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "message,m",
+				Usage: "Commit message to use (default: »imported <archive>«)",
+			},
+		},
+		Description: `Stage every file of »archive« below »path« (»/« if omitted)
+   and make a commit afterwards. The archive can be a tar (optionally
+   gzipped) or a zip file; the format is detected from its content.
+
+   The modification times of the files and directories in the archive are
+   kept. brig does not store permissions, so the modes are ignored, as are
+   other entries like symbolic links. Entries that would end up outside of
+   »path« are placed inside of it.
+
+EXAMPLES:
+
+   $ brig import photos.zip /photos        # Add everything below /photos.
+   $ brig import -m "old stuff" backup.tar.gz`,
 	},
 	"touch": {
 		Usage:     "Create an empty file under the specified path",
@@ -1004,11 +1424,30 @@ EXAMPLES:
 		},
 		Description: `Start the dameon process in the foreground.
 
+Clients talk to the daemon over a unix socket called »brig.socket« in the
+repository. Only the user that started the daemon can connect to it.
+
+If »daemon.tcp.enabled« is set, the daemon also listens on »daemon.port«
+(on the host given by --bind). Clients connecting this way need to pass the
+token from the »daemon.token« file in the repository via --token.
+
+If »daemon.http.enabled« is set, the api is also offered as JSON over HTTP
+on the »brig-http.socket« in the repository (and on »daemon.http.port« if
+tcp is enabled). See »GET /api/v1« for a list of all calls.
+
+The daemon can serve other repositories too. Add them to the config as
+»daemon.repos.<name>.path« and restart the daemon. Each of them keeps its own
+files, remotes, gateway and mounts, but the connection to the backend is
+shared. If the other repositories have no password helper, they need the same
+password. Use »--repo <name>« to talk to them over the socket of the daemon.
 
 EXAMPLES:
 
    $ brig daemon quit        # Shut down any previous daemon.
    $ brig daemon launch -s   # Start in foreground and log to stdout.
+   $ brig --token $(cat ~/.brig/daemon.token) ls   # Connect over TCP.
+   $ brig cfg set daemon.repos.work.path ~/work    # Serve ~/work as "work".
+   $ brig --repo work ls                           # List files of ~/work.
 `,
 	},
 	"daemon.quit": {
@@ -1213,11 +1652,361 @@ CAVEATS
    The other garbage collector is not very important to the user and cleans up
    unused references inside of the metadata store. It is only run if you pass
    »--aggressive«.
+`,
+	},
+	"fsck": {
+		Usage:    "Check the repository for inconsistencies",
+		Complete: completeArgsUsage,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "deep,d",
+				Usage: "Also read and verify the content of all files",
+			},
+			cli.BoolFlag{
+				Name:  "repair,r",
+				Usage: "Fix all problems that can be fixed",
+			},
+		},
+		Description: `Check the metadata of the repository for consistency.
+
+   All commits and the nodes they reference are checked for existence and
+   their hashes are recomputed. Also the internal indexes, the move
+   mappings and the pin cache are checked.
+
+   With »--deep« the content of every file in every commit is fetched,
+   decrypted and compared to its content hash. Additionally the pins of the
+   backend are compared to the pin cache. This might take a long time and
+   needs to fetch content that is not available locally.
+
+   With »--repair« all indexes that can be derived from other data are
+   rebuilt and invalid entries are removed. Missing or corrupt content and
+   metadata cannot be repaired; you might be able to get them back by
+   syncing with a remote.
+
+   The command exits with a non-zero exit code if unrepaired problems were found.
+
+EXAMPLES:
+
+   $ brig fsck                   # Quick check of the metadata.
+   $ brig fsck --deep --repair   # Check everything and fix what can be fixed.
+`,
+	},
+	"backup": {
+		Usage: "Create and restore encrypted backups of the repository",
+		Description: `A backup bundle is a single encrypted file that contains everything
+   needed to recreate the repository on another machine: the metadata of all
+   users, the config, the keys, the remote list and the gateway users.
+   By default all pinned content is included too. Restoring a bundle does
+   not need any other peer to be online.
+
+   Bundles are encrypted with the password of the repository.
+   See the subcommands for more details.
+`,
+	},
+	"backup.create": {
+		Usage:     "Write a backup bundle of the repository to a file",
+		ArgsUsage: "<file>",
+		Complete:  completeLocalPath,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "parent,p",
+				Usage: "Only include content that is not in this (older) bundle",
+			},
+			cli.BoolFlag{
+				Name:  "no-content,n",
+				Usage: "Do not include any content, only metadata",
+			},
+		},
+		Description: `Write a backup bundle to »file«.
+
+   With »--parent« an incremental backup is made. It only contains the content
+   that is not in the parent bundle (or in the parents of it). Metadata and
+   repository files are always included in full, since they are small.
+   To restore an incremental backup, all bundles of the chain are needed.
+
+EXAMPLES:
+
+   $ brig backup create monday.brigbkp
+   $ brig backup create --parent monday.brigbkp tuesday.brigbkp
+`,
+	},
+	"backup.restore": {
+		Usage:     "Recreate a repository from one or several backup bundles",
+		ArgsUsage: "<file> [<file>...]",
+		Complete:  completeLocalPath,
+		Description: `Recreate the repository from the given bundles in the folder
+   given by »--repo« (or the default location). The folder must be empty.
+
+   Pass the full backup first and then all incremental backups in the order
+   they were made. The restored content is added to the backend once the
+   daemon is started for the first time. The password is the password of the
+   repository at the time the backup was made.
+
+EXAMPLES:
+
+   $ brig --repo ~/restored backup restore monday.brigbkp tuesday.brigbkp
+   $ brig --repo ~/restored daemon launch
+`,
+	},
+	"backup.info": {
+		Usage:     "Show what a backup bundle contains",
+		ArgsUsage: "<file>",
+		Complete:  completeLocalPath,
+		Description: `Show the manifest of a backup bundle.
+
+EXAMPLES:
+
+   $ brig backup info monday.brigbkp
+`,
+	},
+	"repo": {
+		Usage: "Manage how the repository is opened",
+		Description: `See the subcommands for details.
+`,
+	},
+	"repo.keyslot": {
+		Usage: "Manage the passwords and keyfiles that open the repository",
+		Description: `The files of a repository are encrypted with a random key. This key is
+   stored in one or several key slots. Each slot is opened either by a password
+   or by a keyfile. Any slot can be used to open the repository, which allows
+   for example a recovery key stored offline next to the normal password.
+
+   Repositories created with older versions of brig have no key slots.
+   They get a »default« slot once a slot is added or the password changes.
+   Key slots are changed directly in the repository; a running daemon keeps working.
+
+   See the subcommands for more details.
+`,
+	},
+	"repo.keyslot.add": {
+		Usage:     "Add a new password or keyfile that opens the repository",
+		ArgsUsage: "<name>",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "keyfile,k",
+				Usage: "Use the content of this file instead of a password; a random key is written to it if it does not exist",
+			},
+		},
+		Description: `Add the key slot »name«. The current password is asked first,
+   then the new password. With »--keyfile« the slot is opened by the content
+   of the file. To open the repository with a keyfile, use a password helper
+   that prints it.
+
+EXAMPLES:
+
+   $ brig repo keyslot add laptop
+   $ brig repo keyslot add --keyfile /media/usb/brig.key recovery
+   $ brig cfg set repo.password_command "cat /media/usb/brig.key"
+`,
+	},
+	"repo.keyslot.list": {
+		Usage: "List all key slots of the repository",
+		Description: `List the name, kind and creation time of every key slot.
+
+EXAMPLES:
+
+   $ brig repo keyslot list
+`,
+	},
+	"repo.keyslot.remove": {
+		Usage:     "Remove a key slot",
+		ArgsUsage: "<name>",
+		Description: `Remove the key slot »name«. The password or keyfile of any slot
+   needs to be given. The last slot can not be removed.
+
+EXAMPLES:
+
+   $ brig repo keyslot remove laptop
+`,
+	},
+	"passwd": {
+		Usage: "Change the password of the repository",
+		Description: `Ask for the current password and then for a new one. Only the key slot
+   opened by the current password is changed; other passwords and keyfiles keep
+   working. No files need to be re-encrypted. If a password helper is configured,
+   update it afterwards.
+
+   The daemon must not be running while changing the password, since it keeps
+   using the password it was started with. Start it again afterwards.
+
+EXAMPLES:
+
+   $ brig passwd
+`,
+	},
+	"audit": {
+		Usage: "Inspect the log of all actions done on the repository",
+		Description: `Every change done via the command line or the gateway is recorded
+   in the audit log, together with the time and the user who did it. Reading
+   files via the gateway or »brig cat« is recorded as well. Actions done via
+   the command line are attributed to the owner of the repository.
+
+   Each entry contains a hash of the previous entry. Modifying or removing an
+   entry breaks this chain, which is detected by »brig audit verify«.
+   The log is stored encrypted in the repository, like all other metadata.
+`,
+	},
+	"audit.log": {
+		Usage: "Show entries of the audit log",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "user,u",
+				Usage: "Only show actions of this user",
+			},
+			cli.StringFlag{
+				Name:  "path,p",
+				Usage: "Only show actions on this path or below it",
+			},
+			cli.StringFlag{
+				Name:  "since,s",
+				Usage: "Only show actions after this date (»2006-01-02«) or duration ago (»2h«, »7d«)",
+			},
+			cli.StringFlag{
+				Name:  "action,a",
+				Usage: "Only show actions starting with this name (»fs.« or »gateway.login«)",
+			},
+			cli.BoolFlag{
+				Name:  "json,j",
+				Usage: "Print the entries as JSON lines, including their hashes",
+			},
+			cli.StringFlag{
+				Name:  "format,f",
+				Usage: "Format the output by a template",
+			},
+		},
+		Description: `Show the entries of the audit log that match all given filters.
+
+   With »--json« the entries are printed one per line in the same format
+   they are stored in. This is useful to export the log to other tools.
+
+EXAMPLES:
+
+   $ brig audit log --user bob --since 7d
+   $ brig audit log --path /photos --action fs.remove
+   $ brig audit log --json > audit.jsonl
+`,
+	},
+	"audit.verify": {
+		Usage: "Check that the audit log was not tampered with",
+		Description: `Check the hash chain of the whole audit log and print the hash of
+   the last entry. Removing entries from the end of the log can only be
+   noticed by comparing this hash with one that was noted down earlier.
+
+EXAMPLES:
+
+   $ brig audit verify
 `,
 	},
 	"docs": {
 		Usage: "Open the online documentation in your default web browser.",
 	},
+	"du": {
+		Usage:     "Show how much storage a directory uses.",
+		ArgsUsage: "[<path>]",
+		Complete:  completeBrigPath(false, true),
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "depth,d",
+				Usage: "Max depth of directories to show",
+				Value: 1,
+			},
+			cli.BoolFlag{
+				Name:  "recursive,R",
+				Usage: "Show all directories",
+			},
+			cli.BoolFlag{
+				Name:  "users,u",
+				Usage: "Show the usage per user (i.e. per remote) instead",
+			},
+		},
+		Description: `Show the storage usage of »path« and the directories below it.
+   If no »<path>« is given, the root directory is assumed. The columns are:
+
+   - FILES: Number of files below the directory.
+   - SIZE: Sum of all file sizes.
+   - DEDUP: Like SIZE, but files with the same content are counted only once.
+   - PINNED: Size of the content that is pinned (i.e. kept) locally.
+   - CACHED: Size of the content that is currently stored locally.
+   - QUOTA: The quota of this directory, if any (see »brig quota«).
+
+   The numbers reflect the state of the last commit.
+   With »--users«, the usage is shown per user that modified the files last.
+   Users that are also remotes show the quota of the remote (see »brig remote quota«).
+
+EXAMPLES:
+
+   $ brig du /photos -d 2
+`,
+	},
+	"quota": {
+		Usage:    "Limit how much storage a folder may use.",
+		Complete: completeSubcommands,
+		Description: `A quota limits the size of all files in a folder.
+   Staging files (via »brig stage«, the gateway or writes to a mount) and syncs
+   that would make the folder bigger than its quota fail.
+   The folder does not need to exist when setting a quota.
+
+   If you do not specify any subcommand, this is a shortcut for »brig quota ls«.
+   See also »brig du« to see the current usage.
+
+EXAMPLES:
+
+   $ brig quota set /photos 50G
+   $ brig quota ls
+   $ brig quota rm /photos
+`,
+	},
+	"quota.set": {
+		Usage:     "Set the quota of a folder.",
+		ArgsUsage: "<folder> <size>",
+		Complete:  completeBrigPath(false, true),
+	},
+	"quota.remove": {
+		Usage:     "Remove the quota of a folder.",
+		ArgsUsage: "<folder>",
+		Complete:  completeBrigPath(false, true),
+	},
+	"quota.list": {
+		Usage: "List all folder quotas.",
+	},
+	"encryption": {
+		Usage:    "Choose how the keys of files in a folder are made.",
+		Complete: completeSubcommands,
+		Description: `By default the key of a file is derived from its content. Files with
+   the same content are therefore encrypted the same way, which allows to store
+   them only once. The downside is that somebody who knows a file can check if
+   it is stored, even without being able to decrypt anything.
+
+   Folders set to »random« give every new file a random key instead. Those files
+   are not deduplicated anymore. The keys are stored in the metadata and are shared
+   with remotes on sync, like all other keys. Existing files get a random key the
+   next time their content changes. The setting of the deepest folder wins, so a
+   sub folder can be set back to »convergent«.
+
+   If you do not specify any subcommand, this is a shortcut for »brig encryption ls«.
+   See »brig show« for the scheme of a single file.
+
+EXAMPLES:
+
+   $ brig encryption set /hr random
+   $ brig encryption set /hr/templates convergent
+   $ brig encryption ls
+   $ brig encryption rm /hr
+`,
+	},
+	"encryption.set": {
+		Usage:     "Set the key scheme of a folder (»convergent« or »random«).",
+		ArgsUsage: "<folder> <scheme>",
+		Complete:  completeBrigPath(false, true),
+	},
+	"encryption.remove": {
+		Usage:     "Remove the key scheme setting of a folder.",
+		ArgsUsage: "<folder>",
+		Complete:  completeBrigPath(false, true),
+	},
+	"encryption.list": {
+		Usage: "List all folders with a key scheme setting.",
+	},
 	"trash": {
 		Usage: "Control the trash bin contents.",
 		Description: `
@@ -1287,7 +2076,8 @@ EXAMPLES:
 		Usage: "Control the user account that can access the HTTP gateway.",
 	},
 	"gateway.user.add": {
-		Usage: "Add a new gateway user.",
+		Usage:     "Add a new gateway user.",
+		ArgsUsage: "<name> [<password> [<folder>[:<rights>]...]]",
 		Flags: []cli.Flag{
 			cli.BoolFlag{
 				Name:  "role-admin,a",
@@ -1325,6 +2115,20 @@ EXAMPLES:
 
    If the folder list is empty, this user can access all files.
    If it is non-empty, the user can only access the files including and below all folders.
+
+   A folder can also be given with its own rights as »<folder>:<right>,...«.
+   Those rights apply to the folder and everything below it and replace the
+   global rights there. A right prefixed with »!« is taken away. The rule of
+   the deepest folder wins. Moving, copying and removing directories needs
+   the respective right for everything inside them.
+
+EXAMPLES:
+
+   # Bob may change /incoming, but only look at /archive:
+   $ brig gw user add bob secret /incoming:fs.view,fs.edit,fs.download /archive:fs.view,fs.download
+
+   # Alice is admin, but may not change anything in /archive:
+   $ brig gw user add --role-admin alice secret / '/archive:!fs.edit'
 `,
 	},
 	"gateway.user.remove": {
@@ -1348,6 +2152,62 @@ EXAMPLES:
    - Salt: Salt of the password.
    - Folders: A list of folders this users may access (might be empty).
    - Rights: A list of rights this users has (might be empty).
+`,
+	},
+	"gateway.token": {
+		Usage: "Manage API tokens that scripts can use to access the gateway.",
+	},
+	"gateway.token.add": {
+		Usage:     "Add a new API token for an existing gateway user.",
+		ArgsUsage: "<user> <name> [<folders>...]",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "rights,r",
+				Usage: "Comma separated list of rights of this token.",
+			},
+		},
+		Description: `
+   Create a new token and print it. The token is not stored in readable
+   form, so it can not be shown again later.
+
+   The token can be passed to the gateway with an »Authorization: Bearer <token>«
+   header instead of logging in. It works for the /api/v0 routes and for /get.
+
+   A token has the same folders and rights as its user, unless given.
+   It is not possible to give a token more rights or folders than the user has.
+   If the user loses rights later, the token loses them too.
+
+EXAMPLES:
+
+   # Add a token that may only download the backups folder:
+   $ brig gw token add ali backup-script /backups -r fs.download
+`,
+	},
+	"gateway.token.remove": {
+		Usage:     "Revoke API tokens by their id.",
+		ArgsUsage: "<id> [<id>...]",
+	},
+	"gateway.token.list": {
+		Usage:     "List API tokens of a user or of all users.",
+		ArgsUsage: "[<user>]",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "format,f",
+				Usage: "Format the output by a template.",
+			},
+		},
+		Description: `
+   List all API tokens and when they were used last.
+
+   The keys accepted by »--format« are:
+
+   - ID: Id of the token.
+   - User: The user the token belongs to.
+   - Name: Name of the token.
+   - Folders: A list of folders the token may access.
+   - Rights: A list of rights of this token.
+   - Created: When the token was created.
+   - LastUsed: When the token was used last (zero if never).
 `,
 	},
 	"debug": {
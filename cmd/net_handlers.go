@@ -3,14 +3,21 @@ package cmd
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/fatih/color"
 	"github.com/sahib/brig/cmd/tabwriter"
 
 	"github.com/sahib/brig/client"
+	"github.com/sahib/brig/net/rendezvous"
+	"github.com/sahib/brig/util/throttle"
 	"github.com/urfave/cli"
 	yml "gopkg.in/yaml.v2"
 )
@@ -23,6 +30,19 @@ func handleOnline(ctx *cli.Context, ctl *client.Client) error {
 	return ctl.NetConnect()
 }
 
+func handleNetRendezvous(ctx *cli.Context) error {
+	lst, err := net.Listen("tcp", ctx.String("bind"))
+	if err != nil {
+		return ExitCode{
+			UnknownError,
+			fmt.Sprintf("failed to listen: %v", err),
+		}
+	}
+
+	fmt.Printf("Serving rendezvous requests on %s\n", lst.Addr())
+	return rendezvous.NewServer(lst).Serve()
+}
+
 func handleIsOnline(ctx *cli.Context, ctl *client.Client) error {
 	self, err := ctl.Whoami()
 	if err != nil {
@@ -35,6 +55,24 @@ func handleIsOnline(ctx *cli.Context, ctl *client.Client) error {
 		fmt.Println(color.RedString("offline"))
 	}
 
+	if self.QueueLength > 0 {
+		fmt.Printf(
+			"%d file(s) with %s in the write queue\n",
+			self.QueueLength,
+			humanize.Bytes(self.QueueBytes),
+		)
+	} else {
+		fmt.Println("write queue is empty")
+	}
+
+	fmt.Printf(
+		"throughput: ↑ %s (limit: %s) ↓ %s (limit: %s)\n",
+		humanize.Bytes(self.UploadRate)+"/s",
+		throttle.FormatRate(self.UploadLimit),
+		humanize.Bytes(self.DownloadRate)+"/s",
+		throttle.FormatRate(self.DownloadLimit),
+	)
+
 	return nil
 }
 
@@ -54,6 +92,14 @@ func nFoldersToIcon(nFolders int) string {
 	return color.YellowString(fmt.Sprintf("%d", nFolders))
 }
 
+func namesToText(names []string) string {
+	if len(names) == 0 {
+		return "-"
+	}
+
+	return strings.Join(names, ",")
+}
+
 func handleRemoteListOffline(ctx *cli.Context, ctl *client.Client) error {
 	remotes, err := ctl.RemoteLs()
 	if err != nil {
@@ -85,7 +131,7 @@ func handleRemoteListOffline(ctx *cli.Context, ctl *client.Client) error {
 		tabwriter.StripEscape,
 	)
 
-	fmt.Fprintln(tabW, "NAME\tFINGERPRINT\tAUTO-UPDATE\tACCEPT PUSH\tCONFLICT STRATEGY\tFOLDERS\t")
+	fmt.Fprintln(tabW, "NAME\tFINGERPRINT\tAUTO-UPDATE\tACCEPT PUSH\tCONFLICT STRATEGY\tGROUPS\tFOLDERS\t")
 
 	for _, remote := range remotes {
 		cs := remote.ConflictStrategy
@@ -95,13 +141,14 @@ func handleRemoteListOffline(ctx *cli.Context, ctl *client.Client) error {
 
 		fmt.Fprintf(
 			tabW,
-			"%s\t%s\t%s\t%s\t%s\t%s\n",
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
 			remote.Name,
 			remote.Fingerprint,
 			yesOrNo(remote.AutoUpdate),
 			yesOrNo(remote.AcceptPush),
 			cs,
-			nFoldersToIcon(len(remote.Folders)),
+			namesToText(remote.Groups),
+			nFoldersToIcon(len(remote.EffectiveFolders)),
 		)
 	}
 
@@ -125,7 +172,7 @@ func handleRemoteListOnline(ctx *cli.Context, ctl *client.Client) error {
 	}
 
 	if !ctx.IsSet("format") {
-		fmt.Fprintln(tabW, "NAME\tFINGERPRINT\tROUNDTRIP\tONLINE\tAUTHENTICATED\tLASTSEEN\tAUTO-UPDATE\tACCEPT PUSH\tCONFLICT STRATEGY\tFOLDERS\t")
+		fmt.Fprintln(tabW, "NAME\tFINGERPRINT\tROUNDTRIP\tONLINE\tAUTHENTICATED\tLASTSEEN\tAUTO-UPDATE\tACCEPT PUSH\tCONFLICT STRATEGY\tGROUPS\tFOLDERS\t")
 	}
 
 	tmpl, err := readFormatTemplate(ctx)
@@ -189,7 +236,7 @@ func handleRemoteListOnline(ctx *cli.Context, ctl *client.Client) error {
 
 		fmt.Fprintf(
 			tabW,
-			"%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
 			status.Remote.Name,
 			shortFp,
 			roundtrip,
@@ -199,7 +246,8 @@ func handleRemoteListOnline(ctx *cli.Context, ctl *client.Client) error {
 			yesOrNo(status.Remote.AutoUpdate),
 			yesOrNo(status.Remote.AcceptPush),
 			cs,
-			nFoldersToIcon(len(status.Remote.Folders)),
+			namesToText(status.Remote.Groups),
+			nFoldersToIcon(len(status.Remote.EffectiveFolders)),
 		)
 	}
 
@@ -261,6 +309,81 @@ func handleRemoteAdd(ctx *cli.Context, ctl *client.Client) error {
 	return nil
 }
 
+func handleRemoteInvite(ctx *cli.Context, ctl *client.Client) error {
+	timeoutSec, err := parseDuration(ctx.String("timeout"))
+	if err != nil {
+		return ExitCode{BadArgs, fmt.Sprintf("bad timeout: %v", err)}
+	}
+
+	folders := []client.RemoteFolder{}
+	for _, folder := range ctx.StringSlice("folder") {
+		isReadOnly := ctx.Bool("read-only")
+		if strings.HasPrefix(folder, "-") {
+			isReadOnly = true
+			folder = folder[1:]
+		}
+
+		folders = append(folders, client.RemoteFolder{
+			Folder:   folder,
+			ReadOnly: isReadOnly,
+		})
+	}
+
+	if len(folders) == 0 && ctx.Bool("read-only") {
+		folders = append(folders, client.RemoteFolder{
+			Folder:   "/",
+			ReadOnly: true,
+		})
+	}
+
+	code, err := ctl.RemoteInvite(folders, timeoutSec)
+	if err != nil {
+		return fmt.Errorf("remote invite: %v", err)
+	}
+
+	if path := ctx.String("output"); path != "" {
+		if err := ioutil.WriteFile(path, []byte(code+"\n"), 0600); err != nil {
+			return err
+		}
+
+		fmt.Printf("Wrote invite to %s. Pass it to »brig remote join«.\n", path)
+		return nil
+	}
+
+	fmt.Println(code)
+	return nil
+}
+
+func handleRemoteJoin(ctx *cli.Context, ctl *client.Client) error {
+	// The code might also be given as file written by »invite -o«:
+	code := ctx.Args().First()
+	if data, err := ioutil.ReadFile(code); err == nil {
+		code = strings.TrimSpace(string(data))
+	}
+
+	remote, err := ctl.RemoteJoin(code)
+	if err != nil {
+		return fmt.Errorf("remote join: %v", err)
+	}
+
+	folders := []string{}
+	for _, folder := range remote.Folders {
+		folders = append(folders, folder.Folder)
+	}
+
+	if len(folders) == 0 {
+		folders = append(folders, "/")
+	}
+
+	fmt.Printf(
+		"Added »%s« as remote. Shared folders: %s\n",
+		remote.Name,
+		strings.Join(folders, ", "),
+	)
+
+	return nil
+}
+
 func handleRemoteAutoUpdate(ctx *cli.Context, ctl *client.Client) error {
 	enable := true
 
@@ -547,6 +670,242 @@ func handleRemoteFolderListAll(ctx *cli.Context, ctl *client.Client) error {
 	return tabW.Flush()
 }
 
+func findGroupForName(ctl *client.Client, name string) (*client.RemoteGroup, error) {
+	groups, err := ctl.RemoteGroupLs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range groups {
+		if group.Name == name {
+			return &group, nil
+		}
+	}
+
+	return nil, fmt.Errorf("No such group with this name: %s", name)
+}
+
+func handleRemoteGroupList(ctx *cli.Context, ctl *client.Client) error {
+	groups, err := ctl.RemoteGroupLs()
+	if err != nil {
+		return err
+	}
+
+	if len(groups) == 0 {
+		fmt.Println("No groups yet. Use `brig remote group add »group« »remote«` to add some.")
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.StripEscape)
+	fmt.Fprintln(tabW, "GROUP\tMEMBERS\tFOLDER\tREAD ONLY\tCONFLICT STRATEGY\t")
+
+	for _, group := range groups {
+		members := namesToText(group.Members)
+		if len(group.Folders) == 0 {
+			fmt.Fprintf(tabW, "%s\t%s\t-\t\t\t\n", group.Name, members)
+			continue
+		}
+
+		for _, folder := range group.Folders {
+			fmt.Fprintf(
+				tabW,
+				"%s\t%s\t%s\t%s\t%s\t\n",
+				group.Name,
+				members,
+				folder.Folder,
+				yesOrNo(folder.ReadOnly),
+				folder.ConflictStrategy,
+			)
+		}
+	}
+
+	return tabW.Flush()
+}
+
+func handleRemoteGroupAdd(ctx *cli.Context, ctl *client.Client) error {
+	name := ctx.Args().First()
+	group, err := findGroupForName(ctl, name)
+	if err != nil {
+		group = &client.RemoteGroup{Name: name}
+	}
+
+	group.Members = append(group.Members, ctx.Args().Tail()...)
+	return ctl.RemoteGroupSet(*group)
+}
+
+func handleRemoteGroupRemove(ctx *cli.Context, ctl *client.Client) error {
+	name := ctx.Args().First()
+	if len(ctx.Args().Tail()) == 0 {
+		return ctl.RemoteGroupRm(name)
+	}
+
+	group, err := findGroupForName(ctl, name)
+	if err != nil {
+		return err
+	}
+
+	toRemove := make(map[string]bool)
+	for _, member := range ctx.Args().Tail() {
+		toRemove[member] = true
+	}
+
+	members := []string{}
+	for _, member := range group.Members {
+		if !toRemove[member] {
+			members = append(members, member)
+		}
+	}
+
+	group.Members = members
+	return ctl.RemoteGroupSet(*group)
+}
+
+func handleRemoteGroupFolderAdd(ctx *cli.Context, ctl *client.Client) error {
+	group, err := findGroupForName(ctl, ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	for _, folder := range ctx.Args().Tail() {
+		if _, err := ctl.Stat(folder); err != nil {
+			fmt.Printf("warning: »%s« does not seem to exist. That's fine though, just in case you made a typo.\n", folder)
+		}
+
+		for _, groupFolder := range group.Folders {
+			if groupFolder.Folder == folder {
+				return fmt.Errorf("»%s« exists already", folder)
+			}
+		}
+
+		group.Folders = append(group.Folders, client.RemoteFolder{
+			Folder:           folder,
+			ReadOnly:         ctx.Bool("read-only"),
+			ConflictStrategy: ctx.String("conflict-strategy"),
+		})
+	}
+
+	return ctl.RemoteGroupSet(*group)
+}
+
+func handleRemoteGroupFolderRemove(ctx *cli.Context, ctl *client.Client) error {
+	group, err := findGroupForName(ctl, ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	toRemove := make(map[string]bool)
+	for _, folder := range ctx.Args().Tail() {
+		toRemove[folder] = true
+	}
+
+	folders := []client.RemoteFolder{}
+	for _, folder := range group.Folders {
+		if !toRemove[folder.Folder] {
+			folders = append(folders, folder)
+		}
+	}
+
+	group.Folders = folders
+	return ctl.RemoteGroupSet(*group)
+}
+
+func handleRemoteSubscribeAdd(ctx *cli.Context, ctl *client.Client) error {
+	remote, err := findRemoteForName(ctl, ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	for _, folder := range ctx.Args().Tail() {
+		folder = path.Clean(prefixSlash(folder))
+
+		isSubscribed := false
+		for _, subscribed := range remote.Subscribed {
+			if subscribed == folder {
+				isSubscribed = true
+				break
+			}
+		}
+
+		if isSubscribed {
+			return fmt.Errorf("»%s« is subscribed already", folder)
+		}
+
+		remote.Subscribed = append(remote.Subscribed, folder)
+	}
+
+	return ctl.RemoteUpdate(*remote)
+}
+
+func handleRemoteSubscribeRemove(ctx *cli.Context, ctl *client.Client) error {
+	remote, err := findRemoteForName(ctl, ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	toRemove := make(map[string]bool)
+	for _, folder := range ctx.Args().Tail() {
+		toRemove[path.Clean(prefixSlash(folder))] = true
+	}
+
+	newSubscribed := []string{}
+	for _, folder := range remote.Subscribed {
+		if toRemove[folder] {
+			continue
+		}
+
+		newSubscribed = append(newSubscribed, folder)
+	}
+
+	remote.Subscribed = newSubscribed
+	return ctl.RemoteUpdate(*remote)
+}
+
+func handleRemoteSubscribeClear(ctx *cli.Context, ctl *client.Client) error {
+	remote, err := findRemoteForName(ctl, ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	remote.Subscribed = []string{}
+	return ctl.RemoteUpdate(*remote)
+}
+
+func handleRemoteSubscribeList(ctx *cli.Context, ctl *client.Client) error {
+	remote, err := findRemoteForName(ctl, ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if len(remote.Subscribed) == 0 {
+		fmt.Println("No folders subscribed. All folders are synced.")
+		return nil
+	}
+
+	for _, folder := range remote.Subscribed {
+		fmt.Println(folder)
+	}
+
+	return nil
+}
+
+func handleRemoteSubscribeListAll(ctx *cli.Context, ctl *client.Client) error {
+	remotes, err := ctl.RemoteLs()
+	if err != nil {
+		return err
+	}
+
+	tabW := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.StripEscape)
+	fmt.Fprintln(tabW, "REMOTE\tFOLDER\t")
+
+	for _, remote := range remotes {
+		for _, folder := range remote.Subscribed {
+			fmt.Fprintf(tabW, "%s\t%s\t\n", remote.Name, folder)
+		}
+	}
+
+	return tabW.Flush()
+}
+
 func handleNetLocate(ctx *cli.Context, ctl *client.Client) error {
 	who := ctx.Args().First()
 	timeoutSec, err := parseDuration(ctx.String("timeout"))
@@ -584,6 +943,8 @@ func handleNetLocate(ctx *cli.Context, ctl *client.Client) error {
 		fingerprint := candidate.Fingerprint
 		if fingerprint == "" {
 			fingerprint = candidate.Addr + color.RedString(" (offline)")
+		} else if !candidate.Verified {
+			fingerprint = color.YellowString(fingerprint + " (unverified)")
 		} else {
 			fingerprint = color.GreenString(fingerprint)
 		}
@@ -639,6 +1000,116 @@ func handleRepin(ctx *cli.Context, ctl *client.Client) error {
 	return ctl.Repin(root)
 }
 
+func handlePinStatus(ctx *cli.Context, ctl *client.Client) error {
+	root := "/"
+	if len(ctx.Args()) > 0 {
+		root = ctx.Args().First()
+	}
+
+	showReplicas := ctx.Bool("replicas")
+	statuses, err := ctl.ReplicaStatus(root, showReplicas && ctx.Bool("refresh"))
+	if err != nil {
+		return err
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	if showReplicas {
+		fmt.Fprintln(tabW, "PATH\tSIZE\tPINNED\tREPLICAS\tREMOTES\t")
+	} else {
+		fmt.Fprintln(tabW, "PATH\tSIZE\tPINNED\t")
+	}
+
+	for _, status := range statuses {
+		pinned := color.RedString("✘")
+		if status.IsPinned {
+			pinned = color.GreenString("✔")
+		}
+
+		if !showReplicas {
+			fmt.Fprintf(
+				tabW,
+				"%s\t%s\t%s\t\n",
+				status.Path,
+				humanize.Bytes(status.Size),
+				pinned,
+			)
+			continue
+		}
+
+		replicas := fmt.Sprintf("%d", len(status.Remotes))
+		if status.MinReplicas > 0 {
+			replicas = fmt.Sprintf("%d/%d", len(status.Remotes), status.MinReplicas)
+			if len(status.Remotes) < status.MinReplicas {
+				replicas = color.RedString(replicas)
+			} else {
+				replicas = color.GreenString(replicas)
+			}
+		}
+
+		fmt.Fprintf(
+			tabW,
+			"%s\t%s\t%s\t%s\t%s\t\n",
+			status.Path,
+			humanize.Bytes(status.Size),
+			pinned,
+			replicas,
+			namesToText(status.Remotes),
+		)
+	}
+
+	return tabW.Flush()
+}
+
+func handlePinPolicySet(ctx *cli.Context, ctl *client.Client) error {
+	minReplicas, err := strconv.Atoi(ctx.Args().Get(1))
+	if err != nil || minReplicas <= 0 {
+		return ExitCode{
+			BadArgs,
+			"the number of replicas has to be bigger than zero; use »brig pin policy rm« to remove it",
+		}
+	}
+
+	return ctl.ReplicaPolicySet(ctx.Args().First(), minReplicas)
+}
+
+func handlePinPolicyRemove(ctx *cli.Context, ctl *client.Client) error {
+	for _, folder := range ctx.Args() {
+		if err := ctl.ReplicaPolicySet(folder, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func handlePinPolicyList(ctx *cli.Context, ctl *client.Client) error {
+	policies, err := ctl.ReplicaPolicyList()
+	if err != nil {
+		return err
+	}
+
+	if len(policies) == 0 {
+		fmt.Println("No replica policies yet. Use »brig pin policy set« to add one.")
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintf(tabW, "FOLDER\tMIN REPLICAS\t\n")
+	for _, policy := range policies {
+		fmt.Fprintf(tabW, "%s\t%d\t\n", color.GreenString(policy.Path), policy.MinReplicas)
+	}
+
+	return tabW.Flush()
+}
+
 func handleWhoami(ctx *cli.Context, ctl *client.Client) error {
 	self, err := ctl.Whoami()
 	if err != nil {
@@ -706,5 +1177,61 @@ func handleWhoami(ctx *cli.Context, ctl *client.Client) error {
 
 func handlePush(ctx *cli.Context, ctl *client.Client) error {
 	remoteName := ctx.Args().First()
-	return ctl.Push(remoteName, ctx.Bool("dry-run"))
+	ticket, err := ctl.PushStart(remoteName, ctx.Bool("dry-run"))
+	if err != nil {
+		return err
+	}
+
+	return waitForJob(ctl, ticket, false)
+}
+
+func handleRemoteQuota(ctx *cli.Context, ctl *client.Client) error {
+	remote, err := findRemoteForName(ctl, ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if len(ctx.Args()) < 2 {
+		fmt.Println(formatQuota(remote.Quota))
+		return nil
+	}
+
+	quota := uint64(0)
+	if sizeArg := ctx.Args().Get(1); sizeArg != "none" {
+		quota, err = humanize.ParseBytes(sizeArg)
+		if err != nil {
+			return ExitCode{
+				BadArgs,
+				fmt.Sprintf("invalid size: %v", err),
+			}
+		}
+	}
+
+	remote.Quota = quota
+	return ctl.RemoteUpdate(*remote)
+}
+
+func handleRemoteBandwidth(ctx *cli.Context, ctl *client.Client) error {
+	remote, err := findRemoteForName(ctl, ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if len(ctx.Args()) < 2 {
+		fmt.Printf("upload:   %s\n", throttle.FormatRate(remote.UploadLimit))
+		fmt.Printf("download: %s\n", throttle.FormatRate(remote.DownloadLimit))
+		return nil
+	}
+
+	rate, err := throttle.ParseRatePair(ctx.Args().Get(1))
+	if err != nil {
+		return ExitCode{
+			BadArgs,
+			fmt.Sprintf("invalid rate: %v", err),
+		}
+	}
+
+	remote.UploadLimit = rate.Up
+	remote.DownloadLimit = rate.Down
+	return ctl.RemoteUpdate(*remote)
 }
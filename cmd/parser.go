@@ -136,16 +136,22 @@ func RunCmdline(args []string) int {
 	app.Flags = []cli.Flag{
 		cli.IntFlag{
 			Name:   "port,p",
-			Usage:  "Port of the daemon to connect to. Normally guessed via --repo.",
+			Usage:  "Port of the daemon when connecting over TCP. Normally guessed via --repo.",
 			EnvVar: "BRIG_PORT",
 			Value:  6666,
 		},
 		cli.StringFlag{
 			Name:   "repo",
-			Usage:  "Path to the repository. Only has effect for new daemons.",
+			Usage:  "Path to the repository or name of one in daemon.repos.",
 			Value:  "",
 			EnvVar: "BRIG_PATH",
 		},
+		cli.StringFlag{
+			Name:   "token",
+			Usage:  "Connect to the daemon over TCP using this token (see daemon.tcp.enabled).",
+			EnvVar: "BRIG_DAEMON_TOKEN",
+			Value:  "",
+		},
 		cli.BoolFlag{
 			Name:  "verbose,V",
 			Usage: "Show certain messages during client startup (helpful for debugging)",
@@ -195,6 +201,12 @@ func RunCmdline(args []string) int {
 					Name:    "remove",
 					Aliases: []string{"rm"},
 					Action:  withArgCheck(needAtLeast(1), withDaemon(handleRemoteRemove, true)),
+				}, {
+					Name:   "invite",
+					Action: withDaemon(handleRemoteInvite, true),
+				}, {
+					Name:   "join",
+					Action: withArgCheck(needAtLeast(1), withDaemon(handleRemoteJoin, true)),
 				}, {
 					Name:    "list",
 					Aliases: []string{"ls"},
@@ -220,6 +232,14 @@ func RunCmdline(args []string) int {
 					Name:    "conflict-strategy",
 					Aliases: []string{"cs"},
 					Action:  withArgCheck(needAtLeast(2), withDaemon(handleRemoteConflictStrategy, true)),
+				}, {
+					Name:    "quota",
+					Aliases: []string{"q"},
+					Action:  withArgCheck(needAtLeast(1), withDaemon(handleRemoteQuota, true)),
+				}, {
+					Name:    "bandwidth",
+					Aliases: []string{"bw"},
+					Action:  withArgCheck(needAtLeast(1), withDaemon(handleRemoteBandwidth, true)),
 				}, {
 					Name:    "folder",
 					Aliases: []string{"fld", "f"},
@@ -246,6 +266,61 @@ func RunCmdline(args []string) int {
 							Action:  withArgCheck(needAtLeast(1), withDaemon(handleRemoteFolderList, true)),
 						},
 					},
+				}, {
+					Name:    "group",
+					Aliases: []string{"grp", "g"},
+					Action:  withDaemon(handleRemoteGroupList, true),
+					Subcommands: []cli.Command{
+						{
+							Name:    "add",
+							Aliases: []string{"a"},
+							Action:  withArgCheck(needAtLeast(1), withDaemon(handleRemoteGroupAdd, true)),
+						}, {
+							Name:    "remove",
+							Aliases: []string{"rm"},
+							Action:  withArgCheck(needAtLeast(1), withDaemon(handleRemoteGroupRemove, true)),
+						}, {
+							Name:    "list",
+							Aliases: []string{"ls"},
+							Action:  withDaemon(handleRemoteGroupList, true),
+						}, {
+							Name:    "folder",
+							Aliases: []string{"fld", "f"},
+							Subcommands: []cli.Command{
+								{
+									Name:    "add",
+									Aliases: []string{"a"},
+									Action:  withArgCheck(needAtLeast(2), withDaemon(handleRemoteGroupFolderAdd, true)),
+								}, {
+									Name:    "remove",
+									Aliases: []string{"rm"},
+									Action:  withArgCheck(needAtLeast(2), withDaemon(handleRemoteGroupFolderRemove, true)),
+								},
+							},
+						},
+					},
+				}, {
+					Name:    "subscribe",
+					Aliases: []string{"sub"},
+					Action:  withDaemon(handleRemoteSubscribeListAll, true),
+					Subcommands: []cli.Command{
+						{
+							Name:    "add",
+							Aliases: []string{"a"},
+							Action:  withArgCheck(needAtLeast(2), withDaemon(handleRemoteSubscribeAdd, true)),
+						}, {
+							Name:    "remove",
+							Aliases: []string{"rm"},
+							Action:  withArgCheck(needAtLeast(2), withDaemon(handleRemoteSubscribeRemove, true)),
+						}, {
+							Name:   "clear",
+							Action: withArgCheck(needAtLeast(1), withDaemon(handleRemoteSubscribeClear, true)),
+						}, {
+							Name:    "list",
+							Aliases: []string{"ls"},
+							Action:  withArgCheck(needAtLeast(1), withDaemon(handleRemoteSubscribeList, true)),
+						},
+					},
 				},
 			},
 		}, {
@@ -259,6 +334,27 @@ func RunCmdline(args []string) int {
 				}, {
 					Name:   "repin",
 					Action: withDaemon(handleRepin, true),
+				}, {
+					Name:    "status",
+					Aliases: []string{"st"},
+					Action:  withDaemon(handlePinStatus, true),
+				}, {
+					Name:   "policy",
+					Action: withDaemon(handlePinPolicyList, true),
+					Subcommands: []cli.Command{
+						{
+							Name:   "set",
+							Action: withArgCheck(needAtLeast(2), withDaemon(handlePinPolicySet, true)),
+						}, {
+							Name:    "remove",
+							Aliases: []string{"rm"},
+							Action:  withArgCheck(needAtLeast(1), withDaemon(handlePinPolicyRemove, true)),
+						}, {
+							Name:    "list",
+							Aliases: []string{"ls"},
+							Action:  withDaemon(handlePinPolicyList, true),
+						},
+					},
 				}, {
 					Name:    "remove",
 					Aliases: []string{"rm"},
@@ -281,6 +377,9 @@ func RunCmdline(args []string) int {
 				}, {
 					Name:   "locate",
 					Action: withArgCheck(needAtLeast(1), withDaemon(handleNetLocate, true)),
+				}, {
+					Name:   "rendezvous",
+					Action: handleNetRendezvous,
 				},
 			},
 		}, {
@@ -332,11 +431,21 @@ func RunCmdline(args []string) int {
 			Aliases:  []string{"hst", "hist"},
 			Category: vcscGroup,
 			Action:   withArgCheck(needAtLeast(1), withDaemon(handleHistory, true)),
+			Subcommands: []cli.Command{
+				{
+					Name:   "prune",
+					Action: withDaemon(handleHistoryPrune, true),
+				},
+			},
 		}, {
 			Name:     "stage",
 			Aliases:  []string{"stg", "add", "a"},
 			Category: wdirGroup,
 			Action:   withArgCheck(needAtLeast(1), withDaemon(handleStage, true)),
+		}, {
+			Name:     "import",
+			Category: wdirGroup,
+			Action:   withArgCheck(needAtLeast(1), withDaemon(handleImport, true)),
 		}, {
 			Name:     "touch",
 			Aliases:  []string{"t"},
@@ -364,6 +473,10 @@ func RunCmdline(args []string) int {
 			Name:     "tree",
 			Category: wdirGroup,
 			Action:   withDaemon(handleTree, true),
+		}, {
+			Name:     "du",
+			Category: wdirGroup,
+			Action:   withDaemon(handleDiskUsage, true),
 		}, {
 			Name:     "mkdir",
 			Category: wdirGroup,
@@ -454,6 +567,46 @@ func RunCmdline(args []string) int {
 					Action:  withArgCheck(needAtLeast(1), withDaemon(handleTrashRemove, true)),
 				},
 			},
+		}, {
+			Name:     "quota",
+			Category: repoGroup,
+			Action:   withDaemon(handleQuotaList, true),
+			Subcommands: []cli.Command{
+				{
+					Name:   "set",
+					Action: withArgCheck(needAtLeast(2), withDaemon(handleQuotaSet, true)),
+				},
+				{
+					Name:    "remove",
+					Aliases: []string{"rm"},
+					Action:  withArgCheck(needAtLeast(1), withDaemon(handleQuotaRemove, true)),
+				},
+				{
+					Name:    "list",
+					Aliases: []string{"ls"},
+					Action:  withDaemon(handleQuotaList, true),
+				},
+			},
+		}, {
+			Name:     "encryption",
+			Category: repoGroup,
+			Action:   withDaemon(handleEncryptionList, true),
+			Subcommands: []cli.Command{
+				{
+					Name:   "set",
+					Action: withArgCheck(needAtLeast(2), withDaemon(handleEncryptionSet, true)),
+				},
+				{
+					Name:    "remove",
+					Aliases: []string{"rm"},
+					Action:  withArgCheck(needAtLeast(1), withDaemon(handleEncryptionRemove, true)),
+				},
+				{
+					Name:    "list",
+					Aliases: []string{"ls"},
+					Action:  withDaemon(handleEncryptionList, true),
+				},
+			},
 		}, {
 			Name:     "gateway",
 			Aliases:  []string{"gw"},
@@ -500,6 +653,27 @@ func RunCmdline(args []string) int {
 						},
 					},
 				},
+				{
+					Name:    "token",
+					Aliases: []string{"t"},
+					Subcommands: []cli.Command{
+						{
+							Name:    "add",
+							Aliases: []string{"a"},
+							Action:  withArgCheck(needAtLeast(2), withDaemon(handleGatewayTokenAdd, true)),
+						},
+						{
+							Name:    "remove",
+							Aliases: []string{"rm"},
+							Action:  withArgCheck(needAtLeast(1), withDaemon(handleGatewayTokenRemove, true)),
+						},
+						{
+							Name:    "list",
+							Aliases: []string{"ls"},
+							Action:  withDaemon(handleGatewayTokenList, true),
+						},
+					},
+				},
 			},
 		}, {
 			Name:     "debug",
@@ -528,6 +702,63 @@ func RunCmdline(args []string) int {
 			Name:     "gc",
 			Category: repoGroup,
 			Action:   withDaemon(handleGc, true),
+		}, {
+			Name:     "fsck",
+			Category: repoGroup,
+			Action:   withDaemon(handleFsck, true),
+		}, {
+			Name:     "backup",
+			Category: repoGroup,
+			Subcommands: []cli.Command{
+				{
+					Name:   "create",
+					Action: withArgCheck(needAtLeast(1), withDaemon(handleBackupCreate, true)),
+				}, {
+					Name:   "restore",
+					Action: withArgCheck(needAtLeast(1), handleBackupRestore),
+				}, {
+					Name:   "info",
+					Action: withArgCheck(needAtLeast(1), handleBackupInfo),
+				},
+			},
+		}, {
+			Name:     "repo",
+			Category: repoGroup,
+			Subcommands: []cli.Command{
+				{
+					Name: "keyslot",
+					Subcommands: []cli.Command{
+						{
+							Name:   "add",
+							Action: withArgCheck(needAtLeast(1), handleKeySlotAdd),
+						}, {
+							Name:    "list",
+							Aliases: []string{"ls"},
+							Action:  handleKeySlotList,
+						}, {
+							Name:    "remove",
+							Aliases: []string{"rm"},
+							Action:  withArgCheck(needAtLeast(1), handleKeySlotRemove),
+						},
+					},
+				},
+			},
+		}, {
+			Name:     "passwd",
+			Category: repoGroup,
+			Action:   handlePasswd,
+		}, {
+			Name:     "audit",
+			Category: repoGroup,
+			Subcommands: []cli.Command{
+				{
+					Name:   "log",
+					Action: withDaemon(handleAuditLog, true),
+				}, {
+					Name:   "verify",
+					Action: withDaemon(handleAuditVerify, true),
+				},
+			},
 		}, {
 			Name:   "docs",
 			Action: handleOpenHelp,
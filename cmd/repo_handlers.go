@@ -1,8 +1,11 @@
 package cmd
 
 import (
-	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/url"
 	"os"
@@ -14,12 +17,15 @@ import (
 
 	"github.com/fatih/color"
 	e "github.com/pkg/errors"
+	"github.com/sahib/brig/audit"
 	"github.com/sahib/brig/client"
 	"github.com/sahib/brig/cmd/pwd"
 	"github.com/sahib/brig/cmd/tabwriter"
 	"github.com/sahib/brig/gateway"
+	"github.com/sahib/brig/repo"
 	"github.com/sahib/brig/repo/setup"
 	"github.com/sahib/brig/server"
+	"github.com/sahib/brig/server/httpapi"
 	"github.com/sahib/brig/util"
 	"github.com/sahib/brig/util/pwutil"
 	"github.com/sahib/brig/version"
@@ -440,6 +446,13 @@ func handleDaemonLaunch(ctx *cli.Context) error {
 
 	defer util.Closer(server)
 
+	httpAPI, err := httpapi.Start(brigPath, bindHost)
+	if err != nil {
+		log.Warningf("failed to start the http api: %v", err)
+	} else {
+		defer util.Closer(httpAPI)
+	}
+
 	if err := server.Serve(); err != nil {
 		return ExitCode{
 			UnknownError,
@@ -555,6 +568,180 @@ func handleGc(ctx *cli.Context, ctl *client.Client) error {
 	return tabW.Flush()
 }
 
+func handleFsck(ctx *cli.Context, ctl *client.Client) error {
+	problems, err := ctl.Fsck(ctx.Bool("deep"), ctx.Bool("repair"))
+	if err != nil {
+		return err
+	}
+
+	if len(problems) == 0 {
+		fmt.Println("No problems found.")
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "KIND\tPATH\tHASH\tDETAIL\tREPAIRED\t")
+
+	unrepaired := 0
+	for _, problem := range problems {
+		hash := ""
+		if problem.Hash != nil {
+			hash = problem.Hash.ShortB58()
+		}
+
+		repaired := color.GreenString("yes")
+		if !problem.Repaired {
+			repaired = color.RedString("no")
+			unrepaired++
+		}
+
+		fmt.Fprintf(
+			tabW,
+			"%s\t%s\t%s\t%s\t%s\t\n",
+			color.YellowString(problem.Kind),
+			color.WhiteString(problem.Path),
+			hash,
+			problem.Detail,
+			repaired,
+		)
+	}
+
+	if err := tabW.Flush(); err != nil {
+		return err
+	}
+
+	if unrepaired > 0 {
+		return ExitCode{
+			UnknownError,
+			fmt.Sprintf("%d of %d problem(s) are not repaired", unrepaired, len(problems)),
+		}
+	}
+
+	return nil
+}
+
+func handleBackupCreate(ctx *cli.Context, ctl *client.Client) error {
+	path, err := filepath.Abs(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	parentPath := ""
+	if parent := ctx.String("parent"); parent != "" {
+		if parentPath, err = filepath.Abs(parent); err != nil {
+			return err
+		}
+	}
+
+	manifest, err := ctl.BackupCreate(path, parentPath, !ctx.Bool("no-content"))
+	if err != nil {
+		return ExitCode{UnknownError, fmt.Sprintf("backup failed: %v", err)}
+	}
+
+	kind := "full"
+	if manifest.Parent != "" {
+		kind = "incremental"
+	}
+
+	fmt.Printf("Wrote %s backup %s to %s", kind, manifest.ID, path)
+	if manifest.WithContent {
+		fmt.Printf(" (%d of %d objects)", manifest.NewBlobs, manifest.Blobs)
+	}
+
+	fmt.Println()
+	return nil
+}
+
+func readBackupPassword(ctx *cli.Context, folder string) (string, error) {
+	if password := readPasswordFromArgs(folder, ctx); password != "" {
+		return password, nil
+	}
+
+	return pwd.PromptPassword()
+}
+
+func handleBackupRestore(ctx *cli.Context) error {
+	folder := guessRepoFolder(ctx)
+	isInitialized, err := repoIsInitialized(folder)
+	if err != nil {
+		return err
+	}
+
+	if isInitialized {
+		return fmt.Errorf("`%s` already exists and is not empty; refusing to restore", folder)
+	}
+
+	password, err := readBackupPassword(ctx, folder)
+	if err != nil {
+		return ExitCode{UnknownError, fmt.Sprintf("failed to read password: %v", err)}
+	}
+
+	bundles := []io.Reader{}
+	for _, path := range ctx.Args() {
+		fd, err := os.Open(path) // #nosec
+		if err != nil {
+			return err
+		}
+
+		defer util.Closer(fd)
+		bundles = append(bundles, fd)
+	}
+
+	manifest, err := repo.RestoreBackup(folder, password, bundles...)
+	if err != nil {
+		// Do not leave a half restored repository behind:
+		os.RemoveAll(folder)
+		return ExitCode{UnknownError, fmt.Sprintf("restore failed: %v", err)}
+	}
+
+	fmt.Printf("Restored repository of %s to %s.\n", manifest.Owner, folder)
+	fmt.Println("Restored content is added to the backend on the next daemon start.")
+	return nil
+}
+
+func handleBackupInfo(ctx *cli.Context) error {
+	path := ctx.Args().First()
+	password, err := readBackupPassword(ctx, guessRepoFolder(ctx))
+	if err != nil {
+		return ExitCode{UnknownError, fmt.Sprintf("failed to read password: %v", err)}
+	}
+
+	fd, err := os.Open(path) // #nosec
+	if err != nil {
+		return err
+	}
+
+	defer util.Closer(fd)
+
+	manifest, err := repo.ReadBackupManifest(fd, password)
+	if err != nil {
+		return ExitCode{BadArgs, err.Error()}
+	}
+
+	parent := manifest.Parent
+	if parent == "" {
+		parent = "none (full backup)"
+	}
+
+	fmt.Printf("ID:       %s\n", manifest.ID)
+	fmt.Printf("Parent:   %s\n", parent)
+	fmt.Printf("Owner:    %s\n", manifest.Owner)
+	fmt.Printf("Backend:  %s\n", manifest.Backend)
+	fmt.Printf("Created:  %s\n", manifest.Created.Format(time.RFC3339))
+	fmt.Printf("Metadata: %s\n", strings.Join(manifest.Owners, ", "))
+	if manifest.WithContent {
+		fmt.Printf("Content:  %d objects (%d in this bundle)\n", len(manifest.Blobs), manifest.NewBlobs)
+	} else {
+		fmt.Printf("Content:  not included\n")
+	}
+
+	return nil
+}
+
 func handleFstabAdd(ctx *cli.Context, ctl *client.Client) error {
 	mountName := ctx.Args().Get(0)
 	mountPath := ctx.Args().Get(1)
@@ -892,8 +1079,7 @@ func handleGatewayCert(ctx *cli.Context) error {
 
 	fmt.Println("A certificate was downloaded successfully.")
 
-	port := guessPort(ctx, true)
-	ctl, err := client.Dial(context.Background(), port)
+	ctl, err := dialDaemon(ctx, guessRepoFolder(ctx))
 	if err != nil {
 		fmt.Println("There does not seem a daemon running currently.")
 		fmt.Println("Please execute the following commands when it is running:")
@@ -1052,7 +1238,7 @@ func handleGatewayUserList(ctx *cli.Context, ctl *client.Client) error {
 		if len(users) == 0 {
 			fmt.Println("No users. Add some with »brig gw user add <name> <pass> <folders...>«")
 		} else {
-			fmt.Fprintln(tabW, "NAME\tFOLDERS\tRIGHTS\t")
+			fmt.Fprintln(tabW, "NAME\tFOLDERS\tRIGHTS\tFOLDER RIGHTS\t")
 		}
 	}
 
@@ -1067,10 +1253,96 @@ func handleGatewayUserList(ctx *cli.Context, ctl *client.Client) error {
 
 		fmt.Fprintf(
 			tabW,
-			"%s\t%s\t%s\t\n",
+			"%s\t%s\t%s\t%s\t\n",
 			user.Name,
 			strings.Join(user.Folders, ","),
 			strings.Join(user.Rights, ","),
+			strings.Join(user.FolderRights, " "),
+		)
+	}
+
+	return tabW.Flush()
+}
+
+func handleGatewayTokenAdd(ctx *cli.Context, ctl *client.Client) error {
+	user := ctx.Args().Get(0)
+	name := ctx.Args().Get(1)
+	folders := []string{}
+	if len(ctx.Args()) > 2 {
+		folders = ctx.Args()[2:]
+	}
+
+	rights := []string{}
+	if r := ctx.String("rights"); r != "" {
+		rights = strings.Split(r, ",")
+	}
+
+	token, err := ctl.GatewayTokenAdd(user, name, folders, rights)
+	if err != nil {
+		return ExitCode{UnknownError, fmt.Sprintf("failed to add token: %v", err)}
+	}
+
+	fmt.Println(token)
+	return nil
+}
+
+func handleGatewayTokenRemove(ctx *cli.Context, ctl *client.Client) error {
+	for _, id := range ctx.Args() {
+		if err := ctl.GatewayTokenRemove(id); err != nil {
+			fmt.Printf("Failed to remove token »%s«: %v\n", id, err)
+		}
+	}
+
+	return nil
+}
+
+func handleGatewayTokenList(ctx *cli.Context, ctl *client.Client) error {
+	tokens, err := ctl.GatewayTokenList(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	tmpl, err := readFormatTemplate(ctx)
+	if err != nil {
+		return err
+	}
+
+	if tmpl == nil {
+		if len(tokens) == 0 {
+			fmt.Println("No tokens. Add some with »brig gw token add <user> <name> <folders...>«")
+		} else {
+			fmt.Fprintln(tabW, "ID\tUSER\tNAME\tFOLDERS\tRIGHTS\tLAST USED\t")
+		}
+	}
+
+	for _, token := range tokens {
+		if tmpl != nil {
+			if err := tmpl.Execute(os.Stdout, token); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		lastUsed := "never"
+		if !token.LastUsed.IsZero() {
+			lastUsed = token.LastUsed.Format(time.UnixDate)
+		}
+
+		fmt.Fprintf(
+			tabW,
+			"%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			token.ID,
+			token.User,
+			token.Name,
+			strings.Join(token.Folders, ","),
+			strings.Join(token.Rights, ","),
+			lastUsed,
 		)
 	}
 
@@ -1092,3 +1364,257 @@ func handleDebugPprofPort(ctx *cli.Context, ctl *client.Client) error {
 
 	return nil
 }
+
+// parseSince accepts a timestamp like »2006-01-02« or RFC3339 or a
+// duration that is interpreted relative to now (»2h«, »7d«).
+func parseSince(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	sec, err := parseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("neither a date nor a duration: %s", s)
+	}
+
+	return time.Now().Add(-time.Duration(sec * float64(time.Second))), nil
+}
+
+func handleAuditLog(ctx *cli.Context, ctl *client.Client) error {
+	query := audit.Query{
+		User:   ctx.String("user"),
+		Path:   ctx.String("path"),
+		Action: ctx.String("action"),
+	}
+
+	if ctx.IsSet("since") {
+		since, err := parseSince(ctx.String("since"))
+		if err != nil {
+			return ExitCode{BadArgs, err.Error()}
+		}
+
+		query.Since = since
+	}
+
+	entries, err := ctl.AuditLog(query)
+	if err != nil {
+		return ExitCode{UnknownError, fmt.Sprintf("audit log: %v", err)}
+	}
+
+	if ctx.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		for _, entry := range entries {
+			if err := enc.Encode(entry); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	tmpl, err := readFormatTemplate(ctx)
+	if err != nil {
+		return err
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	if tmpl == nil {
+		if len(entries) == 0 {
+			fmt.Println("No matching entries.")
+		} else {
+			fmt.Fprintln(tabW, "SEQ\tTIME\tSOURCE\tUSER\tACTION\tPATH\tDETAIL\t")
+		}
+	}
+
+	for _, entry := range entries {
+		if tmpl != nil {
+			if err := tmpl.Execute(os.Stdout, entry); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		fmt.Fprintf(
+			tabW,
+			"%d\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			entry.Seq,
+			entry.Time.Local().Format(time.Stamp),
+			entry.Source,
+			entry.User,
+			entry.Action,
+			entry.Path,
+			entry.Detail,
+		)
+	}
+
+	return tabW.Flush()
+}
+
+func handleAuditVerify(ctx *cli.Context, ctl *client.Client) error {
+	count, head, err := ctl.AuditVerify()
+	if err != nil {
+		return ExitCode{UnknownError, fmt.Sprintf("audit log is not intact: %v", err)}
+	}
+
+	fmt.Printf("The audit log is intact (%d entries).\n", count)
+	if head != "" {
+		fmt.Printf("Hash of the last entry: %s\n", head)
+	}
+
+	return nil
+}
+
+func readKeyfile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path) // #nosec
+	if err == nil {
+		return strings.Trim(string(data), "\n"), nil
+	}
+
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	// Generate a new recovery key if the file does not exist yet:
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	secret := hex.EncodeToString(key)
+	if err := ioutil.WriteFile(path, []byte(secret+"\n"), 0600); err != nil {
+		return "", err
+	}
+
+	fmt.Printf("Wrote a new key to %s. Keep it somewhere safe.\n", path)
+	return secret, nil
+}
+
+func handleKeySlotAdd(ctx *cli.Context) error {
+	folder := guessRepoFolder(ctx)
+	name := ctx.Args().First()
+
+	password, err := readBackupPassword(ctx, folder)
+	if err != nil {
+		return ExitCode{UnknownError, fmt.Sprintf("failed to read password: %v", err)}
+	}
+
+	if err := repo.CheckPassword(folder, password); err != nil {
+		return ExitCode{BadPassword, err.Error()}
+	}
+
+	kind, secret := repo.KeySlotPassword, ""
+	if keyfile := ctx.String("keyfile"); keyfile != "" {
+		kind = repo.KeySlotKeyfile
+		if secret, err = readKeyfile(keyfile); err != nil {
+			return err
+		}
+	} else {
+		secretBytes, err := pwd.PromptNewPassword(20)
+		if err != nil {
+			return ExitCode{UnknownError, fmt.Sprintf("failed to read password: %v", err)}
+		}
+
+		secret = string(secretBytes)
+	}
+
+	if err := repo.AddKeySlot(folder, password, name, kind, secret); err != nil {
+		return err
+	}
+
+	fmt.Printf("Added key slot %s.\n", name)
+	return nil
+}
+
+func handleKeySlotList(ctx *cli.Context) error {
+	slots, err := repo.ListKeySlots(guessRepoFolder(ctx))
+	if err != nil {
+		return err
+	}
+
+	if len(slots) == 0 {
+		fmt.Println("No key slots yet. The repository is opened by its initial password.")
+		return nil
+	}
+
+	tabW := tabwriter.NewWriter(
+		os.Stdout, 0, 0, 2, ' ',
+		tabwriter.StripEscape,
+	)
+
+	fmt.Fprintln(tabW, "NAME\tKIND\tCREATED\t")
+
+	for _, slot := range slots {
+		fmt.Fprintf(
+			tabW,
+			"%s\t%s\t%s\t\n",
+			color.CyanString(slot.Name),
+			slot.Kind,
+			slot.CreatedAt.Format(time.RFC3339),
+		)
+	}
+
+	return tabW.Flush()
+}
+
+func handleKeySlotRemove(ctx *cli.Context) error {
+	folder := guessRepoFolder(ctx)
+	name := ctx.Args().First()
+
+	password, err := readBackupPassword(ctx, folder)
+	if err != nil {
+		return ExitCode{UnknownError, fmt.Sprintf("failed to read password: %v", err)}
+	}
+
+	if err := repo.RemoveKeySlot(folder, password, name); err != nil {
+		if err == repo.ErrBadPassword {
+			return ExitCode{BadPassword, err.Error()}
+		}
+
+		return err
+	}
+
+	fmt.Printf("Removed key slot %s.\n", name)
+	return nil
+}
+
+func handlePasswd(ctx *cli.Context) error {
+	folder := guessRepoFolder(ctx)
+
+	// A running daemon keeps using the old password (e.g. for backups),
+	// which would make them impossible to restore with the new one.
+	if ctl, err := dialDaemon(ctx, folder); err == nil {
+		ctl.Close()
+		return ExitCode{
+			UnknownError,
+			"the daemon is running; stop it with »brig daemon quit« first",
+		}
+	}
+
+	password, err := readBackupPassword(ctx, folder)
+	if err != nil {
+		return ExitCode{UnknownError, fmt.Sprintf("failed to read password: %v", err)}
+	}
+
+	if err := repo.CheckPassword(folder, password); err != nil {
+		return ExitCode{BadPassword, err.Error()}
+	}
+
+	newPassword, err := pwd.PromptNewPassword(20)
+	if err != nil {
+		return ExitCode{UnknownError, fmt.Sprintf("failed to read password: %v", err)}
+	}
+
+	if err := repo.ChangePassword(folder, password, string(newPassword)); err != nil {
+		return err
+	}
+
+	fmt.Println("Password changed. Remember to update your password helper, if any.")
+	return nil
+}
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -9,7 +8,6 @@ import (
 	"strings"
 
 	"github.com/fatih/color"
-	"github.com/sahib/brig/client"
 	"github.com/urfave/cli"
 	"github.com/xrash/smetrics"
 )
@@ -138,10 +136,8 @@ func completeLocalPath(ctx *cli.Context) {
 
 func completeBrigPath(allowFiles, allowDirs bool) func(ctx *cli.Context) {
 	return func(ctx *cli.Context) {
-		port := guessPort(ctx, true)
-
 		// Check if the daemon is running:
-		ctl, err := client.Dial(context.Background(), port)
+		ctl, err := dialDaemon(ctx, guessRepoFolder(ctx))
 		if err != nil {
 			return
 		}
@@ -10,6 +10,7 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -17,11 +18,14 @@ import (
 	"text/template"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/fatih/color"
+	isatty "github.com/mattn/go-isatty"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/sahib/brig/client"
 	"github.com/sahib/brig/cmd/pwd"
 	"github.com/sahib/brig/defaults"
+	"github.com/sahib/brig/server"
 	"github.com/sahib/brig/util/pwutil"
 	"github.com/sahib/config"
 	log "github.com/sirupsen/logrus"
@@ -74,11 +78,17 @@ func checkmarkify(val bool) string {
 // guessRepoFolder tries to find the repository path
 // by using a number of sources.
 // This helper may call exit when it fails to get the path.
+// If --repo is the name of a repository served by the daemon
+// of the default repository, the path of the default repository is returned.
 func guessRepoFolder(ctx *cli.Context) string {
-	if argPath := ctx.GlobalString("repo"); argPath != "" {
+	if argPath := ctx.GlobalString("repo"); argPath != "" && guessRepoName(ctx) == "" {
 		return mustAbsPath(argPath)
 	}
 
+	return defaultRepoFolder()
+}
+
+func defaultRepoFolder() string {
 	dir, err := homedir.Expand("~/.brig")
 	if err != nil {
 		fmt.Printf("failed to expand home dir: %v; aborting.", err)
@@ -88,6 +98,49 @@ func guessRepoFolder(ctx *cli.Context) string {
 	return mustAbsPath(dir)
 }
 
+// guessRepoName returns the value of --repo if it is not a path, but the
+// name of a repository in daemon.repos of the default repository.
+// Otherwise the empty string is returned.
+func guessRepoName(ctx *cli.Context) string {
+	name := ctx.GlobalString("repo")
+	if name == "" || strings.ContainsRune(name, os.PathSeparator) {
+		return ""
+	}
+
+	// Existing paths win over names:
+	if _, err := os.Stat(name); err == nil {
+		return ""
+	}
+
+	cfg, err := defaults.OpenMigratedConfig(filepath.Join(defaultRepoFolder(), "config.yml"))
+	if err != nil {
+		return ""
+	}
+
+	if _, ok := server.ServedRepos(cfg)[name]; !ok {
+		return ""
+	}
+
+	return name
+}
+
+// dialDaemon connects to the daemon of the repository at `folder`.
+// If a token was given, the daemon is reached over TCP instead of its socket.
+// If --repo names a repository, the daemon is asked to use it.
+func dialDaemon(ctx *cli.Context, folder string) (*client.Client, error) {
+	opts := []client.DialOption{}
+	if name := guessRepoName(ctx); name != "" {
+		opts = append(opts, client.WithRepo(name))
+	}
+
+	if token := ctx.GlobalString("token"); token != "" {
+		host := ctx.GlobalString("bind")
+		return client.DialTCP(context.Background(), host, guessPort(ctx, true), token, opts...)
+	}
+
+	return client.Dial(context.Background(), folder, opts...)
+}
+
 func guessNextFreePort(ctx *cli.Context) (int, error) {
 	// This can be overwritten by specifying -p $SOME_PORT.
 	// Use this if we want
@@ -254,9 +307,8 @@ func startDaemon(ctx *cli.Context, repoPath string, port int) (*client.Client, e
 
 	logVerbose(
 		ctx,
-		"No Daemon running at %s:%d. Starting daemon from binary: %s",
-		bindHost,
-		port,
+		"No Daemon running for %s. Starting daemon from binary: %s",
+		repoPath,
 		exePath,
 	)
 
@@ -296,7 +348,7 @@ func startDaemon(ctx *cli.Context, repoPath string, port int) (*client.Client, e
 
 	warningPrinted := false
 	for i := 0; i < 500; i++ {
-		ctl, err := client.Dial(context.Background(), port)
+		ctl, err := dialDaemon(ctx, repoPath)
 		if err != nil {
 			// Only print this warning once...
 			if !warningPrinted && i >= 100 {
@@ -344,15 +396,15 @@ func guessNextRepoFolder(ctx *cli.Context) string {
 
 func withDaemon(handler cmdHandlerWithClient, startNew bool) cli.ActionFunc {
 	return func(ctx *cli.Context) error {
-		port := guessPort(ctx, true)
+		folder := guessRepoFolder(ctx)
 		if startNew {
-			logVerbose(ctx, "using port %d to check for running daemon.", port)
+			logVerbose(ctx, "using repo '%s' to check for running daemon.", folder)
 		} else {
-			logVerbose(ctx, "using port %d to connect to old daemon.", port)
+			logVerbose(ctx, "using repo '%s' to connect to old daemon.", folder)
 		}
 
 		// Check if the daemon is running already:
-		ctl, err := client.Dial(context.Background(), port)
+		ctl, err := dialDaemon(ctx, folder)
 		if err == nil {
 			defer ctl.Close()
 			return handler(ctx, ctl)
@@ -364,10 +416,9 @@ func withDaemon(handler cmdHandlerWithClient, startNew bool) cli.ActionFunc {
 		}
 
 		// Start the server & pass the password:
-		folder := guessRepoFolder(ctx)
 		logVerbose(ctx, "starting new daemon in background, on folder '%s'", folder)
 
-		ctl, err = startDaemon(ctx, folder, port)
+		ctl, err = startDaemon(ctx, folder, guessPort(ctx, true))
 		if err != nil {
 			return ExitCode{
 				DaemonNotResponding,
@@ -535,13 +586,21 @@ func edit(data []byte, suffix string) ([]byte, error) {
 // parseDuration tries to convert the string `s` to
 // a duration in seconds (+ fractions).
 // It uses time.ParseDuration() internally, but allows
-// whole numbers which are counted as seconds.
+// whole numbers which are counted as seconds and
+// numbers with a "d" suffix which are counted as days.
 func parseDuration(s string) (float64, error) {
 	sec, err := strconv.ParseFloat(s, 64)
 	if err == nil {
 		return sec, nil
 	}
 
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64)
+		if err == nil {
+			return days * 24 * 60 * 60, nil
+		}
+	}
+
 	dur, err := time.ParseDuration(s)
 	if err != nil {
 		return 0.0, err
@@ -586,6 +645,71 @@ func yesOrNo(v bool) string {
 	return color.RedString("no")
 }
 
+func formatJobStatus(status *client.JobStatus) string {
+	desc := fmt.Sprintf("%s with %s: %s", status.Kind, status.Remote, status.Phase)
+	if status.BytesDone > 0 {
+		desc += fmt.Sprintf(" (%s", humanize.Bytes(uint64(status.BytesDone)))
+		if status.BytesTotal > 0 {
+			desc += fmt.Sprintf(" of %s", humanize.Bytes(uint64(status.BytesTotal)))
+		}
+
+		desc += ")"
+	}
+
+	if status.FilesDone > 0 {
+		desc += fmt.Sprintf(" (%d", status.FilesDone)
+		if status.FilesTotal > 0 {
+			desc += fmt.Sprintf(" of %d", status.FilesTotal)
+		}
+
+		desc += " files)"
+	}
+
+	return desc
+}
+
+// waitForJob waits until the job behind `ticket` finished.
+// If `quiet` is false, the progress of the job is printed.
+// Pressing Ctrl-C will ask the daemon to cancel the job.
+func waitForJob(ctl *client.Client, ticket uint64, quiet bool) error {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
+
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	go func() {
+		select {
+		case <-sigCh:
+			fmt.Println()
+			fmt.Println("Canceling... (this might take a moment)")
+			if err := ctl.JobCancel(ticket); err != nil {
+				log.Warningf("failed to cancel: %v", err)
+			}
+		case <-doneCh:
+		}
+	}()
+
+	lastLen := 0
+	err := ctl.JobWait(ticket, 250*time.Millisecond, func(status *client.JobStatus) {
+		if quiet || !isatty.IsTerminal(os.Stdout.Fd()) {
+			return
+		}
+
+		// Pad with spaces to overwrite longer, previous lines.
+		desc := formatJobStatus(status)
+		fmt.Printf("\r%-*s", lastLen, desc)
+		lastLen = len(desc)
+	})
+
+	if lastLen > 0 {
+		fmt.Printf("\r%-*s\r", lastLen, "")
+	}
+
+	return err
+}
+
 type logWriter struct{ prefix string }
 
 func (lw *logWriter) Write(buf []byte) (int, error) {
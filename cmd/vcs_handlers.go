@@ -44,6 +44,35 @@ func commitName(cmt *client.Commit) string {
 	return cmt.Hash.ShortB58()
 }
 
+func handleHistoryPrune(ctx *cli.Context, ctl *client.Client) error {
+	if !ctx.IsSet("keep") && !ctx.IsSet("keep-last") {
+		return ExitCode{BadArgs, "please specify either --keep or --keep-last"}
+	}
+
+	var keep time.Duration
+	if ctx.IsSet("keep") {
+		keepSec, err := parseDuration(ctx.String("keep"))
+		if err != nil {
+			return ExitCode{BadArgs, fmt.Sprintf("bad --keep: %v", err)}
+		}
+
+		keep = time.Duration(keepSec * float64(time.Second))
+	}
+
+	nPruned, err := ctl.HistoryPrune(keep, ctx.Int("keep-last"))
+	if err != nil {
+		return ExitCode{UnknownError, fmt.Sprintf("prune: %v", err)}
+	}
+
+	if nPruned == 0 {
+		fmt.Println("Nothing to prune.")
+		return nil
+	}
+
+	fmt.Printf("Removed %d commits.\n", nPruned)
+	return nil
+}
+
 func handleHistory(ctx *cli.Context, ctl *client.Client) error {
 	path := ctx.Args().First()
 
@@ -441,7 +470,12 @@ func handleDiff(ctx *cli.Context, ctl *client.Client) error {
 
 func handleFetch(ctx *cli.Context, ctl *client.Client) error {
 	who := ctx.Args().First()
-	return ctl.Fetch(who)
+	ticket, err := ctl.FetchStart(who)
+	if err != nil {
+		return err
+	}
+
+	return waitForJob(ctl, ticket, false)
 }
 
 func handleSync(ctx *cli.Context, ctl *client.Client) error {
@@ -476,15 +510,25 @@ func handleSyncSingle(ctx *cli.Context, ctl *client.Client, remoteName string) e
 		needFetch = false
 	}
 
-	if ctx.Bool("quiet") {
-		return nil
+	ticket, err := ctl.SyncStart(remoteName, needFetch, ctx.StringSlice("only"))
+	if err != nil {
+		return err
+	}
+
+	quiet := ctx.Bool("quiet")
+	if err := waitForJob(ctl, ticket, quiet); err != nil {
+		return err
 	}
 
-	diff, err := ctl.Sync(remoteName, needFetch)
+	diff, err := ctl.SyncResult(ticket)
 	if err != nil {
 		return err
 	}
 
+	if quiet {
+		return nil
+	}
+
 	if isEmptyDiff(diff) {
 		fmt.Println("Nothing changed.")
 		return nil
@@ -601,6 +645,24 @@ func handleTag(ctx *cli.Context, ctl *client.Client) error {
 	return nil
 }
 
+// signatureMarker returns a single symbol describing
+// the state of a commit's signature.
+func signatureMarker(state string) string {
+	switch state {
+	case "good":
+		return color.GreenString("✔")
+	case "bad":
+		return color.RedString("✘")
+	case "unsigned":
+		return color.YellowString("-")
+	case "unknown":
+		return color.YellowString("?")
+	default:
+		// The staging commit is not signed.
+		return " "
+	}
+}
+
 func handleLog(ctx *cli.Context, ctl *client.Client) error {
 	entries, err := ctl.Log()
 	if err != nil {
@@ -639,16 +701,15 @@ func handleLog(ctx *cli.Context, ctl *client.Client) error {
 			msg = color.RedString("•")
 		}
 
-		entry.Hash.ShortB58()
-
 		commitHash := entry.Hash.ShortB58()
 		if isCurr {
 			commitHash = "      -     "
 		}
 
 		fmt.Printf(
-			"%s %s %s%s\n",
+			"%s %s %s %s%s\n",
 			color.GreenString(commitHash),
+			signatureMarker(entry.Signature),
 			color.YellowString(entry.Date.Format(time.UnixDate)),
 			msg,
 			color.CyanString(tags),
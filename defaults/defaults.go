@@ -1,9 +1,13 @@
 package defaults
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 
+	humanize "github.com/dustin/go-humanize"
 	e "github.com/pkg/errors"
+	"github.com/sahib/brig/util/throttle"
 	"github.com/sahib/config"
 )
 
@@ -13,6 +17,20 @@ const CurrentVersion = 0
 // Defaults is the default validation for brig
 var Defaults = DefaultsV0
 
+const (
+	// DaemonSocketName is the name of the unix socket in the repository
+	// that the daemon listens on for local clients.
+	DaemonSocketName = "brig.socket"
+
+	// DaemonHTTPSocketName is the name of the unix socket in the repository
+	// that the daemon serves its JSON over HTTP api on.
+	DaemonHTTPSocketName = "brig-http.socket"
+
+	// DaemonTokenName is the name of the file in the repository that
+	// holds the token clients need to send when connecting over TCP.
+	DaemonTokenName = "daemon.token"
+)
+
 // OpenMigratedConfig takes the config.yml at path and loads it.
 // If required, it also migrates the config structure to the newest
 // version - brig can always rely on the latest config keys to be present.
@@ -35,3 +53,46 @@ func OpenMigratedConfig(path string) (*config.Config, error) {
 
 	return cfg, nil
 }
+
+func rateValidator(val interface{}) error {
+	s, ok := val.(string)
+	if !ok {
+		return fmt.Errorf("rate is not a string: %v", val)
+	}
+
+	_, err := throttle.ParseRate(s)
+	return err
+}
+
+func windowValidator(val interface{}) error {
+	s, ok := val.(string)
+	if !ok {
+		return fmt.Errorf("window is not a string: %v", val)
+	}
+
+	_, err := throttle.ParseWindow(s)
+	return err
+}
+
+func sizeValidator(val interface{}) error {
+	s, ok := val.(string)
+	if !ok {
+		return fmt.Errorf("size is not a string: %v", val)
+	}
+
+	_, err := humanize.ParseBytes(s)
+	return err
+}
+
+func absPathValidator(val interface{}) error {
+	s, ok := val.(string)
+	if !ok {
+		return fmt.Errorf("path is not a string: %v", val)
+	}
+
+	if s != "" && !filepath.IsAbs(s) {
+		return fmt.Errorf("path is not absolute: %s", s)
+	}
+
+	return nil
+}
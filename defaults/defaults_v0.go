@@ -10,9 +10,40 @@ var DefaultsV0 = config.DefaultMapping{
 		"port": config.DefaultEntry{
 			Default:      6666,
 			NeedsRestart: true,
-			Docs:         "Port of the daemon process.",
+			Docs:         "Port of the daemon process (only used if daemon.tcp.enabled is set).",
 			Validator:    config.IntRangeValidator(1, 655356),
 		},
+		"tcp": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      false,
+				NeedsRestart: true,
+				Docs:         "Accept clients over TCP on daemon.port. They need to send the token from the daemon.token file in the repository.",
+			},
+		},
+		"http": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      false,
+				NeedsRestart: true,
+				Docs:         "Offer the api as JSON over HTTP on the brig-http.socket in the repository.",
+			},
+			"port": config.DefaultEntry{
+				Default:      6680,
+				NeedsRestart: true,
+				Docs:         "Port of the JSON over HTTP api (only used if daemon.tcp.enabled is set).",
+				Validator:    config.IntRangeValidator(1, 655356),
+			},
+		},
+		"repos": config.DefaultMapping{
+			// This key stands for the name clients use for the repository:
+			"__many__": config.DefaultMapping{
+				"path": config.DefaultEntry{
+					Default:      "",
+					NeedsRestart: true,
+					Docs:         "Absolute path of another repository served by this daemon. Clients choose it with »brig --repo <name>«.",
+					Validator:    absPathValidator,
+				},
+			},
+		},
 		"ipfs_path": config.DefaultEntry{
 			Default:      "",
 			NeedsRestart: true,
@@ -51,6 +82,66 @@ var DefaultsV0 = config.DefaultMapping{
 			Docs:         "How many outgoing events per second to send out at max",
 		},
 	},
+	"net": config.DefaultMapping{
+		"lan": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      false,
+				NeedsRestart: true,
+				Docs:         "Find other peers on the local network via multicast and talk to them directly over TCP.",
+			},
+			"exclusive": config.DefaultEntry{
+				Default:      false,
+				NeedsRestart: true,
+				Docs:         "Only talk to peers on the local network; never use the backend's own transport.",
+			},
+			"port": config.DefaultEntry{
+				Default:      0,
+				NeedsRestart: true,
+				Docs:         "TCP port to accept connections from the local network on (0 picks a random port).",
+				Validator:    config.IntRangeValidator(0, 65535),
+			},
+			"group": config.DefaultEntry{
+				Default:      "239.255.66.99:7789",
+				NeedsRestart: true,
+				Docs:         "Multicast group (ip:port) used to find other peers. All peers need to use the same.",
+			},
+		},
+		"bandwidth": config.DefaultMapping{
+			"upload": config.DefaultEntry{
+				Default:      "unlimited",
+				NeedsRestart: false,
+				Docs:         "Max. rate (like »1MB«, per second) to send data to other peers with.",
+				Validator:    rateValidator,
+			},
+			"download": config.DefaultEntry{
+				Default:      "unlimited",
+				NeedsRestart: false,
+				Docs:         "Max. rate to receive data from other peers with (includes pinning and pre-caching).",
+				Validator:    rateValidator,
+			},
+			"schedule": config.DefaultEntry{
+				Default:      []string{},
+				NeedsRestart: false,
+				Docs: `Time windows with other limits than upload and download.
+
+  Each entry looks like »20:00-06:00 unlimited« or »08:00-18:00 512KB/2MB«
+  (upload/download). The first window that matches the current time is used.`,
+				Validator: config.ListValidator(windowValidator),
+			},
+		},
+		"rendezvous": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      false,
+				NeedsRestart: true,
+				Docs:         "Publish and resolve names with a rendezvous server instead of the global network (see »brig net rendezvous«).",
+			},
+			"server": config.DefaultEntry{
+				Default:      "",
+				NeedsRestart: true,
+				Docs:         "Address (host:port) of the rendezvous server to use.",
+			},
+		},
+	},
 	"gateway": config.DefaultMapping{
 		"enabled": config.DefaultEntry{
 			Default:      false,
@@ -74,6 +165,22 @@ var DefaultsV0 = config.DefaultMapping{
 				Docs:         "Enable debug mode (load resources from filesystem).",
 			},
 		},
+		"thumbnails": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs:         "Generate previews of images and PDF documents in the UI.",
+			},
+			"cache_size": config.DefaultEntry{
+				Default:      "50MB",
+				NeedsRestart: false,
+				Docs: `Maximum size of the thumbnail cache on disk.
+
+  If the cache grows bigger, the least recently used thumbnails are removed.
+`,
+				Validator: sizeValidator,
+			},
+		},
 		"cert": config.DefaultMapping{
 			"certfile": config.DefaultEntry{
 				Default:      "",
@@ -132,6 +239,55 @@ var DefaultsV0 = config.DefaultMapping{
 				NeedsRestart: true,
 				Docs:         "Key used for CSRF protection. Generated if empty.",
 			},
+			"oidc": config.DefaultMapping{
+				"enabled": config.DefaultEntry{
+					Default:      false,
+					NeedsRestart: false,
+					Docs:         "Allow logging in via an OpenID Connect provider (single sign-on).",
+				},
+				"issuer": config.DefaultEntry{
+					Default:      "",
+					NeedsRestart: false,
+					Docs:         "URL of the identity provider, like »https://accounts.example.org«.",
+				},
+				"client_id": config.DefaultEntry{
+					Default:      "",
+					NeedsRestart: false,
+					Docs:         "Client id the gateway is registered with at the provider.",
+				},
+				"client_secret": config.DefaultEntry{
+					Default:      "",
+					NeedsRestart: false,
+					Docs:         "Client secret the gateway is registered with at the provider.",
+				},
+				"redirect_url": config.DefaultEntry{
+					Default:      "",
+					NeedsRestart: false,
+					Docs: `URL the provider sends the user back to after logging in.
+
+  It has to point to /api/v0/oidc/callback of the gateway. If empty, it is
+  derived from the address the gateway was accessed with.
+`,
+				},
+				"user_claim": config.DefaultEntry{
+					Default:      "sub",
+					NeedsRestart: false,
+					Docs: `Which claim of the ID token is used as gateway user name.
+
+  Only use a claim the users can not change themselves at the provider.
+  Otherwise they could log in as any existing gateway user (including admins).
+  Many providers let their users choose their »preferred_username«.
+`,
+				},
+				"template_user": config.DefaultEntry{
+					Default:      "",
+					NeedsRestart: false,
+					Docs: `Create unknown users on their first login with the folders and rights of this user.
+
+  If empty, only users that were added with »brig gateway user add« may log in.
+`,
+				},
+			},
 		},
 	},
 	"fs": config.DefaultMapping{
@@ -159,6 +315,13 @@ var DefaultsV0 = config.DefaultMapping{
   * embrace: Take the remote version and replace ours with it.
 `,
 			},
+			"require_signatures": config.DefaultEntry{
+				Default:      false,
+				NeedsRestart: false,
+				Docs: `Reject history and patches from remotes that are unsigned
+or whose signature does not match the remote's key.
+If false, such history is accepted, but a warning is logged.`,
+			},
 		},
 		"compress": config.DefaultMapping{
 			"default_algo": config.DefaultEntry{
@@ -209,6 +372,26 @@ var DefaultsV0 = config.DefaultMapping{
 				Docs:         `Keep at max »n« versions of a pinned file and remove it even if it does not exceed quota.`,
 			},
 		},
+		"replicas": config.DefaultMapping{
+			"enabled": config.DefaultEntry{
+				Default:      true,
+				NeedsRestart: false,
+				Docs: `Exchange pin summaries with online remotes and check the replica
+policies (see »brig pin policy --help«).`,
+			},
+			"interval": config.DefaultEntry{
+				Default:      "10m",
+				NeedsRestart: false,
+				Docs:         "In what interval to exchange pin summaries and check the replica policies.",
+				Validator:    config.DurationValidator(),
+			},
+			"accept_requests": config.DefaultEntry{
+				Default:      false,
+				NeedsRestart: false,
+				Docs: `Pin files when remotes ask us to, because they lack replicas.
+Only files in our own tree are pinned and only while »fs.repin.quota« is not exceeded.`,
+			},
+		},
 		"autocommit": config.DefaultMapping{
 			"enabled": config.DefaultEntry{
 				Default:      true,
@@ -248,6 +431,29 @@ var DefaultsV0 = config.DefaultMapping{
 			},
 		},
 	},
+	"metrics": config.DefaultMapping{
+		"enabled": config.DefaultEntry{
+			Default:      false,
+			NeedsRestart: true,
+			Docs:         "Serve metrics of the daemon in the Prometheus text format.",
+		},
+		"host": config.DefaultEntry{
+			Default:      "localhost",
+			NeedsRestart: true,
+			Docs:         "Host or ip the metrics endpoint is listening on. Use 0.0.0.0 to allow scraping from other hosts.",
+		},
+		"port": config.DefaultEntry{
+			Default:      6690,
+			NeedsRestart: true,
+			Docs:         "Port of the metrics endpoint.",
+			Validator:    config.IntRangeValidator(1, 655356),
+		},
+		"path": config.DefaultEntry{
+			Default:      "/metrics",
+			NeedsRestart: true,
+			Docs:         "URL path under which the metrics are served.",
+		},
+	},
 	"mounts": config.DefaultMapping{
 		// This key stands for the fstab name entry:
 		"__many__": config.DefaultMapping{
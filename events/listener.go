@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/sahib/brig/events/backend"
+	"github.com/sahib/brig/metrics"
 	"github.com/sahib/config"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/time/rate"
@@ -184,6 +185,8 @@ func (lst *Listener) eventSendLoop() {
 			log.Errorf("event: failed to publish: %v", err)
 			return
 		}
+
+		metrics.EventsPublished.With(ev.Type.String()).Inc()
 	})
 }
 
@@ -223,6 +226,7 @@ func (lst *Listener) PublishEvent(ev Event) error {
 	case lst.evSendCh <- ev:
 		return nil
 	default:
+		metrics.EventsDropped.With(ev.Type.String()).Inc()
 		return fmt.Errorf("lost event: %v", ev)
 	}
 }
@@ -305,6 +309,7 @@ func (lst *Listener) listenSingle(ctx context.Context, topic string) error {
 		}
 
 		ev.Source = msg.Source()
+		metrics.EventsReceived.With(ev.Type.String()).Inc()
 
 		if lst.isClosed {
 			break
@@ -313,6 +318,7 @@ func (lst *Listener) listenSingle(ctx context.Context, topic string) error {
 		select {
 		case lst.evRecvCh <- *ev:
 		default:
+			metrics.EventsDropped.With(ev.Type.String()).Inc()
 			log.Warningf("dropped incoming event: %v", ev)
 		}
 	}
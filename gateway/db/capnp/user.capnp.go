@@ -14,12 +14,12 @@ type User struct{ capnp.Struct }
 const User_TypeID = 0x861de4463c5a4a22
 
 func NewUser(s *capnp.Segment) (User, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 5})
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 6})
 	return User{st}, err
 }
 
 func NewRootUser(s *capnp.Segment) (User, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 5})
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 6})
 	return User{st}, err
 }
 
@@ -140,12 +140,37 @@ func (s User) NewRights(n int32) (capnp.TextList, error) {
 	return l, err
 }
 
+func (s User) FolderRights() (capnp.TextList, error) {
+	p, err := s.Struct.Ptr(5)
+	return capnp.TextList{List: p.List()}, err
+}
+
+func (s User) HasFolderRights() bool {
+	p, err := s.Struct.Ptr(5)
+	return p.IsValid() || err != nil
+}
+
+func (s User) SetFolderRights(v capnp.TextList) error {
+	return s.Struct.SetPtr(5, v.List.ToPtr())
+}
+
+// NewFolderRights sets the folderRights field to a newly
+// allocated capnp.TextList, preferring placement in s's segment.
+func (s User) NewFolderRights(n int32) (capnp.TextList, error) {
+	l, err := capnp.NewTextList(s.Struct.Segment(), n)
+	if err != nil {
+		return capnp.TextList{}, err
+	}
+	err = s.Struct.SetPtr(5, l.List.ToPtr())
+	return l, err
+}
+
 // User_List is a list of User.
 type User_List struct{ capnp.List }
 
 // NewUser creates a new list of User.
 func NewUser_List(s *capnp.Segment, sz int32) (User_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 5}, sz)
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 6}, sz)
 	return User_List{l}, err
 }
 
@@ -166,25 +191,253 @@ func (p User_Promise) Struct() (User, error) {
 	return User{s}, err
 }
 
-const schema_a0b1c18bd0f965c4 = "x\xda\\\xca\xb1J\x03A\x18\xc4\xf1\x99\xdd;\x05\x09" +
-	"\x89\x0b[*\x82\xa5\xa0!m\x10\x14\x0b\x11\xab|\x85" +
-	"\x8d\xddj\xd6D\x89\xc9q{\x12-D\x85 \x8a\x0a" +
-	"VV\x16\x0a\xbe\x80\x9d\x9d\x08\xdak\xe1\x1b\xf8\x12V" +
-	"'\x17Hc\xf7\x9f\x1f3y\xb7\xacj\xf1+\x01\xb1" +
-	"\xf1X>\xbb\xbe\xb9\xb8\xfa3}\x0e3\xc5\xfc\xdd\xff" +
-	"~^\xbd=? \x8e\xc7\x81\xda\xc7\x04\xcdw\x11_" +
-	"3\xc4|\xder\x99\xef\xbb\xa3\xaannU\xb7]\xd2" +
-	"M\xaa\x07\xc1\xa7\x0b\xc3\xaco\x04\x9f\x02\x0dR\xac\x8e" +
-	"\x80\x88\x809\x9e\x03\xe4PS\x06\x8a\x86\xb4,\xf0l" +
-	"\x0f\x90SM\xb9V4JY*\xc0\\\x16\xcf\x81\xa6" +
-	"\xdc*\x1a\xad-5`nV\x00\xb9\xd0\x94'E\x13" +
-	"E\x96\x11`\x1e\xeb\x80\xdck\xca\x8bb\xa5\xeb\xf6=" +
-	"KP,\x81y\xe2B\xe8\xf7\xd2&*k.\xb4G" +
-	"\\\x09\xae\x93\x8d\xc6\xc9N\xaf\xd3\xf4i`\x19lh" +
-	"\x0e\xb9\x0c.\xa5\xbb\xadv\xf6_\xff\x02\x00\x00\xff\xff" +
-	"\x89\xf1=\x11"
+type Token struct{ capnp.Struct }
+
+// Token_TypeID is the unique identifier for the type Token.
+const Token_TypeID = 0x84d3789a406068a2
+
+func NewToken(s *capnp.Segment) (Token, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 8})
+	return Token{st}, err
+}
+
+func NewRootToken(s *capnp.Segment) (Token, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 8})
+	return Token{st}, err
+}
+
+func ReadRootToken(msg *capnp.Message) (Token, error) {
+	root, err := msg.RootPtr()
+	return Token{root.Struct()}, err
+}
+
+func (s Token) String() string {
+	str, _ := text.Marshal(0x84d3789a406068a2, s.Struct)
+	return str
+}
+
+func (s Token) Id() (string, error) {
+	p, err := s.Struct.Ptr(0)
+	return p.Text(), err
+}
+
+func (s Token) HasId() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s Token) IdBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(0)
+	return p.TextBytes(), err
+}
+
+func (s Token) SetId(v string) error {
+	return s.Struct.SetText(0, v)
+}
+
+func (s Token) User() (string, error) {
+	p, err := s.Struct.Ptr(1)
+	return p.Text(), err
+}
+
+func (s Token) HasUser() bool {
+	p, err := s.Struct.Ptr(1)
+	return p.IsValid() || err != nil
+}
+
+func (s Token) UserBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(1)
+	return p.TextBytes(), err
+}
+
+func (s Token) SetUser(v string) error {
+	return s.Struct.SetText(1, v)
+}
+
+func (s Token) Name() (string, error) {
+	p, err := s.Struct.Ptr(2)
+	return p.Text(), err
+}
+
+func (s Token) HasName() bool {
+	p, err := s.Struct.Ptr(2)
+	return p.IsValid() || err != nil
+}
+
+func (s Token) NameBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(2)
+	return p.TextBytes(), err
+}
+
+func (s Token) SetName(v string) error {
+	return s.Struct.SetText(2, v)
+}
+
+func (s Token) SecretHash() (string, error) {
+	p, err := s.Struct.Ptr(3)
+	return p.Text(), err
+}
+
+func (s Token) HasSecretHash() bool {
+	p, err := s.Struct.Ptr(3)
+	return p.IsValid() || err != nil
+}
+
+func (s Token) SecretHashBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(3)
+	return p.TextBytes(), err
+}
+
+func (s Token) SetSecretHash(v string) error {
+	return s.Struct.SetText(3, v)
+}
+
+func (s Token) Folders() (capnp.TextList, error) {
+	p, err := s.Struct.Ptr(4)
+	return capnp.TextList{List: p.List()}, err
+}
+
+func (s Token) HasFolders() bool {
+	p, err := s.Struct.Ptr(4)
+	return p.IsValid() || err != nil
+}
+
+func (s Token) SetFolders(v capnp.TextList) error {
+	return s.Struct.SetPtr(4, v.List.ToPtr())
+}
+
+// NewFolders sets the folders field to a newly
+// allocated capnp.TextList, preferring placement in s's segment.
+func (s Token) NewFolders(n int32) (capnp.TextList, error) {
+	l, err := capnp.NewTextList(s.Struct.Segment(), n)
+	if err != nil {
+		return capnp.TextList{}, err
+	}
+	err = s.Struct.SetPtr(4, l.List.ToPtr())
+	return l, err
+}
+
+func (s Token) Rights() (capnp.TextList, error) {
+	p, err := s.Struct.Ptr(5)
+	return capnp.TextList{List: p.List()}, err
+}
+
+func (s Token) HasRights() bool {
+	p, err := s.Struct.Ptr(5)
+	return p.IsValid() || err != nil
+}
+
+func (s Token) SetRights(v capnp.TextList) error {
+	return s.Struct.SetPtr(5, v.List.ToPtr())
+}
+
+// NewRights sets the rights field to a newly
+// allocated capnp.TextList, preferring placement in s's segment.
+func (s Token) NewRights(n int32) (capnp.TextList, error) {
+	l, err := capnp.NewTextList(s.Struct.Segment(), n)
+	if err != nil {
+		return capnp.TextList{}, err
+	}
+	err = s.Struct.SetPtr(5, l.List.ToPtr())
+	return l, err
+}
+
+func (s Token) Created() (string, error) {
+	p, err := s.Struct.Ptr(6)
+	return p.Text(), err
+}
+
+func (s Token) HasCreated() bool {
+	p, err := s.Struct.Ptr(6)
+	return p.IsValid() || err != nil
+}
+
+func (s Token) CreatedBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(6)
+	return p.TextBytes(), err
+}
+
+func (s Token) SetCreated(v string) error {
+	return s.Struct.SetText(6, v)
+}
+
+func (s Token) LastUsed() (string, error) {
+	p, err := s.Struct.Ptr(7)
+	return p.Text(), err
+}
+
+func (s Token) HasLastUsed() bool {
+	p, err := s.Struct.Ptr(7)
+	return p.IsValid() || err != nil
+}
+
+func (s Token) LastUsedBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(7)
+	return p.TextBytes(), err
+}
+
+func (s Token) SetLastUsed(v string) error {
+	return s.Struct.SetText(7, v)
+}
+
+// Token_List is a list of Token.
+type Token_List struct{ capnp.List }
+
+// NewToken creates a new list of Token.
+func NewToken_List(s *capnp.Segment, sz int32) (Token_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 8}, sz)
+	return Token_List{l}, err
+}
+
+func (s Token_List) At(i int) Token { return Token{s.List.Struct(i)} }
+
+func (s Token_List) Set(i int, v Token) error { return s.List.SetStruct(i, v.Struct) }
+
+func (s Token_List) String() string {
+	str, _ := text.MarshalList(0x84d3789a406068a2, s.List)
+	return str
+}
+
+// Token_Promise is a wrapper for a Token promised by a client call.
+type Token_Promise struct{ *capnp.Pipeline }
+
+func (p Token_Promise) Struct() (Token, error) {
+	s, err := p.Pipeline.Struct()
+	return Token{s}, err
+}
+
+const schema_a0b1c18bd0f965c4 = "x\xda\x9c\xd11h\x13Q\x1c\xc7\xf1\xdf\xef\xfdsI" +
+	"\x91\xda\xf4\x99\x03\x8bT\x10GAcq+\xa2\xa5\x83" +
+	"\x94N}\xc6\"t\xf2L\x9eMjL\xc2\xbd\x93\xea" +
+	"\xd4E\\\\]\x14\x85 \x0a\x0a.n.\x82\x82\xe0" +
+	"\xe2\xd0b\x05\x85\x0a\x1d\x14\x14\\\x04\x1d\x02\xca\xc9\xab" +
+	"6\x1eA\x1c\xdc\xfe\xef{\x7f8\xde\xe7\x1d>\xc6)" +
+	"5\x11\xecV\x80\x19\x0b\xf2\xe9\x9d\xfa\x99\xa9\x9b\x97^" +
+	"]\x81\x1eg\xfa\xdc\xf6V\xaf={\xd4E0T\x00" +
+	"&\xbe\xecb\x89\xf4\xd3\x8f\x17\x04\xd3\xfd\xb3\x0bGO" +
+	"\xbc\xdf{u`7_\x00\x8e|R;X\xea)?" +
+	"~S\xa7\x89\x83\xe9b\x94\xd8\xe5\xe8rYjg\xcb" +
+	"\xd5\xa8\xd3\xea\x94/:\x1b\x1f\xda\x1a'O\xb5\xcf[" +
+	"\xb6\xe6H\xb3Or@\x8e\x80^\xdb\x03\x98\x97B\xf3" +
+	"FQ\x93!}|}\x000\xabB\xb3\xa1\xa8\x95\x0a" +
+	"\xa9\x00\xfd\xd6\xc7u\xa1\xd9T\xd4\"!\x05\xd0\xef\x16" +
+	"\x00\xb3!4\x1f\x15u.\x172\x07\xe8\x0f\xd3\x80\xd9" +
+	"\x14\x9a\xef\x8a:\x08B\x06\x80\xeeM\x02\xe6\xab\xb02" +
+	"JE\x9d\xcf\x87\xcc\x03\xa5\x9d\x9c\x06*C\x14VB" +
+	"\xdf\x0b\x85\xd0\xdf\xbd\xa49\x0bTF}\x1f\xa7\xa24" +
+	"j\x1c\x86\xe20X\xf4\x17\xea\x1fZ\xd1\x05\xbb}H" +
+	"\x9d\xad\xc66\x99\x89 \xae\xbe\x1dW\xce\xb5\x9b5\x1b" +
+	";\x8e\x80s\xc2\xad<\x02\x1e\x8f\x1b\x8b\xf5d\xb0\xae" +
+	"Tc\x1b%\xb6\xff\xaf\xb4\x19\xb9d\xde\xd9\x1a\x80~" +
+	"\xfb'\xf1\xbc\xb31\xe0\x89\xc7\xfa\xc47<\xdcu\xa1" +
+	"\xe9f\x88o/\x01\xe6\x96\xd0\xdc\xcf\x10\xdf\xf3\x9b]" +
+	"\xa1y\x98!~\xe05\xef\x0a\xcd\xd3\x0c\xf1\x13\xaf\xf9" +
+	"Xh\xd63\xc4kK\xbf\x9f\xed\xb3\x1a\x90\xe9D\xce" +
+	"-\xb7\xe3\x1a\x8a3\xd1\x1f\x9b\xa2\x8b\x9a\xc9\x7fA\xa5" +
+	"\xbf\x96O6P\xfc\xcb\xd7\x9f\x03\x00\xc6y\x97\xc3"
 
 func init() {
 	schemas.Register(schema_a0b1c18bd0f965c4,
+		0x84d3789a406068a2,
 		0x861de4463c5a4a22)
 }
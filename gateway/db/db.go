@@ -5,6 +5,7 @@ import (
 	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -169,12 +170,33 @@ func UserFromCapnp(capUser capnp.User) (*User, error) {
 		return nil, err
 	}
 
+	capFolderRights, err := capUser.FolderRights()
+	if err != nil {
+		return nil, err
+	}
+
+	folderRights := []FolderRights{}
+	for idx := 0; idx < capFolderRights.Len(); idx++ {
+		spec, err := capFolderRights.At(idx)
+		if err != nil {
+			return nil, err
+		}
+
+		fr, _, err := ParseFolderRights(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		folderRights = append(folderRights, fr)
+	}
+
 	return &User{
 		Name:         name,
 		PasswordHash: passwordHash,
 		Salt:         salt,
 		Folders:      folders,
 		Rights:       rights,
+		FolderRights: folderRights,
 	}, nil
 }
 
@@ -228,6 +250,20 @@ func UserToCapnp(user *User, seg *capnp_lib.Segment) (*capnp.User, error) {
 		return nil, err
 	}
 
+	specs := []string{}
+	for _, fr := range user.FolderRights {
+		specs = append(specs, fr.String())
+	}
+
+	capFolderRights, err := newTextList(seg, specs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := capUser.SetFolderRights(capFolderRights); err != nil {
+		return nil, err
+	}
+
 	if err := capUser.SetName(user.Name); err != nil {
 		return nil, err
 	}
@@ -245,12 +281,17 @@ func UserToCapnp(user *User, seg *capnp_lib.Segment) (*capnp.User, error) {
 
 // User is one user that is stored in the database.
 // The passwords are stored as scrypt hash with added salt.
+// The global rights apply to all of the folders, unless
+// they are changed by one of the per-folder rights.
 type User struct {
 	Name         string
 	PasswordHash string
 	Salt         string
 	Folders      []string
 	Rights       []string
+	FolderRights []FolderRights
+
+	scope *scope
 }
 
 // CheckPassword checks if `password` matches the stored one.
@@ -288,11 +329,22 @@ func HashPassword(password string) (string, string, error) {
 
 // Add adds a new user to the database.
 // If the user exists already, it is overwritten.
+// `folders` may contain per-folder rights (see ParseFolderRights).
 func (ub *UserDatabase) Add(name, password string, folders []string, rights []string) error {
 	ub.mu.Lock()
 	defer ub.mu.Unlock()
 
-	if len(folders) == 0 {
+	if strings.HasPrefix(name, tokenKeyPrefix) {
+		return fmt.Errorf("user name may not start with »%s«", tokenKeyPrefix)
+	}
+
+	folders, folderRights, err := SplitFolderSpecs(folders)
+	if err != nil {
+		return err
+	}
+
+	// A user with only per-folder rights should not see everything:
+	if len(folders) == 0 && len(folderRights) == 0 {
 		folders = []string{"/"}
 	}
 
@@ -317,6 +369,7 @@ func (ub *UserDatabase) Add(name, password string, folders []string, rights []st
 		Salt:         salt,
 		Folders:      folders,
 		Rights:       rights,
+		FolderRights: folderRights,
 	}
 
 	data, err := marshalUser(user)
@@ -357,7 +410,7 @@ func (ub *UserDatabase) Get(name string) (User, error) {
 	})
 }
 
-// Remove removes an existing user.
+// Remove removes an existing user and all of its tokens.
 func (ub *UserDatabase) Remove(name string) error {
 	ub.mu.Lock()
 	defer ub.mu.Unlock()
@@ -368,6 +421,17 @@ func (ub *UserDatabase) Remove(name string) error {
 			return err
 		}
 
+		tokens, err := listTokens(txn, name)
+		if err != nil {
+			return err
+		}
+
+		for _, token := range tokens {
+			if err := txn.Delete([]byte(tokenKeyPrefix + token.ID)); err != nil {
+				return err
+			}
+		}
+
 		return txn.Delete([]byte(name))
 	})
 }
@@ -383,6 +447,11 @@ func (ub *UserDatabase) List() ([]User, error) {
 		defer iter.Close()
 
 		for iter.Rewind(); iter.Valid(); iter.Next() {
+			// Tokens live in the same database:
+			if strings.HasPrefix(string(iter.Item().Key()), tokenKeyPrefix) {
+				continue
+			}
+
 			data, err := iter.Item().Value()
 			if err != nil {
 				return err
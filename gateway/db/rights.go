@@ -0,0 +1,226 @@
+package db
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// FolderRights gives or takes away rights for a folder and everything below
+// it. When checking a path, the rule of the deepest folder that mentions the
+// right wins. If no rule mentions it, the global rights and folders of the
+// user decide. Denying a right therefore works even if the user has it
+// globally or for a parent folder.
+type FolderRights struct {
+	Folder string
+	Allow  []string
+	Deny   []string
+}
+
+// ParseFolderRights parses a spec like »/incoming:fs.view,fs.edit« or
+// »/archive:fs.view,!fs.edit«. Rights prefixed with »!« are denied.
+// If `spec` has no rights part, ok is false and `spec` is a plain folder.
+func ParseFolderRights(spec string) (FolderRights, bool, error) {
+	idx := strings.LastIndex(spec, ":")
+	if idx < 0 {
+		return FolderRights{}, false, nil
+	}
+
+	fr := FolderRights{Folder: path.Clean("/" + spec[:idx])}
+	for _, right := range strings.Split(spec[idx+1:], ",") {
+		right = strings.TrimSpace(right)
+		if right == "" {
+			continue
+		}
+
+		isDeny := strings.HasPrefix(right, "!")
+		right = strings.TrimPrefix(right, "!")
+		if !AllRights[right] {
+			return FolderRights{}, false, fmt.Errorf("invalid right in »%s«: %s", spec, right)
+		}
+
+		if !strings.HasPrefix(right, "fs.") {
+			return FolderRights{}, false, fmt.Errorf("only fs.* rights can be given per folder: %s", right)
+		}
+
+		if isDeny {
+			fr.Deny = append(fr.Deny, right)
+		} else {
+			fr.Allow = append(fr.Allow, right)
+		}
+	}
+
+	if len(fr.Allow) == 0 && len(fr.Deny) == 0 {
+		return FolderRights{}, false, fmt.Errorf("no rights given in »%s«", spec)
+	}
+
+	return fr, true, nil
+}
+
+// SplitFolderSpecs splits `specs` into plain folders and per-folder rights.
+func SplitFolderSpecs(specs []string) ([]string, []FolderRights, error) {
+	folders := []string{}
+	rules := []FolderRights{}
+
+	for _, spec := range specs {
+		fr, ok, err := ParseFolderRights(spec)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if ok {
+			rules = append(rules, fr)
+		} else {
+			folders = append(folders, spec)
+		}
+	}
+
+	return folders, rules, nil
+}
+
+// String returns the rule in the form understood by ParseFolderRights.
+func (fr FolderRights) String() string {
+	rights := append([]string{}, fr.Allow...)
+	for _, right := range fr.Deny {
+		rights = append(rights, "!"+right)
+	}
+
+	return fr.Folder + ":" + strings.Join(rights, ",")
+}
+
+// FolderSpecs returns the folders and the per-folder rights of the user as
+// one list. It can be passed to UserDatabase.Add to create a similar user.
+func (u User) FolderSpecs() []string {
+	specs := append([]string{}, u.Folders...)
+	for _, fr := range u.FolderRights {
+		specs = append(specs, fr.String())
+	}
+
+	return specs
+}
+
+// scope limits what a user may do, no matter what rights it has otherwise.
+// It is set for users that authenticated with a token.
+type scope struct {
+	folders []string
+	rights  []string
+}
+
+func containsRight(rights []string, right string) bool {
+	for _, r := range rights {
+		if r == right {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasRight checks if the user may use `right` on `nodePath`.
+func (u User) HasRight(nodePath, right string) bool {
+	nodePath = path.Clean("/" + nodePath)
+	if u.scope != nil {
+		if !containsRight(u.scope.rights, right) || !isBelowAny(nodePath, u.scope.folders) {
+			return false
+		}
+	}
+
+	for curr := nodePath; ; curr = path.Dir(curr) {
+		isAllowed, isDenied := false, false
+		for _, fr := range u.FolderRights {
+			if path.Clean("/"+fr.Folder) != curr {
+				continue
+			}
+
+			isAllowed = isAllowed || containsRight(fr.Allow, right)
+			isDenied = isDenied || containsRight(fr.Deny, right)
+		}
+
+		// Deny wins if a folder has conflicting rules:
+		if isDenied {
+			return false
+		}
+
+		if isAllowed {
+			return true
+		}
+
+		if curr == "/" {
+			break
+		}
+	}
+
+	return containsRight(u.Rights, right) && isBelowAny(nodePath, u.Folders)
+}
+
+// HasRightBelow works like HasRight, but also checks that no folder below
+// `nodePath` takes the right away. Use it for operations that affect
+// a whole directory, like moving or removing it.
+func (u User) HasRightBelow(nodePath, right string) bool {
+	if !u.HasRight(nodePath, right) {
+		return false
+	}
+
+	nodePath = path.Clean("/" + nodePath)
+	for _, fr := range u.FolderRights {
+		folder := path.Clean("/" + fr.Folder)
+		if folder == nodePath || !isBelowAny(folder, []string{nodePath}) {
+			continue
+		}
+
+		if !u.HasRight(folder, right) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// HasRightAnywhere checks if the user has `right` for at least some folder.
+func (u User) HasRightAnywhere(right string) bool {
+	if u.scope != nil && !containsRight(u.scope.rights, right) {
+		return false
+	}
+
+	if containsRight(u.Rights, right) {
+		return true
+	}
+
+	for _, fr := range u.FolderRights {
+		if containsRight(fr.Allow, right) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RightsAnywhere returns all rights the user has for at least some folder.
+// This is the set of rights a user interface should offer to the user.
+func (u User) RightsAnywhere() []string {
+	rights := []string{}
+	for _, right := range DefaultRights {
+		if u.HasRightAnywhere(right) {
+			rights = append(rights, right)
+		}
+	}
+
+	return rights
+}
+
+// Roots returns all folders the user might access something in.
+// The folders themselves might not be accessible, but something below.
+func (u User) Roots() []string {
+	if u.scope != nil {
+		return u.scope.folders
+	}
+
+	roots := append([]string{}, u.Folders...)
+	for _, fr := range u.FolderRights {
+		if len(fr.Allow) > 0 {
+			roots = append(roots, fr.Folder)
+		}
+	}
+
+	return roots
+}
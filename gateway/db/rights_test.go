@@ -0,0 +1,94 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFolderRights(t *testing.T) {
+	fr, ok, err := ParseFolderRights("/archive:fs.view, !fs.edit")
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, "/archive", fr.Folder)
+	require.Equal(t, []string{RightFsView}, fr.Allow)
+	require.Equal(t, []string{RightFsEdit}, fr.Deny)
+	require.Equal(t, "/archive:fs.view,!fs.edit", fr.String())
+
+	_, ok, err = ParseFolderRights("/plain")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	_, _, err = ParseFolderRights("/x:fs.fly")
+	require.NotNil(t, err)
+
+	_, _, err = ParseFolderRights("/x:remotes.edit")
+	require.NotNil(t, err)
+
+	_, _, err = ParseFolderRights("/x:")
+	require.NotNil(t, err)
+}
+
+func TestFolderRights(t *testing.T) {
+	withDummyDb(t, func(db *UserDatabase) {
+		require.Nil(t, db.Add("bob", "pass", []string{
+			"/public",
+			"/incoming:fs.view,fs.edit",
+			"/archive:fs.view",
+			"/archive/secret:!fs.view",
+		}, []string{RightFsView}))
+
+		bob, err := db.Get("bob")
+		require.Nil(t, err)
+		require.Equal(t, []string{"/public"}, bob.Folders)
+		require.Len(t, bob.FolderRights, 3)
+
+		// Global rights for global folders:
+		require.True(t, bob.HasRight("/public/x", RightFsView))
+		require.False(t, bob.HasRight("/public/x", RightFsEdit))
+		require.False(t, bob.HasRight("/other", RightFsView))
+
+		// Per-folder rights, inherited by sub folders:
+		require.True(t, bob.HasRight("/incoming/a/b", RightFsEdit))
+		require.True(t, bob.HasRight("/archive/2019", RightFsView))
+		require.False(t, bob.HasRight("/archive/2019", RightFsEdit))
+
+		// Explicit deny:
+		require.False(t, bob.HasRight("/archive/secret/x", RightFsView))
+		require.False(t, bob.HasRightBelow("/archive", RightFsView))
+		require.True(t, bob.HasRightBelow("/incoming", RightFsEdit))
+
+		require.True(t, bob.HasRightAnywhere(RightFsEdit))
+		require.False(t, bob.HasRightAnywhere(RightDownload))
+		require.Equal(t, []string{RightFsView, RightFsEdit}, bob.RightsAnywhere())
+	})
+}
+
+func TestFolderRightsDenyGlobal(t *testing.T) {
+	withDummyDb(t, func(db *UserDatabase) {
+		require.Nil(t, db.Add("ali", "pass", []string{"/", "/archive:!fs.edit"}, nil))
+
+		ali, err := db.Get("ali")
+		require.Nil(t, err)
+		require.True(t, ali.HasRight("/docs", RightFsEdit))
+		require.False(t, ali.HasRight("/archive/x", RightFsEdit))
+		require.True(t, ali.HasRight("/archive/x", RightFsView))
+		require.False(t, ali.HasRightBelow("/", RightFsEdit))
+	})
+}
+
+func TestFolderRightsWithToken(t *testing.T) {
+	withDummyDb(t, func(db *UserDatabase) {
+		require.Nil(t, db.Add("bob", "pass", []string{"/public", "/incoming:fs.view,fs.edit"}, nil))
+
+		secret, _, err := db.AddToken("bob", "script", []string{"/public"}, []string{RightFsView, RightFsEdit})
+		require.Nil(t, err)
+
+		bob, err := db.CheckToken(secret)
+		require.Nil(t, err)
+
+		// The token may not leave its folders, even if bob may:
+		require.True(t, bob.HasRight("/public/x", RightFsEdit))
+		require.False(t, bob.HasRight("/incoming/x", RightFsEdit))
+	})
+}
@@ -0,0 +1,509 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	capnp "github.com/sahib/brig/gateway/db/capnp"
+	capnp_lib "zombiezen.com/go/capnproto2"
+)
+
+const (
+	// tokenKeyPrefix is prepended to the id of a token to get its key.
+	// User names may not start with it.
+	tokenKeyPrefix = "token:"
+
+	// lastUsedPrecision is how often the last used timestamp
+	// of a token is updated at most, to avoid a write on every request.
+	lastUsedPrecision = time.Minute
+)
+
+var (
+	// ErrBadToken is returned by CheckToken for unknown or malformed tokens.
+	ErrBadToken = fmt.Errorf("invalid token")
+)
+
+// Token is a long-lived API token of a user. It can be used instead of
+// a login and may carry less rights and folders than the user itself.
+// Only a hash of the secret part is stored.
+type Token struct {
+	ID         string
+	User       string
+	Name       string
+	SecretHash string
+	Folders    []string
+	Rights     []string
+	Created    time.Time
+	LastUsed   time.Time
+}
+
+// Restrict returns a copy of `user` that has only the rights and folders
+// that both the user and the token have. This way a token never gives more
+// access than its user has, even if the user was changed after creating it.
+func (t Token) Restrict(user User) User {
+	userRights := make(map[string]bool)
+	for _, right := range user.Rights {
+		userRights[right] = true
+	}
+
+	rights := []string{}
+	for _, right := range t.Rights {
+		if userRights[right] {
+			rights = append(rights, right)
+		}
+	}
+
+	folders := []string{}
+	for _, folder := range t.Folders {
+		if isBelowAny(folder, user.Folders) {
+			folders = append(folders, folder)
+		}
+	}
+
+	user.Rights = rights
+	user.Folders = folders
+
+	// The per-folder rights of the user might give access outside
+	// of the token's folders; the scope prevents that.
+	user.scope = &scope{folders: t.Folders, rights: t.Rights}
+	return user
+}
+
+// isBelowAny checks if `folder` is equal to or below one of `folders`.
+func isBelowAny(folder string, folders []string) bool {
+	folder = path.Clean("/" + folder)
+	for _, parent := range folders {
+		parent = path.Clean("/" + parent)
+		if parent == "/" || folder == parent || strings.HasPrefix(folder, parent+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hashTokenSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func randomString(n int, encode func([]byte) string) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return encode(buf), nil
+}
+
+func unmarshalToken(data []byte) (*Token, error) {
+	msg, err := capnp_lib.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	capToken, err := capnp.ReadRootToken(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return TokenFromCapnp(capToken)
+}
+
+func readTextList(list capnp_lib.TextList) ([]string, error) {
+	result := []string{}
+	for idx := 0; idx < list.Len(); idx++ {
+		elem, err := list.At(idx)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, elem)
+	}
+
+	return result, nil
+}
+
+func newTextList(seg *capnp_lib.Segment, elems []string) (capnp_lib.TextList, error) {
+	list, err := capnp_lib.NewTextList(seg, int32(len(elems)))
+	if err != nil {
+		return list, err
+	}
+
+	for idx, elem := range elems {
+		if err := list.Set(idx, elem); err != nil {
+			return list, err
+		}
+	}
+
+	return list, nil
+}
+
+// TokenFromCapnp takes a capnp.Token and returns a regular Token from it.
+func TokenFromCapnp(capToken capnp.Token) (*Token, error) {
+	id, err := capToken.Id()
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := capToken.User()
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := capToken.Name()
+	if err != nil {
+		return nil, err
+	}
+
+	secretHash, err := capToken.SecretHash()
+	if err != nil {
+		return nil, err
+	}
+
+	capFolders, err := capToken.Folders()
+	if err != nil {
+		return nil, err
+	}
+
+	folders, err := readTextList(capFolders)
+	if err != nil {
+		return nil, err
+	}
+
+	capRights, err := capToken.Rights()
+	if err != nil {
+		return nil, err
+	}
+
+	rights, err := readTextList(capRights)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &Token{
+		ID:         id,
+		User:       user,
+		Name:       name,
+		SecretHash: secretHash,
+		Folders:    folders,
+		Rights:     rights,
+	}
+
+	created, err := capToken.Created()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := token.Created.UnmarshalText([]byte(created)); err != nil {
+		return nil, err
+	}
+
+	lastUsed, err := capToken.LastUsed()
+	if err != nil {
+		return nil, err
+	}
+
+	// A token that was never used has no timestamp:
+	if lastUsed != "" {
+		if err := token.LastUsed.UnmarshalText([]byte(lastUsed)); err != nil {
+			return nil, err
+		}
+	}
+
+	return token, nil
+}
+
+func marshalToken(token *Token) ([]byte, error) {
+	msg, seg, err := capnp_lib.NewMessage(capnp_lib.SingleSegment(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := TokenToCapnp(token, seg); err != nil {
+		return nil, err
+	}
+
+	return msg.Marshal()
+}
+
+// TokenToCapnp converts a Token to a capnp.Token.
+func TokenToCapnp(token *Token, seg *capnp_lib.Segment) (*capnp.Token, error) {
+	capToken, err := capnp.NewRootToken(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := capToken.SetId(token.ID); err != nil {
+		return nil, err
+	}
+
+	if err := capToken.SetUser(token.User); err != nil {
+		return nil, err
+	}
+
+	if err := capToken.SetName(token.Name); err != nil {
+		return nil, err
+	}
+
+	if err := capToken.SetSecretHash(token.SecretHash); err != nil {
+		return nil, err
+	}
+
+	capFolders, err := newTextList(seg, token.Folders)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := capToken.SetFolders(capFolders); err != nil {
+		return nil, err
+	}
+
+	capRights, err := newTextList(seg, token.Rights)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := capToken.SetRights(capRights); err != nil {
+		return nil, err
+	}
+
+	created, err := token.Created.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := capToken.SetCreated(string(created)); err != nil {
+		return nil, err
+	}
+
+	lastUsed := ""
+	if !token.LastUsed.IsZero() {
+		data, err := token.LastUsed.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+
+		lastUsed = string(data)
+	}
+
+	if err := capToken.SetLastUsed(lastUsed); err != nil {
+		return nil, err
+	}
+
+	return &capToken, nil
+}
+
+func getUser(txn *badger.Txn, name string) (*User, error) {
+	item, err := txn.Get([]byte(name))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := item.Value()
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalUser(data)
+}
+
+func getToken(txn *badger.Txn, id string) (*Token, error) {
+	item, err := txn.Get([]byte(tokenKeyPrefix + id))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := item.Value()
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalToken(data)
+}
+
+func setToken(txn *badger.Txn, token *Token) error {
+	data, err := marshalToken(token)
+	if err != nil {
+		return err
+	}
+
+	return txn.Set([]byte(tokenKeyPrefix+token.ID), data)
+}
+
+// AddToken creates a new token for the existing user `userName`.
+// `folders` and `rights` must be a subset of what the user has; if they
+// are empty, the ones of the user are taken. The returned string is the
+// secret token that has to be passed as »Authorization: Bearer <token>«.
+// It is not stored and can not be retrieved later.
+func (ub *UserDatabase) AddToken(userName, name string, folders, rights []string) (string, *Token, error) {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	id, err := randomString(8, hex.EncodeToString)
+	if err != nil {
+		return "", nil, err
+	}
+
+	secret, err := randomString(24, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token := &Token{
+		ID:         id,
+		User:       userName,
+		Name:       name,
+		SecretHash: hashTokenSecret(secret),
+		Created:    time.Now(),
+	}
+
+	err = ub.db.Update(func(txn *badger.Txn) error {
+		user, err := getUser(txn, userName)
+		if err != nil {
+			return fmt.Errorf("no such user: %s", userName)
+		}
+
+		if len(folders) == 0 {
+			folders = user.Folders
+		}
+
+		if len(rights) == 0 {
+			rights = user.Rights
+		}
+
+		token.Folders = folders
+		token.Rights = rights
+
+		restricted := token.Restrict(*user)
+		for _, right := range rights {
+			if !AllRights[right] {
+				return fmt.Errorf("invalid right: %s", right)
+			}
+		}
+
+		if len(restricted.Rights) != len(rights) {
+			return fmt.Errorf("token may not have more rights than %s", userName)
+		}
+
+		if len(restricted.Folders) != len(folders) {
+			return fmt.Errorf("token may not access more folders than %s", userName)
+		}
+
+		return setToken(txn, token)
+	})
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	return id + "." + secret, token, nil
+}
+
+// CheckToken checks if `secretToken` is a valid token and returns the user
+// that it belongs to, restricted to the rights and folders of the token.
+func (ub *UserDatabase) CheckToken(secretToken string) (User, error) {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	split := strings.SplitN(secretToken, ".", 2)
+	if len(split) != 2 {
+		return User{}, ErrBadToken
+	}
+
+	id, secret := split[0], split[1]
+
+	var user User
+	err := ub.db.Update(func(txn *badger.Txn) error {
+		token, err := getToken(txn, id)
+		if err != nil {
+			return ErrBadToken
+		}
+
+		givenHash := []byte(hashTokenSecret(secret))
+		if subtle.ConstantTimeCompare(givenHash, []byte(token.SecretHash)) != 1 {
+			return ErrBadToken
+		}
+
+		dbUser, err := getUser(txn, token.User)
+		if err != nil {
+			return ErrBadToken
+		}
+
+		user = token.Restrict(*dbUser)
+
+		now := time.Now()
+		if now.Sub(token.LastUsed) < lastUsedPrecision {
+			return nil
+		}
+
+		token.LastUsed = now
+		return setToken(txn, token)
+	})
+
+	return user, err
+}
+
+// RemoveToken revokes the token with the id `id`.
+func (ub *UserDatabase) RemoveToken(id string) error {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	return ub.db.Update(func(txn *badger.Txn) error {
+		key := []byte(tokenKeyPrefix + id)
+		if _, err := txn.Get(key); err != nil {
+			return err
+		}
+
+		return txn.Delete(key)
+	})
+}
+
+// ListTokens returns all tokens of `userName`,
+// or the tokens of all users if it is empty.
+func (ub *UserDatabase) ListTokens(userName string) ([]Token, error) {
+	ub.mu.Lock()
+	defer ub.mu.Unlock()
+
+	var tokens []Token
+	err := ub.db.View(func(txn *badger.Txn) error {
+		var err error
+		tokens, err = listTokens(txn, userName)
+		return err
+	})
+
+	return tokens, err
+}
+
+func listTokens(txn *badger.Txn, userName string) ([]Token, error) {
+	iter := txn.NewIterator(badger.IteratorOptions{})
+	defer iter.Close()
+
+	tokens := []Token{}
+	prefix := []byte(tokenKeyPrefix)
+	for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+		data, err := iter.Item().Value()
+		if err != nil {
+			return nil, err
+		}
+
+		token, err := unmarshalToken(data)
+		if err != nil {
+			return nil, err
+		}
+
+		if userName == "" || token.User == userName {
+			tokens = append(tokens, *token)
+		}
+	}
+
+	return tokens, nil
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenAddCheck(t *testing.T) {
+	withDummyDb(t, func(db *UserDatabase) {
+		require.Nil(t, db.Add("ali", "pass", []string{"/public", "/shared"}, DefaultRights))
+
+		secret, token, err := db.AddToken("ali", "backup-script", []string{"/public/backups"}, []string{RightDownload})
+		require.Nil(t, err)
+		require.Equal(t, "ali", token.User)
+		require.True(t, token.LastUsed.IsZero())
+
+		user, err := db.CheckToken(secret)
+		require.Nil(t, err)
+		require.Equal(t, "ali", user.Name)
+		require.Equal(t, []string{"/public/backups"}, user.Folders)
+		require.Equal(t, []string{RightDownload}, user.Rights)
+
+		tokens, err := db.ListTokens("ali")
+		require.Nil(t, err)
+		require.Len(t, tokens, 1)
+		require.Equal(t, "backup-script", tokens[0].Name)
+		require.False(t, tokens[0].LastUsed.IsZero())
+
+		// Tokens should not show up as users:
+		users, err := db.List()
+		require.Nil(t, err)
+		require.Len(t, users, 1)
+
+		_, err = db.CheckToken(token.ID + ".wrong")
+		require.Equal(t, ErrBadToken, err)
+
+		_, err = db.CheckToken("garbage")
+		require.Equal(t, ErrBadToken, err)
+
+		require.Nil(t, db.RemoveToken(token.ID))
+		_, err = db.CheckToken(secret)
+		require.Equal(t, ErrBadToken, err)
+	})
+}
+
+func TestTokenNoEscalation(t *testing.T) {
+	withDummyDb(t, func(db *UserDatabase) {
+		require.Nil(t, db.Add("ali", "pass", []string{"/public"}, []string{RightFsView}))
+
+		_, _, err := db.AddToken("ali", "x", nil, []string{RightFsEdit})
+		require.NotNil(t, err)
+
+		_, _, err = db.AddToken("ali", "x", []string{"/"}, nil)
+		require.NotNil(t, err)
+
+		_, _, err = db.AddToken("bob", "x", nil, nil)
+		require.NotNil(t, err)
+
+		// Empty folders and rights mean "same as the user":
+		secret, _, err := db.AddToken("ali", "x", nil, nil)
+		require.Nil(t, err)
+
+		// If the user loses rights, so does the token:
+		require.Nil(t, db.Add("ali", "pass", []string{"/public/sub"}, []string{RightDownload}))
+		user, err := db.CheckToken(secret)
+		require.Nil(t, err)
+		require.Empty(t, user.Rights)
+		require.Empty(t, user.Folders)
+
+		// Removing the user removes its tokens:
+		require.Nil(t, db.Remove("ali"))
+		tokens, err := db.ListTokens("")
+		require.Nil(t, err)
+		require.Empty(t, tokens)
+	})
+}
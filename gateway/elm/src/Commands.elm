@@ -467,17 +467,19 @@ type alias WhoamiResponse =
     , isAnon : Bool
     , anonIsAllowed : Bool
     , rights : List String
+    , oidcEnabled : Bool
     }
 
 
 decodeWhoami : D.Decoder WhoamiResponse
 decodeWhoami =
-    D.map5 WhoamiResponse
+    D.map6 WhoamiResponse
         (D.field "user" D.string)
         (D.field "is_logged_in" D.bool)
         (D.field "is_anon" D.bool)
         (D.field "anon_is_allowed" D.bool)
         (D.field "rights" (D.list D.string))
+        (D.field "oidc_enabled" D.bool)
 
 
 doWhoami : (Result Http.Error WhoamiResponse -> msg) -> Cmd msg
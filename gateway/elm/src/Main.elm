@@ -113,6 +113,7 @@ type alias Model =
     , url : Url.Url
     , loginState : LoginState
     , serverIsOnline : Bool
+    , oidcEnabled : Bool
     }
 
 
@@ -123,6 +124,7 @@ init _ url key =
       , url = url
       , loginState = LoginLimbo
       , serverIsOnline = True
+      , oidcEnabled = False
       }
     , Cmd.batch
         [ Task.perform AdjustTimeZone Time.here
@@ -287,7 +289,7 @@ update msg model =
                             doInitAfterLogin model whoami.username whoami.rights whoami.isAnon whoami.anonIsAllowed
 
                         False ->
-                            ( { model | loginState = LoginReady "" "" }, Cmd.none )
+                            ( { model | loginState = LoginReady "" "", oidcEnabled = whoami.oidcEnabled }, Cmd.none )
 
                 Err _ ->
                     ( { model | loginState = LoginReady "" "" }, Cmd.none )
@@ -714,6 +716,20 @@ viewLoginButton username password isLoading =
         [ span [ class loadingClass ] [], text " Log in" ]
 
 
+viewOidcButton : Model -> List (Html Msg)
+viewOidcButton model =
+    if model.oidcEnabled then
+        [ Button.linkButton
+            [ Button.outlinePrimary
+            , Button.attrs [ href "/api/v0/oidc/login", class "login-btn" ]
+            ]
+            [ span [ class "fas fa-sign-in-alt" ] [], text " Log in with SSO" ]
+        ]
+
+    else
+        []
+
+
 viewLoginForm : Model -> Html Msg
 viewLoginForm model =
     Grid.containerFluid []
@@ -729,6 +745,7 @@ viewLoginForm model =
                             LoginReady username password ->
                                 viewLoginInputs username password
                                     ++ [ viewLoginButton username password False ]
+                                    ++ viewOidcButton model
 
                             LoginLoading username password ->
                                 viewLoginInputs username password
@@ -739,6 +756,7 @@ viewLoginForm model =
                                     ++ [ Alert.simpleDanger [] [ text "Login failed, please try again." ]
                                        , viewLoginButton username password False
                                        ]
+                                    ++ viewOidcButton model
 
                             _ ->
                                 -- This should not happen.
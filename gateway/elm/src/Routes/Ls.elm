@@ -743,7 +743,27 @@ viewEntryIcon entry =
             span [ class "fas fa-lg fa-folder text-xs-right file-list-icon" ] []
 
         False ->
-            span [ class "far fa-lg fa-file text-xs-right file-list-icon" ] []
+            if hasThumbnail entry.path then
+                img
+                    [ src ("/thumb" ++ Util.urlEncodePath entry.path ++ "?size=64")
+                    , class "file-list-thumb"
+                    , alt ""
+                    ]
+                    []
+
+            else
+                span [ class "far fa-lg fa-file text-xs-right file-list-icon" ] []
+
+
+hasThumbnail : String -> Bool
+hasThumbnail path =
+    let
+        lowerPath =
+            String.toLower path
+    in
+    List.any
+        (\ext -> String.endsWith ext lowerPath)
+        [ ".jpg", ".jpeg", ".png", ".gif", ".webp", ".pdf" ]
 
 
 makeCheckbox : Bool -> (Bool -> Msg) -> Html Msg
@@ -811,6 +831,19 @@ buildActionDropdown model actModel entry =
                 [ span [ class "fa fa-md fa-file-download" ] []
                 , text " Download"
                 ]
+            , Dropdown.anchorItem
+                [ href
+                    ("/get"
+                        ++ Util.urlEncodePath
+                            (Util.joinPath [ actModel.self.path, Util.basename entry.path ])
+                        ++ "?direct=yes&format=zip"
+                    )
+                , onClick (ActionDropdownMsg entry Dropdown.initialState)
+                , disabled (not (mayDownload model) || not entry.isDir)
+                ]
+                [ span [ class "fa fa-md fa-file-archive" ] []
+                , text " Download as zip"
+                ]
             , Dropdown.anchorItem
                 [ href
                     ("/get"
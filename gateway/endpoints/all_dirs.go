@@ -28,7 +28,7 @@ type AllDirsResponse struct {
 }
 
 func (ah *AllDirsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if user := getUserName(ah.store, w, r); user == "" {
+	if _, ok := ah.requestUser(w, r); !ok {
 		jsonifyErrf(w, http.StatusForbidden, "bad user")
 		return
 	}
@@ -41,12 +41,12 @@ func (ch *CopyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	src := prefixRoot(copyReq.Source)
 	dst := prefixRoot(copyReq.Destination)
 
-	if !ch.validatePath(src, w, r) {
+	if !ch.checkPathRightBelow(src, db.RightFsView, w, r) {
 		jsonifyErrf(w, http.StatusUnauthorized, "source path forbidden")
 		return
 	}
 
-	if !ch.validatePath(dst, w, r) {
+	if !ch.checkPathRight(dst, db.RightFsEdit, w, r) {
 		jsonifyErrf(w, http.StatusUnauthorized, "destination path forbidden")
 		return
 	}
@@ -62,5 +62,7 @@ func (ch *CopyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ch.recordAudit(w, r, "fs.copy", src, "to "+dst)
+
 	jsonifySuccess(w)
 }
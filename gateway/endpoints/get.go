@@ -39,14 +39,14 @@ func mimeTypeFromStream(stream mio.Stream) (io.ReadSeeker, string) {
 // setContentDisposition sets the Content-Disposition header, based on
 // the content we are serving. It tells a browser if it should open
 // a save dialog or display it inline (and how)
-func setContentDisposition(info *catfs.StatInfo, hdr http.Header, dispoType string) {
+func setContentDisposition(info *catfs.StatInfo, hdr http.Header, dispoType, archiveExt string) {
 	basename := path.Base(info.Path)
 	if info.IsDir {
 		if basename == "/" {
 			basename = "root"
 		}
 
-		basename += ".tar"
+		basename += archiveExt
 	}
 
 	hdr.Set(
@@ -59,32 +59,25 @@ func setContentDisposition(info *catfs.StatInfo, hdr http.Header, dispoType stri
 	)
 }
 
-func (gh *GetHandler) checkBasicAuth(nodePath string, w http.ResponseWriter, r *http.Request) bool {
+func (gh *GetHandler) checkBasicAuth(nodePath string, w http.ResponseWriter, r *http.Request) (db.User, bool) {
 	name, pass, ok := r.BasicAuth()
 
 	// No basic auth sent. If a browser send the request: ask him to
 	// show a user/password form that gives a chance to change that.
 	if !ok {
 		w.Header().Set("WWW-Authenticate", "Basic realm=\"brig gateway\"")
-		return false
+		return db.User{}, false
 	}
 
 	// Check is the basic auth credentials are valid.
 	user, err := gh.userDb.Get(name)
 	if err != nil {
-		return false
+		return db.User{}, false
 	}
 
-	hasRight := false
-	for _, right := range user.Rights {
-		if right == db.RightDownload {
-			hasRight = true
-			break
-		}
-	}
-
-	if !hasRight {
-		return false
+	// Check if this user may download the path at all:
+	if !user.HasRight(nodePath, db.RightDownload) {
+		return db.User{}, false
 	}
 
 	isValid, err := user.CheckPassword(pass)
@@ -93,35 +86,45 @@ func (gh *GetHandler) checkBasicAuth(nodePath string, w http.ResponseWriter, r *
 			log.Warningf("get: failed to check password: %v", err)
 		}
 
-		return false
+		return db.User{}, false
 	}
 
-	// Check again if this user has access to the path:
-	if !gh.validatePathForUser(nodePath, user, w, r) {
-		return false
-	}
-
-	return true
+	return user, true
 }
 
-func (gh *GetHandler) checkDownloadRight(w http.ResponseWriter, r *http.Request) bool {
-	name := getUserName(gh.store, w, r)
-	if name == "" {
-		return false
-	}
+// authorize checks if the request may download `nodePath` and returns
+// the user doing the request. If not, an error is written to `w`.
+func (gh *GetHandler) authorize(nodePath string, w http.ResponseWriter, r *http.Request) (db.User, bool) {
+	nodePath = prefixRoot(nodePath)
 
-	user, err := gh.userDb.Get(name)
-	if err != nil {
-		return false
-	}
+	// Check if the user is actually logged in. The login could come
+	// from a previous login to the UI (the /get endpoint could be used separately)
+	user, ok := gh.requestUser(w, r)
+	if !gh.cfg.Bool("auth.anon_allowed") {
+		if !ok {
+			// If the user was not previously logged into the UI,
+			// we also accept basic auth for this endpoint.
+			// This way hyperlinks can be shared without having to login.
+			// Using HTTPS here is strongly recommended.
+			user, ok = gh.checkBasicAuth(nodePath, w, r)
+			if !ok {
+				http.Error(w, "not authorized", http.StatusUnauthorized)
+				return db.User{}, false
+			}
+		} else if !user.HasRight(nodePath, db.RightDownload) {
+			http.Error(w, "insufficient rights", http.StatusUnauthorized)
+			return db.User{}, false
+		}
 
-	for _, right := range user.Rights {
-		if right == db.RightDownload {
-			return true
+		// All good. Proceed with the content.
+	} else {
+		if !ok || !user.HasRight(nodePath, db.RightDownload) {
+			http.Error(w, "insufficient rights for anon", http.StatusUnauthorized)
+			return db.User{}, false
 		}
 	}
 
-	return false
+	return user, true
 }
 
 func (gh *GetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -138,32 +141,9 @@ func (gh *GetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !gh.cfg.Bool("auth.anon_allowed") {
-		// validatePath will check if the user is actually logged in
-		// and may access the path in question. The login could come
-		// from a previous login to the UI (the /get endpoint could be used separately)
-		if !gh.validatePath(nodePath, w, r) {
-			// If the user was not previously logged into the UI,
-			// we also accept basic auth for this endpoint.
-			// This way hyperlinks can be shared without having to login.
-			// Using HTTPS here is strongly recommended.
-			if !gh.checkBasicAuth(nodePath, w, r) {
-				http.Error(w, "not authorized", http.StatusUnauthorized)
-				return
-			}
-		} else {
-			if !gh.checkDownloadRight(w, r) {
-				http.Error(w, "insufficient rights", http.StatusUnauthorized)
-				return
-			}
-		}
-
-		// All good. Proceed with the content.
-	} else {
-		if !gh.checkDownloadRight(w, r) {
-			http.Error(w, "insufficient rights for anon", http.StatusUnauthorized)
-			return
-		}
+	user, ok := gh.authorize(nodePath, w, r)
+	if !ok {
+		return
 	}
 
 	info, err := gh.fs.Stat(nodePath)
@@ -188,6 +168,11 @@ func (gh *GetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		includes := params["include"]
 
 		filter := func(info *catfs.StatInfo) bool {
+			// Folders below might not be downloadable:
+			if !user.HasRight(info.Path, db.RightDownload) {
+				return false
+			}
+
 			if len(includes) == 0 {
 				return true
 			}
@@ -201,8 +186,16 @@ func (gh *GetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return false
 		}
 
-		setContentDisposition(info, hdr, "attachment")
-		if err := gh.fs.Tar(nodePath, w, filter); err != nil {
+		// Zip is better supported on Windows:
+		archive, archiveExt := gh.fs.Tar, ".tar"
+		if params.Get("format") == "zip" {
+			archive, archiveExt = gh.fs.Zip, ".zip"
+			hdr.Set("Content-Type", "application/zip")
+		}
+
+		gh.recordAudit(w, r, "fs.read", nodePath, "archive"+archiveExt)
+		setContentDisposition(info, hdr, "attachment", archiveExt)
+		if err := archive(nodePath, w, filter); err != nil {
 			log.Errorf("gateway: failed to stream %s: %v", nodePath, err)
 			http.Error(w, "failed to stream", http.StatusInternalServerError)
 			return
@@ -215,6 +208,7 @@ func (gh *GetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		gh.recordAudit(w, r, "fs.read", nodePath, "")
 		prefixStream, mimeType := mimeTypeFromStream(stream)
 		hdr.Set("Content-Type", mimeType)
 		hdr.Set("Content-Length", strconv.FormatUint(info.Size, 10))
@@ -223,9 +217,9 @@ func (gh *GetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 		// Set the content disposition to inline if it looks like something viewable.
 		if mimeType == "application/octet-stream" || isDirectDownload {
-			setContentDisposition(info, hdr, "attachment")
+			setContentDisposition(info, hdr, "attachment", "")
 		} else {
-			setContentDisposition(info, hdr, "inline")
+			setContentDisposition(info, hdr, "inline", "")
 		}
 
 		http.ServeContent(w, r, path.Base(info.Path), info.ModTime, prefixStream)
@@ -1,6 +1,7 @@
 package endpoints
 
 import (
+	"archive/zip"
 	"bytes"
 	"io/ioutil"
 	"net/http"
@@ -45,3 +46,28 @@ func TestGetEndpointDisallowed(t *testing.T) {
 		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
 	})
 }
+
+func TestGetEndpointZip(t *testing.T) {
+	withState(t, func(s *testState) {
+		fileData := []byte("HelloWorld")
+		require.Nil(t, s.fs.Stage("/dir/file", bytes.NewReader(fileData)))
+
+		resp := s.mustRun(
+			t,
+			NewGetHandler(s.State),
+			"GET",
+			"http://localhost:5000/get/dir?format=zip",
+			nil,
+		)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Contains(t, resp.Header.Get("Content-Disposition"), "dir.zip")
+
+		data, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		require.Nil(t, err)
+		require.Len(t, zr.File, 1)
+		require.Equal(t, "file", zr.File[0].Name)
+	})
+}
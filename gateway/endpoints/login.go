@@ -33,20 +33,23 @@ func getUserName(store *sessions.CookieStore, w http.ResponseWriter, r *http.Req
 	return userName
 }
 
-func setSession(store *sessions.CookieStore, userName string, w http.ResponseWriter, r *http.Request) {
-	// Ignore the error here, since it will usually trigger when there was a previously
-	// outdated session that fails to decode. Since we overwrite the session anyways, it
-	// doesn't really matter in this case.
-	sess, _ := store.Get(r, "sess")
-
+func sessionOptions(r *http.Request) *sessions.Options {
 	isHTTPS := r.TLS != nil
-	sess.Options = &sessions.Options{
+	return &sessions.Options{
 		Path:     "/",
 		MaxAge:   31 * 24 * 60 * 60,
 		HttpOnly: true,
 		Secure:   isHTTPS,
 	}
+}
+
+func setSession(store *sessions.CookieStore, userName string, w http.ResponseWriter, r *http.Request) {
+	// Ignore the error here, since it will usually trigger when there was a previously
+	// outdated session that fails to decode. Since we overwrite the session anyways, it
+	// doesn't really matter in this case.
+	sess, _ := store.Get(r, "sess")
 
+	sess.Options = sessionOptions(r)
 	sess.Values["name"] = userName
 	if err := sess.Save(r, w); err != nil {
 		log.Warningf("set: failed to save session: %v", err)
@@ -113,6 +116,7 @@ func (lih *LoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	dbUser, err := lih.userDb.Get(loginReq.Username)
 	if err != nil {
 		// No such user.
+		lih.recordAuditAs(r, loginReq.Username, "gateway.login_failed", "", "no such user")
 		jsonifyErrf(w, http.StatusForbidden, "bad credentials")
 		return
 	}
@@ -129,6 +133,7 @@ func (lih *LoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			log.Warningf("check password failed: %v", err)
 		}
 
+		lih.recordAuditAs(r, dbUser.Name, "gateway.login_failed", "", "bad password")
 		jsonifyErrf(w, http.StatusForbidden, "bad credentials")
 		return
 	}
@@ -136,11 +141,12 @@ func (lih *LoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	anonIsAllowed := lih.cfg.Bool("auth.anon_allowed")
 	anonUserName := lih.cfg.String("auth.anon_user")
 
+	lih.recordAuditAs(r, dbUser.Name, "gateway.login", "", "password")
 	setSession(lih.store, dbUser.Name, w, r)
 	jsonify(w, http.StatusOK, &LoginResponse{
 		Success:       true,
 		Username:      loginReq.Username,
-		Rights:        dbUser.Rights,
+		Rights:        dbUser.RightsAnywhere(),
 		IsAnon:        anonUserName == loginReq.Username,
 		AnonIsAllowed: anonIsAllowed,
 	})
@@ -165,6 +171,7 @@ func (loh *LogoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	loh.recordAuditAs(r, user, "gateway.logout", "", "")
 	clearSession(loh.store, w, r)
 	jsonifySuccess(w)
 }
@@ -189,6 +196,7 @@ type WhoamiResponse struct {
 	AnonIsAllowed bool     `json:"anon_is_allowed"`
 	User          string   `json:"user"`
 	Rights        []string `json:"rights"`
+	OIDCEnabled   bool     `json:"oidc_enabled"`
 }
 
 func (wh *WhoamiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -208,7 +216,7 @@ func (wh *WhoamiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			log.Warningf("could not get user »%s« : %v", name, err)
 		} else {
-			rights = possiblyAnonUser.Rights
+			rights = possiblyAnonUser.RightsAnywhere()
 			setSession(wh.store, name, w, r)
 		}
 	}
@@ -219,6 +227,7 @@ func (wh *WhoamiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		AnonIsAllowed: anonIsAllowed,
 		User:          name,
 		Rights:        rights,
+		OIDCEnabled:   wh.cfg.Bool("auth.oidc.enabled"),
 	})
 }
 
@@ -232,6 +241,12 @@ type authMiddleware struct {
 type dbUserKey string
 
 func (am *authMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The user might have been authenticated by a token already:
+	if _, ok := r.Context().Value(dbUserKey("brig.db_user")).(db.User); ok {
+		am.SubHandler.ServeHTTP(w, r)
+		return
+	}
+
 	anonIsAllowed := am.cfg.Bool("auth.anon_allowed")
 	name := getUserName(am.store, w, r)
 
@@ -270,13 +285,10 @@ func checkRights(w http.ResponseWriter, r *http.Request, rights ...string) bool
 		return false
 	}
 
-	rmap := make(map[string]bool)
-	for _, right := range user.Rights {
-		rmap[right] = true
-	}
-
+	// Rights that are only given for some folders are checked
+	// in more detail by the handlers themselves:
 	for _, right := range rights {
-		if !rmap[right] {
+		if !user.HasRightAnywhere(right) {
 			jsonifyErrf(w, http.StatusUnauthorized, "insufficient rights")
 			return false
 		}
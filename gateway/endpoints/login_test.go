@@ -1,9 +1,14 @@
 package endpoints
 
 import (
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/sahib/brig/audit"
+	"github.com/sahib/brig/gateway/db"
 	"github.com/stretchr/testify/require"
 )
 
@@ -34,3 +39,45 @@ func TestLoginEndpointSuccess(t *testing.T) {
 		require.Equal(t, "sess", cookies[0].Name)
 	})
 }
+
+func TestLoginAudit(t *testing.T) {
+	withState(t, func(s *testState) {
+		tmpDir, err := ioutil.TempDir("", "brig-endpoints-test-audit")
+		require.Nil(t, err)
+		defer os.RemoveAll(tmpDir)
+
+		auditLog, err := audit.Open(filepath.Join(tmpDir, "audit.log"))
+		require.Nil(t, err)
+		defer auditLog.Close()
+		s.SetAuditLog(auditLog)
+
+		url := "http://localhost:5000/api/v0/login"
+		resp := s.mustRun(t, NewLoginHandler(s.State), "POST", url, &LoginRequest{
+			Username: "ali",
+			Password: "wrong",
+		})
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		resp = s.mustRun(t, NewLoginHandler(s.State), "POST", url, &LoginRequest{
+			Username: "ali",
+			Password: "ila",
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		require.Nil(t, s.userDb.Remove("ali"))
+		require.Nil(t, s.userDb.Add("ali", "ila", []string{"/"}, []string{db.RightFsView, db.RightFsEdit}))
+		resp = s.mustRun(t, NewMkdirHandler(s.State), "POST", "http://localhost:5000/api/v0/mkdir", &MkdirRequest{
+			Path: "/dir",
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		actions := []string{}
+		require.Nil(t, auditLog.Query(audit.Query{User: "ali"}, func(en audit.Entry) error {
+			require.Equal(t, audit.SourceGateway, en.Source)
+			actions = append(actions, en.Action)
+			return nil
+		}))
+
+		require.Equal(t, []string{"gateway.login_failed", "gateway.login", "fs.mkdir"}, actions)
+	})
+}
@@ -0,0 +1,64 @@
+package endpoints
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sahib/brig/metrics"
+)
+
+// statusRecorder remembers the status code written to a ResponseWriter.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	sr.code = code
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Flush() {
+	if flusher, ok := sr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack is needed for the websocket of the events endpoint.
+func (sr *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := sr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer can not be hijacked")
+	}
+
+	sr.code = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+// MetricsMiddleware counts the requests to each endpoint
+// and measures how long they took.
+func MetricsMiddleware() func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Use the route template, so that /get/<path> is only one endpoint:
+			endpoint := r.URL.Path
+			if route := mux.CurrentRoute(r); route != nil {
+				if tmpl, err := route.GetPathTemplate(); err == nil {
+					endpoint = tmpl
+				}
+			}
+
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+			h.ServeHTTP(rec, r)
+
+			metrics.GatewayDuration.With(endpoint).ObserveSince(start)
+			metrics.GatewayRequests.With(endpoint, strconv.Itoa(rec.code)).Inc()
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package endpoints
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sahib/brig/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(MetricsMiddleware())
+	router.PathPrefix("/metrics-test").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics-test/some/path")
+	require.Nil(t, err)
+	require.Nil(t, resp.Body.Close())
+	require.Equal(t, http.StatusTeapot, resp.StatusCode)
+
+	buf := &bytes.Buffer{}
+	require.Nil(t, metrics.Default.Write(buf))
+	require.Contains(
+		t,
+		buf.String(),
+		`brig_gateway_requests_total{endpoint="/metrics-test",code="418"} 1`,
+	)
+	require.Contains(
+		t,
+		buf.String(),
+		`brig_gateway_request_duration_seconds_count{endpoint="/metrics-test"} 1`,
+	)
+}
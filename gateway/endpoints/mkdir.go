@@ -37,7 +37,7 @@ func (mh *MkdirHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	path := prefixRoot(mkdirReq.Path)
-	if !mh.validatePath(path, w, r) {
+	if !mh.checkPathRight(path, db.RightFsEdit, w, r) {
 		jsonifyErrf(w, http.StatusUnauthorized, "path forbidden")
 		return
 	}
@@ -52,5 +52,7 @@ func (mh *MkdirHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !mh.commitChange(msg, w, r) {
 		return
 	}
+
+	mh.recordAudit(w, r, "fs.mkdir", path, "")
 	jsonifySuccess(w)
 }
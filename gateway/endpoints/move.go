@@ -41,12 +41,12 @@ func (mh *MoveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	src := prefixRoot(moveReq.Source)
 	dst := prefixRoot(moveReq.Destination)
 
-	if !mh.validatePath(src, w, r) {
+	if !mh.checkPathRightBelow(src, db.RightFsEdit, w, r) {
 		jsonifyErrf(w, http.StatusUnauthorized, "source path forbidden")
 		return
 	}
 
-	if !mh.validatePath(dst, w, r) {
+	if !mh.checkPathRight(dst, db.RightFsEdit, w, r) {
 		jsonifyErrf(w, http.StatusUnauthorized, "destination path forbidden")
 		return
 	}
@@ -63,5 +63,7 @@ func (mh *MoveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	mh.recordAudit(w, r, "fs.move", src, "to "+dst)
+
 	jsonifySuccess(w)
 }
@@ -80,3 +80,40 @@ func TestMoveDisallowedDest(t *testing.T) {
 		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
 	})
 }
+
+func TestMoveFolderRights(t *testing.T) {
+	withState(t, func(s *testState) {
+		s.mustChangeFolders(
+			t,
+			"/incoming:fs.view,fs.edit",
+			"/archive:fs.view",
+			"/incoming/d/locked:fs.view,!fs.edit",
+		)
+
+		require.Nil(t, s.fs.Mkdir("/incoming/a", true))
+		require.Nil(t, s.fs.Mkdir("/incoming/d/locked/b", true))
+		require.Nil(t, s.fs.Mkdir("/archive/c", true))
+
+		move := func(src, dst string) int {
+			resp := s.mustRun(
+				t,
+				NewMoveHandler(s.State),
+				"POST",
+				"http://localhost:5000/api/v0/move",
+				&MoveRequest{Source: src, Destination: dst},
+			)
+
+			return resp.StatusCode
+		}
+
+		// Only viewable, so nothing may be moved in or out:
+		require.Equal(t, http.StatusUnauthorized, move("/incoming/a", "/archive/a"))
+		require.Equal(t, http.StatusUnauthorized, move("/archive/c", "/incoming/c"))
+
+		// The directory contains a folder that may not be edited:
+		require.Equal(t, http.StatusUnauthorized, move("/incoming/d", "/incoming/e"))
+		require.Equal(t, http.StatusUnauthorized, move("/incoming/d/locked/b", "/incoming/b"))
+
+		require.Equal(t, http.StatusOK, move("/incoming/a", "/incoming/x"))
+	})
+}
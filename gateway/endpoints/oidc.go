@@ -0,0 +1,207 @@
+package endpoints
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"reflect"
+
+	"github.com/sahib/brig/gateway/oidc"
+	log "github.com/sirupsen/logrus"
+)
+
+func (s *State) oidcConfig() oidc.Config {
+	return oidc.Config{
+		Issuer:       s.cfg.String("auth.oidc.issuer"),
+		ClientID:     s.cfg.String("auth.oidc.client_id"),
+		ClientSecret: s.cfg.String("auth.oidc.client_secret"),
+		Scopes:       []string{"profile", "email"},
+	}
+}
+
+// getOIDCProvider returns the provider for the current config.
+// It is rebuilt when the config changes.
+func (s *State) getOIDCProvider() *oidc.Provider {
+	s.oidcMu.Lock()
+	defer s.oidcMu.Unlock()
+
+	cfg := s.oidcConfig()
+	if s.oidcProvider == nil || !reflect.DeepEqual(cfg, s.oidcProviderCfg) {
+		s.oidcProvider = oidc.NewProvider(cfg)
+		s.oidcProviderCfg = cfg
+	}
+
+	return s.oidcProvider
+}
+
+func (s *State) oidcRedirectURL(r *http.Request) string {
+	if redirectURL := s.cfg.String("auth.oidc.redirect_url"); redirectURL != "" {
+		return redirectURL
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host + "/api/v0/oidc/callback"
+}
+
+func randomOIDCValue() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+///////
+
+// OIDCLoginHandler implements http.Handler.
+// It redirects the user to the login page of the identity provider.
+type OIDCLoginHandler struct {
+	*State
+}
+
+// NewOIDCLoginHandler returns a new OIDCLoginHandler
+func NewOIDCLoginHandler(s *State) *OIDCLoginHandler {
+	return &OIDCLoginHandler{State: s}
+}
+
+func (oh *OIDCLoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !oh.cfg.Bool("auth.oidc.enabled") {
+		http.Error(w, "single sign-on is not enabled", http.StatusNotFound)
+		return
+	}
+
+	state, err := randomOIDCValue()
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	nonce, err := randomOIDCValue()
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	authURL, err := oh.getOIDCProvider().AuthURL(r.Context(), oh.oidcRedirectURL(r), state, nonce)
+	if err != nil {
+		log.Warningf("gateway: oidc: %v", err)
+		http.Error(w, "identity provider is not available", http.StatusBadGateway)
+		return
+	}
+
+	// Remember state and nonce until the user comes back:
+	sess, _ := oh.store.Get(r, "sess")
+	sess.Options = sessionOptions(r)
+	sess.Values["oidc_state"] = state
+	sess.Values["oidc_nonce"] = nonce
+	if err := sess.Save(r, w); err != nil {
+		log.Warningf("oidc: failed to save session: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+///////
+
+// OIDCCallbackHandler implements http.Handler.
+// The identity provider redirects the user here after logging in.
+type OIDCCallbackHandler struct {
+	*State
+}
+
+// NewOIDCCallbackHandler returns a new OIDCCallbackHandler
+func NewOIDCCallbackHandler(s *State) *OIDCCallbackHandler {
+	return &OIDCCallbackHandler{State: s}
+}
+
+func (oh *OIDCCallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !oh.cfg.Bool("auth.oidc.enabled") {
+		http.Error(w, "single sign-on is not enabled", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	if errMsg := query.Get("error"); errMsg != "" {
+		log.Debugf("gateway: oidc: provider returned error: %s", errMsg)
+		http.Error(w, "login failed: "+errMsg, http.StatusForbidden)
+		return
+	}
+
+	sess, err := oh.store.Get(r, "sess")
+	if err != nil {
+		http.Error(w, "no login in progress", http.StatusBadRequest)
+		return
+	}
+
+	state, _ := sess.Values["oidc_state"].(string)
+	nonce, _ := sess.Values["oidc_nonce"].(string)
+	delete(sess.Values, "oidc_state")
+	delete(sess.Values, "oidc_nonce")
+
+	if state == "" || state != query.Get("state") {
+		http.Error(w, "bad login state", http.StatusBadRequest)
+		return
+	}
+
+	provider := oh.getOIDCProvider()
+	claims, err := provider.Exchange(r.Context(), oh.oidcRedirectURL(r), query.Get("code"), nonce)
+	if err != nil {
+		log.Warningf("gateway: oidc: %v", err)
+		http.Error(w, "login failed", http.StatusForbidden)
+		return
+	}
+
+	name := claims.String(oh.cfg.String("auth.oidc.user_claim"))
+	if name == "" {
+		http.Error(w, "login failed: no user name", http.StatusForbidden)
+		return
+	}
+
+	if _, err := oh.userDb.Get(name); err != nil {
+		if !oh.createOIDCUser(name) {
+			oh.recordAuditAs(r, name, "gateway.login_failed", "", "oidc: unknown user")
+			http.Error(w, "login failed: unknown user", http.StatusForbidden)
+			return
+		}
+	}
+
+	oh.recordAuditAs(r, name, "gateway.login", "", "oidc")
+	setSession(oh.store, name, w, r)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// createOIDCUser creates a user for `name` with the folders and rights of
+// the template user. It is only possible to log in with the provider then,
+// since nobody knows the random password.
+func (oh *OIDCCallbackHandler) createOIDCUser(name string) bool {
+	templateName := oh.cfg.String("auth.oidc.template_user")
+	if templateName == "" {
+		return false
+	}
+
+	template, err := oh.userDb.Get(templateName)
+	if err != nil {
+		log.Warningf("gateway: oidc: no such template user: %s", templateName)
+		return false
+	}
+
+	password, err := randomOIDCValue()
+	if err != nil {
+		return false
+	}
+
+	if err := oh.userDb.Add(name, password, template.FolderSpecs(), template.Rights); err != nil {
+		log.Warningf("gateway: oidc: failed to create user %s: %v", name, err)
+		return false
+	}
+
+	log.Infof("gateway: created user »%s« on first login", name)
+	return true
+}
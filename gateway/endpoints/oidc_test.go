@@ -0,0 +1,121 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/sahib/brig/gateway/oidc"
+	"github.com/stretchr/testify/require"
+)
+
+func withOIDCState(t *testing.T, fn func(s *testState, mi *oidc.MockIssuer)) {
+	withState(t, func(s *testState) {
+		mi, err := oidc.NewMockIssuer("brig", "secret")
+		require.Nil(t, err)
+		defer mi.Close()
+
+		s.cfg.SetBool("auth.oidc.enabled", true)
+		s.cfg.SetString("auth.oidc.issuer", mi.URL())
+		s.cfg.SetString("auth.oidc.client_id", "brig")
+		s.cfg.SetString("auth.oidc.client_secret", "secret")
+		fn(s, mi)
+	})
+}
+
+// oidcLogin goes through the whole login flow and returns
+// the response of the callback and the cookies it set.
+func oidcLogin(t *testing.T, s *testState) (*http.Response, []*http.Cookie) {
+	req := httptest.NewRequest("GET", "http://localhost:5000/api/v0/oidc/login", nil)
+	rsw := httptest.NewRecorder()
+	NewOIDCLoginHandler(s.State).ServeHTTP(rsw, req)
+
+	resp := rsw.Result()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	cookies := resp.Cookies()
+
+	// The mock issuer logs in immediately and redirects back to us:
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	issuerResp, err := client.Get(resp.Header.Get("Location"))
+	require.Nil(t, err)
+	require.Equal(t, http.StatusFound, issuerResp.StatusCode)
+
+	callbackURL, err := url.Parse(issuerResp.Header.Get("Location"))
+	require.Nil(t, err)
+	require.Equal(t, "/api/v0/oidc/callback", callbackURL.Path)
+
+	req = httptest.NewRequest("GET", callbackURL.String(), nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	rsw = httptest.NewRecorder()
+	NewOIDCCallbackHandler(s.State).ServeHTTP(rsw, req)
+	return rsw.Result(), rsw.Result().Cookies()
+}
+
+func sessionUser(t *testing.T, s *testState, cookies []*http.Cookie) string {
+	req := httptest.NewRequest("GET", "http://localhost:5000/", nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	return getUserName(s.store, httptest.NewRecorder(), req)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	withOIDCState(t, func(s *testState, mi *oidc.MockIssuer) {
+		// By default the subject is used, which is not »ali«,
+		// even though the user said so at the provider:
+		mi.SetUser("ali")
+		resp, _ := oidcLogin(t, s)
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		s.cfg.SetString("auth.oidc.user_claim", "preferred_username")
+		resp, cookies := oidcLogin(t, s)
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+		require.Equal(t, "/", resp.Header.Get("Location"))
+		require.Equal(t, "ali", sessionUser(t, s, cookies))
+	})
+}
+
+func TestOIDCUnknownUser(t *testing.T) {
+	withOIDCState(t, func(s *testState, mi *oidc.MockIssuer) {
+		mi.SetUser("bob")
+		resp, _ := oidcLogin(t, s)
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		// With a template user, bob gets created:
+		s.cfg.SetString("auth.oidc.template_user", "ali")
+		resp, cookies := oidcLogin(t, s)
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+		require.Equal(t, "sub-bob", sessionUser(t, s, cookies))
+
+		bob, err := s.userDb.Get("sub-bob")
+		require.Nil(t, err)
+
+		ali, err := s.userDb.Get("ali")
+		require.Nil(t, err)
+		require.Equal(t, ali.Rights, bob.Rights)
+	})
+}
+
+func TestOIDCBadState(t *testing.T) {
+	withOIDCState(t, func(s *testState, mi *oidc.MockIssuer) {
+		req := httptest.NewRequest("GET", "http://localhost:5000/api/v0/oidc/callback?code=x&state=y", nil)
+		rsw := httptest.NewRecorder()
+		NewOIDCCallbackHandler(s.State).ServeHTTP(rsw, req)
+		require.Equal(t, http.StatusBadRequest, rsw.Result().StatusCode)
+
+		s.cfg.SetBool("auth.oidc.enabled", false)
+		rsw = httptest.NewRecorder()
+		NewOIDCLoginHandler(s.State).ServeHTTP(rsw, req)
+		require.Equal(t, http.StatusNotFound, rsw.Result().StatusCode)
+	})
+}
@@ -45,7 +45,7 @@ func (ph *PinHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	path := prefixRoot(pinReq.Path)
-	if !ph.validatePath(path, w, r) {
+	if !ph.checkPathRightBelow(path, db.RightFsEdit, w, r) {
 		jsonifyErrf(w, http.StatusUnauthorized, "path forbidden")
 		return
 	}
@@ -64,6 +64,7 @@ func (ph *PinHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	ph.recordAudit(w, r, "fs."+name, path, pinReq.Revision)
 	ph.evHdl.Notify(r.Context(), "pin")
 	jsonifySuccess(w)
 }
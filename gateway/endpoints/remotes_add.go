@@ -30,6 +30,9 @@ type RemoteAddRequest struct {
 	AcceptAutoUpdates bool                `json:"accept_auto_updates"`
 	AcceptPush        bool                `json:"accept_push"`
 	ConflictStrategy  string              `json:"conflict_strategy"`
+	Quota             *uint64             `json:"quota,omitempty"`
+	UploadLimit       *uint64             `json:"upload_limit,omitempty"`
+	DownloadLimit     *uint64             `json:"download_limit,omitempty"`
 }
 
 func dedupeFolders(folders []remotesapi.Folder) []remotesapi.Folder {
@@ -85,6 +88,9 @@ func readRemoteRequest(w http.ResponseWriter, r *http.Request) (*remotesapi.Remo
 		AcceptAutoUpdates: remoteAddReq.AcceptAutoUpdates,
 		AcceptPush:        remoteAddReq.AcceptPush,
 		ConflictStrategy:  remoteAddReq.ConflictStrategy,
+		Quota:             remoteAddReq.Quota,
+		UploadLimit:       remoteAddReq.UploadLimit,
+		DownloadLimit:     remoteAddReq.DownloadLimit,
 	}, nil
 }
 
@@ -104,6 +110,7 @@ func (rh *RemotesAddHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	rh.recordAudit(w, r, "remote.add", "", rmt.Name)
 	jsonifySuccess(w)
 }
 
@@ -135,5 +142,6 @@ func (rh *RemotesModifyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	rh.recordAudit(w, r, "remote.modify", "", rmt.Name)
 	jsonifySuccess(w)
 }
@@ -80,3 +80,43 @@ func TestRemoteModifyEndpoint(t *testing.T) {
 		require.Equal(t, true, rmt.AcceptAutoUpdates)
 	})
 }
+
+func TestRemoteModifyEndpointQuota(t *testing.T) {
+	withState(t, func(s *testState) {
+		quota := uint64(1024)
+		require.Nil(t, s.State.rapi.Set(remotesapi.Remote{
+			Name:        "bob",
+			Fingerprint: TestFingerprint,
+			Quota:       &quota,
+		}))
+
+		modify := func(quota *uint64) {
+			resp := s.mustRun(
+				t,
+				NewRemotesModifyHandler(s.State),
+				"POST",
+				"http://localhost:5000/api/v0/remotes/modify",
+				&RemoteAddRequest{
+					Name:        "bob",
+					Fingerprint: TestFingerprint,
+					Quota:       quota,
+				},
+			)
+
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+
+		// No quota given, the old one is kept:
+		modify(nil)
+		rmt, err := s.State.rapi.Get("bob")
+		require.Nil(t, err)
+		require.Equal(t, uint64(1024), *rmt.Quota)
+
+		// A quota of zero removes it:
+		noQuota := uint64(0)
+		modify(&noQuota)
+		rmt, err = s.State.rapi.Get("bob")
+		require.Nil(t, err)
+		require.Equal(t, uint64(0), *rmt.Quota)
+	})
+}
@@ -43,5 +43,7 @@ func (rh *RemotesRemoveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	rh.recordAudit(w, r, "remote.remove", "", rmtRmReq.Name)
+
 	jsonifySuccess(w)
 }
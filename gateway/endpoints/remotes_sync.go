@@ -43,5 +43,7 @@ func (rh *RemotesSyncHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	rh.recordAudit(w, r, "net.sync", "", rmtSyncReq.Name)
+
 	jsonifySuccess(w)
 }
@@ -0,0 +1,47 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sahib/brig/gateway/db"
+)
+
+// RemotesSyncCancelHandler implements http.Handler
+type RemotesSyncCancelHandler struct {
+	*State
+}
+
+// NewRemotesSyncCancelHandler returns a new RemotesSyncCancelHandler
+func NewRemotesSyncCancelHandler(s *State) *RemotesSyncCancelHandler {
+	return &RemotesSyncCancelHandler{State: s}
+}
+
+// RemoteSyncCancelRequest is the data being sent to this endpoint.
+type RemoteSyncCancelRequest struct {
+	Name string `json:"name"`
+}
+
+func (rh *RemotesSyncCancelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkRights(w, r, db.RightRemotesEdit, db.RightFsEdit) {
+		return
+	}
+
+	rmtCancelReq := RemoteSyncCancelRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&rmtCancelReq); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	if rmtCancelReq.Name == "" {
+		jsonifyErrf(w, http.StatusBadRequest, "empty remote name")
+		return
+	}
+
+	if err := rh.rapi.SyncCancel(rmtCancelReq.Name); err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "failed to cancel sync")
+		return
+	}
+
+	jsonifySuccess(w)
+}
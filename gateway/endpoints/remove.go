@@ -37,7 +37,7 @@ func (rh *RemoveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	for _, path := range rmReq.Paths {
 		path = prefixRoot(path)
-		if !rh.validatePath(path, w, r) {
+		if !rh.checkPathRightBelow(path, db.RightFsEdit, w, r) {
 			jsonifyErrf(w, http.StatusUnauthorized, "path forbidden")
 			return
 		}
@@ -53,6 +53,7 @@ func (rh *RemoveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 		paths = append(paths, path)
+		rh.recordAudit(w, r, "fs.remove", path, "")
 	}
 
 	if len(paths) > 0 {
@@ -38,7 +38,7 @@ func (rh *ResetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	path := prefixRoot(resetReq.Path)
-	if !rh.validatePath(path, w, r) {
+	if !rh.checkPathRightBelow(path, db.RightFsEdit, w, r) {
 		jsonifyErrf(w, http.StatusUnauthorized, "path forbidden")
 		return
 	}
@@ -62,5 +62,7 @@ func (rh *ResetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	rh.recordAudit(w, r, "vcs.reset", path, resetReq.Revision)
+
 	jsonifySuccess(w)
 }
@@ -0,0 +1,101 @@
+package endpoints
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	ie "github.com/sahib/brig/catfs/errors"
+	"github.com/sahib/brig/gateway/thumbs"
+	log "github.com/sirupsen/logrus"
+)
+
+// ThumbHandler implements http.Handler.
+// It serves small JPEG previews of images and PDF documents.
+type ThumbHandler struct {
+	*GetHandler
+	cache *thumbs.Cache
+}
+
+// NewThumbHandler returns a new ThumbHandler
+func NewThumbHandler(s *State, cache *thumbs.Cache) *ThumbHandler {
+	return &ThumbHandler{
+		GetHandler: NewGetHandler(s),
+		cache:      cache,
+	}
+}
+
+func (th *ThumbHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// get the file nodePath including the leading slash:
+	fullURL := r.URL.EscapedPath()
+	nodePath, err := url.PathUnescape(fullURL[len("/thumb"):])
+	if err != nil {
+		log.Debugf("received malformed url: %s", fullURL)
+		http.Error(w, "malformed url", http.StatusBadRequest)
+		return
+	}
+
+	if !th.cfg.Bool("thumbnails.enabled") || !thumbs.IsSupported(nodePath) {
+		http.Error(w, "no preview", http.StatusNotFound)
+		return
+	}
+
+	if _, ok := th.authorize(nodePath, w, r); !ok {
+		return
+	}
+
+	info, err := th.fs.Stat(nodePath)
+	if err != nil {
+		if ie.IsNoSuchFileError(err) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		log.Errorf("gateway: failed to stat %s: %v", nodePath, err)
+		http.Error(w, "failed to stat file", http.StatusInternalServerError)
+		return
+	}
+
+	if info.IsDir {
+		http.Error(w, "no preview", http.StatusNotFound)
+		return
+	}
+
+	size := thumbs.DefaultSize
+	if sizeParam := r.URL.Query().Get("size"); sizeParam != "" {
+		size, err = strconv.Atoi(sizeParam)
+		if err != nil || size <= 0 {
+			http.Error(w, "bad size", http.StatusBadRequest)
+			return
+		}
+
+		if size > thumbs.MaxSize {
+			size = thumbs.MaxSize
+		}
+	}
+
+	hash := info.ContentHash.B58String()
+	data, err := th.cache.Get(hash, nodePath, size, func() (io.ReadCloser, error) {
+		return th.fs.Cat(nodePath)
+	})
+
+	if err == thumbs.ErrNoPreview {
+		http.Error(w, "no preview", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		log.Errorf("gateway: failed to generate thumbnail for %s: %v", nodePath, err)
+		http.Error(w, "failed to generate thumbnail", http.StatusInternalServerError)
+		return
+	}
+
+	// The thumbnail only changes when the content changes:
+	hdr := w.Header()
+	hdr.Set("Content-Type", "image/jpeg")
+	hdr.Set("ETag", strconv.Quote(hash+"-"+strconv.Itoa(size)))
+	hdr.Set("Cache-Control", "private, max-age=86400")
+	http.ServeContent(w, r, "", info.ModTime, bytes.NewReader(data))
+}
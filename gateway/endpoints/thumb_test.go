@@ -0,0 +1,75 @@
+package endpoints
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/sahib/brig/gateway/thumbs"
+	"github.com/stretchr/testify/require"
+)
+
+func withThumbHandler(t *testing.T, s *testState, fn func(hdl *ThumbHandler)) {
+	dir, err := ioutil.TempDir("", "brig-endpoints-test-thumbs")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	cache, err := thumbs.NewCache(dir, 1024*1024)
+	require.Nil(t, err)
+	fn(NewThumbHandler(s.State, cache))
+}
+
+func TestThumbEndpointSuccess(t *testing.T) {
+	withState(t, func(s *testState) {
+		buf := &bytes.Buffer{}
+		require.Nil(t, png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 100, 50))))
+		require.Nil(t, s.fs.Stage("/pic.png", bytes.NewReader(buf.Bytes())))
+
+		withThumbHandler(t, s, func(hdl *ThumbHandler) {
+			resp := s.mustRun(t, hdl, "GET", "http://localhost:5000/thumb/pic.png?size=20", nil)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, "image/jpeg", resp.Header.Get("Content-Type"))
+
+			cfg, _, err := image.DecodeConfig(resp.Body)
+			require.Nil(t, err)
+			require.Equal(t, 20, cfg.Width)
+			require.Equal(t, 10, cfg.Height)
+		})
+	})
+}
+
+func TestThumbEndpointNoPreview(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Stage("/file.txt", bytes.NewReader([]byte("hello"))))
+		require.Nil(t, s.fs.Stage("/broken.jpg", bytes.NewReader([]byte("hello"))))
+
+		withThumbHandler(t, s, func(hdl *ThumbHandler) {
+			for _, url := range []string{
+				"http://localhost:5000/thumb/file.txt",
+				"http://localhost:5000/thumb/broken.jpg",
+				"http://localhost:5000/thumb/missing.jpg",
+			} {
+				resp := s.mustRun(t, hdl, "GET", url, nil)
+				require.Equal(t, http.StatusNotFound, resp.StatusCode, url)
+			}
+		})
+	})
+}
+
+func TestThumbEndpointDisallowed(t *testing.T) {
+	withState(t, func(s *testState) {
+		buf := &bytes.Buffer{}
+		require.Nil(t, png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 10, 10))))
+		require.Nil(t, s.fs.Stage("/pic.png", bytes.NewReader(buf.Bytes())))
+		s.mustChangeFolders(t, "/public")
+
+		withThumbHandler(t, s, func(hdl *ThumbHandler) {
+			resp := s.mustRun(t, hdl, "GET", "http://localhost:5000/thumb/pic.png", nil)
+			require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		})
+	})
+}
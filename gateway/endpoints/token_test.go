@@ -0,0 +1,52 @@
+package endpoints
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sahib/brig/gateway/db"
+	"github.com/stretchr/testify/require"
+)
+
+// runWithToken runs a request without session, but with an API token.
+func (s *testState) runWithToken(t *testing.T, hdl http.Handler, token, url string, jsonBody interface{}) *http.Response {
+	req := httptest.NewRequest("POST", url, mustEncodeBody(t, jsonBody))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rsw := httptest.NewRecorder()
+	TokenMiddleware(s.State)(AuthMiddleware(s.State)(hdl)).ServeHTTP(rsw, req)
+	return rsw.Result()
+}
+
+func TestTokenAuth(t *testing.T) {
+	withState(t, func(s *testState) {
+		require.Nil(t, s.fs.Stage("/public/a.png", bytes.NewReader([]byte("a"))))
+		require.Nil(t, s.fs.Stage("/private/b.png", bytes.NewReader([]byte("b"))))
+
+		token, _, err := s.userDb.AddToken("ali", "script", []string{"/public"}, []string{db.RightFsView})
+		require.Nil(t, err)
+
+		resp := s.runWithToken(t, NewLsHandler(s.State), token, "http://localhost:5000/api/v0/ls", &LsRequest{
+			Root: "/",
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		lsResp := &LsResponse{}
+		mustDecodeBody(t, resp.Body, &lsResp)
+		require.Len(t, lsResp.Files, 1)
+		require.Equal(t, "/public", lsResp.Files[0].Path)
+
+		// The token has no right to edit:
+		resp = s.runWithToken(t, NewMkdirHandler(s.State), token, "http://localhost:5000/api/v0/mkdir", &MkdirRequest{
+			Path: "/public/new",
+		})
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		resp = s.runWithToken(t, NewLsHandler(s.State), token+"x", "http://localhost:5000/api/v0/ls", &LsRequest{
+			Root: "/",
+		})
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
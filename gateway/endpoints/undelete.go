@@ -37,7 +37,7 @@ func (uh *UndeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	path := prefixRoot(undelReq.Path)
-	if !uh.validatePath(path, w, r) {
+	if !uh.checkPathRight(path, db.RightFsEdit, w, r) {
 		jsonifyErrf(w, http.StatusUnauthorized, "path forbidden")
 		return
 	}
@@ -54,5 +54,7 @@ func (uh *UndeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	uh.recordAudit(w, r, "fs.undelete", path, "")
+
 	jsonifySuccess(w)
 }
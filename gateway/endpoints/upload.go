@@ -53,7 +53,7 @@ func (uh *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			if !uh.validatePath(path, w, r) {
+			if !uh.checkPathRight(path, db.RightFsEdit, w, r) {
 				jsonifyErrf(w, http.StatusUnauthorized, "unauthorized")
 				return
 			}
@@ -66,6 +66,7 @@ func (uh *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			}
 
 			paths = append(paths, path)
+			uh.recordAudit(w, r, "fs.stage", path, "upload")
 			fd.Close()
 		}
 	}
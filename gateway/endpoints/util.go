@@ -8,14 +8,17 @@ import (
 	"net/http"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/securecookie"
 	"github.com/gorilla/sessions"
+	"github.com/sahib/brig/audit"
 	"github.com/sahib/brig/catfs"
 	ie "github.com/sahib/brig/catfs/errors"
 	"github.com/sahib/brig/events"
 	"github.com/sahib/brig/gateway/db"
+	"github.com/sahib/brig/gateway/oidc"
 	"github.com/sahib/brig/gateway/remotesapi"
 	"github.com/sahib/config"
 	log "github.com/sirupsen/logrus"
@@ -31,6 +34,11 @@ type State struct {
 	evHdl  *EventsHandler
 	store  *sessions.CookieStore
 	userDb *db.UserDatabase
+	audit  *audit.Log
+
+	oidcMu          sync.Mutex
+	oidcProvider    *oidc.Provider
+	oidcProviderCfg oidc.Config
 }
 
 func readOrInitKeyFromConfig(cfg *config.Config, keyName string, keyLen int) ([]byte, error) {
@@ -91,6 +99,38 @@ func (s *State) UserDatabase() *db.UserDatabase {
 	return s.userDb
 }
 
+// SetAuditLog sets the log that records all actions done via the gateway.
+// If it is never called, nothing is recorded.
+func (s *State) SetAuditLog(lg *audit.Log) {
+	s.audit = lg
+}
+
+// recordAudit adds an entry for the user that is doing `r` to the audit log.
+func (s *State) recordAudit(w http.ResponseWriter, r *http.Request, action, nodePath, detail string) {
+	name := ""
+	if user, ok := s.requestUser(w, r); ok {
+		name = user.Name
+	}
+
+	s.recordAuditAs(r, name, action, nodePath, detail)
+}
+
+// recordAuditAs is like recordAudit, but for an explicitly given user name.
+func (s *State) recordAuditAs(r *http.Request, name, action, nodePath, detail string) {
+	err := s.audit.Record(audit.Entry{
+		Source: audit.SourceGateway,
+		User:   name,
+		Addr:   r.RemoteAddr,
+		Action: action,
+		Path:   nodePath,
+		Detail: detail,
+	})
+
+	if err != nil {
+		log.Warningf("failed to record audit entry: %v", err)
+	}
+}
+
 func (s *State) publishFsEvent(req *http.Request) {
 	if s.evHdl != nil {
 		ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
@@ -121,44 +161,43 @@ func prefixRoot(nodePath string) string {
 	return "/" + nodePath
 }
 
-func buildFolderCache(folders []string) map[string]bool {
-	folderCache := make(map[string]bool)
-	for _, folder := range folders {
-		folderCache[prefixRoot(path.Clean(folder))] = true
-	}
-
-	return folderCache
-}
-
-func (s *State) pathIsVisible(nodePath string, w http.ResponseWriter, r *http.Request) bool {
-	nodePath = prefixRoot(path.Clean(nodePath))
-	if s.validatePath(nodePath, w, r) {
-		return true
+// requestUser returns the user that is doing the request `r`. This is the
+// user attached by one of the auth middlewares (possibly restricted by an
+// API token) or otherwise the user of the current session.
+func (s *State) requestUser(w http.ResponseWriter, r *http.Request) (db.User, bool) {
+	if user, ok := r.Context().Value(dbUserKey("brig.db_user")).(db.User); ok {
+		return user, true
 	}
 
 	name := getUserName(s.store, w, r)
 	if name == "" {
-		return false
+		return db.User{}, false
 	}
 
 	user, err := s.userDb.Get(name)
 	if err != nil {
-		return false
+		return db.User{}, false
 	}
 
-	folderCache := buildFolderCache(user.Folders)
-	if err != nil {
-		log.Debugf("failed to build folder cache: %v", err)
+	return user, true
+}
+
+func (s *State) pathIsVisible(nodePath string, w http.ResponseWriter, r *http.Request) bool {
+	nodePath = prefixRoot(path.Clean(nodePath))
+	if s.validatePath(nodePath, w, r) {
+		return true
+	}
+
+	user, ok := s.requestUser(w, r)
+	if !ok {
 		return false
 	}
 
 	// Go over all folders, and see if we have some allowed folder
 	// that we need to display "on the way". This could be probably
 	// made faster if we ever need to.
-	for folder, isValid := range folderCache {
-		if !isValid {
-			continue
-		}
+	for _, folder := range user.Roots() {
+		folder = prefixRoot(path.Clean(folder))
 
 		// Example case:
 		// folder   = /nested/something
@@ -167,7 +206,7 @@ func (s *State) pathIsVisible(nodePath string, w http.ResponseWriter, r *http.Re
 		//
 		// Other case (folder = /nested, nodePath = /nested/something)
 		// is already handled by calling validatePath() above.
-		if strings.HasPrefix(folder, nodePath) {
+		if nodePath == "/" || folder == nodePath || strings.HasPrefix(folder, nodePath+"/") {
 			return true
 		}
 	}
@@ -176,45 +215,40 @@ func (s *State) pathIsVisible(nodePath string, w http.ResponseWriter, r *http.Re
 	return false
 }
 
+// validatePath checks if the user of `r` may view `nodePath`.
 func (s *State) validatePath(nodePath string, w http.ResponseWriter, r *http.Request) bool {
-	if !strings.HasPrefix(nodePath, "/") {
-		return false
-	}
+	return s.checkPathRight(nodePath, db.RightFsView, w, r)
+}
 
-	name := getUserName(s.store, w, r)
-	if name == "" {
+// checkPathRight checks if the user of `r` has `right` for `nodePath`.
+func (s *State) checkPathRight(nodePath, right string, w http.ResponseWriter, r *http.Request) bool {
+	if !strings.HasPrefix(nodePath, "/") {
 		return false
 	}
 
-	user, err := s.userDb.Get(name)
-	if err != nil {
+	user, ok := s.requestUser(w, r)
+	if !ok {
 		return false
 	}
 
 	// At this point we know that the user is logged in.
-	return s.validatePathForUser(nodePath, user, w, r)
+	return user.HasRight(nodePath, right)
 }
 
-func (s *State) validatePathForUser(nodePath string, user db.User, w http.ResponseWriter, r *http.Request) bool {
-	curr := prefixRoot(nodePath)
-	folderCache := buildFolderCache(user.Folders)
-
-	for curr != "" {
-		if folderCache[curr] {
-			return true
-		}
-
-		next := path.Dir(curr)
-		if curr == "/" && next == curr {
-			// We've gone up too much:
-			break
-		}
+// checkPathRightBelow is like checkPathRight, but also checks that no
+// folder below `nodePath` takes away `right`. Use it for operations
+// that work on a whole directory.
+func (s *State) checkPathRightBelow(nodePath, right string, w http.ResponseWriter, r *http.Request) bool {
+	if !strings.HasPrefix(nodePath, "/") {
+		return false
+	}
 
-		curr = next
+	user, ok := s.requestUser(w, r)
+	if !ok {
+		return false
 	}
 
-	// No fitting path found:
-	return false
+	return user.HasRightBelow(nodePath, right)
 }
 
 //////////////////////
@@ -257,8 +291,8 @@ func jsonifySuccess(w http.ResponseWriter) {
 }
 
 func (s *State) commitChange(msg string, w http.ResponseWriter, r *http.Request) bool {
-	name := getUserName(s.store, w, r)
-	fullMsg := fmt.Sprintf("gateway: »%s« %s", name, msg)
+	user, _ := s.requestUser(w, r)
+	fullMsg := fmt.Sprintf("gateway: »%s« %s", user.Name, msg)
 
 	if err := s.fs.MakeCommit(fullMsg); err != nil {
 		if err != ie.ErrNoChange {
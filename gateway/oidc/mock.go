@@ -0,0 +1,199 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// MockIssuer is a minimal identity provider for testing. Every visit of
+// its login page logs in the user set by SetUser without asking.
+type MockIssuer struct {
+	mu           sync.Mutex
+	srv          *httptest.Server
+	key          *rsa.PrivateKey
+	clientID     string
+	clientSecret string
+	user         string
+	codes        map[string]string
+}
+
+// NewMockIssuer starts a new issuer on a random local port
+// that accepts the client `clientID` with `clientSecret`.
+func NewMockIssuer(clientID, clientSecret string) (*MockIssuer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	mi := &MockIssuer{
+		key:          key,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		user:         "ali",
+		codes:        make(map[string]string),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", mi.handleDiscovery)
+	mux.HandleFunc("/auth", mi.handleAuth)
+	mux.HandleFunc("/token", mi.handleToken)
+	mux.HandleFunc("/keys", mi.handleKeys)
+	mi.srv = httptest.NewServer(mux)
+	return mi, nil
+}
+
+// URL returns the issuer URL.
+func (mi *MockIssuer) URL() string {
+	return mi.srv.URL
+}
+
+// SetUser sets the »preferred_username« of the next logins.
+func (mi *MockIssuer) SetUser(name string) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	mi.user = name
+}
+
+// Close shuts down the issuer.
+func (mi *MockIssuer) Close() {
+	mi.srv.Close()
+}
+
+func (mi *MockIssuer) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (mi *MockIssuer) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	mi.writeJSON(w, metadata{
+		Issuer:                mi.srv.URL,
+		AuthorizationEndpoint: mi.srv.URL + "/auth",
+		TokenEndpoint:         mi.srv.URL + "/token",
+		JWKSURI:               mi.srv.URL + "/keys",
+	})
+}
+
+func (mi *MockIssuer) handleKeys(w http.ResponseWriter, r *http.Request) {
+	encode := base64.RawURLEncoding.EncodeToString
+	mi.writeJSON(w, map[string][]jsonWebKey{
+		"keys": {{
+			Kty: "RSA",
+			Kid: "mock",
+			N:   encode(mi.key.N.Bytes()),
+			E:   encode(big.NewInt(int64(mi.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (mi *MockIssuer) handleAuth(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != mi.clientID {
+		http.Error(w, "unknown client", http.StatusBadRequest)
+		return
+	}
+
+	redirectURL, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "bad redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	codeData := make([]byte, 16)
+	if _, err := rand.Read(codeData); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	code := hex.EncodeToString(codeData)
+
+	mi.mu.Lock()
+	mi.codes[code] = query.Get("nonce")
+	mi.mu.Unlock()
+
+	redirectQuery := redirectURL.Query()
+	redirectQuery.Set("code", code)
+	redirectQuery.Set("state", query.Get("state"))
+	redirectURL.RawQuery = redirectQuery.Encode()
+	http.Redirect(w, r, redirectURL.String(), http.StatusFound)
+}
+
+func (mi *MockIssuer) handleToken(w http.ResponseWriter, r *http.Request) {
+	// Credentials are form-encoded before being put into the header:
+	id, secret, ok := r.BasicAuth()
+	id, _ = url.QueryUnescape(id)
+	secret, _ = url.QueryUnescape(secret)
+	if !ok || id != mi.clientID || secret != mi.clientSecret {
+		http.Error(w, "bad client credentials", http.StatusUnauthorized)
+		return
+	}
+
+	code := r.FormValue("code")
+
+	mi.mu.Lock()
+	nonce, ok := mi.codes[code]
+	delete(mi.codes, code)
+	user := mi.user
+	mi.mu.Unlock()
+
+	if !ok {
+		http.Error(w, "bad code", http.StatusBadRequest)
+		return
+	}
+
+	idToken, err := mi.sign(map[string]interface{}{
+		"iss":                mi.srv.URL,
+		"aud":                mi.clientID,
+		"sub":                "sub-" + user,
+		"preferred_username": user,
+		"email":              user + "@example.org",
+		"nonce":              nonce,
+		"iat":                time.Now().Unix(),
+		"exp":                time.Now().Add(time.Hour).Unix(),
+	})
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	mi.writeJSON(w, map[string]string{
+		"access_token": "mock",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func (mi *MockIssuer) sign(claims map[string]interface{}) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": "mock"})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	encode := base64.RawURLEncoding.EncodeToString
+	signed := encode(header) + "." + encode(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, mi.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signed + "." + encode(sig), nil
+}
@@ -0,0 +1,411 @@
+// Package oidc implements the parts of OpenID Connect that the gateway needs
+// to log in users with an external identity provider: discovery, the
+// authorization code flow and verification of the returned ID token.
+// Only RS256 and ES256 signed tokens are supported, which covers the
+// common providers.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	e "github.com/pkg/errors"
+)
+
+const (
+	// clockSkew is how much we tolerate the clocks of us
+	// and the provider to differ when checking the expiry.
+	clockSkew = time.Minute
+)
+
+// Config describes how to talk to an identity provider.
+type Config struct {
+	// Issuer is the URL of the provider, as given in the »iss« claim.
+	Issuer string
+	// ClientID is the id this gateway is registered with at the provider.
+	ClientID string
+	// ClientSecret is the secret of this client.
+	ClientSecret string
+	// Scopes are requested in addition to »openid«.
+	Scopes []string
+}
+
+// Claims are the claims of a verified ID token.
+type Claims map[string]interface{}
+
+// String returns the claim `key` if it is a string or an empty string.
+func (c Claims) String(key string) string {
+	val, _ := c[key].(string)
+	return val
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Provider talks to an identity provider. The discovery document and the
+// signing keys are fetched on first use and cached afterwards.
+type Provider struct {
+	mu     sync.Mutex
+	cfg    Config
+	client *http.Client
+	meta   *metadata
+	keys   map[string]crypto.PublicKey
+}
+
+// NewProvider returns a new provider for `cfg`.
+func NewProvider(cfg Config) *Provider {
+	return &Provider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (p *Provider) metadata(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	issuer := strings.TrimSuffix(p.cfg.Issuer, "/")
+	meta := &metadata{}
+	if err := p.getJSON(ctx, issuer+"/.well-known/openid-configuration", meta); err != nil {
+		return nil, e.Wrap(err, "discovery failed")
+	}
+
+	if strings.TrimSuffix(meta.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("issuer mismatch: %s != %s", meta.Issuer, issuer)
+	}
+
+	p.meta = meta
+	return meta, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
+
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+
+		exp, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(exp.Int64())}, nil
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve: %s", jwk.Crv)
+		}
+
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", jwk.Kty)
+	}
+}
+
+// key returns the signing key with the id `kid`. The keys are fetched
+// again if the id is unknown, since providers rotate their keys.
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	jwks := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+
+	if err := p.getJSON(ctx, meta.JWKSURI, &jwks); err != nil {
+		return nil, e.Wrap(err, "failed to fetch keys")
+	}
+
+	p.keys = make(map[string]crypto.PublicKey)
+	for _, jwk := range jwks.Keys {
+		key, err := jwk.publicKey()
+		if err != nil {
+			// Skip keys we do not understand; they might not be used.
+			continue
+		}
+
+		p.keys[jwk.Kid] = key
+	}
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("no key with id »%s«", kid)
+	}
+
+	return key, nil
+}
+
+// AuthURL returns the URL of the provider's login page. The provider will
+// redirect the user to `redirectURL` afterwards. `state` is passed back
+// as-is, `nonce` is embedded in the ID token.
+func (p *Provider) AuthURL(ctx context.Context, redirectURL, state, nonce string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := append([]string{"openid"}, p.cfg.Scopes...)
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.cfg.ClientID)
+	query.Set("redirect_uri", redirectURL)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	authURL.RawQuery = query.Encode()
+	return authURL.String(), nil
+}
+
+// Exchange trades the authorization code `code` for an ID token
+// and returns its claims after verifying it.
+func (p *Provider) Exchange(ctx context.Context, redirectURL, code, nonce string) (Claims, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+
+	req, err := http.NewRequest("POST", meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, e.Wrap(err, "token request failed")
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token request failed: %s: %s", resp.Status, body)
+	}
+
+	tokenResp := struct {
+		IDToken string `json:"id_token"`
+	}{}
+
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, e.Wrap(err, "bad token response")
+	}
+
+	if tokenResp.IDToken == "" {
+		return nil, fmt.Errorf("no id_token in response")
+	}
+
+	return p.Verify(ctx, tokenResp.IDToken, nonce)
+}
+
+func verifySignature(key crypto.PublicKey, alg string, signed, sig []byte) error {
+	digest := sha256.Sum256(signed)
+
+	switch alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key does not match algorithm %s", alg)
+		}
+
+		return rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], sig)
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(sig) != 64 {
+			return fmt.Errorf("key does not match algorithm %s", alg)
+		}
+
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(ecKey, digest[:], r, s) {
+			return fmt.Errorf("bad signature")
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+}
+
+func (c Claims) hasAudience(clientID string) bool {
+	switch aud := c["aud"].(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, elem := range aud {
+			if elem == clientID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Verify checks the signature and the claims of the ID token `rawToken`.
+func (p *Provider) Verify(ctx context.Context, rawToken, nonce string) (Claims, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed id token")
+	}
+
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+
+	headerData, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, e.Wrap(err, "malformed id token header")
+	}
+
+	if err := json.Unmarshal(headerData, &header); err != nil {
+		return nil, e.Wrap(err, "malformed id token header")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, e.Wrap(err, "malformed id token signature")
+	}
+
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	if err := verifySignature(key, header.Alg, signed, sig); err != nil {
+		return nil, e.Wrap(err, "id token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, e.Wrap(err, "malformed id token payload")
+	}
+
+	claims := Claims{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, e.Wrap(err, "malformed id token payload")
+	}
+
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.String("iss") != meta.Issuer {
+		return nil, fmt.Errorf("id token has wrong issuer: %s", claims.String("iss"))
+	}
+
+	if !claims.hasAudience(p.cfg.ClientID) {
+		return nil, fmt.Errorf("id token is not meant for us")
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok || time.Unix(int64(exp), 0).Add(clockSkew).Before(time.Now()) {
+		return nil, fmt.Errorf("id token expired")
+	}
+
+	if claims.String("nonce") != nonce {
+		return nil, fmt.Errorf("id token has wrong nonce")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,102 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func withMockIssuer(t *testing.T, fn func(mi *MockIssuer, p *Provider)) {
+	mi, err := NewMockIssuer("brig", "s3cr3t")
+	require.Nil(t, err)
+	defer mi.Close()
+
+	fn(mi, NewProvider(Config{
+		Issuer:       mi.URL(),
+		ClientID:     "brig",
+		ClientSecret: "s3cr3t",
+	}))
+}
+
+// login follows the auth url and returns the code the provider hands out.
+func login(t *testing.T, p *Provider, state, nonce string) string {
+	ctx := context.Background()
+	authURL, err := p.AuthURL(ctx, "http://localhost/callback", state, nonce)
+	require.Nil(t, err)
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(authURL)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	redirect, err := url.Parse(resp.Header.Get("Location"))
+	require.Nil(t, err)
+	require.Equal(t, state, redirect.Query().Get("state"))
+	return redirect.Query().Get("code")
+}
+
+func TestLoginFlow(t *testing.T) {
+	withMockIssuer(t, func(mi *MockIssuer, p *Provider) {
+		mi.SetUser("bob")
+		code := login(t, p, "some-state", "some-nonce")
+
+		ctx := context.Background()
+		claims, err := p.Exchange(ctx, "http://localhost/callback", code, "some-nonce")
+		require.Nil(t, err)
+		require.Equal(t, "bob", claims.String("preferred_username"))
+		require.Equal(t, "bob@example.org", claims.String("email"))
+
+		// Codes can only be used once:
+		_, err = p.Exchange(ctx, "http://localhost/callback", code, "some-nonce")
+		require.NotNil(t, err)
+	})
+}
+
+func TestBadNonce(t *testing.T) {
+	withMockIssuer(t, func(mi *MockIssuer, p *Provider) {
+		code := login(t, p, "state", "nonce")
+		_, err := p.Exchange(context.Background(), "http://localhost/callback", code, "other-nonce")
+		require.NotNil(t, err)
+	})
+}
+
+func TestBadSignature(t *testing.T) {
+	withMockIssuer(t, func(mi *MockIssuer, p *Provider) {
+		token, err := mi.sign(map[string]interface{}{
+			"iss":   mi.URL(),
+			"aud":   "brig",
+			"nonce": "nonce",
+			"exp":   float64(1 << 40),
+		})
+		require.Nil(t, err)
+
+		ctx := context.Background()
+		_, err = p.Verify(ctx, token, "nonce")
+		require.Nil(t, err)
+
+		// Flip a bit in the payload:
+		tampered := []byte(token)
+		tampered[len(tampered)/2] ^= 1
+		_, err = p.Verify(ctx, string(tampered), "nonce")
+		require.NotNil(t, err)
+
+		// Tokens for other clients are rejected:
+		token, err = mi.sign(map[string]interface{}{
+			"iss":   mi.URL(),
+			"aud":   "other",
+			"nonce": "nonce",
+			"exp":   float64(1 << 40),
+		})
+		require.Nil(t, err)
+		_, err = p.Verify(ctx, token, "nonce")
+		require.NotNil(t, err)
+	})
+}
@@ -17,6 +17,8 @@ type Folder struct {
 }
 
 // Remote is a the result of List and Get.
+// Quota and the limits are pointers, so that Set can tell
+// "not given" from "no quota" or "unlimited".
 type Remote struct {
 	Name              string    `json:"name"`
 	Folders           []Folder  `json:"folders"`
@@ -27,6 +29,10 @@ type Remote struct {
 	AcceptPush        bool      `json:"accept_push"`
 	ConflictStrategy  string    `json:"conflict_strategy"`
 	LastSeen          time.Time `json:"last_seen"`
+	SubscribedFolders []string  `json:"subscribed_folders"`
+	Quota             *uint64   `json:"quota,omitempty"`
+	UploadLimit       *uint64   `json:"upload_limit,omitempty"`
+	DownloadLimit     *uint64   `json:"download_limit,omitempty"`
 }
 
 // Identity describes our own repository identity.
@@ -46,5 +52,6 @@ type RemotesAPI interface {
 	OnChange(fn func())
 
 	Sync(name string) error
+	SyncCancel(name string) error
 	MakeDiff(name string) (*catfs.Diff, error)
 }
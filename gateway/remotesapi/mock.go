@@ -69,6 +69,17 @@ func (m *Mock) Set(rm Remote) error {
 		rm.IsAuthenticated = prevRm.IsAuthenticated
 		rm.LastSeen = prevRm.LastSeen
 		rm.IsOnline = prevRm.IsOnline
+		if rm.Quota == nil {
+			rm.Quota = prevRm.Quota
+		}
+
+		if rm.UploadLimit == nil {
+			rm.UploadLimit = prevRm.UploadLimit
+		}
+
+		if rm.DownloadLimit == nil {
+			rm.DownloadLimit = prevRm.DownloadLimit
+		}
 	}
 
 	m.remotes[rm.Name] = &rm
@@ -105,6 +116,16 @@ func (m *Mock) Sync(name string) error {
 	return nil
 }
 
+// SyncCancel cancels all running syncs with `name`.
+// The mock implementation does nothing, since Sync returns immediately.
+func (m *Mock) SyncCancel(name string) error {
+	if _, ok := m.remotes[name]; !ok {
+		return fmt.Errorf("no such remote: %s", name)
+	}
+
+	return nil
+}
+
 func dummyNode(path, user string, isDir bool) catfs.StatInfo {
 	return catfs.StatInfo{
 		BackendHash: h.EmptyBackendHash.Clone(),
@@ -4,17 +4,21 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"path/filepath"
 	"time"
 
 	"github.com/NYTimes/gziphandler"
+	humanize "github.com/dustin/go-humanize"
 	"github.com/gorilla/csrf"
 	"github.com/gorilla/mux"
 	"github.com/phogolabs/parcello"
+	"github.com/sahib/brig/audit"
 	"github.com/sahib/brig/catfs"
 	"github.com/sahib/brig/events"
 	"github.com/sahib/brig/gateway/db"
 	"github.com/sahib/brig/gateway/endpoints"
 	"github.com/sahib/brig/gateway/remotesapi"
+	"github.com/sahib/brig/gateway/thumbs"
 	"github.com/sahib/config"
 	log "github.com/sirupsen/logrus"
 	"github.com/ulule/limiter"
@@ -40,6 +44,7 @@ type Gateway struct {
 	isReloading bool
 	state       *endpoints.State
 	evHdl       *endpoints.EventsHandler
+	thumbs      *thumbs.Cache
 
 	srv      *http.Server
 	redirSrv *http.Server
@@ -59,11 +64,20 @@ func NewGateway(fs *catfs.FS, rapi remotesapi.RemotesAPI, cfg *config.Config, ev
 		return nil, err
 	}
 
+	thumbCache, err := thumbs.NewCache(
+		filepath.Join(dbPath, "thumbs"),
+		thumbCacheSize(cfg),
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	gw := &Gateway{
 		state:    state,
 		isClosed: true,
 		cfg:      cfg,
 		evHdl:    evHdl,
+		thumbs:   thumbCache,
 	}
 
 	// Restarts the gateway on the next possible idle phase:
@@ -97,9 +111,22 @@ func NewGateway(fs *catfs.FS, rapi remotesapi.RemotesAPI, cfg *config.Config, ev
 	cfg.AddEvent("auth.session-encryption-key", reloader)
 	cfg.AddEvent("auth.session-authentication-key", reloader)
 	cfg.AddEvent("auth.session-csrf-key", reloader)
+	cfg.AddEvent("thumbnails.cache_size", func(key string) {
+		thumbCache.SetMaxSize(thumbCacheSize(cfg))
+	})
 	return gw, nil
 }
 
+func thumbCacheSize(cfg *config.Config) int64 {
+	size, err := humanize.ParseBytes(cfg.String("thumbnails.cache_size"))
+	if err != nil {
+		log.Warningf("bad thumbnail cache size: %v", err)
+		return 0
+	}
+
+	return int64(size)
+}
+
 // Stop stops the gateway gracefully.
 func (gw *Gateway) Stop() error {
 	if gw.isClosed {
@@ -188,7 +215,11 @@ func (gw *Gateway) Start() {
 	// Use csrf protection for all routes by default.
 	// This does not influence GET routes, only POST ones:
 	router := mux.NewRouter()
+	router.Use(endpoints.MetricsMiddleware())
 	router.Use(endpoints.SecureMiddleware(gw.state))
+
+	// API tokens are checked before CSRF, since requests using them skip it.
+	router.Use(endpoints.TokenMiddleware(gw.state))
 	needsAuth := endpoints.AuthMiddleware(gw.state)
 
 	csrfOpts := []csrf.Option{
@@ -231,7 +262,12 @@ func (gw *Gateway) Start() {
 		apiRouter.Handle("/remotes/remove", needsAuth(endpoints.NewRemotesRemoveHandler(gw.state)))
 		apiRouter.Handle("/remotes/self", needsAuth(endpoints.NewRemotesSelfHandler(gw.state)))
 		apiRouter.Handle("/remotes/sync", needsAuth(endpoints.NewRemotesSyncHandler(gw.state)))
+		apiRouter.Handle("/remotes/sync/cancel", needsAuth(endpoints.NewRemotesSyncCancelHandler(gw.state)))
 		apiRouter.Handle("/remotes/diff", needsAuth(endpoints.NewRemotesDiffHandler(gw.state)))
+
+		// Single sign-on is a browser flow with redirects, so it uses GET:
+		router.Handle("/api/v0/oidc/login", endpoints.NewOIDCLoginHandler(gw.state)).Methods("GET")
+		router.Handle("/api/v0/oidc/callback", endpoints.NewOIDCCallbackHandler(gw.state)).Methods("GET")
 	}
 
 	// Add the /get endpoint. Since it might contain any path, we have to
@@ -240,6 +276,9 @@ func (gw *Gateway) Start() {
 	// since it needs to be available if somebody is not using the UI.
 	router.PathPrefix("/get").Handler(endpoints.NewGetHandler(gw.state)).Methods("GET")
 
+	// Previews of images and PDFs. Same auth handling as /get.
+	router.PathPrefix("/thumb").Handler(endpoints.NewThumbHandler(gw.state, gw.thumbs)).Methods("GET")
+
 	if uiEnabled {
 		// /events is a websocket that pushes events to the client.
 		// The client will probably call /ls then.
@@ -306,6 +345,11 @@ func (gw *Gateway) Start() {
 	}()
 }
 
+// SetAuditLog sets the log where all actions of gateway users are recorded.
+func (gw *Gateway) SetAuditLog(lg *audit.Log) {
+	gw.state.SetAuditLog(lg)
+}
+
 // UserDatabase returns the user database API.
 func (gw *Gateway) UserDatabase() *db.UserDatabase {
 	return gw.state.UserDatabase()
@@ -184,3 +184,43 @@ func TestGatewayConfigChangePort(t *testing.T) {
 		require.Equal(t, exampleData, data)
 	})
 }
+
+func TestGatewayToken(t *testing.T) {
+	withBasicGateway(t, func(gw *Gateway, fs *catfs.FS) {
+		require.Nil(t, fs.Stage("/hello/world.png", bytes.NewReader([]byte("Hello world"))))
+
+		token, _, err := gw.UserDatabase().AddToken("ali", "test", nil, nil)
+		require.Nil(t, err)
+
+		doLs := func(token string) int {
+			req, err := http.NewRequest("POST", buildURL(gw, "/api/v0/ls"), strings.NewReader(`{"root": "/"}`))
+			require.Nil(t, err)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.Nil(t, err)
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+
+		// No CSRF token needed when using an API token:
+		require.Equal(t, http.StatusOK, doLs(token))
+		require.Equal(t, http.StatusUnauthorized, doLs("wrong.token"))
+
+		// Tokens work for /get too:
+		req, err := http.NewRequest("GET", buildURL(gw, "/get/hello/world.png"), nil)
+		require.Nil(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// Single sign-on is disabled by default:
+		resp, err = http.Get(buildURL(gw, "/api/v0/oidc/login"))
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
@@ -0,0 +1,167 @@
+package thumbs
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// generation is a thumbnail that is currently being generated.
+// Others asking for the same thumbnail wait for it.
+type generation struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// Cache stores generated thumbnails in a directory. Entries are keyed by
+// the content hash of a file, so they survive renames and moves. If the
+// directory grows bigger than the max. size, the least recently used
+// thumbnails are removed.
+type Cache struct {
+	mu      sync.Mutex
+	dir     string
+	maxSize int64
+	pending map[string]*generation
+}
+
+// NewCache returns a new cache in `dir` that may use up to `maxSize` bytes.
+func NewCache(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		pending: make(map[string]*generation),
+	}, nil
+}
+
+// SetMaxSize changes the max. size of the cache.
+// It is applied the next time a thumbnail is added.
+func (c *Cache) SetMaxSize(maxSize int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maxSize = maxSize
+}
+
+// Get returns the thumbnail of the file `path` with the content hash
+// `hash` in the box size `size`. If it is not cached yet, it is generated
+// from the stream returned by `open`. If no preview can be generated,
+// ErrNoPreview is returned; this is remembered too.
+func (c *Cache) Get(hash, path string, size int, open func() (io.ReadCloser, error)) ([]byte, error) {
+	key := fmt.Sprintf("%s-%d", hash, size)
+	entryPath := filepath.Join(c.dir, key)
+
+	c.mu.Lock()
+	if gen, ok := c.pending[key]; ok {
+		c.mu.Unlock()
+		<-gen.done
+		return gen.data, gen.err
+	}
+
+	if data, err := ioutil.ReadFile(entryPath); err == nil {
+		c.mu.Unlock()
+
+		// Mark the entry as recently used:
+		now := time.Now()
+		if err := os.Chtimes(entryPath, now, now); err != nil {
+			log.Debugf("thumbs: failed to touch %s: %v", entryPath, err)
+		}
+
+		if len(data) == 0 {
+			return nil, ErrNoPreview
+		}
+
+		return data, nil
+	}
+
+	gen := &generation{done: make(chan struct{})}
+	c.pending[key] = gen
+	c.mu.Unlock()
+
+	gen.data, gen.err = c.generate(entryPath, path, size, open)
+
+	c.mu.Lock()
+	delete(c.pending, key)
+	c.mu.Unlock()
+
+	close(gen.done)
+	return gen.data, gen.err
+}
+
+func (c *Cache) generate(entryPath, path string, size int, open func() (io.ReadCloser, error)) ([]byte, error) {
+	stream, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	defer stream.Close()
+
+	data, err := Generate(path, stream, size)
+	if err != nil && err != ErrNoPreview {
+		return nil, err
+	}
+
+	// An empty entry means that there is no preview.
+	if err := ioutil.WriteFile(entryPath, data, 0600); err != nil {
+		log.Warningf("thumbs: failed to cache thumbnail: %v", err)
+	}
+
+	if err := c.evict(); err != nil {
+		log.Warningf("thumbs: failed to clean up cache: %v", err)
+	}
+
+	if data == nil {
+		return nil, ErrNoPreview
+	}
+
+	return data, nil
+}
+
+// evict removes the least recently used entries until the cache is small enough.
+func (c *Cache) evict() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	infos, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	total := int64(0)
+	for _, info := range infos {
+		total += info.Size()
+	}
+
+	if total <= c.maxSize {
+		return nil
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+
+	for _, info := range infos {
+		if total <= c.maxSize {
+			break
+		}
+
+		if err := os.Remove(filepath.Join(c.dir, info.Name())); err != nil {
+			return err
+		}
+
+		total -= info.Size()
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package thumbs
+
+import (
+	"bytes"
+)
+
+var (
+	pdfMagic     = []byte("%PDF-")
+	pdfDCTFilter = []byte("/DCTDecode")
+	pdfStream    = []byte("stream")
+	pdfEndStream = []byte("endstream")
+	jpegMagic    = []byte{0xff, 0xd8}
+)
+
+// firstPDFImage returns the first JPEG image embedded in the PDF `data`.
+// Rendering a PDF page is out of scope, but scanned documents and most
+// photo books consist of a JPEG per page, so this gives a good preview.
+func firstPDFImage(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pdfMagic) {
+		return nil, ErrNoPreview
+	}
+
+	for {
+		idx := bytes.Index(data, pdfDCTFilter)
+		if idx < 0 {
+			return nil, ErrNoPreview
+		}
+
+		data = data[idx+len(pdfDCTFilter):]
+		start := bytes.Index(data, pdfStream)
+		if start < 0 {
+			return nil, ErrNoPreview
+		}
+
+		// The stream keyword is followed by CRLF or LF:
+		content := data[start+len(pdfStream):]
+		content = bytes.TrimPrefix(content, []byte("\r"))
+		content = bytes.TrimPrefix(content, []byte("\n"))
+
+		end := bytes.Index(content, pdfEndStream)
+		if end < 0 {
+			return nil, ErrNoPreview
+		}
+
+		// Images with other filters applied on top are skipped:
+		if bytes.HasPrefix(content, jpegMagic) {
+			return content[:end], nil
+		}
+
+		data = content[end:]
+	}
+}
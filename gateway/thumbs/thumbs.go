@@ -0,0 +1,174 @@
+// Package thumbs generates small previews of images and PDF documents
+// and keeps them in a size-bounded cache on disk.
+package thumbs
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	// Register the decoders for image.Decode().
+	_ "image/gif"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// DefaultSize is the default max. width and height of a thumbnail.
+	DefaultSize = 256
+
+	// MaxSize is the biggest thumbnail size we generate.
+	MaxSize = 1024
+
+	// maxInputSize limits how much data we read to generate a thumbnail.
+	maxInputSize = 64 * 1024 * 1024
+
+	// maxPixels limits the size of images we are willing to decode.
+	maxPixels = 64 * 1024 * 1024
+)
+
+var (
+	// ErrNoPreview is returned when no preview can be generated for a file.
+	ErrNoPreview = errors.New("no preview available")
+
+	imageExts = []string{".jpg", ".jpeg", ".png", ".gif", ".webp"}
+	pdfExts   = []string{".pdf"}
+)
+
+func hasExt(path string, exts []string) bool {
+	lowerPath := strings.ToLower(path)
+	for _, ext := range exts {
+		if strings.HasSuffix(lowerPath, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsSupported returns true if we might be able
+// to generate a preview for a file at `path`.
+func IsSupported(path string) bool {
+	return hasExt(path, imageExts) || hasExt(path, pdfExts)
+}
+
+// Generate reads the file `path` from `r` and returns a JPEG encoded
+// thumbnail that fits into a `size`x`size` box. For PDFs the first
+// embedded JPEG image is used, which works well for scanned documents.
+func Generate(path string, r io.Reader, size int) ([]byte, error) {
+	if !IsSupported(path) {
+		return nil, ErrNoPreview
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxInputSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if hasExt(path, pdfExts) {
+		if data, err = firstPDFImage(data); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrNoPreview
+	}
+
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrNoPreview
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrNoPreview
+	}
+
+	buf := &bytes.Buffer{}
+	thumb := scale(img, size)
+	if err := jpeg.Encode(buf, thumb, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// fit returns the dimensions of a `w`x`h` image fit into a `size` box.
+func fit(w, h, size int) (int, int) {
+	if w <= size && h <= size {
+		return w, h
+	}
+
+	if w > h {
+		h = h * size / w
+		w = size
+	} else {
+		w = w * size / h
+		h = size
+	}
+
+	if w < 1 {
+		w = 1
+	}
+
+	if h < 1 {
+		h = 1
+	}
+
+	return w, h
+}
+
+// scale shrinks `src` to fit into a `size` box by averaging all source
+// pixels that fall into a destination pixel. Transparent areas become white.
+func scale(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dstW, dstH := fit(srcW, srcH, size)
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for dy := 0; dy < dstH; dy++ {
+		y0 := bounds.Min.Y + dy*srcH/dstH
+		y1 := bounds.Min.Y + (dy+1)*srcH/dstH
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for dx := 0; dx < dstW; dx++ {
+			x0 := bounds.Min.X + dx*srcW/dstW
+			x1 := bounds.Min.X + (dx+1)*srcW/dstW
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, n uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					sr, sg, sb, sa := src.At(x, y).RGBA()
+
+					// Blend with a white background:
+					bg := uint64(0xffff - sa)
+					r += uint64(sr) + bg
+					g += uint64(sg) + bg
+					b += uint64(sb) + bg
+					n++
+				}
+			}
+
+			dst.SetRGBA(dx, dy, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: 0xff,
+			})
+		}
+	}
+
+	return dst
+}
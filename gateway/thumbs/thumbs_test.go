@@ -0,0 +1,163 @@
+package thumbs
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func dummyImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0x80, 0xff})
+		}
+	}
+
+	return img
+}
+
+func mustEncodePNG(t *testing.T, img image.Image) []byte {
+	buf := &bytes.Buffer{}
+	require.Nil(t, png.Encode(buf, img))
+	return buf.Bytes()
+}
+
+func mustEncodeJPEG(t *testing.T, img image.Image) []byte {
+	buf := &bytes.Buffer{}
+	require.Nil(t, jpeg.Encode(buf, img, nil))
+	return buf.Bytes()
+}
+
+func requireThumbSize(t *testing.T, data []byte, w, h int) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	require.Nil(t, err)
+	require.Equal(t, "jpeg", format)
+	require.Equal(t, w, cfg.Width)
+	require.Equal(t, h, cfg.Height)
+}
+
+func TestGeneratePNG(t *testing.T) {
+	data := mustEncodePNG(t, dummyImage(200, 100))
+
+	thumb, err := Generate("/photos/a.PNG", bytes.NewReader(data), 50)
+	require.Nil(t, err)
+	requireThumbSize(t, thumb, 50, 25)
+
+	// Small images are not scaled up:
+	thumb, err = Generate("/photos/a.png", bytes.NewReader(data), 500)
+	require.Nil(t, err)
+	requireThumbSize(t, thumb, 200, 100)
+}
+
+func TestGenerateWebP(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/blue-purple-pink.webp")
+	require.Nil(t, err)
+
+	thumb, err := Generate("/photos/a.webp", bytes.NewReader(data), 75)
+	require.Nil(t, err)
+	requireThumbSize(t, thumb, 75, 50)
+}
+
+func TestGenerateUnsupported(t *testing.T) {
+	_, err := Generate("/a.txt", bytes.NewReader([]byte("hello")), 50)
+	require.Equal(t, ErrNoPreview, err)
+
+	_, err = Generate("/a.png", bytes.NewReader([]byte("not a png")), 50)
+	require.Equal(t, ErrNoPreview, err)
+}
+
+func TestGeneratePDF(t *testing.T) {
+	img := mustEncodeJPEG(t, dummyImage(100, 200))
+
+	pdf := &bytes.Buffer{}
+	fmt.Fprintf(pdf, "%%PDF-1.4\n1 0 obj\n<< /Filter /FlateDecode >>\nstream\nxxx\nendstream\nendobj\n")
+	fmt.Fprintf(pdf, "2 0 obj\n<< /Type /XObject /Filter /DCTDecode /Length %d >>\nstream\r\n", len(img))
+	pdf.Write(img)
+	fmt.Fprintf(pdf, "\nendstream\nendobj\n%%%%EOF\n")
+
+	thumb, err := Generate("/scan.pdf", bytes.NewReader(pdf.Bytes()), 50)
+	require.Nil(t, err)
+	requireThumbSize(t, thumb, 25, 50)
+
+	_, err = Generate("/empty.pdf", bytes.NewReader([]byte("%PDF-1.4\n%%EOF\n")), 50)
+	require.Equal(t, ErrNoPreview, err)
+}
+
+func withCache(t *testing.T, maxSize int64, fn func(c *Cache, dir string)) {
+	dir, err := ioutil.TempDir("", "brig-thumbs-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	cache, err := NewCache(dir, maxSize)
+	require.Nil(t, err)
+	fn(cache, dir)
+}
+
+func TestCacheGet(t *testing.T) {
+	withCache(t, 1024*1024, func(c *Cache, dir string) {
+		data := mustEncodePNG(t, dummyImage(100, 100))
+		opened := 0
+		open := func() (io.ReadCloser, error) {
+			opened++
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		}
+
+		thumb1, err := c.Get("hash", "/a.png", 10, open)
+		require.Nil(t, err)
+		requireThumbSize(t, thumb1, 10, 10)
+
+		// Second time it should come from the cache,
+		// even if the file was renamed in the meantime:
+		thumb2, err := c.Get("hash", "/b.png", 10, open)
+		require.Nil(t, err)
+		require.Equal(t, thumb1, thumb2)
+		require.Equal(t, 1, opened)
+
+		// Failures are remembered:
+		_, err = c.Get("other", "/c.png", 10, func() (io.ReadCloser, error) {
+			opened++
+			return ioutil.NopCloser(bytes.NewReader([]byte("garbage"))), nil
+		})
+		require.Equal(t, ErrNoPreview, err)
+
+		_, err = c.Get("other", "/c.png", 10, open)
+		require.Equal(t, ErrNoPreview, err)
+		require.Equal(t, 2, opened)
+	})
+}
+
+func TestCacheEvict(t *testing.T) {
+	withCache(t, 1024*1024, func(c *Cache, dir string) {
+		data := mustEncodePNG(t, dummyImage(256, 256))
+		open := func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		}
+
+		for idx := 0; idx < 10; idx++ {
+			_, err := c.Get(fmt.Sprintf("hash%d", idx), "/a.png", 256, open)
+			require.Nil(t, err)
+		}
+
+		infos, err := ioutil.ReadDir(dir)
+		require.Nil(t, err)
+		require.Len(t, infos, 10)
+
+		c.SetMaxSize(1)
+		_, err = c.Get("last", "/a.png", 256, open)
+		require.Nil(t, err)
+
+		infos, err = ioutil.ReadDir(dir)
+		require.Nil(t, err)
+		require.Len(t, infos, 0)
+	})
+}
@@ -0,0 +1,138 @@
+package metrics
+
+var (
+	// StagedBytes counts the bytes of content that were staged.
+	StagedBytes = Default.NewCounter(
+		"brig_staged_bytes_total",
+		"Number of content bytes that were staged.",
+	)
+
+	// CatBytes counts the bytes that were read from files.
+	CatBytes = Default.NewCounter(
+		"brig_cat_bytes_total",
+		"Number of content bytes that were read from files.",
+	)
+
+	// BackendDuration measures how long the backend took for an operation.
+	BackendDuration = Default.NewHistogramVec(
+		"brig_backend_duration_seconds",
+		"Latency of backend operations.",
+		DefaultBuckets,
+		"op",
+	)
+
+	// BackendErrors counts failed backend operations.
+	BackendErrors = Default.NewCounterVec(
+		"brig_backend_errors_total",
+		"Number of backend operations that failed.",
+		"op",
+	)
+
+	// Pins counts how often content was pinned or unpinned.
+	Pins = Default.NewCounterVec(
+		"brig_pin_ops_total",
+		"Number of pin and unpin operations on the backend.",
+		"op",
+	)
+
+	// RepinPinnedBytes is the size of the pinned content after the last repin.
+	RepinPinnedBytes = Default.NewGauge(
+		"brig_repin_pinned_bytes",
+		"Bytes of pinned content after the last repin run.",
+	)
+
+	// RepinQuotaBytes is the quota that was used during the last repin.
+	RepinQuotaBytes = Default.NewGauge(
+		"brig_repin_quota_bytes",
+		"Configured repin quota in bytes.",
+	)
+
+	// RepinUnpinnedBytes counts bytes that were unpinned to stay in quota.
+	RepinUnpinnedBytes = Default.NewCounter(
+		"brig_repin_unpinned_bytes_total",
+		"Number of bytes unpinned by repin to stay below the quota.",
+	)
+
+	// GCRuns counts garbage collector runs.
+	GCRuns = Default.NewCounter(
+		"brig_gc_runs_total",
+		"Number of garbage collector runs.",
+	)
+
+	// GCFreedObjects counts objects removed by the garbage collector.
+	GCFreedObjects = Default.NewCounter(
+		"brig_gc_freed_objects_total",
+		"Number of objects removed by the garbage collector.",
+	)
+
+	// GCFreedBytes counts the content bytes freed by the garbage collector.
+	GCFreedBytes = Default.NewCounter(
+		"brig_gc_freed_bytes_total",
+		"Number of content bytes freed by the garbage collector.",
+	)
+
+	// SyncDuration measures how long a sync with a remote took.
+	SyncDuration = Default.NewHistogramVec(
+		"brig_sync_duration_seconds",
+		"Duration of syncs per remote.",
+		DefaultBuckets,
+		"remote",
+	)
+
+	// SyncErrors counts failed syncs per remote.
+	SyncErrors = Default.NewCounterVec(
+		"brig_sync_errors_total",
+		"Number of failed syncs per remote.",
+		"remote",
+	)
+
+	// SyncConflicts counts conflicts during syncs per remote.
+	SyncConflicts = Default.NewCounterVec(
+		"brig_sync_conflicts_total",
+		"Number of conflicts found while syncing per remote.",
+		"remote",
+	)
+
+	// PeerRoundtrip is the last measured roundtrip time to a peer.
+	PeerRoundtrip = Default.NewGaugeVec(
+		"brig_peer_roundtrip_seconds",
+		"Last measured roundtrip time to a remote.",
+		"remote",
+	)
+
+	// EventsPublished counts events sent to other peers.
+	EventsPublished = Default.NewCounterVec(
+		"brig_events_published_total",
+		"Number of events published to other peers.",
+		"type",
+	)
+
+	// EventsReceived counts events received from other peers.
+	EventsReceived = Default.NewCounterVec(
+		"brig_events_received_total",
+		"Number of events received from other peers.",
+		"type",
+	)
+
+	// EventsDropped counts received events that were not dispatched.
+	EventsDropped = Default.NewCounterVec(
+		"brig_events_dropped_total",
+		"Number of received events that were dropped.",
+		"type",
+	)
+
+	// GatewayRequests counts gateway requests by endpoint and status code.
+	GatewayRequests = Default.NewCounterVec(
+		"brig_gateway_requests_total",
+		"Number of gateway requests per endpoint and status code.",
+		"endpoint", "code",
+	)
+
+	// GatewayDuration measures the latency of gateway requests.
+	GatewayDuration = Default.NewHistogramVec(
+		"brig_gateway_request_duration_seconds",
+		"Latency of gateway requests per endpoint.",
+		DefaultBuckets,
+		"endpoint",
+	)
+)
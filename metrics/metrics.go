@@ -0,0 +1,361 @@
+// Package metrics collects numbers about what the daemon is doing and
+// exposes them in the text format understood by Prometheus (and other
+// OpenMetrics compatible tools). Only counters, gauges and histograms are
+// supported, since that is all brig needs.
+//
+// All metrics of brig are defined in brig.go, so that the packages
+// reporting them do not need to know about each other.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// DefaultBuckets are the upper bounds of the histogram buckets
+	// for latencies, in seconds.
+	DefaultBuckets = []float64{
+		0.001, 0.005, 0.01, 0.025, 0.05, 0.1,
+		0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300,
+	}
+)
+
+type metricType string
+
+const (
+	typeCounter   = metricType("counter")
+	typeGauge     = metricType("gauge")
+	typeHistogram = metricType("histogram")
+)
+
+// Registry holds a set of metrics that are written together.
+type Registry struct {
+	mu   sync.Mutex
+	vecs []*vec
+}
+
+// Default is the registry all metrics of brig are registered in.
+var Default = &Registry{}
+
+// vec is a metric with all values for its label combinations.
+type vec struct {
+	mu      sync.Mutex
+	name    string
+	help    string
+	typ     metricType
+	labels  []string
+	buckets []float64
+	values  map[string]*value
+}
+
+// value is the state of a single metric with fixed label values.
+type value struct {
+	mu          sync.Mutex
+	labelValues []string
+
+	// val is used by counters and gauges, sum by histograms.
+	val    float64
+	counts []uint64
+	count  uint64
+}
+
+func (r *Registry) register(name, help string, typ metricType, buckets []float64, labels []string) *vec {
+	v := &vec{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  labels,
+		buckets: buckets,
+		values:  make(map[string]*value),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, other := range r.vecs {
+		if other.name == name {
+			panic(fmt.Sprintf("metric registered twice: %s", name))
+		}
+	}
+
+	r.vecs = append(r.vecs, v)
+	return v
+}
+
+func (v *vec) with(labelValues []string) *value {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s needs %d labels", v.name, len(v.labels)))
+	}
+
+	key := strings.Join(labelValues, "\x00")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	val, ok := v.values[key]
+	if !ok {
+		val = &value{
+			labelValues: append([]string{}, labelValues...),
+			counts:      make([]uint64, len(v.buckets)),
+		}
+
+		v.values[key] = val
+	}
+
+	return val
+}
+
+/////////////////////////
+
+// Counter is a value that only goes up.
+type Counter struct {
+	val *value
+}
+
+// Add adds `delta` to the counter. Negative values are ignored.
+func (c Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+
+	c.val.mu.Lock()
+	c.val.val += delta
+	c.val.mu.Unlock()
+}
+
+// Inc adds one to the counter.
+func (c Counter) Inc() {
+	c.Add(1)
+}
+
+// CounterVec is a counter with labels.
+type CounterVec struct {
+	vec *vec
+}
+
+// With returns the counter for `labelValues`.
+func (cv *CounterVec) With(labelValues ...string) Counter {
+	return Counter{val: cv.vec.with(labelValues)}
+}
+
+// NewCounterVec registers a new counter with `labels` in `r`.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{vec: r.register(name, help, typeCounter, nil, labels)}
+}
+
+// NewCounter registers a new counter without labels in `r`.
+func (r *Registry) NewCounter(name, help string) Counter {
+	return r.NewCounterVec(name, help).With()
+}
+
+/////////////////////////
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	val *value
+}
+
+// Set sets the gauge to `val`.
+func (g Gauge) Set(val float64) {
+	g.val.mu.Lock()
+	g.val.val = val
+	g.val.mu.Unlock()
+}
+
+// Add adds `delta` (which may be negative) to the gauge.
+func (g Gauge) Add(delta float64) {
+	g.val.mu.Lock()
+	g.val.val += delta
+	g.val.mu.Unlock()
+}
+
+// GaugeVec is a gauge with labels.
+type GaugeVec struct {
+	vec *vec
+}
+
+// With returns the gauge for `labelValues`.
+func (gv *GaugeVec) With(labelValues ...string) Gauge {
+	return Gauge{val: gv.vec.with(labelValues)}
+}
+
+// NewGaugeVec registers a new gauge with `labels` in `r`.
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{vec: r.register(name, help, typeGauge, nil, labels)}
+}
+
+// NewGauge registers a new gauge without labels in `r`.
+func (r *Registry) NewGauge(name, help string) Gauge {
+	return r.NewGaugeVec(name, help).With()
+}
+
+/////////////////////////
+
+// Histogram counts observations (like latencies) in buckets.
+type Histogram struct {
+	val     *value
+	buckets []float64
+}
+
+// Observe adds a single observation.
+func (h Histogram) Observe(val float64) {
+	h.val.mu.Lock()
+	defer h.val.mu.Unlock()
+
+	for idx, bound := range h.buckets {
+		if val <= bound {
+			h.val.counts[idx]++
+		}
+	}
+
+	h.val.val += val
+	h.val.count++
+}
+
+// ObserveSince adds the time passed since `start` in seconds.
+func (h Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// HistogramVec is a histogram with labels.
+type HistogramVec struct {
+	vec *vec
+}
+
+// With returns the histogram for `labelValues`.
+func (hv *HistogramVec) With(labelValues ...string) Histogram {
+	return Histogram{
+		val:     hv.vec.with(labelValues),
+		buckets: hv.vec.buckets,
+	}
+}
+
+// NewHistogramVec registers a new histogram with `labels` in `r`.
+// `buckets` are the upper bounds of the buckets in ascending order.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{vec: r.register(name, help, typeHistogram, buckets, labels)}
+}
+
+// NewHistogram registers a new histogram without labels in `r`.
+func (r *Registry) NewHistogram(name, help string, buckets []float64) Histogram {
+	return r.NewHistogramVec(name, help, buckets).With()
+}
+
+/////////////////////////
+
+func formatFloat(val float64) string {
+	switch {
+	case math.IsInf(val, +1):
+		return "+Inf"
+	case math.IsInf(val, -1):
+		return "-Inf"
+	case math.IsNaN(val):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(val, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	pairs := []string{}
+	for idx, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, labelEscaper.Replace(values[idx])))
+	}
+
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extraName, extraValue))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (v *vec) write(w io.Writer) {
+	v.mu.Lock()
+	values := make([]*value, 0, len(v.values))
+	for _, val := range v.values {
+		values = append(values, val)
+	}
+	v.mu.Unlock()
+
+	// Metrics without labels should show up even if nothing happened yet:
+	if len(values) == 0 && len(v.labels) == 0 {
+		values = append(values, v.with(nil))
+	}
+
+	if len(values) == 0 {
+		return
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		return strings.Join(values[i].labelValues, "\x00") < strings.Join(values[j].labelValues, "\x00")
+	})
+
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, strings.Replace(v.help, "\n", " ", -1))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.typ)
+
+	for _, val := range values {
+		val.mu.Lock()
+		switch v.typ {
+		case typeCounter, typeGauge:
+			fmt.Fprintf(w, "%s%s %s\n", v.name, formatLabels(v.labels, val.labelValues, "", ""), formatFloat(val.val))
+		case typeHistogram:
+			for idx, bound := range v.buckets {
+				labels := formatLabels(v.labels, val.labelValues, "le", formatFloat(bound))
+				fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, labels, val.counts[idx])
+			}
+
+			labels := formatLabels(v.labels, val.labelValues, "le", "+Inf")
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, labels, val.count)
+
+			labels = formatLabels(v.labels, val.labelValues, "", "")
+			fmt.Fprintf(w, "%s_sum%s %s\n", v.name, labels, formatFloat(val.val))
+			fmt.Fprintf(w, "%s_count%s %d\n", v.name, labels, val.count)
+		}
+		val.mu.Unlock()
+	}
+}
+
+// Write writes all metrics in `r` to `w` in the Prometheus text format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	vecs := append([]*vec{}, r.vecs...)
+	r.mu.Unlock()
+
+	sort.Slice(vecs, func(i, j int) bool {
+		return vecs[i].name < vecs[j].name
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, v := range vecs {
+		v.write(bw)
+	}
+
+	return bw.Flush()
+}
+
+// Handler returns a http.Handler that serves the metrics in `r`.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := r.Write(w); err != nil {
+			log.Debugf("failed to write metrics: %v", err)
+		}
+	})
+}
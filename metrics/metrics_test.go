@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := &Registry{}
+
+	counter := r.NewCounterVec("test_ops_total", "Ops.", "op")
+	counter.With("add").Inc()
+	counter.With("add").Add(2)
+	counter.With("cat").Inc()
+	counter.With("cat").Add(-1)
+
+	gauge := r.NewGauge("test_size_bytes", "Size.")
+	gauge.Set(10)
+	gauge.Add(-2.5)
+
+	hist := r.NewHistogramVec("test_duration_seconds", "Duration.", []float64{0.1, 1}, "remote")
+	hist.With(`bob"s`).Observe(0.05)
+	hist.With(`bob"s`).Observe(0.5)
+	hist.With(`bob"s`).Observe(5)
+
+	// Labeled metrics that were never used do not show up:
+	r.NewCounterVec("test_unused_total", "Unused.", "op")
+
+	buf := &bytes.Buffer{}
+	require.Nil(t, r.Write(buf))
+	require.Equal(t, `# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{remote="bob\"s",le="0.1"} 1
+test_duration_seconds_bucket{remote="bob\"s",le="1"} 2
+test_duration_seconds_bucket{remote="bob\"s",le="+Inf"} 3
+test_duration_seconds_sum{remote="bob\"s"} 5.55
+test_duration_seconds_count{remote="bob\"s"} 3
+# HELP test_ops_total Ops.
+# TYPE test_ops_total counter
+test_ops_total{op="add"} 3
+test_ops_total{op="cat"} 1
+# HELP test_size_bytes Size.
+# TYPE test_size_bytes gauge
+test_size_bytes 7.5
+`, buf.String())
+}
+
+func TestRegisterTwice(t *testing.T) {
+	r := &Registry{}
+	r.NewCounter("test_total", "")
+	require.Panics(t, func() {
+		r.NewGauge("test_total", "")
+	})
+}
+
+func TestHandler(t *testing.T) {
+	r := &Registry{}
+	r.NewCounter("test_total", "Test.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	data, err := ioutil.ReadAll(rec.Body)
+	require.Nil(t, err)
+	require.Contains(t, rec.Header().Get("Content-Type"), "version=0.0.4")
+	require.Contains(t, string(data), "test_total 1\n")
+}
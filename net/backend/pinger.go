@@ -0,0 +1,181 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"io"
+	stdnet "net"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrWaiting is the initial error state of a pinger.
+	// The error will be unset once a successful ping was made.
+	ErrWaiting = errors.New("waiting for route")
+
+	// PingInterval is the time between two pings of a pinger
+	// created by NewPinger.
+	PingInterval = 10 * time.Second
+
+	// PingTimeout is the time a single ping may take.
+	PingTimeout = 5 * time.Second
+)
+
+type pinger struct {
+	lastSeen  time.Time
+	roundtrip time.Duration
+	err       error
+
+	mu     sync.Mutex
+	cancel func()
+	dial   func() (stdnet.Conn, error)
+
+	// fallback is the pinger of a wrapped backend, if any.
+	fallback Pinger
+}
+
+// NewPinger returns a Pinger that regularly opens a connection with `dial`
+// and waits for the other side to echo a single byte (see EchoPing).
+// If `fallback` is not nil, it is asked whenever our own pings fail.
+// This is meant for backends that wrap another backend.
+func NewPinger(dial func() (stdnet.Conn, error), fallback Pinger) Pinger {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &pinger{
+		dial:     dial,
+		err:      ErrWaiting,
+		cancel:   cancel,
+		fallback: fallback,
+	}
+
+	go p.run(ctx)
+	return p
+}
+
+// EchoPing answers a ping on `conn` that was sent by a pinger
+// created with NewPinger. The connection is closed afterwards.
+func EchoPing(conn stdnet.Conn) {
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(PingTimeout)); err != nil {
+		return
+	}
+
+	buf := make([]byte, 1)
+	if _, err := io.ReadFull(conn, buf); err == nil {
+		conn.Write(buf)
+	}
+}
+
+// LastSeen returns the time we pinged the remote last time.
+func (p *pinger) LastSeen() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.fallback != nil {
+		if fallbackSeen := p.fallback.LastSeen(); fallbackSeen.After(p.lastSeen) {
+			return fallbackSeen
+		}
+	}
+
+	return p.lastSeen
+}
+
+// Roundtrip returns the time needed send a single package to
+// the remote and receive the answer.
+func (p *pinger) Roundtrip() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil && p.fallback != nil {
+		return p.fallback.Roundtrip()
+	}
+
+	return p.roundtrip
+}
+
+// Err will return a non-nil error when the peer could not be reached
+// by us and (if used) the fallback.
+func (p *pinger) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil && p.fallback != nil {
+		return p.fallback.Err()
+	}
+
+	return p.err
+}
+
+// Close will clean up the pinger.
+func (p *pinger) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+
+	if p.fallback != nil {
+		return p.fallback.Close()
+	}
+
+	return nil
+}
+
+func (p *pinger) ping() (time.Duration, error) {
+	start := time.Now()
+	conn, err := p.dial()
+	if err != nil {
+		return 0, err
+	}
+
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(PingTimeout)); err != nil {
+		return 0, err
+	}
+
+	buf := []byte{42}
+	if _, err := conn.Write(buf); err != nil {
+		return 0, err
+	}
+
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return 0, err
+	}
+
+	return time.Since(start), nil
+}
+
+func (p *pinger) update() {
+	// Do the network op without a lock:
+	roundtrip, err := p.ping()
+
+	p.mu.Lock()
+	if err != nil {
+		p.err = err
+	} else {
+		p.err = nil
+		p.lastSeen = time.Now()
+		p.roundtrip = roundtrip
+	}
+
+	p.mu.Unlock()
+}
+
+func (p *pinger) run(ctx context.Context) {
+	p.update()
+	tckr := time.NewTicker(PingInterval)
+	defer tckr.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tckr.C:
+			p.update()
+		}
+	}
+}
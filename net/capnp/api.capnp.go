@@ -1043,6 +1043,735 @@ func (p Meta_ping_Results_Promise) Struct() (Meta_ping_Results, error) {
 	return Meta_ping_Results{s}, err
 }
 
+// A folder the invited remote may access
+type InviteFolder struct{ capnp.Struct }
+
+// InviteFolder_TypeID is the unique identifier for the type InviteFolder.
+const InviteFolder_TypeID = 0xf2bb3efdf3f10515
+
+func NewInviteFolder(s *capnp.Segment) (InviteFolder, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 1})
+	return InviteFolder{st}, err
+}
+
+func NewRootInviteFolder(s *capnp.Segment) (InviteFolder, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 1})
+	return InviteFolder{st}, err
+}
+
+func ReadRootInviteFolder(msg *capnp.Message) (InviteFolder, error) {
+	root, err := msg.RootPtr()
+	return InviteFolder{root.Struct()}, err
+}
+
+func (s InviteFolder) String() string {
+	str, _ := text.Marshal(0xf2bb3efdf3f10515, s.Struct)
+	return str
+}
+
+func (s InviteFolder) Folder() (string, error) {
+	p, err := s.Struct.Ptr(0)
+	return p.Text(), err
+}
+
+func (s InviteFolder) HasFolder() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s InviteFolder) FolderBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(0)
+	return p.TextBytes(), err
+}
+
+func (s InviteFolder) SetFolder(v string) error {
+	return s.Struct.SetText(0, v)
+}
+
+func (s InviteFolder) ReadOnly() bool {
+	return s.Struct.Bit(0)
+}
+
+func (s InviteFolder) SetReadOnly(v bool) {
+	s.Struct.SetBit(0, v)
+}
+
+// InviteFolder_List is a list of InviteFolder.
+type InviteFolder_List struct{ capnp.List }
+
+// NewInviteFolder creates a new list of InviteFolder.
+func NewInviteFolder_List(s *capnp.Segment, sz int32) (InviteFolder_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 8, PointerCount: 1}, sz)
+	return InviteFolder_List{l}, err
+}
+
+func (s InviteFolder_List) At(i int) InviteFolder { return InviteFolder{s.List.Struct(i)} }
+
+func (s InviteFolder_List) Set(i int, v InviteFolder) error { return s.List.SetStruct(i, v.Struct) }
+
+func (s InviteFolder_List) String() string {
+	str, _ := text.MarshalList(0xf2bb3efdf3f10515, s.List)
+	return str
+}
+
+// InviteFolder_Promise is a wrapper for a InviteFolder promised by a client call.
+type InviteFolder_Promise struct{ *capnp.Pipeline }
+
+func (p InviteFolder_Promise) Struct() (InviteFolder, error) {
+	s, err := p.Pipeline.Struct()
+	return InviteFolder{s}, err
+}
+
+type Pairing struct{ Client capnp.Client }
+
+// Pairing_TypeID is the unique identifier for the type Pairing.
+const Pairing_TypeID = 0xa250f01e86305506
+
+func (c Pairing) RedeemInvite(ctx context.Context, params func(Pairing_redeemInvite_Params) error, opts ...capnp.CallOption) Pairing_redeemInvite_Results_Promise {
+	if c.Client == nil {
+		return Pairing_redeemInvite_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0xa250f01e86305506,
+			MethodID:      0,
+			InterfaceName: "net/capnp/api.capnp:Pairing",
+			MethodName:    "redeemInvite",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 3}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Pairing_redeemInvite_Params{Struct: s}) }
+	}
+	return Pairing_redeemInvite_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
+
+type Pairing_Server interface {
+	RedeemInvite(Pairing_redeemInvite) error
+}
+
+func Pairing_ServerToClient(s Pairing_Server) Pairing {
+	c, _ := s.(server.Closer)
+	return Pairing{Client: server.New(Pairing_Methods(nil, s), c)}
+}
+
+func Pairing_Methods(methods []server.Method, s Pairing_Server) []server.Method {
+	if cap(methods) == 0 {
+		methods = make([]server.Method, 0, 1)
+	}
+
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0xa250f01e86305506,
+			MethodID:      0,
+			InterfaceName: "net/capnp/api.capnp:Pairing",
+			MethodName:    "redeemInvite",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Pairing_redeemInvite{c, opts, Pairing_redeemInvite_Params{Struct: p}, Pairing_redeemInvite_Results{Struct: r}}
+			return s.RedeemInvite(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
+	})
+
+	return methods
+}
+
+// Pairing_redeemInvite holds the arguments for a server call to Pairing.redeemInvite.
+type Pairing_redeemInvite struct {
+	Ctx     context.Context
+	Options capnp.CallOptions
+	Params  Pairing_redeemInvite_Params
+	Results Pairing_redeemInvite_Results
+}
+
+type Pairing_redeemInvite_Params struct{ capnp.Struct }
+
+// Pairing_redeemInvite_Params_TypeID is the unique identifier for the type Pairing_redeemInvite_Params.
+const Pairing_redeemInvite_Params_TypeID = 0xb18008ea6d8572b6
+
+func NewPairing_redeemInvite_Params(s *capnp.Segment) (Pairing_redeemInvite_Params, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 3})
+	return Pairing_redeemInvite_Params{st}, err
+}
+
+func NewRootPairing_redeemInvite_Params(s *capnp.Segment) (Pairing_redeemInvite_Params, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 3})
+	return Pairing_redeemInvite_Params{st}, err
+}
+
+func ReadRootPairing_redeemInvite_Params(msg *capnp.Message) (Pairing_redeemInvite_Params, error) {
+	root, err := msg.RootPtr()
+	return Pairing_redeemInvite_Params{root.Struct()}, err
+}
+
+func (s Pairing_redeemInvite_Params) String() string {
+	str, _ := text.Marshal(0xb18008ea6d8572b6, s.Struct)
+	return str
+}
+
+func (s Pairing_redeemInvite_Params) Token() (string, error) {
+	p, err := s.Struct.Ptr(0)
+	return p.Text(), err
+}
+
+func (s Pairing_redeemInvite_Params) HasToken() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s Pairing_redeemInvite_Params) TokenBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(0)
+	return p.TextBytes(), err
+}
+
+func (s Pairing_redeemInvite_Params) SetToken(v string) error {
+	return s.Struct.SetText(0, v)
+}
+
+func (s Pairing_redeemInvite_Params) Name() (string, error) {
+	p, err := s.Struct.Ptr(1)
+	return p.Text(), err
+}
+
+func (s Pairing_redeemInvite_Params) HasName() bool {
+	p, err := s.Struct.Ptr(1)
+	return p.IsValid() || err != nil
+}
+
+func (s Pairing_redeemInvite_Params) NameBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(1)
+	return p.TextBytes(), err
+}
+
+func (s Pairing_redeemInvite_Params) SetName(v string) error {
+	return s.Struct.SetText(1, v)
+}
+
+func (s Pairing_redeemInvite_Params) Fingerprint() (string, error) {
+	p, err := s.Struct.Ptr(2)
+	return p.Text(), err
+}
+
+func (s Pairing_redeemInvite_Params) HasFingerprint() bool {
+	p, err := s.Struct.Ptr(2)
+	return p.IsValid() || err != nil
+}
+
+func (s Pairing_redeemInvite_Params) FingerprintBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(2)
+	return p.TextBytes(), err
+}
+
+func (s Pairing_redeemInvite_Params) SetFingerprint(v string) error {
+	return s.Struct.SetText(2, v)
+}
+
+// Pairing_redeemInvite_Params_List is a list of Pairing_redeemInvite_Params.
+type Pairing_redeemInvite_Params_List struct{ capnp.List }
+
+// NewPairing_redeemInvite_Params creates a new list of Pairing_redeemInvite_Params.
+func NewPairing_redeemInvite_Params_List(s *capnp.Segment, sz int32) (Pairing_redeemInvite_Params_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 3}, sz)
+	return Pairing_redeemInvite_Params_List{l}, err
+}
+
+func (s Pairing_redeemInvite_Params_List) At(i int) Pairing_redeemInvite_Params {
+	return Pairing_redeemInvite_Params{s.List.Struct(i)}
+}
+
+func (s Pairing_redeemInvite_Params_List) Set(i int, v Pairing_redeemInvite_Params) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s Pairing_redeemInvite_Params_List) String() string {
+	str, _ := text.MarshalList(0xb18008ea6d8572b6, s.List)
+	return str
+}
+
+// Pairing_redeemInvite_Params_Promise is a wrapper for a Pairing_redeemInvite_Params promised by a client call.
+type Pairing_redeemInvite_Params_Promise struct{ *capnp.Pipeline }
+
+func (p Pairing_redeemInvite_Params_Promise) Struct() (Pairing_redeemInvite_Params, error) {
+	s, err := p.Pipeline.Struct()
+	return Pairing_redeemInvite_Params{s}, err
+}
+
+type Pairing_redeemInvite_Results struct{ capnp.Struct }
+
+// Pairing_redeemInvite_Results_TypeID is the unique identifier for the type Pairing_redeemInvite_Results.
+const Pairing_redeemInvite_Results_TypeID = 0xfa8fa93d371eae0e
+
+func NewPairing_redeemInvite_Results(s *capnp.Segment) (Pairing_redeemInvite_Results, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Pairing_redeemInvite_Results{st}, err
+}
+
+func NewRootPairing_redeemInvite_Results(s *capnp.Segment) (Pairing_redeemInvite_Results, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Pairing_redeemInvite_Results{st}, err
+}
+
+func ReadRootPairing_redeemInvite_Results(msg *capnp.Message) (Pairing_redeemInvite_Results, error) {
+	root, err := msg.RootPtr()
+	return Pairing_redeemInvite_Results{root.Struct()}, err
+}
+
+func (s Pairing_redeemInvite_Results) String() string {
+	str, _ := text.Marshal(0xfa8fa93d371eae0e, s.Struct)
+	return str
+}
+
+func (s Pairing_redeemInvite_Results) Folders() (InviteFolder_List, error) {
+	p, err := s.Struct.Ptr(0)
+	return InviteFolder_List{List: p.List()}, err
+}
+
+func (s Pairing_redeemInvite_Results) HasFolders() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s Pairing_redeemInvite_Results) SetFolders(v InviteFolder_List) error {
+	return s.Struct.SetPtr(0, v.List.ToPtr())
+}
+
+// NewFolders sets the folders field to a newly
+// allocated InviteFolder_List, preferring placement in s's segment.
+func (s Pairing_redeemInvite_Results) NewFolders(n int32) (InviteFolder_List, error) {
+	l, err := NewInviteFolder_List(s.Struct.Segment(), n)
+	if err != nil {
+		return InviteFolder_List{}, err
+	}
+	err = s.Struct.SetPtr(0, l.List.ToPtr())
+	return l, err
+}
+
+// Pairing_redeemInvite_Results_List is a list of Pairing_redeemInvite_Results.
+type Pairing_redeemInvite_Results_List struct{ capnp.List }
+
+// NewPairing_redeemInvite_Results creates a new list of Pairing_redeemInvite_Results.
+func NewPairing_redeemInvite_Results_List(s *capnp.Segment, sz int32) (Pairing_redeemInvite_Results_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1}, sz)
+	return Pairing_redeemInvite_Results_List{l}, err
+}
+
+func (s Pairing_redeemInvite_Results_List) At(i int) Pairing_redeemInvite_Results {
+	return Pairing_redeemInvite_Results{s.List.Struct(i)}
+}
+
+func (s Pairing_redeemInvite_Results_List) Set(i int, v Pairing_redeemInvite_Results) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s Pairing_redeemInvite_Results_List) String() string {
+	str, _ := text.MarshalList(0xfa8fa93d371eae0e, s.List)
+	return str
+}
+
+// Pairing_redeemInvite_Results_Promise is a wrapper for a Pairing_redeemInvite_Results promised by a client call.
+type Pairing_redeemInvite_Results_Promise struct{ *capnp.Pipeline }
+
+func (p Pairing_redeemInvite_Results_Promise) Struct() (Pairing_redeemInvite_Results, error) {
+	s, err := p.Pipeline.Struct()
+	return Pairing_redeemInvite_Results{s}, err
+}
+
+type Replication struct{ Client capnp.Client }
+
+// Replication_TypeID is the unique identifier for the type Replication.
+const Replication_TypeID = 0x8face4f23bb814d5
+
+func (c Replication) PinSummary(ctx context.Context, params func(Replication_pinSummary_Params) error, opts ...capnp.CallOption) Replication_pinSummary_Results_Promise {
+	if c.Client == nil {
+		return Replication_pinSummary_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0x8face4f23bb814d5,
+			MethodID:      0,
+			InterfaceName: "net/capnp/api.capnp:Replication",
+			MethodName:    "pinSummary",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 0}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Replication_pinSummary_Params{Struct: s}) }
+	}
+	return Replication_pinSummary_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
+func (c Replication) RequestPins(ctx context.Context, params func(Replication_requestPins_Params) error, opts ...capnp.CallOption) Replication_requestPins_Results_Promise {
+	if c.Client == nil {
+		return Replication_requestPins_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0x8face4f23bb814d5,
+			MethodID:      1,
+			InterfaceName: "net/capnp/api.capnp:Replication",
+			MethodName:    "requestPins",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 1}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Replication_requestPins_Params{Struct: s}) }
+	}
+	return Replication_requestPins_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
+
+type Replication_Server interface {
+	PinSummary(Replication_pinSummary) error
+
+	RequestPins(Replication_requestPins) error
+}
+
+func Replication_ServerToClient(s Replication_Server) Replication {
+	c, _ := s.(server.Closer)
+	return Replication{Client: server.New(Replication_Methods(nil, s), c)}
+}
+
+func Replication_Methods(methods []server.Method, s Replication_Server) []server.Method {
+	if cap(methods) == 0 {
+		methods = make([]server.Method, 0, 2)
+	}
+
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0x8face4f23bb814d5,
+			MethodID:      0,
+			InterfaceName: "net/capnp/api.capnp:Replication",
+			MethodName:    "pinSummary",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Replication_pinSummary{c, opts, Replication_pinSummary_Params{Struct: p}, Replication_pinSummary_Results{Struct: r}}
+			return s.PinSummary(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
+	})
+
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0x8face4f23bb814d5,
+			MethodID:      1,
+			InterfaceName: "net/capnp/api.capnp:Replication",
+			MethodName:    "requestPins",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Replication_requestPins{c, opts, Replication_requestPins_Params{Struct: p}, Replication_requestPins_Results{Struct: r}}
+			return s.RequestPins(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
+	})
+
+	return methods
+}
+
+// Replication_pinSummary holds the arguments for a server call to Replication.pinSummary.
+type Replication_pinSummary struct {
+	Ctx     context.Context
+	Options capnp.CallOptions
+	Params  Replication_pinSummary_Params
+	Results Replication_pinSummary_Results
+}
+
+// Replication_requestPins holds the arguments for a server call to Replication.requestPins.
+type Replication_requestPins struct {
+	Ctx     context.Context
+	Options capnp.CallOptions
+	Params  Replication_requestPins_Params
+	Results Replication_requestPins_Results
+}
+
+type Replication_pinSummary_Params struct{ capnp.Struct }
+
+// Replication_pinSummary_Params_TypeID is the unique identifier for the type Replication_pinSummary_Params.
+const Replication_pinSummary_Params_TypeID = 0x8ebb0ac6da8cb0f5
+
+func NewReplication_pinSummary_Params(s *capnp.Segment) (Replication_pinSummary_Params, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 0})
+	return Replication_pinSummary_Params{st}, err
+}
+
+func NewRootReplication_pinSummary_Params(s *capnp.Segment) (Replication_pinSummary_Params, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 0})
+	return Replication_pinSummary_Params{st}, err
+}
+
+func ReadRootReplication_pinSummary_Params(msg *capnp.Message) (Replication_pinSummary_Params, error) {
+	root, err := msg.RootPtr()
+	return Replication_pinSummary_Params{root.Struct()}, err
+}
+
+func (s Replication_pinSummary_Params) String() string {
+	str, _ := text.Marshal(0x8ebb0ac6da8cb0f5, s.Struct)
+	return str
+}
+
+// Replication_pinSummary_Params_List is a list of Replication_pinSummary_Params.
+type Replication_pinSummary_Params_List struct{ capnp.List }
+
+// NewReplication_pinSummary_Params creates a new list of Replication_pinSummary_Params.
+func NewReplication_pinSummary_Params_List(s *capnp.Segment, sz int32) (Replication_pinSummary_Params_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 0}, sz)
+	return Replication_pinSummary_Params_List{l}, err
+}
+
+func (s Replication_pinSummary_Params_List) At(i int) Replication_pinSummary_Params {
+	return Replication_pinSummary_Params{s.List.Struct(i)}
+}
+
+func (s Replication_pinSummary_Params_List) Set(i int, v Replication_pinSummary_Params) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s Replication_pinSummary_Params_List) String() string {
+	str, _ := text.MarshalList(0x8ebb0ac6da8cb0f5, s.List)
+	return str
+}
+
+// Replication_pinSummary_Params_Promise is a wrapper for a Replication_pinSummary_Params promised by a client call.
+type Replication_pinSummary_Params_Promise struct{ *capnp.Pipeline }
+
+func (p Replication_pinSummary_Params_Promise) Struct() (Replication_pinSummary_Params, error) {
+	s, err := p.Pipeline.Struct()
+	return Replication_pinSummary_Params{s}, err
+}
+
+type Replication_pinSummary_Results struct{ capnp.Struct }
+
+// Replication_pinSummary_Results_TypeID is the unique identifier for the type Replication_pinSummary_Results.
+const Replication_pinSummary_Results_TypeID = 0xd79e43e87f3cc13a
+
+func NewReplication_pinSummary_Results(s *capnp.Segment) (Replication_pinSummary_Results, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Replication_pinSummary_Results{st}, err
+}
+
+func NewRootReplication_pinSummary_Results(s *capnp.Segment) (Replication_pinSummary_Results, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Replication_pinSummary_Results{st}, err
+}
+
+func ReadRootReplication_pinSummary_Results(msg *capnp.Message) (Replication_pinSummary_Results, error) {
+	root, err := msg.RootPtr()
+	return Replication_pinSummary_Results{root.Struct()}, err
+}
+
+func (s Replication_pinSummary_Results) String() string {
+	str, _ := text.Marshal(0xd79e43e87f3cc13a, s.Struct)
+	return str
+}
+
+func (s Replication_pinSummary_Results) Filter() ([]byte, error) {
+	p, err := s.Struct.Ptr(0)
+	return []byte(p.Data()), err
+}
+
+func (s Replication_pinSummary_Results) HasFilter() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s Replication_pinSummary_Results) SetFilter(v []byte) error {
+	return s.Struct.SetData(0, v)
+}
+
+// Replication_pinSummary_Results_List is a list of Replication_pinSummary_Results.
+type Replication_pinSummary_Results_List struct{ capnp.List }
+
+// NewReplication_pinSummary_Results creates a new list of Replication_pinSummary_Results.
+func NewReplication_pinSummary_Results_List(s *capnp.Segment, sz int32) (Replication_pinSummary_Results_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1}, sz)
+	return Replication_pinSummary_Results_List{l}, err
+}
+
+func (s Replication_pinSummary_Results_List) At(i int) Replication_pinSummary_Results {
+	return Replication_pinSummary_Results{s.List.Struct(i)}
+}
+
+func (s Replication_pinSummary_Results_List) Set(i int, v Replication_pinSummary_Results) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s Replication_pinSummary_Results_List) String() string {
+	str, _ := text.MarshalList(0xd79e43e87f3cc13a, s.List)
+	return str
+}
+
+// Replication_pinSummary_Results_Promise is a wrapper for a Replication_pinSummary_Results promised by a client call.
+type Replication_pinSummary_Results_Promise struct{ *capnp.Pipeline }
+
+func (p Replication_pinSummary_Results_Promise) Struct() (Replication_pinSummary_Results, error) {
+	s, err := p.Pipeline.Struct()
+	return Replication_pinSummary_Results{s}, err
+}
+
+type Replication_requestPins_Params struct{ capnp.Struct }
+
+// Replication_requestPins_Params_TypeID is the unique identifier for the type Replication_requestPins_Params.
+const Replication_requestPins_Params_TypeID = 0x8d5423aa7556a18c
+
+func NewReplication_requestPins_Params(s *capnp.Segment) (Replication_requestPins_Params, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Replication_requestPins_Params{st}, err
+}
+
+func NewRootReplication_requestPins_Params(s *capnp.Segment) (Replication_requestPins_Params, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Replication_requestPins_Params{st}, err
+}
+
+func ReadRootReplication_requestPins_Params(msg *capnp.Message) (Replication_requestPins_Params, error) {
+	root, err := msg.RootPtr()
+	return Replication_requestPins_Params{root.Struct()}, err
+}
+
+func (s Replication_requestPins_Params) String() string {
+	str, _ := text.Marshal(0x8d5423aa7556a18c, s.Struct)
+	return str
+}
+
+func (s Replication_requestPins_Params) Hashes() (capnp.DataList, error) {
+	p, err := s.Struct.Ptr(0)
+	return capnp.DataList{List: p.List()}, err
+}
+
+func (s Replication_requestPins_Params) HasHashes() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s Replication_requestPins_Params) SetHashes(v capnp.DataList) error {
+	return s.Struct.SetPtr(0, v.List.ToPtr())
+}
+
+// NewHashes sets the hashes field to a newly
+// allocated capnp.DataList, preferring placement in s's segment.
+func (s Replication_requestPins_Params) NewHashes(n int32) (capnp.DataList, error) {
+	l, err := capnp.NewDataList(s.Struct.Segment(), n)
+	if err != nil {
+		return capnp.DataList{}, err
+	}
+	err = s.Struct.SetPtr(0, l.List.ToPtr())
+	return l, err
+}
+
+// Replication_requestPins_Params_List is a list of Replication_requestPins_Params.
+type Replication_requestPins_Params_List struct{ capnp.List }
+
+// NewReplication_requestPins_Params creates a new list of Replication_requestPins_Params.
+func NewReplication_requestPins_Params_List(s *capnp.Segment, sz int32) (Replication_requestPins_Params_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1}, sz)
+	return Replication_requestPins_Params_List{l}, err
+}
+
+func (s Replication_requestPins_Params_List) At(i int) Replication_requestPins_Params {
+	return Replication_requestPins_Params{s.List.Struct(i)}
+}
+
+func (s Replication_requestPins_Params_List) Set(i int, v Replication_requestPins_Params) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s Replication_requestPins_Params_List) String() string {
+	str, _ := text.MarshalList(0x8d5423aa7556a18c, s.List)
+	return str
+}
+
+// Replication_requestPins_Params_Promise is a wrapper for a Replication_requestPins_Params promised by a client call.
+type Replication_requestPins_Params_Promise struct{ *capnp.Pipeline }
+
+func (p Replication_requestPins_Params_Promise) Struct() (Replication_requestPins_Params, error) {
+	s, err := p.Pipeline.Struct()
+	return Replication_requestPins_Params{s}, err
+}
+
+type Replication_requestPins_Results struct{ capnp.Struct }
+
+// Replication_requestPins_Results_TypeID is the unique identifier for the type Replication_requestPins_Results.
+const Replication_requestPins_Results_TypeID = 0xe9fecd0f9000183c
+
+func NewReplication_requestPins_Results(s *capnp.Segment) (Replication_requestPins_Results, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Replication_requestPins_Results{st}, err
+}
+
+func NewRootReplication_requestPins_Results(s *capnp.Segment) (Replication_requestPins_Results, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	return Replication_requestPins_Results{st}, err
+}
+
+func ReadRootReplication_requestPins_Results(msg *capnp.Message) (Replication_requestPins_Results, error) {
+	root, err := msg.RootPtr()
+	return Replication_requestPins_Results{root.Struct()}, err
+}
+
+func (s Replication_requestPins_Results) String() string {
+	str, _ := text.Marshal(0xe9fecd0f9000183c, s.Struct)
+	return str
+}
+
+func (s Replication_requestPins_Results) Pinned() (capnp.DataList, error) {
+	p, err := s.Struct.Ptr(0)
+	return capnp.DataList{List: p.List()}, err
+}
+
+func (s Replication_requestPins_Results) HasPinned() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s Replication_requestPins_Results) SetPinned(v capnp.DataList) error {
+	return s.Struct.SetPtr(0, v.List.ToPtr())
+}
+
+// NewPinned sets the pinned field to a newly
+// allocated capnp.DataList, preferring placement in s's segment.
+func (s Replication_requestPins_Results) NewPinned(n int32) (capnp.DataList, error) {
+	l, err := capnp.NewDataList(s.Struct.Segment(), n)
+	if err != nil {
+		return capnp.DataList{}, err
+	}
+	err = s.Struct.SetPtr(0, l.List.ToPtr())
+	return l, err
+}
+
+// Replication_requestPins_Results_List is a list of Replication_requestPins_Results.
+type Replication_requestPins_Results_List struct{ capnp.List }
+
+// NewReplication_requestPins_Results creates a new list of Replication_requestPins_Results.
+func NewReplication_requestPins_Results_List(s *capnp.Segment, sz int32) (Replication_requestPins_Results_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1}, sz)
+	return Replication_requestPins_Results_List{l}, err
+}
+
+func (s Replication_requestPins_Results_List) At(i int) Replication_requestPins_Results {
+	return Replication_requestPins_Results{s.List.Struct(i)}
+}
+
+func (s Replication_requestPins_Results_List) Set(i int, v Replication_requestPins_Results) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s Replication_requestPins_Results_List) String() string {
+	str, _ := text.MarshalList(0xe9fecd0f9000183c, s.List)
+	return str
+}
+
+// Replication_requestPins_Results_Promise is a wrapper for a Replication_requestPins_Results promised by a client call.
+type Replication_requestPins_Results_Promise struct{ *capnp.Pipeline }
+
+func (p Replication_requestPins_Results_Promise) Struct() (Replication_requestPins_Results, error) {
+	s, err := p.Pipeline.Struct()
+	return Replication_requestPins_Results{s}, err
+}
+
 type API struct{ Client capnp.Client }
 
 // API_TypeID is the unique identifier for the type API.
@@ -1188,6 +1917,66 @@ func (c API) Ping(ctx context.Context, params func(Meta_ping_Params) error, opts
 	}
 	return Meta_ping_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
 }
+func (c API) RedeemInvite(ctx context.Context, params func(Pairing_redeemInvite_Params) error, opts ...capnp.CallOption) Pairing_redeemInvite_Results_Promise {
+	if c.Client == nil {
+		return Pairing_redeemInvite_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0xa250f01e86305506,
+			MethodID:      0,
+			InterfaceName: "net/capnp/api.capnp:Pairing",
+			MethodName:    "redeemInvite",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 3}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Pairing_redeemInvite_Params{Struct: s}) }
+	}
+	return Pairing_redeemInvite_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
+func (c API) PinSummary(ctx context.Context, params func(Replication_pinSummary_Params) error, opts ...capnp.CallOption) Replication_pinSummary_Results_Promise {
+	if c.Client == nil {
+		return Replication_pinSummary_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0x8face4f23bb814d5,
+			MethodID:      0,
+			InterfaceName: "net/capnp/api.capnp:Replication",
+			MethodName:    "pinSummary",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 0}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Replication_pinSummary_Params{Struct: s}) }
+	}
+	return Replication_pinSummary_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
+func (c API) RequestPins(ctx context.Context, params func(Replication_requestPins_Params) error, opts ...capnp.CallOption) Replication_requestPins_Results_Promise {
+	if c.Client == nil {
+		return Replication_requestPins_Results_Promise{Pipeline: capnp.NewPipeline(capnp.ErrorAnswer(capnp.ErrNullClient))}
+	}
+	call := &capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID:   0x8face4f23bb814d5,
+			MethodID:      1,
+			InterfaceName: "net/capnp/api.capnp:Replication",
+			MethodName:    "requestPins",
+		},
+		Options: capnp.NewCallOptions(opts),
+	}
+	if params != nil {
+		call.ParamsSize = capnp.ObjectSize{DataSize: 0, PointerCount: 1}
+		call.ParamsFunc = func(s capnp.Struct) error { return params(Replication_requestPins_Params{Struct: s}) }
+	}
+	return Replication_requestPins_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
+}
 
 type API_Server interface {
 	Version(API_version) error
@@ -1203,6 +1992,12 @@ type API_Server interface {
 	Push(Sync_push) error
 
 	Ping(Meta_ping) error
+
+	RedeemInvite(Pairing_redeemInvite) error
+
+	PinSummary(Replication_pinSummary) error
+
+	RequestPins(Replication_requestPins) error
 }
 
 func API_ServerToClient(s API_Server) API {
@@ -1212,7 +2007,7 @@ func API_ServerToClient(s API_Server) API {
 
 func API_Methods(methods []server.Method, s API_Server) []server.Method {
 	if cap(methods) == 0 {
-		methods = make([]server.Method, 0, 7)
+		methods = make([]server.Method, 0, 10)
 	}
 
 	methods = append(methods, server.Method{
@@ -1313,6 +2108,48 @@ func API_Methods(methods []server.Method, s API_Server) []server.Method {
 		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
 	})
 
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0xa250f01e86305506,
+			MethodID:      0,
+			InterfaceName: "net/capnp/api.capnp:Pairing",
+			MethodName:    "redeemInvite",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Pairing_redeemInvite{c, opts, Pairing_redeemInvite_Params{Struct: p}, Pairing_redeemInvite_Results{Struct: r}}
+			return s.RedeemInvite(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
+	})
+
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0x8face4f23bb814d5,
+			MethodID:      0,
+			InterfaceName: "net/capnp/api.capnp:Replication",
+			MethodName:    "pinSummary",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Replication_pinSummary{c, opts, Replication_pinSummary_Params{Struct: p}, Replication_pinSummary_Results{Struct: r}}
+			return s.PinSummary(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
+	})
+
+	methods = append(methods, server.Method{
+		Method: capnp.Method{
+			InterfaceID:   0x8face4f23bb814d5,
+			MethodID:      1,
+			InterfaceName: "net/capnp/api.capnp:Replication",
+			MethodName:    "requestPins",
+		},
+		Impl: func(c context.Context, opts capnp.CallOptions, p, r capnp.Struct) error {
+			call := Replication_requestPins{c, opts, Replication_requestPins_Params{Struct: p}, Replication_requestPins_Results{Struct: r}}
+			return s.RequestPins(call)
+		},
+		ResultsSize: capnp.ObjectSize{DataSize: 0, PointerCount: 1},
+	})
+
 	return methods
 }
 
@@ -1442,79 +2279,126 @@ func (p API_version_Results_Promise) Struct() (API_version_Results, error) {
 	return API_version_Results{s}, err
 }
 
-const schema_9bcb07fb35756ee6 = "x\xda\xacU]h\x1cU\x14>\xe7\xde;\x9d\x82\x89" +
-	"\xcbe\"d\x85\x9a>\xac-D\xdc\xa4\xd1\"\xe9\x83" +
-	"\xbb\x1bm\xe3>Df\xb6\xe2O\x9e\x1cwo\xbb\x83" +
-	"\xbb\xb3\xd3\x99Ym\x84 V\x0aU\xd2\xe2\x1f>\xb4" +
-	"U\xfc\xc1\x87V\x04)\x88\xd0\xc7\x8a\x04\"\xfe\xbc\xf8" +
-	" *X\x8a? \x08\x0a\xc1@\xd8\x8e\xdc\x99\x9d\xc9" +
-	"t7\xab\x8b\xf4\xed\xb2\xdf\xb9\xdf~\xe7\x9c\xef~3" +
-	"\xcdI\x91\xecS\x0a*\x80\xf1\x88\xb2#\xd8{\xedd" +
-	"\xe5j\xe7\x95\xb3\xc0\xb3\x08\xa0\xa0\x0ap\xcfI:\x83" +
-	"\x80\xdaiZ\x00\x0c^\xff`3\xfb\xc9\xcb\xe7\xde\x8b" +
-	"\x0a\x98\xc4?\xa2\x97\x11X\xf0\xd0\xc6\x89\x95\xbfN\xec" +
-	"\xbb\x08F\x16c\xe8-\xfa\x9c\xbcz!\xbc\x1a\xac\xae" +
-	"<\xf6\xce]w\x7f\x0c|\x8c\x06\xbf\xd8\xed\xfd\x9b\xea" +
-	"\x17\xe7\x00P\xfb\x92\xaei\xdfQ\x15@\xfb\x96\xcek" +
-	"\x1dy\x0a\xd6?{\xf2\xcc\x197s)\xcd\xf63]" +
-	"\x94l\x7f\x86l\x9d\xeb\xafM\xe9\x8f\x97?\xedc\xbb" +
-	"\x8d]\xd1v\xc9z-\xcb\xe6\xb5\x12\xdb\x0b\x10,\xe5" +
-	"\xd6o=KN\xad\xa6\xdb\x9aeOI\xb6\x83L\xb2" +
-	"\xbd\xb9\xe7\xefK\xbbw_\xfc*\xd5\x96`3\xb2-" +
-	"\xfeF\xf9\xe8\xc3\xac\xfaC\x0aY`\x8b\x12yq\xcf" +
-	"\xa9;n\xcf\xfc\x91Ff\x99+\x91\x95\xdc\x9a}\xa8" +
-	"s\xe1j\x0a\xb9\x93MJ\xe4~\xfe _\xfe\xe9\xdd" +
-	"_\xd3m\x8d\xb2+R\xc8\xaeP\xc8-\xf7\xbd\xff\xfd" +
-	"\xb5\xec\x8f\xbf\x831\x9e\x14\x94\xd8\x9c,(\x87\x05\xee" +
-	"\xf1\xaf?W'\xad\xf5\xbe\xbe-\xb6\xa6\xb5\xc3\xfac" +
-	"l\x1e\xb5\x05E\x05\xe8\x9c\xffm\xfa\xed\xe2\xbd\x1b\xa9" +
-	"\xb6\xf7+a\xdb%E\x92\xad.?\xfd\xc2\xa3\xe6\xf5" +
-	"\x8d\x94PS\x09\x85\xb2\xfa\xb1oNW>\xdc\x04>" +
-	"\x1e#e\xe5\x00\xc2t`\x0b\x7f\xaaj:6u\xa6" +
-	"L\xc7\xca\xcb\xa3s`A\xf8f\xde\xb1\xec\xa3\xb9\x8a" +
-	"\x98\xf0\xda\x0d\xdf3\x18e\x00\x0c\x01\xf8\xe8\x0c\x80\xb1" +
-	"\x93\xa21Fp\xc2\x15Nc\x09G\x80\xe0\x08`B" +
-	"\xa6\xa4\xc9\x0e/\xd9\xd5\xbc\xe5=\xd0j:\x0d\xe1\x8b" +
-	"C\xc2\xaf\xd6K\x8dF\xebYQ\xcb\x15t\xd35\x9b" +
-	"^r\x91msQo{I}\xa5 \xfa\xe4T\x00" +
-	"\x8c\x11\x8a\xc68\xc1\xc0\xf2\xa2J\xc0\x1a\"\x10\xc4\x94" +
-	"(\xd2\xdb!\x80\x8eh0\xaa\x00$;\xc6\xf8\xc9p" +
-	">\x09\x84+jF\x8e\xa1\x88:\xe2\xbf\x88<\"{" +
-	"\xd2M\xbfZ\xcf\xc9\x86hs\xa0\xc0#n\xabY\xb6" +
-	"k\x02\xf08*@P\x19$\xb0\xa4\x97S\xf2\xe2\xfd" +
-	"al(\xce\xe7By\xcf?#\\\xcfj\xd9E4" +
-	"vb\xcaN\x00[\x0f\x14`8\xe9\x15\xe1\xb5\xd5\x9e" +
-	"\xe1Nn\xed:S3}\x13G\x81\xe0hJ4\xed" +
-	"ct\xda^=\xf1\xcd\x7f\xfd\xf3a\xbf\xe5\x8axh" +
-	"C\xdb@\x9f\xb8\xd16\x03\xcc\xab\x9b\x99\x1b\xcav\x0c" +
-	"k\xcbJ\xe42\xf8?6c=[\xccw7\xb4-" +
-	"\xe9\xdc\xd6x\xe3M\"\x03\x82l\x90-\xa4\xea\xc8\xb7" +
-	"c\xa11\xe2<\xc3\xf3\xd0\xcd\x86W\x17\x81\xf0\x97T" +
-	"\xc4$t1\xceK\xbe,\xb1\xb6\x8a$\x09~\x8c#" +
-	"\x8c[\x97\x81p\xa1\"M\x92\x10\xe3o\x00\x7f\xc2\x05" +
-	"\xc2\x0d\x15Y\x121\x18G,?(\xdf\xc9\xac\x1a\xc4" +
-	"\xeb\x04\xea\x8a\"\x06\xb1\xaf\x80V\xebE9\xb1h\xd0" +
-	"\x18O\xba\x10\x8d:\x84\xa2\xd5\xc2D\xf7\x97\x8ct\xd0" +
-	"PO.r\xcf\xcd\xf4m\xafe\xe8\xa0mv\x93\xeb" +
-	"\x9f\x00\x00\x00\xff\xff\x05x4\x05"
+const schema_9bcb07fb35756ee6 = "x\xda\xacV]l\x14\xd5\x17?gfgo\x97\x7f" +
+	"\xdbe2K\xd2\xfe#t\x8d+\x86*\xa5\x05\x09Z" +
+	"(\xdd\xf2U\xd7\x80\xee,\x82@\xa2q\xd8\xbd\xed\x8e" +
+	"\xec\xce.3\xb3\xc0\x1a\x1b\x04S\x05R>\x04\xe3\x03" +
+	" \x0a\xc6\x07\xd0\x08\x92\xa0\x06\xc2\x83\x18$\xc1\xa0\xbe" +
+	"\xa8\xf1\x83DD\x144\x18\xc54\xa2M\x19sg;" +
+	"\xbb\xd3\x8f\x85j|\x9b\xdd{\xcf\xd7\xef\xfc~\xe7\x9e" +
+	"\xc6\xcd|\x98k\x12\x0e\x8f\x01\x90\x9f\x16\xbcV\xcf\xfe" +
+	"\xa5\xb9Cw<\xb2\x15\xc4 \x02\x08H\x00\xa6\xb5\x08" +
+	"\xbb\x11PZ$\xb4\x02Z\xbdGz\xbe\xfap\xcc\x89" +
+	"m\x85\x0b\x1ev\xde%\xecD\xf0X\x9f\x05\xde\x9by" +
+	"\xed\xfb7\xb7\x83\x18\xe0\xad\x1f\xb4\xdc\xf4>\xf2\xd1\x1e" +
+	"\x00\x9c\x96\x168\x94\xf2\x02\x01\x90r\xc2\xf3\xd29\xf6" +
+	"e\xddu\xb1;v\xa1\x7f\xc7n\x10k\x8b\x81\x8e\x09" +
+	"SY\xa0\x93v \xef\x92\xc6\xe7&\xfc\x1a=0\xd4" +
+	"\x9dt^\xf8N\xbab{\xbb$\xb4K\xa2\x97y\xdb" +
+	"\xf5z_\xed\xb1-{\x0e\x14\xbc\xd9Y\xfd)\x1cg" +
+	"Y=p}c\xcf\xb5\x8dM\x87@\xaeE\xe7\xe8\x8a" +
+	"\xf0\x14\x8b\xd3k\xc7\xb1\xce\xf4<\xfa\xea\xdd\x93\x8f\x0c" +
+	"\x8bS\xeb=+\xdd\xc9\xbcK\xb7{\xdb\xa5\x88\x1d\xe7" +
+	"\x1d\xbd;\xfdS\xc53o\x83x\x1b\xcb\x9ag\xde\x9a" +
+	"\xbc\x1b\x99\xb7\x16\xefa\x06\xcf\x07Ol\xdb\xa6\xfb\x8f" +
+	"\xba\xc3}\xe9]\xc1.\\\xf2\xb2p\xfd7vN\x89" +
+	".\x8b\xbc;,\x9c\x8f\x9c\x92D\xc2\xc2U\x91vi" +
+	":i\x07\xb0\xf2\xa1\xde\xea\xdd\xdc\xa63n\x90Z\xc8" +
+	"J\xe6-B\x98\xb7\x97&\xfeq4\x18<\xf4\xb1\xab" +
+	"n\x95Leu7\xbf?k\xfd\xe5\xb9\xfb\xbep7" +
+	"R&v#\x15\xdbT|1\xd2\xf9\x90'\xfe\x8d\xcb" +
+	"t\x03Y\xc1L\x9f\x9d\xb8i\xc2\xff\xfd\xbf\xb8O\xd2" +
+	"Dg'=\xa1\xb3\xda\x82\xfe\x83\x17\\'\xcbI=" +
+	";\x99-\xce\x13\xbb\xbe\xdd\xff\xa3\xbb\xee6r\x8a\x85" +
+	"\x93Y\xb8\xbeY5;\xfc\xe7n\\qe\x93#\x07" +
+	"\xd8q\xb7\x9d\xcd\xfff\xbc\xf6\xf5\xc5\xda\xf3?\x83\\" +
+	"S\xb4?F\xe6\xd8t\xb0/\x8c\x13~\xfb\xbd\x7f\xf6" +
+	"\x89k \x07\x10K\xc0\x15\\\x9d'cP\xbaJ\x88" +
+	"t\x95\xd4I\xe3+\xd6\x02Z\xfa\xbaON\x93z\xb5" +
+	"w\x18\xce]\x15g\xa5\xcd\x15\xcc\xaa\xbb\xa2\x1d%\xc5" +
+	"G\x00\xfa\xf7^n\xdc\x17\xbe\xf7\xba\x0b\xe6\x88\xcf\x86" +
+	"y\x89\x8f\x05?\xd3\xb5j\xc3R\xe5\xc6uW\xdd9" +
+	"\x9f]w\xf5[\x13f\xb4\x1c\xdc\xfe\xd7\x00!l\xd3" +
+	"\xc7|[\x98i\xda6\xf5$W\x7f\xba5\xf6F\x1f" +
+	"\x885\x8e\xe9\xcb\xbef\x84\x99\x96F\xcd)q%\xab" +
+	"\x09\xd9)JVm`\x9f\xd9\xe6\x18\xcd\xa6\xd4\xb8b" +
+	"\xaa\x19\xadA\xa7\xabs\xd40\xa3\xaaf\x84\xa2\x8a\xae" +
+	"\xf0iC\xf6\xf0\x1e\x00\x0f\x02\x88U\xcd\x00r\x05\x8f" +
+	"r\x88\xc3\xd6\xa4b$\xa9\x81\xd5\x80Q\x1e\xb1\x0a8" +
+	"\xf6y\xeb\x08YU[\x9cK\xa7\x15=o\x07H\xa3" +
+	"Q\xb4\xe1\xcb\xd8@\x14Q\xae\xe0\x05\x80\xe2(@\x87" +
+	"kb\xd3\x0a\xe0\xc4I\x04\xb18GpV\x0d\x14\x1a" +
+	"?~%p\xe28b91\x81\xd7\xf3a\xb4\x9c\"" +
+	"\x81\xa8\x9a\x11\xc6(\xe2\xc8),\xa2\xa6\xc2\xf2\xed\x0c" +
+	"\xc5h\x9d\x91K\x99\x83\xb0\x98:\x80E\x80\xc3:\x9d" +
+	"fSy\xac\x04\x0e+]\x18pngQE\xd5\x89" +
+	"\xaau\xb2Z<\xbc\xe0\x126:\x0d\x15\xc5'\x81\x13" +
+	"}\xc4\xd2i\x82\xd2tD\x03\xff\x1a\xd5\xa4\x833\x1c" +
+	"\x04\xec\xe2\xbc\x16oP\x8d\xb9\x99t6EM\xba\x80" +
+	"\x9a\xf1d[*\x95YK\x13\xa1V\x1b\xde\x12\xba\x9e" +
+	"\x11\x0c\xa39\xa3x?\xd6J\x87\xd5\x18\x03\x90+y" +
+	"\x94k8\xb4T\xa3p\x130\x81\x08\x1cb\xb9J\x19" +
+	"l\x00\xa5:\x1d\x05\xa33\x7fE\xb1\x1e8Q ~" +
+	"\x86\xedM\xaac\x90\xa9Zg\x83\x03\x07\x03\xa3@\x1a" +
+	"\x83\xa5\xe5d9\x9fu\"\xcc\xa3\xbc\x90C\x111\x80" +
+	"\xec\xcfH=\x80<\x8fG9\xca\xa1\xc8q\x01\xe4\x00" +
+	"\xc4E+\x01\xe4\x85<\xca\xcb8\xac33\xab\xa8\xe6" +
+	"\xf4\xcc\xaf)i\xea\xfc\xb0:T\xad\x93\xeaY\x9d\x11" +
+	"\xc4\x1c\xd6\xd6\xe1@v0\xdc\xa3\x8a\x19O\x8e$\x1a" +
+	"7\x88\x1dz&\x1d\xd1\x12\x14p\x1d\x0a\xc0\xa1P\x0e" +
+	"\xc4\xb6h\xc4\x05\xa1\xa3htf\x96(\xce\xb1!\\" +
+	"\xbf\x86\xea\x86\x9a\xd1\xc2(\x8fE\xd7\x04\x02(=2" +
+	"\x00\xa5\x87\x0d\xa0\xf4f\x02\x8c\xae\xa4\x185rd\x08" +
+	"1\xeaK\xe4\xf7'\x14S\xb1\xf5_\x05e\x84d{" +
+	"\xcc\xe6\x8cdQH\xffhN\xb0\x04R\xbc9\xe2$" +
+	"\x0ap\xd8\xda\xa1\xa6L\xaa\x0fK\xa1LQ\x8b\xcd\x8c" +
+	"N\x9d>\x8dZ\x1d\xd1\xba\xc1j*3(\xa2\x8a\x7f" +
+	"\xd05\xefh\xd5\x1a+\x88\x0f\xfe\x8d\xfaF5\xcdG" +
+	"\xea\xa2{\x9cgUM\xa3\x89r\xe3\xdc3\x84\x9b\x0d" +
+	"\x03\xbc\x1b1\xef9\xa5\xde8\xfcD\x0fp\xe8)\xc7" +
+	"\x8f\x82\xb4\x17dR\x09\xaa\x0fL\x0e\xe4\xac\xc7w\xbd" +
+	"\"\x9f\xfc|\xcbi\x90=\x1c\xb6\x05\x11+\x01\x9a\xb0" +
+	"\x19\xad\xb6`\x87}\x95\x0f\x9aI\x1aTm\xe3DP" +
+	"\xa7\xe9\x8cI\x83i%\x1flU\xe2qj\x18v\x1a" +
+	"NV\x93X\xb1!\x1e\xe5F\x0e\x9d!1\xf9A\x00" +
+	"\xf9\x1e\x1e\xe5\xfb\x18\x8bl\x9fE\xb5\xebTI<\xac" +
+	"\xa5\xf2\x00p\xf3q\xc7\xbaZH:`k\xd5Yr" +
+	"p/\x0c\xbc\xf0/\xb0'j3\xc1\xd2\xaa\x86\xce\x96" +
+	"%v\xb1\xb3\x1cA\xae\xb8O\xa2\xb3\xd7\x88\xeaq\xe0" +
+	"DJ\x90/\xaeG\xe8\xac\x96\xe2r\x1d8Q&\xe8" +
+	").\x0a\xe8,f\xe2|6^\xef'\x96Cw\xe0" +
+	"u\x1aF\xcb\x914\xf0\xf1d\x18-\x87\x88\xe80\xb1" +
+	"\xb5@E\xfb\xa8@}\xa8\x1b\xf8\xc7\xcf\xc4;xR" +
+	"\xdfT]\xff\xe5\xc8\x18*\xa9[?\x11\x0e'G$" +
+	"e\x88\xc3\xf5\x85V\x17w\x97\xb1\xa5M\x0f\x10\xab\xcb" +
+	"\xa5\xe4\xa6\xfd\xc0\xe3\xfa\xf7\x00\xcb\x97\xb1\x81"
 
 func init() {
 	schemas.Register(schema_9bcb07fb35756ee6,
+		0x8d5423aa7556a18c,
+		0x8ebb0ac6da8cb0f5,
+		0x8face4f23bb814d5,
 		0x9a90fde15285e327,
+		0xa250f01e86305506,
 		0xa29b8ab519fba593,
 		0xaa3182f28c82f848,
 		0xb02d2ba0578cc7ff,
+		0xb18008ea6d8572b6,
 		0xb20f728e8e60c3f5,
 		0xb74958502f92fefd,
 		0xc788029a0ef52479,
 		0xceaa2020b2f72696,
+		0xd79e43e87f3cc13a,
 		0xdc63044e67499411,
 		0xdcee0f1a1e882683,
 		0xe1a9fd466eca248c,
 		0xe7a1e07d1144113e,
+		0xe9fecd0f9000183c,
 		0xebdd19e3dba3370b,
+		0xf2bb3efdf3f10515,
 		0xf5692a07c5cf7872,
 		0xf834409e30e8009c,
 		0xf8fe6156816b7dc7,
+		0xfa8fa93d371eae0e,
 		0xfbab528dd0716804)
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"sync"
 
 	e "github.com/pkg/errors"
 	netBackend "github.com/sahib/brig/net/backend"
@@ -26,6 +27,27 @@ type Client struct {
 	rawConn  net.Conn
 	authConn *AuthReadWriter
 	api      capnp.API
+	counter  *countingConn
+}
+
+// countingConn tells a callback how many bytes were read from a connection.
+type countingConn struct {
+	net.Conn
+
+	mu     sync.Mutex
+	onRead func(n int64)
+}
+
+func (cc *countingConn) Read(buf []byte) (int, error) {
+	n, err := cc.Conn.Read(buf)
+
+	cc.mu.Lock()
+	if cc.onRead != nil && n > 0 {
+		cc.onRead(int64(n))
+	}
+	cc.mu.Unlock()
+
+	return n, err
 }
 
 // Dial creates a new Client connected to `name`.
@@ -53,28 +75,74 @@ func DialByAddr(
 	bk netBackend.Backend,
 	pingMap *PingMap,
 ) (*Client, error) {
+	if fingerprint == "" {
+		return nil, fmt.Errorf("rejecting own, empty fingerprint... bug?")
+	}
+
+	rawConn, counter, authConn, err := dialAuth(addr, fingerprint.PubKeyID(), fingerprint, rp, bk)
+	if err != nil {
+		// Daemons that do not serve several repositories listen on a
+		// protocol name without the fingerprint. Give those a chance too:
+		log.Debugf("dial to %s failed (%v), trying without fingerprint", addr, err)
+		rawConn, counter, authConn, err = dialAuth(addr, "", fingerprint, rp, bk)
+	}
+
+	if err != nil {
+		pingMap.hintNetAttempt(addr, false)
+		return nil, err
+	}
+
+	pingMap.hintNetAttempt(addr, true)
+
+	// Setup capnp-rpc:
+	transport := rpc.StreamTransport(rawConn)
+	clientConn := rpc.NewConn(transport, rpc.ConnLog(nil))
+	api := capnp.API{Client: clientConn.Bootstrap(ctx)}
+
+	return &Client{
+		ctx:      ctx,
+		authConn: authConn,
+		conn:     clientConn,
+		rawConn:  rawConn,
+		api:      api,
+		counter:  counter,
+	}, nil
+}
+
+// dialAuth opens a raw connection to `addr` (to the repository
+// identified by `pubKeyID` on that node) and authenticates it.
+// The remote has to authenticate with the key of `fingerprint`.
+func dialAuth(
+	addr, pubKeyID string,
+	fingerprint peer.Fingerprint,
+	rp *repo.Repository,
+	bk netBackend.Backend,
+) (net.Conn, *countingConn, *AuthReadWriter, error) {
 	kr := rp.Keyring()
 	ownPubKey, err := kr.OwnPubKey()
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	// Low level by addr, not by brig's remote name:
-	log.Debugf("raw dial to %s:%s", addr, fingerprint.PubKeyID())
-	rawConn, err := bk.Dial(addr, fingerprint.PubKeyID(), "brig/caprpc")
+	log.Debugf("raw dial to %s:%s", addr, pubKeyID)
+	rawConn, err := bk.Dial(addr, pubKeyID, "brig/caprpc")
 	if err != nil {
-		pingMap.hintNetAttempt(addr, false)
-		return nil, e.Wrapf(err, "raw")
+		return nil, nil, nil, e.Wrapf(err, "raw")
 	}
 
-	ownName := rp.Owner
-	if fingerprint == "" {
-		return nil, fmt.Errorf("rejecting own, empty fingerprint... bug?")
+	// Limit the connection by the global and (if known) by the remote's limits:
+	remoteName := ""
+	if remote, err := rp.Remotes.RemoteByAddr(addr); err == nil {
+		remoteName = remote.Name
 	}
 
-	authConn := NewAuthReadWriter(rawConn, kr, ownPubKey, ownName, func(pubKey []byte) error {
+	rawConn = rp.Bandwidth.Conn(rawConn, remoteName)
+	counter := &countingConn{Conn: rawConn}
+	rawConn = counter
+
+	authConn := NewAuthReadWriter(rawConn, kr, ownPubKey, rp.Owner, func(pubKey []byte) error {
 		if !fingerprint.PubKeyMatches(pubKey) {
-			pingMap.hintNetAttempt(addr, false)
 			return fmt.Errorf("remote pubkey does not match fingerprint")
 		}
 
@@ -84,24 +152,11 @@ func DialByAddr(
 	// Trigger the authentication:
 	// (otherwise it would be triggered on the first read/write)
 	if err := authConn.Trigger(); err != nil {
-		pingMap.hintNetAttempt(addr, false)
-		return nil, e.Wrapf(err, "auth")
+		rawConn.Close()
+		return nil, nil, nil, e.Wrapf(err, "auth")
 	}
 
-	pingMap.hintNetAttempt(addr, true)
-
-	// Setup capnp-rpc:
-	transport := rpc.StreamTransport(rawConn)
-	clientConn := rpc.NewConn(transport, rpc.ConnLog(nil))
-	api := capnp.API{Client: clientConn.Bootstrap(ctx)}
-
-	return &Client{
-		ctx:      ctx,
-		authConn: authConn,
-		conn:     clientConn,
-		rawConn:  rawConn,
-		api:      api,
-	}, nil
+	return rawConn, counter, authConn, nil
 }
 
 // PeekRemotePubkey connects to `addr` and tries to read the public key they claim.
@@ -142,6 +197,22 @@ func (cl *Client) Close() error {
 	return cl.conn.Close()
 }
 
+// RemotePubKey returns the public key the remote authenticated with.
+// It was already checked to match the remote's fingerprint.
+func (cl *Client) RemotePubKey() []byte {
+	return cl.authConn.RemotePubKey()
+}
+
+// OnReceive sets a function that is called with the number of bytes
+// read from the remote whenever data arrives. This can be used to report
+// progress of long calls like FetchStore. Pass nil to unset it.
+func (cl *Client) OnReceive(fn func(n int64)) {
+	cl.counter.mu.Lock()
+	defer cl.counter.mu.Unlock()
+
+	cl.counter.onRead = fn
+}
+
 /////////////////////
 // ACTUAL COMMANDS //
 /////////////////////
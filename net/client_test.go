@@ -5,10 +5,12 @@ import (
 	"context"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"testing"
 	"time"
 
 	"github.com/sahib/brig/backend"
+	"github.com/sahib/brig/backend/httpipfs"
 	"github.com/sahib/brig/catfs"
 	ie "github.com/sahib/brig/catfs/errors"
 	"github.com/sahib/brig/net/peer"
@@ -150,11 +152,20 @@ func TestClientFetchStore(t *testing.T) {
 			t.Fatalf("failed to stage simple file: %v", err)
 		}
 
+		received := int64(0)
+		b.ctl.OnReceive(func(n int64) {
+			received += n
+		})
+
 		data, err := b.ctl.FetchStore()
 		if err != nil {
 			t.Fatalf("failed to read store: %v", err)
 		}
 
+		// The answer alone is bigger than the store:
+		b.ctl.OnReceive(nil)
+		require.True(t, received >= int64(data.Len()))
+
 		aliceFs, err := b.rp.FS("alice", b.bk)
 		if err != nil {
 			t.Fatalf("Failed to get empty bob fs: %v", err)
@@ -283,3 +294,131 @@ func TestClientCompleteFetchAllowed(t *testing.T) {
 		require.True(t, isAllowed)
 	})
 }
+
+func TestClientCompleteFetchAllowedByGroup(t *testing.T) {
+	withNetPair(t, func(a, b testUnit) {
+		// bob has no own folders, so a group can not take anything away:
+		require.Nil(t, a.rp.Remotes.AddOrUpdateGroup(repo.Group{
+			Name:    "team",
+			Members: []string{"bob"},
+			Folders: []repo.Folder{{Folder: "/team-docs"}},
+		}))
+
+		isAllowed, err := b.ctl.IsCompleteFetchAllowed()
+		require.Nil(t, err)
+		require.True(t, isAllowed)
+
+		// With own folders he only gets those and the group folders:
+		bobRemote, err := a.rp.Remotes.Remote("bob")
+		require.Nil(t, err)
+		bobRemote.Folders = []repo.Folder{{Folder: "/photos"}}
+		require.Nil(t, a.rp.Remotes.AddOrUpdateRemote(bobRemote))
+
+		isAllowed, err = b.ctl.IsCompleteFetchAllowed()
+		require.Nil(t, err)
+		require.False(t, isAllowed)
+
+		require.Nil(t, a.rp.Remotes.AddOrUpdateGroup(repo.Group{
+			Name:    "admins",
+			Members: []string{"bob"},
+			Folders: []repo.Folder{{Folder: "/"}},
+		}))
+
+		isAllowed, err = b.ctl.IsCompleteFetchAllowed()
+		require.Nil(t, err)
+		require.True(t, isAllowed)
+	})
+}
+
+func withIpfsRepo(t *testing.T, name string, fn func(rp *repo.Repository, pubKeyID string)) {
+	basePath, err := ioutil.TempDir("", "brig-net-ipfs-test")
+	require.Nil(t, err)
+
+	defer func() {
+		require.Nil(t, os.RemoveAll(basePath))
+	}()
+
+	require.Nil(t, repo.Init(basePath, name, "password", "httpipfs", 6666))
+	rp, err := repo.Open(basePath, "password")
+	require.Nil(t, err)
+
+	ownPubKey, err := rp.Keyring().OwnPubKey()
+	require.Nil(t, err)
+
+	fn(rp, peer.BuildFingerprint("", ownPubKey).PubKeyID())
+}
+
+func serveWith(t *testing.T, rp *repo.Repository, bk backend.Backend) func() {
+	srv, err := NewServer(rp, bk, nil)
+	require.Nil(t, err)
+
+	waitForDeath := make(chan bool)
+	go func() {
+		defer func() {
+			waitForDeath <- true
+		}()
+		require.Nil(t, srv.Serve())
+		require.Nil(t, srv.Close())
+	}()
+
+	return func() {
+		srv.Quit()
+		<-waitForDeath
+	}
+}
+
+func TestPeekRemotePubkeySharedNode(t *testing.T) {
+	if _, err := exec.LookPath("ipfs"); err != nil {
+		t.Skip("needs an ipfs binary")
+	}
+
+	httpipfs.WithDoubleIpfs(t, 1, func(t *testing.T, ipfsPathA, ipfsPathB string) {
+		withIpfsRepo(t, "ali", func(aliRp *repo.Repository, aliID string) {
+			withIpfsRepo(t, "bob", func(bobRp *repo.Repository, bobID string) {
+				withIpfsRepo(t, "cem", func(cemRp *repo.Repository, cemID string) {
+					// ali owns the first node, bob shares it with her:
+					aliNd, err := httpipfs.NewNode(ipfsPathA, aliID)
+					require.Nil(t, err)
+					bobNd := aliNd.WithFingerprint(bobID)
+
+					defer serveWith(t, aliRp, aliNd)()
+					defer serveWith(t, bobRp, bobNd)()
+
+					cemNd, err := httpipfs.NewNode(ipfsPathB, cemID)
+					require.Nil(t, err)
+
+					self, err := aliNd.Identity()
+					require.Nil(t, err)
+
+					aliPubKey, err := aliRp.Keyring().OwnPubKey()
+					require.Nil(t, err)
+
+					bobPubKey, err := bobRp.Keyring().OwnPubKey()
+					require.Nil(t, err)
+
+					// Peeking does not know the fingerprint yet and
+					// should reach the repository owning the node.
+					// Try from another node and from the same node:
+					ctx := context.Background()
+					for _, peeker := range []struct {
+						rp *repo.Repository
+						bk backend.Backend
+					}{{cemRp, cemNd}, {bobRp, bobNd}} {
+						pubKey, name, err := PeekRemotePubkey(ctx, self.Addr, peeker.rp, peeker.bk)
+						require.Nil(t, err)
+						require.Equal(t, aliPubKey, pubKey)
+						require.Equal(t, "ali", name)
+					}
+
+					// With the fingerprint we can reach bob too:
+					for _, pubKey := range [][]byte{aliPubKey, bobPubKey} {
+						ctl, err := DialByAddr(ctx, self.Addr, peer.BuildFingerprint(self.Addr, pubKey), cemRp, cemNd, nil)
+						require.Nil(t, err)
+						require.Equal(t, pubKey, ctl.RemotePubKey())
+						require.Nil(t, ctl.Close())
+					}
+				})
+			})
+		})
+	})
+}
@@ -9,7 +9,9 @@ import (
 	"github.com/sahib/brig/backend"
 	"github.com/sahib/brig/gateway/remotesapi"
 	"github.com/sahib/brig/net/capnp"
+	"github.com/sahib/brig/net/peer"
 	"github.com/sahib/brig/repo"
+	"github.com/sahib/brig/util/throttle"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -19,6 +21,14 @@ type requestHandler struct {
 	ctx            context.Context
 	rapi           remotesapi.RemotesAPI
 	currRemoteName string
+
+	// guestPubKey is the public key of a peer that is not
+	// in our remote list yet. Only set while invites are pending.
+	guestPubKey []byte
+
+	// limConn is the connection to the other side. It is limited
+	// by the global limits and the ones of currRemoteName.
+	limConn *throttle.Conn
 }
 
 func completeExportAllowed(folders []repo.Folder) bool {
@@ -37,8 +47,8 @@ func completeExportAllowed(folders []repo.Folder) bool {
 
 func (hdl *requestHandler) FetchStore(call capnp.Sync_fetchStore) error {
 	// We should only export our complete metadata, when the root directory
-	// was enabled or no folders were configured.
-	currRemote, err := hdl.rp.Remotes.Remote(hdl.currRemoteName)
+	// was enabled or no folders were configured (also not by groups).
+	currRemote, err := hdl.rp.Remotes.EffectiveRemote(hdl.currRemoteName)
 	if err != nil {
 		return err
 	}
@@ -62,7 +72,7 @@ func (hdl *requestHandler) FetchStore(call capnp.Sync_fetchStore) error {
 }
 
 func (hdl *requestHandler) FetchPatch(call capnp.Sync_fetchPatch) error {
-	currRemote, err := hdl.rp.Remotes.Remote(hdl.currRemoteName)
+	currRemote, err := hdl.rp.Remotes.EffectiveRemote(hdl.currRemoteName)
 	if err != nil {
 		return err
 	}
@@ -92,7 +102,7 @@ func (hdl *requestHandler) FetchPatch(call capnp.Sync_fetchPatch) error {
 }
 
 func (hdl *requestHandler) IsCompleteFetchAllowed(call capnp.Sync_isCompleteFetchAllowed) error {
-	currRemote, err := hdl.rp.Remotes.Remote(hdl.currRemoteName)
+	currRemote, err := hdl.rp.Remotes.EffectiveRemote(hdl.currRemoteName)
 	if err != nil {
 		return err
 	}
@@ -111,6 +121,105 @@ func (hdl *requestHandler) Version(call capnp.API_version) error {
 	return nil
 }
 
+func (hdl *requestHandler) RedeemInvite(call capnp.Pairing_redeemInvite) error {
+	if hdl.guestPubKey == nil {
+		return fmt.Errorf("you are already a remote of ours")
+	}
+
+	token, err := call.Params.Token()
+	if err != nil {
+		return err
+	}
+
+	name, err := call.Params.Name()
+	if err != nil {
+		return err
+	}
+
+	capFingerprint, err := call.Params.Fingerprint()
+	if err != nil {
+		return err
+	}
+
+	fingerprint, err := peer.CastFingerprint(capFingerprint)
+	if err != nil {
+		return err
+	}
+
+	// Make sure nobody can add a key it does not own:
+	if !fingerprint.PubKeyMatches(hdl.guestPubKey) {
+		return fmt.Errorf("fingerprint does not match your key")
+	}
+
+	if _, err := hdl.rp.Remotes.Remote(name); err == nil {
+		return fmt.Errorf("there is already a remote named %s", name)
+	}
+
+	invite, err := hdl.rp.Invites.Redeem(token)
+	if err != nil {
+		log.Warningf("attempt to redeem invalid invite by `%s`", name)
+		return err
+	}
+
+	if hdl.rapi != nil {
+		// Add it via the daemon, so it also starts to watch the new remote.
+		folders := []remotesapi.Folder{}
+		for _, folder := range invite.Folders {
+			folders = append(folders, remotesapi.Folder{
+				Folder:   folder.Folder,
+				ReadOnly: folder.ReadOnly,
+			})
+		}
+
+		err = hdl.rapi.Set(remotesapi.Remote{
+			Name:        name,
+			Fingerprint: string(fingerprint),
+			Folders:     folders,
+		})
+	} else {
+		err = hdl.rp.Remotes.AddOrUpdateRemote(repo.Remote{
+			Name:        name,
+			Fingerprint: fingerprint,
+			Folders:     invite.Folders,
+		})
+	}
+
+	if err != nil {
+		return err
+	}
+
+	log.Infof("added `%s` as remote by invite", name)
+
+	// The guest is a remote like any other from now on:
+	if hdl.limConn != nil {
+		hdl.rp.Bandwidth.LimitRemote(hdl.limConn, name)
+	}
+
+	seg := call.Results.Segment()
+	capFolders, err := capnp.NewInviteFolder_List(seg, int32(len(invite.Folders)))
+	if err != nil {
+		return err
+	}
+
+	for idx, folder := range invite.Folders {
+		capFolder, err := capnp.NewInviteFolder(seg)
+		if err != nil {
+			return err
+		}
+
+		if err := capFolder.SetFolder(folder.Folder); err != nil {
+			return err
+		}
+
+		capFolder.SetReadOnly(folder.ReadOnly)
+		if err := capFolders.Set(idx, capFolder); err != nil {
+			return err
+		}
+	}
+
+	return call.Results.SetFolders(capFolders)
+}
+
 func (hdl *requestHandler) IsPushAllowed(call capnp.Sync_isPushAllowed) error {
 	currRemote, err := hdl.rp.Remotes.Remote(hdl.currRemoteName)
 	if err != nil {
@@ -0,0 +1,194 @@
+package net
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	e "github.com/pkg/errors"
+	netBackend "github.com/sahib/brig/net/backend"
+	"github.com/sahib/brig/net/capnp"
+	"github.com/sahib/brig/net/peer"
+	"github.com/sahib/brig/repo"
+)
+
+const (
+	invitePrefix = "brig-invite:"
+)
+
+// InviteCode contains everything a peer needs to add itself
+// as remote of the peer that created the invite.
+// It is handed over out of band as a string (see Encode()).
+type InviteCode struct {
+	// Name of the inviting peer.
+	Name string `json:"name"`
+	// Fingerprint of the inviting peer.
+	Fingerprint peer.Fingerprint `json:"fingerprint"`
+	// Token is the secret of the invite (see repo.Invite).
+	Token string `json:"token"`
+}
+
+// Encode encodes the invite code, so it can be
+// copied around and read back by ParseInviteCode().
+func (ic InviteCode) Encode() (string, error) {
+	data, err := json.Marshal(ic)
+	if err != nil {
+		return "", e.Wrapf(err, "failed to encode invite code")
+	}
+
+	return invitePrefix + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// ParseInviteCode reads an invite code created with Encode().
+func ParseInviteCode(s string) (*InviteCode, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, invitePrefix) {
+		return nil, fmt.Errorf("not an invite code")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(s[len(invitePrefix):])
+	if err != nil {
+		return nil, e.Wrapf(err, "bad invite code")
+	}
+
+	ic := &InviteCode{}
+	if err := json.Unmarshal(data, ic); err != nil {
+		return nil, e.Wrapf(err, "bad invite code")
+	}
+
+	if _, err := peer.CastFingerprint(string(ic.Fingerprint)); err != nil {
+		return nil, err
+	}
+
+	if ic.Name == "" || ic.Token == "" {
+		return nil, fmt.Errorf("bad invite code: missing name or token")
+	}
+
+	return ic, nil
+}
+
+// Invite creates a new invite for a peer that may then access `folders`.
+// The returned code is valid once and only until `timeout` passed.
+func (sv *Server) Invite(folders []repo.Folder, timeout time.Duration) (*InviteCode, error) {
+	rp := sv.hdl.rp
+	self, err := sv.Identity()
+	if err != nil {
+		return nil, err
+	}
+
+	ownPubKey, err := rp.Keyring().OwnPubKey()
+	if err != nil {
+		return nil, err
+	}
+
+	invite, err := rp.Invites.Create(folders, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InviteCode{
+		Name:        rp.Owner,
+		Fingerprint: peer.BuildFingerprint(self.Addr, ownPubKey),
+		Token:       invite.Token,
+	}, nil
+}
+
+// RedeemInvite asks the remote to add us as remote with the invite `token`.
+// `name` and `fingerprint` are the ones it should use for us.
+// The folders we were granted access to are returned.
+func (cl *Client) RedeemInvite(token, name string, fingerprint peer.Fingerprint) ([]repo.Folder, error) {
+	call := cl.api.RedeemInvite(cl.ctx, func(p capnp.Pairing_redeemInvite_Params) error {
+		if err := p.SetToken(token); err != nil {
+			return err
+		}
+
+		if err := p.SetName(name); err != nil {
+			return err
+		}
+
+		return p.SetFingerprint(string(fingerprint))
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capFolders, err := result.Folders()
+	if err != nil {
+		return nil, err
+	}
+
+	folders := []repo.Folder{}
+	for idx := 0; idx < capFolders.Len(); idx++ {
+		capFolder := capFolders.At(idx)
+		folder, err := capFolder.Folder()
+		if err != nil {
+			return nil, err
+		}
+
+		folders = append(folders, repo.Folder{
+			Folder:   folder,
+			ReadOnly: capFolder.ReadOnly(),
+		})
+	}
+
+	return folders, nil
+}
+
+// Join redeems the invite `code` and adds the inviting peer as remote.
+// The new remote gets access to the same folders we were granted and
+// only those folders of it are synced. The new remote is returned.
+func Join(ctx context.Context, code *InviteCode, rp *repo.Repository, bk netBackend.Backend, pingMap *PingMap) (*repo.Remote, error) {
+	if _, err := rp.Remotes.Remote(code.Name); err == nil {
+		return nil, fmt.Errorf("there is already a remote named %s", code.Name)
+	}
+
+	ownPubKey, err := rp.Keyring().OwnPubKey()
+	if err != nil {
+		return nil, err
+	}
+
+	self, err := bk.Identity()
+	if err != nil {
+		return nil, err
+	}
+
+	ctl, err := DialByAddr(ctx, code.Fingerprint.Addr(), code.Fingerprint, rp, bk, pingMap)
+	if err != nil {
+		return nil, e.Wrapf(err, "dial")
+	}
+
+	defer ctl.Close()
+
+	ownFingerprint := peer.BuildFingerprint(self.Addr, ownPubKey)
+	grantedFolders, err := ctl.RedeemInvite(code.Token, rp.Owner, ownFingerprint)
+	if err != nil {
+		return nil, e.Wrapf(err, "redeem")
+	}
+
+	// A read only folder keeps them from taking our changes.
+	// We still want theirs, so the flag is not mirrored here.
+	folders := []repo.Folder{}
+	subscribed := []string{}
+	for _, folder := range grantedFolders {
+		folders = append(folders, repo.Folder{Folder: folder.Folder})
+		subscribed = append(subscribed, folder.Folder)
+	}
+
+	remote := repo.Remote{
+		Name:              code.Name,
+		Fingerprint:       code.Fingerprint,
+		Folders:           folders,
+		SubscribedFolders: subscribed,
+	}
+
+	if err := rp.Remotes.AddOrUpdateRemote(remote); err != nil {
+		return nil, err
+	}
+
+	return &remote, nil
+}
@@ -0,0 +1,90 @@
+package net
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sahib/brig/repo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInviteCodeParse(t *testing.T) {
+	code := InviteCode{
+		Name:        "alice",
+		Fingerprint: "QmAddr:QmPubKey",
+		Token:       "abcdef",
+	}
+
+	encoded, err := code.Encode()
+	require.Nil(t, err)
+
+	parsed, err := ParseInviteCode(" " + encoded + "\n")
+	require.Nil(t, err)
+	require.Equal(t, code, *parsed)
+
+	_, err = ParseInviteCode("brig-invite:garbage")
+	require.NotNil(t, err)
+
+	_, err = ParseInviteCode("QmAddr:QmPubKey")
+	require.NotNil(t, err)
+}
+
+func TestInviteJoin(t *testing.T) {
+	basePath, err := ioutil.TempDir("", "brig-net-invite-test")
+	require.Nil(t, err)
+
+	defer func() {
+		require.Nil(t, os.RemoveAll(basePath))
+	}()
+
+	withNetServer(t, "alice", basePath, func(a testUnit) {
+		withNetServer(t, "bob", basePath, func(b testUnit) {
+			ctx := context.Background()
+
+			// bob is not known to alice yet:
+			_, err := Dial(ctx, "alice", b.rp, b.bk, nil)
+			require.NotNil(t, err)
+
+			folders := []repo.Folder{{Folder: "/public", ReadOnly: true}}
+			code, err := a.srv.Invite(folders, time.Minute)
+			require.Nil(t, err)
+
+			encoded, err := code.Encode()
+			require.Nil(t, err)
+
+			parsedCode, err := ParseInviteCode(encoded)
+			require.Nil(t, err)
+
+			aliceRemote, err := Join(ctx, parsedCode, b.rp, b.bk, nil)
+			require.Nil(t, err)
+			require.Equal(t, "alice", aliceRemote.Name)
+			require.Equal(t, []string{"/public"}, aliceRemote.SubscribedFolders)
+			require.Equal(t, []repo.Folder{{Folder: "/public"}}, aliceRemote.Folders)
+
+			bobRemote, err := a.rp.Remotes.Remote("bob")
+			require.Nil(t, err)
+			require.Equal(t, folders, bobRemote.Folders)
+			require.Equal(t, buildFingerprint(t, b), bobRemote.Fingerprint)
+			require.False(t, a.rp.Invites.HasPending())
+
+			// Both sides know each other now:
+			aliCtl, err := Dial(ctx, "alice", b.rp, b.bk, nil)
+			require.Nil(t, err)
+			require.Nil(t, aliCtl.Ping())
+			require.Nil(t, aliCtl.Close())
+
+			bobCtl, err := Dial(ctx, "bob", a.rp, a.bk, nil)
+			require.Nil(t, err)
+			require.Nil(t, bobCtl.Ping())
+			require.Nil(t, bobCtl.Close())
+
+			// The code can be used only once:
+			require.Nil(t, b.rp.Remotes.RmRemote("alice"))
+			_, err = Join(ctx, parsedCode, b.rp, b.bk, nil)
+			require.NotNil(t, err)
+		})
+	})
+}
@@ -0,0 +1,184 @@
+package lan
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// msgQuery asks for either a name or an addr.
+	msgQuery = "query"
+	// msgAnnounce tells everyone where to reach the sender.
+	msgAnnounce = "announce"
+
+	// maxMessageSize is the size of the biggest datagram we accept.
+	maxMessageSize = 4096
+)
+
+// message is sent as JSON over the multicast group.
+type message struct {
+	Type string `json:"type"`
+
+	// Name is the queried or announced name.
+	Name string `json:"name,omitempty"`
+
+	// Addr is the queried or announced identity addr.
+	Addr string `json:"addr,omitempty"`
+
+	// Port is the TCP port of the announcing peer.
+	// The IP is taken from the datagram.
+	Port int `json:"port,omitempty"`
+}
+
+// announcement is a received msgAnnounce message with a full endpoint.
+type announcement struct {
+	Name     string
+	Addr     string
+	Endpoint string
+}
+
+func (lb *Backend) send(msg message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	lb.mu.Lock()
+	udpSend := lb.udpSend
+	isOnline := lb.isOnline
+	lb.mu.Unlock()
+
+	if !isOnline {
+		return ErrOffline
+	}
+
+	_, err = udpSend.Write(data)
+	return err
+}
+
+// announce tells the group that we're reachable as `name`.
+// `name` may be empty, in which case only our addr is announced.
+func (lb *Backend) announce(name string) error {
+	addr, err := lb.ownAddr()
+	if err != nil {
+		return err
+	}
+
+	return lb.send(message{
+		Type: msgAnnounce,
+		Name: name,
+		Addr: addr,
+		Port: lb.tcpPort(),
+	})
+}
+
+func (lb *Backend) subscribe() chan announcement {
+	ch := make(chan announcement, 100)
+
+	lb.mu.Lock()
+	lb.waiters[ch] = true
+	lb.mu.Unlock()
+
+	return ch
+}
+
+func (lb *Backend) unsubscribe(ch chan announcement) {
+	lb.mu.Lock()
+	delete(lb.waiters, ch)
+	lb.mu.Unlock()
+}
+
+func (lb *Backend) discoveryLoop(conn *net.UDPConn) {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			// This happens normally when stop() closes the socket.
+			log.Debugf("lan: discovery loop stopped: %v", err)
+			return
+		}
+
+		msg := message{}
+		if err := json.Unmarshal(buf[:n], &msg); err != nil {
+			log.Debugf("lan: ignoring bad message from %s: %v", src, err)
+			continue
+		}
+
+		switch msg.Type {
+		case msgQuery:
+			lb.handleQuery(msg)
+		case msgAnnounce:
+			lb.handleAnnounce(msg, src)
+		default:
+			log.Debugf("lan: ignoring unknown message type `%s`", msg.Type)
+		}
+	}
+}
+
+func (lb *Backend) handleQuery(msg message) {
+	addr, err := lb.ownAddr()
+	if err != nil {
+		log.Warningf("lan: failed to get own identity: %v", err)
+		return
+	}
+
+	lb.mu.Lock()
+	isPublished := lb.names[msg.Name]
+	lb.mu.Unlock()
+
+	switch {
+	case msg.Name != "" && isPublished:
+	case msg.Addr != "" && msg.Addr == addr:
+	default:
+		// Not meant for us.
+		return
+	}
+
+	if err := lb.announce(msg.Name); err != nil {
+		log.Warningf("lan: failed to answer query: %v", err)
+	}
+}
+
+func (lb *Backend) handleAnnounce(msg message, src *net.UDPAddr) {
+	if msg.Addr == "" || msg.Port <= 0 {
+		return
+	}
+
+	ann := announcement{
+		Name:     msg.Name,
+		Addr:     msg.Addr,
+		Endpoint: net.JoinHostPort(src.IP.String(), strconv.Itoa(msg.Port)),
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	lb.endpoints[ann.Addr] = ann.Endpoint
+	for ch := range lb.waiters {
+		select {
+		case ch <- ann:
+		default:
+			// Nobody is reading fast enough; drop it.
+		}
+	}
+}
+
+// lookup returns the TCP endpoint of the peer with `addr`.
+func (lb *Backend) lookup(addr string) (string, bool) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	endpoint, ok := lb.endpoints[addr]
+	return endpoint, ok
+}
+
+// forget removes a cached endpoint, usually after dialing it failed.
+func (lb *Backend) forget(addr string) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	delete(lb.endpoints, addr)
+}
@@ -0,0 +1,380 @@
+// Package lan implements a net backend that finds other peers on the local
+// network via UDP multicast and talks to them over plain TCP.
+//
+// It can be used on its own or stacked on top of another net backend
+// (usually the IPFS one). In the latter case the identity of the wrapped
+// backend is re-used, so remotes do not change when enabling it, and the
+// wrapped backend is used whenever a peer can not be reached directly.
+//
+// The transport itself is not secured; the usual authentication
+// handshake (see net.AuthReadWriter) is still done on top of it.
+package lan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	netBackend "github.com/sahib/brig/net/backend"
+	"github.com/sahib/brig/net/peer"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultGroup is the multicast group used to discover other peers.
+	DefaultGroup = "239.255.66.99:7789"
+
+	// pingProtocol is handled by the backend itself.
+	pingProtocol = "brig/lan-ping"
+)
+
+var (
+	// ErrOffline is returned when an operation needs the local network,
+	// but Disconnect() was called before.
+	ErrOffline = errors.New("lan backend is offline")
+
+	// ErrNoRoute is returned by Dial() when no peer on the local network
+	// answered for an addr and no other backend could be used.
+	ErrNoRoute = errors.New("no route to peer on the local network")
+)
+
+// Options can be used to configure the behaviour of the backend.
+type Options struct {
+	// Group is the multicast group (ip:port) to announce and resolve names in.
+	// If empty, DefaultGroup is used.
+	Group string
+
+	// Port is the TCP port to accept connections on. 0 means a random port.
+	Port int
+
+	// Exclusive disables using the wrapped backend for anything net related.
+	// Identity() is still taken from it, if there is one.
+	Exclusive bool
+
+	// ID is used as our addr, if there is no wrapped backend.
+	ID string
+
+	// ResolveTimeout is how long ResolveName() and Dial() wait for answers
+	// when the passed context has no deadline. Defaults to one second.
+	ResolveTimeout time.Duration
+}
+
+// Backend implements net/backend.Backend for the local network.
+type Backend struct {
+	mu sync.Mutex
+
+	inner netBackend.Backend
+	opts  Options
+	group *net.UDPAddr
+
+	// state that is only valid while being online:
+	isOnline bool
+	udpRecv  *net.UDPConn
+	udpSend  *net.UDPConn
+	tcpLst   net.Listener
+
+	// names we published and answer queries for:
+	names map[string]bool
+
+	// addr -> tcp endpoint ("ip:port") of peers we heard of:
+	endpoints map[string]string
+
+	// protocol -> listener returned by Listen():
+	listeners map[string]*muxListener
+
+	// subscribers to incoming announcements:
+	waiters map[chan announcement]bool
+}
+
+// NewBackend returns a new LAN backend that wraps `inner`, which may be nil.
+// The backend is online right away, as if Connect() was called.
+func NewBackend(inner netBackend.Backend, opts Options) (*Backend, error) {
+	if opts.Group == "" {
+		opts.Group = DefaultGroup
+	}
+
+	if opts.ResolveTimeout <= 0 {
+		opts.ResolveTimeout = time.Second
+	}
+
+	if inner == nil && opts.ID == "" {
+		return nil, fmt.Errorf("need either an inner backend or an id")
+	}
+
+	group, err := net.ResolveUDPAddr("udp4", opts.Group)
+	if err != nil {
+		return nil, err
+	}
+
+	if !group.IP.IsMulticast() {
+		return nil, fmt.Errorf("not a multicast address: %s", opts.Group)
+	}
+
+	lb := &Backend{
+		inner:     inner,
+		opts:      opts,
+		group:     group,
+		names:     make(map[string]bool),
+		endpoints: make(map[string]string),
+		listeners: make(map[string]*muxListener),
+		waiters:   make(map[chan announcement]bool),
+	}
+
+	if err := lb.start(); err != nil {
+		return nil, err
+	}
+
+	return lb, nil
+}
+
+func (lb *Backend) useInner() bool {
+	return lb.inner != nil && !lb.opts.Exclusive
+}
+
+func (lb *Backend) start() error {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if lb.isOnline {
+		return nil
+	}
+
+	udpRecv, err := net.ListenMulticastUDP("udp4", nil, lb.group)
+	if err != nil {
+		return err
+	}
+
+	// ListenMulticastUDP disables multicast loopback for its own socket.
+	// Use a separate socket for sending, so peers on the same host see us.
+	udpSend, err := net.DialUDP("udp4", nil, lb.group)
+	if err != nil {
+		udpRecv.Close()
+		return err
+	}
+
+	tcpLst, err := net.Listen("tcp", fmt.Sprintf(":%d", lb.opts.Port))
+	if err != nil {
+		udpRecv.Close()
+		udpSend.Close()
+		return err
+	}
+
+	lb.udpRecv = udpRecv
+	lb.udpSend = udpSend
+	lb.tcpLst = tcpLst
+	lb.isOnline = true
+
+	log.Debugf("lan: listening on %s (group %s)", tcpLst.Addr(), lb.group)
+	go lb.discoveryLoop(udpRecv)
+	go lb.acceptLoop(tcpLst)
+	return nil
+}
+
+func (lb *Backend) stop() error {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if !lb.isOnline {
+		return nil
+	}
+
+	lb.isOnline = false
+	lb.endpoints = make(map[string]string)
+
+	// Closing the sockets will also stop the loops.
+	errs := []error{
+		lb.udpRecv.Close(),
+		lb.udpSend.Close(),
+		lb.tcpLst.Close(),
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (lb *Backend) tcpPort() int {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if lb.tcpLst == nil {
+		return 0
+	}
+
+	return lb.tcpLst.Addr().(*net.TCPAddr).Port
+}
+
+// Connect will start announcing and resolving names on the local network
+// again. The wrapped backend (if any) is connected too.
+func (lb *Backend) Connect() error {
+	if err := lb.start(); err != nil {
+		return err
+	}
+
+	if lb.inner != nil {
+		return lb.inner.Connect()
+	}
+
+	return nil
+}
+
+// Disconnect will stop all local network activity
+// and disconnect the wrapped backend (if any).
+func (lb *Backend) Disconnect() error {
+	if err := lb.stop(); err != nil {
+		return err
+	}
+
+	if lb.inner != nil {
+		return lb.inner.Disconnect()
+	}
+
+	return nil
+}
+
+// Close is like Disconnect, but leaves the wrapped backend alone.
+func (lb *Backend) Close() error {
+	return lb.stop()
+}
+
+// IsOnline returns true if the local network can be used
+// and the wrapped backend (if any) is online.
+func (lb *Backend) IsOnline() bool {
+	lb.mu.Lock()
+	isOnline := lb.isOnline
+	lb.mu.Unlock()
+
+	if lb.inner != nil {
+		return isOnline && lb.inner.IsOnline()
+	}
+
+	return isOnline
+}
+
+// Identity returns the identity of the wrapped backend or,
+// if there is none, the id passed in the options.
+func (lb *Backend) Identity() (peer.Info, error) {
+	if lb.inner != nil {
+		return lb.inner.Identity()
+	}
+
+	return peer.Info{
+		Name: "lan",
+		Addr: lb.opts.ID,
+	}, nil
+}
+
+func (lb *Backend) ownAddr() (string, error) {
+	self, err := lb.Identity()
+	if err != nil {
+		return "", err
+	}
+
+	return self.Addr, nil
+}
+
+// PublishName will answer queries for `name` on the local network from now on.
+// It is also published with the wrapped backend, if it is used.
+func (lb *Backend) PublishName(name string) error {
+	lb.mu.Lock()
+	if !lb.isOnline {
+		lb.mu.Unlock()
+		return ErrOffline
+	}
+
+	lb.names[name] = true
+	lb.mu.Unlock()
+
+	// Tell everyone who's listening right away, so they can fill their cache:
+	if err := lb.announce(name); err != nil {
+		log.Warningf("lan: failed to announce `%s`: %v", name, err)
+	}
+
+	if lb.useInner() {
+		return lb.inner.PublishName(name)
+	}
+
+	return nil
+}
+
+func (lb *Backend) resolveContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, lb.opts.ResolveTimeout)
+}
+
+// ResolveName asks the local network who is known as `name`.
+// Results of the wrapped backend are merged in, if it is used.
+func (lb *Backend) ResolveName(ctx context.Context, name string) ([]peer.Info, error) {
+	ctx, cancel := lb.resolveContext(ctx)
+	defer cancel()
+
+	answers := lb.subscribe()
+	defer lb.unsubscribe(answers)
+
+	if err := lb.send(message{Type: msgQuery, Name: name}); err != nil {
+		return nil, err
+	}
+
+	// Resolve the inner backend in parallel; it's usually a lot slower.
+	type innerResult struct {
+		infos []peer.Info
+		err   error
+	}
+
+	innerCh := make(chan innerResult, 1)
+	if lb.useInner() {
+		go func() {
+			infos, err := lb.inner.ResolveName(ctx, name)
+			innerCh <- innerResult{infos: infos, err: err}
+		}()
+	} else {
+		close(innerCh)
+	}
+
+	seen := make(map[string]bool)
+	infos := []peer.Info{}
+	addInfo := func(info peer.Info) {
+		if !seen[info.Addr] {
+			seen[info.Addr] = true
+			infos = append(infos, info)
+		}
+	}
+
+	var innerErr error
+	for {
+		select {
+		case ann := <-answers:
+			if ann.Name == name {
+				addInfo(peer.Info{Name: peer.Name(name), Addr: ann.Addr})
+			}
+		case res, ok := <-innerCh:
+			if !ok {
+				// Avoid selecting the closed channel over and over.
+				innerCh = nil
+				continue
+			}
+
+			innerCh = nil
+			innerErr = res.err
+			for _, info := range res.infos {
+				addInfo(info)
+			}
+		case <-ctx.Done():
+			if len(infos) == 0 && innerErr != nil {
+				return nil, innerErr
+			}
+
+			return infos, nil
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package lan
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	netBackend "github.com/sahib/brig/net/backend"
+	"github.com/sahib/brig/net/mock"
+	"github.com/sahib/brig/util"
+	"github.com/stretchr/testify/require"
+)
+
+func testGroup() string {
+	return fmt.Sprintf("239.255.66.99:%d", util.FindFreePort())
+}
+
+func withBackends(t *testing.T, fn func(a, b *Backend)) {
+	group := testGroup()
+	opts := func(id string) Options {
+		return Options{
+			Group:          group,
+			ID:             id,
+			ResolveTimeout: 500 * time.Millisecond,
+		}
+	}
+
+	a, err := NewBackend(nil, opts("alice-id"))
+	require.Nil(t, err)
+	defer a.Close()
+
+	b, err := NewBackend(nil, opts("bob-id"))
+	require.Nil(t, err)
+	defer b.Close()
+
+	fn(a, b)
+}
+
+func TestResolveName(t *testing.T) {
+	withBackends(t, func(a, b *Backend) {
+		require.Nil(t, a.PublishName("alice"))
+		require.Nil(t, b.PublishName("bob"))
+
+		infos, err := a.ResolveName(context.Background(), "bob")
+		require.Nil(t, err)
+		require.Len(t, infos, 1)
+		require.Equal(t, "bob-id", infos[0].Addr)
+		require.Equal(t, "bob", string(infos[0].Name))
+
+		infos, err = b.ResolveName(context.Background(), "charlie")
+		require.Nil(t, err)
+		require.Empty(t, infos)
+	})
+}
+
+func TestDialAndListen(t *testing.T) {
+	withBackends(t, func(a, b *Backend) {
+		lst, err := b.Listen("brig/test")
+		require.Nil(t, err)
+		defer lst.Close()
+
+		go func() {
+			conn, err := lst.Accept()
+			if err != nil {
+				return
+			}
+
+			defer conn.Close()
+			io.Copy(conn, conn)
+		}()
+
+		// Nobody listens on other protocols:
+		conn, err := a.Dial("bob-id", "", "brig/other")
+		require.Nil(t, err)
+		_, err = conn.Read(make([]byte, 1))
+		require.Equal(t, io.EOF, err)
+		require.Nil(t, conn.Close())
+
+		conn, err = a.Dial("bob-id", "", "brig/test")
+		require.Nil(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("hello"))
+		require.Nil(t, err)
+
+		buf := make([]byte, 5)
+		_, err = io.ReadFull(conn, buf)
+		require.Nil(t, err)
+		require.Equal(t, []byte("hello"), buf)
+
+		_, err = a.Dial("charlie-id", "", "brig/test")
+		require.Equal(t, ErrNoRoute, err)
+	})
+}
+
+func TestPing(t *testing.T) {
+	withBackends(t, func(a, b *Backend) {
+		pinger, err := a.Ping("bob-id")
+		require.Nil(t, err)
+		defer pinger.Close()
+
+		for idx := 0; idx < 50 && pinger.Err() == netBackend.ErrWaiting; idx++ {
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		require.Nil(t, pinger.Err())
+		require.False(t, pinger.LastSeen().IsZero())
+		require.True(t, pinger.Roundtrip() > 0)
+	})
+}
+
+func TestOffline(t *testing.T) {
+	withBackends(t, func(a, b *Backend) {
+		require.Nil(t, b.Disconnect())
+		require.False(t, b.IsOnline())
+		require.Equal(t, ErrOffline, b.PublishName("bob"))
+
+		_, err := a.Dial("bob-id", "", "brig/test")
+		require.Equal(t, ErrNoRoute, err)
+
+		require.Nil(t, b.Connect())
+		require.True(t, b.IsOnline())
+
+		_, err = a.Dial("bob-id", "", "brig/test")
+		require.Nil(t, err)
+	})
+}
+
+func TestFallback(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "brig-lan-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	group := testGroup()
+	aliInner := mock.NewNetBackend(tmpDir, "alice")
+	bobInner := mock.NewNetBackend(tmpDir, "bob")
+
+	ali, err := NewBackend(aliInner, Options{Group: group})
+	require.Nil(t, err)
+	defer ali.Close()
+
+	// Bob is only reachable via the mock network:
+	bobAddr, err := bobInner.Identity()
+	require.Nil(t, err)
+
+	bobLst, err := bobInner.Listen("brig/test")
+	require.Nil(t, err)
+	defer bobLst.Close()
+
+	go func() {
+		conn, err := bobLst.Accept()
+		if err != nil {
+			return
+		}
+
+		conn.Write([]byte("x"))
+		conn.Close()
+	}()
+
+	conn, err := ali.Dial(bobAddr.Addr, "", "brig/test")
+	require.Nil(t, err)
+	data, err := ioutil.ReadAll(conn)
+	require.Nil(t, err)
+	require.Equal(t, []byte("x"), data)
+	require.Nil(t, conn.Close())
+
+	// ...unless the fallback is not allowed:
+	exclusive, err := NewBackend(aliInner, Options{
+		Group:          group,
+		Exclusive:      true,
+		ResolveTimeout: 200 * time.Millisecond,
+	})
+	require.Nil(t, err)
+	defer exclusive.Close()
+
+	_, err = exclusive.Dial(bobAddr.Addr, "", "brig/test")
+	require.Equal(t, ErrNoRoute, err)
+
+	// Our identity is the one of the wrapped backend:
+	self, err := ali.Identity()
+	require.Nil(t, err)
+	innerSelf, err := aliInner.Identity()
+	require.Nil(t, err)
+	require.Equal(t, innerSelf, self)
+}
@@ -0,0 +1,34 @@
+package lan
+
+import (
+	"net"
+
+	netBackend "github.com/sahib/brig/net/backend"
+	log "github.com/sirupsen/logrus"
+)
+
+// Ping returns a pinger for `addr` that checks if the peer
+// is reachable over the local network or the wrapped backend.
+func (lb *Backend) Ping(addr string) (netBackend.Pinger, error) {
+	if !lb.IsOnline() {
+		return nil, ErrOffline
+	}
+
+	log.Debugf("lan: start ping »%s«", addr)
+
+	var fallback netBackend.Pinger
+	if lb.useInner() {
+		innerPinger, err := lb.inner.Ping(addr)
+		if err != nil {
+			log.Debugf("lan: fallback can not ping %s: %v", addr, err)
+		} else {
+			fallback = innerPinger
+		}
+	}
+
+	dial := func() (net.Conn, error) {
+		return lb.dialLAN(addr, pingProtocol)
+	}
+
+	return netBackend.NewPinger(dial, fallback), nil
+}
@@ -0,0 +1,288 @@
+package lan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	netBackend "github.com/sahib/brig/net/backend"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// headerPrefix starts the single line every connection begins with.
+	// The rest of the line is the protocol name.
+	headerPrefix = "brig-lan "
+
+	// maxHeaderSize limits the header line, including the newline.
+	maxHeaderSize = 256
+
+	// dialTimeout is the timeout for establishing a TCP connection.
+	dialTimeout = 5 * time.Second
+)
+
+var (
+	errListenerClosed = errors.New("listener was closed")
+)
+
+func writeHeader(conn net.Conn, protocol string) error {
+	if strings.ContainsAny(protocol, "\r\n") {
+		return fmt.Errorf("invalid protocol: %q", protocol)
+	}
+
+	_, err := io.WriteString(conn, headerPrefix+protocol+"\n")
+	return err
+}
+
+// readHeader reads the header byte by byte, so nothing
+// of the following data is consumed.
+func readHeader(conn net.Conn) (string, error) {
+	buf := make([]byte, 0, maxHeaderSize)
+	oneByte := make([]byte, 1)
+
+	for len(buf) < maxHeaderSize {
+		if _, err := io.ReadFull(conn, oneByte); err != nil {
+			return "", err
+		}
+
+		if oneByte[0] == '\n' {
+			line := string(buf)
+			if !strings.HasPrefix(line, headerPrefix) {
+				return "", fmt.Errorf("bad header: %q", line)
+			}
+
+			return strings.TrimPrefix(line, headerPrefix), nil
+		}
+
+		buf = append(buf, oneByte[0])
+	}
+
+	return "", fmt.Errorf("header is too long")
+}
+
+func (lb *Backend) acceptLoop(lst net.Listener) {
+	for {
+		conn, err := lst.Accept()
+		if err != nil {
+			log.Debugf("lan: accept loop stopped: %v", err)
+			return
+		}
+
+		go lb.handleConn(conn)
+	}
+}
+
+func (lb *Backend) handleConn(conn net.Conn) {
+	if err := conn.SetReadDeadline(time.Now().Add(dialTimeout)); err != nil {
+		conn.Close()
+		return
+	}
+
+	protocol, err := readHeader(conn)
+	if err != nil {
+		log.Debugf("lan: dropping connection from %s: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return
+	}
+
+	if protocol == pingProtocol {
+		netBackend.EchoPing(conn)
+		return
+	}
+
+	lb.mu.Lock()
+	lst, ok := lb.listeners[protocol]
+	lb.mu.Unlock()
+
+	if !ok || !lst.push(conn) {
+		log.Debugf("lan: nobody listens on `%s`", protocol)
+		conn.Close()
+	}
+}
+
+// dialLAN opens a TCP connection to `peerAddr`, asking the local
+// network for its endpoint if we do not know it already.
+func (lb *Backend) dialLAN(peerAddr, protocol string) (net.Conn, error) {
+	endpoint, ok := lb.lookup(peerAddr)
+	if !ok {
+		var err error
+		endpoint, err = lb.resolveAddr(peerAddr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := net.DialTimeout("tcp", endpoint, dialTimeout)
+	if err != nil {
+		// The peer might have changed its ip or port; ask again next time.
+		lb.forget(peerAddr)
+		return nil, err
+	}
+
+	if err := writeHeader(conn, protocol); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// resolveAddr asks the local network for the endpoint of `peerAddr`.
+func (lb *Backend) resolveAddr(peerAddr string) (string, error) {
+	ctx, cancel := lb.resolveContext(context.Background())
+	defer cancel()
+
+	answers := lb.subscribe()
+	defer lb.unsubscribe(answers)
+
+	if err := lb.send(message{Type: msgQuery, Addr: peerAddr}); err != nil {
+		return "", err
+	}
+
+	for {
+		select {
+		case ann := <-answers:
+			if ann.Addr == peerAddr {
+				return ann.Endpoint, nil
+			}
+		case <-ctx.Done():
+			return "", ErrNoRoute
+		}
+	}
+}
+
+// Dial connects to `peerAddr` over the local network. If it can not be
+// found there, the wrapped backend is used (unless running exclusively).
+func (lb *Backend) Dial(peerAddr, fingerprint, protocol string) (net.Conn, error) {
+	conn, err := lb.dialLAN(peerAddr, protocol)
+	if err == nil {
+		return conn, nil
+	}
+
+	if !lb.useInner() {
+		return nil, err
+	}
+
+	log.Debugf("lan: no direct route to %s (%v); using fallback", peerAddr, err)
+	return lb.inner.Dial(peerAddr, fingerprint, protocol)
+}
+
+// Listen returns a listener that yields connections for `protocol`
+// from the local network and the wrapped backend.
+func (lb *Backend) Listen(protocol string) (net.Listener, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if _, ok := lb.listeners[protocol]; ok {
+		return nil, fmt.Errorf("already listening on `%s`", protocol)
+	}
+
+	lst := &muxListener{
+		lb:       lb,
+		protocol: protocol,
+		connCh:   make(chan net.Conn),
+		closeCh:  make(chan struct{}),
+	}
+
+	if lb.useInner() {
+		// Do not fail if the wrapped backend can't listen right now;
+		// we can still offer the local network in that case.
+		innerLst, err := lb.inner.Listen(protocol)
+		if err != nil {
+			log.Warningf("lan: fallback backend can not listen: %v", err)
+		} else {
+			lst.inner = innerLst
+			go lst.forward(innerLst)
+		}
+	}
+
+	lb.listeners[protocol] = lst
+	return lst, nil
+}
+
+type lanAddr struct {
+	protocol string
+}
+
+func (la lanAddr) Network() string {
+	return "lan"
+}
+
+func (la lanAddr) String() string {
+	return la.protocol
+}
+
+// muxListener yields all connections for a single protocol.
+type muxListener struct {
+	lb       *Backend
+	protocol string
+	inner    net.Listener
+	connCh   chan net.Conn
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+func (ml *muxListener) push(conn net.Conn) bool {
+	select {
+	case ml.connCh <- conn:
+		return true
+	case <-ml.closeCh:
+		return false
+	}
+}
+
+func (ml *muxListener) forward(lst net.Listener) {
+	for {
+		conn, err := lst.Accept()
+		if err != nil {
+			log.Debugf("lan: fallback listener stopped: %v", err)
+			return
+		}
+
+		if !ml.push(conn) {
+			conn.Close()
+			return
+		}
+	}
+}
+
+func (ml *muxListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-ml.connCh:
+		return conn, nil
+	case <-ml.closeCh:
+		return nil, errListenerClosed
+	}
+}
+
+func (ml *muxListener) Addr() net.Addr {
+	return lanAddr{protocol: ml.protocol}
+}
+
+func (ml *muxListener) Close() error {
+	var err error
+	ml.closeOnce.Do(func() {
+		close(ml.closeCh)
+
+		ml.lb.mu.Lock()
+		delete(ml.lb.listeners, ml.protocol)
+		ml.lb.mu.Unlock()
+
+		if ml.inner != nil {
+			err = ml.inner.Close()
+		}
+	})
+
+	return err
+}
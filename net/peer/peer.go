@@ -204,7 +204,14 @@ func (fp Fingerprint) PubKeyMatches(pubKeyData []byte) bool {
 ///////////////////////
 
 // Info is a pair of addr and a peer name.
+// Backends that can tell who published a name also set the fingerprint
+// of the peer; it is empty otherwise.
 type Info struct {
-	Name Name
-	Addr string
+	Name        Name
+	Addr        string
+	Fingerprint Fingerprint
+
+	// Verified is true if Fingerprint was checked against
+	// a fingerprint we already knew for Name.
+	Verified bool
 }
@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/sahib/brig/metrics"
 	"github.com/sahib/brig/net/backend"
 	"github.com/sahib/brig/repo"
 	log "github.com/sirupsen/logrus"
@@ -80,6 +81,9 @@ func (pm *PingMap) doUpdate() {
 
 		// Reaching this point means that the pinger
 		// seems to work and did not error out.
+		if rmt, err := pm.rp.Remotes.RemoteByAddr(addr); err == nil {
+			metrics.PeerRoundtrip.With(rmt.Name).Set(pinger.Roundtrip().Seconds())
+		}
 	}
 }
 
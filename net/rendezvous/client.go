@@ -0,0 +1,454 @@
+package rendezvous
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	netBackend "github.com/sahib/brig/net/backend"
+	"github.com/sahib/brig/net/peer"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// pingProtocol is handled by the backend itself.
+	pingProtocol = "brig/rendezvous-ping"
+
+	// dialTimeout is the timeout for connecting to the server.
+	dialTimeout = 5 * time.Second
+)
+
+var (
+	// ErrOffline is returned when Disconnect() was called before.
+	ErrOffline = errors.New("rendezvous backend is offline")
+
+	// RefreshInterval is the time between registering our names again.
+	// It should be a good deal shorter than the server's EntryTTL.
+	RefreshInterval = time.Hour
+
+	// ReconnectInterval is the time to wait before trying to
+	// listen again when the control connection was lost.
+	ReconnectInterval = 5 * time.Second
+)
+
+// Options can be used to configure the backend.
+type Options struct {
+	// Server is the addr (host:port) of the rendezvous server.
+	Server string
+
+	// ID is used as our addr, if there is no wrapped backend.
+	ID string
+
+	// Fingerprints returns the fingerprints we already know for `name`,
+	// usually from the remote list. When resolving `name`, entries made
+	// by other keys are dropped then. May be nil.
+	Fingerprints func(name string) []peer.Fingerprint
+}
+
+// Backend implements net/backend.Backend by using a rendezvous server.
+// Names are only published to and resolved with the server. Connections
+// are made by the wrapped backend (if any) and are relayed through the
+// server if that fails.
+type Backend struct {
+	mu sync.Mutex
+
+	inner  netBackend.Backend
+	signer Signer
+	opts   Options
+
+	isOnline bool
+	cancel   func()
+
+	// names we published and need to refresh:
+	names map[string]bool
+
+	// protocol -> listener returned by Listen():
+	listeners map[string]*muxListener
+}
+
+// NewBackend returns a new rendezvous client that wraps `inner`, which may
+// be nil. Entries are signed with `signer`. The backend is online right away.
+func NewBackend(inner netBackend.Backend, signer Signer, opts Options) (*Backend, error) {
+	if opts.Server == "" {
+		return nil, fmt.Errorf("no rendezvous server given")
+	}
+
+	if inner == nil && opts.ID == "" {
+		return nil, fmt.Errorf("need either an inner backend or an id")
+	}
+
+	rb := &Backend{
+		inner:     inner,
+		signer:    signer,
+		opts:      opts,
+		names:     make(map[string]bool),
+		listeners: make(map[string]*muxListener),
+	}
+
+	rb.start()
+	return rb, nil
+}
+
+func (rb *Backend) start() {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if rb.isOnline {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rb.cancel = cancel
+	rb.isOnline = true
+
+	go rb.controlLoop(ctx)
+	go rb.refreshLoop(ctx)
+}
+
+func (rb *Backend) stop() {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if !rb.isOnline {
+		return
+	}
+
+	rb.cancel()
+	rb.cancel = nil
+	rb.isOnline = false
+}
+
+func (rb *Backend) online() bool {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	return rb.isOnline
+}
+
+func (rb *Backend) dialServer() (net.Conn, error) {
+	if !rb.online() {
+		return nil, ErrOffline
+	}
+
+	return net.DialTimeout("tcp", rb.opts.Server, dialTimeout)
+}
+
+func (rb *Backend) newEntry(name string) (*Entry, error) {
+	self, err := rb.Identity()
+	if err != nil {
+		return nil, err
+	}
+
+	return newEntry(name, self.Addr, rb.signer)
+}
+
+func (rb *Backend) register(name string) error {
+	entry, err := rb.newEntry(name)
+	if err != nil {
+		return err
+	}
+
+	conn, err := rb.dialServer()
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = roundtrip(conn, request{Op: opRegister, Entry: entry})
+	return err
+}
+
+func (rb *Backend) refreshLoop(ctx context.Context) {
+	tckr := time.NewTicker(RefreshInterval)
+	defer tckr.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tckr.C:
+			rb.mu.Lock()
+			names := []string{}
+			for name := range rb.names {
+				names = append(names, name)
+			}
+			rb.mu.Unlock()
+
+			for _, name := range names {
+				if err := rb.register(name); err != nil {
+					log.Warningf("rendezvous: failed to refresh `%s`: %v", name, err)
+				}
+			}
+		}
+	}
+}
+
+// controlLoop keeps a control connection to the server open,
+// so other peers can reach us via relay.
+func (rb *Backend) controlLoop(ctx context.Context) {
+	for {
+		if err := rb.control(ctx); err != nil {
+			log.Debugf("rendezvous: control connection failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(ReconnectInterval):
+		}
+	}
+}
+
+func (rb *Backend) control(ctx context.Context) error {
+	entry, err := rb.newEntry("")
+	if err != nil {
+		return err
+	}
+
+	conn, err := rb.dialServer()
+	if err != nil {
+		return err
+	}
+
+	// Make sure the connection gets closed when going offline:
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-connCtx.Done()
+		conn.Close()
+	}()
+
+	if _, err := roundtrip(conn, request{Op: opListen, Entry: entry}); err != nil {
+		return err
+	}
+
+	for {
+		push := response{}
+		if err := readMsg(conn, &push); err != nil {
+			return err
+		}
+
+		go rb.acceptRelay(push.Session, push.Protocol)
+	}
+}
+
+func (rb *Backend) acceptRelay(sessionID, protocol string) {
+	conn, err := rb.dialServer()
+	if err != nil {
+		log.Debugf("rendezvous: failed to accept relay: %v", err)
+		return
+	}
+
+	if _, err := roundtrip(conn, request{Op: opAccept, Session: sessionID}); err != nil {
+		log.Debugf("rendezvous: failed to accept relay: %v", err)
+		conn.Close()
+		return
+	}
+
+	if protocol == pingProtocol {
+		netBackend.EchoPing(conn)
+		return
+	}
+
+	rb.mu.Lock()
+	lst, ok := rb.listeners[protocol]
+	rb.mu.Unlock()
+
+	if !ok || !lst.push(conn) {
+		log.Debugf("rendezvous: nobody listens on `%s`", protocol)
+		conn.Close()
+	}
+}
+
+// Connect will register with the server again.
+// The wrapped backend (if any) is connected too.
+func (rb *Backend) Connect() error {
+	rb.start()
+
+	if rb.inner != nil {
+		return rb.inner.Connect()
+	}
+
+	return nil
+}
+
+// Disconnect will stop talking to the server
+// and disconnect the wrapped backend (if any).
+func (rb *Backend) Disconnect() error {
+	rb.stop()
+
+	if rb.inner != nil {
+		return rb.inner.Disconnect()
+	}
+
+	return nil
+}
+
+// Close is like Disconnect, but leaves the wrapped backend alone.
+func (rb *Backend) Close() error {
+	rb.stop()
+	return nil
+}
+
+// IsOnline returns true if we were not disconnected
+// and the wrapped backend (if any) is online.
+func (rb *Backend) IsOnline() bool {
+	if rb.inner != nil {
+		return rb.online() && rb.inner.IsOnline()
+	}
+
+	return rb.online()
+}
+
+// Identity returns the identity of the wrapped backend or,
+// if there is none, the id passed in the options.
+func (rb *Backend) Identity() (peer.Info, error) {
+	if rb.inner != nil {
+		return rb.inner.Identity()
+	}
+
+	return peer.Info{
+		Name: "rendezvous",
+		Addr: rb.opts.ID,
+	}, nil
+}
+
+// PublishName registers `name` with the server.
+func (rb *Backend) PublishName(name string) error {
+	if err := rb.register(name); err != nil {
+		return err
+	}
+
+	rb.mu.Lock()
+	rb.names[name] = true
+	rb.mu.Unlock()
+	return nil
+}
+
+// ResolveName asks the server who is known as `name`.
+// Entries with a bad signature are skipped, as are entries of
+// other keys than the ones Options.Fingerprints knows for `name`.
+func (rb *Backend) ResolveName(ctx context.Context, name string) ([]peer.Info, error) {
+	conn, err := rb.dialServer()
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := roundtrip(conn, request{Op: opResolve, Name: name})
+	if err != nil {
+		return nil, err
+	}
+
+	known := []peer.Fingerprint{}
+	if rb.opts.Fingerprints != nil {
+		known = rb.opts.Fingerprints(name)
+	}
+
+	infos := []peer.Info{}
+	for _, entry := range resp.Entries {
+		if entry.Name != name {
+			continue
+		}
+
+		// Do not trust the server (or whoever registered there):
+		if err := entry.VerifyFingerprint(known); err != nil {
+			log.Warningf("rendezvous: skipping entry for %s: %v", entry.Addr, err)
+			continue
+		}
+
+		info := entry.Info()
+		info.Verified = len(known) > 0
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+func (rb *Backend) dialRelay(peerAddr, protocol string) (net.Conn, error) {
+	conn, err := rb.dialServer()
+	if err != nil {
+		return nil, err
+	}
+
+	// The server waits a while for the other side; wait a bit longer.
+	if err := conn.SetDeadline(time.Now().Add(RelayTimeout + dialTimeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if _, err := roundtrip(conn, request{
+		Op:       opDial,
+		Addr:     peerAddr,
+		Protocol: protocol,
+	}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// Dial connects to `peerAddr` with the wrapped backend and
+// relays the connection through the server if that fails.
+func (rb *Backend) Dial(peerAddr, fingerprint, protocol string) (net.Conn, error) {
+	if rb.inner != nil {
+		conn, err := rb.inner.Dial(peerAddr, fingerprint, protocol)
+		if err == nil {
+			return conn, nil
+		}
+
+		log.Debugf("rendezvous: direct dial to %s failed (%v); relaying", peerAddr, err)
+	}
+
+	return rb.dialRelay(peerAddr, protocol)
+}
+
+// Listen returns a listener that yields connections for `protocol`
+// from the wrapped backend and relayed by the server.
+func (rb *Backend) Listen(protocol string) (net.Listener, error) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if _, ok := rb.listeners[protocol]; ok {
+		return nil, fmt.Errorf("already listening on `%s`", protocol)
+	}
+
+	lst := &muxListener{
+		rb:       rb,
+		protocol: protocol,
+		connCh:   make(chan net.Conn),
+		closeCh:  make(chan struct{}),
+	}
+
+	if rb.inner != nil {
+		// Relaying still works if the wrapped backend can't listen.
+		innerLst, err := rb.inner.Listen(protocol)
+		if err != nil {
+			log.Warningf("rendezvous: wrapped backend can not listen: %v", err)
+		} else {
+			lst.inner = innerLst
+			go lst.forward(innerLst)
+		}
+	}
+
+	rb.listeners[protocol] = lst
+	return lst, nil
+}
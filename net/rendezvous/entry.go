@@ -0,0 +1,136 @@
+package rendezvous
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sahib/brig/net/peer"
+	"golang.org/x/crypto/openpgp"
+)
+
+// Signer is able to sign data with a private key.
+// repo.Keyring implements this interface.
+type Signer interface {
+	// OwnPubKey returns the public key that can check our signatures.
+	OwnPubKey() ([]byte, error)
+
+	// Sign returns a detached signature of `data`.
+	Sign(data []byte) ([]byte, error)
+}
+
+// Entry is what peers register with the rendezvous server.
+// It is signed by the peer, so the server can not forge entries.
+type Entry struct {
+	// Name is the published name (might be just a part of the full name).
+	Name string `json:"name"`
+
+	// Addr is the identity addr of the peer.
+	Addr string `json:"addr"`
+
+	// PubKey is the public key of the peer that made the signature.
+	PubKey []byte `json:"pubkey"`
+
+	// Time is the unix timestamp of when the entry was created.
+	Time int64 `json:"time"`
+
+	// Signature is a detached signature over all other fields.
+	Signature []byte `json:"signature,omitempty"`
+}
+
+func newEntry(name, addr string, signer Signer) (*Entry, error) {
+	pubKey, err := signer.OwnPubKey()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{
+		Name:   name,
+		Addr:   addr,
+		PubKey: pubKey,
+		Time:   time.Now().Unix(),
+	}
+
+	payload, err := entry.payload()
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Signature, err = signer.Sign(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (en *Entry) payload() ([]byte, error) {
+	unsigned := *en
+	unsigned.Signature = nil
+	return json.Marshal(unsigned)
+}
+
+// Fingerprint returns the fingerprint of the peer that registered the entry.
+func (en *Entry) Fingerprint() peer.Fingerprint {
+	return peer.BuildFingerprint(en.Addr, en.PubKey)
+}
+
+// Info returns the entry as peer.Info.
+func (en *Entry) Info() peer.Info {
+	return peer.Info{
+		Name:        peer.Name(en.Name),
+		Addr:        en.Addr,
+		Fingerprint: en.Fingerprint(),
+	}
+}
+
+// Verify checks if the entry was signed by the owner of its public key.
+// Note that anyone can make a valid entry for any name; use
+// VerifyFingerprint if you know who should have published it.
+func (en *Entry) Verify() error {
+	if en.Addr == "" {
+		return fmt.Errorf("entry has no addr")
+	}
+
+	ents, err := openpgp.ReadKeyRing(bytes.NewReader(en.PubKey))
+	if err != nil {
+		return fmt.Errorf("bad public key: %v", err)
+	}
+
+	payload, err := en.payload()
+	if err != nil {
+		return err
+	}
+
+	if _, err := openpgp.CheckDetachedSignature(
+		ents,
+		bytes.NewReader(payload),
+		bytes.NewReader(en.Signature),
+	); err != nil {
+		return fmt.Errorf("bad signature: %v", err)
+	}
+
+	return nil
+}
+
+// VerifyFingerprint is like Verify, but also checks that the entry
+// was made by the owner of one of the `known` fingerprints.
+// If `known` is empty, this is the same as Verify.
+func (en *Entry) VerifyFingerprint(known []peer.Fingerprint) error {
+	if err := en.Verify(); err != nil {
+		return err
+	}
+
+	if len(known) == 0 {
+		return nil
+	}
+
+	for _, fingerprint := range known {
+		if fingerprint.PubKeyMatches(en.PubKey) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("entry was made by an unknown key")
+}
@@ -0,0 +1,90 @@
+package rendezvous
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	errListenerClosed = errors.New("listener was closed")
+)
+
+type rendezvousAddr struct {
+	protocol string
+}
+
+func (ra rendezvousAddr) Network() string {
+	return "rendezvous"
+}
+
+func (ra rendezvousAddr) String() string {
+	return ra.protocol
+}
+
+// muxListener yields all connections for a single protocol.
+type muxListener struct {
+	rb       *Backend
+	protocol string
+	inner    net.Listener
+	connCh   chan net.Conn
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+func (ml *muxListener) push(conn net.Conn) bool {
+	select {
+	case ml.connCh <- conn:
+		return true
+	case <-ml.closeCh:
+		return false
+	}
+}
+
+func (ml *muxListener) forward(lst net.Listener) {
+	for {
+		conn, err := lst.Accept()
+		if err != nil {
+			log.Debugf("rendezvous: wrapped listener stopped: %v", err)
+			return
+		}
+
+		if !ml.push(conn) {
+			conn.Close()
+			return
+		}
+	}
+}
+
+func (ml *muxListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-ml.connCh:
+		return conn, nil
+	case <-ml.closeCh:
+		return nil, errListenerClosed
+	}
+}
+
+func (ml *muxListener) Addr() net.Addr {
+	return rendezvousAddr{protocol: ml.protocol}
+}
+
+func (ml *muxListener) Close() error {
+	var err error
+	ml.closeOnce.Do(func() {
+		close(ml.closeCh)
+
+		ml.rb.mu.Lock()
+		delete(ml.rb.listeners, ml.protocol)
+		ml.rb.mu.Unlock()
+
+		if ml.inner != nil {
+			err = ml.inner.Close()
+		}
+	})
+
+	return err
+}
@@ -0,0 +1,34 @@
+package rendezvous
+
+import (
+	"net"
+
+	netBackend "github.com/sahib/brig/net/backend"
+	log "github.com/sirupsen/logrus"
+)
+
+// Ping returns a pinger for `addr` that checks if the peer
+// is reachable over the wrapped backend or the relay.
+func (rb *Backend) Ping(addr string) (netBackend.Pinger, error) {
+	if !rb.IsOnline() {
+		return nil, ErrOffline
+	}
+
+	log.Debugf("rendezvous: start ping »%s«", addr)
+
+	var fallback netBackend.Pinger
+	if rb.inner != nil {
+		innerPinger, err := rb.inner.Ping(addr)
+		if err != nil {
+			log.Debugf("rendezvous: fallback can not ping %s: %v", addr, err)
+		} else {
+			fallback = innerPinger
+		}
+	}
+
+	dial := func() (net.Conn, error) {
+		return rb.dialRelay(addr, pingProtocol)
+	}
+
+	return netBackend.NewPinger(dial, fallback), nil
+}
@@ -0,0 +1,110 @@
+package rendezvous
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// Every connection to the server starts with a single request line.
+// The server answers with a single response line. Afterwards the
+// connection is either closed, used to push relay requests to a
+// listening peer (opListen) or spliced to another peer (opDial, opAccept).
+//
+// Lines are read byte by byte, so that no data following the line is
+// consumed. This way the connection can be handed out as-is afterwards.
+const (
+	// opRegister stores a signed entry.
+	opRegister = "register"
+	// opResolve returns all entries for a name.
+	opResolve = "resolve"
+	// opListen makes the connection a control connection for an addr.
+	opListen = "listen"
+	// opDial asks the server to relay a connection to an addr.
+	opDial = "dial"
+	// opAccept answers a relay request pushed over a control connection.
+	opAccept = "accept"
+
+	// maxLineSize limits the size of a single request or response.
+	maxLineSize = 64 * 1024
+)
+
+var (
+	errLineTooLong = errors.New("line is too long")
+)
+
+type request struct {
+	Op       string `json:"op"`
+	Entry    *Entry `json:"entry,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Addr     string `json:"addr,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	Session  string `json:"session,omitempty"`
+}
+
+type response struct {
+	Error   string   `json:"error,omitempty"`
+	Entries []*Entry `json:"entries,omitempty"`
+
+	// Those are set when pushing relay requests to a listening peer:
+	Session  string `json:"session,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+}
+
+func readLine(r io.Reader) ([]byte, error) {
+	line := []byte{}
+	oneByte := make([]byte, 1)
+
+	for len(line) < maxLineSize {
+		if _, err := io.ReadFull(r, oneByte); err != nil {
+			return nil, err
+		}
+
+		if oneByte[0] == '\n' {
+			return line, nil
+		}
+
+		line = append(line, oneByte[0])
+	}
+
+	return nil, errLineTooLong
+}
+
+func readMsg(r io.Reader, msg interface{}) error {
+	line, err := readLine(r)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(line, msg)
+}
+
+func writeMsg(w io.Writer, msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// roundtrip sends `req` and returns the server's response.
+func roundtrip(conn net.Conn, req request) (*response, error) {
+	if err := writeMsg(conn, req); err != nil {
+		return nil, err
+	}
+
+	resp := &response{}
+	if err := readMsg(conn, resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, fmt.Errorf("rendezvous: %s", resp.Error)
+	}
+
+	return resp, nil
+}
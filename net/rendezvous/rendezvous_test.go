@@ -0,0 +1,233 @@
+package rendezvous
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alokmenghrajani/gpgeez"
+	netBackend "github.com/sahib/brig/net/backend"
+	"github.com/sahib/brig/net/peer"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+)
+
+// Do not use repo.Keyring, simply re-implement for this test's purpose.
+type testSigner struct {
+	prv, pub []byte
+}
+
+func newTestSigner(t *testing.T, owner string) *testSigner {
+	cfg := gpgeez.Config{Expiry: 0 * time.Second}
+	cfg.RSABits = 1024
+
+	comment := fmt.Sprintf("brig gpg key of %s", owner)
+	key, err := gpgeez.CreateKey(owner, comment, owner, &cfg)
+	require.Nil(t, err)
+
+	return &testSigner{prv: key.Secring(&cfg), pub: key.Keyring()}
+}
+
+func (ts *testSigner) OwnPubKey() ([]byte, error) {
+	return ts.pub, nil
+}
+
+func (ts *testSigner) Sign(data []byte) ([]byte, error) {
+	ents, err := openpgp.ReadKeyRing(bytes.NewReader(ts.prv))
+	if err != nil {
+		return nil, err
+	}
+
+	sigBuf := &bytes.Buffer{}
+	if err := openpgp.DetachSign(sigBuf, ents[0], bytes.NewReader(data), nil); err != nil {
+		return nil, err
+	}
+
+	return sigBuf.Bytes(), nil
+}
+
+func withServer(t *testing.T, fn func(srv *Server, ali, bob *Backend)) {
+	lst, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+
+	srv := NewServer(lst)
+	go srv.Serve()
+	defer srv.Close()
+
+	opts := func(id string) Options {
+		return Options{Server: lst.Addr().String(), ID: id}
+	}
+
+	ali, err := NewBackend(nil, newTestSigner(t, "alice"), opts("alice-id"))
+	require.Nil(t, err)
+	defer ali.Close()
+
+	bob, err := NewBackend(nil, newTestSigner(t, "bob"), opts("bob-id"))
+	require.Nil(t, err)
+	defer bob.Close()
+
+	fn(srv, ali, bob)
+}
+
+func waitForControl(t *testing.T, srv *Server, addr string) {
+	for idx := 0; idx < 100; idx++ {
+		srv.mu.Lock()
+		_, ok := srv.controls[addr]
+		srv.mu.Unlock()
+
+		if ok {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("%s did not start listening", addr)
+}
+
+func TestEntrySignature(t *testing.T) {
+	signer := newTestSigner(t, "alice")
+	entry, err := newEntry("alice", "alice-id", signer)
+	require.Nil(t, err)
+	require.Nil(t, entry.Verify())
+
+	// Changing any field breaks the signature:
+	entry.Addr = "mallory-id"
+	require.NotNil(t, entry.Verify())
+	entry.Addr = "alice-id"
+
+	// So does using another key:
+	other := newTestSigner(t, "mallory")
+	entry.PubKey = other.pub
+	require.NotNil(t, entry.Verify())
+}
+
+func TestResolveName(t *testing.T) {
+	withServer(t, func(srv *Server, ali, bob *Backend) {
+		require.Nil(t, ali.PublishName("alice"))
+		require.Nil(t, bob.PublishName("bob"))
+
+		infos, err := ali.ResolveName(context.Background(), "bob")
+		require.Nil(t, err)
+		require.Len(t, infos, 1)
+		require.Equal(t, "bob-id", infos[0].Addr)
+		require.Equal(t, "bob", string(infos[0].Name))
+
+		bobPubKey, err := bob.signer.OwnPubKey()
+		require.Nil(t, err)
+		require.Equal(t, peer.BuildFingerprint("bob-id", bobPubKey), infos[0].Fingerprint)
+
+		infos, err = bob.ResolveName(context.Background(), "charlie")
+		require.Nil(t, err)
+		require.Empty(t, infos)
+
+		// A forged entry is rejected by the server:
+		entry, err := newEntry("bob", "mallory-id", ali.signer)
+		require.Nil(t, err)
+		entry.PubKey = bob.signer.(*testSigner).pub
+		require.NotNil(t, srv.register(entry))
+	})
+}
+
+func TestResolveNameForged(t *testing.T) {
+	withServer(t, func(srv *Server, ali, bob *Backend) {
+		require.Nil(t, bob.PublishName("bob"))
+
+		// Anyone (including the server) can make a valid entry for bob:
+		mallory := newTestSigner(t, "mallory")
+		entry, err := newEntry("bob", "mallory-id", mallory)
+		require.Nil(t, err)
+		require.Nil(t, srv.register(entry))
+
+		// Without knowing bob, we can only tell them apart by fingerprint:
+		infos, err := ali.ResolveName(context.Background(), "bob")
+		require.Nil(t, err)
+		require.Len(t, infos, 2)
+		require.False(t, infos[0].Verified)
+		require.False(t, infos[1].Verified)
+
+		bobPubKey, err := bob.signer.OwnPubKey()
+		require.Nil(t, err)
+		bobFingerprint := peer.BuildFingerprint("bob-id", bobPubKey)
+
+		ali.opts.Fingerprints = func(name string) []peer.Fingerprint {
+			if name == "bob" {
+				return []peer.Fingerprint{bobFingerprint}
+			}
+
+			return nil
+		}
+
+		infos, err = ali.ResolveName(context.Background(), "bob")
+		require.Nil(t, err)
+		require.Len(t, infos, 1)
+		require.Equal(t, "bob-id", infos[0].Addr)
+		require.Equal(t, bobFingerprint, infos[0].Fingerprint)
+		require.True(t, infos[0].Verified)
+	})
+}
+
+func TestRelay(t *testing.T) {
+	withServer(t, func(srv *Server, ali, bob *Backend) {
+		lst, err := bob.Listen("brig/test")
+		require.Nil(t, err)
+		defer lst.Close()
+
+		go func() {
+			conn, err := lst.Accept()
+			if err != nil {
+				return
+			}
+
+			defer conn.Close()
+			io.Copy(conn, conn)
+		}()
+
+		waitForControl(t, srv, "bob-id")
+
+		conn, err := ali.Dial("bob-id", "", "brig/test")
+		require.Nil(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("hello"))
+		require.Nil(t, err)
+
+		buf := make([]byte, 5)
+		_, err = io.ReadFull(conn, buf)
+		require.Nil(t, err)
+		require.Equal(t, []byte("hello"), buf)
+
+		_, err = ali.Dial("charlie-id", "", "brig/test")
+		require.NotNil(t, err)
+
+		pinger, err := ali.Ping("bob-id")
+		require.Nil(t, err)
+		defer pinger.Close()
+
+		for idx := 0; idx < 100 && pinger.Err() == netBackend.ErrWaiting; idx++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		require.Nil(t, pinger.Err())
+		require.True(t, pinger.Roundtrip() > 0)
+	})
+}
+
+func TestOffline(t *testing.T) {
+	withServer(t, func(srv *Server, ali, bob *Backend) {
+		require.Nil(t, ali.Disconnect())
+		require.False(t, ali.IsOnline())
+		require.Equal(t, ErrOffline, ali.PublishName("alice"))
+
+		_, err := ali.ResolveName(context.Background(), "bob")
+		require.Equal(t, ErrOffline, err)
+
+		require.Nil(t, ali.Connect())
+		require.True(t, ali.IsOnline())
+		require.Nil(t, ali.PublishName("alice"))
+	})
+}
@@ -0,0 +1,372 @@
+package rendezvous
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// EntryTTL is the time after which entries are forgotten
+	// if they were not registered again.
+	EntryTTL = 24 * time.Hour
+
+	// MaxClockSkew is how far the time of an entry used with opListen
+	// may differ from the server's time. It limits replaying old entries.
+	MaxClockSkew = 5 * time.Minute
+
+	// RelayTimeout is how long a dialing peer waits for the other side.
+	RelayTimeout = 10 * time.Second
+)
+
+type control struct {
+	mu       sync.Mutex
+	conn     net.Conn
+	pubKeyID string
+}
+
+func (ctl *control) push(resp response) error {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+
+	return writeMsg(ctl.conn, resp)
+}
+
+// Server is a rendezvous server. Peers register their names with it,
+// ask it for other peers and can relay connections through it.
+type Server struct {
+	mu  sync.Mutex
+	lst net.Listener
+
+	// name -> pubkey id -> entry
+	entries map[string]map[string]*Entry
+
+	// addr -> control connection of a listening peer
+	controls map[string]*control
+
+	// session id -> waiting dialer
+	sessions map[string]chan net.Conn
+}
+
+// NewServer returns a new rendezvous server that accepts connections on `lst`.
+func NewServer(lst net.Listener) *Server {
+	return &Server{
+		lst:      lst,
+		entries:  make(map[string]map[string]*Entry),
+		controls: make(map[string]*control),
+		sessions: make(map[string]chan net.Conn),
+	}
+}
+
+// Addr returns the address the server is listening on.
+func (srv *Server) Addr() net.Addr {
+	return srv.lst.Addr()
+}
+
+// Serve handles incoming connections until Close() is called.
+func (srv *Server) Serve() error {
+	for {
+		conn, err := srv.lst.Accept()
+		if err != nil {
+			return err
+		}
+
+		go srv.handleConn(conn)
+	}
+}
+
+// Close stops the server and drops all control connections.
+func (srv *Server) Close() error {
+	srv.mu.Lock()
+	for _, ctl := range srv.controls {
+		ctl.conn.Close()
+	}
+	srv.mu.Unlock()
+
+	return srv.lst.Close()
+}
+
+func (srv *Server) handleConn(conn net.Conn) {
+	if err := conn.SetReadDeadline(time.Now().Add(RelayTimeout)); err != nil {
+		conn.Close()
+		return
+	}
+
+	req := request{}
+	if err := readMsg(conn, &req); err != nil {
+		log.Debugf("rendezvous: bad request from %s: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return
+	}
+
+	var resp response
+	var err error
+
+	switch req.Op {
+	case opRegister:
+		err = srv.register(req.Entry)
+	case opResolve:
+		resp.Entries = srv.resolve(req.Name)
+	case opListen:
+		// The connection stays open.
+		srv.listen(conn, req.Entry)
+		return
+	case opDial:
+		// The connection is handed over on success.
+		srv.dial(conn, req.Addr, req.Protocol)
+		return
+	case opAccept:
+		srv.accept(conn, req.Session)
+		return
+	default:
+		err = fmt.Errorf("unknown op `%s`", req.Op)
+	}
+
+	if err != nil {
+		resp.Error = err.Error()
+	}
+
+	if err := writeMsg(conn, resp); err != nil {
+		log.Debugf("rendezvous: failed to respond: %v", err)
+	}
+
+	conn.Close()
+}
+
+func (srv *Server) register(entry *Entry) error {
+	if entry == nil {
+		return fmt.Errorf("no entry given")
+	}
+
+	if err := entry.Verify(); err != nil {
+		return err
+	}
+
+	pubKeyID := entry.Fingerprint().PubKeyID()
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	byKey, ok := srv.entries[entry.Name]
+	if !ok {
+		byKey = make(map[string]*Entry)
+		srv.entries[entry.Name] = byKey
+	}
+
+	if old, ok := byKey[pubKeyID]; ok && old.Time > entry.Time {
+		return fmt.Errorf("a newer entry exists already")
+	}
+
+	byKey[pubKeyID] = entry
+	return nil
+}
+
+func (srv *Server) resolve(name string) []*Entry {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	byKey := srv.entries[name]
+	entries := []*Entry{}
+	for pubKeyID, entry := range byKey {
+		if time.Since(time.Unix(entry.Time, 0)) > EntryTTL {
+			delete(byKey, pubKeyID)
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if len(byKey) == 0 {
+		delete(srv.entries, name)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Addr < entries[j].Addr
+	})
+
+	return entries
+}
+
+func (srv *Server) listen(conn net.Conn, entry *Entry) {
+	if err := srv.addControl(conn, entry); err != nil {
+		writeMsg(conn, response{Error: err.Error()})
+		conn.Close()
+		return
+	}
+
+	// Nothing is read from a control connection;
+	// wait until it gets closed to clean up.
+	io.Copy(ioutil.Discard, conn)
+
+	srv.mu.Lock()
+	if ctl, ok := srv.controls[entry.Addr]; ok && ctl.conn == conn {
+		delete(srv.controls, entry.Addr)
+	}
+	srv.mu.Unlock()
+
+	conn.Close()
+}
+
+func (srv *Server) addControl(conn net.Conn, entry *Entry) error {
+	if entry == nil {
+		return fmt.Errorf("no entry given")
+	}
+
+	if err := entry.Verify(); err != nil {
+		return err
+	}
+
+	skew := time.Since(time.Unix(entry.Time, 0))
+	if skew > MaxClockSkew || skew < -MaxClockSkew {
+		return fmt.Errorf("entry is too old or too new")
+	}
+
+	ctl := &control{
+		conn:     conn,
+		pubKeyID: entry.Fingerprint().PubKeyID(),
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if old, ok := srv.controls[entry.Addr]; ok {
+		if old.pubKeyID != ctl.pubKeyID {
+			return fmt.Errorf("addr is already taken by another key")
+		}
+
+		old.conn.Close()
+	}
+
+	srv.controls[entry.Addr] = ctl
+
+	// Tell the peer that it's listening now. Take the control's lock,
+	// so no relay request gets pushed before this.
+	return ctl.push(response{})
+}
+
+func newSessionID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+func (srv *Server) dial(conn net.Conn, addr, protocol string) {
+	other, err := srv.waitForPeer(addr, protocol)
+	if err != nil {
+		writeMsg(conn, response{Error: err.Error()})
+		conn.Close()
+		return
+	}
+
+	if err := writeMsg(conn, response{}); err != nil {
+		conn.Close()
+		other.Close()
+		return
+	}
+
+	splice(conn, other)
+}
+
+func (srv *Server) waitForPeer(addr, protocol string) (net.Conn, error) {
+	sessionID, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	otherCh := make(chan net.Conn, 1)
+
+	srv.mu.Lock()
+	ctl, ok := srv.controls[addr]
+	if ok {
+		srv.sessions[sessionID] = otherCh
+	}
+	srv.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no such peer: %s", addr)
+	}
+
+	defer func() {
+		srv.mu.Lock()
+		delete(srv.sessions, sessionID)
+		srv.mu.Unlock()
+
+		// The peer might have accepted just after we gave up:
+		select {
+		case other := <-otherCh:
+			other.Close()
+		default:
+		}
+	}()
+
+	if err := ctl.push(response{Session: sessionID, Protocol: protocol}); err != nil {
+		return nil, fmt.Errorf("failed to contact peer: %v", err)
+	}
+
+	select {
+	case other := <-otherCh:
+		return other, nil
+	case <-time.After(RelayTimeout):
+		return nil, fmt.Errorf("peer did not accept in time")
+	}
+}
+
+func (srv *Server) accept(conn net.Conn, sessionID string) {
+	srv.mu.Lock()
+	otherCh, ok := srv.sessions[sessionID]
+	delete(srv.sessions, sessionID)
+	srv.mu.Unlock()
+
+	if !ok {
+		writeMsg(conn, response{Error: "no such session"})
+		conn.Close()
+		return
+	}
+
+	if err := writeMsg(conn, response{}); err != nil {
+		conn.Close()
+		return
+	}
+
+	// The dialing side takes over from here.
+	otherCh <- conn
+}
+
+// splice copies data between `a` and `b` until both sides are done.
+func splice(a, b net.Conn) {
+	done := make(chan bool, 2)
+	copyHalf := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		if tcpConn, ok := dst.(*net.TCPConn); ok {
+			tcpConn.CloseWrite()
+		} else {
+			dst.Close()
+		}
+
+		done <- true
+	}
+
+	go copyHalf(a, b)
+	go copyHalf(b, a)
+	<-done
+	<-done
+
+	a.Close()
+	b.Close()
+}
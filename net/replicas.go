@@ -0,0 +1,172 @@
+package net
+
+import (
+	"fmt"
+
+	"github.com/sahib/brig/net/capnp"
+	"github.com/sahib/brig/util/bloom"
+	h "github.com/sahib/brig/util/hashlib"
+	log "github.com/sirupsen/logrus"
+	capnplib "zombiezen.com/go/capnproto2"
+)
+
+func hashesToCapData(hashes []h.Hash, seg *capnplib.Segment) (capnplib.DataList, error) {
+	capHashes, err := capnplib.NewDataList(seg, int32(len(hashes)))
+	if err != nil {
+		return capHashes, err
+	}
+
+	for idx, hash := range hashes {
+		if err := capHashes.Set(idx, hash.Bytes()); err != nil {
+			return capHashes, err
+		}
+	}
+
+	return capHashes, nil
+}
+
+func capDataToHashes(capHashes capnplib.DataList) ([]h.Hash, error) {
+	hashes := []h.Hash{}
+	for idx := 0; idx < capHashes.Len(); idx++ {
+		data, err := capHashes.At(idx)
+		if err != nil {
+			return nil, err
+		}
+
+		hash, err := h.Cast(data)
+		if err != nil {
+			return nil, err
+		}
+
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, nil
+}
+
+// remotePrefixes returns the folders the current remote may access.
+// An empty list means that it may access everything.
+func (hdl *requestHandler) remotePrefixes() ([]string, error) {
+	currRemote, err := hdl.rp.Remotes.EffectiveRemote(hdl.currRemoteName)
+	if err != nil {
+		return nil, err
+	}
+
+	prefixes := []string{}
+	for _, folder := range currRemote.Folders {
+		prefixes = append(prefixes, folder.Folder)
+	}
+
+	return prefixes, nil
+}
+
+func (hdl *requestHandler) PinSummary(call capnp.Replication_pinSummary) error {
+	// Only tell the remote about the folders it may see anyways:
+	prefixes, err := hdl.remotePrefixes()
+	if err != nil {
+		return err
+	}
+
+	fs, err := hdl.rp.FS(hdl.rp.Owner, hdl.bk)
+	if err != nil {
+		return err
+	}
+
+	filter, err := fs.PinSummary(prefixes)
+	if err != nil {
+		return err
+	}
+
+	return call.Results.SetFilter(filter.Bytes())
+}
+
+func (hdl *requestHandler) RequestPins(call capnp.Replication_requestPins) error {
+	// The remote may only make us pin content it may see anyways.
+	// Otherwise the result would tell it what else we have.
+	prefixes, err := hdl.remotePrefixes()
+	if err != nil {
+		return err
+	}
+
+	if !hdl.rp.Config.Bool("fs.replicas.accept_requests") {
+		return fmt.Errorf("pin requests are not accepted")
+	}
+
+	capHashes, err := call.Params.Hashes()
+	if err != nil {
+		return err
+	}
+
+	hashes, err := capDataToHashes(capHashes)
+	if err != nil {
+		return err
+	}
+
+	fs, err := hdl.rp.FS(hdl.rp.Owner, hdl.bk)
+	if err != nil {
+		return err
+	}
+
+	pinned, err := fs.PinReplicas(hashes, prefixes)
+	if err != nil {
+		return err
+	}
+
+	log.Infof(
+		"pinned %d of %d files as requested by »%s«",
+		len(pinned), len(hashes), hdl.currRemoteName,
+	)
+
+	capPinned, err := hashesToCapData(pinned, call.Results.Segment())
+	if err != nil {
+		return err
+	}
+
+	return call.Results.SetPinned(capPinned)
+}
+
+// PinSummary returns a bloom filter of the content the remote has pinned.
+// Only content in folders we may access is part of it.
+func (cl *Client) PinSummary() (*bloom.Filter, error) {
+	call := cl.api.PinSummary(cl.ctx, func(p capnp.Replication_pinSummary_Params) error {
+		return nil
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := result.Filter()
+	if err != nil {
+		return nil, err
+	}
+
+	return bloom.FromBytes(data)
+}
+
+// RequestPins asks the remote to pin the content with the backend hashes
+// in `hashes`. The remote decides what it pins; the hashes it holds
+// afterwards are returned.
+func (cl *Client) RequestPins(hashes []h.Hash) ([]h.Hash, error) {
+	call := cl.api.RequestPins(cl.ctx, func(p capnp.Replication_requestPins_Params) error {
+		capHashes, err := hashesToCapData(hashes, p.Segment())
+		if err != nil {
+			return err
+		}
+
+		return p.SetHashes(capHashes)
+	})
+
+	result, err := call.Struct()
+	if err != nil {
+		return nil, err
+	}
+
+	capPinned, err := result.Pinned()
+	if err != nil {
+		return nil, err
+	}
+
+	return capDataToHashes(capPinned)
+}
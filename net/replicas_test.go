@@ -0,0 +1,59 @@
+package net
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sahib/brig/repo"
+	h "github.com/sahib/brig/util/hashlib"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientReplicas(t *testing.T) {
+	withNetPair(t, func(a, b testUnit) {
+		require.Nil(t, a.fs.Stage("/archive/x", bytes.NewReader([]byte{1, 2, 3})))
+		info, err := a.fs.Stat("/archive/x")
+		require.Nil(t, err)
+
+		filter, err := b.ctl.PinSummary()
+		require.Nil(t, err)
+		require.True(t, filter.Test(info.BackendHash.Bytes()))
+
+		require.Nil(t, a.fs.Unpin("/archive/x", "curr", true))
+		filter, err = b.ctl.PinSummary()
+		require.Nil(t, err)
+		require.False(t, filter.Test(info.BackendHash.Bytes()))
+
+		// alice does not want to pin for others by default:
+		_, err = b.ctl.RequestPins([]h.Hash{info.BackendHash})
+		require.NotNil(t, err)
+
+		a.rp.Config.SetBool("fs.replicas.accept_requests", true)
+
+		// bob may not make alice pin things outside of his folders:
+		bobRemote, err := a.rp.Remotes.Remote("bob")
+		require.Nil(t, err)
+		bobRemote.Folders = []repo.Folder{{Folder: "/photos"}}
+		require.Nil(t, a.rp.Remotes.AddOrUpdateRemote(bobRemote))
+
+		pinned, err := b.ctl.RequestPins([]h.Hash{info.BackendHash})
+		require.Nil(t, err)
+		require.Empty(t, pinned)
+
+		isPinned, _, err := a.fs.IsPinned("/archive/x")
+		require.Nil(t, err)
+		require.False(t, isPinned)
+
+		bobRemote.Folders = nil
+		require.Nil(t, a.rp.Remotes.AddOrUpdateRemote(bobRemote))
+
+		pinned, err = b.ctl.RequestPins([]h.Hash{info.BackendHash})
+		require.Nil(t, err)
+		require.Len(t, pinned, 1)
+		require.True(t, pinned[0].Equal(info.BackendHash))
+
+		isPinned, _, err = a.fs.IsPinned("/archive/x")
+		require.Nil(t, err)
+		require.True(t, isPinned)
+	})
+}
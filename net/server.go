@@ -314,13 +314,19 @@ func (hdl *connHandler) Handle(ctx context.Context, conn net.Conn) {
 
 	ownFingerprint := peer.BuildFingerprint("", ownPubKey)
 
+	// Limit the connection by the global limits for now;
+	// the remote's limits are added once we know who it is.
+	limConn := hdl.rp.Bandwidth.Conn(conn, "")
+	conn = limConn
+
 	// The respective handler should get its own context it can listen to.
 	reqCtx, reqCancel := context.WithCancel(ctx)
 	reqHdl := &requestHandler{
-		bk:   hdl.bk,
-		rp:   hdl.rp,
-		ctx:  reqCtx,
-		rapi: hdl.rapi,
+		bk:      hdl.bk,
+		rp:      hdl.rp,
+		ctx:     reqCtx,
+		rapi:    hdl.rapi,
+		limConn: limConn,
 	}
 
 	// This func will be called during the authentication process.
@@ -347,10 +353,20 @@ func (hdl *connHandler) Handle(ctx context.Context, conn net.Conn) {
 				log.Infof("starting connection with addr `%s`", addr)
 				hdl.pingMap.hintNetAttempt(addr, true)
 				reqHdl.currRemoteName = remote.Name
+				hdl.rp.Bandwidth.LimitRemote(limConn, remote.Name)
 				return nil
 			}
 		}
 
+		// Unknown peers may only connect to redeem an invite.
+		// All other calls check for a known remote. Until then,
+		// only the global limits apply to the connection.
+		if hdl.rp.Invites.HasPending() {
+			log.Infof("accepting unknown peer to redeem an invite")
+			reqHdl.guestPubKey = pubKey
+			return nil
+		}
+
 		netAddr := conn.RemoteAddr()
 		if netAddr != nil {
 			hdl.pingMap.hintNetAttempt(netAddr.String(), false)
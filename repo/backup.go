@@ -0,0 +1,631 @@
+package repo
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	e "github.com/pkg/errors"
+	"github.com/sahib/brig/catfs"
+	"github.com/sahib/brig/catfs/db"
+	"github.com/sahib/brig/catfs/mio/encrypt"
+	"github.com/sahib/brig/defaults"
+	"github.com/sahib/brig/util"
+	h "github.com/sahib/brig/util/hashlib"
+	log "github.com/sirupsen/logrus"
+)
+
+// Layout of a backup bundle:
+//
+// magic (8 bytes) | version (2 bytes, big endian) | salt (32 bytes) | data
+//
+// data is a tar archive that was encrypted with a key derived from
+// the repository password and the salt. The archive contains:
+//
+// MANIFEST             (json encoded BackupManifest; always first)
+// repo/<path>          (files of the repository, like config.yml)
+// metadata/<owner>     (a dump of the metadata of each owner)
+// blobs/<hash>         (pinned content, as stored by the backend)
+
+const (
+	backupVersion  = 1
+	backupSaltSize = 32
+
+	backupManifestName = "MANIFEST"
+	backupRepoPrefix   = "repo/"
+	backupMetaPrefix   = "metadata/"
+	backupBlobPrefix   = "blobs/"
+
+	// restoreFolder is the folder in data/ where restored content waits
+	// until the backend is available to add it again.
+	restoreFolder = "restore"
+)
+
+var (
+	backupMagic = []byte("brigbkp\x00")
+
+	// Top-level entries that are handled separately or that
+	// can not be restored in a useful way.
+	excludedFromBackup = []string{"data", "metadata", "INIT_TAG", defaults.DaemonTokenName}
+
+	// ErrBadBackup is returned when a file does not look like a backup bundle.
+	ErrBadBackup = errors.New("not a brig backup bundle")
+)
+
+// BackupManifest describes the content of a single backup bundle.
+type BackupManifest struct {
+	// Version is the format version of the bundle.
+	Version int `json:"version"`
+
+	// ID is a random id that identifies this bundle.
+	ID string `json:"id"`
+
+	// Parent is the ID of the bundle this one is relative to.
+	// It is empty for full backups.
+	Parent string `json:"parent,omitempty"`
+
+	// Owner is the owner of the backed up repository.
+	Owner string `json:"owner"`
+
+	// Backend is the name of the backend the repository used.
+	Backend string `json:"backend"`
+
+	// Created is the time the backup was made.
+	Created time.Time `json:"created"`
+
+	// Owners lists all owners whose metadata is in the bundle.
+	Owners []string `json:"owners"`
+
+	// WithContent is true if pinned content was backed up too.
+	WithContent bool `json:"with_content"`
+
+	// Blobs lists the (base58) hashes of all content in this bundle
+	// and in all bundles it is relative to.
+	Blobs []string `json:"blobs"`
+
+	// NewBlobs is the number of blobs that are stored in this bundle.
+	NewBlobs int `json:"new_blobs"`
+}
+
+// BackupOptions can be passed to Backup() to configure what is included.
+type BackupOptions struct {
+	// WithContent adds all pinned content to the bundle.
+	WithContent bool
+
+	// Parent is the manifest of a previous bundle. If given, only content
+	// that is not in this bundle (or its parents) is included.
+	// Metadata and repository files are always included in full.
+	Parent *BackupManifest
+}
+
+func newBackupID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", buf), nil
+}
+
+func writeBackupHeader(w io.Writer, password string) ([]byte, error) {
+	salt := make([]byte, backupSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, len(backupMagic)+2)
+	copy(header, backupMagic)
+	binary.BigEndian.PutUint16(header[len(backupMagic):], backupVersion)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(salt); err != nil {
+		return nil, err
+	}
+
+	return backupKey(password, salt), nil
+}
+
+func backupKey(password string, salt []byte) []byte {
+	return util.DeriveKey([]byte(password), salt, 32)
+}
+
+// openBackup checks the header of the bundle in `r` and
+// returns a reader for the archive inside of it.
+func openBackup(r io.Reader, password string) (*tar.Reader, *BackupManifest, error) {
+	header := make([]byte, len(backupMagic)+2+backupSaltSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil, ErrBadBackup
+	}
+
+	if !bytes.Equal(header[:len(backupMagic)], backupMagic) {
+		return nil, nil, ErrBadBackup
+	}
+
+	version := binary.BigEndian.Uint16(header[len(backupMagic):])
+	if version != backupVersion {
+		return nil, nil, fmt.Errorf("unsupported backup version: %d", version)
+	}
+
+	salt := header[len(backupMagic)+2:]
+	encR, err := encrypt.NewReader(r, backupKey(password, salt))
+	if err != nil {
+		return nil, nil, e.Wrap(err, "failed to decrypt backup (wrong password?)")
+	}
+
+	tr := tar.NewReader(encR)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, nil, e.Wrap(err, "failed to decrypt backup (wrong password?)")
+	}
+
+	if hdr.Name != backupManifestName {
+		return nil, nil, fmt.Errorf("backup has no manifest")
+	}
+
+	manifest := &BackupManifest{}
+	if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+		return nil, nil, e.Wrap(err, "failed to read manifest")
+	}
+
+	return tr, manifest, nil
+}
+
+// ReadBackupManifest reads the manifest of the bundle in `r`.
+// Only the start of the bundle needs to be read for this.
+func ReadBackupManifest(r io.Reader, password string) (*BackupManifest, error) {
+	_, manifest, err := openBackup(r, password)
+	return manifest, err
+}
+
+func writeTarEntry(tw *tar.Writer, name string, size int64, r io.Reader) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    size,
+		ModTime: time.Now(),
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(tw, r)
+	return err
+}
+
+func writeTarFile(tw *tar.Writer, name, path string) error {
+	fd, err := os.Open(path) // #nosec
+	if err != nil {
+		return err
+	}
+
+	defer util.Closer(fd)
+
+	info, err := fd.Stat()
+	if err != nil {
+		return err
+	}
+
+	return writeTarEntry(tw, name, info.Size(), fd)
+}
+
+func (rp *Repository) backupFiles(tw *tar.Writer) error {
+	return filepath.Walk(rp.BaseFolder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path == rp.BaseFolder {
+			return nil
+		}
+
+		isTopLevel := filepath.Dir(path) == filepath.Clean(rp.BaseFolder)
+		if isTopLevel && isExcluded(path, excludedFromBackup) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(rp.BaseFolder, path)
+		if err != nil {
+			return err
+		}
+
+		return writeTarFile(tw, backupRepoPrefix+filepath.ToSlash(relPath), path)
+	})
+}
+
+func (rp *Repository) owners() ([]string, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(rp.BaseFolder, "metadata"))
+	if err != nil {
+		return nil, err
+	}
+
+	owners := []string{}
+	for _, info := range infos {
+		if info.IsDir() {
+			owners = append(owners, info.Name())
+		}
+	}
+
+	return owners, nil
+}
+
+// Backup writes an encrypted bundle of the repository to `w`. It contains
+// everything needed to restore the repository with RestoreBackup() without
+// any other peer being online. The bundle is encrypted with `password`,
+// which should be the password of the repository.
+func (rp *Repository) Backup(w io.Writer, password string, bk catfs.FsBackend, opts BackupOptions) (*BackupManifest, error) {
+	id, err := newBackupID()
+	if err != nil {
+		return nil, err
+	}
+
+	owners, err := rp.owners()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &BackupManifest{
+		Version:     backupVersion,
+		ID:          id,
+		Owner:       rp.Owner,
+		Backend:     rp.BackendName(),
+		Created:     time.Now(),
+		Owners:      owners,
+		WithContent: opts.WithContent,
+		Blobs:       []string{},
+	}
+
+	knownBlobs := make(map[string]bool)
+	if opts.Parent != nil {
+		if opts.Parent.Owner != rp.Owner {
+			return nil, fmt.Errorf(
+				"parent backup belongs to `%s`, not `%s`",
+				opts.Parent.Owner,
+				rp.Owner,
+			)
+		}
+
+		manifest.Parent = opts.Parent.ID
+		for _, blob := range opts.Parent.Blobs {
+			knownBlobs[blob] = true
+		}
+	}
+
+	// Dump the metadata first; pinned content is collected on the way.
+	dumps := make(map[string][]byte)
+	newBlobs := []h.Hash{}
+	for _, owner := range owners {
+		fs, err := rp.FS(owner, bk)
+		if err != nil {
+			return nil, err
+		}
+
+		buf := &bytes.Buffer{}
+		if err := fs.Export(buf); err != nil {
+			return nil, e.Wrapf(err, "failed to export metadata of %s", owner)
+		}
+
+		dumps[owner] = buf.Bytes()
+		if !opts.WithContent {
+			continue
+		}
+
+		hashes, err := fs.PinnedContent()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, hash := range hashes {
+			b58 := hash.B58String()
+			if knownBlobs[b58] {
+				continue
+			}
+
+			knownBlobs[b58] = true
+			newBlobs = append(newBlobs, hash)
+		}
+	}
+
+	for blob := range knownBlobs {
+		manifest.Blobs = append(manifest.Blobs, blob)
+	}
+
+	sort.Strings(manifest.Blobs)
+	manifest.NewBlobs = len(newBlobs)
+
+	key, err := writeBackupHeader(w, password)
+	if err != nil {
+		return nil, err
+	}
+
+	encW, err := encrypt.NewWriter(w, key)
+	if err != nil {
+		return nil, err
+	}
+
+	tw := tar.NewWriter(encW)
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeTarEntry(tw, backupManifestName, int64(len(manifestData)), bytes.NewReader(manifestData)); err != nil {
+		return nil, err
+	}
+
+	if err := rp.backupFiles(tw); err != nil {
+		return nil, e.Wrap(err, "failed to backup repository files")
+	}
+
+	for _, owner := range owners {
+		dump := dumps[owner]
+		if err := writeTarEntry(tw, backupMetaPrefix+owner, int64(len(dump)), bytes.NewReader(dump)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, hash := range newBlobs {
+		if err := writeBlob(tw, bk, hash); err != nil {
+			return nil, e.Wrapf(err, "failed to backup content %s", hash.B58String())
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := encW.Close(); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+func writeBlob(tw *tar.Writer, bk catfs.FsBackend, hash h.Hash) error {
+	stream, err := bk.Cat(hash)
+	if err != nil {
+		return err
+	}
+
+	defer util.Closer(stream)
+
+	// The tar header needs the size upfront:
+	size, err := stream.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	if _, err := stream.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	return writeTarEntry(tw, backupBlobPrefix+hash.B58String(), size, stream)
+}
+
+func restoreFile(r io.Reader, dstPath string) error {
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0700); err != nil {
+		return err
+	}
+
+	fd, err := os.OpenFile(dstPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(fd, r); err != nil {
+		fd.Close()
+		return err
+	}
+
+	return fd.Close()
+}
+
+func restoreMetadata(r io.Reader, dbPath string) error {
+	if err := os.MkdirAll(dbPath, 0700); err != nil {
+		return err
+	}
+
+	kv, err := db.NewBadgerDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+
+	if err := kv.Import(r); err != nil {
+		kv.Close()
+		return err
+	}
+
+	return kv.Close()
+}
+
+// cleanBackupName makes sure that `name` does not point outside of the repo.
+func cleanBackupName(name, prefix string) (string, error) {
+	relPath := path.Clean(strings.TrimPrefix(name, prefix))
+	if relPath == "." || relPath == ".." || strings.HasPrefix(relPath, "../") || path.IsAbs(relPath) {
+		return "", fmt.Errorf("invalid path in backup: %s", name)
+	}
+
+	return filepath.FromSlash(relPath), nil
+}
+
+func restoreBundle(r io.Reader, folder, password string, isLast bool) (*BackupManifest, error) {
+	tr, manifest, err := openBackup(r, password)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, e.Wrap(err, "failed to read backup")
+		}
+
+		var prefix, dstPath string
+		switch {
+		case strings.HasPrefix(hdr.Name, backupBlobPrefix):
+			prefix, dstPath = backupBlobPrefix, filepath.Join(folder, "data", restoreFolder)
+		case strings.HasPrefix(hdr.Name, backupRepoPrefix):
+			prefix, dstPath = backupRepoPrefix, folder
+		case strings.HasPrefix(hdr.Name, backupMetaPrefix):
+			prefix, dstPath = backupMetaPrefix, filepath.Join(folder, "metadata")
+		default:
+			log.Warningf("backup: ignoring unknown entry `%s`", hdr.Name)
+			continue
+		}
+
+		relPath, err := cleanBackupName(hdr.Name, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		dstPath = filepath.Join(dstPath, relPath)
+		switch {
+		case prefix == backupBlobPrefix:
+			err = restoreFile(tr, dstPath)
+		case !isLast:
+			// Files and metadata of older bundles are outdated.
+			continue
+		case prefix == backupRepoPrefix:
+			err = restoreFile(tr, dstPath)
+		case prefix == backupMetaPrefix:
+			err = restoreMetadata(tr, dstPath)
+		}
+
+		if err != nil {
+			return nil, e.Wrapf(err, "failed to restore %s", hdr.Name)
+		}
+	}
+
+	return manifest, nil
+}
+
+// RestoreBackup recreates a repository in `folder` from `bundles`. Pass
+// the full backup first and then all incremental ones in the order they
+// were made. Restored content is added to the backend once the repository
+// is opened by the daemon (see AddRestoredContent).
+func RestoreBackup(folder, password string, bundles ...io.Reader) (*BackupManifest, error) {
+	if len(bundles) == 0 {
+		return nil, fmt.Errorf("no backup given")
+	}
+
+	if err := os.MkdirAll(folder, 0700); err != nil {
+		return nil, err
+	}
+
+	children, err := ioutil.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(children) > 0 {
+		return nil, fmt.Errorf("`%s` is not empty; refusing to restore", folder)
+	}
+
+	var last *BackupManifest
+	for idx, bundle := range bundles {
+		manifest, err := restoreBundle(bundle, folder, password, idx == len(bundles)-1)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case last == nil && manifest.Parent != "":
+			return nil, fmt.Errorf("first backup is incremental; need its parent %s", manifest.Parent)
+		case last != nil && manifest.Parent != last.ID:
+			return nil, fmt.Errorf("backup %s does not follow %s", manifest.ID, last.ID)
+		}
+
+		last = manifest
+	}
+
+	// The backend data is not part of the backup; set up an empty one.
+	if err := os.MkdirAll(filepath.Join(folder, "data", last.Backend), 0700); err != nil {
+		return nil, err
+	}
+
+	// Lock everything, so it looks like the repository was closed normally:
+	if err := LockRepo(folder, last.Owner, password, excludedFromLock, excludedFromUnlock); err != nil {
+		return nil, err
+	}
+
+	// Check that the password also opens the restored repository:
+	if err := CheckPassword(folder, password); err != nil {
+		return nil, err
+	}
+
+	return last, nil
+}
+
+// AddRestoredContent adds content left over by RestoreBackup() to `bk`
+// and pins it. Content that was added successfully is removed.
+func (rp *Repository) AddRestoredContent(bk catfs.FsBackend) error {
+	restorePath := filepath.Join(rp.BaseFolder, "data", restoreFolder)
+	infos, err := ioutil.ReadDir(restorePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	log.Infof("adding %d restored objects to the backend", len(infos))
+	for _, info := range infos {
+		if err := addRestoredBlob(bk, filepath.Join(restorePath, info.Name())); err != nil {
+			return e.Wrapf(err, "failed to add restored content %s", info.Name())
+		}
+	}
+
+	return os.Remove(restorePath)
+}
+
+func addRestoredBlob(bk catfs.FsBackend, path string) error {
+	fd, err := os.Open(path) // #nosec
+	if err != nil {
+		return err
+	}
+
+	defer util.Closer(fd)
+
+	hash, err := bk.Add(fd)
+	if err != nil {
+		return err
+	}
+
+	if expect := filepath.Base(path); hash.B58String() != expect {
+		// Might happen if the backend uses other settings than before.
+		log.Warningf("restored content %s got a different hash: %s", expect, hash.B58String())
+	}
+
+	if err := bk.Pin(hash); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
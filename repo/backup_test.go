@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/sahib/brig/backend/mock"
+	"github.com/sahib/brig/catfs"
+	"github.com/stretchr/testify/require"
+)
+
+func requireContent(t *testing.T, fs *catfs.FS, path string, expect []byte) {
+	stream, err := fs.Cat(path)
+	require.Nil(t, err)
+
+	data, err := ioutil.ReadAll(stream)
+	require.Nil(t, err)
+	require.Equal(t, expect, data)
+	require.Nil(t, stream.Close())
+}
+
+func TestBackupRestore(t *testing.T) {
+	srcDir, err := ioutil.TempDir("", "brig-backup-src")
+	require.Nil(t, err)
+	defer os.RemoveAll(srcDir)
+
+	dstDir, err := ioutil.TempDir("", "brig-backup-dst")
+	require.Nil(t, err)
+	defer os.RemoveAll(dstDir)
+
+	require.Nil(t, Init(srcDir, "alice", "klaus", "mock", 6666))
+	rp, err := Open(srcDir, "klaus")
+	require.Nil(t, err)
+
+	bk := mock.NewMockBackend("", "")
+	fs, err := rp.FS(rp.CurrentUser(), bk)
+	require.Nil(t, err)
+
+	require.Nil(t, fs.Stage("/x", bytes.NewReader([]byte{1, 2, 3})))
+	require.Nil(t, rp.Remotes.AddOrUpdateRemote(Remote{Name: "bob"}))
+
+	full := &bytes.Buffer{}
+	fullManifest, err := rp.Backup(full, "klaus", bk, BackupOptions{WithContent: true})
+	require.Nil(t, err)
+	require.Equal(t, "alice", fullManifest.Owner)
+	require.Equal(t, 1, fullManifest.NewBlobs)
+
+	// Only the new file should end up in the incremental backup:
+	require.Nil(t, fs.Stage("/y", bytes.NewReader([]byte{4, 5, 6})))
+	incr := &bytes.Buffer{}
+	incrManifest, err := rp.Backup(incr, "klaus", bk, BackupOptions{
+		WithContent: true,
+		Parent:      fullManifest,
+	})
+	require.Nil(t, err)
+	require.Equal(t, fullManifest.ID, incrManifest.Parent)
+	require.Equal(t, 1, incrManifest.NewBlobs)
+	require.Len(t, incrManifest.Blobs, 2)
+
+	readManifest, err := ReadBackupManifest(bytes.NewReader(incr.Bytes()), "klaus")
+	require.Nil(t, err)
+	require.Equal(t, incrManifest.ID, readManifest.ID)
+
+	_, err = ReadBackupManifest(bytes.NewReader(incr.Bytes()), "wrong")
+	require.NotNil(t, err)
+
+	require.Nil(t, fs.Close())
+	require.Nil(t, rp.Close())
+
+	// An incremental backup can not be restored without its parent:
+	_, err = RestoreBackup(dstDir, "klaus", bytes.NewReader(incr.Bytes()))
+	require.NotNil(t, err)
+	require.Nil(t, os.RemoveAll(dstDir))
+
+	manifest, err := RestoreBackup(
+		dstDir,
+		"klaus",
+		bytes.NewReader(full.Bytes()),
+		bytes.NewReader(incr.Bytes()),
+	)
+	require.Nil(t, err)
+	require.Equal(t, incrManifest.ID, manifest.ID)
+
+	// Open the restored repository with a fresh backend:
+	rp, err = Open(dstDir, "klaus")
+	require.Nil(t, err)
+	require.Equal(t, "alice", rp.Owner)
+
+	remote, err := rp.Remotes.Remote("bob")
+	require.Nil(t, err)
+	require.Equal(t, "bob", remote.Name)
+
+	bk = mock.NewMockBackend("", "")
+	require.Nil(t, rp.AddRestoredContent(bk))
+
+	fs, err = rp.FS(rp.CurrentUser(), bk)
+	require.Nil(t, err)
+
+	requireContent(t, fs, "/x", []byte{1, 2, 3})
+	requireContent(t, fs, "/y", []byte{4, 5, 6})
+
+	require.Nil(t, fs.Close())
+	require.Nil(t, rp.Close())
+}
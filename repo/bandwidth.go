@@ -0,0 +1,186 @@
+package repo
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sahib/brig/util/throttle"
+	"github.com/sahib/config"
+	log "github.com/sirupsen/logrus"
+)
+
+type remoteLimiters struct {
+	up   *throttle.Limiter
+	down *throttle.Limiter
+}
+
+// Bandwidth keeps track of the limits and the throughput of all transfers
+// from and to other peers. The global limits are taken from the config
+// (net.bandwidth.*) and may change over the day (net.bandwidth.schedule).
+// Per remote limits are set in the remote list and apply all the time.
+type Bandwidth struct {
+	mu sync.Mutex
+
+	cfg     *config.Config
+	remotes *RemoteList
+
+	up        *throttle.Limiter
+	down      *throttle.Limiter
+	upMeter   *throttle.Meter
+	downMeter *throttle.Meter
+
+	// remote name -> limiters for this remote
+	perRemote map[string]*remoteLimiters
+
+	quitCh chan bool
+}
+
+func newBandwidth(cfg *config.Config, remotes *RemoteList) *Bandwidth {
+	bw := &Bandwidth{
+		cfg:       cfg,
+		remotes:   remotes,
+		up:        throttle.NewLimiter(0),
+		down:      throttle.NewLimiter(0),
+		upMeter:   throttle.NewMeter(),
+		downMeter: throttle.NewMeter(),
+		perRemote: make(map[string]*remoteLimiters),
+		quitCh:    make(chan bool, 1),
+	}
+
+	bw.updateGlobal()
+
+	for _, key := range []string{"upload", "download", "schedule"} {
+		cfg.AddEvent("net.bandwidth."+key, func(key string) {
+			bw.updateGlobal()
+		})
+	}
+
+	remotes.OnChange(bw.updateRemotes)
+	go bw.scheduleLoop()
+	return bw
+}
+
+// schedule returns the currently configured schedule.
+func (bw *Bandwidth) schedule() throttle.Schedule {
+	def := throttle.Rate{}
+	var err error
+
+	def.Up, err = throttle.ParseRate(bw.cfg.String("net.bandwidth.upload"))
+	if err != nil {
+		log.Warningf("bandwidth: ignoring upload limit: %v", err)
+	}
+
+	def.Down, err = throttle.ParseRate(bw.cfg.String("net.bandwidth.download"))
+	if err != nil {
+		log.Warningf("bandwidth: ignoring download limit: %v", err)
+	}
+
+	sched, err := throttle.ParseSchedule(def, bw.cfg.Strings("net.bandwidth.schedule"))
+	if err != nil {
+		log.Warningf("bandwidth: ignoring schedule: %v", err)
+		return throttle.Schedule{Default: def}
+	}
+
+	return sched
+}
+
+func (bw *Bandwidth) updateGlobal() {
+	rate := bw.schedule().RateAt(time.Now())
+	bw.up.SetRate(rate.Up)
+	bw.down.SetRate(rate.Down)
+}
+
+func (bw *Bandwidth) updateRemotes() {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	for name, lims := range bw.perRemote {
+		remote, err := bw.remotes.Remote(name)
+		if err != nil {
+			// Removed remotes can't be connected anymore anyways.
+			delete(bw.perRemote, name)
+			continue
+		}
+
+		lims.up.SetRate(remote.UploadLimit)
+		lims.down.SetRate(remote.DownloadLimit)
+	}
+}
+
+func (bw *Bandwidth) scheduleLoop() {
+	tckr := time.NewTicker(30 * time.Second)
+	defer tckr.Stop()
+
+	for {
+		select {
+		case <-bw.quitCh:
+			return
+		case <-tckr.C:
+			bw.updateGlobal()
+		}
+	}
+}
+
+func (bw *Bandwidth) close() {
+	bw.quitCh <- true
+}
+
+// Limits returns the global limits that are currently in effect.
+func (bw *Bandwidth) Limits() throttle.Rate {
+	return throttle.Rate{
+		Up:   bw.up.Rate(),
+		Down: bw.down.Rate(),
+	}
+}
+
+// Throughput returns the current throughput in bytes per second.
+func (bw *Bandwidth) Throughput() throttle.Rate {
+	return throttle.Rate{
+		Up:   bw.upMeter.Rate(),
+		Down: bw.downMeter.Rate(),
+	}
+}
+
+// Conn limits `conn` by the global limits. If the remote on the other side
+// is not known yet, pass an empty `remoteName` and call LimitRemote() later.
+func (bw *Bandwidth) Conn(conn net.Conn, remoteName string) *throttle.Conn {
+	limConn := throttle.NewConn(conn, bw.upMeter, bw.downMeter)
+	limConn.Limit(bw.up, bw.down)
+	if remoteName != "" {
+		bw.LimitRemote(limConn, remoteName)
+	}
+
+	return limConn
+}
+
+// LimitRemote adds the limits of the remote `remoteName` to `conn`.
+func (bw *Bandwidth) LimitRemote(conn *throttle.Conn, remoteName string) {
+	remote, err := bw.remotes.Remote(remoteName)
+	if err != nil {
+		return
+	}
+
+	bw.mu.Lock()
+	lims, ok := bw.perRemote[remoteName]
+	if !ok {
+		lims = &remoteLimiters{
+			up:   throttle.NewLimiter(0),
+			down: throttle.NewLimiter(0),
+		}
+
+		bw.perRemote[remoteName] = lims
+	}
+	bw.mu.Unlock()
+
+	lims.up.SetRate(remote.UploadLimit)
+	lims.down.SetRate(remote.DownloadLimit)
+	conn.Limit(lims.up, lims.down)
+}
+
+// Reader limits reading from `r` by the global download limit.
+// Use it for content that is fetched in the background.
+func (bw *Bandwidth) Reader(r io.Reader) io.Reader {
+	return throttle.NewReader(r, bw.downMeter, bw.down)
+}
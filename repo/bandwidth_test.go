@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sahib/brig/defaults"
+	"github.com/sahib/brig/net/peer"
+	"github.com/sahib/brig/util/throttle"
+	"github.com/sahib/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBandwidth(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "brig-repo-bandwidth-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	cfg, err := config.Open(nil, defaults.Defaults, config.StrictnessPanic)
+	require.Nil(t, err)
+
+	remotes, err := NewRemotes(filepath.Join(testDir, "remotes.yml"), "")
+	require.Nil(t, err)
+
+	bw := newBandwidth(cfg, remotes)
+	defer bw.close()
+
+	require.Equal(t, throttle.Rate{}, bw.Limits())
+
+	// Config changes apply immediately:
+	require.Nil(t, cfg.SetString("net.bandwidth.upload", "1MB"))
+	require.Equal(t, throttle.Rate{Up: 1000 * 1000}, bw.Limits())
+
+	// A window that always matches replaces the default:
+	require.Nil(t, cfg.SetStrings("net.bandwidth.schedule", []string{"00:00-00:00 unlimited"}))
+	require.Equal(t, throttle.Rate{}, bw.Limits())
+	require.NotNil(t, cfg.SetStrings("net.bandwidth.schedule", []string{"at night"}))
+
+	// Per remote limits are updated when the remote changes:
+	remote := Remote{
+		Name:        "bob",
+		Fingerprint: peer.Fingerprint("QmBob:W1bob"),
+		UploadLimit: 1000,
+	}
+
+	require.Nil(t, remotes.AddOrUpdateRemote(remote))
+
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	bw.Conn(a, "bob")
+	require.Equal(t, uint64(1000), bw.perRemote["bob"].up.Rate())
+
+	remote.UploadLimit = 2000
+	require.Nil(t, remotes.AddOrUpdateRemote(remote))
+	require.Equal(t, uint64(2000), bw.perRemote["bob"].up.Rate())
+}
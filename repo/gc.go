@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/sahib/brig/metrics"
 	h "github.com/sahib/brig/util/hashlib"
 	log "github.com/sirupsen/logrus"
 )
@@ -24,7 +25,11 @@ func (rp *Repository) GC(backend Backend, aggressive bool) (map[string]map[strin
 		return nil, err
 	}
 
+	metrics.GCRuns.Inc()
+	metrics.GCFreedObjects.Add(float64(len(killed)))
+
 	result := make(map[string]map[string]h.Hash)
+	freed := make(map[string]uint64)
 	if len(killed) == 0 {
 		// Shortcut, since running the loop below
 		// is currently rather expensive due to FilesByContents.
@@ -48,11 +53,17 @@ func (rp *Repository) GC(backend Backend, aggressive bool) (map[string]map[strin
 		subResult := make(map[string]h.Hash)
 		for content, info := range nodeMap {
 			subResult[content] = info.ContentHash
+			freed[content] = info.Size
 		}
 
 		result[owner] = subResult
 	}
 
+	// The same content might be shared by several owners:
+	for _, size := range freed {
+		metrics.GCFreedBytes.Add(float64(size))
+	}
+
 	return result, nil
 }
 
@@ -0,0 +1,250 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/sahib/brig/catfs/vcs"
+	yml "gopkg.in/yaml.v2"
+)
+
+var (
+	// ErrNoSuchGroup is returned when a non-existing group was requested.
+	ErrNoSuchGroup = errors.New("No such group with this name")
+)
+
+// Group is a named set of remotes that share the same folder grants.
+// Every member may access the folders of the group in addition to
+// the folders configured for the member itself.
+type Group struct {
+	// Name is the name of the group.
+	Name string
+
+	// Members is a list of remote names in this group.
+	Members []string
+
+	// Folders is a list of folders the members have access to.
+	Folders []Folder
+}
+
+func loadGroups(path string) (map[string]*Group, error) {
+	groups := make(map[string]*Group)
+	if path == "" {
+		return groups, nil
+	}
+
+	data, err := ioutil.ReadFile(path) // #nosec
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err := yml.Unmarshal(data, groups); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+func (rl *RemoteList) saveGroups() error {
+	if rl.groupPath == "" {
+		rl.notify()
+		return nil
+	}
+
+	data, err := yml.Marshal(rl.groups)
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(rl.groupPath, data, 0600); err != nil {
+		return err
+	}
+
+	rl.notify()
+	return nil
+}
+
+func dedupeStrings(strs []string) []string {
+	seen := make(map[string]bool)
+	newStrs := []string{}
+
+	for _, str := range strs {
+		if seen[str] {
+			continue
+		}
+
+		seen[str] = true
+		newStrs = append(newStrs, str)
+	}
+
+	return newStrs
+}
+
+// AddOrUpdateGroup will add or overwrite the group with the name of `group`.
+// All members need to be in the remote list already.
+func (rl *RemoteList) AddOrUpdateGroup(group Group) error {
+	if group.Name == "" {
+		return fmt.Errorf("group name may not be empty")
+	}
+
+	for _, member := range group.Members {
+		if _, ok := rl.remotes[member]; !ok {
+			return fmt.Errorf("%v: %s", ErrNoSuchRemote, member)
+		}
+	}
+
+	for _, folder := range group.Folders {
+		if folder.ConflictStrategy == "" {
+			continue
+		}
+
+		cs := vcs.ConflictStrategyFromString(folder.ConflictStrategy)
+		if cs == vcs.ConflictStragetyUnknown {
+			return fmt.Errorf("unknown conflict strategy: %s", folder.ConflictStrategy)
+		}
+	}
+
+	group.Members = dedupeStrings(group.Members)
+	group.Folders = dedupeFolders(group.Folders)
+	sort.Strings(group.Members)
+	sort.Slice(group.Folders, func(i, j int) bool {
+		return group.Folders[i].Folder < group.Folders[j].Folder
+	})
+
+	rl.groups[group.Name] = &group
+	return rl.saveGroups()
+}
+
+// RmGroup will remove the group named `name`.
+// The members themselves stay in the remote list.
+func (rl *RemoteList) RmGroup(name string) error {
+	if _, ok := rl.groups[name]; !ok {
+		return ErrNoSuchGroup
+	}
+
+	delete(rl.groups, name)
+	return rl.saveGroups()
+}
+
+// Group returns the group named `name`.
+func (rl *RemoteList) Group(name string) (Group, error) {
+	group, ok := rl.groups[name]
+	if !ok {
+		return Group{}, ErrNoSuchGroup
+	}
+
+	return *group, nil
+}
+
+// ListGroups returns a copy of all groups, sorted by name.
+func (rl *RemoteList) ListGroups() ([]Group, error) {
+	groups := []Group{}
+	for _, group := range rl.groups {
+		groups = append(groups, *group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+
+	return groups, nil
+}
+
+// GroupsOf returns the names of all groups `remoteName` is a member of.
+func (rl *RemoteList) GroupsOf(remoteName string) []string {
+	names := []string{}
+	for _, group := range rl.groups {
+		for _, member := range group.Members {
+			if member == remoteName {
+				names = append(names, group.Name)
+				break
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// forgetMember removes `remoteName` from all groups.
+// It does not save the group list.
+func (rl *RemoteList) forgetMember(remoteName string) bool {
+	changed := false
+	for _, group := range rl.groups {
+		members := []string{}
+		for _, member := range group.Members {
+			if member != remoteName {
+				members = append(members, member)
+			}
+		}
+
+		if len(members) != len(group.Members) {
+			group.Members = members
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// EffectiveFolders returns the union of the folders of `remote`
+// and the folders of all groups it is a member of. Settings of the
+// remote itself take precedence. If several groups grant the same
+// folder, it is only read-only if all of them say so.
+//
+// Note that a remote without own folders may access everything.
+// Adding the group folders to that would not change anything.
+func (rl *RemoteList) EffectiveFolders(remote Remote) []Folder {
+	groupNames := rl.GroupsOf(remote.Name)
+	if len(groupNames) == 0 || len(remote.Folders) == 0 {
+		return remote.Folders
+	}
+
+	folders := append([]Folder{}, remote.Folders...)
+	own := make(map[string]bool)
+	for _, folder := range remote.Folders {
+		own[folder.Folder] = true
+	}
+
+	granted := make(map[string]int)
+	for _, name := range groupNames {
+		for _, folder := range rl.groups[name].Folders {
+			if own[folder.Folder] {
+				continue
+			}
+
+			idx, ok := granted[folder.Folder]
+			if !ok {
+				granted[folder.Folder] = len(folders)
+				folders = append(folders, folder)
+				continue
+			}
+
+			folders[idx].ReadOnly = folders[idx].ReadOnly && folder.ReadOnly
+			if folders[idx].ConflictStrategy == "" {
+				folders[idx].ConflictStrategy = folder.ConflictStrategy
+			}
+		}
+	}
+
+	sort.Slice(folders, func(i, j int) bool {
+		return folders[i].Folder < folders[j].Folder
+	})
+
+	return folders
+}
+
+// EffectiveRemote works like Remote, but the returned remote
+// has its folders replaced by EffectiveFolders.
+func (rl *RemoteList) EffectiveRemote(name string) (Remote, error) {
+	remote, err := rl.Remote(name)
+	if err != nil {
+		return Remote{}, err
+	}
+
+	remote.Folders = rl.EffectiveFolders(remote)
+	return remote, nil
+}
@@ -0,0 +1,92 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroups(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "brig-groups-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	remotePath := filepath.Join(testDir, "remotes.yml")
+	groupPath := filepath.Join(testDir, "groups.yml")
+
+	rl, err := NewRemotes(remotePath, groupPath)
+	require.Nil(t, err)
+
+	require.Nil(t, rl.AddOrUpdateRemote(bobRemote))
+	require.Nil(t, rl.AddOrUpdateRemote(charlieRemote))
+	require.Nil(t, rl.AddOrUpdateRemote(Remote{Name: "dave", Folders: []Folder{{Folder: "/music"}}}))
+	require.Nil(t, rl.AddOrUpdateRemote(Remote{Name: "erin"}))
+
+	require.NotNil(t, rl.AddOrUpdateGroup(Group{Name: "team", Members: []string{"eve"}}))
+	require.Nil(t, rl.AddOrUpdateGroup(Group{
+		Name:    "team",
+		Members: []string{"charlie", "dave", "erin"},
+		Folders: []Folder{
+			{Folder: "/team-docs", ReadOnly: true},
+			{Folder: "/Porns", ReadOnly: true, ConflictStrategy: "embrace"},
+		},
+	}))
+	require.Nil(t, rl.AddOrUpdateGroup(Group{
+		Name:    "writers",
+		Members: []string{"dave"},
+		Folders: []Folder{
+			{Folder: "/team-docs", ConflictStrategy: "ignore"},
+		},
+	}))
+
+	// bob is in no group, so nothing changes for him:
+	require.Equal(t, bobRemote.Folders, rl.EffectiveFolders(bobRemote))
+
+	// Settings of charlie himself win over the group:
+	charlie, err := rl.EffectiveRemote("charlie")
+	require.Nil(t, err)
+	require.Equal(t, []Folder{
+		{Folder: "/Porns"},
+		{Folder: "/team-docs", ReadOnly: true},
+	}, charlie.Folders)
+
+	// dave may write since one of his groups allows it:
+	dave, err := rl.EffectiveRemote("dave")
+	require.Nil(t, err)
+	require.Equal(t, []Folder{
+		{Folder: "/Porns", ReadOnly: true, ConflictStrategy: "embrace"},
+		{Folder: "/music"},
+		{Folder: "/team-docs", ConflictStrategy: "ignore"},
+	}, dave.Folders)
+	require.Equal(t, []string{"team", "writers"}, rl.GroupsOf("dave"))
+
+	// erin has no own folders and may still access everything:
+	erin, err := rl.EffectiveRemote("erin")
+	require.Nil(t, err)
+	require.Empty(t, erin.Folders)
+
+	// Groups survive a reload:
+	rl, err = NewRemotes(remotePath, groupPath)
+	require.Nil(t, err)
+
+	groups, err := rl.ListGroups()
+	require.Nil(t, err)
+	require.Len(t, groups, 2)
+	require.Equal(t, []string{"charlie", "dave", "erin"}, groups[0].Members)
+
+	// Removed remotes are dropped from their groups:
+	require.Nil(t, rl.RmRemote("dave"))
+	team, err := rl.Group("team")
+	require.Nil(t, err)
+	require.Equal(t, []string{"charlie", "erin"}, team.Members)
+
+	require.Nil(t, rl.RmGroup("team"))
+	require.Equal(t, ErrNoSuchGroup, rl.RmGroup("team"))
+
+	charlie, err = rl.EffectiveRemote("charlie")
+	require.Nil(t, err)
+	require.Equal(t, charlieRemote.Folders, charlie.Folders)
+}
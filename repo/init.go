@@ -1,6 +1,7 @@
 package repo
 
 import (
+	"crypto/rand"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -109,10 +110,26 @@ func Init(baseFolder, owner, password, backendName string, daemonPort int64) err
 		return err
 	}
 
+	// The repository is locked with a random master key,
+	// which the password opens via the default key slot.
+	key := make([]byte, masterKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+
+	slot, err := newKeySlot(defaultKeySlotName, KeySlotPassword, password, key)
+	if err != nil {
+		return err
+	}
+
+	if err := writeKeySlots(baseFolder, []KeySlot{*slot}); err != nil {
+		return e.Wrap(err, "Failed to write key slots")
+	}
+
 	// passwd is used to verify the user password,
 	// so it needs to be locked only once on init and
 	// kept out otherwise from the locking machinery.
-	if err := lockFile(passwdFile, keyFromPassword(owner, password)); err != nil {
+	if err := lockFile(passwdFile, key); err != nil {
 		return e.Wrapf(err, "passwd-lock")
 	}
 
@@ -0,0 +1,144 @@
+package repo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	yml "gopkg.in/yaml.v2"
+)
+
+var (
+	// ErrNoSuchInvite is returned by Redeem when the invite
+	// does not exist, was already used or is expired.
+	ErrNoSuchInvite = errors.New("no such invite or invite expired")
+)
+
+// Invite allows a peer that is not yet in our remote list
+// to add itself as remote with the given folders. It can
+// be redeemed only once and only until it expires.
+type Invite struct {
+	// Token is the secret the invited peer has to present.
+	Token string
+
+	// Folders is the list of folders the new remote may access.
+	// If empty, it may access all folders.
+	Folders []Folder
+
+	// Expires is the time after which the invite is no longer valid.
+	Expires time.Time
+}
+
+// InviteList keeps track of all pending invites.
+type InviteList struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewInvites returns a new InviteList that stores its invites at `path`.
+func NewInvites(path string) *InviteList {
+	return &InviteList{path: path}
+}
+
+// load reads all invites that did not expire yet.
+func (il *InviteList) load() (map[string]Invite, error) {
+	invites := make(map[string]Invite)
+	data, err := ioutil.ReadFile(il.path) // #nosec
+	if os.IsNotExist(err) {
+		return invites, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yml.Unmarshal(data, invites); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for token, invite := range invites {
+		if now.After(invite.Expires) {
+			delete(invites, token)
+		}
+	}
+
+	return invites, nil
+}
+
+func (il *InviteList) save(invites map[string]Invite) error {
+	data, err := yml.Marshal(invites)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(il.path, data, 0600)
+}
+
+// Create makes a new invite that is valid for `timeout`.
+func (il *InviteList) Create(folders []Folder, timeout time.Duration) (*Invite, error) {
+	il.mu.Lock()
+	defer il.mu.Unlock()
+
+	invites, err := il.load()
+	if err != nil {
+		return nil, err
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	invite := Invite{
+		Token:   hex.EncodeToString(token),
+		Folders: dedupeFolders(folders),
+		Expires: time.Now().Add(timeout),
+	}
+
+	invites[invite.Token] = invite
+	if err := il.save(invites); err != nil {
+		return nil, err
+	}
+
+	return &invite, nil
+}
+
+// Redeem returns the invite with `token` and removes it,
+// so it can not be used again. ErrNoSuchInvite is returned
+// if there is no such invite or if it expired.
+func (il *InviteList) Redeem(token string) (*Invite, error) {
+	il.mu.Lock()
+	defer il.mu.Unlock()
+
+	invites, err := il.load()
+	if err != nil {
+		return nil, err
+	}
+
+	invite, ok := invites[token]
+	if !ok {
+		return nil, ErrNoSuchInvite
+	}
+
+	delete(invites, token)
+	if err := il.save(invites); err != nil {
+		return nil, err
+	}
+
+	return &invite, nil
+}
+
+// HasPending returns true if there is at least one invite
+// that was not redeemed and did not expire yet.
+func (il *InviteList) HasPending() bool {
+	il.mu.Lock()
+	defer il.mu.Unlock()
+
+	invites, err := il.load()
+	return err == nil && len(invites) > 0
+}
@@ -0,0 +1,47 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvites(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "brig-invites-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	il := NewInvites(filepath.Join(testDir, "invites.yml"))
+	require.False(t, il.HasPending())
+
+	folders := []Folder{{Folder: "/public", ReadOnly: true}}
+	invite, err := il.Create(folders, time.Hour)
+	require.Nil(t, err)
+	require.Len(t, invite.Token, 32)
+	require.True(t, il.HasPending())
+
+	_, err = il.Redeem("wrong")
+	require.Equal(t, ErrNoSuchInvite, err)
+
+	// Invites survive a reload:
+	il = NewInvites(filepath.Join(testDir, "invites.yml"))
+	redeemed, err := il.Redeem(invite.Token)
+	require.Nil(t, err)
+	require.Equal(t, folders, redeemed.Folders)
+
+	// ...but can be used only once:
+	_, err = il.Redeem(invite.Token)
+	require.Equal(t, ErrNoSuchInvite, err)
+	require.False(t, il.HasPending())
+
+	expired, err := il.Create(nil, -time.Second)
+	require.Nil(t, err)
+	require.False(t, il.HasPending())
+
+	_, err = il.Redeem(expired.Token)
+	require.Equal(t, ErrNoSuchInvite, err)
+}
@@ -88,6 +88,46 @@ func decryptAsymetric(folder string, data []byte) ([]byte, error) {
 	return ioutil.ReadAll(md.UnverifiedBody)
 }
 
+// signDetached uses the private key from `folder` to create a detached
+// signature of `data`. Like decryptAsymetric it is not meant for large data.
+func signDetached(folder string, data []byte) ([]byte, error) {
+	prvPath := filepath.Join(folder, "gpg.prv")
+	fd, err := os.Open(prvPath) // #nosec
+	if err != nil {
+		return nil, err
+	}
+
+	defer util.Closer(fd)
+
+	ents, err := openpgp.ReadKeyRing(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ents) == 0 {
+		return nil, fmt.Errorf("no private key in %s", prvPath)
+	}
+
+	sigBuf := &bytes.Buffer{}
+	if err := openpgp.DetachSign(sigBuf, ents[0], bytes.NewReader(data), nil); err != nil {
+		return nil, err
+	}
+
+	return sigBuf.Bytes(), nil
+}
+
+// verifyDetached checks if `sig` is a detached signature of `data`
+// made with the private key belonging to `pubKey`.
+func verifyDetached(pubKey, data, sig []byte) error {
+	ents, err := openpgp.ReadKeyRing(bytes.NewReader(pubKey))
+	if err != nil {
+		return err
+	}
+
+	_, err = openpgp.CheckDetachedSignature(ents, bytes.NewReader(data), bytes.NewReader(sig))
+	return err
+}
+
 // Keyring manages our own keypair and stores the last known
 // pubkeys of other remotes.
 type Keyring struct {
@@ -114,6 +154,17 @@ func (kp *Keyring) Decrypt(data []byte) ([]byte, error) {
 	return decryptAsymetric(kp.folder, data)
 }
 
+// Sign creates a detached signature of `data` with our private key.
+// It can be checked by others with our public key.
+func (kp *Keyring) Sign(data []byte) ([]byte, error) {
+	return signDetached(kp.folder, data)
+}
+
+// Verify checks if `sig` is a signature of `data` made by the owner of `pubKey`.
+func (kp *Keyring) Verify(pubKey, data, sig []byte) error {
+	return verifyDetached(pubKey, data, sig)
+}
+
 // OwnPubKey returns an exported version of our own public key.
 func (kp *Keyring) OwnPubKey() ([]byte, error) {
 	pubPath := filepath.Join(kp.folder, "gpg.pub")
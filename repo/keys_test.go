@@ -29,6 +29,12 @@ func TestKeyring(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, testData, decTestData)
 
+	sig, err := kr.Sign(testData)
+	require.Nil(t, err)
+	require.NotEmpty(t, sig)
+	require.Nil(t, kr.Verify(ownPubKey, testData, sig))
+	require.NotNil(t, kr.Verify(ownPubKey, []byte("Hello?"), sig))
+
 	require.Nil(t, kr.SavePubKey("a", []byte{1}))
 	require.Nil(t, kr.SavePubKey("a", []byte{1}))
 	remotePubKey, err := kr.PubKeyFor("a")
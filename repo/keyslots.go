@@ -0,0 +1,285 @@
+package repo
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	e "github.com/pkg/errors"
+	"github.com/sahib/brig/catfs/mio/encrypt"
+	"github.com/sahib/brig/util"
+)
+
+// Key slots allow to open a repository with more than one secret.
+// The files of a repository are locked with a random master key.
+// Every slot stores a copy of this key, encrypted with a key derived
+// from its secret, which is either a password or the content of a keyfile.
+// Changing a password or adding a recovery key therefore only touches
+// the slot file and never the locked files themselves.
+//
+// Repositories created before key slots existed have no slot file.
+// Their master key is derived from the password and the owner. Once
+// a slot is added or the password is changed, this key is stored
+// in a "default" slot, so existing files stay readable.
+
+const (
+	// KeySlotsName is the name of the file in the repository storing the slots.
+	KeySlotsName = "keyslots.json"
+
+	// KeySlotPassword is the kind of slot opened by a password.
+	KeySlotPassword = "password"
+	// KeySlotKeyfile is the kind of slot opened by the content of a keyfile.
+	KeySlotKeyfile = "keyfile"
+
+	defaultKeySlotName = "default"
+	masterKeySize      = 32
+	keySlotSaltSize    = 32
+)
+
+var (
+	// ErrNoSuchKeySlot is returned when a key slot with a certain name does not exist.
+	ErrNoSuchKeySlot = errors.New("no such key slot")
+	// ErrKeySlotExists is returned when adding a key slot with a name that is already taken.
+	ErrKeySlotExists = errors.New("key slot exists already")
+	// ErrLastKeySlot is returned when trying to remove the only key slot.
+	ErrLastKeySlot = errors.New("refusing to remove the last key slot")
+)
+
+// KeySlot is one way of opening a repository.
+type KeySlot struct {
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Salt is used for deriving the key of the slot from its secret.
+	Salt []byte `json:"salt"`
+
+	// Key is the master key, encrypted with the key of the slot.
+	Key []byte `json:"key"`
+}
+
+func newKeySlot(name, kind, secret string, masterKey []byte) (*KeySlot, error) {
+	salt := make([]byte, keySlotSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	sealed := &bytes.Buffer{}
+	slotKey := util.DeriveKey([]byte(secret), salt, 32)
+	if _, err := encrypt.Encrypt(slotKey, bytes.NewReader(masterKey), sealed); err != nil {
+		return nil, err
+	}
+
+	return &KeySlot{
+		Name:      name,
+		Kind:      kind,
+		CreatedAt: time.Now(),
+		Salt:      salt,
+		Key:       sealed.Bytes(),
+	}, nil
+}
+
+// open returns the master key if `secret` belongs to this slot.
+func (ks *KeySlot) open(secret string) ([]byte, error) {
+	masterKey := &bytes.Buffer{}
+	slotKey := util.DeriveKey([]byte(secret), ks.Salt, 32)
+	if _, err := encrypt.Decrypt(slotKey, bytes.NewReader(ks.Key), masterKey); err != nil {
+		return nil, err
+	}
+
+	return masterKey.Bytes(), nil
+}
+
+// readKeySlots returns nil if the repository has no slot file yet.
+func readKeySlots(baseFolder string) ([]KeySlot, error) {
+	data, err := ioutil.ReadFile(filepath.Join(baseFolder, KeySlotsName)) // #nosec
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	slots := []KeySlot{}
+	if err := json.Unmarshal(data, &slots); err != nil {
+		return nil, e.Wrapf(err, "failed to parse %s", KeySlotsName)
+	}
+
+	return slots, nil
+}
+
+func writeKeySlots(baseFolder string, slots []KeySlot) error {
+	data, err := json.MarshalIndent(slots, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first; a half-written
+	// slot file would make the repository unusable.
+	path := filepath.Join(baseFolder, KeySlotsName)
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+func readOwner(baseFolder string) (string, error) {
+	owner, err := ioutil.ReadFile(filepath.Join(baseFolder, "OWNER")) // #nosec
+	if err != nil {
+		return "", e.Wrap(err, "failed to read OWNER")
+	}
+
+	return string(owner), nil
+}
+
+// masterKeyFromSlots tries `secret` on every slot and returns the
+// master key and the index of the slot it opened.
+func masterKeyFromSlots(slots []KeySlot, secret string) ([]byte, int, error) {
+	for idx := range slots {
+		if key, err := slots[idx].open(secret); err == nil {
+			return key, idx, nil
+		}
+	}
+
+	return nil, -1, ErrBadPassword
+}
+
+// masterKey returns the key the repository at `baseFolder` is locked with.
+// `owner` is only needed for repositories without key slots.
+func masterKey(baseFolder, owner, secret string) ([]byte, error) {
+	slots, err := readKeySlots(baseFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	if slots == nil {
+		return keyFromPassword(owner, secret), nil
+	}
+
+	key, _, err := masterKeyFromSlots(slots, secret)
+	return key, err
+}
+
+// openKeySlots reads the slots of the repository at `baseFolder` and
+// checks that `secret` opens one of them. Repositories without key
+// slots get a default slot for `secret`, which is not written yet.
+func openKeySlots(baseFolder, secret string) ([]KeySlot, []byte, int, error) {
+	if err := CheckPassword(baseFolder, secret); err != nil {
+		return nil, nil, -1, err
+	}
+
+	slots, err := readKeySlots(baseFolder)
+	if err != nil {
+		return nil, nil, -1, err
+	}
+
+	if slots != nil {
+		key, idx, err := masterKeyFromSlots(slots, secret)
+		return slots, key, idx, err
+	}
+
+	owner, err := readOwner(baseFolder)
+	if err != nil {
+		return nil, nil, -1, err
+	}
+
+	key := keyFromPassword(owner, secret)
+	slot, err := newKeySlot(defaultKeySlotName, KeySlotPassword, secret, key)
+	if err != nil {
+		return nil, nil, -1, err
+	}
+
+	return []KeySlot{*slot}, key, 0, nil
+}
+
+// ListKeySlots returns the key slots of the repository at `baseFolder`.
+// Repositories that never changed their password have no slots.
+func ListKeySlots(baseFolder string) ([]KeySlot, error) {
+	slots, err := readKeySlots(baseFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	if slots == nil {
+		return []KeySlot{}, nil
+	}
+
+	return slots, nil
+}
+
+// AddKeySlot adds a slot called `name` of `kind` to the repository at
+// `baseFolder`, which can be opened with `newSecret` afterwards.
+// `secret` has to open one of the existing slots.
+func AddKeySlot(baseFolder, secret, name, kind, newSecret string) error {
+	if kind != KeySlotPassword && kind != KeySlotKeyfile {
+		return e.Errorf("bad key slot kind: %s", kind)
+	}
+
+	slots, key, _, err := openKeySlots(baseFolder, secret)
+	if err != nil {
+		return err
+	}
+
+	for _, slot := range slots {
+		if slot.Name == name {
+			return ErrKeySlotExists
+		}
+	}
+
+	slot, err := newKeySlot(name, kind, newSecret, key)
+	if err != nil {
+		return err
+	}
+
+	return writeKeySlots(baseFolder, append(slots, *slot))
+}
+
+// RemoveKeySlot removes the slot called `name` from the repository at
+// `baseFolder`. `secret` has to open one of the slots. The last slot
+// can not be removed, since the repository could not be opened anymore.
+func RemoveKeySlot(baseFolder, secret, name string) error {
+	slots, _, _, err := openKeySlots(baseFolder, secret)
+	if err != nil {
+		return err
+	}
+
+	for idx, slot := range slots {
+		if slot.Name != name {
+			continue
+		}
+
+		if len(slots) == 1 {
+			return ErrLastKeySlot
+		}
+
+		return writeKeySlots(baseFolder, append(slots[:idx], slots[idx+1:]...))
+	}
+
+	return ErrNoSuchKeySlot
+}
+
+// ChangePassword replaces `oldSecret` with `newSecret` in the slot it opens.
+// Other slots and the locked files of the repository are not touched.
+func ChangePassword(baseFolder, oldSecret, newSecret string) error {
+	slots, key, idx, err := openKeySlots(baseFolder, oldSecret)
+	if err != nil {
+		return err
+	}
+
+	old := slots[idx]
+	slot, err := newKeySlot(old.Name, old.Kind, newSecret, key)
+	if err != nil {
+		return err
+	}
+
+	slots[idx] = *slot
+	return writeKeySlots(baseFolder, slots)
+}
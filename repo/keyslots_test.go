@@ -0,0 +1,83 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeySlots(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "brig-keyslots-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	require.Nil(t, Init(testDir, "alice", "klaus", "mock", 6666))
+	rp, err := Open(testDir, "klaus")
+	require.Nil(t, err)
+	require.Nil(t, rp.Close())
+
+	slots, err := ListKeySlots(testDir)
+	require.Nil(t, err)
+	require.Len(t, slots, 1)
+	require.Equal(t, "default", slots[0].Name)
+
+	require.Equal(t, ErrBadPassword, AddKeySlot(testDir, "wrong", "recovery", KeySlotKeyfile, "secret"))
+	require.Nil(t, AddKeySlot(testDir, "klaus", "recovery", KeySlotKeyfile, "secret"))
+	require.Equal(t, ErrKeySlotExists, AddKeySlot(testDir, "klaus", "recovery", KeySlotKeyfile, "other"))
+
+	// Both slots open the repository:
+	rp, err = Open(testDir, "secret")
+	require.Nil(t, err)
+	require.Nil(t, rp.Close())
+	require.Nil(t, CheckPassword(testDir, "klaus"))
+
+	require.Nil(t, ChangePassword(testDir, "klaus", "hans"))
+	require.Equal(t, ErrBadPassword, CheckPassword(testDir, "klaus"))
+
+	rp, err = Open(testDir, "hans")
+	require.Nil(t, err)
+	require.Nil(t, rp.Close())
+
+	require.Nil(t, RemoveKeySlot(testDir, "hans", "recovery"))
+	require.Equal(t, ErrBadPassword, CheckPassword(testDir, "secret"))
+	require.Equal(t, ErrNoSuchKeySlot, RemoveKeySlot(testDir, "hans", "recovery"))
+	require.Equal(t, ErrLastKeySlot, RemoveKeySlot(testDir, "hans", "default"))
+}
+
+func TestKeySlotsLegacyRepo(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "brig-keyslots-legacy-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(testDir)
+
+	// Lay out a repository like it was created before key slots:
+	key := keyFromPassword("alice", "klaus")
+	passwdPath := filepath.Join(testDir, "passwd")
+	require.Nil(t, ioutil.WriteFile(filepath.Join(testDir, "OWNER"), []byte("alice"), 0600))
+	require.Nil(t, ioutil.WriteFile(passwdPath, []byte("alice"), 0600))
+	require.Nil(t, lockFile(passwdPath, key))
+	require.Nil(t, os.Remove(passwdPath))
+
+	dataPath := filepath.Join(testDir, "remotes.yml")
+	require.Nil(t, ioutil.WriteFile(dataPath, []byte("hello"), 0600))
+	require.Nil(t, LockRepo(testDir, "alice", "klaus", excludedFromLock, excludedFromUnlock))
+
+	slots, err := ListKeySlots(testDir)
+	require.Nil(t, err)
+	require.Len(t, slots, 0)
+
+	require.Nil(t, ChangePassword(testDir, "klaus", "hans"))
+
+	slots, err = ListKeySlots(testDir)
+	require.Nil(t, err)
+	require.Len(t, slots, 1)
+
+	require.Equal(t, ErrBadPassword, CheckPassword(testDir, "klaus"))
+	require.Nil(t, UnlockRepo(testDir, "alice", "hans", excludedFromLock, excludedFromUnlock))
+
+	data, err := ioutil.ReadFile(dataPath)
+	require.Nil(t, err)
+	require.Equal(t, []byte("hello"), data)
+}
@@ -97,13 +97,21 @@ func keyFromPassword(owner, password string) []byte {
 // depending on `user` and `password`. `unlockExcludes` is only used to
 // prevent warnings about not locked files.
 func LockRepo(root, user, password string, lockExcludes, unlockExcludes []string) error {
-	files, err := ioutil.ReadDir(root)
+	// user is not the perfect salt, but pretty much the only available one here.
+	// It is only used for repositories without key slots.
+	key, err := masterKey(root, user, password)
 	if err != nil {
 		return err
 	}
 
-	// user is not the perfect salt, but pretty much the only available one here.
-	key := keyFromPassword(user, password)
+	return lockRepoWithKey(root, key, lockExcludes, unlockExcludes)
+}
+
+func lockRepoWithKey(root string, key []byte, lockExcludes, unlockExcludes []string) error {
+	files, err := ioutil.ReadDir(root)
+	if err != nil {
+		return err
+	}
 
 	for _, info := range files {
 		path := filepath.Join(root, info.Name())
@@ -212,6 +220,15 @@ func unlockDirectory(path string, key []byte) error {
 
 // UnlockRepo is the exact opposite of LockRepo.
 func UnlockRepo(root, user, password string, lockExcludes, unlockExcludes []string) error {
+	key, err := masterKey(root, user, password)
+	if err != nil {
+		return err
+	}
+
+	return unlockRepoWithKey(root, key, lockExcludes, unlockExcludes)
+}
+
+func unlockRepoWithKey(root string, key []byte, lockExcludes, unlockExcludes []string) error {
 	files, err := ioutil.ReadDir(root)
 	if err != nil {
 		return err
@@ -226,8 +243,6 @@ func UnlockRepo(root, user, password string, lockExcludes, unlockExcludes []stri
 		}
 	}
 
-	key := keyFromPassword(user, password)
-
 	for _, info := range files {
 		path := filepath.Join(root, info.Name())
 
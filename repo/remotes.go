@@ -7,7 +7,9 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"sort"
+	"strings"
 
 	"github.com/sahib/brig/catfs/vcs"
 	"github.com/sahib/brig/net/peer"
@@ -46,6 +48,8 @@ type Remote struct {
 
 	// Folders is a list of folders the remote has access to.
 	// If this list is empty, this remote may access all folders.
+	// Folders granted to groups of the remote are not part of this list,
+	// see RemoteList.EffectiveFolders.
 	Folders []Folder
 
 	// Fingerprint is the fingerprint of the remote.
@@ -62,6 +66,24 @@ type Remote struct {
 
 	// AcceptPush will allow this remote to push data to us if true.
 	AcceptPush bool
+
+	// SubscribedFolders is a list of folders of the remote we want to sync.
+	// If this list is empty, the complete tree of the remote is synced.
+	// Unlike Folders, this limits what we take from them, not what they
+	// may take from us.
+	SubscribedFolders []string
+
+	// Quota is the maximum number of bytes that files modified last by
+	// this remote may take in our tree. Syncs that would exceed it fail.
+	// A quota of 0 means no limit.
+	Quota uint64
+
+	// UploadLimit is the max. number of bytes per second we send to this
+	// remote. It applies in addition to the global limits. 0 means no limit.
+	UploadLimit uint64
+
+	// DownloadLimit is like UploadLimit, but for what we receive.
+	DownloadLimit uint64
 }
 
 // ReadOnlyFolders returns the folders that are set to read only
@@ -77,6 +99,24 @@ func (r Remote) ReadOnlyFolders() []string {
 	return folders
 }
 
+// CanAccess returns true if `nodePath` is equal to or below one of the
+// folders of the remote. A remote without folders may access everything.
+func (r Remote) CanAccess(nodePath string) bool {
+	if len(r.Folders) == 0 {
+		return true
+	}
+
+	nodePath = path.Clean("/" + nodePath)
+	for _, folder := range r.Folders {
+		parent := path.Clean("/" + folder.Folder)
+		if parent == "/" || nodePath == parent || strings.HasPrefix(nodePath, parent+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
 // ConflictStrategyPerFolder returns a map of folders (as key)
 // which have a dedicated conflict strategy (as value).
 func (r Remote) ConflictStrategyPerFolder() map[string]string {
@@ -95,12 +135,15 @@ func (r Remote) ConflictStrategyPerFolder() map[string]string {
 // and makes it easily accessible from the Go side.
 type RemoteList struct {
 	remotes   map[string]*Remote
+	groups    map[string]*Group
 	callbacks []func()
 	path      string
+	groupPath string
 }
 
-// NewRemotes returns a new RemoteList.
-func NewRemotes(path string) (*RemoteList, error) {
+// NewRemotes returns a new RemoteList. The remotes are stored at `path`,
+// the groups at `groupPath`. If `groupPath` is empty, groups are not persisted.
+func NewRemotes(path, groupPath string) (*RemoteList, error) {
 	data, err := ioutil.ReadFile(path) // #nosec
 	if err != nil && !os.IsNotExist(err) {
 		return nil, err
@@ -119,9 +162,16 @@ func NewRemotes(path string) (*RemoteList, error) {
 		})
 	}
 
+	groups, err := loadGroups(groupPath)
+	if err != nil {
+		return nil, err
+	}
+
 	return &RemoteList{
-		remotes: remotes,
-		path:    path,
+		remotes:   remotes,
+		groups:    groups,
+		path:      path,
+		groupPath: groupPath,
 	}, nil
 }
 
@@ -191,6 +241,12 @@ func (rl *RemoteList) RmRemote(name string) error {
 	}
 
 	delete(rl.remotes, name)
+	if rl.forgetMember(name) {
+		if err := rl.saveGroups(); err != nil {
+			return err
+		}
+	}
+
 	return rl.save()
 }
 
@@ -208,6 +264,14 @@ func (rl *RemoteList) Remote(name string) (Remote, error) {
 // Clear will remove all of the remote list.
 func (rl *RemoteList) Clear() error {
 	rl.remotes = make(map[string]*Remote)
+	for _, group := range rl.groups {
+		group.Members = []string{}
+	}
+
+	if err := rl.saveGroups(); err != nil {
+		return err
+	}
+
 	return rl.save()
 }
 
@@ -244,6 +308,22 @@ func (rl *RemoteList) SaveList(remotes []Remote) error {
 		})
 	}
 
+	// Remotes that are gone should not be part of any group anymore:
+	groupsChanged := false
+	for _, group := range rl.groups {
+		for _, member := range group.Members {
+			if _, ok := rl.remotes[member]; !ok && rl.forgetMember(member) {
+				groupsChanged = true
+			}
+		}
+	}
+
+	if groupsChanged {
+		if err := rl.saveGroups(); err != nil {
+			return err
+		}
+	}
+
 	return rl.save()
 }
 
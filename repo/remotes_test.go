@@ -40,12 +40,12 @@ func TestRemotesReload(t *testing.T) {
 	defer require.Nil(t, os.Remove(fd.Name()))
 	defer require.Nil(t, fd.Close())
 
-	rl1, err := NewRemotes(fd.Name())
+	rl1, err := NewRemotes(fd.Name(), "")
 	require.Nil(t, err)
 
 	require.Nil(t, rl1.AddOrUpdateRemote(bobRemote))
 
-	rl2, err := NewRemotes(fd.Name())
+	rl2, err := NewRemotes(fd.Name(), "")
 	require.Nil(t, err)
 
 	remotes, err := rl2.ListRemotes()
@@ -66,7 +66,7 @@ func TestRemoteOps(t *testing.T) {
 	defer require.Nil(t, os.Remove(fd.Name()))
 	defer require.Nil(t, fd.Close())
 
-	rl, err := NewRemotes(fd.Name())
+	rl, err := NewRemotes(fd.Name(), "")
 	require.Nil(t, err)
 
 	require.Nil(t, rl.AddOrUpdateRemote(bobRemote))
@@ -100,3 +100,17 @@ func TestRemoteOps(t *testing.T) {
 	require.Equal(t, remotes[0], bobRemote)
 	require.Equal(t, remotes[1], charlieRemote)
 }
+
+func TestRemoteCanAccess(t *testing.T) {
+	require.True(t, Remote{}.CanAccess("/anything"))
+
+	rm := Remote{Folders: []Folder{{Folder: "/photos"}, {Folder: "/docs/"}}}
+	require.True(t, rm.CanAccess("/photos"))
+	require.True(t, rm.CanAccess("/photos/cat.png"))
+	require.True(t, rm.CanAccess("/docs/a.txt"))
+	require.False(t, rm.CanAccess("/photosynthesis"))
+	require.False(t, rm.CanAccess("/"))
+
+	rm.Folders = append(rm.Folders, Folder{Folder: "/"})
+	require.True(t, rm.CanAccess("/photosynthesis"))
+}
@@ -8,6 +8,7 @@ import (
 	"sync"
 
 	e "github.com/pkg/errors"
+	"github.com/sahib/brig/audit"
 	"github.com/sahib/brig/catfs"
 	fserr "github.com/sahib/brig/catfs/errors"
 	"github.com/sahib/brig/defaults"
@@ -17,7 +18,10 @@ import (
 
 var (
 	// Do not encrypt "data" (already contains encrypted streams) and
-	excludedFromLock   = []string{"data", "OWNER", "BACKEND", "REPO_ID", "config.yml"}
+	excludedFromLock = []string{
+		"data", "OWNER", "BACKEND", "REPO_ID", "config.yml", KeySlotsName,
+		defaults.DaemonSocketName, defaults.DaemonHTTPSocketName, defaults.DaemonTokenName,
+	}
 	excludedFromUnlock = []string{"passwd.locked"}
 )
 
@@ -34,14 +38,19 @@ var (
 // BACKEND
 // REPO_ID
 // remotes.yml
+// groups.yml
+// invites.yml
 // data/
-//    <backend_name>
-//        (data-backend specific)
+//
+//	<backend_name>
+//	    (data-backend specific)
+//
 // metadata/
-//    <name_1>
-//        (fs-backend specific)
-//    <name_2>
-//        (fs-backend specific)
+//
+//	<name_1>
+//	    (fs-backend specific)
+//	<name_2>
+//	    (fs-backend specific)
 type Repository struct {
 	mu sync.Mutex
 
@@ -63,8 +72,20 @@ type Repository struct {
 	// Remotes gives access to all known remotes
 	Remotes *RemoteList
 
+	// Invites are the pending invites for new remotes
+	Invites *InviteList
+
+	// Bandwidth limits and measures transfers to other peers
+	Bandwidth *Bandwidth
+
+	// Audit records what was done with the repository
+	Audit *audit.Log
+
 	// channel to control the auto gc loop
 	autoGCControl chan bool
+
+	// key the repository is locked with on Close()
+	masterKey []byte
 }
 
 // CheckPassword will try to validate `password` by decrypting something
@@ -83,13 +104,21 @@ func CheckPassword(baseFolder, password string) error {
 
 	// Try to get the owner of the repo.
 	// Needed for the key derivation function.
-	ownerPath := filepath.Join(baseFolder, "OWNER")
-	owner, err := ioutil.ReadFile(ownerPath) // #nosec
+	owner, err := readOwner(baseFolder)
 	if err != nil {
-		return e.Wrap(err, "failed to read OWNER")
+		return err
+	}
+
+	key, err := masterKey(baseFolder, owner, password)
+	if err == ErrBadPassword {
+		log.Warningf("Password does not open any key slot. Wrong password entered?")
+		return err
+	}
+
+	if err != nil {
+		return err
 	}
 
-	key := keyFromPassword(string(owner), password)
 	if err := checkUnlockability(passwdFile, key); err != nil {
 		log.Warningf("Failed to unlock passwd file. Wrong password entered?")
 		return ErrBadPassword
@@ -107,35 +136,32 @@ func Open(baseFolder, password string) (*Repository, error) {
 		return nil, err
 	}
 
-	ownerPath := filepath.Join(baseFolder, "OWNER")
-	owner, err := ioutil.ReadFile(ownerPath) // #nosec
+	owner, err := readOwner(baseFolder)
 	if err != nil {
-		return nil, e.Wrap(err, "failed to read OWNER")
+		return nil, err
 	}
 
-	err = UnlockRepo(
-		baseFolder,
-		string(owner),
-		password,
-		excludedFromLock,
-		excludedFromUnlock,
-	)
-
+	key, err := masterKey(baseFolder, owner, password)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := unlockRepoWithKey(baseFolder, key, excludedFromLock, excludedFromUnlock); err != nil {
+		return nil, err
+	}
+
 	cfgPath := filepath.Join(baseFolder, "config.yml")
 	cfg, err := defaults.OpenMigratedConfig(cfgPath)
 	if err != nil {
 		return nil, err
 	}
 
-	cfg.SetString("repo.current_user", string(owner))
+	cfg.SetString("repo.current_user", owner)
 
 	// Load the remote list:
 	remotePath := filepath.Join(baseFolder, "remotes.yml")
-	remotes, err := NewRemotes(remotePath)
+	groupPath := filepath.Join(baseFolder, "groups.yml")
+	remotes, err := NewRemotes(remotePath, groupPath)
 	if err != nil {
 		return nil, err
 	}
@@ -146,29 +172,37 @@ func Open(baseFolder, password string) (*Repository, error) {
 		return nil, err
 	}
 
+	auditLog, err := audit.Open(filepath.Join(baseFolder, "audit.log"))
+	if err != nil {
+		return nil, e.Wrap(err, "failed to open audit log")
+	}
+
 	rp := &Repository{
 		BaseFolder:    baseFolder,
 		backendName:   string(backendName),
 		Config:        cfg,
 		Remotes:       remotes,
-		Owner:         string(owner),
+		Invites:       NewInvites(filepath.Join(baseFolder, "invites.yml")),
+		Bandwidth:     newBandwidth(cfg, remotes),
+		Audit:         auditLog,
+		Owner:         owner,
 		fsMap:         make(map[string]*catfs.FS),
 		autoGCControl: make(chan bool, 1),
+		masterKey:     key,
 	}
 
 	return rp, nil
 }
 
 // Close will lock the repository, making this instance unusable.
-func (rp *Repository) Close(password string) error {
+func (rp *Repository) Close() error {
 	rp.stopAutoGCLoop()
-	return LockRepo(
-		rp.BaseFolder,
-		rp.Owner,
-		password,
-		excludedFromLock,
-		excludedFromUnlock,
-	)
+	rp.Bandwidth.close()
+	if err := rp.Audit.Close(); err != nil {
+		log.Warningf("failed to close audit log: %v", err)
+	}
+
+	return lockRepoWithKey(rp.BaseFolder, rp.masterKey, excludedFromLock, excludedFromUnlock)
 }
 
 // BackendName returns the backend name used when constructing the repo.
@@ -217,6 +251,24 @@ func (rp *Repository) FS(owner string, bk catfs.FsBackend) (*catfs.FS, error) {
 		return nil, err
 	}
 
+	fs.SetContentThrottle(rp.Bandwidth.Reader)
+
+	// Our own commits are signed; history of others
+	// is checked against the last key we know of them.
+	kr := rp.Keyring()
+	if owner == rp.Owner {
+		fs.SetSigner(kr.Sign)
+		if pubKey, err := kr.OwnPubKey(); err == nil {
+			fs.SetVerifier(func(data, sig []byte) error {
+				return kr.Verify(pubKey, data, sig)
+			})
+		}
+	} else if pubKey, err := kr.PubKeyFor(owner); err == nil {
+		fs.SetVerifier(func(data, sig []byte) error {
+			return kr.Verify(pubKey, data, sig)
+		})
+	}
+
 	// Create an initial commit if there was none yet:
 	if _, err := fs.Head(); fserr.IsErrNoSuchRef(err) {
 		if err := fs.MakeCommit("initial commit"); err != nil {
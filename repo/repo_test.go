@@ -39,7 +39,7 @@ func TestRepoInit(t *testing.T) {
 	require.Equal(t, data, []byte{1, 2, 3})
 
 	require.Nil(t, fs.Close())
-	require.Nil(t, rp.Close("klaus"))
+	require.Nil(t, rp.Close())
 
 }
 
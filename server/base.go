@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
@@ -18,14 +19,18 @@ import (
 	_ "net/http/pprof"
 
 	e "github.com/pkg/errors"
+	"github.com/sahib/brig/audit"
 	"github.com/sahib/brig/backend"
 	"github.com/sahib/brig/catfs"
 	fserrs "github.com/sahib/brig/catfs/errors"
 	"github.com/sahib/brig/events"
 	"github.com/sahib/brig/fuse"
 	"github.com/sahib/brig/gateway"
+	"github.com/sahib/brig/metrics"
 	p2pnet "github.com/sahib/brig/net"
+	"github.com/sahib/brig/net/lan"
 	"github.com/sahib/brig/net/peer"
+	"github.com/sahib/brig/net/rendezvous"
 	"github.com/sahib/brig/repo"
 	"github.com/sahib/brig/server/capnp"
 	"github.com/sahib/brig/util/conductor"
@@ -61,7 +66,11 @@ type base struct {
 
 	// This the general backend, not a specific submodule one:
 	backend backend.Backend
-	quitCh  chan struct{}
+
+	// backends is shared by all repositories of the daemon.
+	backends *backendPool
+
+	quitCh chan struct{}
 
 	conductor *conductor.Conductor
 
@@ -82,6 +91,15 @@ type base struct {
 
 	// pprofPort is the port pprof can acquire profiling from
 	pprofPort int
+
+	// metricsSrv serves the metrics if enabled; nil otherwise.
+	metricsSrv *http.Server
+
+	// replicas knows what content our online remotes have pinned.
+	replicas *replicaTable
+
+	// replicaCancel stops the replica loop.
+	replicaCancel context.CancelFunc
 }
 
 func repoIsInitialized(path string) error {
@@ -97,9 +115,8 @@ func repoIsInitialized(path string) error {
 	return nil
 }
 
-// Handle is being called by the base server implementation
-// for every local request that is being served to the brig daemon.
-func (b *base) Handle(ctx context.Context, conn net.Conn) {
+// serve answers the requests of an authenticated client on `conn`.
+func (b *base) serve(conn net.Conn) {
 	transport := rpc.StreamTransport(conn)
 	srv := capnp.API_ServerToClient(newAPIHandler(b))
 	rpcConn := rpc.NewConn(
@@ -178,6 +195,37 @@ func (b *base) loadProfileServer() {
 	b.pprofPort = port
 }
 
+func (b *base) loadMetricsServer() error {
+	cfg := b.repo.Config.Section("metrics")
+	if !cfg.Bool("enabled") {
+		log.Debugf("not loading metrics server; not enabled in config")
+		return nil
+	}
+
+	addr := net.JoinHostPort(cfg.String("host"), strconv.FormatInt(cfg.Int("port"), 10))
+	lst, err := net.Listen("tcp", addr)
+	if err != nil {
+		return e.Wrapf(err, "metrics")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(cfg.String("path"), metrics.Default.Handler())
+	b.metricsSrv = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Infof("serving metrics on http://%s%s", addr, cfg.String("path"))
+
+	go func() {
+		if err := b.metricsSrv.Serve(lst); err != nil && err != http.ErrServerClosed {
+			log.Warningf("failed to serve metrics: %v", err)
+		}
+	}()
+
+	return nil
+}
+
 /////////
 
 func (b *base) loadBackend() error {
@@ -191,7 +239,7 @@ func (b *base) loadBackend() error {
 
 	fingerprint := peer.BuildFingerprint("", pubKey)
 
-	realBackend, err := backend.FromName(
+	realBackend, err := b.backends.get(
 		backendName,
 		b.repo.Config.String("daemon.ipfs_path"),
 		fingerprint.PubKeyID(),
@@ -202,8 +250,44 @@ func (b *base) loadBackend() error {
 		return err
 	}
 
+	if b.repo.Config.Bool("net.lan.enabled") {
+		log.Infof("using the local network to talk to other peers")
+		realBackend, err = backend.WithLAN(realBackend, lan.Options{
+			Group:     b.repo.Config.String("net.lan.group"),
+			Port:      int(b.repo.Config.Int("net.lan.port")),
+			Exclusive: b.repo.Config.Bool("net.lan.exclusive"),
+		})
+
+		if err != nil {
+			log.Errorf("Failed to setup local network transport: %v", err)
+			return err
+		}
+	}
+
+	if b.repo.Config.Bool("net.rendezvous.enabled") {
+		server := b.repo.Config.String("net.rendezvous.server")
+		log.Infof("using rendezvous server at %s", server)
+		realBackend, err = backend.WithRendezvous(realBackend, b.repo.Keyring(), rendezvous.Options{
+			Server:       server,
+			Fingerprints: b.knownFingerprints,
+		})
+
+		if err != nil {
+			log.Errorf("Failed to setup rendezvous client: %v", err)
+			return err
+		}
+	}
+
+	realBackend = backend.WithMetrics(realBackend)
+
 	b.backend = realBackend
 	b.repo.StartAutoGCLoop(realBackend)
+
+	// Content of a restored backup is waiting to be added again:
+	if err := b.repo.AddRestoredContent(realBackend); err != nil {
+		log.Warningf("failed to add restored content: %v", err)
+	}
+
 	return nil
 }
 
@@ -264,6 +348,10 @@ func (b *base) loadPeerServer() error {
 		log.Warningf("initial sync failed with one or more peers: %v", err)
 	}
 
+	var replicaCtx context.Context
+	replicaCtx, b.replicaCancel = context.WithCancel(b.ctx)
+	go b.replicaLoop(replicaCtx)
+
 	// Now that we boooted up, we should tell other users that our fs changed.
 	// It may or may not have, but other remotes judge that.
 	b.notifyFsChangeEvent()
@@ -290,6 +378,7 @@ func (b *base) loadGateway() error {
 		}
 
 		b.gateway = gateway
+		b.gateway.SetAuditLog(b.repo.Audit)
 		b.gateway.Start()
 		return nil
 	})
@@ -335,12 +424,31 @@ func (b *base) loadAll() error {
 		return err
 	}
 
-	b.loadProfileServer()
 	return nil
 }
 
 /////////
 
+// recordAudit adds an entry for an action done via the API to the audit log.
+// All those actions are attributed to the owner of the repository.
+func (b *base) recordAudit(action, path, detail string) {
+	if b.repo == nil {
+		return
+	}
+
+	err := b.repo.Audit.Record(audit.Entry{
+		Source: audit.SourceCLI,
+		User:   b.repo.Owner,
+		Action: action,
+		Path:   path,
+		Detail: detail,
+	})
+
+	if err != nil {
+		log.Warningf("failed to record audit entry: %v", err)
+	}
+}
+
 func (b *base) withCurrFs(fn func(fs *catfs.FS) error) error {
 	user := b.repo.CurrentUser()
 	fs, err := b.repo.FS(user, b.backend)
@@ -378,7 +486,13 @@ func (b *base) withFsFromPath(path string, fn func(url *URL, fs *catfs.FS) error
 }
 
 func (b *base) withNetClient(who string, fn func(ctl *p2pnet.Client) error) error {
-	subCtx, cancel := context.WithCancel(b.ctx)
+	return b.withNetClientContext(b.ctx, who, fn)
+}
+
+// withNetClientContext works like withNetClient, but all network operations
+// done with the client will be stopped once `ctx` is canceled.
+func (b *base) withNetClientContext(ctx context.Context, who string, fn func(ctl *p2pnet.Client) error) error {
+	subCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	ctl, err := p2pnet.Dial(subCtx, who, b.repo, b.backend, b.peerServer.PingMap())
@@ -405,12 +519,22 @@ func (b *base) Quit() (err error) {
 		log.Warningf("could not shut down gateway: %v", err)
 	}
 
+	if b.metricsSrv != nil {
+		if err := b.metricsSrv.Close(); err != nil {
+			log.Warningf("could not close metrics server: %v", err)
+		}
+	}
+
 	log.Infof("closing peer server...")
 	if err = b.peerServer.Close(); err != nil {
 		log.Warningf("failed to close peer server: %v", err)
 	}
 
 	b.evListenerCancel()
+	if b.replicaCancel != nil {
+		b.replicaCancel()
+	}
+
 	log.Infof("shutting down event listener...")
 	if b.evListener != nil {
 		if err := b.evListener.Close(); err != nil {
@@ -420,7 +544,7 @@ func (b *base) Quit() (err error) {
 
 	log.Infof("trying to lock repository...")
 
-	if err = b.repo.Close(b.password); err != nil {
+	if err = b.repo.Close(); err != nil {
 		log.Warningf("failed to lock repository: %v", err)
 	}
 
@@ -441,6 +565,7 @@ func newBase(
 	bindHost string,
 	quitCh chan struct{},
 	logToStdout bool,
+	backends *backendPool,
 ) *base {
 	return &base{
 		ctx:         ctx,
@@ -450,55 +575,152 @@ func newBase(
 		bindHost:    bindHost,
 		quitCh:      quitCh,
 		logToStdout: logToStdout,
+		backends:    backends,
 		conductor:   conductor.New(5*time.Minute, 100),
+		replicas:    newReplicaTable(),
+	}
+}
+
+// receiveReporter returns a function for Client.OnReceive that reports
+// the received bytes to `rep`. The total is not known until the call is done.
+func receiveReporter(rep *jobReporter) func(n int64) {
+	received := int64(0)
+	return func(n int64) {
+		received += n
+		rep.Bytes(received, 0)
 	}
 }
 
-func (b *base) doFetch(who string) error {
+// knownFingerprints returns the fingerprints of all remotes named `name`.
+func (b *base) knownFingerprints(name string) []peer.Fingerprint {
+	remotes, err := b.repo.Remotes.ListRemotes()
+	if err != nil {
+		log.Warningf("failed to list remotes: %v", err)
+		return nil
+	}
+
+	fingerprints := []peer.Fingerprint{}
+	for _, remote := range remotes {
+		if remote.Name == name {
+			fingerprints = append(fingerprints, remote.Fingerprint)
+		}
+	}
+
+	return fingerprints
+}
+
+// doFetch updates our local copy of the metadata of `who`.
+// The fetch can be stopped by canceling `ctx`; progress
+// is reported to `rep`, which may be nil.
+func (b *base) doFetch(ctx context.Context, who string, rep *jobReporter) error {
 	if who == b.repo.Owner {
 		log.Infof("skipping fetch for own metadata")
 		return nil
 	}
 
-	return b.withNetClient(who, func(ctl *p2pnet.Client) error {
+	rep.Phase("dial")
+	return b.withNetClientContext(ctx, who, func(ctl *p2pnet.Client) error {
 		return b.withRemoteFs(who, func(remoteFs *catfs.FS) error {
-			// Not all remotes might allow doing a full fetch.
-			// This is only possible when having full access to all folders.
-			if isAllowed, err := ctl.IsCompleteFetchAllowed(); isAllowed && err != nil {
-				log.Debugf("fetch: doing complete fetch for %s", who)
-				storeBuf, err := ctl.FetchStore()
-				if err != nil {
-					return e.Wrapf(err, "fetch-store")
-				}
-
-				return e.Wrapf(remoteFs.Import(storeBuf), "import")
+			// Check the remote's history against the key it
+			// authenticated with and remember that key for later.
+			kr := b.repo.Keyring()
+			remotePubKey := ctl.RemotePubKey()
+			if err := kr.SavePubKey(who, remotePubKey); err != nil {
+				log.Warningf("failed to save public key of %s: %v", who, err)
 			}
 
+			remoteFs.SetVerifier(func(data, sig []byte) error {
+				return kr.Verify(remotePubKey, data, sig)
+			})
+
 			// Ask our local copy of the remote what the last patch index was.
 			fromIndex, err := remoteFs.LastPatchIndex()
 			if err != nil {
 				return err
 			}
 
+			// Not all remotes might allow doing a full fetch.
+			// This is only possible when having full access to all folders.
+			// It is only done on the first fetch, since importing the store
+			// would replace the history we merged with before.
+			if fromIndex == 0 {
+				isAllowed, err := ctl.IsCompleteFetchAllowed()
+				if err != nil {
+					log.Warningf("fetch: failed to check for complete fetch with %s: %v", who, err)
+				}
+
+				if err == nil && isAllowed {
+					log.Debugf("fetch: doing complete fetch for %s", who)
+					rep.Phase("fetch-store")
+					ctl.OnReceive(receiveReporter(rep))
+					storeBuf, err := ctl.FetchStore()
+					ctl.OnReceive(nil)
+					if err != nil {
+						return e.Wrapf(err, "fetch-store")
+					}
+
+					size := int64(storeBuf.Len())
+					rep.Bytes(size, size)
+					if err := checkCanceled(ctx); err != nil {
+						return err
+					}
+
+					rep.Phase("import")
+					return e.Wrapf(remoteFs.Import(storeBuf), "import")
+				}
+			}
+
 			// Get the missing changes since then:
 			log.Debugf("fetch: doing partial fetch for %s starting at %d", who, fromIndex)
+			rep.Phase("fetch-patch")
+			ctl.OnReceive(receiveReporter(rep))
 			patch, err := ctl.FetchPatch(fromIndex)
+			ctl.OnReceive(nil)
 			if err != nil {
 				return err
 			}
 
+			size := int64(len(patch))
+			rep.Bytes(size, size)
+			if err := checkCanceled(ctx); err != nil {
+				return err
+			}
+
+			rep.Phase("apply-patch")
 			return remoteFs.ApplyPatch(patch)
 		})
 	})
 }
 
-func (b *base) doSync(withWhom string, needFetch bool, msg string) (*catfs.Diff, error) {
+// doSync fetches the latest state of `withWhom` (if `needFetch` is true)
+// and merges it with our own state. The resulting diff is returned.
+// If `onlyFolders` is empty, the subscribed folders of the remote are
+// synced, or everything if there are none.
+// Canceling `ctx` stops the sync before the merge. The merge
+// itself can not be interrupted. If the merge would exceed the
+// folder quotas or the quota of the remote, nothing is merged.
+func (b *base) doSync(ctx context.Context, withWhom string, needFetch bool, msg string, onlyFolders []string, rep *jobReporter) (*catfs.Diff, error) {
+	start := time.Now()
+	diff, err := b.doSyncUnmeasured(ctx, withWhom, needFetch, msg, onlyFolders, rep)
+	metrics.SyncDuration.With(withWhom).ObserveSince(start)
+	if err != nil {
+		metrics.SyncErrors.With(withWhom).Inc()
+	}
+
+	return diff, err
+}
+
+func (b *base) doSyncUnmeasured(ctx context.Context, withWhom string, needFetch bool, msg string, onlyFolders []string, rep *jobReporter) (*catfs.Diff, error) {
 	if needFetch {
-		if err := b.doFetch(withWhom); err != nil {
+		if err := b.doFetch(ctx, withWhom, rep); err != nil {
 			return nil, e.Wrapf(err, "fetch")
 		}
 	}
 
+	if err := checkCanceled(ctx); err != nil {
+		return nil, err
+	}
+
 	var diff *catfs.Diff
 
 	return diff, b.withCurrFs(func(ownFs *catfs.FS) error {
@@ -517,25 +739,44 @@ func (b *base) doSync(withWhom string, needFetch bool, msg string) (*catfs.Diff,
 
 			log.Debugf("Starting sync with %s", withWhom)
 
-			rmt, err := b.repo.Remotes.Remote(withWhom)
+			rmt, err := b.repo.Remotes.EffectiveRemote(withWhom)
 			if err != nil {
 				return err
 			}
 
-			err = ownFs.Sync(
-				remoteFs,
+			if len(onlyFolders) == 0 {
+				onlyFolders = rmt.SubscribedFolders
+			}
+
+			syncOpts := []catfs.SyncOption{
 				catfs.SyncOptMessage(msg),
 				catfs.SyncOptConflictStrategy(rmt.ConflictStrategy),
 				catfs.SyncOptReadOnlyFolders(rmt.ReadOnlyFolders()),
 				catfs.SyncOptConflictgStrategyPerFolder(rmt.ConflictStrategyPerFolder()),
-			)
+				catfs.SyncOptOnlyFolders(onlyFolders),
+			}
 
-			if err != nil {
+			rep.Phase("quota")
+			if err := ownFs.CheckSyncQuota(remoteFs, rmt.Name, rmt.Quota, syncOpts...); err != nil {
+				return err
+			}
+
+			rep.Phase("merge")
+			syncOpts = append(syncOpts, catfs.SyncOptProgress(func(done int64) {
+				rep.Files(done, 0)
+			}))
+
+			syncOpts = append(syncOpts, catfs.SyncOptOnConflict(func(path string) {
+				metrics.SyncConflicts.With(withWhom).Inc()
+			}))
+
+			if err := ownFs.Sync(remoteFs, syncOpts...); err != nil {
 				return err
 			}
 
 			log.Debugf("Sync with %s done", withWhom)
 
+			rep.Phase("diff")
 			cmtAfter, err := ownFs.Head()
 			if err != nil {
 				return err
@@ -547,6 +788,34 @@ func (b *base) doSync(withWhom string, needFetch bool, msg string) (*catfs.Diff,
 	})
 }
 
+// doPush asks `remoteName` to sync with us.
+// If `dryRun` is true, only the permission to do so is checked.
+func (b *base) doPush(ctx context.Context, remoteName string, dryRun bool, rep *jobReporter) error {
+	rep.Phase("dial")
+	return b.withNetClientContext(ctx, remoteName, func(ctl *p2pnet.Client) error {
+		rep.Phase("check")
+		pushAllowed, err := ctl.IsPushAllowed()
+		if err != nil {
+			return err
+		}
+
+		if !pushAllowed {
+			return fmt.Errorf("cannot push: remote does not allow it")
+		}
+
+		if dryRun {
+			return nil
+		}
+
+		if err := checkCanceled(ctx); err != nil {
+			return err
+		}
+
+		rep.Phase("push")
+		return ctl.Push()
+	})
+}
+
 func (b *base) handleFsEvent(ev *events.Event) {
 	rmt, err := b.repo.Remotes.RemoteByAddr(ev.Source)
 	if err != nil {
@@ -561,7 +830,7 @@ func (b *base) handleFsEvent(ev *events.Event) {
 	log.Infof("doing sync with »%s« since we received an update notification.", rmt.Name)
 
 	msg := fmt.Sprintf("sync due to notification from »%s«", rmt.Name)
-	if _, err := b.doSync(rmt.Name, true, msg); err != nil {
+	if _, err := b.doSync(b.ctx, rmt.Name, true, msg, nil, nil); err != nil {
 		log.Warningf("sync failed: %v", err)
 	}
 }
@@ -597,7 +866,7 @@ func (b *base) initialSyncWithAutoUpdatePeers() error {
 		}
 
 		msg := fmt.Sprintf("sync with »%s« due to initial auto-update", rmt.Name)
-		if _, err := b.doSync(rmt.Name, true, msg); err != nil {
+		if _, err := b.doSync(b.ctx, rmt.Name, true, msg, nil, nil); err != nil {
 			log.Warningf("failed to sync initially with %s: %v", rmt.Name, err)
 		}
 	}
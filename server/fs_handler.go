@@ -1,13 +1,17 @@
 package server
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"io"
-	"net"
 	"os"
+	"path/filepath"
+	"sort"
 
 	"github.com/sahib/brig/catfs"
 	ie "github.com/sahib/brig/catfs/errors"
+	n "github.com/sahib/brig/catfs/nodes"
 	"github.com/sahib/brig/server/capnp"
 	log "github.com/sirupsen/logrus"
 	capnplib "zombiezen.com/go/capnproto2"
@@ -59,6 +63,10 @@ func statToCapnp(info *catfs.StatInfo, seg *capnplib.Segment) (*capnp.StatInfo,
 	capInfo.SetDepth(int32(info.Depth))
 	capInfo.SetIsPinned(info.IsPinned)
 	capInfo.SetIsExplicit(info.IsExplicit)
+	if err := capInfo.SetKeyScheme(info.KeyScheme); err != nil {
+		return nil, err
+	}
+
 	return &capInfo, nil
 }
 
@@ -132,6 +140,7 @@ func (fh *fsHandler) Stage(call capnp.FS_stage) error {
 			return err
 		}
 
+		fh.base.recordAudit("fs.stage", url.Path, localPath)
 		fh.base.notifyFsChangeEvent()
 		return nil
 	})
@@ -162,26 +171,13 @@ func (fh *fsHandler) Cat(call capnp.FS_cat) error {
 			return err
 		}
 
-		port, err := bootTransferServer(fs, fh.base.bindHost, func(conn net.Conn) {
-			defer stream.Close()
-			localAddr := conn.LocalAddr().String()
-
-			n, err := io.Copy(conn, stream)
-			if err != nil {
-				log.Warningf("IO failed for path %s on %s: %v", path, localAddr, err)
-				return
-			}
-
-			log.Infof("Wrote %d bytes of `%s` over %s", n, path, localAddr)
-		})
-
-		if err != nil {
-			// Close the stream, since the copy callback was likely not called.
+		if err := call.Results.SetStream(newStream(path, stream)); err != nil {
+			// Close the stream, since the client will never release it.
 			stream.Close()
 			return err
 		}
 
-		call.Results.SetPort(int32(port))
+		fh.base.recordAudit("fs.read", url.Path, "")
 		return nil
 	})
 }
@@ -210,15 +206,25 @@ func (fh *fsHandler) Tar(call capnp.FS_tar) error {
 			return err
 		}
 
-		port, err := bootTransferServer(fs, fh.base.bindHost, func(conn net.Conn) {
-			localAddr := conn.LocalAddr().String()
-			if err := fs.Tar(path, conn, nil); err != nil {
-				log.Warningf("tar failed for path %s on %s: %v", path, localAddr, err)
+		pr, pw := io.Pipe()
+		go func() {
+			// This stops once the client released the stream (closing pr).
+			if err := fs.Tar(path, pw, nil); err != nil {
+				log.Warningf("tar failed for path %s: %v", path, err)
+				pw.CloseWithError(err)
+				return
 			}
-		})
 
-		call.Results.SetPort(int32(port))
-		return err
+			pw.Close()
+		}()
+
+		if err := call.Results.SetStream(newStream(path, pr)); err != nil {
+			pr.Close()
+			return err
+		}
+
+		fh.base.recordAudit("fs.read", url.Path, "archive.tar")
+		return nil
 	})
 }
 
@@ -236,6 +242,7 @@ func (fh *fsHandler) Mkdir(call capnp.FS_mkdir) error {
 			return err
 		}
 
+		fh.base.recordAudit("fs.mkdir", url.Path, "")
 		fh.base.notifyFsChangeEvent()
 		return nil
 	})
@@ -254,6 +261,7 @@ func (fh *fsHandler) Remove(call capnp.FS_remove) error {
 			return err
 		}
 
+		fh.base.recordAudit("fs.remove", url.Path, "")
 		fh.base.notifyFsChangeEvent()
 		return nil
 	})
@@ -286,6 +294,7 @@ func (fh *fsHandler) Move(call capnp.FS_move) error {
 			return err
 		}
 
+		fh.base.recordAudit("fs.move", srcUrl.Path, "to "+dstURL.Path)
 		fh.base.notifyFsChangeEvent()
 		return nil
 	})
@@ -318,6 +327,7 @@ func (fh *fsHandler) Copy(call capnp.FS_copy) error {
 			return err
 		}
 
+		fh.base.recordAudit("fs.copy", srcUrl.Path, "to "+dstURL.Path)
 		fh.base.notifyFsChangeEvent()
 		return nil
 	})
@@ -332,7 +342,12 @@ func (fh *fsHandler) Pin(call capnp.FS_pin) error {
 	}
 
 	return fh.base.withFsFromPath(path, func(url *URL, fs *catfs.FS) error {
-		return fs.Pin(url.Path, "curr", true)
+		if err := fs.Pin(url.Path, "curr", true); err != nil {
+			return err
+		}
+
+		fh.base.recordAudit("fs.pin", url.Path, "curr")
+		return nil
 	})
 }
 
@@ -345,7 +360,12 @@ func (fh *fsHandler) Unpin(call capnp.FS_unpin) error {
 	}
 
 	return fh.base.withFsFromPath(path, func(url *URL, fs *catfs.FS) error {
-		return fs.Unpin(url.Path, "curr", true)
+		if err := fs.Unpin(url.Path, "curr", true); err != nil {
+			return err
+		}
+
+		fh.base.recordAudit("fs.unpin", url.Path, "curr")
+		return nil
 	})
 }
 
@@ -451,6 +471,7 @@ func (fh *fsHandler) Touch(call capnp.FS_touch) error {
 			return err
 		}
 
+		fh.base.recordAudit("fs.touch", url.Path, "")
 		fh.base.notifyFsChangeEvent()
 		return nil
 	})
@@ -486,7 +507,12 @@ func (fh *fsHandler) Undelete(call capnp.FS_undelete) error {
 	}
 
 	return fh.base.withCurrFs(func(fs *catfs.FS) error {
-		return fs.Undelete(path)
+		if err := fs.Undelete(path); err != nil {
+			return err
+		}
+
+		fh.base.recordAudit("fs.undelete", path, "")
+		return nil
 	})
 }
 
@@ -542,3 +568,339 @@ func (fh *fsHandler) IsCached(call capnp.FS_isCached) error {
 		return nil
 	})
 }
+
+func usagesToCapnp(usages []catfs.Usage, seg *capnplib.Segment) (*capnp.DiskUsage_List, error) {
+	lst, err := capnp.NewDiskUsage_List(seg, int32(len(usages)))
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, usage := range usages {
+		capUsage, err := capnp.NewDiskUsage(seg)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := capUsage.SetPath(usage.Path); err != nil {
+			return nil, err
+		}
+
+		capUsage.SetFiles(usage.Files)
+		capUsage.SetLogical(usage.Logical)
+		capUsage.SetDeduplicated(usage.Deduplicated)
+		capUsage.SetPinned(usage.Pinned)
+		capUsage.SetCached(usage.Cached)
+		capUsage.SetQuota(usage.Quota)
+
+		if err := lst.Set(idx, capUsage); err != nil {
+			return nil, err
+		}
+	}
+
+	return &lst, nil
+}
+
+func (fh *fsHandler) DiskUsage(call capnp.FS_diskUsage) error {
+	server.Ack(call.Options)
+
+	root, err := call.Params.Root()
+	if err != nil {
+		return err
+	}
+
+	maxDepth := call.Params.MaxDepth()
+
+	return fh.base.withFsFromPath(root, func(url *URL, fs *catfs.FS) error {
+		usages, err := fs.DiskUsage(url.Path, int(maxDepth))
+		if err != nil {
+			return err
+		}
+
+		lst, err := usagesToCapnp(usages, call.Results.Segment())
+		if err != nil {
+			return err
+		}
+
+		return call.Results.SetUsages(*lst)
+	})
+}
+
+func (fh *fsHandler) UserUsage(call capnp.FS_userUsage) error {
+	server.Ack(call.Options)
+
+	return fh.base.withCurrFs(func(fs *catfs.FS) error {
+		usages, err := fs.UsageByUser()
+		if err != nil {
+			return err
+		}
+
+		// Users that are also remotes might have a quota:
+		for idx := range usages {
+			rmt, err := fh.base.repo.Remotes.Remote(usages[idx].Path)
+			if err != nil {
+				continue
+			}
+
+			usages[idx].Quota = rmt.Quota
+		}
+
+		lst, err := usagesToCapnp(usages, call.Results.Segment())
+		if err != nil {
+			return err
+		}
+
+		return call.Results.SetUsages(*lst)
+	})
+}
+
+func (fh *fsHandler) QuotaSet(call capnp.FS_quotaSet) error {
+	server.Ack(call.Options)
+
+	path, err := call.Params.Path()
+	if err != nil {
+		return err
+	}
+
+	return fh.base.withCurrFs(func(fs *catfs.FS) error {
+		return fs.SetQuota(path, call.Params.Size())
+	})
+}
+
+func (fh *fsHandler) KeySchemeSet(call capnp.FS_keySchemeSet) error {
+	server.Ack(call.Options)
+
+	path, err := call.Params.Path()
+	if err != nil {
+		return err
+	}
+
+	scheme, err := call.Params.Scheme()
+	if err != nil {
+		return err
+	}
+
+	return fh.base.withCurrFs(func(fs *catfs.FS) error {
+		return fs.SetKeyScheme(path, n.KeyScheme(scheme))
+	})
+}
+
+func (fh *fsHandler) KeySchemeList(call capnp.FS_keySchemeList) error {
+	server.Ack(call.Options)
+
+	return fh.base.withCurrFs(func(fs *catfs.FS) error {
+		schemes, err := fs.KeySchemes()
+		if err != nil {
+			return err
+		}
+
+		folders := []string{}
+		for folder := range schemes {
+			folders = append(folders, folder)
+		}
+
+		sort.Strings(folders)
+
+		seg := call.Results.Segment()
+		lst, err := capnp.NewFolderKeyScheme_List(seg, int32(len(folders)))
+		if err != nil {
+			return err
+		}
+
+		for idx, folder := range folders {
+			capScheme, err := capnp.NewFolderKeyScheme(seg)
+			if err != nil {
+				return err
+			}
+
+			if err := capScheme.SetPath(folder); err != nil {
+				return err
+			}
+
+			if err := capScheme.SetScheme(string(schemes[folder])); err != nil {
+				return err
+			}
+
+			if err := lst.Set(idx, capScheme); err != nil {
+				return err
+			}
+		}
+
+		return call.Results.SetSchemes(lst)
+	})
+}
+
+func (fh *fsHandler) QuotaList(call capnp.FS_quotaList) error {
+	server.Ack(call.Options)
+
+	return fh.base.withCurrFs(func(fs *catfs.FS) error {
+		quotas, err := fs.Quotas()
+		if err != nil {
+			return err
+		}
+
+		folders := []string{}
+		for folder := range quotas {
+			folders = append(folders, folder)
+		}
+
+		sort.Strings(folders)
+
+		seg := call.Results.Segment()
+		lst, err := capnp.NewQuota_List(seg, int32(len(folders)))
+		if err != nil {
+			return err
+		}
+
+		for idx, folder := range folders {
+			capQuota, err := capnp.NewQuota(seg)
+			if err != nil {
+				return err
+			}
+
+			if err := capQuota.SetPath(folder); err != nil {
+				return err
+			}
+
+			capQuota.SetSize(quotas[folder])
+			if err := lst.Set(idx, capQuota); err != nil {
+				return err
+			}
+		}
+
+		return call.Results.SetQuotas(lst)
+	})
+}
+
+func (fh *fsHandler) Fsck(call capnp.FS_fsck) error {
+	server.Ack(call.Options)
+
+	deep := call.Params.Deep()
+	repair := call.Params.Repair()
+
+	return fh.base.withCurrFs(func(fs *catfs.FS) error {
+		problems, err := fs.Fsck(deep, repair)
+		if err != nil {
+			return err
+		}
+
+		seg := call.Results.Segment()
+		lst, err := capnp.NewFsckProblem_List(seg, int32(len(problems)))
+		if err != nil {
+			return err
+		}
+
+		for idx, problem := range problems {
+			capProblem, err := capnp.NewFsckProblem(seg)
+			if err != nil {
+				return err
+			}
+
+			if err := capProblem.SetKind(problem.Kind); err != nil {
+				return err
+			}
+
+			if err := capProblem.SetPath(problem.Path); err != nil {
+				return err
+			}
+
+			hash := ""
+			if problem.Hash != nil {
+				hash = problem.Hash.B58String()
+			}
+
+			if err := capProblem.SetHash(hash); err != nil {
+				return err
+			}
+
+			if err := capProblem.SetDetail(problem.Detail); err != nil {
+				return err
+			}
+
+			capProblem.SetRepaired(problem.Repaired)
+			if err := lst.Set(idx, capProblem); err != nil {
+				return err
+			}
+		}
+
+		return call.Results.SetProblems(lst)
+	})
+}
+
+// importArchive stages the content of the tar (maybe gzipped) or zip
+// archive at `localPath` below `root`.
+func importArchive(fs *catfs.FS, localPath, root string) (int, error) {
+	fd, err := os.Open(localPath) // #nosec
+	if err != nil {
+		return 0, err
+	}
+
+	defer fd.Close()
+
+	info, err := fd.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(fd, magic); err != nil && err != io.ErrUnexpectedEOF {
+		return 0, err
+	}
+
+	if _, err := fd.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	switch {
+	case bytes.Equal(magic, []byte("PK\x03\x04")), bytes.Equal(magic, []byte("PK\x05\x06")):
+		return fs.ImportZip(root, fd, info.Size())
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gzr, err := gzip.NewReader(fd)
+		if err != nil {
+			return 0, err
+		}
+
+		defer gzr.Close()
+		return fs.ImportTar(root, gzr)
+	default:
+		return fs.ImportTar(root, fd)
+	}
+}
+
+func (fh *fsHandler) ImportArchive(call capnp.FS_importArchive) error {
+	server.Ack(call.Options)
+
+	localPath, err := call.Params.LocalPath()
+	if err != nil {
+		return err
+	}
+
+	repoPath, err := call.Params.RepoPath()
+	if err != nil {
+		return err
+	}
+
+	msg, err := call.Params.Message()
+	if err != nil {
+		return err
+	}
+
+	if msg == "" {
+		msg = fmt.Sprintf("imported %s", filepath.Base(localPath))
+	}
+
+	return fh.base.withFsFromPath(repoPath, func(url *URL, fs *catfs.FS) error {
+		count, err := importArchive(fs, localPath, url.Path)
+		if err != nil {
+			return err
+		}
+
+		if err := fs.MakeCommit("user: " + msg); err != nil && err != ie.ErrNoChange {
+			return err
+		}
+
+		fh.base.recordAudit("fs.import", url.Path, localPath)
+		call.Results.SetCount(int64(count))
+		fh.base.notifyFsChangeEvent()
+		return nil
+	})
+}
@@ -0,0 +1,459 @@
+// Package httpapi offers the api of the daemon as JSON over HTTP, for tools
+// that can not easily use capnp or the Go client. Every exported method of
+// client.Client is available as /api/v1/<Method>, so both stay in lockstep:
+// a call is forwarded to the daemon's capnp handlers by the Go client.
+//
+// Arguments are passed as JSON list in the body ({"args": ["/", -1]}) or as
+// repeated »arg« query parameters. Methods that read data (like
+// StageFromReader) take the request body as stream and their other arguments
+// from the query. Methods that return a stream (like Cat or Tar) send it as
+// the response body. All other results are returned as JSON.
+// GET /api/v1 lists all available methods with their argument types.
+package httpapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sahib/brig/client"
+	"github.com/sahib/brig/defaults"
+	"github.com/sahib/brig/util/server"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Prefix is the path every api call starts with.
+	Prefix = "/api/v1"
+)
+
+var (
+	readerType     = reflect.TypeOf((*io.Reader)(nil)).Elem()
+	readCloserType = reflect.TypeOf((*io.ReadCloser)(nil)).Elem()
+	errorType      = reflect.TypeOf((*error)(nil)).Elem()
+
+	// Methods of client.Client that make no sense over HTTP:
+	skippedMethods = map[string]bool{
+		"Close":      true,
+		"LocalAddr":  true,
+		"RemoteAddr": true,
+	}
+)
+
+// method is a callable method of client.Client.
+type method struct {
+	name    string
+	fn      reflect.Value
+	args    []reflect.Type
+	results []reflect.Type
+
+	// readerIdx is the index of the io.Reader argument or -1.
+	readerIdx int
+}
+
+// MethodInfo describes a method in the listing at GET /api/v1.
+type MethodInfo struct {
+	Name    string   `json:"name"`
+	Args    []string `json:"args"`
+	Results []string `json:"results"`
+}
+
+func isSupportedArg(typ reflect.Type) bool {
+	if typ == readerType {
+		return true
+	}
+
+	switch typ.Kind() {
+	case reflect.Func, reflect.Chan, reflect.Interface:
+		return false
+	}
+
+	return true
+}
+
+func isSupportedResult(typ reflect.Type) bool {
+	if typ == readCloserType {
+		return true
+	}
+
+	switch typ.Kind() {
+	case reflect.Func, reflect.Interface:
+		return false
+	}
+
+	return true
+}
+
+// collectMethods finds all methods of client.Client that can be called over HTTP.
+// Methods taking a callback (like JobWait) are left out.
+func collectMethods() map[string]*method {
+	methods := make(map[string]*method)
+	clientType := reflect.TypeOf(&client.Client{})
+
+	for idx := 0; idx < clientType.NumMethod(); idx++ {
+		refMethod := clientType.Method(idx)
+		if skippedMethods[refMethod.Name] {
+			continue
+		}
+
+		fnType := refMethod.Type
+		numOut := fnType.NumOut()
+		if numOut == 0 || fnType.Out(numOut-1) != errorType {
+			continue
+		}
+
+		m := &method{
+			name:      refMethod.Name,
+			fn:        refMethod.Func,
+			readerIdx: -1,
+		}
+
+		isSupported := true
+
+		// Argument 0 is the receiver:
+		for argIdx := 1; argIdx < fnType.NumIn(); argIdx++ {
+			typ := fnType.In(argIdx)
+			if !isSupportedArg(typ) || (typ == readerType && m.readerIdx >= 0) {
+				isSupported = false
+				break
+			}
+
+			if typ == readerType {
+				m.readerIdx = len(m.args)
+			}
+
+			m.args = append(m.args, typ)
+		}
+
+		for outIdx := 0; outIdx < numOut-1; outIdx++ {
+			typ := fnType.Out(outIdx)
+			if !isSupportedResult(typ) || (typ == readCloserType && numOut != 2) {
+				isSupported = false
+				break
+			}
+
+			m.results = append(m.results, typ)
+		}
+
+		if isSupported {
+			methods[m.name] = m
+		}
+	}
+
+	return methods
+}
+
+func (m *method) info() MethodInfo {
+	info := MethodInfo{
+		Name:    m.name,
+		Args:    []string{},
+		Results: []string{},
+	}
+
+	for _, typ := range m.args {
+		info.Args = append(info.Args, typ.String())
+	}
+
+	for _, typ := range m.results {
+		info.Results = append(info.Results, typ.String())
+	}
+
+	return info
+}
+
+func (m *method) isStream() bool {
+	return len(m.results) == 1 && m.results[0] == readCloserType
+}
+
+// API serves the JSON over HTTP api.
+type API struct {
+	repoPath string
+	token    string
+	methods  map[string]*method
+	srv      *http.Server
+}
+
+type connKey struct{}
+
+// Start serves the api for the daemon of the repository at `repoPath`,
+// if daemon.http.enabled is set. It listens on the brig-http.socket in the
+// repository and, if daemon.tcp.enabled is set, also on `bindHost` with the
+// port in daemon.http.port. Clients are authenticated like on the daemon's
+// own socket: by their peer credentials or by the daemon's token.
+func Start(repoPath, bindHost string) (*API, error) {
+	api := &API{
+		repoPath: repoPath,
+		methods:  collectMethods(),
+	}
+
+	api.srv = &http.Server{
+		Handler: api,
+		ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+			return context.WithValue(ctx, connKey{}, conn)
+		},
+	}
+
+	cfg, err := defaults.OpenMigratedConfig(filepath.Join(repoPath, "config.yml"))
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.Bool("daemon.http.enabled") {
+		return api, nil
+	}
+
+	socketPath := filepath.Join(repoPath, defaults.DaemonHTTPSocketName)
+	socketLst, err := server.ListenUnix(socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	lsts := []net.Listener{socketLst}
+	if cfg.Bool("daemon.tcp.enabled") {
+		api.token, err = server.ReadOrCreateToken(filepath.Join(repoPath, defaults.DaemonTokenName))
+		if err != nil {
+			socketLst.Close()
+			return nil, err
+		}
+
+		addr := fmt.Sprintf("%s:%d", bindHost, cfg.Int("daemon.http.port"))
+		tcpLst, err := net.Listen("tcp", addr)
+		if err != nil {
+			socketLst.Close()
+			return nil, err
+		}
+
+		lsts = append(lsts, tcpLst)
+	}
+
+	for _, lst := range lsts {
+		log.Infof("serving http api on %s", lst.Addr())
+		go func(lst net.Listener) {
+			if err := api.srv.Serve(lst); err != nil && err != http.ErrServerClosed {
+				log.Warningf("http api on %s failed: %v", lst.Addr(), err)
+			}
+		}(lst)
+	}
+
+	return api, nil
+}
+
+// Close stops serving the api.
+func (api *API) Close() error {
+	return api.srv.Close()
+}
+
+type response struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message,omitempty"`
+	Result  interface{} `json:"result"`
+}
+
+func jsonify(w http.ResponseWriter, statusCode int, resp response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Warningf("failed to encode http api response: %v", err)
+	}
+}
+
+func jsonifyErrf(w http.ResponseWriter, statusCode int, format string, args ...interface{}) {
+	jsonify(w, statusCode, response{Message: fmt.Sprintf(format, args...)})
+}
+
+// authorize checks the client like the daemon checks clients on its socket.
+func (api *API) authorize(r *http.Request) error {
+	switch conn := r.Context().Value(connKey{}).(type) {
+	case *net.UnixConn:
+		return server.CheckPeerCredentials(conn)
+	case *net.TCPConn:
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if api.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(api.token)) != 1 {
+			return fmt.Errorf("bad token")
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unsupported connection type: %T", conn)
+	}
+}
+
+func (api *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := api.authorize(r); err != nil {
+		log.Warningf("rejected http api client: %v", err)
+		jsonifyErrf(w, http.StatusUnauthorized, "not authorized: %v", err)
+		return
+	}
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, Prefix), "/")
+	if !strings.HasPrefix(r.URL.Path, Prefix) {
+		jsonifyErrf(w, http.StatusNotFound, "no such path: %s", r.URL.Path)
+		return
+	}
+
+	if name == "" {
+		api.serveIndex(w, r)
+		return
+	}
+
+	m, ok := api.methods[name]
+	if !ok {
+		jsonifyErrf(w, http.StatusNotFound, "no such method: %s", name)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		jsonifyErrf(w, http.StatusMethodNotAllowed, "methods need to be called with POST")
+		return
+	}
+
+	api.serveCall(w, r, m)
+}
+
+func (api *API) serveIndex(w http.ResponseWriter, r *http.Request) {
+	infos := []MethodInfo{}
+	for _, m := range api.methods {
+		infos = append(infos, m.info())
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+
+	jsonify(w, http.StatusOK, response{Success: true, Result: infos})
+}
+
+// parseArgs reads the arguments of `m` from the request.
+func parseArgs(r *http.Request, m *method) ([]reflect.Value, error) {
+	rawArgs := []json.RawMessage{}
+	queryArgs := r.URL.Query()["arg"]
+
+	useBody := m.readerIdx < 0 && r.ContentLength != 0 && len(queryArgs) == 0
+	if useBody {
+		body := struct {
+			Args []json.RawMessage `json:"args"`
+		}{}
+
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return nil, fmt.Errorf("bad json: %v", err)
+		}
+
+		rawArgs = body.Args
+	}
+
+	given := len(queryArgs)
+	if useBody {
+		given = len(rawArgs)
+	}
+
+	expected := len(m.args)
+	if m.readerIdx >= 0 {
+		expected--
+	}
+
+	if given != expected {
+		return nil, fmt.Errorf("%s needs %d arguments, got %d", m.name, expected, given)
+	}
+
+	values := []reflect.Value{}
+	argIdx := 0
+	for idx, typ := range m.args {
+		if idx == m.readerIdx {
+			values = append(values, reflect.ValueOf(r.Body))
+			continue
+		}
+
+		val := reflect.New(typ)
+		if useBody {
+			if err := json.Unmarshal(rawArgs[argIdx], val.Interface()); err != nil {
+				return nil, fmt.Errorf("argument %d: %v", argIdx+1, err)
+			}
+		} else if typ.Kind() == reflect.String {
+			// Strings in the query do not need to be quoted:
+			val.Elem().SetString(queryArgs[argIdx])
+		} else if err := json.Unmarshal([]byte(queryArgs[argIdx]), val.Interface()); err != nil {
+			return nil, fmt.Errorf("argument %d: %v", argIdx+1, err)
+		}
+
+		values = append(values, val.Elem())
+		argIdx++
+	}
+
+	return values, nil
+}
+
+// drainChannel collects all values sent on a channel until it is closed.
+func drainChannel(ch reflect.Value) interface{} {
+	items := reflect.MakeSlice(reflect.SliceOf(ch.Type().Elem()), 0, 0)
+	for {
+		item, ok := ch.Recv()
+		if !ok {
+			return items.Interface()
+		}
+
+		items = reflect.Append(items, item)
+	}
+}
+
+func (api *API) serveCall(w http.ResponseWriter, r *http.Request, m *method) {
+	args, err := parseArgs(r, m)
+	if err != nil {
+		jsonifyErrf(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	ctl, err := client.Dial(r.Context(), api.repoPath)
+	if err != nil {
+		jsonifyErrf(w, http.StatusServiceUnavailable, "failed to connect to daemon: %v", err)
+		return
+	}
+
+	defer ctl.Close()
+
+	outs := m.fn.Call(append([]reflect.Value{reflect.ValueOf(ctl)}, args...))
+	if errVal := outs[len(outs)-1]; !errVal.IsNil() {
+		jsonifyErrf(w, http.StatusInternalServerError, "%v", errVal.Interface())
+		return
+	}
+
+	if m.isStream() {
+		stream := outs[0].Interface().(io.ReadCloser)
+		defer stream.Close()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if _, err := io.Copy(w, stream); err != nil {
+			log.Warningf("http api: failed to send %s stream: %v", m.name, err)
+		}
+
+		return
+	}
+
+	results := []interface{}{}
+	for _, out := range outs[:len(outs)-1] {
+		if out.Kind() == reflect.Chan {
+			results = append(results, drainChannel(out))
+			continue
+		}
+
+		results = append(results, out.Interface())
+	}
+
+	resp := response{Success: true}
+	switch len(results) {
+	case 0:
+	case 1:
+		resp.Result = results[0]
+	default:
+		resp.Result = results
+	}
+
+	jsonify(w, http.StatusOK, resp)
+}
@@ -0,0 +1,193 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sahib/brig/defaults"
+	"github.com/sahib/brig/repo"
+	"github.com/sahib/brig/server"
+	"github.com/sahib/brig/util"
+	"github.com/stretchr/testify/require"
+)
+
+func withAPI(t *testing.T, fn func(repoPath string, httpPort int)) {
+	port := util.FindFreePort()
+	repoPath, err := ioutil.TempDir("", "brig-httpapi-repo")
+	require.Nil(t, err)
+
+	defer os.RemoveAll(repoPath)
+
+	httpPort := util.FindFreePort()
+	require.Nil(t, repo.Init(repoPath, "ali", "no-pass", "mock", int64(port)))
+	require.Nil(t, repo.OverwriteConfigKey(repoPath, "daemon.http.enabled", true))
+	require.Nil(t, repo.OverwriteConfigKey(repoPath, "daemon.tcp.enabled", true))
+	require.Nil(t, repo.OverwriteConfigKey(repoPath, "daemon.http.port", int64(httpPort)))
+
+	passwordFn := func() (string, error) {
+		return "no-pass", nil
+	}
+
+	srv, err := server.BootServer(repoPath, passwordFn, "127.0.0.1", port, true)
+	require.Nil(t, err)
+
+	go func() {
+		require.Nil(t, srv.Serve())
+	}()
+
+	defer func() {
+		require.Nil(t, srv.Close())
+	}()
+
+	api, err := Start(repoPath, "127.0.0.1")
+	require.Nil(t, err)
+
+	defer func() {
+		require.Nil(t, api.Close())
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+	fn(repoPath, httpPort)
+}
+
+func socketClient(repoPath string) *http.Client {
+	socketPath := filepath.Join(repoPath, defaults.DaemonHTTPSocketName)
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", socketPath)
+			},
+		},
+	}
+}
+
+func decode(t *testing.T, resp *http.Response, result interface{}) response {
+	defer resp.Body.Close()
+
+	data := response{Result: result}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&data))
+	return data
+}
+
+func call(t *testing.T, hc *http.Client, method string, args ...interface{}) *http.Response {
+	body, err := json.Marshal(map[string]interface{}{"args": args})
+	require.Nil(t, err)
+
+	resp, err := hc.Post("http://brig"+Prefix+"/"+method, "application/json", bytes.NewReader(body))
+	require.Nil(t, err)
+	return resp
+}
+
+func TestCallsOverSocket(t *testing.T) {
+	withAPI(t, func(repoPath string, httpPort int) {
+		hc := socketClient(repoPath)
+
+		// Upload with the path in the query and the data as body:
+		query := url.Values{"arg": []string{"/hello.txt"}}
+		resp, err := hc.Post(
+			"http://brig"+Prefix+"/StageFromReader?"+query.Encode(),
+			"application/octet-stream",
+			strings.NewReader("hello world"),
+		)
+		require.Nil(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.True(t, decode(t, resp, nil).Success)
+
+		// Download again:
+		resp = call(t, hc, "Cat", "/hello.txt", false)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		data, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		require.Nil(t, resp.Body.Close())
+		require.Equal(t, "hello world", string(data))
+
+		// Structured results:
+		infos := []map[string]interface{}{}
+		resp = call(t, hc, "List", "/", -1)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		decode(t, resp, &infos)
+		require.Len(t, infos, 2)
+		require.Equal(t, "/hello.txt", infos[1]["Path"])
+
+		exists := true
+		resp = call(t, hc, "Exists", "/nope")
+		decode(t, resp, &exists)
+		require.False(t, exists)
+
+		// Errors of the daemon are passed on:
+		resp = call(t, hc, "Remove", "/nope")
+		require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		require.False(t, decode(t, resp, nil).Success)
+
+		resp = call(t, hc, "Remove")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp = call(t, hc, "Close")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		// The index lists all methods:
+		methods := []MethodInfo{}
+		resp, err = hc.Get("http://brig" + Prefix)
+		require.Nil(t, err)
+		decode(t, resp, &methods)
+
+		found := false
+		for _, info := range methods {
+			if info.Name == "Cat" {
+				found = true
+				require.Equal(t, []string{"string", "bool"}, info.Args)
+				require.Equal(t, []string{"io.ReadCloser"}, info.Results)
+			}
+
+			// Methods with callbacks can not be called:
+			require.NotEqual(t, "JobWait", info.Name)
+		}
+
+		require.True(t, found)
+	})
+}
+
+func TestTCPNeedsToken(t *testing.T) {
+	withAPI(t, func(repoPath string, httpPort int) {
+		tokenData, err := ioutil.ReadFile(filepath.Join(repoPath, defaults.DaemonTokenName))
+		require.Nil(t, err)
+
+		url := fmt.Sprintf("http://127.0.0.1:%d%s/Exists", httpPort, Prefix)
+		doCall := func(token string) *http.Response {
+			req, err := http.NewRequest("POST", url, strings.NewReader(`{"args": ["/"]}`))
+			require.Nil(t, err)
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.Nil(t, err)
+			return resp
+		}
+
+		resp := doCall("")
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		resp.Body.Close()
+
+		resp = doCall("wrong")
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		resp.Body.Close()
+
+		exists := false
+		resp = doCall(strings.TrimSpace(string(tokenData)))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		decode(t, resp, &exists)
+		require.True(t, exists)
+	})
+}
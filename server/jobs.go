@@ -0,0 +1,221 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/sahib/brig/server/capnp"
+	log "github.com/sirupsen/logrus"
+	capnplib "zombiezen.com/go/capnproto2"
+)
+
+var errJobCanceled = errors.New("job was canceled")
+
+// jobStatus describes the progress of a long running job.
+// It is stored as status in the conductor and is only ever copied,
+// so it's fine to read it without any further locking.
+type jobStatus struct {
+	Ticket     uint64
+	Kind       string
+	Remote     string
+	Phase      string
+	BytesDone  int64
+	BytesTotal int64
+	FilesDone  int64
+	FilesTotal int64
+	IsDone     bool
+	Err        string
+}
+
+// jobReporter is passed to the actual worker functions (doSync, doFetch...)
+// and is used by them to report their progress. A nil reporter is valid
+// and will simply discard all reports, so callers that do not care about
+// progress do not need to create one.
+type jobReporter struct {
+	mu     sync.Mutex
+	b      *base
+	status jobStatus
+}
+
+func (jr *jobReporter) update(fn func(st *jobStatus)) {
+	if jr == nil {
+		return
+	}
+
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+
+	fn(&jr.status)
+	if err := jr.b.conductor.SetStatus(jr.status.Ticket, jr.status); err != nil {
+		log.Debugf("failed to set job status: %v", err)
+	}
+}
+
+// Phase sets the current phase of the job and resets all counters.
+func (jr *jobReporter) Phase(phase string) {
+	jr.update(func(st *jobStatus) {
+		st.Phase = phase
+		st.BytesDone, st.BytesTotal = 0, 0
+		st.FilesDone, st.FilesTotal = 0, 0
+	})
+}
+
+// Bytes reports how many bytes were transferred in the current phase.
+// `total` may be zero if it is not known.
+func (jr *jobReporter) Bytes(done, total int64) {
+	jr.update(func(st *jobStatus) {
+		st.BytesDone, st.BytesTotal = done, total
+	})
+}
+
+// Files reports how many files were processed in the current phase.
+// `total` may be zero if it is not known.
+func (jr *jobReporter) Files(done, total int64) {
+	jr.update(func(st *jobStatus) {
+		st.FilesDone, st.FilesTotal = done, total
+	})
+}
+
+func (jr *jobReporter) finish(err error) {
+	jr.update(func(st *jobStatus) {
+		st.IsDone = true
+		st.Phase = "done"
+		if err != nil {
+			st.Err = err.Error()
+		}
+	})
+}
+
+// checkCanceled returns errJobCanceled if `ctx` was canceled.
+// It is meant to be called between the phases of a job,
+// since most phases cannot be interrupted in the middle.
+func checkCanceled(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return errJobCanceled
+	}
+
+	return nil
+}
+
+// jobError makes errors caused by canceling `ctx` readable.
+func jobError(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return errJobCanceled
+	}
+
+	return err
+}
+
+// startJob executes `fn` in the background and returns a ticket
+// that can be used to query the status of the job or to cancel it.
+// `kind` and `remote` are only used for display purpose.
+func (b *base) startJob(kind, remote string, fn func(ctx context.Context, rep *jobReporter) error) uint64 {
+	return b.conductor.ExecWithContext(b.ctx, func(ctx context.Context, ticket uint64) error {
+		rep := &jobReporter{
+			b: b,
+			status: jobStatus{
+				Ticket: ticket,
+				Kind:   kind,
+				Remote: remote,
+			},
+		}
+
+		rep.Phase("start")
+		err := jobError(ctx, fn(ctx, rep))
+		if err != nil {
+			log.Warningf("%s job with %s failed: %v", kind, remote, err)
+		}
+
+		rep.finish(err)
+		return err
+	})
+}
+
+// runJob is like startJob, but waits for the job to finish.
+// The job is still listed and can be canceled while it runs.
+func (b *base) runJob(kind, remote string, fn func(ctx context.Context, rep *jobReporter) error) error {
+	errCh := make(chan error, 1)
+	b.startJob(kind, remote, func(ctx context.Context, rep *jobReporter) error {
+		err := fn(ctx, rep)
+		errCh <- jobError(ctx, err)
+		return err
+	})
+
+	return <-errCh
+}
+
+// jobStatusByTicket returns the status of the job behind `ticket`.
+// The boolean is false if the ticket belongs to something else than a job.
+func (b *base) jobStatusByTicket(ticket uint64) (jobStatus, bool, error) {
+	data, err := b.conductor.Status(ticket)
+	if err != nil {
+		return jobStatus{}, false, err
+	}
+
+	status, ok := data.(jobStatus)
+	return status, ok, nil
+}
+
+// jobs returns the status of all jobs that are running or recently finished.
+func (b *base) jobs() []jobStatus {
+	statuses := []jobStatus{}
+	for _, ticket := range b.conductor.Tickets() {
+		status, ok, err := b.jobStatusByTicket(ticket)
+		if err != nil || !ok {
+			continue
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// cancelJobs cancels all running jobs of `kind` with `remote`.
+// It is not an error if there is no such job.
+func (b *base) cancelJobs(kind, remote string) error {
+	for _, status := range b.jobs() {
+		if status.IsDone || status.Kind != kind || status.Remote != remote {
+			continue
+		}
+
+		if err := b.conductor.Cancel(status.Ticket); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func jobStatusToCapnp(status jobStatus, seg *capnplib.Segment) (*capnp.JobStatus, error) {
+	capStatus, err := capnp.NewJobStatus(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	capStatus.SetTicket(status.Ticket)
+	if err := capStatus.SetKind(status.Kind); err != nil {
+		return nil, err
+	}
+
+	if err := capStatus.SetRemote(status.Remote); err != nil {
+		return nil, err
+	}
+
+	if err := capStatus.SetPhase(status.Phase); err != nil {
+		return nil, err
+	}
+
+	capStatus.SetBytesDone(status.BytesDone)
+	capStatus.SetBytesTotal(status.BytesTotal)
+	capStatus.SetFilesDone(status.FilesDone)
+	capStatus.SetFilesTotal(status.FilesTotal)
+	capStatus.SetIsDone(status.IsDone)
+
+	if err := capStatus.SetError(status.Err); err != nil {
+		return nil, err
+	}
+
+	return &capStatus, nil
+}
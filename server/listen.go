@@ -0,0 +1,126 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+
+	"github.com/sahib/brig/defaults"
+	"github.com/sahib/brig/util/server"
+	log "github.com/sirupsen/logrus"
+)
+
+// localListener is where the local api is served.
+type localListener struct {
+	net.Listener
+
+	// token that clients connecting over tcp need to send.
+	// Empty if tcp is disabled.
+	token string
+
+	// sockets maps the sockets of the repositories in daemon.repos
+	// to their name. Repositories whose socket is in use are missing.
+	sockets map[string]string
+}
+
+// listen creates the listener for the local api. Clients can always
+// connect over the unix socket in `basePath` and in the path of every
+// repository in daemon.repos. If daemon.tcp.enabled is set, they can
+// also connect on `bindHost:port` when sending the token of the listener.
+func listen(basePath, bindHost string, port int) (*localListener, error) {
+	socketLst, err := server.ListenUnix(filepath.Join(basePath, defaults.DaemonSocketName))
+	if err != nil {
+		return nil, err
+	}
+
+	lst := &localListener{sockets: make(map[string]string)}
+	lsts := []net.Listener{socketLst}
+
+	cfg, err := defaults.OpenMigratedConfig(filepath.Join(basePath, "config.yml"))
+	if err != nil {
+		log.Infof("could not read config, only listening on socket: %v", err)
+		lst.Listener = server.NewMultiListener(lsts...)
+		return lst, nil
+	}
+
+	for name, repoPath := range ServedRepos(cfg) {
+		socketPath := filepath.Join(repoPath, defaults.DaemonSocketName)
+		repoLst, err := server.ListenUnix(socketPath)
+		if err != nil {
+			log.Warningf("not serving repository %s: %v", name, err)
+			continue
+		}
+
+		lst.sockets[socketPath] = name
+		lsts = append(lsts, repoLst)
+	}
+
+	if !cfg.Bool("daemon.tcp.enabled") {
+		log.Infof("listening on %d sockets", len(lsts))
+		lst.Listener = server.NewMultiListener(lsts...)
+		return lst, nil
+	}
+
+	// The token is kept over restarts, so clients do not need a new one every time:
+	lst.token, err = server.ReadOrCreateToken(filepath.Join(basePath, defaults.DaemonTokenName))
+	if err != nil {
+		closeAll(lsts)
+		return nil, err
+	}
+
+	tcpLst, err := net.Listen("tcp", fmt.Sprintf("%s:%d", bindHost, port))
+	if err != nil {
+		closeAll(lsts)
+		return nil, err
+	}
+
+	log.Infof("listening on %d sockets and %s", len(lsts), tcpLst.Addr())
+	lst.Listener = server.NewMultiListener(append(lsts, tcpLst)...)
+	return lst, nil
+}
+
+func closeAll(lsts []net.Listener) {
+	for _, lst := range lsts {
+		lst.Close()
+	}
+}
+
+// authenticate checks if the client on `conn` may use the api and returns
+// the repository it wants to use. Clients on the unix sockets need to run
+// as the same user as the daemon, clients over tcp need to know the token.
+func (rs *repoSet) authenticate(conn net.Conn) (*base, error) {
+	var b *base
+	err := server.ServerHandshake(conn, func(hello server.Hello) error {
+		name := hello.Repo
+		switch c := conn.(type) {
+		case *net.UnixConn:
+			if err := server.CheckPeerCredentials(c); err != nil {
+				return err
+			}
+
+			// Clients of a named repository might not know that it is
+			// served by this daemon and just use its socket:
+			if name == "" {
+				name = rs.sockets[c.LocalAddr().String()]
+			}
+		case *net.TCPConn:
+			if rs.token == "" {
+				return errors.New("tcp is disabled")
+			}
+
+			if subtle.ConstantTimeCompare([]byte(hello.Token), []byte(rs.token)) != 1 {
+				return errors.New("bad token")
+			}
+		default:
+			return fmt.Errorf("unsupported connection type: %T", conn)
+		}
+
+		var err error
+		b, err = rs.lookup(name)
+		return err
+	})
+
+	return b, err
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/sahib/brig/catfs"
 	p2pnet "github.com/sahib/brig/net"
 	"github.com/sahib/brig/net/peer"
 	"github.com/sahib/brig/repo"
@@ -56,6 +57,25 @@ func (nh *netHandler) Whoami(call capnp.Net_whoami) error {
 	}
 
 	capID.SetIsOnline(psrv.IsOnline())
+
+	err = nh.base.withCurrFs(func(fs *catfs.FS) error {
+		stats := fs.WriteQueueStats()
+		capID.SetQueueLength(uint64(stats.Length))
+		capID.SetQueueBytes(stats.Bytes)
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	throughput := rp.Bandwidth.Throughput()
+	capID.SetUploadRate(throughput.Up)
+	capID.SetDownloadRate(throughput.Down)
+
+	limits := rp.Bandwidth.Limits()
+	capID.SetUploadLimit(limits.Up)
+	capID.SetDownloadLimit(limits.Down)
 	return call.Results.SetWhoami(capID)
 }
 
@@ -94,7 +114,7 @@ func (nh *netHandler) RemoteOnlineList(call capnp.Net_remoteOnlineList) error {
 			return err
 		}
 
-		capRemote, err := remoteToCapRemote(remote, seg)
+		capRemote, err := remoteToCapRemote(remote, rp.Remotes, seg)
 		if err != nil {
 			return err
 		}
@@ -152,6 +172,88 @@ func (nh *netHandler) RemotePing(call capnp.Net_remotePing) error {
 	})
 }
 
+func capTextListToStrings(capLst capnplib.TextList) ([]string, error) {
+	lst := []string{}
+	for idx := 0; idx < capLst.Len(); idx++ {
+		elem, err := capLst.At(idx)
+		if err != nil {
+			return nil, err
+		}
+
+		lst = append(lst, elem)
+	}
+
+	return lst, nil
+}
+
+func stringsToCapTextList(lst []string, seg *capnplib.Segment) (capnplib.TextList, error) {
+	capLst, err := capnplib.NewTextList(seg, int32(len(lst)))
+	if err != nil {
+		return capLst, err
+	}
+
+	for idx, elem := range lst {
+		if err := capLst.Set(idx, elem); err != nil {
+			return capLst, err
+		}
+	}
+
+	return capLst, nil
+}
+
+func capFoldersToFolders(capFolders capnp.RemoteFolder_List) ([]repo.Folder, error) {
+	folders := []repo.Folder{}
+	for idx := 0; idx < capFolders.Len(); idx++ {
+		capFolder := capFolders.At(idx)
+		folderName, err := capFolder.Folder()
+		if err != nil {
+			return nil, err
+		}
+
+		cs, err := capFolder.ConflictStrategy()
+		if err != nil {
+			return nil, err
+		}
+
+		folders = append(folders, repo.Folder{
+			Folder:           folderName,
+			ReadOnly:         capFolder.ReadOnly(),
+			ConflictStrategy: cs,
+		})
+	}
+
+	return folders, nil
+}
+
+func foldersToCapFolders(folders []repo.Folder, seg *capnplib.Segment) (*capnp.RemoteFolder_List, error) {
+	capFolders, err := capnp.NewRemoteFolder_List(seg, int32(len(folders)))
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, folder := range folders {
+		capFolder, err := capnp.NewRemoteFolder(seg)
+		if err != nil {
+			return nil, err
+		}
+
+		capFolder.SetReadOnly(folder.ReadOnly)
+		if err := capFolder.SetFolder(folder.Folder); err != nil {
+			return nil, err
+		}
+
+		if err := capFolder.SetConflictStrategy(folder.ConflictStrategy); err != nil {
+			return nil, err
+		}
+
+		if err := capFolders.Set(idx, capFolder); err != nil {
+			return nil, err
+		}
+	}
+
+	return &capFolders, nil
+}
+
 func capRemoteToRemote(remote capnp.Remote) (*repo.Remote, error) {
 	remoteName, err := remote.Name()
 	if err != nil {
@@ -179,24 +281,19 @@ func capRemoteToRemote(remote capnp.Remote) (*repo.Remote, error) {
 		return nil, err
 	}
 
-	folders := []repo.Folder{}
-	for idx := 0; idx < remoteFolders.Len(); idx++ {
-		capFolder := remoteFolders.At(idx)
-		folderName, err := capFolder.Folder()
-		if err != nil {
-			return nil, err
-		}
+	folders, err := capFoldersToFolders(remoteFolders)
+	if err != nil {
+		return nil, err
+	}
 
-		cs, err := capFolder.ConflictStrategy()
-		if err != nil {
-			return nil, err
-		}
+	capSubscribed, err := remote.SubscribedFolders()
+	if err != nil {
+		return nil, err
+	}
 
-		folders = append(folders, repo.Folder{
-			Folder:           folderName,
-			ReadOnly:         capFolder.ReadOnly(),
-			ConflictStrategy: cs,
-		})
+	subscribed, err := capTextListToStrings(capSubscribed)
+	if err != nil {
+		return nil, err
 	}
 
 	return &repo.Remote{
@@ -206,10 +303,16 @@ func capRemoteToRemote(remote capnp.Remote) (*repo.Remote, error) {
 		AcceptAutoUpdates: remote.AcceptAutoUpdates(),
 		AcceptPush:        remote.AcceptPush(),
 		ConflictStrategy:  conflictStrategy,
+		SubscribedFolders: subscribed,
+		Quota:             remote.Quota(),
+		UploadLimit:       remote.UploadLimit(),
+		DownloadLimit:     remote.DownloadLimit(),
 	}, nil
 }
 
-func remoteToCapRemote(remote repo.Remote, seg *capnplib.Segment) (*capnp.Remote, error) {
+// remoteToCapRemote converts `remote` to its capnp form. The groups and the
+// effective folders of the remote are looked up in `rl`.
+func remoteToCapRemote(remote repo.Remote, rl *repo.RemoteList, seg *capnplib.Segment) (*capnp.Remote, error) {
 	capRemote, err := capnp.NewRemote(seg)
 	if err != nil {
 		return nil, err
@@ -227,37 +330,47 @@ func remoteToCapRemote(remote repo.Remote, seg *capnplib.Segment) (*capnp.Remote
 		return nil, err
 	}
 
-	capFolders, err := capnp.NewRemoteFolder_List(seg, int32(len(remote.Folders)))
+	capFolders, err := foldersToCapFolders(remote.Folders, seg)
 	if err != nil {
 		return nil, err
 	}
 
-	for idx, folder := range remote.Folders {
-		capFolder, err := capnp.NewRemoteFolder(seg)
-		if err != nil {
-			return nil, err
-		}
+	if err := capRemote.SetFolders(*capFolders); err != nil {
+		return nil, err
+	}
 
-		capFolder.SetReadOnly(folder.ReadOnly)
-		if err := capFolder.SetFolder(folder.Folder); err != nil {
-			return nil, err
-		}
+	capEffective, err := foldersToCapFolders(rl.EffectiveFolders(remote), seg)
+	if err != nil {
+		return nil, err
+	}
 
-		if err := capFolder.SetConflictStrategy(folder.ConflictStrategy); err != nil {
-			return nil, err
-		}
+	if err := capRemote.SetEffectiveFolders(*capEffective); err != nil {
+		return nil, err
+	}
 
-		if err := capFolders.Set(idx, capFolder); err != nil {
-			return nil, err
-		}
+	capGroups, err := stringsToCapTextList(rl.GroupsOf(remote.Name), seg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := capRemote.SetGroups(capGroups); err != nil {
+		return nil, err
 	}
 
-	if err := capRemote.SetFolders(capFolders); err != nil {
+	capSubscribed, err := stringsToCapTextList(remote.SubscribedFolders, seg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := capRemote.SetSubscribedFolders(capSubscribed); err != nil {
 		return nil, err
 	}
 
 	capRemote.SetAcceptAutoUpdates(remote.AcceptAutoUpdates)
 	capRemote.SetAcceptPush(remote.AcceptPush)
+	capRemote.SetQuota(remote.Quota)
+	capRemote.SetUploadLimit(remote.UploadLimit)
+	capRemote.SetDownloadLimit(remote.DownloadLimit)
 	return &capRemote, nil
 }
 
@@ -275,7 +388,7 @@ func (nh *netHandler) RemoteByName(call capnp.Net_remoteByName) error {
 		return err
 	}
 
-	capRemote, err := remoteToCapRemote(rmt, call.Results.Segment())
+	capRemote, err := remoteToCapRemote(rmt, rp.Remotes, call.Results.Segment())
 	if err != nil {
 		return err
 	}
@@ -305,12 +418,18 @@ func (nh *netHandler) RemoteAddOrUpdate(call capnp.Net_remoteAddOrUpdate) error
 		return err
 	}
 
+	nh.base.recordAudit("remote.add", "", remote.Name)
 	return nh.base.syncRemoteStates()
 }
 
 func (nh *netHandler) RemoteClear(call capnp.Net_remoteClear) error {
 	server.Ack(call.Options)
-	return nh.base.repo.Remotes.Clear()
+	if err := nh.base.repo.Remotes.Clear(); err != nil {
+		return err
+	}
+
+	nh.base.recordAudit("remote.clear", "", "")
+	return nil
 }
 
 func (nh *netHandler) RemoteRm(call capnp.Net_remoteRm) error {
@@ -326,6 +445,7 @@ func (nh *netHandler) RemoteRm(call capnp.Net_remoteRm) error {
 		return err
 	}
 
+	nh.base.recordAudit("remote.remove", "", name)
 	return nh.base.syncRemoteStates()
 }
 
@@ -345,7 +465,7 @@ func (nh *netHandler) RemoteLs(call capnp.Net_remoteLs) error {
 	}
 
 	for idx, remote := range remotes {
-		capRemote, err := remoteToCapRemote(remote, seg)
+		capRemote, err := remoteToCapRemote(remote, rp.Remotes, seg)
 		if err != nil {
 			return err
 		}
@@ -372,7 +492,12 @@ func (nh *netHandler) RemoteUpdate(call capnp.Net_remoteUpdate) error {
 		return err
 	}
 
-	return rp.Remotes.AddOrUpdateRemote(*remote)
+	if err := rp.Remotes.AddOrUpdateRemote(*remote); err != nil {
+		return err
+	}
+
+	nh.base.recordAudit("remote.modify", "", remote.Name)
+	return nil
 }
 
 func (nh *netHandler) RemoteSave(call capnp.Net_remoteSave) error {
@@ -399,6 +524,7 @@ func (nh *netHandler) RemoteSave(call capnp.Net_remoteSave) error {
 		return err
 	}
 
+	nh.base.recordAudit("remote.save", "", fmt.Sprintf("%d remotes", len(remotes)))
 	return nh.base.syncRemoteStates()
 }
 
@@ -408,6 +534,7 @@ type LocateResult struct {
 	Mask        string
 	Addr        string
 	Fingerprint string
+	Verified    bool
 }
 
 func (nh *netHandler) peekAndCachePeer(peer peer.Info, mask p2pnet.LocateMask, ticket uint64) error {
@@ -425,6 +552,25 @@ func (nh *netHandler) peekAndCachePeer(peer peer.Info, mask p2pnet.LocateMask, t
 		return err
 	}
 
+	// Some backends know who published the name already.
+	// Only prefer their fingerprint if it matched a remote we know;
+	// otherwise it is not better than what the peer told us.
+	verified := false
+	if peer.Verified && peer.Fingerprint != "" {
+		fingerprint = peer.Fingerprint
+		verified = true
+		if remoteName == "" {
+			remoteName = string(peer.Name)
+		}
+	} else {
+		for _, known := range nh.base.knownFingerprints(remoteName) {
+			if known == fingerprint {
+				verified = true
+				break
+			}
+		}
+	}
+
 	if string(fingerprint) == "" {
 		return nil
 	}
@@ -434,6 +580,7 @@ func (nh *netHandler) peekAndCachePeer(peer peer.Info, mask p2pnet.LocateMask, t
 		Addr:        string(peer.Addr),
 		Mask:        mask.String(),
 		Fingerprint: string(fingerprint),
+		Verified:    verified,
 	}
 
 	log.Debugf("Pushing partial result: %v", result)
@@ -564,6 +711,8 @@ func (nh *netHandler) NetLocateNext(call capnp.Net_netLocateNext) error {
 		return err
 	}
 
+	capResult.SetVerified(result.Verified)
+
 	return call.Results.SetResult(capResult)
 }
 
@@ -573,20 +722,226 @@ func (nh *netHandler) Push(call capnp.Net_push) error {
 		return err
 	}
 
-	return nh.base.withNetClient(remoteName, func(ctl *p2pnet.Client) error {
-		pushAllowed, err := ctl.IsPushAllowed()
+	nh.base.recordAudit("net.push", "", remoteName)
+	return nh.base.doPush(nh.base.ctx, remoteName, call.Params.DryRun(), nil)
+}
+
+func (nh *netHandler) PushStart(call capnp.Net_pushStart) error {
+	server.Ack(call.Options)
+
+	remoteName, err := call.Params.RemoteName()
+	if err != nil {
+		return err
+	}
+
+	nh.base.recordAudit("net.push", "", remoteName)
+	dryRun := call.Params.DryRun()
+	ticket := nh.base.startJob("push", remoteName, func(ctx context.Context, rep *jobReporter) error {
+		return nh.base.doPush(ctx, remoteName, dryRun, rep)
+	})
+
+	call.Results.SetTicket(ticket)
+	return nil
+}
+
+func (nh *netHandler) RemoteInvite(call capnp.Net_remoteInvite) error {
+	server.Ack(call.Options)
+
+	capFolders, err := call.Params.Folders()
+	if err != nil {
+		return err
+	}
+
+	folders := []repo.Folder{}
+	for idx := 0; idx < capFolders.Len(); idx++ {
+		capFolder := capFolders.At(idx)
+		folder, err := capFolder.Folder()
 		if err != nil {
 			return err
 		}
 
-		if !pushAllowed {
-			return fmt.Errorf("cannot push: remote does not allow it")
+		folders = append(folders, repo.Folder{
+			Folder:   folder,
+			ReadOnly: capFolder.ReadOnly(),
+		})
+	}
+
+	timeout := time.Duration(call.Params.TimeoutSec() * float64(time.Second))
+	code, err := nh.base.peerServer.Invite(folders, timeout)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := code.Encode()
+	if err != nil {
+		return err
+	}
+
+	nh.base.recordAudit("remote.invite", "", fmt.Sprintf("%v", folders))
+	return call.Results.SetCode(encoded)
+}
+
+func (nh *netHandler) RemoteJoin(call capnp.Net_remoteJoin) error {
+	server.Ack(call.Options)
+
+	capCode, err := call.Params.Code()
+	if err != nil {
+		return err
+	}
+
+	code, err := p2pnet.ParseInviteCode(capCode)
+	if err != nil {
+		return err
+	}
+
+	b := nh.base
+	if code.Name == b.repo.Owner {
+		return fmt.Errorf("refusing to join yourself")
+	}
+
+	remote, err := p2pnet.Join(b.ctx, code, b.repo, b.backend, b.peerServer.PingMap())
+	if err != nil {
+		return err
+	}
+
+	b.recordAudit("remote.join", "", remote.Name)
+	if err := b.syncRemoteStates(); err != nil {
+		return err
+	}
+
+	capRemote, err := remoteToCapRemote(*remote, nh.base.repo.Remotes, call.Results.Segment())
+	if err != nil {
+		return err
+	}
+
+	return call.Results.SetRemote(*capRemote)
+}
+
+func capGroupToGroup(capGroup capnp.RemoteGroup) (*repo.Group, error) {
+	name, err := capGroup.Name()
+	if err != nil {
+		return nil, err
+	}
+
+	capMembers, err := capGroup.Members()
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := capTextListToStrings(capMembers)
+	if err != nil {
+		return nil, err
+	}
+
+	capFolders, err := capGroup.Folders()
+	if err != nil {
+		return nil, err
+	}
+
+	folders, err := capFoldersToFolders(capFolders)
+	if err != nil {
+		return nil, err
+	}
+
+	return &repo.Group{
+		Name:    name,
+		Members: members,
+		Folders: folders,
+	}, nil
+}
+
+func groupToCapGroup(group repo.Group, seg *capnplib.Segment) (*capnp.RemoteGroup, error) {
+	capGroup, err := capnp.NewRemoteGroup(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := capGroup.SetName(group.Name); err != nil {
+		return nil, err
+	}
+
+	capMembers, err := stringsToCapTextList(group.Members, seg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := capGroup.SetMembers(capMembers); err != nil {
+		return nil, err
+	}
+
+	capFolders, err := foldersToCapFolders(group.Folders, seg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := capGroup.SetFolders(*capFolders); err != nil {
+		return nil, err
+	}
+
+	return &capGroup, nil
+}
+
+func (nh *netHandler) RemoteGroupLs(call capnp.Net_remoteGroupLs) error {
+	server.Ack(call.Options)
+
+	groups, err := nh.base.repo.Remotes.ListGroups()
+	if err != nil {
+		return err
+	}
+
+	seg := call.Results.Segment()
+	capGroups, err := capnp.NewRemoteGroup_List(seg, int32(len(groups)))
+	if err != nil {
+		return err
+	}
+
+	for idx, group := range groups {
+		capGroup, err := groupToCapGroup(group, seg)
+		if err != nil {
+			return err
 		}
 
-		if call.Params.DryRun() {
-			return nil
+		if err := capGroups.Set(idx, *capGroup); err != nil {
+			return err
 		}
+	}
 
-		return ctl.Push()
-	})
+	return call.Results.SetGroups(capGroups)
+}
+
+func (nh *netHandler) RemoteGroupSet(call capnp.Net_remoteGroupSet) error {
+	server.Ack(call.Options)
+
+	capGroup, err := call.Params.Group()
+	if err != nil {
+		return err
+	}
+
+	group, err := capGroupToGroup(capGroup)
+	if err != nil {
+		return err
+	}
+
+	if err := nh.base.repo.Remotes.AddOrUpdateGroup(*group); err != nil {
+		return err
+	}
+
+	nh.base.recordAudit("remote.group.modify", "", group.Name)
+	return nil
+}
+
+func (nh *netHandler) RemoteGroupRm(call capnp.Net_remoteGroupRm) error {
+	server.Ack(call.Options)
+
+	name, err := call.Params.Name()
+	if err != nil {
+		return err
+	}
+
+	if err := nh.base.repo.Remotes.RmGroup(name); err != nil {
+		return err
+	}
+
+	nh.base.recordAudit("remote.group.remove", "", name)
+	return nil
 }
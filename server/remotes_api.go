@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 
 	e "github.com/pkg/errors"
@@ -60,6 +61,10 @@ func (a *RemotesAPI) get(name string) (*remotesapi.Remote, error) {
 	extRmt.AcceptAutoUpdates = rmt.AcceptAutoUpdates
 	extRmt.AcceptPush = rmt.AcceptPush
 	extRmt.ConflictStrategy = rmt.ConflictStrategy
+	extRmt.SubscribedFolders = rmt.SubscribedFolders
+	extRmt.Quota = &rmt.Quota
+	extRmt.UploadLimit = &rmt.UploadLimit
+	extRmt.DownloadLimit = &rmt.DownloadLimit
 
 	for _, folder := range rmt.Folders {
 		extRmt.Folders = append(extRmt.Folders, remotesapi.Folder{
@@ -98,6 +103,34 @@ func (a *RemotesAPI) Set(rm remotesapi.Remote) error {
 		})
 	}
 
+	// Older clients do not know about subscribed folders.
+	// Do not reset them when they were not given.
+	subscribed := rm.SubscribedFolders
+	if subscribed == nil {
+		if oldRmt, err := a.base.repo.Remotes.Remote(rm.Name); err == nil {
+			subscribed = oldRmt.SubscribedFolders
+		}
+	}
+
+	// Same goes for the quota and the bandwidth limits.
+	// Remotes that do not exist yet start without any.
+	oldRmt, _ := a.base.repo.Remotes.Remote(rm.Name)
+
+	quota := oldRmt.Quota
+	if rm.Quota != nil {
+		quota = *rm.Quota
+	}
+
+	uploadLimit := oldRmt.UploadLimit
+	if rm.UploadLimit != nil {
+		uploadLimit = *rm.UploadLimit
+	}
+
+	downloadLimit := oldRmt.DownloadLimit
+	if rm.DownloadLimit != nil {
+		downloadLimit = *rm.DownloadLimit
+	}
+
 	err = a.base.repo.Remotes.AddOrUpdateRemote(repo.Remote{
 		Name:              rm.Name,
 		Fingerprint:       fp,
@@ -105,6 +138,10 @@ func (a *RemotesAPI) Set(rm remotesapi.Remote) error {
 		AcceptAutoUpdates: rm.AcceptAutoUpdates,
 		AcceptPush:        rm.AcceptPush,
 		ConflictStrategy:  rm.ConflictStrategy,
+		SubscribedFolders: subscribed,
+		Quota:             quota,
+		UploadLimit:       uploadLimit,
+		DownloadLimit:     downloadLimit,
 	})
 
 	if err != nil {
@@ -145,20 +182,37 @@ func (a *RemotesAPI) Self() (remotesapi.Identity, error) {
 // Sync synchronizes the latest state of `name` with our latest state.
 func (a *RemotesAPI) Sync(name string) error {
 	msg := fmt.Sprintf("sync with »%s« from gateway", name)
-	_, err := a.base.doSync(name, true, msg)
-	return err
+	return a.base.runJob("sync", name, func(ctx context.Context, rep *jobReporter) error {
+		_, err := a.base.doSync(ctx, name, true, msg, nil, rep)
+		return err
+	})
+}
+
+// SyncCancel cancels all running syncs with `name`.
+func (a *RemotesAPI) SyncCancel(name string) error {
+	return a.base.cancelJobs("sync", name)
 }
 
 // MakeDiff produces a diff to the remote with `name`.
 func (a *RemotesAPI) MakeDiff(name string) (*catfs.Diff, error) {
-	if err := a.base.doFetch(name); err != nil {
+	if err := a.base.doFetch(a.base.ctx, name, nil); err != nil {
 		return nil, e.Wrapf(err, "fetch-remote")
 	}
 
+	rmt, err := a.base.repo.Remotes.Remote(name)
+	if err != nil {
+		return nil, err
+	}
+
 	var diff *catfs.Diff
 	return diff, a.base.withCurrFs(func(localFs *catfs.FS) error {
 		return a.base.withRemoteFs(name, func(remoteFs *catfs.FS) error {
-			newDiff, err := localFs.MakeDiff(remoteFs, "CURR", "CURR")
+			newDiff, err := localFs.MakeDiff(
+				remoteFs,
+				"CURR",
+				"CURR",
+				catfs.SyncOptOnlyFolders(rmt.SubscribedFolders),
+			)
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,361 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sahib/brig/catfs"
+	p2pnet "github.com/sahib/brig/net"
+	"github.com/sahib/brig/server/capnp"
+	"github.com/sahib/brig/util/bloom"
+	h "github.com/sahib/brig/util/hashlib"
+	log "github.com/sirupsen/logrus"
+	"zombiezen.com/go/capnproto2/server"
+)
+
+const (
+	// replicaDialTimeout is how long we try to reach a single remote
+	// when exchanging pin summaries.
+	replicaDialTimeout = 30 * time.Second
+)
+
+// replicaTable remembers what content the online remotes have pinned,
+// as told by their last pin summary.
+type replicaTable struct {
+	mu        sync.Mutex
+	summaries map[string]*bloom.Filter
+}
+
+func newReplicaTable() *replicaTable {
+	return &replicaTable{
+		summaries: make(map[string]*bloom.Filter),
+	}
+}
+
+func (rt *replicaTable) set(name string, filter *bloom.Filter) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	rt.summaries[name] = filter
+}
+
+func (rt *replicaTable) forget(name string) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	delete(rt.summaries, name)
+}
+
+// add marks `hash` as pinned by `name` until the next summary arrives.
+func (rt *replicaTable) add(name string, hash h.Hash) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if filter, ok := rt.summaries[name]; ok {
+		filter.Add(hash.Bytes())
+	}
+}
+
+// remotes returns the names of all remotes we have a summary of.
+func (rt *replicaTable) remotes() []string {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	names := []string{}
+	for name := range rt.summaries {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// holders returns the names of all remotes that (probably) pinned `hash`.
+func (rt *replicaTable) holders(hash h.Hash) []string {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	names := []string{}
+	for name, filter := range rt.summaries {
+		if filter.Test(hash.Bytes()) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func (b *base) replicaLoop(ctx context.Context) {
+	lastCheck := time.Now()
+	checkTicker := time.NewTicker(1 * time.Second)
+	defer checkTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Debugf("quitting the replica loop")
+			return
+		case <-checkTicker.C:
+			if !b.repo.Config.Bool("fs.replicas.enabled") {
+				continue
+			}
+
+			if time.Since(lastCheck) < b.repo.Config.Duration("fs.replicas.interval") {
+				continue
+			}
+
+			lastCheck = time.Now()
+			if err := b.updateReplicas(ctx); err != nil {
+				log.Warningf("failed to update replicas: %v", err)
+				continue
+			}
+
+			if err := b.enforceReplicaPolicies(ctx); err != nil {
+				log.Warningf("failed to check replica policies: %v", err)
+			}
+		}
+	}
+}
+
+// updateReplicas fetches the pin summaries of all online remotes.
+// Remotes that are not reachable are removed from the replica table.
+func (b *base) updateReplicas(ctx context.Context) error {
+	remotes, err := b.repo.Remotes.ListRemotes()
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool)
+	for _, remote := range remotes {
+		known[remote.Name] = true
+
+		// Do not bother dialing remotes we know to be offline:
+		pinger, err := b.peerServer.PingMap().For(remote.Fingerprint.Addr())
+		if err == nil && pinger.Err() != nil {
+			b.replicas.forget(remote.Name)
+			continue
+		}
+
+		dialCtx, cancel := context.WithTimeout(ctx, replicaDialTimeout)
+		err = b.withNetClientContext(dialCtx, remote.Name, func(ctl *p2pnet.Client) error {
+			filter, err := ctl.PinSummary()
+			if err != nil {
+				return err
+			}
+
+			b.replicas.set(remote.Name, filter)
+			return nil
+		})
+
+		cancel()
+
+		if err != nil {
+			log.Debugf("failed to get pin summary of »%s«: %v", remote.Name, err)
+			b.replicas.forget(remote.Name)
+		}
+	}
+
+	for _, name := range b.replicas.remotes() {
+		if !known[name] {
+			b.replicas.forget(name)
+		}
+	}
+
+	return nil
+}
+
+// enforceReplicaPolicies warns about files that are pinned by less remotes
+// than their replica policy requires and asks other online remotes to pin them.
+func (b *base) enforceReplicaPolicies(ctx context.Context) error {
+	fs, err := b.repo.FS(b.repo.Owner, b.backend)
+	if err != nil {
+		return err
+	}
+
+	files, err := fs.ReplicaFiles("/")
+	if err != nil {
+		return err
+	}
+
+	online := b.replicas.remotes()
+	requests := make(map[string][]h.Hash)
+
+	for _, file := range files {
+		if file.MinReplicas == 0 {
+			continue
+		}
+
+		holders := b.replicas.holders(file.BackendHash)
+		if len(holders) >= file.MinReplicas {
+			continue
+		}
+
+		log.Warningf(
+			"»%s« is pinned by %d online remotes, but should be by %d",
+			file.Path, len(holders), file.MinReplicas,
+		)
+
+		isHolder := make(map[string]bool)
+		for _, holder := range holders {
+			isHolder[holder] = true
+		}
+
+		missing := file.MinReplicas - len(holders)
+		for _, name := range online {
+			if missing == 0 {
+				break
+			}
+
+			// Only ask remotes that may see the file anyways:
+			remote, err := b.repo.Remotes.EffectiveRemote(name)
+			if err != nil || !remote.CanAccess(file.Path) {
+				continue
+			}
+
+			if !isHolder[name] {
+				requests[name] = append(requests[name], file.BackendHash)
+				missing--
+			}
+		}
+	}
+
+	for name, hashes := range requests {
+		dialCtx, cancel := context.WithTimeout(ctx, replicaDialTimeout)
+		err := b.withNetClientContext(dialCtx, name, func(ctl *p2pnet.Client) error {
+			pinned, err := ctl.RequestPins(hashes)
+			if err != nil {
+				return err
+			}
+
+			for _, hash := range pinned {
+				b.replicas.add(name, hash)
+			}
+
+			log.Infof("»%s« pinned %d of %d requested files", name, len(pinned), len(hashes))
+			return nil
+		})
+
+		cancel()
+
+		if err != nil {
+			log.Debugf("»%s« did not pin any requested files: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (fh *fsHandler) ReplicaStatus(call capnp.FS_replicaStatus) error {
+	server.Ack(call.Options)
+
+	root, err := call.Params.Root()
+	if err != nil {
+		return err
+	}
+
+	if call.Params.Refresh() {
+		if err := fh.base.updateReplicas(fh.base.ctx); err != nil {
+			return err
+		}
+	}
+
+	return fh.base.withCurrFs(func(fs *catfs.FS) error {
+		files, err := fs.ReplicaFiles(root)
+		if err != nil {
+			return err
+		}
+
+		seg := call.Results.Segment()
+		lst, err := capnp.NewReplicaStatus_List(seg, int32(len(files)))
+		if err != nil {
+			return err
+		}
+
+		for idx, file := range files {
+			capStatus, err := capnp.NewReplicaStatus(seg)
+			if err != nil {
+				return err
+			}
+
+			if err := capStatus.SetPath(file.Path); err != nil {
+				return err
+			}
+
+			capRemotes, err := stringsToCapTextList(fh.base.replicas.holders(file.BackendHash), seg)
+			if err != nil {
+				return err
+			}
+
+			if err := capStatus.SetRemotes(capRemotes); err != nil {
+				return err
+			}
+
+			capStatus.SetSize(file.Size)
+			capStatus.SetIsPinned(file.IsPinned)
+			capStatus.SetMinReplicas(int32(file.MinReplicas))
+			if err := lst.Set(idx, capStatus); err != nil {
+				return err
+			}
+		}
+
+		return call.Results.SetFiles(lst)
+	})
+}
+
+func (fh *fsHandler) ReplicaPolicySet(call capnp.FS_replicaPolicySet) error {
+	server.Ack(call.Options)
+
+	path, err := call.Params.Path()
+	if err != nil {
+		return err
+	}
+
+	return fh.base.withCurrFs(func(fs *catfs.FS) error {
+		return fs.SetReplicaPolicy(path, int(call.Params.MinReplicas()))
+	})
+}
+
+func (fh *fsHandler) ReplicaPolicyList(call capnp.FS_replicaPolicyList) error {
+	server.Ack(call.Options)
+
+	return fh.base.withCurrFs(func(fs *catfs.FS) error {
+		policies, err := fs.ReplicaPolicies()
+		if err != nil {
+			return err
+		}
+
+		folders := []string{}
+		for folder := range policies {
+			folders = append(folders, folder)
+		}
+
+		sort.Strings(folders)
+
+		seg := call.Results.Segment()
+		lst, err := capnp.NewReplicaPolicy_List(seg, int32(len(folders)))
+		if err != nil {
+			return err
+		}
+
+		for idx, folder := range folders {
+			capPolicy, err := capnp.NewReplicaPolicy(seg)
+			if err != nil {
+				return err
+			}
+
+			if err := capPolicy.SetPath(folder); err != nil {
+				return err
+			}
+
+			capPolicy.SetMinReplicas(int32(policies[folder]))
+			if err := lst.Set(idx, capPolicy); err != nil {
+				return err
+			}
+		}
+
+		return call.Results.SetPolicies(lst)
+	})
+}
@@ -2,12 +2,15 @@ package server
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
+	"github.com/sahib/brig/audit"
 	"github.com/sahib/brig/backend"
 	"github.com/sahib/brig/fuse"
 	gwdb "github.com/sahib/brig/gateway/db"
 	gwcapnp "github.com/sahib/brig/gateway/db/capnp"
+	"github.com/sahib/brig/repo"
 	"github.com/sahib/brig/server/capnp"
 	"github.com/sahib/brig/version"
 	log "github.com/sirupsen/logrus"
@@ -250,6 +253,8 @@ func (rh *repoHandler) ConfigSet(call capnp.Repo_configSet) error {
 		return err
 	}
 
+	// The value is not recorded, since it might be a secret:
+	rh.base.recordAudit("config.set", "", key)
 	return rp.SaveConfig()
 }
 
@@ -410,7 +415,12 @@ func (rh *repoHandler) GatewayUserAdd(call capnp.Repo_gatewayUserAdd) error {
 	}
 
 	gwDb := rh.base.gateway.UserDatabase()
-	return gwDb.Add(name, password, folders, rights)
+	if err := gwDb.Add(name, password, folders, rights); err != nil {
+		return err
+	}
+
+	rh.base.recordAudit("gateway.user_add", "", name)
+	return nil
 }
 
 func (rh *repoHandler) GatewayUserRm(call capnp.Repo_gatewayUserRm) error {
@@ -422,7 +432,12 @@ func (rh *repoHandler) GatewayUserRm(call capnp.Repo_gatewayUserRm) error {
 	}
 
 	gwDb := rh.base.gateway.UserDatabase()
-	return gwDb.Remove(name)
+	if err := gwDb.Remove(name); err != nil {
+		return err
+	}
+
+	rh.base.recordAudit("gateway.user_remove", "", name)
+	return nil
 }
 
 func (rh *repoHandler) GatewayUserList(call capnp.Repo_gatewayUserList) error {
@@ -454,8 +469,384 @@ func (rh *repoHandler) GatewayUserList(call capnp.Repo_gatewayUserList) error {
 	return call.Results.SetUsers(capUsers)
 }
 
+func (rh *repoHandler) GatewayTokenAdd(call capnp.Repo_gatewayTokenAdd) error {
+	server.Ack(call.Options)
+
+	user, err := call.Params.User()
+	if err != nil {
+		return err
+	}
+
+	name, err := call.Params.Name()
+	if err != nil {
+		return err
+	}
+
+	capFolders, err := call.Params.Folders()
+	if err != nil {
+		return err
+	}
+
+	folders := []string{}
+	for idx := 0; idx < capFolders.Len(); idx++ {
+		folder, err := capFolders.At(idx)
+		if err != nil {
+			return err
+		}
+
+		if !strings.HasPrefix(folder, "/") {
+			folder = "/" + folder
+		}
+
+		folders = append(folders, folder)
+	}
+
+	capRights, err := call.Params.Rights()
+	if err != nil {
+		return err
+	}
+
+	rights := []string{}
+	for idx := 0; idx < capRights.Len(); idx++ {
+		right, err := capRights.At(idx)
+		if err != nil {
+			return err
+		}
+
+		rights = append(rights, right)
+	}
+
+	gwDb := rh.base.gateway.UserDatabase()
+	token, info, err := gwDb.AddToken(user, name, folders, rights)
+	if err != nil {
+		return err
+	}
+
+	rh.base.recordAudit("gateway.token_add", "", fmt.Sprintf("%s for %s", info.ID, user))
+	return call.Results.SetToken(token)
+}
+
+func (rh *repoHandler) GatewayTokenRm(call capnp.Repo_gatewayTokenRm) error {
+	server.Ack(call.Options)
+
+	id, err := call.Params.Id()
+	if err != nil {
+		return err
+	}
+
+	gwDb := rh.base.gateway.UserDatabase()
+	if err := gwDb.RemoveToken(id); err != nil {
+		return err
+	}
+
+	rh.base.recordAudit("gateway.token_remove", "", id)
+	return nil
+}
+
+func (rh *repoHandler) GatewayTokenList(call capnp.Repo_gatewayTokenList) error {
+	server.Ack(call.Options)
+
+	user, err := call.Params.User()
+	if err != nil {
+		return err
+	}
+
+	gwDb := rh.base.gateway.UserDatabase()
+	tokens, err := gwDb.ListTokens(user)
+	if err != nil {
+		return err
+	}
+
+	seg := call.Results.Segment()
+	capTokens, err := gwcapnp.NewToken_List(seg, int32(len(tokens)))
+	if err != nil {
+		return err
+	}
+
+	for idx, token := range tokens {
+		capToken, err := gwdb.TokenToCapnp(&token, seg)
+		if err != nil {
+			return err
+		}
+
+		if err := capTokens.Set(idx, *capToken); err != nil {
+			return err
+		}
+	}
+
+	return call.Results.SetTokens(capTokens)
+}
+
+func (rh *repoHandler) AuditLog(call capnp.Repo_auditLog) error {
+	server.Ack(call.Options)
+
+	query := audit.Query{}
+
+	var err error
+	if query.User, err = call.Params.User(); err != nil {
+		return err
+	}
+
+	if query.Path, err = call.Params.Path(); err != nil {
+		return err
+	}
+
+	if query.Action, err = call.Params.Action(); err != nil {
+		return err
+	}
+
+	since, err := call.Params.Since()
+	if err != nil {
+		return err
+	}
+
+	if since != "" {
+		if err := query.Since.UnmarshalText([]byte(since)); err != nil {
+			return err
+		}
+	}
+
+	entries := []audit.Entry{}
+	err = rh.base.repo.Audit.Query(query, func(en audit.Entry) error {
+		entries = append(entries, en)
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	seg := call.Results.Segment()
+	capEntries, err := capnp.NewAuditEntry_List(seg, int32(len(entries)))
+	if err != nil {
+		return err
+	}
+
+	for idx, en := range entries {
+		capEntry, err := auditEntryToCapnp(en, seg)
+		if err != nil {
+			return err
+		}
+
+		if err := capEntries.Set(idx, *capEntry); err != nil {
+			return err
+		}
+	}
+
+	return call.Results.SetEntries(capEntries)
+}
+
+func (rh *repoHandler) AuditVerify(call capnp.Repo_auditVerify) error {
+	server.Ack(call.Options)
+
+	count, head, err := rh.base.repo.Audit.Verify()
+	if err != nil {
+		return err
+	}
+
+	call.Results.SetCount(count)
+	return call.Results.SetHead(head)
+}
+
+func auditEntryToCapnp(en audit.Entry, seg *capnplib.Segment) (*capnp.AuditEntry, error) {
+	capEntry, err := capnp.NewAuditEntry(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	enTime, err := en.Time.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+
+	capEntry.SetSeq(en.Seq)
+	if err := capEntry.SetTime(string(enTime)); err != nil {
+		return nil, err
+	}
+
+	if err := capEntry.SetSource(en.Source); err != nil {
+		return nil, err
+	}
+
+	if err := capEntry.SetUser(en.User); err != nil {
+		return nil, err
+	}
+
+	if err := capEntry.SetAddr(en.Addr); err != nil {
+		return nil, err
+	}
+
+	if err := capEntry.SetAction(en.Action); err != nil {
+		return nil, err
+	}
+
+	if err := capEntry.SetPath(en.Path); err != nil {
+		return nil, err
+	}
+
+	if err := capEntry.SetDetail(en.Detail); err != nil {
+		return nil, err
+	}
+
+	if err := capEntry.SetPrev(en.Prev); err != nil {
+		return nil, err
+	}
+
+	if err := capEntry.SetHash(en.Hash); err != nil {
+		return nil, err
+	}
+
+	return &capEntry, nil
+}
+
 func (rh *repoHandler) DebugProfilePort(call capnp.Repo_debugProfilePort) error {
 	server.Ack(call.Options)
 	call.Results.SetPort(int32(rh.base.pprofPort))
 	return nil
 }
+
+func (rh *repoHandler) JobStatus(call capnp.Repo_jobStatus) error {
+	server.Ack(call.Options)
+
+	ticket := call.Params.Ticket()
+	status, ok, err := rh.base.jobStatusByTicket(ticket)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		// The job did not report anything yet.
+		status = jobStatus{Ticket: ticket, Phase: "start"}
+	}
+
+	capStatus, err := jobStatusToCapnp(status, call.Results.Segment())
+	if err != nil {
+		return err
+	}
+
+	return call.Results.SetStatus(*capStatus)
+}
+
+func (rh *repoHandler) JobCancel(call capnp.Repo_jobCancel) error {
+	server.Ack(call.Options)
+	return rh.base.conductor.Cancel(call.Params.Ticket())
+}
+
+func (rh *repoHandler) JobList(call capnp.Repo_jobList) error {
+	server.Ack(call.Options)
+
+	seg := call.Results.Segment()
+	statuses := rh.base.jobs()
+
+	lst, err := capnp.NewJobStatus_List(seg, int32(len(statuses)))
+	if err != nil {
+		return err
+	}
+
+	for idx, status := range statuses {
+		capStatus, err := jobStatusToCapnp(status, seg)
+		if err != nil {
+			return err
+		}
+
+		if err := lst.Set(idx, *capStatus); err != nil {
+			return err
+		}
+	}
+
+	return call.Results.SetJobs(lst)
+}
+
+func (rh *repoHandler) BackupCreate(call capnp.Repo_backupCreate) error {
+	server.Ack(call.Options)
+
+	path, err := call.Params.Path()
+	if err != nil {
+		return err
+	}
+
+	parentPath, err := call.Params.ParentPath()
+	if err != nil {
+		return err
+	}
+
+	opts := repo.BackupOptions{
+		WithContent: call.Params.WithContent(),
+	}
+
+	if parentPath != "" {
+		fd, err := os.Open(parentPath) // #nosec
+		if err != nil {
+			return err
+		}
+
+		opts.Parent, err = repo.ReadBackupManifest(fd, rh.base.password)
+		fd.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Write to a temporary file first, so we do not leave
+	// a half-written backup behind if something goes wrong.
+	tmpPath := path + ".tmp"
+	fd, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := rh.base.repo.Backup(fd, rh.base.password, rh.base.backend, opts)
+	if closeErr := fd.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	rh.base.recordAudit("repo.backup", "", path)
+	capManifest, err := backupManifestToCapnp(manifest, call.Results.Segment())
+	if err != nil {
+		return err
+	}
+
+	return call.Results.SetManifest(*capManifest)
+}
+
+func backupManifestToCapnp(manifest *repo.BackupManifest, seg *capnplib.Segment) (*capnp.BackupManifest, error) {
+	capManifest, err := capnp.NewBackupManifest(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := manifest.Created.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := capManifest.SetCreated(string(created)); err != nil {
+		return nil, err
+	}
+
+	if err := capManifest.SetId(manifest.ID); err != nil {
+		return nil, err
+	}
+
+	if err := capManifest.SetParent(manifest.Parent); err != nil {
+		return nil, err
+	}
+
+	if err := capManifest.SetOwner(manifest.Owner); err != nil {
+		return nil, err
+	}
+
+	capManifest.SetWithContent(manifest.WithContent)
+	capManifest.SetBlobs(int64(len(manifest.Blobs)))
+	capManifest.SetNewBlobs(int64(manifest.NewBlobs))
+	return &capManifest, nil
+}
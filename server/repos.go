@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	e "github.com/pkg/errors"
+	"github.com/sahib/brig/backend"
+	"github.com/sahib/brig/repo"
+	"github.com/sahib/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// ServedRepos returns the repositories configured under daemon.repos
+// in `cfg`, which is the config of the repository a daemon was started for.
+// The key of the map is the name of the repository, the value its path.
+func ServedRepos(cfg *config.Config) map[string]string {
+	repos := make(map[string]string)
+	section := cfg.Section("daemon.repos")
+	for _, key := range section.Keys() {
+		split := strings.Split(key, ".")
+		if len(split) != 2 || split[1] != "path" {
+			continue
+		}
+
+		if path := section.String(key); path != "" {
+			repos[split[0]] = path
+		}
+	}
+
+	return repos
+}
+
+// repoSet are the repositories served by one daemon. The repository the
+// daemon was started for is always served. Others are configured under
+// daemon.repos and are chosen by clients with their name or by connecting
+// to their socket. Every repository has its own filesystem, remotes,
+// gateway and mounts, but they share the connection to the backend.
+type repoSet struct {
+	// token that clients connecting over tcp need to send.
+	// Empty if tcp is disabled.
+	token string
+
+	primary *base
+
+	mu    sync.Mutex
+	named map[string]*base
+
+	// sockets maps the socket of a named repository to its name.
+	sockets map[string]string
+}
+
+func newRepoSet(primary *base, lst *localListener) *repoSet {
+	return &repoSet{
+		token:   lst.token,
+		primary: primary,
+		named:   make(map[string]*base),
+		sockets: lst.sockets,
+	}
+}
+
+// loadNamed loads all repositories that got a socket.
+// Repositories that fail to load are not served, but
+// do not stop the daemon from working.
+func (rs *repoSet) loadNamed() {
+	names := []string{}
+	paths := make(map[string]string)
+	for socketPath, name := range rs.sockets {
+		names = append(names, name)
+		paths[name] = filepath.Dir(socketPath)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		b, err := rs.loadRepo(name, paths[name])
+		if err != nil {
+			log.Warningf("failed to load repository %s: %v", name, err)
+			continue
+		}
+
+		rs.mu.Lock()
+		rs.named[name] = b
+		rs.mu.Unlock()
+	}
+}
+
+func (rs *repoSet) loadRepo(name, repoPath string) (*base, error) {
+	log.Infof("loading repository %s at %s", name, repoPath)
+
+	// Without a password helper, the repository needs the same password:
+	password, err := readPasswordFromHelper(repoPath, func() (string, error) {
+		return rs.primary.password, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repo.CheckPassword(repoPath, password); err != nil {
+		return nil, e.Wrapf(err, "password")
+	}
+
+	b := newBase(
+		rs.primary.ctx,
+		rs.primary.port,
+		repoPath,
+		password,
+		rs.primary.bindHost,
+		rs.primary.quitCh,
+		rs.primary.logToStdout,
+		rs.primary.backends,
+	)
+
+	if err := b.loadAll(); err != nil {
+		return nil, err
+	}
+
+	if err := applyFstabInitially(b); err != nil {
+		log.Warnf("could not mount fstab mounts of %s: %v", name, err)
+	}
+
+	// The profile server is shared by all repositories:
+	b.pprofPort = rs.primary.pprofPort
+	return b, nil
+}
+
+// lookup returns the repository called `name`.
+// The repository the daemon was started for has the empty name.
+func (rs *repoSet) lookup(name string) (*base, error) {
+	if name == "" {
+		return rs.primary, nil
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	b, ok := rs.named[name]
+	if !ok {
+		for _, socketName := range rs.sockets {
+			if socketName == name {
+				return nil, e.Errorf("repository %s could not be loaded", name)
+			}
+		}
+
+		return nil, e.Errorf("no such repository: %s", name)
+	}
+
+	return b, nil
+}
+
+// Handle is being called by the base server implementation
+// for every local request that is being served to the brig daemon.
+func (rs *repoSet) Handle(ctx context.Context, conn net.Conn) {
+	b, err := rs.authenticate(conn)
+	if err != nil {
+		log.Warnf("rejected client: %v", err)
+		conn.Close()
+		return
+	}
+
+	b.serve(conn)
+}
+
+// Quit shuts down all repositories.
+func (rs *repoSet) Quit() error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	for name, b := range rs.named {
+		if err := b.Quit(); err != nil {
+			log.Warningf("failed to shut down repository %s: %v", name, err)
+		}
+	}
+
+	return rs.primary.Quit()
+}
+
+/////////
+
+// backendPool hands out the backends of the repositories served by a
+// daemon. Repositories using the same backend share one connection to it.
+type backendPool struct {
+	mu  sync.Mutex
+	bks map[string]backend.Backend
+}
+
+func newBackendPool() *backendPool {
+	return &backendPool{
+		bks: make(map[string]backend.Backend),
+	}
+}
+
+func (bp *backendPool) get(name, path, fingerprint string) (backend.Backend, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	key := name + ":" + path
+	if bk, ok := bp.bks[key]; ok {
+		log.Infof("sharing backend `%s` with another repository", name)
+		return backend.Share(bk, name, path, fingerprint)
+	}
+
+	bk, err := backend.FromName(name, path, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	bp.bks[key] = bk
+	return bk, nil
+}
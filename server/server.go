@@ -2,10 +2,8 @@ package server
 
 import (
 	"context"
-	"fmt"
 	"io/ioutil"
 	"log/syslog"
-	"net"
 	"os"
 	"path/filepath"
 	"runtime/debug"
@@ -22,7 +20,7 @@ import (
 // Server is the local api server used by the command client.
 type Server struct {
 	baseServer *server.Server
-	base       *base
+	repos      *repoSet
 }
 
 // Serve blocks until a quit command was send.
@@ -85,8 +83,10 @@ func applyFstabInitially(base *base) error {
 // `basePath` is the path to the repository.
 // `passwordFn` is a function that will deliver a password when
 // no password was configured.
-// `bindHost` is the host to bind too.
-// `port` is the port to listen for requests.
+// Clients connect over a unix socket in `basePath`. The repositories
+// in daemon.repos of its config are served by the same daemon.
+// `bindHost` is the host to bind too, if tcp is enabled.
+// `port` is the port to listen for requests, if tcp is enabled.
 // `logToStdout` should be true when logging to stdout.
 func BootServer(
 	basePath string,
@@ -113,8 +113,7 @@ func BootServer(
 		switchToSyslog()
 	}
 
-	addr := fmt.Sprintf("%s:%d", bindHost, port)
-	log.Infof("starting daemon for %s on port %s", basePath, addr)
+	log.Infof("starting daemon for %s", basePath)
 
 	password, err := readPasswordFromHelper(basePath, passwordFn)
 	if err != nil {
@@ -141,14 +140,16 @@ func BootServer(
 		bindHost,
 		quitCh,
 		logToStdout,
+		newBackendPool(),
 	)
 
-	lst, err := net.Listen("tcp", addr)
+	lst, err := listen(basePath, bindHost, port)
 	if err != nil {
 		return nil, err
 	}
 
-	baseServer, err := server.NewServer(ctx, lst, base)
+	repos := newRepoSet(base, lst)
+	baseServer, err := server.NewServer(ctx, lst, repos)
 	if err != nil {
 		return nil, err
 	}
@@ -166,12 +167,20 @@ func BootServer(
 		return nil, err
 	}
 
+	if err := base.loadMetricsServer(); err != nil {
+		return nil, err
+	}
+
+	base.loadProfileServer()
+
 	if err := applyFstabInitially(base); err != nil {
 		log.Warnf("could not mount fstab mounts: %v", err)
 	}
 
+	repos.loadNamed()
+
 	return &Server{
 		baseServer: baseServer,
-		base:       base,
+		repos:      repos,
 	}, nil
 }
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"io"
+	"sync"
+
+	"github.com/sahib/brig/server/capnp"
+	log "github.com/sirupsen/logrus"
+	"zombiezen.com/go/capnproto2/server"
+)
+
+const (
+	// maxStreamChunkSize limits how much data is sent in a single read call.
+	maxStreamChunkSize = 1024 * 1024
+)
+
+// streamHandler sends the data of a reader to the client over the same
+// connection the client used to make the request. This way no extra port
+// needs to be opened, which anybody on the machine could connect to.
+type streamHandler struct {
+	mu   sync.Mutex
+	name string
+	rc   io.ReadCloser
+	n    int64
+}
+
+func newStream(name string, rc io.ReadCloser) capnp.Stream {
+	return capnp.Stream_ServerToClient(&streamHandler{name: name, rc: rc})
+}
+
+func (sh *streamHandler) Read(call capnp.Stream_read) error {
+	server.Ack(call.Options)
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	size := int(call.Params.Size())
+	if size <= 0 || size > maxStreamChunkSize {
+		size = maxStreamChunkSize
+	}
+
+	// Fill the buffer as far as possible to save round trips.
+	// An empty result tells the client that the stream is over.
+	buf := make([]byte, size)
+	n, err := io.ReadFull(sh.rc, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	sh.n += int64(n)
+	return call.Results.SetData(buf[:n])
+}
+
+// Close is called once the client released the stream
+// or when the connection to it was lost.
+func (sh *streamHandler) Close() error {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	log.Infof("Wrote %d bytes of `%s` to client", sh.n, sh.name)
+	return sh.rc.Close()
+}
@@ -1,7 +1,9 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	e "github.com/pkg/errors"
 	"github.com/sahib/brig/catfs"
@@ -52,6 +54,10 @@ func commitToCap(entry *catfs.Commit, seg *cplib.Segment) (*capnp.Commit, error)
 		return nil, err
 	}
 
+	if err := capEntry.SetSignature(entry.Signature); err != nil {
+		return nil, err
+	}
+
 	return &capEntry, nil
 }
 
@@ -99,8 +105,12 @@ func (vcs *vcsHandler) Commit(call capnp.VCS_commit) error {
 	}
 
 	return vcs.base.withCurrFs(func(fs *catfs.FS) error {
-		msg = "user: " + msg
-		return fs.MakeCommit(msg)
+		if err := fs.MakeCommit("user: " + msg); err != nil {
+			return err
+		}
+
+		vcs.base.recordAudit("vcs.commit", "", msg)
+		return nil
 	})
 }
 
@@ -118,7 +128,12 @@ func (vcs *vcsHandler) Tag(call capnp.VCS_tag) error {
 	}
 
 	return vcs.base.withCurrFs(func(fs *catfs.FS) error {
-		return fs.Tag(rev, tagName)
+		if err := fs.Tag(rev, tagName); err != nil {
+			return err
+		}
+
+		vcs.base.recordAudit("vcs.tag", "", tagName+" at "+rev)
+		return nil
 	})
 }
 
@@ -131,7 +146,12 @@ func (vcs *vcsHandler) Untag(call capnp.VCS_untag) error {
 	}
 
 	return vcs.base.withCurrFs(func(fs *catfs.FS) error {
-		return fs.RemoveTag(tagName)
+		if err := fs.RemoveTag(tagName); err != nil {
+			return err
+		}
+
+		vcs.base.recordAudit("vcs.untag", "", tagName)
+		return nil
 	})
 }
 
@@ -152,7 +172,12 @@ func (vcs *vcsHandler) Reset(call capnp.VCS_reset) error {
 	// the whole commit.
 	if path == "" {
 		return vcs.base.withCurrFs(func(fs *catfs.FS) error {
-			return fs.Checkout(rev, call.Params.Force())
+			if err := fs.Checkout(rev, call.Params.Force()); err != nil {
+				return err
+			}
+
+			vcs.base.recordAudit("vcs.checkout", "", rev)
+			return nil
 		})
 	}
 
@@ -162,6 +187,7 @@ func (vcs *vcsHandler) Reset(call capnp.VCS_reset) error {
 			return err
 		}
 
+		vcs.base.recordAudit("vcs.reset", url.Path, rev)
 		vcs.base.notifyFsChangeEvent()
 		return nil
 	})
@@ -388,11 +414,11 @@ func (vcs *vcsHandler) MakeDiff(call capnp.VCS_makeDiff) error {
 
 	rp := vcs.base.repo
 	if call.Params.NeedFetch() {
-		if err := vcs.base.doFetch(remoteOwner); err != nil {
+		if err := vcs.base.doFetch(vcs.base.ctx, remoteOwner, nil); err != nil {
 			return e.Wrapf(err, "fetch-remote")
 		}
 
-		if err := vcs.base.doFetch(localOwner); err != nil {
+		if err := vcs.base.doFetch(vcs.base.ctx, localOwner, nil); err != nil {
 			return e.Wrapf(err, "fetch-local")
 		}
 	}
@@ -414,9 +440,16 @@ func (vcs *vcsHandler) MakeDiff(call capnp.VCS_makeDiff) error {
 		return err
 	}
 
+	// Only show what a sync would touch,
+	// if we subscribed to specific folders of the remote.
+	diffOpts := []catfs.SyncOption{}
+	if rmt, err := rp.Remotes.Remote(remoteOwner); err == nil {
+		diffOpts = append(diffOpts, catfs.SyncOptOnlyFolders(rmt.SubscribedFolders))
+	}
+
 	return vcs.base.withRemoteFs(localOwner, func(localFs *catfs.FS) error {
 		return vcs.base.withRemoteFs(remoteOwner, func(remoteFs *catfs.FS) error {
-			diff, err := localFs.MakeDiff(remoteFs, localRev, remoteRev)
+			diff, err := localFs.MakeDiff(remoteFs, localRev, remoteRev, diffOpts...)
 			if err != nil {
 				return err
 			}
@@ -439,7 +472,8 @@ func (vcs *vcsHandler) Fetch(call capnp.VCS_fetch) error {
 		return err
 	}
 
-	return vcs.base.doFetch(who)
+	vcs.base.recordAudit("net.fetch", "", who)
+	return vcs.base.doFetch(vcs.base.ctx, who, nil)
 }
 
 func (vcs *vcsHandler) Sync(call capnp.VCS_sync) error {
@@ -450,11 +484,67 @@ func (vcs *vcsHandler) Sync(call capnp.VCS_sync) error {
 		return err
 	}
 
-	diff, err := vcs.base.doSync(withWhom, call.Params.NeedFetch(), "")
+	vcs.base.recordAudit("net.sync", "", withWhom)
+	diff, err := vcs.base.doSync(vcs.base.ctx, withWhom, call.Params.NeedFetch(), "", nil, nil)
+	if err != nil {
+		return err
+	}
+
+	capDiff, err := diffToCapnpDiff(call.Results.Segment(), diff)
+	if err != nil {
+		return err
+	}
+
+	return call.Results.SetDiff(*capDiff)
+}
+
+func (vcs *vcsHandler) SyncStart(call capnp.VCS_syncStart) error {
+	server.Ack(call.Options)
+
+	withWhom, err := call.Params.WithWhom()
+	if err != nil {
+		return err
+	}
+
+	capOnlyFolders, err := call.Params.OnlyFolders()
+	if err != nil {
+		return err
+	}
+
+	onlyFolders, err := capTextListToStrings(capOnlyFolders)
 	if err != nil {
 		return err
 	}
 
+	vcs.base.recordAudit("net.sync", "", withWhom)
+	needFetch := call.Params.NeedFetch()
+	ticket := vcs.base.startJob("sync", withWhom, func(ctx context.Context, rep *jobReporter) error {
+		diff, err := vcs.base.doSync(ctx, withWhom, needFetch, "", onlyFolders, rep)
+		if err != nil {
+			return err
+		}
+
+		// Only one item is pushed, so this will not block.
+		return vcs.base.conductor.Push(rep.status.Ticket, diff)
+	})
+
+	call.Results.SetTicket(ticket)
+	return nil
+}
+
+func (vcs *vcsHandler) SyncResult(call capnp.VCS_syncResult) error {
+	server.Ack(call.Options)
+
+	data, err := vcs.base.conductor.Pop(call.Params.Ticket())
+	if err != nil {
+		return err
+	}
+
+	diff, ok := data.(*catfs.Diff)
+	if !ok {
+		return fmt.Errorf("internal error: wrong type for sync result")
+	}
+
 	capDiff, err := diffToCapnpDiff(call.Results.Segment(), diff)
 	if err != nil {
 		return err
@@ -463,6 +553,23 @@ func (vcs *vcsHandler) Sync(call capnp.VCS_sync) error {
 	return call.Results.SetDiff(*capDiff)
 }
 
+func (vcs *vcsHandler) FetchStart(call capnp.VCS_fetchStart) error {
+	server.Ack(call.Options)
+
+	who, err := call.Params.Who()
+	if err != nil {
+		return err
+	}
+
+	vcs.base.recordAudit("net.fetch", "", who)
+	ticket := vcs.base.startJob("fetch", who, func(ctx context.Context, rep *jobReporter) error {
+		return vcs.base.doFetch(ctx, who, rep)
+	})
+
+	call.Results.SetTicket(ticket)
+	return nil
+}
+
 func (vcs *vcsHandler) CommitInfo(call capnp.VCS_commitInfo) error {
 	server.Ack(call.Options)
 
@@ -492,3 +599,24 @@ func (vcs *vcsHandler) CommitInfo(call capnp.VCS_commitInfo) error {
 		return nil
 	})
 }
+
+func (vcs *vcsHandler) HistoryPrune(call capnp.VCS_historyPrune) error {
+	server.Ack(call.Options)
+
+	var keepSince time.Time
+	if keepSeconds := call.Params.KeepSeconds(); keepSeconds > 0 {
+		keepSince = time.Now().Add(-time.Duration(keepSeconds) * time.Second)
+	}
+
+	keepLast := int(call.Params.KeepLast())
+	return vcs.base.withCurrFs(func(fs *catfs.FS) error {
+		nPruned, err := fs.PruneHistory(keepSince, keepLast)
+		if err != nil {
+			return err
+		}
+
+		vcs.base.recordAudit("vcs.prune", "", fmt.Sprintf("%d pruned", nPruned))
+		call.Results.SetPruned(int64(nPruned))
+		return nil
+	})
+}
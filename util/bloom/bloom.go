@@ -0,0 +1,104 @@
+// Package bloom implements a small bloom filter that can be sent over the
+// network to tell other peers what content we have, without listing it.
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+)
+
+var (
+	// ErrBadFilter is returned by FromBytes on malformed data.
+	ErrBadFilter = errors.New("bad bloom filter data")
+)
+
+// Filter is a bloom filter. Test may return true for data that was never
+// added (with the false positive rate given to New), but never false for
+// data that was added.
+type Filter struct {
+	bits []byte
+	k    uint32
+}
+
+// New returns a filter sized for `n` elements with a false
+// positive rate of about `fpRate` (e.g. 0.01 for 1%).
+func New(n int, fpRate float64) *Filter {
+	if n < 1 {
+		n = 1
+	}
+
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+
+	// See https://en.wikipedia.org/wiki/Bloom_filter#Optimal_number_of_hash_functions
+	m := math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k := math.Ceil(m / float64(n) * math.Ln2)
+
+	return &Filter{
+		bits: make([]byte, (uint64(m)+7)/8),
+		k:    uint32(k),
+	}
+}
+
+// indices yields the k bit positions of `data` using double hashing.
+func (f *Filter) indices(data []byte, fn func(idx uint64)) {
+	h1 := fnv.New64()
+	h1.Write(data)
+	a := h1.Sum64()
+
+	h2 := fnv.New64a()
+	h2.Write(data)
+	b := h2.Sum64() | 1
+
+	m := uint64(len(f.bits)) * 8
+	for i := uint64(0); i < uint64(f.k); i++ {
+		fn((a + i*b) % m)
+	}
+}
+
+// Add adds `data` to the filter.
+func (f *Filter) Add(data []byte) {
+	f.indices(data, func(idx uint64) {
+		f.bits[idx/8] |= 1 << (idx % 8)
+	})
+}
+
+// Test returns true if `data` was (probably) added to the filter.
+func (f *Filter) Test(data []byte) bool {
+	found := true
+	f.indices(data, func(idx uint64) {
+		if f.bits[idx/8]&(1<<(idx%8)) == 0 {
+			found = false
+		}
+	})
+
+	return found
+}
+
+// Bytes returns the serialized form of the filter.
+func (f *Filter) Bytes() []byte {
+	data := make([]byte, 4+len(f.bits))
+	binary.BigEndian.PutUint32(data, f.k)
+	copy(data[4:], f.bits)
+	return data
+}
+
+// FromBytes reads a filter serialized with Bytes.
+func FromBytes(data []byte) (*Filter, error) {
+	if len(data) < 5 {
+		return nil, ErrBadFilter
+	}
+
+	k := binary.BigEndian.Uint32(data)
+	if k == 0 || k > 64 {
+		return nil, ErrBadFilter
+	}
+
+	return &Filter{
+		bits: append([]byte{}, data[4:]...),
+		k:    k,
+	}, nil
+}
@@ -0,0 +1,35 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	f := New(1000, 0.01)
+	for idx := 0; idx < 1000; idx++ {
+		f.Add([]byte(fmt.Sprintf("in-%d", idx)))
+	}
+
+	loaded, err := FromBytes(f.Bytes())
+	require.Nil(t, err)
+
+	for idx := 0; idx < 1000; idx++ {
+		require.True(t, loaded.Test([]byte(fmt.Sprintf("in-%d", idx))))
+	}
+
+	falsePositives := 0
+	for idx := 0; idx < 1000; idx++ {
+		if loaded.Test([]byte(fmt.Sprintf("out-%d", idx))) {
+			falsePositives++
+		}
+	}
+
+	// Expected are about 10, leave some room for bad luck:
+	require.True(t, falsePositives < 50, "too many false positives: %d", falsePositives)
+
+	_, err = FromBytes([]byte{1, 2})
+	require.Equal(t, ErrBadFilter, err)
+}
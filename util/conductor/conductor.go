@@ -3,7 +3,9 @@
 package conductor
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
@@ -56,6 +58,8 @@ type Conductor struct {
 	ticketCount uint64
 	tickets     map[uint64]chan interface{}
 	errors      map[uint64]error
+	cancels     map[uint64]context.CancelFunc
+	status      map[uint64]interface{}
 }
 
 // New creates a new conductor that will expire unfetched results
@@ -66,6 +70,8 @@ func New(timeout time.Duration, maxData int) *Conductor {
 		maxData: maxData,
 		tickets: make(map[uint64]chan interface{}),
 		errors:  make(map[uint64]error),
+		cancels: make(map[uint64]context.CancelFunc),
+		status:  make(map[uint64]interface{}),
 	}
 }
 
@@ -74,6 +80,15 @@ func New(timeout time.Duration, maxData int) *Conductor {
 // that can be used by the caller to Pull() partial results until ErrNoDataLeft
 // is returned.
 func (cd *Conductor) Exec(fn func(ticket uint64) error) uint64 {
+	return cd.ExecWithContext(context.Background(), func(ctx context.Context, ticket uint64) error {
+		return fn(ticket)
+	})
+}
+
+// ExecWithContext works like Exec, but `fn` gets passed a context
+// derived from `ctx` that is canceled once Cancel() was called
+// for this ticket. It is up to `fn` to check the context regularly.
+func (cd *Conductor) ExecWithContext(ctx context.Context, fn func(ctx context.Context, ticket uint64) error) uint64 {
 	cd.mu.Lock()
 	defer cd.mu.Unlock()
 
@@ -81,8 +96,11 @@ func (cd *Conductor) Exec(fn func(ticket uint64) error) uint64 {
 	ticket := cd.ticketCount
 	cd.tickets[ticket] = make(chan interface{}, cd.maxData)
 
+	subCtx, cancel := context.WithCancel(ctx)
+	cd.cancels[ticket] = cancel
+
 	go func() {
-		if err := fn(ticket); err != nil {
+		if err := fn(subCtx, ticket); err != nil {
 			cd.mu.Lock()
 			cd.errors[ticket] = err
 			cd.mu.Unlock()
@@ -92,19 +110,90 @@ func (cd *Conductor) Exec(fn func(ticket uint64) error) uint64 {
 		if ticketCh, ok := cd.tickets[ticket]; ok {
 			close(ticketCh)
 		}
+
+		// Release the context resources early,
+		// there is nothing left to cancel anyways.
+		delete(cd.cancels, ticket)
 		cd.mu.Unlock()
+		cancel()
 
 		time.Sleep(cd.timeout)
 
 		cd.mu.Lock()
 		delete(cd.tickets, ticket)
 		delete(cd.errors, ticket)
+		delete(cd.status, ticket)
 		cd.mu.Unlock()
 	}()
 
 	return ticket
 }
 
+// Cancel cancels the context of the job associated with `ticket`.
+// Calling Cancel on a job that already finished is not an error.
+// It will return ErrNoSuchTicket if you passed an invalid ticket.
+func (cd *Conductor) Cancel(ticket uint64) error {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+
+	if _, ok := cd.tickets[ticket]; !ok {
+		return ErrNoSuchTicket{ticket}
+	}
+
+	if cancel, ok := cd.cancels[ticket]; ok {
+		cancel()
+	}
+
+	return nil
+}
+
+// SetStatus remembers `status` as the latest status of the job
+// behind `ticket`. Unlike Push() it will never block and will
+// overwrite any previous status. Useful for progress reporting.
+func (cd *Conductor) SetStatus(ticket uint64, status interface{}) error {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+
+	if _, ok := cd.tickets[ticket]; !ok {
+		return ErrNoSuchTicket{ticket}
+	}
+
+	cd.status[ticket] = status
+	return nil
+}
+
+// Status returns the last status set by SetStatus() for `ticket`.
+// If no status was set yet, nil is returned.
+func (cd *Conductor) Status(ticket uint64) (interface{}, error) {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+
+	if _, ok := cd.tickets[ticket]; !ok {
+		return nil, ErrNoSuchTicket{ticket}
+	}
+
+	return cd.status[ticket], nil
+}
+
+// Tickets returns all tickets that are currently known,
+// including the ones that are finished but did not expire yet.
+// The tickets are returned in ascending order.
+func (cd *Conductor) Tickets() []uint64 {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+
+	tickets := make([]uint64, 0, len(cd.tickets))
+	for ticket := range cd.tickets {
+		tickets = append(tickets, ticket)
+	}
+
+	sort.Slice(tickets, func(i, j int) bool {
+		return tickets[i] < tickets[j]
+	})
+
+	return tickets
+}
+
 // Push records a new partial result under a specific `ticket`.
 // Push will block after pushing more than `maxData` items.
 func (cd *Conductor) Push(ticket uint64, data interface{}) error {
@@ -1,6 +1,7 @@
 package conductor
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
@@ -97,3 +98,44 @@ func TestConductorTimeoutError(t *testing.T) {
 	_, err = cnd.Pop(ticket)
 	require.True(t, IsNoSuchTicket(err))
 }
+
+func TestConductorCancel(t *testing.T) {
+	cnd := New(5*time.Second, 10)
+	ticket := cnd.ExecWithContext(context.Background(), func(ctx context.Context, ticket uint64) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	require.Nil(t, cnd.Cancel(ticket))
+	time.Sleep(10 * time.Millisecond)
+
+	_, err := cnd.Pop(ticket)
+	require.Equal(t, context.Canceled, err)
+
+	// Canceling twice is fine, as long as the ticket is still around:
+	require.Nil(t, cnd.Cancel(ticket))
+	require.True(t, IsNoSuchTicket(cnd.Cancel(ticket+1)))
+}
+
+func TestConductorStatus(t *testing.T) {
+	cnd := New(5*time.Second, 10)
+	ticket := cnd.Exec(func(ticket uint64) error {
+		for i := 0; i < 20; i++ {
+			// Would block with Push() at some point:
+			require.Nil(t, cnd.SetStatus(ticket, i))
+		}
+
+		return nil
+	})
+
+	_, err := cnd.Pop(ticket)
+	require.True(t, IsNoDataLeft(err))
+
+	status, err := cnd.Status(ticket)
+	require.Nil(t, err)
+	require.Equal(t, 19, status)
+	require.Equal(t, []uint64{ticket}, cnd.Tickets())
+
+	_, err = cnd.Status(ticket + 1)
+	require.True(t, IsNoSuchTicket(err))
+}
@@ -83,6 +83,16 @@ func FromB58String(b58 string) (Hash, error) {
 	return Hash(mh), nil
 }
 
+// MarshalJSON encodes the hash as base58 string,
+// which is also what UnmarshalJSON expects.
+func (h Hash) MarshalJSON() ([]byte, error) {
+	if h == nil {
+		return []byte("null"), nil
+	}
+
+	return []byte(strconv.Quote(h.B58String())), nil
+}
+
 // UnmarshalJSON loads a base58 string representation of a hash
 // and converts it to raw bytes.
 func (h Hash) UnmarshalJSON(data []byte) error {
@@ -1,6 +1,7 @@
 package hashlib
 
 import (
+	"encoding/json"
 	"testing"
 )
 
@@ -22,3 +23,15 @@ func TestHashWriter(t *testing.T) {
 		t.Fatalf("hashes differ due to different feed order")
 	}
 }
+
+func TestHashMarshalJSON(t *testing.T) {
+	hash := Sum([]byte("hello"))
+	data, err := json.Marshal(struct{ Hash Hash }{hash})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	if string(data) != `{"Hash":"`+hash.B58String()+`"}` {
+		t.Fatalf("hash was not encoded as base58: %s", data)
+	}
+}
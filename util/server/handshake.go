@@ -0,0 +1,116 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	handshakeTimeout = 10 * time.Second
+	maxHandshakeLine = 512
+)
+
+// readLine reads a single line byte by byte, so that nothing
+// of what follows the handshake is consumed.
+func readLine(r io.Reader) (string, error) {
+	line := make([]byte, 0, 64)
+	buf := make([]byte, 1)
+	for len(line) < maxHandshakeLine {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+
+		if buf[0] == '\n' {
+			return string(line), nil
+		}
+
+		line = append(line, buf[0])
+	}
+
+	return "", errors.New("handshake line is too long")
+}
+
+// Hello is what a client tells the server before using a connection.
+type Hello struct {
+	// Token authenticates the client. It may be empty
+	// if the server does not need one.
+	Token string
+
+	// Repo is the name of the repository the client wants to use.
+	// It is empty for the repository the server was started for.
+	Repo string
+}
+
+func parseHello(line string) Hello {
+	split := strings.SplitN(line, " ", 2)
+	hello := Hello{Token: split[0]}
+	if len(split) > 1 {
+		hello.Repo = split[1]
+	}
+
+	return hello
+}
+
+func (hello Hello) String() string {
+	if hello.Repo == "" {
+		return hello.Token
+	}
+
+	return hello.Token + " " + hello.Repo
+}
+
+// ClientHandshake has to be done by clients before using `conn`.
+// It sends `hello` and returns an error if the server rejected the connection.
+func ClientHandshake(conn net.Conn, hello Hello) error {
+	if strings.ContainsAny(hello.Token, " \n") || strings.Contains(hello.Repo, "\n") {
+		return errors.New("token or repo name contain invalid characters")
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(conn, hello.String()+"\n"); err != nil {
+		return err
+	}
+
+	status, err := readLine(conn)
+	if err != nil {
+		return fmt.Errorf("handshake failed: %v", err)
+	}
+
+	if status != "OK" {
+		return fmt.Errorf("connection rejected: %s", strings.TrimPrefix(status, "ERR "))
+	}
+
+	return conn.SetDeadline(time.Time{})
+}
+
+// ServerHandshake reads the hello sent by ClientHandshake and calls `check`
+// with it. The client is told if check accepted the connection.
+func ServerHandshake(conn net.Conn, check func(hello Hello) error) error {
+	if err := conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return err
+	}
+
+	line, err := readLine(conn)
+	if err != nil {
+		return err
+	}
+
+	if err := check(parseHello(line)); err != nil {
+		// Tell the client why; it does not matter if that fails anymore.
+		io.WriteString(conn, "ERR "+strings.Replace(err.Error(), "\n", " ", -1)+"\n")
+		return err
+	}
+
+	if _, err := io.WriteString(conn, "OK\n"); err != nil {
+		return err
+	}
+
+	return conn.SetDeadline(time.Time{})
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+type acceptTimeoutErr struct{}
+
+func (acceptTimeoutErr) Error() string   { return "accept timed out" }
+func (acceptTimeoutErr) Timeout() bool   { return true }
+func (acceptTimeoutErr) Temporary() bool { return true }
+
+// multiListener accepts connections from several listeners at once.
+type multiListener struct {
+	lsts []net.Listener
+
+	mu       sync.Mutex
+	deadline time.Time
+
+	connCh    chan net.Conn
+	errCh     chan error
+	doneCh    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewMultiListener returns a listener that accepts connections from all
+// of `lsts`. Closing it closes all of them. It implements DeadlineListener,
+// so Server can still react on quit signals while waiting for connections.
+func NewMultiListener(lsts ...net.Listener) net.Listener {
+	ml := &multiListener{
+		lsts:   lsts,
+		connCh: make(chan net.Conn),
+		errCh:  make(chan error),
+		doneCh: make(chan struct{}),
+	}
+
+	for _, lst := range lsts {
+		go ml.acceptLoop(lst)
+	}
+
+	return ml
+}
+
+func (ml *multiListener) acceptLoop(lst net.Listener) {
+	for {
+		conn, err := lst.Accept()
+		if err != nil {
+			select {
+			case ml.errCh <- err:
+			case <-ml.doneCh:
+				return
+			}
+
+			if strings.HasSuffix(err.Error(), "use of closed network connection") {
+				return
+			}
+
+			continue
+		}
+
+		select {
+		case ml.connCh <- conn:
+		case <-ml.doneCh:
+			conn.Close()
+			return
+		}
+	}
+}
+
+func (ml *multiListener) Accept() (net.Conn, error) {
+	ml.mu.Lock()
+	deadline := ml.deadline
+	ml.mu.Unlock()
+
+	var timeoutCh <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	select {
+	case conn := <-ml.connCh:
+		return conn, nil
+	case err := <-ml.errCh:
+		return nil, err
+	case <-timeoutCh:
+		return nil, acceptTimeoutErr{}
+	case <-ml.doneCh:
+		return nil, net.ErrClosed
+	}
+}
+
+// SetDeadline sets the time after which Accept gives up waiting.
+func (ml *multiListener) SetDeadline(deadline time.Time) error {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	ml.deadline = deadline
+	return nil
+}
+
+func (ml *multiListener) Close() error {
+	var firstErr error
+	ml.closeOnce.Do(func() {
+		close(ml.doneCh)
+		for _, lst := range ml.lsts {
+			if err := lst.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	})
+
+	return firstErr
+}
+
+// Addr returns the address of the first listener.
+func (ml *multiListener) Addr() net.Addr {
+	return ml.lsts[0].Addr()
+}
@@ -0,0 +1,43 @@
+// +build linux
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// CheckPeerCredentials makes sure that the process on the other end of
+// `conn` runs as the same user as this process.
+func CheckPeerCredentials(conn *net.UnixConn) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	err = rawConn.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(
+			int(fd),
+			syscall.SOL_SOCKET,
+			syscall.SO_PEERCRED,
+		)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if credErr != nil {
+		return credErr
+	}
+
+	if int(cred.Uid) != os.Getuid() {
+		return fmt.Errorf("peer runs as uid %d (pid %d), not as %d", cred.Uid, cred.Pid, os.Getuid())
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ListenUnix listens on a unix socket at `path` that only the current user
+// can connect to. A socket left over by a crashed process is removed,
+// but an error is returned if somebody still listens on it.
+func ListenUnix(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		conn, err := net.DialTimeout("unix", path, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("somebody is already listening on %s", path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	lst, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Peer credentials should be checked too, but do not
+	// let anyone else connect in the first place.
+	if err := os.Chmod(path, 0600); err != nil {
+		lst.Close()
+		return nil, err
+	}
+
+	return lst, nil
+}
+
+// ReadOrCreateToken reads the token stored at `path`. If there is none yet,
+// a random one is created and stored there, readable only by the current user.
+func ReadOrCreateToken(path string) (string, error) {
+	data, err := ioutil.ReadFile(path) // #nosec
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	token := hex.EncodeToString(buf)
+	if err := ioutil.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
@@ -0,0 +1,129 @@
+package throttle
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+)
+
+type reader struct {
+	r     io.Reader
+	meter *Meter
+	lims  []*Limiter
+}
+
+// NewReader returns a reader that reads from `r` no faster than all
+// of `lims` allow. Read bytes are recorded in `meter`, if not nil.
+func NewReader(r io.Reader, meter *Meter, lims ...*Limiter) io.Reader {
+	return &reader{r: r, meter: meter, lims: lims}
+}
+
+func (rd *reader) Read(buf []byte) (int, error) {
+	if len(buf) > chunkSize {
+		buf = buf[:chunkSize]
+	}
+
+	n, err := rd.r.Read(buf)
+	if n > 0 {
+		if rd.meter != nil {
+			rd.meter.Add(n)
+		}
+
+		// Wait after reading; we don't know how much we get beforehand.
+		if waitErr := waitAll(context.Background(), n, rd.lims); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
+
+// Conn is a net.Conn whose reads (download) and writes (upload) are limited.
+// Limiters can be added while the connection is in use.
+type Conn struct {
+	net.Conn
+
+	mu        sync.Mutex
+	upMeter   *Meter
+	downMeter *Meter
+	upLims    []*Limiter
+	downLims  []*Limiter
+}
+
+// NewConn wraps `conn`. Written bytes are recorded in `upMeter`, read
+// bytes in `downMeter`. Both may be nil. Use Limit() to add limits.
+func NewConn(conn net.Conn, upMeter, downMeter *Meter) *Conn {
+	return &Conn{
+		Conn:      conn,
+		upMeter:   upMeter,
+		downMeter: downMeter,
+	}
+}
+
+// Limit adds limiters for writing (`up`) and reading (`down`).
+// Either may be nil.
+func (cn *Conn) Limit(up, down *Limiter) {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+
+	cn.upLims = append(cn.upLims, up)
+	cn.downLims = append(cn.downLims, down)
+}
+
+func (cn *Conn) limiters() ([]*Limiter, []*Limiter) {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+
+	return cn.upLims, cn.downLims
+}
+
+func (cn *Conn) Read(buf []byte) (int, error) {
+	if len(buf) > chunkSize {
+		buf = buf[:chunkSize]
+	}
+
+	n, err := cn.Conn.Read(buf)
+	if n > 0 {
+		if cn.downMeter != nil {
+			cn.downMeter.Add(n)
+		}
+
+		_, downLims := cn.limiters()
+		if waitErr := waitAll(context.Background(), n, downLims); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
+
+func (cn *Conn) Write(buf []byte) (int, error) {
+	upLims, _ := cn.limiters()
+
+	written := 0
+	for len(buf) > 0 {
+		chunk := buf
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
+		}
+
+		if err := waitAll(context.Background(), len(chunk), upLims); err != nil {
+			return written, err
+		}
+
+		n, err := cn.Conn.Write(chunk)
+		written += n
+		if cn.upMeter != nil {
+			cn.upMeter.Add(n)
+		}
+
+		if err != nil {
+			return written, err
+		}
+
+		buf = buf[n:]
+	}
+
+	return written, nil
+}
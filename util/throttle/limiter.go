@@ -0,0 +1,123 @@
+// Package throttle implements bandwidth limits and throughput measurements
+// for readers, writers and network connections.
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dustin/go-humanize"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// chunkSize is the max. number of bytes we wait for at once.
+	// It is also the burst size of a limiter.
+	chunkSize = 64 * 1024
+)
+
+// ParseRate parses a human readable rate like "2MB" or "512 KiB/s"
+// into bytes per second. "unlimited", "none", "0" and the empty
+// string all mean no limit, which is returned as 0.
+func ParseRate(s string) (uint64, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	s = strings.TrimSuffix(s, "/s")
+
+	switch s {
+	case "", "0", "none", "unlimited":
+		return 0, nil
+	}
+
+	bytesPerSec, err := humanize.ParseBytes(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate `%s`: %v", s, err)
+	}
+
+	return bytesPerSec, nil
+}
+
+// FormatRate is the inverse of ParseRate.
+func FormatRate(bytesPerSec uint64) string {
+	if bytesPerSec == 0 {
+		return "unlimited"
+	}
+
+	return humanize.Bytes(bytesPerSec) + "/s"
+}
+
+// Limiter limits the number of bytes that may pass per second.
+// A single limiter can be shared by many readers and writers.
+type Limiter struct {
+	mu          sync.Mutex
+	bytesPerSec uint64
+	lim         *rate.Limiter
+}
+
+// NewLimiter returns a new limiter allowing `bytesPerSec`.
+// If `bytesPerSec` is zero, the limiter does not limit anything.
+func NewLimiter(bytesPerSec uint64) *Limiter {
+	lim := &Limiter{
+		lim: rate.NewLimiter(rate.Inf, chunkSize),
+	}
+
+	lim.SetRate(bytesPerSec)
+	return lim
+}
+
+// SetRate changes the rate of the limiter.
+// It also affects readers and writers that are already running.
+func (lim *Limiter) SetRate(bytesPerSec uint64) {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	lim.bytesPerSec = bytesPerSec
+	if bytesPerSec == 0 {
+		lim.lim.SetLimit(rate.Inf)
+		return
+	}
+
+	lim.lim.SetLimit(rate.Limit(bytesPerSec))
+}
+
+// Rate returns the current rate in bytes per second (0 means unlimited).
+func (lim *Limiter) Rate() uint64 {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	return lim.bytesPerSec
+}
+
+// WaitN blocks until `n` bytes may pass.
+func (lim *Limiter) WaitN(ctx context.Context, n int) error {
+	for n > 0 {
+		chunk := n
+		if chunk > chunkSize {
+			chunk = chunkSize
+		}
+
+		if err := lim.lim.WaitN(ctx, chunk); err != nil {
+			return err
+		}
+
+		n -= chunk
+	}
+
+	return nil
+}
+
+// waitAll waits for `n` bytes on all `lims`. nil limiters are ignored.
+func waitAll(ctx context.Context, n int, lims []*Limiter) error {
+	for _, lim := range lims {
+		if lim == nil {
+			continue
+		}
+
+		if err := lim.WaitN(ctx, n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
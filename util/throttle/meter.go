@@ -0,0 +1,71 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// meterSlots is the number of seconds the throughput is averaged over.
+	meterSlots = 5
+)
+
+// Meter measures the throughput of some data stream
+// as average over the last few seconds.
+type Meter struct {
+	mu    sync.Mutex
+	slots [meterSlots]uint64
+	last  int64
+	now   func() time.Time
+}
+
+// NewMeter returns a new, zeroed meter.
+func NewMeter() *Meter {
+	return &Meter{now: time.Now}
+}
+
+// advance clears all slots that lie between the last record and now.
+func (mt *Meter) advance() int64 {
+	sec := mt.now().Unix()
+	if sec-mt.last >= meterSlots {
+		mt.slots = [meterSlots]uint64{}
+	} else {
+		for s := mt.last + 1; s <= sec; s++ {
+			mt.slots[s%meterSlots] = 0
+		}
+	}
+
+	if sec > mt.last {
+		mt.last = sec
+	}
+
+	return sec
+}
+
+// Add records that `n` bytes passed just now.
+func (mt *Meter) Add(n int) {
+	if n <= 0 {
+		return
+	}
+
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	sec := mt.advance()
+	mt.slots[sec%meterSlots] += uint64(n)
+}
+
+// Rate returns the average number of bytes per second.
+func (mt *Meter) Rate() uint64 {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	mt.advance()
+
+	sum := uint64(0)
+	for _, slot := range mt.slots {
+		sum += slot
+	}
+
+	return sum / meterSlots
+}
@@ -0,0 +1,156 @@
+package throttle
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Rate is a pair of upload and download limits in bytes per second.
+// A zero value means no limit.
+type Rate struct {
+	Up   uint64
+	Down uint64
+}
+
+// Window is a time of the day during which other limits apply.
+// If End is before Start, the window goes over midnight.
+// If both are the same, the window spans the whole day.
+type Window struct {
+	// Start and End are minutes since midnight (local time).
+	Start int
+	End   int
+	Rate  Rate
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time `%s` (use HH:MM)", s)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ParseWindow parses a window description. Examples:
+//
+//	20:00-06:00 unlimited      # No limits over night.
+//	08:00-18:00 1MB            # 1MB/s up and down during work hours.
+//	08:00-18:00 512KB/4MB      # 512KB/s up and 4MB/s down.
+//
+// The rate may also be given before the time range.
+func ParseWindow(s string) (Window, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return Window{}, fmt.Errorf("invalid window `%s`: need a time range and a rate", s)
+	}
+
+	timeRange, rateSpec := fields[0], fields[1]
+	if !strings.Contains(timeRange, ":") {
+		timeRange, rateSpec = rateSpec, timeRange
+	}
+
+	bounds := strings.Split(timeRange, "-")
+	if len(bounds) != 2 {
+		return Window{}, fmt.Errorf("invalid time range `%s` (use HH:MM-HH:MM)", timeRange)
+	}
+
+	start, err := parseClock(bounds[0])
+	if err != nil {
+		return Window{}, err
+	}
+
+	end, err := parseClock(bounds[1])
+	if err != nil {
+		return Window{}, err
+	}
+
+	rate, err := ParseRatePair(rateSpec)
+	if err != nil {
+		return Window{}, err
+	}
+
+	return Window{Start: start, End: end, Rate: rate}, nil
+}
+
+// ParseRatePair parses either a single rate (used for up and down)
+// or two rates separated by a slash (up/down).
+func ParseRatePair(s string) (Rate, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) == 2 && strings.ToLower(parts[1]) == "s" {
+		// Just something like "2MB/s".
+		parts = parts[:1]
+	}
+
+	switch len(parts) {
+	case 1:
+		bytesPerSec, err := ParseRate(parts[0])
+		if err != nil {
+			return Rate{}, err
+		}
+
+		return Rate{Up: bytesPerSec, Down: bytesPerSec}, nil
+	case 2:
+		up, err := ParseRate(parts[0])
+		if err != nil {
+			return Rate{}, err
+		}
+
+		down, err := ParseRate(parts[1])
+		if err != nil {
+			return Rate{}, err
+		}
+
+		return Rate{Up: up, Down: down}, nil
+	default:
+		return Rate{}, fmt.Errorf("invalid rate `%s` (use <rate> or <up>/<down>)", s)
+	}
+}
+
+// Contains checks if `t` lies inside of the window.
+func (w Window) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.Start == w.End {
+		return true
+	}
+
+	if w.Start < w.End {
+		return minute >= w.Start && minute < w.End
+	}
+
+	// Goes over midnight:
+	return minute >= w.Start || minute < w.End
+}
+
+// Schedule decides what rate to use at a certain time of the day.
+type Schedule struct {
+	Default Rate
+	Windows []Window
+}
+
+// ParseSchedule builds a schedule from a list of window descriptions.
+func ParseSchedule(def Rate, windows []string) (Schedule, error) {
+	sched := Schedule{Default: def}
+	for _, spec := range windows {
+		window, err := ParseWindow(spec)
+		if err != nil {
+			return Schedule{}, err
+		}
+
+		sched.Windows = append(sched.Windows, window)
+	}
+
+	return sched, nil
+}
+
+// RateAt returns the rate of the first window containing `t`
+// or the default rate if there is none.
+func (sc Schedule) RateAt(t time.Time) Rate {
+	for _, window := range sc.Windows {
+		if window.Contains(t) {
+			return window.Rate
+		}
+	}
+
+	return sc.Default
+}
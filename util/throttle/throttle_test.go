@@ -0,0 +1,103 @@
+package throttle
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRate(t *testing.T) {
+	tcs := []struct {
+		input    string
+		expected uint64
+	}{
+		{"unlimited", 0},
+		{"", 0},
+		{"1MB", 1000 * 1000},
+		{"512 KiB/s", 512 * 1024},
+		{"2mb/s", 2000 * 1000},
+	}
+
+	for _, tc := range tcs {
+		rate, err := ParseRate(tc.input)
+		require.Nil(t, err, tc.input)
+		require.Equal(t, tc.expected, rate, tc.input)
+	}
+
+	_, err := ParseRate("fast")
+	require.NotNil(t, err)
+}
+
+func TestSchedule(t *testing.T) {
+	sched, err := ParseSchedule(Rate{Up: 100, Down: 200}, []string{
+		"20:00-06:00 unlimited",
+		"12:00-13:00 1KB/2KB",
+	})
+	require.Nil(t, err)
+
+	at := func(clock string) time.Time {
+		t, _ := time.Parse("15:04", clock)
+		return t
+	}
+
+	require.Equal(t, Rate{}, sched.RateAt(at("23:30")))
+	require.Equal(t, Rate{}, sched.RateAt(at("05:59")))
+	require.Equal(t, Rate{Up: 100, Down: 200}, sched.RateAt(at("06:00")))
+	require.Equal(t, Rate{Up: 1000, Down: 2000}, sched.RateAt(at("12:30")))
+
+	// The rate might also come first:
+	window, err := ParseWindow("unlimited 20:00-06:00")
+	require.Nil(t, err)
+	require.Equal(t, sched.Windows[0], window)
+
+	_, err = ParseWindow("25:00-06:00 1MB")
+	require.NotNil(t, err)
+	_, err = ParseWindow("20:00 1MB")
+	require.NotNil(t, err)
+}
+
+func TestReaderLimit(t *testing.T) {
+	data := bytes.Repeat([]byte{42}, 4*chunkSize)
+	meter := NewMeter()
+	lim := NewLimiter(uint64(8 * chunkSize))
+
+	// The first chunk is free (burst); the rest needs ~375ms.
+	start := time.Now()
+	read, err := ioutil.ReadAll(NewReader(bytes.NewReader(data), meter, lim))
+	require.Nil(t, err)
+	require.Equal(t, data, read)
+	require.True(t, time.Since(start) > 300*time.Millisecond)
+	require.Equal(t, uint64(len(data)/meterSlots), meter.Rate())
+
+	// Unlimited limiters do not wait:
+	lim.SetRate(0)
+	start = time.Now()
+	_, err = ioutil.ReadAll(NewReader(bytes.NewReader(data), nil, lim))
+	require.Nil(t, err)
+	require.True(t, time.Since(start) < 100*time.Millisecond)
+}
+
+func TestConnLimit(t *testing.T) {
+	a, b := net.Pipe()
+	upMeter, downMeter := NewMeter(), NewMeter()
+	conn := NewConn(a, upMeter, downMeter)
+	conn.Limit(NewLimiter(uint64(4*chunkSize)), nil)
+
+	data := bytes.Repeat([]byte{23}, 3*chunkSize)
+	go func() {
+		conn.Write(data)
+		conn.Close()
+	}()
+
+	start := time.Now()
+	read, err := ioutil.ReadAll(b)
+	require.Nil(t, err)
+	require.Equal(t, data, read)
+	require.True(t, time.Since(start) > 400*time.Millisecond)
+	require.Equal(t, uint64(len(data)/meterSlots), upMeter.Rate())
+	require.Equal(t, uint64(0), downMeter.Rate())
+}